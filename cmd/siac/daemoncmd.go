@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -19,6 +21,24 @@ var (
 		Run:   wrap(alertscmd),
 	}
 
+	alertsDismissCmd = &cobra.Command{
+		Use:   "dismiss [module] [id]",
+		Short: "dismiss an alert",
+		Long: `Dismiss the alert with the given id of the given module. A dismissed
+alert is still reported but no longer displayed with every siac command. It
+stays dismissed until it is registered again with a different cause.`,
+		Run: wrap(alertsdismisscmd),
+	}
+
+	alertsSnoozeCmd = &cobra.Command{
+		Use:   "snooze [module] [id] [duration]",
+		Short: "snooze an alert",
+		Long: `Snooze the alert with the given id of the given module for the given
+duration, e.g. 12h. A snoozed alert is still reported but no longer displayed
+with every siac command until the duration has passed.`,
+		Run: wrap(alertssnoozecmd),
+	}
+
 	stopCmd = &cobra.Command{
 		Use:   "stop",
 		Short: "Stop the Sia daemon",
@@ -139,6 +159,39 @@ func alertscmd() {
 	}
 }
 
+// alertsdismisscmd dismisses an alert.
+func alertsdismisscmd(module, id string) {
+	err := httpClient.DaemonAlertsDismissPost(module, modules.AlertID(id))
+	if err != nil {
+		die("Could not dismiss alert:", err)
+	}
+	fmt.Println("Alert dismissed")
+}
+
+// alertssnoozecmd snoozes an alert for the given duration.
+func alertssnoozecmd(module, id, duration string) {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		die("Could not parse duration:", err)
+	}
+	err = httpClient.DaemonAlertsSnoozePost(module, modules.AlertID(id), d)
+	if err != nil {
+		die("Could not snooze alert:", err)
+	}
+	fmt.Printf("Alert snoozed for %v\n", d)
+}
+
+// activeAlerts returns the alerts that are neither dismissed nor snoozed.
+func activeAlerts(alerts []modules.Alert) []modules.Alert {
+	var active []modules.Alert
+	for _, a := range alerts {
+		if !a.Dismissed && !a.Snoozed() {
+			active = append(active, a)
+		}
+	}
+	return active
+}
+
 // profilecmd displays the usage info for the command.
 func profilecmd(cmd *cobra.Command, args []string) {
 	_ = cmd.UsageFunc()(cmd)
@@ -298,9 +351,31 @@ func printAlerts(alerts []modules.Alert, as modules.AlertSeverity) {
 		fmt.Printf(`
 ------------------
   Module:   %s
+  ID:       %s
   Severity: %s
   Message:  %s
-  Cause:    %s`, a.Module, a.Severity.String(), a.Msg, a.Cause)
+  Cause:    %s`, a.Module, a.ID, a.Severity.String(), a.Msg, a.Cause)
+		keys := make([]string, 0, len(a.Details))
+		for k := range a.Details {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf(`
+  %-9s %s`, k+":", a.Details[k])
+		}
+		if !a.FirstRegistered.IsZero() {
+			fmt.Printf(`
+  Age:      %v
+  Count:    %v`, time.Since(a.FirstRegistered).Round(time.Second), a.Count)
+		}
+		if a.Dismissed {
+			fmt.Printf(`
+  Status:   dismissed`)
+		} else if a.Snoozed() {
+			fmt.Printf(`
+  Status:   snoozed for %v`, time.Until(a.SnoozeUntil).Round(time.Second))
+		}
 	}
 	fmt.Printf("\n------------------\n\n")
 }
@@ -70,13 +70,18 @@ Available settings:
      registrysize:       filesize
      customregistrypath: string
 
+     scrubenabled:           boolean
+     scrubinterval:          seconds
+     scrubmarkcorrupt:       boolean
+     scrubmaxbytespersecond: filesize
+
 Currency units can be specified, e.g. 10SC; run 'siac help wallet' for details.
 
 Durations (maxduration and windowsize) must be specified in either blocks (b),
 hours (h), days (d), or weeks (w). A block is approximately 10 minutes, so one
 hour is six blocks, a day is 144 blocks, and a week is 1008 blocks.
 
-Timeouts (ephemeralaccountexpiry) must be specified in either seconds (s),
+Timeouts (ephemeralaccountexpiry and scrubinterval) must be specified in either seconds (s),
 hours (h), days (d), or weeks (w). One hour is 3600 seconds, a day is 86400
 seconds, and a week is 604800 seconds.
 
@@ -131,6 +136,33 @@ other storage folders.`,
 		Run: wrap(hostfolderresizecmd),
 	}
 
+	hostFolderMoveCmd = &cobra.Command{
+		Use:   "move [source] [destination]",
+		Short: "Move data from one storage folder to another",
+		Long: `Move data from the source storage folder to the destination storage folder,
+for example to rebalance the folders or to drain a read-only folder. All data is
+moved unless a maximum size is specified with the size flag. The move stops early
+if the destination folder is full. No data is lost if the host shuts down during
+the move, an interrupted move is resumed when the host starts again.`,
+		Run: wrap(hostfoldermovecmd),
+	}
+
+	hostFolderReadOnlyCmd = &cobra.Command{
+		Use:   "readonly [path]",
+		Short: "Stop storing new data in a storage folder",
+		Long: `Mark a storage folder as read-only. No new data will be stored in the
+folder, but the data it already stores can still be downloaded and deleted.
+This allows for retiring a disk gracefully as its data expires.`,
+		Run: wrap(hostfolderreadonlycmd),
+	}
+
+	hostFolderWritableCmd = &cobra.Command{
+		Use:   "writable [path]",
+		Short: "Store new data in a read-only storage folder again",
+		Long:  "Mark a read-only storage folder as writable again.",
+		Run:   wrap(hostfolderwritablecmd),
+	}
+
 	hostSectorCmd = &cobra.Command{
 		Use:   "sector",
 		Short: "Add or delete a sector (add not supported)",
@@ -238,6 +270,11 @@ Host Internal Settings:
 	registrysize:       %v
 	customregistrypath: %v
 
+	scrubenabled:           %v
+	scrubinterval:          %vs
+	scrubmarkcorrupt:       %v
+	scrubmaxbytespersecond: %v/s
+
 Host Financials:
 	Contract Count:               %v
 	Transaction Fee Compensation: %v
@@ -292,6 +329,11 @@ RPC Stats:
 			modules.FilesizeUnits(is.RegistrySize),
 			is.CustomRegistryPath,
 
+			yesNo(is.ScrubEnabled),
+			is.ScrubInterval.Seconds(),
+			yesNo(is.ScrubMarkCorrupt),
+			modules.FilesizeUnits(is.ScrubMaxBytesPerSecond),
+
 			fm.ContractCount, currencyUnits(fm.ContractCompensation),
 			currencyUnits(fm.PotentialContractCompensation),
 			currencyUnits(fm.TransactionFeeExpenses),
@@ -355,11 +397,15 @@ RPC Stats:
 		return
 	}
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
-	fmt.Fprintf(w, "\tUsed\tCapacity\t%% Used\tPath\n")
+	fmt.Fprintf(w, "\tUsed\tCapacity\t%% Used\tRead-Only\tPath\n")
 	for _, folder := range sg.Folders {
 		curSize := int64(folder.Capacity - folder.CapacityRemaining)
 		pctUsed := 100 * (float64(curSize) / float64(folder.Capacity))
-		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\n", modules.FilesizeUnits(uint64(curSize)), modules.FilesizeUnits(folder.Capacity), pctUsed, folder.Path)
+		readOnly := "No"
+		if folder.ReadOnly {
+			readOnly = "Yes"
+		}
+		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\t%s\n", modules.FilesizeUnits(uint64(curSize)), modules.FilesizeUnits(folder.Capacity), pctUsed, readOnly, folder.Path)
 	}
 	if err := w.Flush(); err != nil {
 		die("failed to flush writer")
@@ -399,7 +445,7 @@ func hostconfigcmd(param, value string) {
 		value = c.String()
 
 	// bool (allow "yes" and "no")
-	case "acceptingcontracts":
+	case "acceptingcontracts", "scrubenabled", "scrubmarkcorrupt":
 		switch strings.ToLower(value) {
 		case "yes":
 			value = "true"
@@ -415,14 +461,14 @@ func hostconfigcmd(param, value string) {
 		}
 
 	// filesize (convert to bytes)
-	case "registrysize":
+	case "registrysize", "scrubmaxbytespersecond":
 		value, err = parseFilesize(value)
 		if err != nil {
 			die("Could not parse "+param+":", err)
 		}
 
 	// timeout (convert to seconds)
-	case "ephemeralaccountexpiry":
+	case "ephemeralaccountexpiry", "scrubinterval":
 		value, err = parseTimeout(value)
 		if err != nil {
 			die("Could not parse "+param+":", err)
@@ -550,6 +596,42 @@ func hostfolderremovecmd(path string) {
 	fmt.Println("Removed folder", path)
 }
 
+// hostfoldermovecmd moves data from one folder of the host to another.
+func hostfoldermovecmd(source, destination string) {
+	var maxBytes uint64
+	if hostFolderMoveSize != "" {
+		size, err := parseFilesize(hostFolderMoveSize)
+		if err != nil {
+			die("Could not parse size:", err)
+		}
+		fmt.Sscan(size, &maxBytes)
+	}
+
+	mp, err := httpClient.HostStorageFoldersMovePost(abs(source), abs(destination), maxBytes)
+	if err != nil {
+		die("Could not move data:", err)
+	}
+	fmt.Printf("Moved %v sectors (%v) from folder %v to %v\n", mp.SectorsMoved, modules.FilesizeUnits(mp.SectorsMoved*modules.SectorSize), source, destination)
+}
+
+// hostfolderreadonlycmd marks a folder of the host as read-only.
+func hostfolderreadonlycmd(path string) {
+	err := httpClient.HostStorageFoldersReadOnlyPost(abs(path), true)
+	if err != nil {
+		die("Could not mark folder as read-only:", err)
+	}
+	fmt.Println("Marked folder", path, "as read-only")
+}
+
+// hostfolderwritablecmd marks a read-only folder of the host as writable.
+func hostfolderwritablecmd(path string) {
+	err := httpClient.HostStorageFoldersReadOnlyPost(abs(path), false)
+	if err != nil {
+		die("Could not mark folder as writable:", err)
+	}
+	fmt.Println("Marked folder", path, "as writable")
+}
+
 // hostfolderresizecmd resizes a folder in the host.
 func hostfolderresizecmd(path, newsize string) {
 	newsize, err := parseFilesize(newsize)
@@ -31,7 +31,7 @@ func jsoncmd() {
 	var rs modules.RenterStats
 
 	// Grab any alerts.
-	alerts, err := httpClient.DaemonAlertsGet()
+	alerts, err := httpClient.DaemonAlertsGetBySeverity(modules.SeverityCritical)
 	if err != nil {
 		die("Could not fetch alerts:", err)
 	}
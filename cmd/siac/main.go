@@ -32,6 +32,7 @@ var (
 
 	// Host Flags
 	hostContractOutputType string // output type for host contracts
+	hostFolderMoveSize     string // maximum amount of data to move between folders
 	hostFolderRemoveForce  bool   // force folder remove
 
 	// Renter Flags
@@ -261,11 +262,12 @@ func main() {
 		}
 
 		// Check for Critical Alerts
-		alerts, err := httpClient.DaemonAlertsGet()
-		if err == nil && len(alerts.CriticalAlerts) > 0 && !alertSuppress {
-			printAlerts(alerts.CriticalAlerts, modules.SeverityCritical)
+		alerts, err := httpClient.DaemonAlertsGetBySeverity(modules.SeverityCritical)
+		critAlerts := activeAlerts(alerts.CriticalAlerts)
+		if err == nil && len(critAlerts) > 0 && !alertSuppress {
+			printAlerts(critAlerts, modules.SeverityCritical)
 			fmt.Println("------------------")
-			fmt.Printf("\n  The above %v critical alerts should be resolved ASAP\n\n", len(alerts.CriticalAlerts))
+			fmt.Printf("\n  The above %v critical alerts should be resolved ASAP\n\n", len(critAlerts))
 		}
 	})
 
@@ -298,9 +300,10 @@ func initCmds() *cobra.Command {
 
 	root.AddCommand(hostCmd)
 	hostCmd.AddCommand(hostAnnounceCmd, hostConfigCmd, hostContractCmd, hostFolderCmd, hostSectorCmd)
-	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
+	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderMoveCmd, hostFolderRemoveCmd, hostFolderResizeCmd, hostFolderReadOnlyCmd, hostFolderWritableCmd)
 	hostSectorCmd.AddCommand(hostSectorDeleteCmd)
 	hostContractCmd.Flags().StringVarP(&hostContractOutputType, "type", "t", "value", "Select output type")
+	hostFolderMoveCmd.Flags().StringVarP(&hostFolderMoveSize, "size", "s", "", "Maximum amount of data to move, e.g. 1TB")
 	hostFolderRemoveCmd.Flags().BoolVarP(&hostFolderRemoveForce, "force", "f", false, "Force the removal of the folder and its data")
 
 	root.AddCommand(hostdbCmd)
@@ -315,7 +318,7 @@ func initCmds() *cobra.Command {
 		renterCleanCmd, renterContractsCmd, renterContractsRecoveryScanProgressCmd, renterDownloadCancelCmd,
 		renterDownloadsCmd, renterExportCmd, renterFilesDeleteCmd, renterFilesDownloadCmd,
 		renterFilesListCmd, renterFilesRenameCmd, renterFilesUnstuckCmd, renterFilesUploadCmd,
-		renterFuseCmd, renterLostCmd, renterPricesCmd, renterRatelimitCmd, renterSetAllowanceCmd,
+		renterFuseCmd, renterGougingCmd, renterLostCmd, renterPricesCmd, renterRatelimitCmd, renterSetAllowanceCmd,
 		renterSetLocalPathCmd, renterTriggerContractRecoveryScanCmd, renterUploadsCmd, renterWorkersCmd,
 		renterHealthSummaryCmd)
 	renterWorkersCmd.AddCommand(renterWorkersAccountsCmd, renterWorkersDownloadsCmd, renterWorkersPriceTableCmd, renterWorkersReadJobsCmd, renterWorkersHasSectorJobSCmd, renterWorkersUploadsCmd, renterWorkersReadRegistryCmd, renterWorkersUpdateRegistryCmd)
@@ -354,10 +357,12 @@ func initCmds() *cobra.Command {
 	renterSetAllowanceCmd.Flags().StringVar(&allowanceMaxUploadBandwidthPrice, "max-upload-bandwidth-price", "", "the maximum price that the renter will pay to upload data to a host")
 
 	renterFuseCmd.AddCommand(renterFuseMountCmd, renterFuseUnmountCmd)
+	renterGougingCmd.AddCommand(renterGougingExemptCmd, renterGougingUnexemptCmd)
 	renterFuseMountCmd.Flags().BoolVarP(&renterFuseMountAllowOther, "allow-other", "", false, "Allow users other than the user that mounted the fuse directory to access and use the fuse directory")
 
 	// Daemon Commands
 	root.AddCommand(alertsCmd, globalRatelimitCmd, profileCmd, stackCmd, stopCmd, updateCmd, versionCmd)
+	alertsCmd.AddCommand(alertsDismissCmd, alertsSnoozeCmd)
 	profileCmd.AddCommand(profileStartCmd, profileStopCmd)
 	profileStartCmd.Flags().BoolVarP(&daemonCPUProfile, "cpu", "c", false, "Start the CPU profile")
 	profileStartCmd.Flags().BoolVarP(&daemonMemoryProfile, "memory", "m", false, "Start the Memory profile")
@@ -194,6 +194,29 @@ local path where the Sia folder is mounted.`,
 		Run: wrap(renterfuseunmountcmd),
 	}
 
+	renterGougingCmd = &cobra.Command{
+		Use:   "gouging",
+		Short: "View the renter's gouging exemptions.",
+		Long:  "View the hosts that are exempt from the renter's price gouging checks.",
+		Run:   wrap(rentergougingcmd),
+	}
+
+	renterGougingExemptCmd = &cobra.Command{
+		Use:   "exempt [hostkey] [check] [check]...",
+		Short: "Exempt a host from price gouging checks.",
+		Long: `Exempt a host from the renter's price gouging checks. If no checks are
+specified, the host is exempt from all checks.
+        [check] can be download, fundaccount, hassector, pricetable, snapshot or upload.`,
+		Run: rentergougingexemptcmd,
+	}
+
+	renterGougingUnexemptCmd = &cobra.Command{
+		Use:   "unexempt [hostkey]",
+		Short: "Remove a host's gouging exemption.",
+		Long:  "Remove a host's gouging exemption, subjecting it to all price gouging checks again.",
+		Run:   wrap(rentergougingunexemptcmd),
+	}
+
 	renterSetLocalPathCmd = &cobra.Command{
 		Use:   "setlocalpath [siapath] [newlocalpath]",
 		Short: "Changes the local path of the file",
@@ -1930,6 +1953,76 @@ func renterfuseunmountcmd(path string) {
 	fmt.Printf("Unmounted %s successfully\n", path)
 }
 
+// rentergougingcmd is the handler for the command `siac renter gouging`. It
+// lists the renter's gouging exemptions.
+func rentergougingcmd() {
+	rgeg, err := httpClient.RenterGougingExemptionsGet()
+	if err != nil {
+		die("Unable to fetch gouging exemptions:", err)
+	}
+	if len(rgeg.Exemptions) == 0 {
+		fmt.Println("No hosts are exempt from price gouging checks.")
+		return
+	}
+	fmt.Println("Gouging exemptions:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\t%s\t%s\n", "Host", "Checks")
+	for _, ge := range rgeg.Exemptions {
+		checks := "all"
+		if len(ge.Checks) > 0 {
+			checkStrs := make([]string, 0, len(ge.Checks))
+			for _, check := range ge.Checks {
+				checkStrs = append(checkStrs, string(check))
+			}
+			checks = strings.Join(checkStrs, ", ")
+		}
+		fmt.Fprintf(w, "\t%s\t%s\n", ge.HostKey.String(), checks)
+	}
+	if err := w.Flush(); err != nil {
+		die("failed to flush writer")
+	}
+}
+
+// rentergougingexemptcmd is the handler for the command `siac renter gouging
+// exempt [hostkey] [check]...`. It exempts a host from price gouging checks.
+func rentergougingexemptcmd(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		_ = cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	var hostKey types.SiaPublicKey
+	if err := hostKey.LoadString(args[0]); err != nil {
+		die("Could not parse host key:", err)
+	}
+	var checks []modules.GougingCheck
+	for _, arg := range args[1:] {
+		check := modules.GougingCheck(arg)
+		if err := check.IsValid(); err != nil {
+			die(err)
+		}
+		checks = append(checks, check)
+	}
+	err := httpClient.RenterGougingExemptionAddPost(hostKey, checks)
+	if err != nil {
+		die("Could not add gouging exemption:", err)
+	}
+	fmt.Printf("Host %v is now exempt from price gouging checks\n", hostKey)
+}
+
+// rentergougingunexemptcmd is the handler for the command `siac renter gouging
+// unexempt [hostkey]`. It removes a host's gouging exemption.
+func rentergougingunexemptcmd(hostKeyStr string) {
+	var hostKey types.SiaPublicKey
+	if err := hostKey.LoadString(hostKeyStr); err != nil {
+		die("Could not parse host key:", err)
+	}
+	err := httpClient.RenterGougingExemptionRemovePost(hostKey)
+	if err != nil {
+		die("Could not remove gouging exemption:", err)
+	}
+	fmt.Printf("Removed the gouging exemption of host %v\n", hostKey)
+}
+
 // rentersetlocalpathcmd is the handler for the command `siac renter setlocalpath [siapath] [newlocalpath]`
 // Changes the trackingpath of the file
 // through API Endpoint
@@ -2,12 +2,72 @@ package modules
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/persist"
+)
+
+var (
+	// AlertMSGAlertsTruncated is the message of the alert that is registered
+	// if an alerter evicted alerts because it reached its limit.
+	AlertMSGAlertsTruncated = "Some alerts were dropped because the module registered too many alerts"
+
+	// DefaultAlertHysteresisWindow is the window within which an alert with
+	// hysteresis that is unregistered and registered again is considered to
+	// have been registered continuously.
+	DefaultAlertHysteresisWindow = time.Minute
+
+	// DefaultAlertLimit is the default maximum number of alerts an alerter
+	// keeps before evicting alerts.
+	DefaultAlertLimit = 1000
+
+	// ErrAlertNotFound is returned when dismissing or snoozing an alert that
+	// isn't registered.
+	ErrAlertNotFound = errors.New("alert not found")
+
+	// ErrInvalidSnoozeDuration is returned when snoozing an alert for a
+	// duration that isn't positive.
+	ErrInvalidSnoozeDuration = errors.New("snooze duration must be positive")
+
+	// ErrSubAlerterCycle is returned when adding a sub alerter would create a
+	// cycle in the hierarchy of alerters.
+	ErrSubAlerterCycle = errors.New("adding the sub alerter would create a cycle")
+
+	// ErrSubAlerterExists is returned when adding a sub alerter that was added
+	// already.
+	ErrSubAlerterExists = errors.New("sub alerter was added already")
+
+	// ErrSubAlerterNotFound is returned when removing a sub alerter that
+	// wasn't added.
+	ErrSubAlerterNotFound = errors.New("sub alerter not found")
+
+	// alertPersistInterval is the time a persisted alerter waits after an
+	// alert was registered or unregistered before saving its alerts to disk.
+	// Changes that happen within that window are saved together.
+	alertPersistInterval = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: 5 * time.Second,
+		Testnet:  5 * time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+
+	// alertFlapThreshold is the number of times an alert with hysteresis
+	// needs to flap before the number of flaps is reported in the alert.
+	alertFlapThreshold = uint64(5)
+
+	// alertPersistMetadata is the header of a persisted alerts file.
+	alertPersistMetadata = persist.Metadata{
+		Header:  "Alerts",
+		Version: "1.5.6",
+	}
 )
 
 // The following consts are the different types of severity levels available in
@@ -56,6 +116,75 @@ const (
 	// registered if the host has insufficient collateral budget left to form or
 	// renew a contract
 	AlertIDHostInsufficientCollateral = "host-insufficient-collateral"
+	// AlertIDRenterHasSectorErrors is the id of the alert that is registered
+	// if a large fraction of the workers failed their HasSector jobs during
+	// the refresh of a chunk worker set.
+	AlertIDRenterHasSectorErrors = "renter-hassector-errors"
+	// AlertIDConsensusInitialSync is the id of the informational alert that
+	// is registered while the consensus set performs its initial blockchain
+	// download.
+	AlertIDConsensusInitialSync = "consensus-initial-sync"
+	// AlertIDHostUnknownWALEntries is the id of the alert that is registered
+	// if the contract manager skipped unknown entries of its WAL during
+	// recovery, which happens after a downgrade.
+	AlertIDHostUnknownWALEntries = "host-unknown-wal-entries"
+	// AlertIDHostCorruptWALEntries is the id of the alert that is registered
+	// if the contract manager skipped corrupt entries of its WAL during
+	// recovery.
+	AlertIDHostCorruptWALEntries = "host-corrupt-wal-entries"
+	// AlertIDHostSlowWALCommits is the id of the alert that is registered if
+	// the contract manager's recent WAL commits were slow on average, which
+	// indicates a failing disk.
+	AlertIDHostSlowWALCommits = "host-slow-wal-commits"
+	// AlertIDRenterAllowanceInsufficientHosts is the id of the alert that is
+	// registered if the renter's allowance can't fund contracts with the
+	// requested number of hosts at the current median host prices.
+	AlertIDRenterAllowanceInsufficientHosts = "renter-allowance-insufficient-hosts"
+	// AlertIDRenterWalletLowBalance is the id of the alert that is registered
+	// if the renter's wallet balance is low compared to the funds needed for
+	// the remainder of the period.
+	AlertIDRenterWalletLowBalance = "renter-wallet-low-balance"
+	// AlertIDHostLowStorage is the id of the alert that is registered if the
+	// remaining storage across the host's storage folders is low.
+	AlertIDHostLowStorage = "host-low-storage"
+	// AlertIDHostCollateralBudgetLocked is the id of the alert that is
+	// registered if most of the host's collateral budget is locked in
+	// contracts.
+	AlertIDHostCollateralBudgetLocked = "host-collateral-budget-locked"
+	// AlertIDHostWalletInsufficientCollateral is the id of the alert that is
+	// registered if the host's wallet balance can't cover the collateral of a
+	// single contract with the maximum collateral.
+	AlertIDHostWalletInsufficientCollateral = "host-wallet-insufficient-collateral"
+	// AlertIDRenterUnrecoverableFiles is the id of the alert that is
+	// registered if the renter has files that can't be recovered.
+	AlertIDRenterUnrecoverableFiles = "renter-unrecoverable-files"
+	// AlertIDRenterLowRedundancyDirs is the id of the alert that is registered
+	// if the redundancy of some of the renter's directories is low.
+	AlertIDRenterLowRedundancyDirs = "renter-low-redundancy-dirs"
+	// AlertIDRenterRefCounterUnderflow is the id of the alert that is
+	// registered if a reference counter was asked to decrement the count of a
+	// sector that isn't referenced anymore.
+	AlertIDRenterRefCounterUnderflow = "renter-refcounter-underflow"
+	// AlertIDRenterRefCounterCorrupt is the id of the alert that is registered
+	// if a contract's reference counter can't be loaded because it is corrupt.
+	AlertIDRenterRefCounterCorrupt = "renter-refcounter-corrupt"
+	// AlertIDGatewayNoOutboundPeers is the id of the alert that is registered
+	// if the gateway didn't have any outbound peers for a while.
+	AlertIDGatewayNoOutboundPeers = "gateway-no-outbound-peers"
+	// AlertIDGatewaySyncStalled is the id of the alert that is registered if
+	// the consensus height didn't advance for a while even though the gateway
+	// is connected to peers.
+	AlertIDGatewaySyncStalled = "gateway-sync-stalled"
+	// AlertIDAlertsTruncated is the id of the alert that is registered if an
+	// alerter evicted alerts because it reached its limit.
+	AlertIDAlertsTruncated = "alerts-truncated"
+	// AlertIDAlertWebhookFailing is the id of the alert that is registered if
+	// alert events can't be delivered to a webhook.
+	AlertIDAlertWebhookFailing = "alert-webhook-failing"
+	// AlertIDHostNoWritableStorageFolders is the id of the alert that is
+	// registered if all of the host's storage folders are read-only, which
+	// means that the host can't store new sectors.
+	AlertIDHostNoWritableStorageFolders = "host-no-writable-storage-folders"
 )
 
 // AlertIDSiafileLowRedundancy uses a Siafile's UID to create a unique AlertID
@@ -64,6 +193,27 @@ func AlertIDSiafileLowRedundancy(uid string) AlertID {
 	return AlertID(fmt.Sprintf("low-redundancy:%v", uid))
 }
 
+// AlertIDRenterRefCounterRebuilt uses a contract's ID to create a unique
+// AlertID for the alert that is registered if the contract's reference counter
+// was rebuilt from its sector roots.
+func AlertIDRenterRefCounterRebuilt(contractID string) AlertID {
+	return AlertID(fmt.Sprintf("refcounter-rebuilt:%v", contractID))
+}
+
+// AlertIDHostCorruptSectors uses the path of a storage folder to create a
+// unique AlertID for the alert that is registered if the background scrub
+// found corrupt sectors in the storage folder.
+func AlertIDHostCorruptSectors(folderPath string) AlertID {
+	return AlertID(fmt.Sprintf("host-corrupt-sectors:%v", folderPath))
+}
+
+// AlertIDHostStorageFolderQuarantined uses the path of a storage folder to
+// create a unique AlertID for the alert that is registered if the storage
+// folder was quarantined because its disk returned too many errors.
+func AlertIDHostStorageFolderQuarantined(folderPath string) AlertID {
+	return AlertID(fmt.Sprintf("host-storage-folder-quarantined:%v", folderPath))
+}
+
 type (
 	// Alerter is the interface implemented by all top-level modules. It's an
 	// interface that allows for asking a module about potential issues.
@@ -71,11 +221,61 @@ type (
 		Alerts() (crit, err, warn, info []Alert)
 	}
 
+	// ModuleAlerter is the interface of the alerter a module registers its
+	// alerts with. It is implemented by GenericAlerter, modules accept the
+	// interface so that tests can pass an alerter that records the alerts,
+	// see the alerttest package.
+	ModuleAlerter interface {
+		Alerter
+		AlertDismisser
+		AlertSubscriber
+		AddSubAlerter(sub Alerter) error
+		Close() error
+		RegisterAlert(id AlertID, msg, cause string, severity AlertSeverity)
+		RegisterAlertWithEscalation(id AlertID, msg, cause string, severity AlertSeverity, escalateAfter time.Duration, escalatedSeverity AlertSeverity)
+		RegisterAlertWithTTL(id AlertID, msg, cause string, severity AlertSeverity, ttl time.Duration)
+		UnregisterAlert(id AlertID)
+		UpdateAlertSeverity(id AlertID, severity AlertSeverity) bool
+	}
+
+	// AlertSubscriber is the interface implemented by alerters that push
+	// events to subscribers whenever an alert is registered or unregistered.
+	// Events are delivered without blocking, a subscriber that doesn't keep up
+	// misses events.
+	AlertSubscriber interface {
+		RegisterSubscriber(ch chan<- AlertEvent)
+		UnregisterSubscriber(ch chan<- AlertEvent)
+	}
+
+	// AlertDismisser is the interface implemented by alerters that allow for
+	// dismissing and snoozing alerts. Alerts are addressed by the module they
+	// originated from and their id.
+	AlertDismisser interface {
+		DismissModuleAlert(module string, id AlertID) error
+		SnoozeModuleAlert(module string, id AlertID, d time.Duration) error
+	}
+
+	// AlertAggregator combines multiple alerters. Subscribers of the aggregator
+	// are subscribed to all of its alerters that implement AlertSubscriber.
+	AlertAggregator []Alerter
+
+	// AlertEvent is sent to the subscribers of an alerter whenever an alert is
+	// registered or unregistered.
+	AlertEvent struct {
+		Alert      Alert   `json:"alert"`
+		ID         AlertID `json:"id"`
+		Registered bool    `json:"registered"`
+	}
+
 	// Alert is a type that contains essential information about an alert.
 	Alert struct {
 		// Cause is the cause for the Alert.
 		// e.g. "Wallet is locked"
 		Cause string `json:"cause"`
+		// RootCause is an optional identifier of the underlying problem that
+		// caused the Alert. Alerts of different modules with the same root
+		// cause are grouped together.
+		RootCause string `json:"rootcause,omitempty"`
 		// Msg is the message the Alert is meant to convey to the user.
 		// e.g. "Contractor can't form new contrats"
 		Msg string `json:"msg"`
@@ -83,6 +283,48 @@ type (
 		Module string `json:"module"`
 		// Severity categorizes the Alerts to allow for an easy way to filter them.
 		Severity AlertSeverity `json:"severity"`
+
+		// Count is the number of times the alert was registered with the same
+		// cause. FirstRegistered and LastRegistered are the times of the first
+		// and the most recent of those registrations.
+		Count           uint64    `json:"count"`
+		FirstRegistered time.Time `json:"firstregistered"`
+		LastRegistered  time.Time `json:"lastregistered"`
+
+		// Restored indicates that the alert was loaded from disk on startup
+		// and hasn't been registered again since. Modules should re-validate
+		// restored alerts and unregister the ones that no longer apply.
+		Restored bool `json:"restored"`
+
+		// ID is the id of the alert within its module.
+		ID AlertID `json:"id"`
+
+		// Dismissed indicates that the user acknowledged the alert. A
+		// dismissed alert stays dismissed until it is registered with a
+		// different cause. SnoozeUntil is the time until which the alert was
+		// snoozed by the user, it is reset once that time has passed.
+		Dismissed   bool      `json:"dismissed"`
+		SnoozeUntil time.Time `json:"snoozeuntil"`
+
+		// Expires is the time at which the alert is removed automatically
+		// unless it is registered again. Zero for alerts that don't expire.
+		Expires time.Time `json:"expires"`
+
+		// EscalateAt is the time at which the alert's severity is raised to
+		// EscalatedSeverity. Zero for alerts that don't escalate.
+		EscalateAt        time.Time     `json:"escalateat"`
+		EscalatedSeverity AlertSeverity `json:"escalatedseverity,omitempty"`
+
+		// Flaps is the number of times the alert was unregistered and
+		// registered again with the same cause within its hysteresis window.
+		// It is only reported once it reaches alertFlapThreshold.
+		Flaps uint64 `json:"flaps,omitempty"`
+
+		// Details are optional structured key/value pairs that describe the
+		// alert, e.g. the contract id or host key it refers to. They allow
+		// monitoring tools to extract information without parsing Msg and
+		// Cause.
+		Details map[string]string `json:"details,omitempty"`
 	}
 
 	// AlertID is a helper type for an Alert's ID.
@@ -97,6 +339,11 @@ func (x Alert) Equals(y Alert) bool {
 	return x.Module == y.Module && x.Cause == y.Cause && x.Msg == y.Msg && x.Severity == y.Severity
 }
 
+// Snoozed returns true if the alert is currently snoozed.
+func (x Alert) Snoozed() bool {
+	return time.Now().Before(x.SnoozeUntil)
+}
+
 // EqualsWithErrorCause returns true if x and y have the same module, message,
 // and severity and if the provided error is in both of the alert's causes
 func (x Alert) EqualsWithErrorCause(y Alert, causeErr string) bool {
@@ -124,19 +371,28 @@ func (a *AlertSeverity) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &severityStr); err != nil {
 		return err
 	}
+	severity, err := ParseAlertSeverity(severityStr)
+	if err != nil {
+		return err
+	}
+	*a = severity
+	return nil
+}
+
+// ParseAlertSeverity parses the string representation of an AlertSeverity.
+func ParseAlertSeverity(severityStr string) (AlertSeverity, error) {
 	switch severityStr {
 	case "info":
-		*a = SeverityInfo
+		return SeverityInfo, nil
 	case "warning":
-		*a = SeverityWarning
+		return SeverityWarning, nil
 	case "error":
-		*a = SeverityError
+		return SeverityError, nil
 	case "critical":
-		*a = SeverityCritical
+		return SeverityCritical, nil
 	default:
-		return fmt.Errorf("unknown severity '%v'", severityStr)
+		return SeverityUnknown, fmt.Errorf("unknown severity '%v'", severityStr)
 	}
-	return nil
 }
 
 // String converts an alertSeverity to a string
@@ -156,6 +412,26 @@ func (a AlertSeverity) String() string {
 	return "unknown"
 }
 
+// AlertsBySeverity aggregates the alerts of the given alerters, only returning
+// the alerts with a severity of at least 'min'. The slices of the severities
+// below 'min' are always empty.
+func AlertsBySeverity(min AlertSeverity, alerters ...Alerter) (crit, err, warn, info []Alert) {
+	for _, a := range alerters {
+		c, e, w, i := a.Alerts()
+		crit = append(crit, c...)
+		if min <= SeverityError {
+			err = append(err, e...)
+		}
+		if min <= SeverityWarning {
+			warn = append(warn, w...)
+		}
+		if min <= SeverityInfo {
+			info = append(info, i...)
+		}
+	}
+	return
+}
+
 // GenericAlerter implements the Alerter interface. It can be used as a helper
 // type to implement the Alerter interface for modules and submodules.
 type (
@@ -163,23 +439,146 @@ type (
 		alerts map[AlertID]Alert
 		module string
 		mu     sync.Mutex
+
+		// escalationClocks tracks since when the alerts that were registered
+		// with an escalation policy have been registered with their current
+		// cause. A clock outlives the alert so that unregistering and
+		// registering the alert again doesn't reset it.
+		escalationClocks map[AlertID]escalationClock
+
+		// hysteresisWindows are the windows of the alerts that opted into
+		// hysteresis. Unregistering such an alert only marks it for removal
+		// in pendingRemovals and it is removed once the window passes without
+		// the alert being registered again. flaps counts how often that
+		// happened with the same cause.
+		flaps             map[AlertID]uint64
+		hysteresisWindows map[AlertID]time.Duration
+		pendingRemovals   map[AlertID]time.Time
+
+		// limit is the maximum number of alerts of the alerter. Once it is
+		// exceeded, the alerts with the lowest severity that were registered
+		// least recently are evicted.
+		limit int
+
+		// subscribers maps the channels of the alerter's subscribers to the
+		// number of events that were dropped because the subscriber's
+		// channel was full.
+		subscribers map[chan<- AlertEvent]uint64
+
+		// subAlerters are the alerters of submodules. Their alerts are
+		// included in the alerter's alerts and the alerter's subscribers are
+		// subscribed to them.
+		subAlerters []Alerter
+
+		// Persistence related fields. staticPersistPath is empty for alerters
+		// that don't persist their alerts.
+		closed            bool
+		persistErr        error
+		saveTimer         *time.Timer
+		staticPersistPath string
+		persistMu         sync.Mutex
+	}
+
+	// persistedAlerts is the on-disk representation of a GenericAlerter's
+	// alerts.
+	persistedAlerts struct {
+		Alerts           map[AlertID]Alert           `json:"alerts"`
+		EscalationClocks map[AlertID]escalationClock `json:"escalationclocks,omitempty"`
+	}
+
+	// alertOptions are the optional properties of an alert that is
+	// registered. The alert expires at 'expires' and its severity is raised to
+	// 'escalatedSeverity' at 'escalateAt'. A zero time means that the alert
+	// doesn't expire or escalate respectively.
+	alertOptions struct {
+		expires           time.Time
+		escalateAt        time.Time
+		escalatedSeverity AlertSeverity
+		rootCause         string
+		details           map[string]string
+	}
+
+	// escalationClock is the time since which an alert has been registered
+	// with the given cause.
+	escalationClock struct {
+		Cause string    `json:"cause"`
+		Since time.Time `json:"since"`
 	}
 )
 
 // NewAlerter creates a new alerter for the renter.
 func NewAlerter(module string) *GenericAlerter {
 	a := &GenericAlerter{
-		alerts: make(map[AlertID]Alert),
-		module: module,
+		alerts:            make(map[AlertID]Alert),
+		escalationClocks:  make(map[AlertID]escalationClock),
+		flaps:             make(map[AlertID]uint64),
+		hysteresisWindows: make(map[AlertID]time.Duration),
+		limit:             DefaultAlertLimit,
+		module:            module,
+		pendingRemovals:   make(map[AlertID]time.Time),
+		subscribers:       make(map[chan<- AlertEvent]uint64),
 	}
 	return a
 }
 
-// Alerts returns the current alerts tracked by the alerter.
+// NewPersistedAlerter creates a new alerter which persists its alerts to a
+// file named after the module within persistDir. See NewAlerterWithPersist.
+func NewPersistedAlerter(module, persistDir string) (*GenericAlerter, error) {
+	return NewAlerterWithPersist(module, filepath.Join(persistDir, module+"_alerts.json"))
+}
+
+// NewAlerterWithPersist creates a new alerter which persists its alerts to the
+// JSON file at path. Alerts that were persisted before are loaded and marked
+// as restored until they are registered again. The returned alerter is always
+// usable, a non-nil error indicates that the persisted alerts couldn't be
+// loaded and were discarded. The alerter needs to be closed to save its final
+// state.
+func NewAlerterWithPersist(module, path string) (*GenericAlerter, error) {
+	a := NewAlerter(module)
+	a.staticPersistPath = path
+
+	var pa persistedAlerts
+	err := persist.LoadJSON(alertPersistMetadata, &pa, a.staticPersistPath)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return a, errors.AddContext(err, fmt.Sprintf("unable to load persisted alerts of module '%v'", module))
+	}
+	for id, alert := range pa.Alerts {
+		alert.ID = id
+		alert.Module = module
+		alert.Restored = true
+		a.alerts[id] = alert
+		if alert.Flaps > 0 {
+			a.flaps[id] = alert.Flaps
+		}
+	}
+	for id, clock := range pa.EscalationClocks {
+		a.escalationClocks[id] = clock
+	}
+	return a, nil
+}
+
+// Alerts returns the current alerts tracked by the alerter, followed by the
+// alerts of its sub alerters in the order they were added. The alerter's own
+// alerts are sorted by id.
 func (a *GenericAlerter) Alerts() (crit, err, warn, info []Alert) {
 	a.mu.Lock()
-	defer a.mu.Unlock()
-	for _, alert := range a.alerts {
+	a.updateAlerts()
+	ids := make([]string, 0, len(a.alerts))
+	for id := range a.alerts {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+	now := time.Now()
+	for _, id := range ids {
+		alert := a.alerts[AlertID(id)]
+		if !alert.SnoozeUntil.IsZero() && !now.Before(alert.SnoozeUntil) {
+			alert.SnoozeUntil = time.Time{}
+			a.alerts[AlertID(id)] = alert
+			a.scheduleSave()
+		}
 		switch alert.Severity {
 		case SeverityInfo:
 			info = append(info, alert)
@@ -193,26 +592,736 @@ func (a *GenericAlerter) Alerts() (crit, err, warn, info []Alert) {
 			build.Critical("Alerts: invalid severity", alert.Severity)
 		}
 	}
+	subAlerters := append([]Alerter{}, a.subAlerters...)
+	a.mu.Unlock()
+
+	// Add the alerts of the sub alerters without holding the lock.
+	for _, sub := range subAlerters {
+		c, e, w, i := sub.Alerts()
+		crit = append(crit, c...)
+		err = append(err, e...)
+		warn = append(warn, w...)
+		info = append(info, i...)
+	}
 	return
 }
 
-// RegisterAlert adds an alert to the alerter.
+// AddSubAlerter adds the alerter of a submodule to the alerter. The alerts of
+// the sub alerter are returned alongside the alerter's own alerts. Cycles can
+// only be detected if the sub alerter is a GenericAlerter.
+func (a *GenericAlerter) AddSubAlerter(sub Alerter) error {
+	if sub == Alerter(a) || isAncestorAlerter(sub, a) {
+		return ErrSubAlerterCycle
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, s := range a.subAlerters {
+		if s == sub {
+			return ErrSubAlerterExists
+		}
+	}
+	a.subAlerters = append(a.subAlerters, sub)
+
+	// Subscribe the existing subscribers to the new sub alerter.
+	if s, ok := sub.(AlertSubscriber); ok {
+		for ch := range a.subscribers {
+			s.RegisterSubscriber(ch)
+		}
+	}
+	return nil
+}
+
+// RemoveSubAlerter removes the alerter of a submodule from the alerter.
+func (a *GenericAlerter) RemoveSubAlerter(sub Alerter) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, s := range a.subAlerters {
+		if s != sub {
+			continue
+		}
+		a.subAlerters = append(a.subAlerters[:i], a.subAlerters[i+1:]...)
+		if s, ok := sub.(AlertSubscriber); ok {
+			for ch := range a.subscribers {
+				s.UnregisterSubscriber(ch)
+			}
+		}
+		return nil
+	}
+	return ErrSubAlerterNotFound
+}
+
+// isAncestorAlerter returns true if 'target' is 'ancestor' or one of its
+// transitive sub alerters.
+func isAncestorAlerter(ancestor Alerter, target *GenericAlerter) bool {
+	ga, ok := ancestor.(*GenericAlerter)
+	if !ok {
+		return false
+	}
+	if ga == target {
+		return true
+	}
+	ga.mu.Lock()
+	subAlerters := append([]Alerter{}, ga.subAlerters...)
+	ga.mu.Unlock()
+	for _, sub := range subAlerters {
+		if isAncestorAlerter(sub, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterAlert adds an alert to the alerter. If an alert with the same id and
+// cause is registered already, its count is incremented instead. An alert with
+// the same id but a different cause replaces the existing alert.
 func (a *GenericAlerter) RegisterAlert(id AlertID, msg, cause string, severity AlertSeverity) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	a.alerts[id] = Alert{
-		Cause:    cause,
-		Module:   a.module,
-		Msg:      msg,
-		Severity: severity,
+	a.registerAlert(id, msg, cause, severity, alertOptions{})
+}
+
+// RegisterAlertWithRootCause registers an alert like RegisterAlert, but also
+// sets the alert's root cause. Alerts of different modules that share a root
+// cause are grouped together by the API even if their causes differ. It should
+// be used for alerts that are the consequence of a problem that other modules
+// might report as well, e.g. a locked wallet.
+func (a *GenericAlerter) RegisterAlertWithRootCause(id AlertID, msg, cause, rootCause string, severity AlertSeverity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.registerAlert(id, msg, cause, severity, alertOptions{rootCause: rootCause})
+}
+
+// RegisterAlertWithDetails registers an alert like RegisterAlert, but also
+// attaches structured details to the alert. The details replace the details of
+// a previous registration of the alert.
+func (a *GenericAlerter) RegisterAlertWithDetails(id AlertID, msg, cause string, severity AlertSeverity, details map[string]string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.registerAlert(id, msg, cause, severity, alertOptions{details: details})
+}
+
+// RegisterAlertWithTTL registers an alert like RegisterAlert, but the alert is
+// removed automatically once the ttl has passed. Registering the alert again
+// refreshes the ttl. It should be used for conditions that may clear without
+// the module noticing.
+func (a *GenericAlerter) RegisterAlertWithTTL(id AlertID, msg, cause string, severity AlertSeverity, ttl time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.registerAlert(id, msg, cause, severity, alertOptions{expires: time.Now().Add(ttl)})
+}
+
+// RegisterAlertWithEscalation registers an alert like RegisterAlert, but the
+// alert's severity is raised to escalatedSeverity once it has been registered
+// with the same cause for longer than escalateAfter. Unregistering the alert
+// doesn't reset that time, only registering it with a different cause does. It
+// should be used for conditions that become more serious the longer they
+// persist.
+func (a *GenericAlerter) RegisterAlertWithEscalation(id AlertID, msg, cause string, severity AlertSeverity, escalateAfter time.Duration, escalatedSeverity AlertSeverity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	clock, exists := a.escalationClocks[id]
+	if !exists || clock.Cause != cause {
+		clock = escalationClock{
+			Cause: cause,
+			Since: time.Now(),
+		}
+		a.escalationClocks[id] = clock
+	}
+	a.registerAlert(id, msg, cause, severity, alertOptions{
+		escalateAt:        clock.Since.Add(escalateAfter),
+		escalatedSeverity: escalatedSeverity,
+	})
+}
+
+// SetAlertHysteresis enables hysteresis for the alert with the given id. Once
+// enabled, unregistering the alert only removes it if it isn't registered
+// again with the same cause within the window. Until then the alert is
+// considered to be registered continuously and no events are sent for the
+// flap. A window that isn't positive disables hysteresis for the alert.
+func (a *GenericAlerter) SetAlertHysteresis(id AlertID, window time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if window <= 0 {
+		delete(a.hysteresisWindows, id)
+		return
+	}
+	a.hysteresisWindows[id] = window
+}
+
+// registerAlert registers an alert with the given options.
+func (a *GenericAlerter) registerAlert(id AlertID, msg, cause string, severity AlertSeverity, opts alertOptions) {
+	now := time.Now()
+	alert, exists := a.alerts[id]
+	_, pendingRemoval := a.pendingRemovals[id]
+	delete(a.pendingRemovals, id)
+	flapped := pendingRemoval && exists && alert.Cause == cause
+	if flapped {
+		a.flaps[id]++
+	}
+	if !exists || alert.Cause != cause {
+		alert = Alert{
+			ID:              id,
+			Cause:           cause,
+			Module:          a.module,
+			FirstRegistered: now,
+		}
+		delete(a.flaps, id)
+	}
+	alert.Flaps = 0
+	if flaps := a.flaps[id]; flaps >= alertFlapThreshold {
+		alert.Flaps = flaps
+	}
+	alert.Msg = msg
+	alert.Severity = severity
+	alert.Count++
+	alert.LastRegistered = now
+	alert.Restored = false
+	alert.RootCause = opts.rootCause
+	alert.Details = nil
+	if len(opts.details) > 0 {
+		// Copy the details to prevent the caller from modifying the alert.
+		alert.Details = make(map[string]string, len(opts.details))
+		for k, v := range opts.details {
+			alert.Details[k] = v
+		}
+	}
+	alert.Expires = opts.expires
+	alert.EscalateAt = opts.escalateAt
+	alert.EscalatedSeverity = opts.escalatedSeverity
+	if opts.escalateAt.IsZero() {
+		// Only alerts that are registered with an escalation policy keep
+		// their clock.
+		delete(a.escalationClocks, id)
+	}
+	alert, escalated := escalateAlert(alert, now)
+	a.alerts[id] = alert
+	a.scheduleSave()
+	// An alert that flapped was registered continuously from the point of
+	// view of the subscribers.
+	if !flapped || escalated {
+		a.notifySubscribers(id, alert, true)
+	}
+	if !exists && id != AlertIDAlertsTruncated {
+		a.enforceAlertLimit()
+	}
+}
+
+// AlertUsage returns the number of alerts of the alerter and the maximum
+// number of alerts it keeps before evicting alerts. The alerts of
+// sub-alerters are not included.
+func (a *GenericAlerter) AlertUsage() (count, limit int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.alerts), a.limit
+}
+
+// SetAlertLimit sets the maximum number of alerts the alerter keeps. If the
+// alerter has more alerts than that, alerts are evicted right away. A limit
+// that isn't positive disables the limit.
+func (a *GenericAlerter) SetAlertLimit(limit int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limit = limit
+	a.enforceAlertLimit()
+}
+
+// enforceAlertLimit evicts alerts until the alerter doesn't exceed its limit
+// anymore and registers an alert about the eviction. The alerts with the
+// lowest severity are evicted first, which means that critical alerts are only
+// evicted once all remaining alerts are critical. Among alerts with the same
+// severity, the ones that were registered least recently are evicted first.
+func (a *GenericAlerter) enforceAlertLimit() {
+	if a.limit <= 0 || len(a.alerts) <= a.limit {
+		return
+	}
+	// Leave room for the alert about the eviction.
+	limit := a.limit
+	if _, exists := a.alerts[AlertIDAlertsTruncated]; !exists {
+		limit--
+	}
+	for len(a.alerts) > limit {
+		var evictID AlertID
+		var evict Alert
+		found := false
+		for id, alert := range a.alerts {
+			if id == AlertIDAlertsTruncated {
+				continue
+			}
+			if !found || alert.Severity < evict.Severity ||
+				(alert.Severity == evict.Severity && alert.LastRegistered.Before(evict.LastRegistered)) ||
+				(alert.Severity == evict.Severity && alert.LastRegistered.Equal(evict.LastRegistered) && id < evictID) {
+				evictID, evict, found = id, alert, true
+			}
+		}
+		if !found {
+			break
+		}
+		delete(a.alerts, evictID)
+		delete(a.flaps, evictID)
+		delete(a.pendingRemovals, evictID)
+		a.scheduleSave()
+		a.notifySubscribers(evictID, evict, false)
 	}
+	cause := fmt.Sprintf("alerts truncated for module %v", a.module)
+	a.registerAlert(AlertIDAlertsTruncated, AlertMSGAlertsTruncated, cause, SeverityWarning, alertOptions{})
 }
 
-// UnregisterAlert removes an alert from the alerter by id.
+// escalateAlert raises the severity of the alert if its escalation time has
+// passed. The returned bool indicates whether the severity was raised.
+func escalateAlert(alert Alert, now time.Time) (Alert, bool) {
+	if alert.EscalateAt.IsZero() || now.Before(alert.EscalateAt) || alert.Severity >= alert.EscalatedSeverity {
+		return alert, false
+	}
+	alert.Severity = alert.EscalatedSeverity
+	alert.LastRegistered = now
+	return alert, true
+}
+
+// updateAlerts removes the expired alerts and the alerts whose hysteresis
+// window passed after they were unregistered, and escalates the alerts whose
+// escalation time has passed.
+func (a *GenericAlerter) updateAlerts() {
+	a.removeExpiredAlerts()
+	a.removePendingAlerts()
+	now := time.Now()
+	for id, alert := range a.alerts {
+		alert, escalated := escalateAlert(alert, now)
+		if !escalated {
+			continue
+		}
+		a.alerts[id] = alert
+		a.scheduleSave()
+		a.notifySubscribers(id, alert, true)
+	}
+}
+
+// removeExpiredAlerts removes the alerts whose ttl has passed.
+func (a *GenericAlerter) removeExpiredAlerts() {
+	now := time.Now()
+	for id, alert := range a.alerts {
+		if alert.Expires.IsZero() || now.Before(alert.Expires) {
+			continue
+		}
+		delete(a.alerts, id)
+		a.scheduleSave()
+		a.notifySubscribers(id, alert, false)
+	}
+}
+
+// removePendingAlerts removes the alerts that were unregistered and not
+// registered again within their hysteresis window.
+func (a *GenericAlerter) removePendingAlerts() {
+	now := time.Now()
+	for id, removeAt := range a.pendingRemovals {
+		if now.Before(removeAt) {
+			continue
+		}
+		delete(a.pendingRemovals, id)
+		delete(a.flaps, id)
+		alert, exists := a.alerts[id]
+		if !exists {
+			continue
+		}
+		delete(a.alerts, id)
+		a.scheduleSave()
+		a.notifySubscribers(id, alert, false)
+	}
+}
+
+// UnregisterAlert removes an alert from the alerter by id. Alerts with
+// hysteresis are only removed once their window passes without them being
+// registered again.
 func (a *GenericAlerter) UnregisterAlert(id AlertID) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	alert, exists := a.alerts[id]
+	if !exists {
+		return
+	}
+	if window, hysteresis := a.hysteresisWindows[id]; hysteresis {
+		if _, pending := a.pendingRemovals[id]; !pending {
+			a.pendingRemovals[id] = time.Now().Add(window)
+			time.AfterFunc(window, a.threadedRemovePendingAlerts)
+		}
+		return
+	}
 	delete(a.alerts, id)
+	a.scheduleSave()
+	a.notifySubscribers(id, alert, false)
+}
+
+// threadedRemovePendingAlerts removes the alerts whose hysteresis window
+// passed. It is called once the window of an unregistered alert passes so
+// that subscribers are notified without waiting for the alerts to be queried.
+func (a *GenericAlerter) threadedRemovePendingAlerts() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.removePendingAlerts()
+}
+
+// UnregisterAll removes all alerts from the alerter, including the ones
+// waiting for their hysteresis window to pass, and resets their escalation
+// clocks. It should be used when a module restarts and its previous
+// alerts are known to be stale. The alerts of sub-alerters are not affected.
+func (a *GenericAlerter) UnregisterAll() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.alerts) == 0 && len(a.escalationClocks) == 0 {
+		return
+	}
+	a.escalationClocks = make(map[AlertID]escalationClock)
+	a.flaps = make(map[AlertID]uint64)
+	a.pendingRemovals = make(map[AlertID]time.Time)
+	for id, alert := range a.alerts {
+		delete(a.alerts, id)
+		a.notifySubscribers(id, alert, false)
+	}
+	a.scheduleSave()
+}
+
+// DismissAlert marks the alert with the given id as dismissed. The alert stays
+// dismissed until it is registered again with a different cause.
+func (a *GenericAlerter) DismissAlert(id AlertID) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.updateAlerts()
+	alert, exists := a.alerts[id]
+	if !exists {
+		return ErrAlertNotFound
+	}
+	alert.Dismissed = true
+	a.alerts[id] = alert
+	a.scheduleSave()
+	a.notifySubscribers(id, alert, true)
+	return nil
+}
+
+// SnoozeAlert snoozes the alert with the given id for the given duration.
+func (a *GenericAlerter) SnoozeAlert(id AlertID, d time.Duration) error {
+	if d <= 0 {
+		return ErrInvalidSnoozeDuration
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.updateAlerts()
+	alert, exists := a.alerts[id]
+	if !exists {
+		return ErrAlertNotFound
+	}
+	alert.SnoozeUntil = time.Now().Add(d)
+	a.alerts[id] = alert
+	a.scheduleSave()
+	a.notifySubscribers(id, alert, true)
+	return nil
+}
+
+// UpdateAlertSeverity changes the severity of the alert with the given id in
+// place, leaving its message and cause intact. Unlike unregistering the alert
+// and registering it again, the alert doesn't disappear in between. The
+// alert's escalation policy is dropped so that the new severity sticks until
+// the alert is registered again. The returned bool indicates whether the alert
+// exists.
+func (a *GenericAlerter) UpdateAlertSeverity(id AlertID, severity AlertSeverity) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.updateAlerts()
+	alert, exists := a.alerts[id]
+	if !exists {
+		return false
+	}
+	if alert.Severity == severity && alert.EscalateAt.IsZero() {
+		return true
+	}
+	alert.Severity = severity
+	alert.EscalateAt = time.Time{}
+	alert.EscalatedSeverity = 0
+	a.alerts[id] = alert
+	a.scheduleSave()
+	a.notifySubscribers(id, alert, true)
+	return true
+}
+
+// HighestSeverity returns the highest severity among the alerts that Alerts
+// would return, or SeverityUnknown if there are none. It is a cheap summary
+// for callers that only need to know how bad things are, e.g. to color a
+// status indicator, without copying all the alerts.
+func (a *GenericAlerter) HighestSeverity() AlertSeverity {
+	a.mu.Lock()
+	a.updateAlerts()
+	highest := AlertSeverity(SeverityUnknown)
+	for _, alert := range a.alerts {
+		if alert.Severity > highest {
+			highest = alert.Severity
+		}
+	}
+	subAlerters := append([]Alerter{}, a.subAlerters...)
+	a.mu.Unlock()
+
+	// Check the sub alerters without holding the lock.
+	for _, sub := range subAlerters {
+		if severity := highestSeverity(sub); severity > highest {
+			highest = severity
+		}
+	}
+	return highest
+}
+
+// highestSeverity returns the highest severity of the alerter's alerts. Only
+// alerters that can't compute it themselves have their alerts fetched.
+func highestSeverity(alerter Alerter) AlertSeverity {
+	if hs, ok := alerter.(interface{ HighestSeverity() AlertSeverity }); ok {
+		return hs.HighestSeverity()
+	}
+	crit, err, warn, info := alerter.Alerts()
+	switch {
+	case len(crit) > 0:
+		return SeverityCritical
+	case len(err) > 0:
+		return SeverityError
+	case len(warn) > 0:
+		return SeverityWarning
+	case len(info) > 0:
+		return SeverityInfo
+	}
+	return SeverityUnknown
+}
+
+// DismissModuleAlert implements the AlertDismisser interface. It dismisses the
+// alert of either the alerter itself or one of its sub alerters.
+func (a *GenericAlerter) DismissModuleAlert(module string, id AlertID) error {
+	if module == a.module {
+		return a.DismissAlert(id)
+	}
+	return a.managedSubAlerters().DismissModuleAlert(module, id)
+}
+
+// SnoozeModuleAlert implements the AlertDismisser interface. It snoozes the
+// alert of either the alerter itself or one of its sub alerters.
+func (a *GenericAlerter) SnoozeModuleAlert(module string, id AlertID, d time.Duration) error {
+	if module == a.module {
+		return a.SnoozeAlert(id, d)
+	}
+	return a.managedSubAlerters().SnoozeModuleAlert(module, id, d)
+}
+
+// managedSubAlerters returns a copy of the alerter's sub alerters.
+func (a *GenericAlerter) managedSubAlerters() AlertAggregator {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append(AlertAggregator{}, a.subAlerters...)
+}
+
+// RestoredAlerts returns the ids of the alerts that were loaded from disk and
+// haven't been registered again since.
+func (a *GenericAlerter) RestoredAlerts() []AlertID {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.updateAlerts()
+	var ids []AlertID
+	for id, alert := range a.alerts {
+		if alert.Restored {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// UnregisterRestoredAlerts removes all the alerts that were loaded from disk
+// and haven't been registered again since.
+func (a *GenericAlerter) UnregisterRestoredAlerts() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	removed := false
+	for id, alert := range a.alerts {
+		if alert.Restored {
+			delete(a.alerts, id)
+			a.notifySubscribers(id, alert, false)
+			removed = true
+		}
+	}
+	if removed {
+		a.scheduleSave()
+	}
+}
+
+// RegisterSubscriber subscribes the given channel to the alert events of the
+// alerter. Events are sent without blocking, if the channel is full the event
+// is dropped. The channel is never closed by the alerter.
+func (a *GenericAlerter) RegisterSubscriber(ch chan<- AlertEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, exists := a.subscribers[ch]; exists {
+		return
+	}
+	a.subscribers[ch] = 0
+	for _, sub := range a.subAlerters {
+		if s, ok := sub.(AlertSubscriber); ok {
+			s.RegisterSubscriber(ch)
+		}
+	}
+}
+
+// UnregisterSubscriber unsubscribes the given channel from the alert events of
+// the alerter. No events are sent to the channel after this method returns.
+func (a *GenericAlerter) UnregisterSubscriber(ch chan<- AlertEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.subscribers, ch)
+	for _, sub := range a.subAlerters {
+		if s, ok := sub.(AlertSubscriber); ok {
+			s.UnregisterSubscriber(ch)
+		}
+	}
+}
+
+// DroppedAlertEvents returns the number of events of the alerter itself that
+// were dropped for the given subscriber because its channel was full.
+func (a *GenericAlerter) DroppedAlertEvents(ch chan<- AlertEvent) uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.subscribers[ch]
+}
+
+// notifySubscribers sends an event for the alert with the given id to all
+// subscribers without blocking.
+func (a *GenericAlerter) notifySubscribers(id AlertID, alert Alert, registered bool) {
+	event := AlertEvent{
+		Alert:      alert,
+		ID:         id,
+		Registered: registered,
+	}
+	for ch := range a.subscribers {
+		select {
+		case ch <- event:
+		default:
+			a.subscribers[ch]++
+		}
+	}
+}
+
+// RegisterSubscriber subscribes the given channel to the alert events of all
+// the aggregated alerters that support subscriptions.
+func (aa AlertAggregator) RegisterSubscriber(ch chan<- AlertEvent) {
+	for _, a := range aa {
+		if s, ok := a.(AlertSubscriber); ok {
+			s.RegisterSubscriber(ch)
+		}
+	}
+}
+
+// UnregisterSubscriber unsubscribes the given channel from the alert events of
+// all the aggregated alerters.
+func (aa AlertAggregator) UnregisterSubscriber(ch chan<- AlertEvent) {
+	for _, a := range aa {
+		if s, ok := a.(AlertSubscriber); ok {
+			s.UnregisterSubscriber(ch)
+		}
+	}
+}
+
+// DismissModuleAlert implements the AlertDismisser interface by dismissing the
+// alert in the first aggregated alerter that knows about it.
+func (aa AlertAggregator) DismissModuleAlert(module string, id AlertID) error {
+	return aa.forEachDismisser(func(d AlertDismisser) error {
+		return d.DismissModuleAlert(module, id)
+	})
+}
+
+// SnoozeModuleAlert implements the AlertDismisser interface by snoozing the
+// alert in the first aggregated alerter that knows about it.
+func (aa AlertAggregator) SnoozeModuleAlert(module string, id AlertID, d time.Duration) error {
+	return aa.forEachDismisser(func(ad AlertDismisser) error {
+		return ad.SnoozeModuleAlert(module, id, d)
+	})
+}
+
+// forEachDismisser calls fn for the aggregated alerters that implement
+// AlertDismisser until a call returns an error other than ErrAlertNotFound.
+func (aa AlertAggregator) forEachDismisser(fn func(AlertDismisser) error) error {
+	for _, a := range aa {
+		d, ok := a.(AlertDismisser)
+		if !ok {
+			continue
+		}
+		err := fn(d)
+		if !errors.Contains(err, ErrAlertNotFound) {
+			return err
+		}
+	}
+	return ErrAlertNotFound
+}
+
+// Alerts returns the alerts of all the aggregated alerters.
+func (aa AlertAggregator) Alerts() (crit, err, warn, info []Alert) {
+	return AlertsBySeverity(SeverityInfo, aa...)
+}
+
+// Close saves the alerts of a persisted alerter to disk. Afterwards, changes
+// to the alerts are no longer persisted.
+func (a *GenericAlerter) Close() error {
+	if a.staticPersistPath == "" {
+		return nil
+	}
+	a.mu.Lock()
+	a.closed = true
+	if a.saveTimer != nil {
+		a.saveTimer.Stop()
+	}
+	a.mu.Unlock()
+	return a.managedSave()
+}
+
+// scheduleSave schedules the alerts to be saved to disk, unless a save is
+// scheduled already.
+func (a *GenericAlerter) scheduleSave() {
+	if a.staticPersistPath == "" || a.closed || a.saveTimer != nil {
+		return
+	}
+	a.saveTimer = time.AfterFunc(alertPersistInterval, a.threadedSave)
+}
+
+// threadedSave saves the alerts to disk after a scheduled save fired.
+func (a *GenericAlerter) threadedSave() {
+	a.mu.Lock()
+	a.saveTimer = nil
+	closed := a.closed
+	a.mu.Unlock()
+	if closed {
+		return
+	}
+	err := a.managedSave()
+	a.mu.Lock()
+	a.persistErr = err
+	a.mu.Unlock()
+}
+
+// PersistErr returns the error of the most recent background save of the
+// alerts, if any.
+func (a *GenericAlerter) PersistErr() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.persistErr
+}
+
+// managedSave saves the current alerts to disk.
+func (a *GenericAlerter) managedSave() error {
+	a.persistMu.Lock()
+	defer a.persistMu.Unlock()
+
+	a.mu.Lock()
+	a.updateAlerts()
+	pa := persistedAlerts{
+		Alerts:           make(map[AlertID]Alert, len(a.alerts)),
+		EscalationClocks: make(map[AlertID]escalationClock, len(a.escalationClocks)),
+	}
+	for id, alert := range a.alerts {
+		pa.Alerts[id] = alert
+	}
+	for id, clock := range a.escalationClocks {
+		pa.EscalationClocks[id] = clock
+	}
+	a.mu.Unlock()
+	return persist.SaveJSON(alertPersistMetadata, pa, a.staticPersistPath)
 }
 
 // PrintAlerts is a helper function to print details of a slice of alerts
@@ -226,6 +1335,11 @@ func PrintAlerts(alerts []Alert, as AlertSeverity) {
   Severity: %s
   Message:  %s
   Cause:    %s`, a.Module, a.Severity.String(), a.Msg, a.Cause)
+		if !a.FirstRegistered.IsZero() {
+			fmt.Printf(`
+  Age:      %v
+  Count:    %v`, time.Since(a.FirstRegistered).Round(time.Second), a.Count)
+		}
 	}
 	fmt.Printf("\n------------------\n\n")
 }
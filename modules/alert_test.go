@@ -2,14 +2,23 @@ package modules
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
 )
 
 // TestMarshalUnmarshalAlertSeverity tests the custom marshaling/unmarshaling
 // code for AlertSeverity.
 func TestMarshalUnmarshalAlertSeverity(t *testing.T) {
 	severityUnknown := AlertSeverity(SeverityUnknown)
+	severityInfo := AlertSeverity(SeverityInfo)
 	severityWarning := AlertSeverity(SeverityWarning)
 	severityError := AlertSeverity(SeverityError)
 	severityCritical := AlertSeverity(SeverityCritical)
@@ -54,6 +63,20 @@ func TestMarshalUnmarshalAlertSeverity(t *testing.T) {
 	if s != SeverityError {
 		t.Fatal("result not the same severity as input")
 	}
+	// Marshal/Unmarshal info.
+	b, err = json.Marshal(severityInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"info"` {
+		t.Fatal("unexpected encoding", string(b))
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s != SeverityInfo {
+		t.Fatal("result not the same severity as input")
+	}
 	// Marshal/Unmarshal invalid.
 	_, err = json.Marshal(severityInvalid)
 	if err == nil {
@@ -97,3 +120,1219 @@ func TestAlertsSorted(t *testing.T) {
 		}
 	}
 }
+
+// TestAlertsBySeverity tests filtering and aggregating the alerts of multiple
+// alerters.
+func TestAlertsBySeverity(t *testing.T) {
+	alerter1 := NewAlerter(t.Name() + "1")
+	alerter2 := NewAlerter(t.Name() + "2")
+
+	// Register some alerts, alerter1 has 2 alerts of every severity and
+	// alerter2 has 1 alert of every severity.
+	for i := 0; i < 8; i++ {
+		id := strconv.Itoa(i)
+		alerter1.RegisterAlert(AlertID(id), "msg"+id, "cause"+id, AlertSeverity(i%4+1))
+	}
+	for i := 0; i < 4; i++ {
+		id := strconv.Itoa(i)
+		alerter2.RegisterAlert(AlertID(id), "msg"+id, "cause"+id, AlertSeverity(i%4+1))
+	}
+
+	tests := []struct {
+		min                               AlertSeverity
+		numCrit, numErr, numWarn, numInfo int
+	}{
+		{SeverityInfo, 3, 3, 3, 3},
+		{SeverityWarning, 3, 3, 3, 0},
+		{SeverityError, 3, 3, 0, 0},
+		{SeverityCritical, 3, 0, 0, 0},
+	}
+	for _, test := range tests {
+		crit, err, warn, info := AlertsBySeverity(test.min, alerter1, alerter2)
+		if len(crit) != test.numCrit || len(err) != test.numErr || len(warn) != test.numWarn || len(info) != test.numInfo {
+			t.Fatalf("%v: returned slices have wrong lengths %v %v %v %v", test.min, len(crit), len(err), len(warn), len(info))
+		}
+		for _, alert := range append(append(append(crit, err...), warn...), info...) {
+			if alert.Severity < test.min {
+				t.Fatal("alert has wrong severity", alert.Severity)
+			}
+		}
+	}
+
+	// Without alerters there are no alerts.
+	crit, err, warn, info := AlertsBySeverity(SeverityInfo)
+	if len(crit)+len(err)+len(warn)+len(info) != 0 {
+		t.Fatal("expected no alerts")
+	}
+}
+
+// TestParseAlertSeverity tests parsing the string representation of an
+// AlertSeverity.
+func TestParseAlertSeverity(t *testing.T) {
+	for _, severity := range []AlertSeverity{SeverityInfo, SeverityWarning, SeverityError, SeverityCritical} {
+		parsed, err := ParseAlertSeverity(severity.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != severity {
+			t.Fatal("unexpected severity", parsed, severity)
+		}
+	}
+	if _, err := ParseAlertSeverity("unknown"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestAlertReregistration tests that registering an alert with the same cause
+// bumps its count and that a different cause replaces the alert.
+func TestAlertReregistration(t *testing.T) {
+	alerter := NewAlerter(t.Name())
+	id := AlertID("id")
+
+	// Register the alert for the first time.
+	alerter.RegisterAlert(id, "msg", "cause", SeverityWarning)
+	_, _, warn, _ := alerter.Alerts()
+	if len(warn) != 1 {
+		t.Fatal("expected 1 alert", len(warn))
+	}
+	first := warn[0]
+	if first.Count != 1 || first.FirstRegistered.IsZero() || !first.FirstRegistered.Equal(first.LastRegistered) {
+		t.Fatal("unexpected initial registration", first.Count, first.FirstRegistered, first.LastRegistered)
+	}
+
+	// Register it again with the same cause, the count and the last registered
+	// time should be bumped.
+	time.Sleep(10 * time.Millisecond)
+	alerter.RegisterAlert(id, "msg", "cause", SeverityWarning)
+	_, _, warn, _ = alerter.Alerts()
+	if len(warn) != 1 {
+		t.Fatal("expected 1 alert", len(warn))
+	}
+	bumped := warn[0]
+	if bumped.Count != 2 {
+		t.Fatal("count wasn't bumped", bumped.Count)
+	}
+	if !bumped.FirstRegistered.Equal(first.FirstRegistered) {
+		t.Fatal("first registered time changed", bumped.FirstRegistered, first.FirstRegistered)
+	}
+	if !bumped.LastRegistered.After(first.LastRegistered) {
+		t.Fatal("last registered time wasn't updated", bumped.LastRegistered, first.LastRegistered)
+	}
+	if !bumped.Equals(first) {
+		t.Fatal("alert changed", bumped, first)
+	}
+
+	// Register it with a different cause, the alert should be replaced and the
+	// counter reset.
+	time.Sleep(10 * time.Millisecond)
+	alerter.RegisterAlert(id, "msg", "other cause", SeverityError)
+	_, err, warn, _ := alerter.Alerts()
+	if len(warn) != 0 || len(err) != 1 {
+		t.Fatal("unexpected alerts", len(warn), len(err))
+	}
+	replaced := err[0]
+	if replaced.Cause != "other cause" || replaced.Count != 1 {
+		t.Fatal("alert wasn't replaced", replaced.Cause, replaced.Count)
+	}
+	if !replaced.FirstRegistered.After(first.FirstRegistered) || !replaced.FirstRegistered.Equal(replaced.LastRegistered) {
+		t.Fatal("unexpected registration times", replaced.FirstRegistered, replaced.LastRegistered)
+	}
+
+	// Unregistering and registering again starts over.
+	alerter.UnregisterAlert(id)
+	alerter.RegisterAlert(id, "msg", "other cause", SeverityError)
+	_, err, _, _ = alerter.Alerts()
+	if len(err) != 1 || err[0].Count != 1 {
+		t.Fatal("unexpected alerts", err)
+	}
+}
+
+// TestAlertDetails verifies that alerts can carry structured details and that
+// the details are only marshaled if there are any.
+func TestAlertDetails(t *testing.T) {
+	alerter := NewAlerter(t.Name())
+	id := AlertID("id")
+
+	// Register an alert with details.
+	details := map[string]string{"contract": "fcid", "amount": "1 SC"}
+	alerter.RegisterAlertWithDetails(id, "msg", "cause", SeverityWarning, details)
+	_, _, warn, _ := alerter.Alerts()
+	if len(warn) != 1 {
+		t.Fatal("expected 1 alert", len(warn))
+	}
+	if len(warn[0].Details) != 2 || warn[0].Details["contract"] != "fcid" || warn[0].Details["amount"] != "1 SC" {
+		t.Fatal("unexpected details", warn[0].Details)
+	}
+
+	// Modifying the map after registering the alert doesn't change it.
+	details["contract"] = "other"
+	_, _, warn, _ = alerter.Alerts()
+	if warn[0].Details["contract"] != "fcid" {
+		t.Fatal("details were modified", warn[0].Details)
+	}
+
+	// The details are marshaled.
+	b, err := json.Marshal(warn[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alert Alert
+	if err := json.Unmarshal(b, &alert); err != nil {
+		t.Fatal(err)
+	}
+	if len(alert.Details) != 2 || alert.Details["contract"] != "fcid" {
+		t.Fatal("unexpected details after unmarshaling", alert.Details)
+	}
+
+	// Registering the alert without details removes them and they are omitted
+	// from the JSON.
+	alerter.RegisterAlert(id, "msg", "cause", SeverityWarning)
+	_, _, warn, _ = alerter.Alerts()
+	if warn[0].Details != nil || warn[0].Count != 2 {
+		t.Fatal("unexpected alert", warn[0].Details, warn[0].Count)
+	}
+	b, err = json.Marshal(warn[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := fields["details"]; exists {
+		t.Fatal("empty details should be omitted", string(b))
+	}
+}
+
+// TestPersistedAlerter verifies that the alerts of a persisted alerter survive
+// a restart, even if the alerts file is corrupted.
+func TestPersistedAlerter(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dir := build.TempDir("modules", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "test_alerts.json")
+
+	// a new alerter starts without alerts
+	a, err := NewPersistedAlerter("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.RegisterAlert("crit", "msg", "cause", SeverityCritical)
+	a.RegisterAlert("crit", "msg", "cause", SeverityCritical)
+	a.RegisterAlert("warn", "msg", "cause", SeverityWarning)
+	a.RegisterAlert("removed", "msg", "cause", SeverityError)
+	a.UnregisterAlert("removed")
+
+	// the changes are saved in the background
+	err = build.Retry(50, alertPersistInterval, func() error {
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+		return a.PersistErr()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// restart the alerter, the alerts should have been restored
+	a, err = NewPersistedAlerter("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crit, errs, warn, info := a.Alerts()
+	if len(crit) != 1 || len(errs) != 0 || len(warn) != 1 || len(info) != 0 {
+		t.Fatal("unexpected alerts", len(crit), len(errs), len(warn), len(info))
+	}
+	if !crit[0].Restored || !warn[0].Restored {
+		t.Fatal("alerts should be marked as restored")
+	}
+	if crit[0].Count != 2 || crit[0].FirstRegistered.IsZero() || crit[0].Msg != "msg" || crit[0].Module != "test" {
+		t.Fatal("unexpected alert", crit[0])
+	}
+
+	// registering an alert again confirms it, the unconfirmed one is removed
+	a.RegisterAlert("crit", "msg", "cause", SeverityCritical)
+	if ids := a.RestoredAlerts(); len(ids) != 1 || ids[0] != "warn" {
+		t.Fatal("unexpected restored alerts", ids)
+	}
+	a.UnregisterRestoredAlerts()
+	crit, _, warn, _ = a.Alerts()
+	if len(crit) != 1 || len(warn) != 0 || crit[0].Restored || crit[0].Count != 3 {
+		t.Fatal("unexpected alerts", crit, warn)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// changes after closing the alerter are not persisted
+	a.RegisterAlert("late", "msg", "cause", SeverityCritical)
+	time.Sleep(2 * alertPersistInterval)
+
+	// corrupt the alerts file, the backup should be used
+	if err := ioutil.WriteFile(path, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a, err = NewPersistedAlerter("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crit, _, warn, _ = a.Alerts()
+	if len(crit) != 1 || len(warn) != 0 || !crit[0].Restored {
+		t.Fatal("unexpected alerts", crit, warn)
+	}
+
+	// corrupt both files, the alerter should start without alerts and
+	// overwrite the corrupted files
+	files, err := filepath.Glob(path + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if err := ioutil.WriteFile(f, []byte("garbage"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err = NewPersistedAlerter("test", dir)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if crit, errs, warn, info := a.Alerts(); len(crit)+len(errs)+len(warn)+len(info) != 0 {
+		t.Fatal("corrupted alerter should start without alerts")
+	}
+	a.RegisterAlert("new", "msg", "cause", SeverityWarning)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	a, err = NewPersistedAlerter("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, warn, _ = a.Alerts()
+	if len(warn) != 1 || !warn[0].Restored {
+		t.Fatal("unexpected alerts", warn)
+	}
+}
+
+// TestAlertSubscribers verifies that the subscribers of an alerter receive the
+// alert events without blocking the alerter.
+func TestAlertSubscribers(t *testing.T) {
+	t.Parallel()
+
+	a := NewAlerter("test")
+
+	// multiple subscribers receive the same events
+	ch1 := make(chan AlertEvent, 10)
+	ch2 := make(chan AlertEvent, 10)
+	a.RegisterSubscriber(ch1)
+	a.RegisterSubscriber(ch2)
+	a.RegisterAlert("id", "msg", "cause", SeverityWarning)
+	a.UnregisterAlert("id")
+	a.UnregisterAlert("unknown")
+	for _, ch := range []chan AlertEvent{ch1, ch2} {
+		if len(ch) != 2 {
+			t.Fatal("unexpected number of events", len(ch))
+		}
+		registered := <-ch
+		if registered.ID != "id" || !registered.Registered || registered.Alert.Msg != "msg" || registered.Alert.Module != "test" {
+			t.Fatal("unexpected event", registered)
+		}
+		unregistered := <-ch
+		if unregistered.ID != "id" || unregistered.Registered || unregistered.Alert.Msg != "msg" {
+			t.Fatal("unexpected event", unregistered)
+		}
+	}
+
+	// a slow subscriber doesn't block the alerter, its events are dropped
+	slow := make(chan AlertEvent, 1)
+	a.RegisterSubscriber(slow)
+	for i := 0; i < 5; i++ {
+		a.RegisterAlert("id", "msg", "cause", SeverityWarning)
+	}
+	if len(slow) != 1 {
+		t.Fatal("unexpected number of events", len(slow))
+	}
+	if dropped := a.DroppedAlertEvents(slow); dropped != 4 {
+		t.Fatal("unexpected number of dropped events", dropped)
+	}
+	if dropped := a.DroppedAlertEvents(ch1); dropped != 0 {
+		t.Fatal("unexpected number of dropped events", dropped)
+	}
+
+	// unsubscribed channels don't receive any more events
+	a.UnregisterSubscriber(ch1)
+	a.UnregisterSubscriber(ch2)
+	a.UnregisterSubscriber(slow)
+	<-ch1
+	a.RegisterAlert("id", "msg", "cause", SeverityWarning)
+	if len(ch1) != 4 || len(slow) != 1 {
+		t.Fatal("unsubscribed channels received events", len(ch1), len(slow))
+	}
+
+	// subscribers can unsubscribe while events are being delivered
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			a.RegisterAlert(AlertID(strconv.Itoa(i%10)), "msg", "cause", SeverityWarning)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		ch := make(chan AlertEvent)
+		a.RegisterSubscriber(ch)
+		go func() {
+			a.UnregisterSubscriber(ch)
+		}()
+		select {
+		case <-ch:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// TestAlertAggregator verifies that the subscribers of an aggregator are
+// subscribed to all of the aggregated alerters.
+func TestAlertAggregator(t *testing.T) {
+	t.Parallel()
+
+	a1 := NewAlerter("a1")
+	a2 := NewAlerter("a2")
+	aa := AlertAggregator{a1, a2}
+
+	ch := make(chan AlertEvent, 10)
+	aa.RegisterSubscriber(ch)
+	a1.RegisterAlert("id1", "msg", "cause", SeverityCritical)
+	a2.RegisterAlert("id2", "msg", "cause", SeverityInfo)
+	if len(ch) != 2 {
+		t.Fatal("unexpected number of events", len(ch))
+	}
+	if e := <-ch; e.Alert.Module != "a1" {
+		t.Fatal("unexpected event", e)
+	}
+	if e := <-ch; e.Alert.Module != "a2" {
+		t.Fatal("unexpected event", e)
+	}
+	crit, _, _, info := aa.Alerts()
+	if len(crit) != 1 || len(info) != 1 {
+		t.Fatal("unexpected alerts", crit, info)
+	}
+
+	aa.UnregisterSubscriber(ch)
+	a1.UnregisterAlert("id1")
+	a2.UnregisterAlert("id2")
+	if len(ch) != 0 {
+		t.Fatal("unsubscribed channel received events", len(ch))
+	}
+}
+
+// TestSubAlerters verifies that the alerts of sub alerters roll up into their
+// parent alerters.
+func TestSubAlerters(t *testing.T) {
+	t.Parallel()
+
+	root := NewAlerter("root")
+	child := NewAlerter("child")
+	grandchild := NewAlerter("grandchild")
+	if err := root.AddSubAlerter(child); err != nil {
+		t.Fatal(err)
+	}
+	if err := child.AddSubAlerter(grandchild); err != nil {
+		t.Fatal(err)
+	}
+
+	// cycles and duplicates are rejected
+	if err := root.AddSubAlerter(root); !errors.Contains(err, ErrSubAlerterCycle) {
+		t.Fatal("unexpected error", err)
+	}
+	if err := grandchild.AddSubAlerter(root); !errors.Contains(err, ErrSubAlerterCycle) {
+		t.Fatal("unexpected error", err)
+	}
+	if err := root.AddSubAlerter(child); !errors.Contains(err, ErrSubAlerterExists) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// subscribers of the root receive the events of the whole hierarchy
+	ch := make(chan AlertEvent, 10)
+	root.RegisterSubscriber(ch)
+
+	root.RegisterAlert("b", "msg", "cause", SeverityWarning)
+	root.RegisterAlert("a", "msg", "cause", SeverityWarning)
+	child.RegisterAlert("c", "msg", "cause", SeverityCritical)
+	grandchild.RegisterAlert("d", "msg", "cause", SeverityWarning)
+	if len(ch) != 4 {
+		t.Fatal("unexpected number of events", len(ch))
+	}
+
+	// the alerts are returned in a deterministic order, with the module of
+	// the alerter that registered them
+	for i := 0; i < 10; i++ {
+		crit, _, warn, _ := root.Alerts()
+		if len(crit) != 1 || crit[0].Module != "child" {
+			t.Fatal("unexpected critical alerts", crit)
+		}
+		if len(warn) != 3 || warn[0].Module != "root" || warn[1].Module != "root" || warn[2].Module != "grandchild" {
+			t.Fatal("unexpected warnings", warn)
+		}
+	}
+	crit, _, warn, _ := child.Alerts()
+	if len(crit) != 1 || len(warn) != 1 {
+		t.Fatal("unexpected alerts of the child", crit, warn)
+	}
+
+	// removing the child removes its alerts and its events
+	if err := root.RemoveSubAlerter(child); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.RemoveSubAlerter(child); !errors.Contains(err, ErrSubAlerterNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+	crit, _, warn, _ = root.Alerts()
+	if len(crit) != 0 || len(warn) != 2 {
+		t.Fatal("unexpected alerts after removing the child", crit, warn)
+	}
+	grandchild.UnregisterAlert("d")
+	child.UnregisterAlert("c")
+	if len(ch) != 4 {
+		t.Fatal("received events of a removed child", len(ch))
+	}
+
+	// the removed child can be added again, without creating a cycle
+	if err := child.AddSubAlerter(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddSubAlerter(child); !errors.Contains(err, ErrSubAlerterCycle) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// TestDismissSnoozeAlerts verifies alerts can be dismissed and snoozed, that a
+// dismissal is reset when the cause of the alert changes and that a snooze
+// expires.
+func TestDismissSnoozeAlerts(t *testing.T) {
+	t.Parallel()
+
+	a := NewAlerter("test")
+	getAlert := func() Alert {
+		_, _, warn, _ := a.Alerts()
+		if len(warn) != 1 {
+			t.Fatal("unexpected number of alerts", len(warn))
+		}
+		return warn[0]
+	}
+
+	// unknown alerts can't be dismissed or snoozed
+	if err := a.DismissAlert("a"); !errors.Contains(err, ErrAlertNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+	if err := a.SnoozeAlert("a", time.Minute); !errors.Contains(err, ErrAlertNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// dismiss an alert, it's still returned but flagged
+	a.RegisterAlert("a", "msg", "cause", SeverityWarning)
+	if err := a.DismissAlert("a"); err != nil {
+		t.Fatal(err)
+	}
+	alert := getAlert()
+	if !alert.Dismissed || alert.ID != "a" {
+		t.Fatal("alert should be dismissed", alert)
+	}
+
+	// registering it again with the same cause keeps the dismissal
+	a.RegisterAlert("a", "msg", "cause", SeverityWarning)
+	if alert := getAlert(); !alert.Dismissed {
+		t.Fatal("alert should still be dismissed")
+	}
+
+	// a different cause resets the dismissal
+	a.RegisterAlert("a", "msg", "other cause", SeverityWarning)
+	if alert := getAlert(); alert.Dismissed {
+		t.Fatal("alert shouldn't be dismissed anymore")
+	}
+
+	// snooze the alert
+	if err := a.SnoozeAlert("a", 0); !errors.Contains(err, ErrInvalidSnoozeDuration) {
+		t.Fatal("unexpected error", err)
+	}
+	if err := a.SnoozeAlert("a", 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if alert := getAlert(); !alert.Snoozed() || alert.SnoozeUntil.IsZero() {
+		t.Fatal("alert should be snoozed")
+	}
+
+	// once the snooze expired the alert is un-flagged
+	time.Sleep(200 * time.Millisecond)
+	if alert := getAlert(); alert.Snoozed() || !alert.SnoozeUntil.IsZero() {
+		t.Fatal("snooze should have expired", alert.SnoozeUntil)
+	}
+}
+
+// TestDismissModuleAlert verifies alerts are addressed by module and id when
+// dismissing them through sub alerters and aggregators.
+func TestDismissModuleAlert(t *testing.T) {
+	t.Parallel()
+
+	root := NewAlerter("root")
+	child := NewAlerter("child")
+	other := NewAlerter("other")
+	if err := root.AddSubAlerter(child); err != nil {
+		t.Fatal(err)
+	}
+	root.RegisterAlert("a", "msg", "cause", SeverityWarning)
+	child.RegisterAlert("a", "msg", "cause", SeverityWarning)
+	other.RegisterAlert("b", "msg", "cause", SeverityWarning)
+	aa := AlertAggregator{root, other}
+
+	// dismiss the child's alert, the root's alert with the same id remains
+	if err := aa.DismissModuleAlert("child", "a"); err != nil {
+		t.Fatal(err)
+	}
+	_, _, warn, _ := aa.Alerts()
+	for _, alert := range warn {
+		if alert.Dismissed != (alert.Module == "child") {
+			t.Fatal("unexpected dismissal", alert)
+		}
+	}
+
+	// snooze the alert of the other alerter
+	if err := aa.SnoozeModuleAlert("other", "b", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	_, _, warn, _ = other.Alerts()
+	if !warn[0].Snoozed() {
+		t.Fatal("alert should be snoozed")
+	}
+
+	// unknown modules and ids are reported
+	if err := aa.DismissModuleAlert("unknown", "a"); !errors.Contains(err, ErrAlertNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+	if err := aa.DismissModuleAlert("other", "a"); !errors.Contains(err, ErrAlertNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// TestAlertIDsUnique verifies that the alert ids declared in this package are
+// unique, since alerts are identified by their id within a module.
+func TestAlertIDsUnique(t *testing.T) {
+	t.Parallel()
+
+	ids := []AlertID{
+		alertIDUnknown,
+		AlertIDWalletLockedDuringMaintenance,
+		AlertIDRenterAllowanceLowFunds,
+		AlertIDRenterContractRenewalError,
+		AlertIDGatewayOffline,
+		AlertIDHostDiskTrouble,
+		AlertIDHostInsufficientCollateral,
+		AlertIDRenterHasSectorErrors,
+		AlertIDConsensusInitialSync,
+		AlertIDHostUnknownWALEntries,
+		AlertIDHostCorruptWALEntries,
+		AlertIDHostSlowWALCommits,
+		AlertIDRenterWalletLowBalance,
+		AlertIDRenterAllowanceInsufficientHosts,
+		AlertIDHostLowStorage,
+		AlertIDHostCollateralBudgetLocked,
+		AlertIDHostWalletInsufficientCollateral,
+		AlertIDRenterUnrecoverableFiles,
+		AlertIDRenterLowRedundancyDirs,
+		AlertIDRenterRefCounterUnderflow,
+		AlertIDRenterRefCounterCorrupt,
+		AlertIDGatewayNoOutboundPeers,
+		AlertIDGatewaySyncStalled,
+		AlertIDAlertsTruncated,
+		AlertIDAlertWebhookFailing,
+		AlertIDHostNoWritableStorageFolders,
+		AlertIDSiafileLowRedundancy(""),
+		AlertIDRenterRefCounterRebuilt(""),
+		AlertIDHostCorruptSectors(""),
+		AlertIDHostStorageFolderQuarantined(""),
+	}
+	seen := make(map[AlertID]struct{})
+	for _, id := range ids {
+		if id == "" {
+			t.Fatal("empty alert id")
+		}
+		if _, exists := seen[id]; exists {
+			t.Fatal("duplicate alert id", id)
+		}
+		seen[id] = struct{}{}
+	}
+}
+
+// TestAlertTTL verifies that alerts registered with a ttl expire, that
+// re-registering them refreshes the ttl and that Alerts never returns expired
+// alerts.
+func TestAlertTTL(t *testing.T) {
+	t.Parallel()
+
+	a := NewAlerter("test")
+	ch := make(chan AlertEvent, 10)
+	a.RegisterSubscriber(ch)
+
+	ttl := 200 * time.Millisecond
+	a.RegisterAlertWithTTL("ttl", "msg", "cause", SeverityWarning, ttl)
+	a.RegisterAlert("permanent", "msg", "cause", SeverityWarning)
+	_, _, warn, _ := a.Alerts()
+	if len(warn) != 2 {
+		t.Fatal("unexpected number of alerts", len(warn))
+	}
+
+	// re-register the alert halfway through its ttl, it should still be
+	// around after the original deadline
+	time.Sleep(ttl / 2)
+	a.RegisterAlertWithTTL("ttl", "msg", "cause", SeverityWarning, ttl)
+	time.Sleep(ttl * 3 / 4)
+	_, _, warn, _ = a.Alerts()
+	if len(warn) != 2 {
+		t.Fatal("alert should not have expired yet", len(warn))
+	}
+
+	// once the ttl has passed, the alert is gone
+	time.Sleep(ttl)
+	_, _, warn, _ = a.Alerts()
+	if len(warn) != 1 || warn[0].ID != "permanent" {
+		t.Fatal("alert should have expired", warn)
+	}
+	if err := a.DismissAlert("ttl"); !errors.Contains(err, ErrAlertNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// subscribers are notified about the expiry
+	var unregistered bool
+	for len(ch) > 0 {
+		e := <-ch
+		unregistered = unregistered || (!e.Registered && e.ID == "ttl")
+	}
+	if !unregistered {
+		t.Fatal("subscriber wasn't notified about the expiry")
+	}
+
+	// registering an alert without a ttl makes it permanent
+	a.RegisterAlertWithTTL("ttl", "msg", "cause", SeverityWarning, ttl)
+	a.RegisterAlert("ttl", "msg", "cause", SeverityWarning)
+	time.Sleep(ttl * 3 / 2)
+	_, _, warn, _ = a.Alerts()
+	if len(warn) != 2 {
+		t.Fatal("alert shouldn't expire", len(warn))
+	}
+}
+
+// TestAlertEscalation tests that alerts registered with an escalation policy
+// have their severity raised once they have been registered for long enough.
+func TestAlertEscalation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dir := build.TempDir("modules", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewPersistedAlerter("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan AlertEvent, 10)
+	a.RegisterSubscriber(ch)
+
+	escalateAfter := 500 * time.Millisecond
+	register := func(cause string) {
+		a.RegisterAlertWithEscalation("escalate", "msg", cause, SeverityWarning, escalateAfter, SeverityError)
+	}
+	register("cause")
+	_, errs, warn, _ := a.Alerts()
+	if len(errs) != 0 || len(warn) != 1 {
+		t.Fatal("alert shouldn't be escalated yet", len(errs), len(warn))
+	}
+
+	// unregistering and registering the alert with the same cause doesn't
+	// reset the clock
+	time.Sleep(escalateAfter / 2)
+	a.UnregisterAlert("escalate")
+	register("cause")
+	time.Sleep(escalateAfter * 3 / 4)
+	_, errs, warn, _ = a.Alerts()
+	if len(errs) != 1 || len(warn) != 0 {
+		t.Fatal("alert should be escalated", len(errs), len(warn))
+	}
+	if !errs[0].LastRegistered.After(errs[0].EscalateAt) {
+		t.Fatal("escalation should update LastRegistered", errs[0].LastRegistered, errs[0].EscalateAt)
+	}
+
+	// subscribers are notified about the escalation
+	var escalated bool
+	for len(ch) > 0 {
+		e := <-ch
+		escalated = escalated || (e.Registered && e.Alert.Severity == SeverityError)
+	}
+	if !escalated {
+		t.Fatal("subscriber wasn't notified about the escalation")
+	}
+
+	// registering the alert again keeps it escalated
+	register("cause")
+	_, errs, _, _ = a.Alerts()
+	if len(errs) != 1 {
+		t.Fatal("alert should stay escalated", len(errs))
+	}
+
+	// the escalation survives a restart
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	a, err = NewPersistedAlerter("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errs, _, _ = a.Alerts()
+	if len(errs) != 1 || !errs[0].Restored {
+		t.Fatal("escalated alert wasn't restored", errs)
+	}
+	register("cause")
+	_, errs, _, _ = a.Alerts()
+	if len(errs) != 1 {
+		t.Fatal("escalation clock wasn't restored", len(errs))
+	}
+
+	// a different cause resets the clock
+	register("other cause")
+	_, errs, warn, _ = a.Alerts()
+	if len(errs) != 0 || len(warn) != 1 {
+		t.Fatal("new cause should reset the escalation", len(errs), len(warn))
+	}
+	time.Sleep(escalateAfter * 3 / 2)
+	_, errs, _, _ = a.Alerts()
+	if len(errs) != 1 || errs[0].Cause != "other cause" {
+		t.Fatal("alert should be escalated", errs)
+	}
+
+	// a pending escalation survives a restart too
+	register("third cause")
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	a, err = NewPersistedAlerter("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errs, warn, _ = a.Alerts()
+	if len(errs) != 0 || len(warn) != 1 {
+		t.Fatal("alert shouldn't be escalated yet", len(errs), len(warn))
+	}
+	time.Sleep(escalateAfter * 3 / 2)
+	_, errs, _, _ = a.Alerts()
+	if len(errs) != 1 {
+		t.Fatal("restored alert should be escalated", len(errs))
+	}
+
+	// registering the alert without escalation drops the policy
+	a.RegisterAlert("escalate", "msg", "third cause", SeverityWarning)
+	time.Sleep(escalateAfter * 3 / 2)
+	_, errs, warn, _ = a.Alerts()
+	if len(errs) != 0 || len(warn) != 1 {
+		t.Fatal("alert shouldn't escalate without a policy", len(errs), len(warn))
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUpdateAlertSeverity verifies that UpdateAlertSeverity changes the
+// severity of an existing alert in place and cancels its escalation.
+func TestUpdateAlertSeverity(t *testing.T) {
+	t.Parallel()
+
+	a := NewAlerter("test")
+	ch := make(chan AlertEvent, 10)
+	a.RegisterSubscriber(ch)
+
+	// unknown alerts aren't registered
+	if a.UpdateAlertSeverity("alert", SeverityCritical) {
+		t.Fatal("alert shouldn't exist")
+	}
+	crit, _, _, _ := a.Alerts()
+	if len(crit) != 0 {
+		t.Fatal("alert shouldn't be registered", crit)
+	}
+
+	// an escalated alert is lowered without being removed
+	a.RegisterAlertWithEscalation("alert", "msg", "cause", SeverityWarning, 0, SeverityCritical)
+	crit, _, _, _ = a.Alerts()
+	if len(crit) != 1 {
+		t.Fatal("alert should be escalated", crit)
+	}
+	for len(ch) > 0 {
+		<-ch
+	}
+	if !a.UpdateAlertSeverity("alert", SeverityWarning) {
+		t.Fatal("alert should exist")
+	}
+	crit, _, warn, _ := a.Alerts()
+	if len(crit) != 0 || len(warn) != 1 {
+		t.Fatal("alert should be a warning", crit, warn)
+	}
+	alert := warn[0]
+	if alert.Msg != "msg" || alert.Cause != "cause" || alert.Count != 1 {
+		t.Fatal("alert should be unchanged", alert)
+	}
+	if !alert.EscalateAt.IsZero() || alert.EscalatedSeverity != SeverityUnknown {
+		t.Fatal("escalation should be cancelled", alert)
+	}
+
+	// subscribers only see the severity change
+	if len(ch) != 1 {
+		t.Fatal("expected a single event", len(ch))
+	}
+	if e := <-ch; !e.Registered || e.Alert.Severity != SeverityWarning {
+		t.Fatal("unexpected event", e)
+	}
+
+	// updating to the same severity is a no-op
+	if !a.UpdateAlertSeverity("alert", SeverityWarning) {
+		t.Fatal("alert should exist")
+	}
+	if len(ch) != 0 {
+		t.Fatal("no event expected", len(ch))
+	}
+}
+
+// TestHighestSeverity verifies that HighestSeverity returns the highest
+// severity among the alerts of an alerter and its sub alerters.
+func TestHighestSeverity(t *testing.T) {
+	t.Parallel()
+
+	a := NewAlerter("test")
+	if severity := a.HighestSeverity(); severity != SeverityUnknown {
+		t.Fatal("expected SeverityUnknown without alerts, got", severity)
+	}
+
+	// mixed severities
+	a.RegisterAlert("info", "msg", "cause", SeverityInfo)
+	a.RegisterAlert("error", "msg", "cause", SeverityError)
+	a.RegisterAlert("warning", "msg", "cause", SeverityWarning)
+	if severity := a.HighestSeverity(); severity != SeverityError {
+		t.Fatal("expected SeverityError, got", severity)
+	}
+
+	// the alerts of sub alerters are included
+	sub := NewAlerter("sub")
+	if err := a.AddSubAlerter(sub); err != nil {
+		t.Fatal(err)
+	}
+	sub.RegisterAlert("critical", "msg", "cause", SeverityCritical)
+	if severity := a.HighestSeverity(); severity != SeverityCritical {
+		t.Fatal("expected SeverityCritical, got", severity)
+	}
+	sub.UnregisterAlert("critical")
+
+	// escalated alerts count with their escalated severity
+	a.RegisterAlertWithEscalation("escalated", "msg", "cause", SeverityInfo, 0, SeverityCritical)
+	if severity := a.HighestSeverity(); severity != SeverityCritical {
+		t.Fatal("expected SeverityCritical, got", severity)
+	}
+
+	// unregistering the alerts lowers the severity again
+	a.UnregisterAlert("escalated")
+	a.UnregisterAlert("error")
+	if severity := a.HighestSeverity(); severity != SeverityWarning {
+		t.Fatal("expected SeverityWarning, got", severity)
+	}
+	a.UnregisterAll()
+	if severity := a.HighestSeverity(); severity != SeverityUnknown {
+		t.Fatal("expected SeverityUnknown without alerts, got", severity)
+	}
+}
+
+// TestUnregisterAll verifies that UnregisterAll removes all of an alerter's
+// alerts but not the alerts of its sub-alerters.
+func TestUnregisterAll(t *testing.T) {
+	t.Parallel()
+
+	a := NewAlerter("test")
+	sub := NewAlerter("sub")
+	if err := a.AddSubAlerter(sub); err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan AlertEvent, 10)
+	a.RegisterSubscriber(ch)
+
+	a.RegisterAlert("info", "msg", "cause", SeverityInfo)
+	a.RegisterAlert("warn", "msg", "cause", SeverityWarning)
+	a.RegisterAlertWithEscalation("err", "msg", "cause", SeverityError, time.Hour, SeverityCritical)
+	sub.RegisterAlert("sub", "msg", "cause", SeverityCritical)
+	for len(ch) > 0 {
+		<-ch
+	}
+
+	a.UnregisterAll()
+	crit, err, warn, info := a.Alerts()
+	if len(err) != 0 || len(warn) != 0 || len(info) != 0 {
+		t.Fatal("alerts weren't unregistered", err, warn, info)
+	}
+	if len(crit) != 1 || crit[0].Module != "sub" {
+		t.Fatal("the alerts of the sub-alerter should be kept", crit)
+	}
+
+	// subscribers are notified about every unregistered alert
+	if len(ch) != 3 {
+		t.Fatal("unexpected number of events", len(ch))
+	}
+	for len(ch) > 0 {
+		if e := <-ch; e.Registered {
+			t.Fatal("unexpected event", e)
+		}
+	}
+
+	// the escalation clocks are reset
+	if len(a.escalationClocks) != 0 {
+		t.Fatal("escalation clocks weren't reset", a.escalationClocks)
+	}
+
+	// unregistering all alerts of an empty alerter is a no-op
+	a.UnregisterAll()
+	if len(ch) != 0 {
+		t.Fatal("unexpected events", len(ch))
+	}
+}
+
+// TestNewAlerterWithPersist verifies that an alerter persisted to a custom
+// path restores its alerts as stale until they are registered again.
+func TestNewAlerterWithPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dir := build.TempDir("modules", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "custom.json")
+
+	a, err := NewAlerterWithPersist("test", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.RegisterAlert("crit", "msg", "wallet locked", SeverityCritical)
+	a.RegisterAlert("warn", "msg", "cause", SeverityWarning)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal("alerts weren't persisted to the given path", err)
+	}
+
+	// an in-memory alerter doesn't restore anything
+	if crit, _, warn, _ := NewAlerter("test").Alerts(); len(crit) != 0 || len(warn) != 0 {
+		t.Fatal("in-memory alerter shouldn't have alerts")
+	}
+
+	a, err = NewAlerterWithPersist("test", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crit, _, warn, _ := a.Alerts()
+	if len(crit) != 1 || len(warn) != 1 || !crit[0].Restored || !warn[0].Restored {
+		t.Fatal("alerts should have been restored", crit, warn)
+	}
+	if crit[0].Cause != "wallet locked" {
+		t.Fatal("unexpected cause", crit[0].Cause)
+	}
+
+	// registering a restored alert again confirms it
+	a.RegisterAlert("crit", "msg", "wallet locked", SeverityCritical)
+	if restored := a.RestoredAlerts(); len(restored) != 1 || restored[0] != "warn" {
+		t.Fatal("unexpected restored alerts", restored)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAlertHysteresis verifies that flapping alerts with hysteresis are
+// treated as continuously registered and that their flaps are counted.
+func TestAlertHysteresis(t *testing.T) {
+	t.Parallel()
+
+	a := NewAlerter("test")
+	window := 200 * time.Millisecond
+	a.SetAlertHysteresis("flapping", window)
+	ch := make(chan AlertEvent, 100)
+	a.RegisterSubscriber(ch)
+
+	// simulate a flapping condition, only the first registration should
+	// cause an event
+	flaps := int(alertFlapThreshold) + 2
+	a.RegisterAlert("flapping", "msg", "cause", SeverityWarning)
+	for i := 0; i < flaps; i++ {
+		a.UnregisterAlert("flapping")
+		a.RegisterAlert("flapping", "msg", "cause", SeverityWarning)
+		_, _, warn, _ := a.Alerts()
+		if len(warn) != 1 {
+			t.Fatal("alert should be registered continuously", warn)
+		}
+		if uint64(i+1) < alertFlapThreshold && warn[0].Flaps != 0 {
+			t.Fatal("flaps shouldn't be reported below the threshold", warn[0].Flaps)
+		} else if uint64(i+1) >= alertFlapThreshold && warn[0].Flaps != uint64(i+1) {
+			t.Fatal("unexpected number of flaps", warn[0].Flaps, i+1)
+		}
+	}
+	if len(ch) != 1 {
+		t.Fatal("unexpected number of events", len(ch))
+	}
+	<-ch
+	_, _, warn, _ := a.Alerts()
+	if warn[0].Count != uint64(flaps+1) {
+		t.Fatal("count should be bumped by every registration", warn[0].Count)
+	}
+
+	// an alert without hysteresis still clears right away
+	a.RegisterAlert("momentary", "msg", "cause", SeverityInfo)
+	a.UnregisterAlert("momentary")
+	if _, _, _, info := a.Alerts(); len(info) != 0 {
+		t.Fatal("alert without hysteresis should be removed", info)
+	}
+	for len(ch) > 0 {
+		<-ch
+	}
+
+	// registering the alert with a different cause isn't a flap
+	a.UnregisterAlert("flapping")
+	a.RegisterAlert("flapping", "msg", "other cause", SeverityWarning)
+	_, _, warn, _ = a.Alerts()
+	if len(warn) != 1 || warn[0].Flaps != 0 || warn[0].Count != 1 {
+		t.Fatal("unexpected alert", warn)
+	}
+	if len(ch) != 1 {
+		t.Fatal("unexpected number of events", len(ch))
+	}
+	<-ch
+
+	// once the window passes the alert is removed and the subscribers are
+	// notified without querying the alerts
+	a.UnregisterAlert("flapping")
+	select {
+	case e := <-ch:
+		if e.Registered || e.ID != "flapping" {
+			t.Fatal("unexpected event", e)
+		}
+	case <-time.After(10 * window):
+		t.Fatal("alert wasn't removed")
+	}
+	if _, _, warn, _ := a.Alerts(); len(warn) != 0 {
+		t.Fatal("alert should be removed", warn)
+	}
+
+	// disabling hysteresis removes the alert right away again
+	a.SetAlertHysteresis("flapping", 0)
+	a.RegisterAlert("flapping", "msg", "cause", SeverityWarning)
+	a.UnregisterAlert("flapping")
+	if _, _, warn, _ := a.Alerts(); len(warn) != 0 {
+		t.Fatal("alert should be removed", warn)
+	}
+}
+
+// TestAlertLimit verifies that an alerter evicts alerts once it exceeds its
+// limit and registers an alert about it.
+func TestAlertLimit(t *testing.T) {
+	t.Parallel()
+
+	a := NewAlerter("test")
+	if count, limit := a.AlertUsage(); count != 0 || limit != DefaultAlertLimit {
+		t.Fatal("unexpected usage", count, limit)
+	}
+	a.SetAlertLimit(5)
+
+	// register alerts of every severity, the oldest first
+	a.RegisterAlert("crit1", "msg", "cause", SeverityCritical)
+	a.RegisterAlert("warn1", "msg", "cause", SeverityWarning)
+	a.RegisterAlert("info1", "msg", "cause", SeverityInfo)
+	a.RegisterAlert("err1", "msg", "cause", SeverityError)
+	if count, _ := a.AlertUsage(); count != 4 {
+		t.Fatal("unexpected count", count)
+	}
+	time.Sleep(time.Millisecond)
+	a.RegisterAlert("info2", "msg", "cause", SeverityInfo)
+	if _, _, _, info := a.Alerts(); len(info) != 2 {
+		t.Fatal("no alert should have been evicted yet", info)
+	}
+
+	// exceeding the limit evicts the oldest alert with the lowest severity and
+	// another one to make room for the meta-alert
+	time.Sleep(time.Millisecond)
+	a.RegisterAlert("warn2", "msg", "cause", SeverityWarning)
+	if count, _ := a.AlertUsage(); count != 5 {
+		t.Fatal("unexpected count", count)
+	}
+	crit, err, warn, info := a.Alerts()
+	if len(crit) != 1 || len(err) != 1 || len(info) != 0 || len(warn) != 3 {
+		t.Fatal("unexpected alerts", crit, err, warn, info)
+	}
+	var meta Alert
+	for _, alert := range warn {
+		if alert.Msg == AlertMSGAlertsTruncated {
+			meta = alert
+		}
+	}
+	if meta.Cause != "alerts truncated for module test" || meta.Severity != SeverityWarning {
+		t.Fatal("unexpected meta-alert", meta)
+	}
+
+	// registering more alerts evicts the older warnings before the errors and
+	// never evicts the meta-alert
+	time.Sleep(time.Millisecond)
+	a.RegisterAlert("crit2", "msg", "cause", SeverityCritical)
+	time.Sleep(time.Millisecond)
+	a.RegisterAlert("crit3", "msg", "cause", SeverityCritical)
+	crit, err, warn, _ = a.Alerts()
+	if len(crit) != 3 || len(err) != 1 || len(warn) != 1 || warn[0].Msg != AlertMSGAlertsTruncated {
+		t.Fatal("unexpected alerts", crit, err, warn)
+	}
+
+	// critical alerts are only evicted once only critical alerts are left
+	time.Sleep(time.Millisecond)
+	a.RegisterAlert("crit4", "msg", "cause", SeverityCritical)
+	crit, err, _, _ = a.Alerts()
+	if len(crit) != 4 || len(err) != 0 {
+		t.Fatal("unexpected alerts", crit, err)
+	}
+	time.Sleep(time.Millisecond)
+	a.RegisterAlert("crit5", "msg", "cause", SeverityCritical)
+	crit, _, _, _ = a.Alerts()
+	if len(crit) != 4 {
+		t.Fatal("unexpected alerts", crit)
+	}
+	a.mu.Lock()
+	_, exists := a.alerts["crit1"]
+	a.mu.Unlock()
+	if exists {
+		t.Fatal("the oldest critical alert should have been evicted")
+	}
+
+	// lowering the limit evicts alerts right away
+	a.SetAlertLimit(2)
+	if count, limit := a.AlertUsage(); count != 2 || limit != 2 {
+		t.Fatal("unexpected usage", count, limit)
+	}
+}
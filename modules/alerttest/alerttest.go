@@ -0,0 +1,121 @@
+// Package alerttest provides an alerter for module unit tests. It records the
+// alerts a module registers and unregisters and allows for asserting on them
+// without reaching into the module's unexported state.
+package alerttest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/modules"
+)
+
+// pollInterval is the interval at which WaitForAlert checks for the alert.
+const pollInterval = 10 * time.Millisecond
+
+type (
+	// Alerter is a modules.ModuleAlerter that records every call that
+	// registers or unregisters an alert. The alerts are tracked by a
+	// modules.GenericAlerter, so ttls, escalation and sub alerters behave like
+	// they do in production.
+	Alerter struct {
+		*modules.GenericAlerter
+
+		events []Event
+		mu     sync.Mutex
+	}
+
+	// Event is a call to register or unregister an alert that was recorded by
+	// the Alerter.
+	Event struct {
+		ID         modules.AlertID
+		Msg        string
+		Cause      string
+		Severity   modules.AlertSeverity
+		Registered bool
+	}
+)
+
+var _ modules.ModuleAlerter = (*Alerter)(nil)
+
+// New creates a new Alerter for the given module.
+func New(module string) *Alerter {
+	return &Alerter{
+		GenericAlerter: modules.NewAlerter(module),
+	}
+}
+
+// RegisterAlert records the call and registers the alert.
+func (a *Alerter) RegisterAlert(id modules.AlertID, msg, cause string, severity modules.AlertSeverity) {
+	a.record(Event{ID: id, Msg: msg, Cause: cause, Severity: severity, Registered: true})
+	a.GenericAlerter.RegisterAlert(id, msg, cause, severity)
+}
+
+// RegisterAlertWithEscalation records the call and registers the alert.
+func (a *Alerter) RegisterAlertWithEscalation(id modules.AlertID, msg, cause string, severity modules.AlertSeverity, escalateAfter time.Duration, escalatedSeverity modules.AlertSeverity) {
+	a.record(Event{ID: id, Msg: msg, Cause: cause, Severity: severity, Registered: true})
+	a.GenericAlerter.RegisterAlertWithEscalation(id, msg, cause, severity, escalateAfter, escalatedSeverity)
+}
+
+// RegisterAlertWithTTL records the call and registers the alert.
+func (a *Alerter) RegisterAlertWithTTL(id modules.AlertID, msg, cause string, severity modules.AlertSeverity, ttl time.Duration) {
+	a.record(Event{ID: id, Msg: msg, Cause: cause, Severity: severity, Registered: true})
+	a.GenericAlerter.RegisterAlertWithTTL(id, msg, cause, severity, ttl)
+}
+
+// UnregisterAlert records the call and unregisters the alert.
+func (a *Alerter) UnregisterAlert(id modules.AlertID) {
+	a.record(Event{ID: id})
+	a.GenericAlerter.UnregisterAlert(id)
+}
+
+// Events returns the recorded calls in the order they were made.
+func (a *Alerter) Events() []Event {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Event(nil), a.events...)
+}
+
+// Alert returns the registered alert with the given id. The bool is false if
+// no such alert is registered.
+func (a *Alerter) Alert(id modules.AlertID) (modules.Alert, bool) {
+	crit, err, warn, info := a.Alerts()
+	for _, alerts := range [][]modules.Alert{crit, err, warn, info} {
+		for _, alert := range alerts {
+			if alert.ID == id {
+				return alert, true
+			}
+		}
+	}
+	return modules.Alert{}, false
+}
+
+// HasAlert returns whether an alert with the given id is registered.
+func (a *Alerter) HasAlert(id modules.AlertID) bool {
+	_, ok := a.Alert(id)
+	return ok
+}
+
+// WaitForAlert waits for an alert with the given id to be registered and
+// returns it. An error is returned if the alert isn't registered within the
+// timeout.
+func (a *Alerter) WaitForAlert(id modules.AlertID, timeout time.Duration) (modules.Alert, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if alert, ok := a.Alert(id); ok {
+			return alert, nil
+		}
+		if time.Now().After(deadline) {
+			return modules.Alert{}, fmt.Errorf("alert %v wasn't registered within %v", id, timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// record appends the event to the recorded calls.
+func (a *Alerter) record(e Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, e)
+}
@@ -0,0 +1,59 @@
+package alerttest
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/modules"
+)
+
+// TestAlerter verifies that the Alerter records the register and unregister
+// calls and tracks the registered alerts.
+func TestAlerter(t *testing.T) {
+	t.Parallel()
+
+	a := New("test")
+	if a.HasAlert("id") {
+		t.Fatal("alert shouldn't be registered")
+	}
+	if _, err := a.WaitForAlert("id", 50*time.Millisecond); err == nil {
+		t.Fatal("waiting for an unregistered alert should time out")
+	}
+
+	// register an alert in the background and wait for it
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		a.RegisterAlert("id", "msg", "cause", modules.SeverityWarning)
+	}()
+	alert, err := a.WaitForAlert("id", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert.Module != "test" || alert.Msg != "msg" || alert.Cause != "cause" || alert.Severity != modules.SeverityWarning {
+		t.Fatal("unexpected alert", alert)
+	}
+
+	// the other register methods are recorded as well
+	a.RegisterAlertWithTTL("ttl", "msg", "cause", modules.SeverityError, time.Hour)
+	a.RegisterAlertWithEscalation("esc", "msg", "cause", modules.SeverityInfo, time.Hour, modules.SeverityError)
+	a.UnregisterAlert("id")
+	if a.HasAlert("id") || !a.HasAlert("ttl") || !a.HasAlert("esc") {
+		t.Fatal("unexpected alerts")
+	}
+
+	expected := []Event{
+		{ID: "id", Msg: "msg", Cause: "cause", Severity: modules.SeverityWarning, Registered: true},
+		{ID: "ttl", Msg: "msg", Cause: "cause", Severity: modules.SeverityError, Registered: true},
+		{ID: "esc", Msg: "msg", Cause: "cause", Severity: modules.SeverityInfo, Registered: true},
+		{ID: "id"},
+	}
+	events := a.Events()
+	if len(events) != len(expected) {
+		t.Fatal("unexpected number of events", events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("event %v: expected %v, got %v", i, expected[i], events[i])
+		}
+	}
+}
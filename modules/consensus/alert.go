@@ -1,10 +1,42 @@
 package consensus
 
 import (
+	"time"
+
 	"go.sia.tech/siad/modules"
 )
 
+const (
+	// AlertMSGConsensusInitialSync indicates that the consensus set is
+	// performing its initial blockchain download.
+	AlertMSGConsensusInitialSync = "initial blockchain download in progress"
+)
+
 // Alerts implements the Alerter interface for the consensusset.
 func (c *ConsensusSet) Alerts() (crit, err, warn, info []modules.Alert) {
-	return
+	return c.staticAlerter.Alerts()
+}
+
+// RegisterSubscriber implements the modules.AlertSubscriber interface for the
+// consensusset.
+func (c *ConsensusSet) RegisterSubscriber(ch chan<- modules.AlertEvent) {
+	c.staticAlerter.RegisterSubscriber(ch)
+}
+
+// UnregisterSubscriber implements the modules.AlertSubscriber interface for
+// the consensusset.
+func (c *ConsensusSet) UnregisterSubscriber(ch chan<- modules.AlertEvent) {
+	c.staticAlerter.UnregisterSubscriber(ch)
+}
+
+// DismissModuleAlert implements the modules.AlertDismisser interface for the
+// consensusset.
+func (c *ConsensusSet) DismissModuleAlert(module string, id modules.AlertID) error {
+	return c.staticAlerter.DismissModuleAlert(module, id)
+}
+
+// SnoozeModuleAlert implements the modules.AlertDismisser interface for the
+// consensusset.
+func (c *ConsensusSet) SnoozeModuleAlert(module string, id modules.AlertID, d time.Duration) error {
+	return c.staticAlerter.SnoozeModuleAlert(module, id, d)
 }
@@ -87,12 +87,13 @@ type ConsensusSet struct {
 	blockValidator  blockValidator
 
 	// Utilities
-	db         *persist.BoltDatabase
-	staticDeps modules.Dependencies
-	log        *persist.Logger
-	mu         demotemutex.DemoteMutex
-	persistDir string
-	tg         threadgroup.ThreadGroup
+	db            *persist.BoltDatabase
+	staticAlerter *modules.GenericAlerter
+	staticDeps    modules.Dependencies
+	log           *persist.Logger
+	mu            demotemutex.DemoteMutex
+	persistDir    string
+	tg            threadgroup.ThreadGroup
 }
 
 // consensusSetBlockingStartup handles the blocking portion of NewCustomConsensusSet.
@@ -119,8 +120,9 @@ func consensusSetBlockingStartup(gateway modules.Gateway, persistDir string, dep
 		blockRuleHelper: stdBlockRuleHelper{},
 		blockValidator:  NewBlockValidator(),
 
-		staticDeps: deps,
-		persistDir: persistDir,
+		staticAlerter: modules.NewAlerter("consensus"),
+		staticDeps:    deps,
+		persistDir:    persistDir,
 	}
 	// Create the diffs for the genesis transaction outputs
 	for _, transaction := range types.GenesisBlock.Transactions {
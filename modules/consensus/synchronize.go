@@ -1,6 +1,7 @@
 package consensus
 
 import (
+	"fmt"
 	"net"
 	"sync"
 	"time"
@@ -562,7 +563,14 @@ func (cs *ConsensusSet) managedInitialBlockchainDownload() error {
 	deadline := time.Now().Add(minIBDWaitTime)
 	numOutboundSynced := 0
 	numOutboundNotSynced := 0
+
+	// Let the user know that the download is in progress. The alert is
+	// updated with the current height on every iteration.
+	defer cs.staticAlerter.UnregisterAlert(modules.AlertIDConsensusInitialSync)
 	for {
+		msg := fmt.Sprintf("%s, current height %v", AlertMSGConsensusInitialSync, cs.Height())
+		cs.staticAlerter.RegisterAlert(modules.AlertIDConsensusInitialSync, msg, "", modules.SeverityInfo)
+
 		numOutboundSynced = 0
 		numOutboundNotSynced = 0
 		for _, p := range cs.gateway.Peers() {
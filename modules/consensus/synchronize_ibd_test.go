@@ -541,3 +541,54 @@ func TestGenesisBlockSync(t *testing.T) {
 		t.Error("disconnection occurred!")
 	}
 }
+
+// TestInitialBlockchainDownloadAlert checks that an informational alert is
+// registered while the initial blockchain download is in progress and that it
+// is unregistered once the download stops.
+func TestInitialBlockchainDownloadAlert(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	cst, err := blankConsensusSetTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without any peers the download never completes.
+	doneChan := make(chan error)
+	go func() {
+		doneChan <- cst.cs.managedInitialBlockchainDownload()
+	}()
+
+	// The alert should be registered.
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		_, _, _, info := cst.cs.Alerts()
+		if len(info) != 1 {
+			return fmt.Errorf("expected 1 info alert, got %v", len(info))
+		}
+		expected := fmt.Sprintf("%s, current height %v", AlertMSGConsensusInitialSync, 0)
+		if info[0].Msg != expected {
+			return fmt.Errorf("unexpected msg '%v'", info[0].Msg)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shutting down stops the download, which unregisters the alert.
+	if err := cst.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-doneChan:
+	case <-time.After(time.Minute):
+		t.Fatal("initialBlockchainDownload never returned")
+	}
+	crit, errs, warn, info := cst.cs.Alerts()
+	if len(crit)+len(errs)+len(warn)+len(info) != 0 {
+		t.Fatal("alert wasn't unregistered")
+	}
+}
@@ -1,8 +1,36 @@
 package gateway
 
-import "go.sia.tech/siad/modules"
+import (
+	"time"
+
+	"go.sia.tech/siad/modules"
+)
 
 // Alerts implements the modules.Alerter interface for the gateway.
 func (g *Gateway) Alerts() (crit, err, warn, info []modules.Alert) {
 	return g.staticAlerter.Alerts()
 }
+
+// RegisterSubscriber implements the modules.AlertSubscriber interface for the
+// gateway.
+func (g *Gateway) RegisterSubscriber(ch chan<- modules.AlertEvent) {
+	g.staticAlerter.RegisterSubscriber(ch)
+}
+
+// UnregisterSubscriber implements the modules.AlertSubscriber interface for
+// the gateway.
+func (g *Gateway) UnregisterSubscriber(ch chan<- modules.AlertEvent) {
+	g.staticAlerter.UnregisterSubscriber(ch)
+}
+
+// DismissModuleAlert implements the modules.AlertDismisser interface for the
+// gateway.
+func (g *Gateway) DismissModuleAlert(module string, id modules.AlertID) error {
+	return g.staticAlerter.DismissModuleAlert(module, id)
+}
+
+// SnoozeModuleAlert implements the modules.AlertDismisser interface for the
+// gateway.
+func (g *Gateway) SnoozeModuleAlert(module string, id modules.AlertID, d time.Duration) error {
+	return g.staticAlerter.SnoozeModuleAlert(module, id, d)
+}
@@ -0,0 +1,125 @@
+package gateway
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// connectivityMonitor keeps track of when the gateway last had an outbound
+// peer and when the consensus height last changed. It is used to register
+// alerts when the gateway loses its outbound peers or stops receiving blocks.
+type connectivityMonitor struct {
+	// heightFunc returns the current consensus height. It is nil until the
+	// gateway was told about the consensus set.
+	heightFunc func() types.BlockHeight
+
+	lastHeight       types.BlockHeight
+	lastHeightChange time.Time
+	lastOutboundPeer time.Time
+	mu               sync.Mutex
+}
+
+// newConnectivityMonitor creates a new connectivity monitor which considers
+// the gateway to be connected at the given time.
+func newConnectivityMonitor(now time.Time) *connectivityMonitor {
+	return &connectivityMonitor{
+		lastHeightChange: now,
+		lastOutboundPeer: now,
+	}
+}
+
+// MonitorConsensus makes the gateway register an alert if the height of the
+// consensus set doesn't advance for a while even though the gateway is
+// connected to peers.
+func (g *Gateway) MonitorConsensus(cs modules.ConsensusSet) {
+	g.staticConnectivity.managedSetHeightFunc(cs.Height, time.Now())
+}
+
+// managedSetHeightFunc sets the function which is used to fetch the consensus
+// height and restarts the clock for the sync stall alert.
+func (cm *connectivityMonitor) managedSetHeightFunc(heightFunc func() types.BlockHeight, now time.Time) {
+	height := heightFunc()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.heightFunc = heightFunc
+	cm.lastHeight = height
+	cm.lastHeightChange = now
+}
+
+// managedCheckConnectivity registers or unregisters the alerts for missing
+// outbound peers and a stalled consensus set based on the state of the gateway
+// at the given time.
+func (g *Gateway) managedCheckConnectivity(now time.Time) {
+	g.mu.RLock()
+	numPeers := len(g.peers)
+	var numOutbound int
+	for _, p := range g.peers {
+		if !p.Inbound {
+			numOutbound++
+		}
+	}
+	g.mu.RUnlock()
+
+	cm := g.staticConnectivity
+	cm.mu.Lock()
+	heightFunc := cm.heightFunc
+	cm.mu.Unlock()
+	var height types.BlockHeight
+	if heightFunc != nil {
+		height = heightFunc()
+	}
+
+	cm.mu.Lock()
+	if numOutbound > 0 {
+		cm.lastOutboundPeer = now
+	}
+	lastOutboundPeer := cm.lastOutboundPeer
+	// The height is only expected to advance while there are peers to
+	// receive blocks from.
+	if height != cm.lastHeight || numPeers == 0 {
+		cm.lastHeight = height
+		cm.lastHeightChange = now
+	}
+	lastHeightChange := cm.lastHeightChange
+	cm.mu.Unlock()
+
+	if now.Sub(lastOutboundPeer) >= noOutboundPeersAlertThreshold {
+		cause := fmt.Sprintf("no outbound peers since %v", lastOutboundPeer.Format(time.RFC3339))
+		g.staticAlerter.RegisterAlert(modules.AlertIDGatewayNoOutboundPeers, AlertMSGGatewayNoOutboundPeers, cause, modules.SeverityWarning)
+	} else {
+		g.staticAlerter.UnregisterAlert(modules.AlertIDGatewayNoOutboundPeers)
+	}
+	if heightFunc != nil && now.Sub(lastHeightChange) >= syncStallAlertThreshold {
+		cause := fmt.Sprintf("consensus height %v unchanged since %v", height, lastHeightChange.Format(time.RFC3339))
+		g.staticAlerter.RegisterAlert(modules.AlertIDGatewaySyncStalled, AlertMSGGatewaySyncStalled, cause, modules.SeverityError)
+	} else {
+		g.staticAlerter.UnregisterAlert(modules.AlertIDGatewaySyncStalled)
+	}
+}
+
+// threadedMonitorConnectivity periodically checks the gateway's outbound peers
+// and the progress of the consensus set. During testing the alerts are only
+// registered if enabled by a dependency, since most test nodes don't have
+// outbound peers and don't mine blocks regularly.
+func (g *Gateway) threadedMonitorConnectivity() {
+	if build.Release == "testing" && !g.staticDeps.Disrupt("EnableGatewayConnectivityAlerts") {
+		return
+	}
+	if err := g.threads.Add(); err != nil {
+		return
+	}
+	defer g.threads.Done()
+	for {
+		select {
+		case <-g.threads.StopChan():
+			return
+		case <-time.After(connectivityCheckFrequency):
+		}
+		g.managedCheckConnectivity(time.Now())
+	}
+}
@@ -0,0 +1,115 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestGatewayConnectivityAlerts tests that the gateway registers and
+// unregisters the alerts about missing outbound peers and a stalled consensus
+// set.
+func TestGatewayConnectivityAlerts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	g := newNamedTestingGateway(t, "1")
+	defer func() {
+		if err := g.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	g2 := newNamedTestingGateway(t, "2")
+
+	// alertSeverity returns the severity of the alert with the given id or
+	// SeverityUnknown if it isn't registered.
+	alertSeverity := func(id modules.AlertID) modules.AlertSeverity {
+		crit, err, warn, info := g.Alerts()
+		for _, alerts := range [][]modules.Alert{crit, err, warn, info} {
+			for _, alert := range alerts {
+				if alert.ID == id {
+					return alert.Severity
+				}
+			}
+		}
+		return modules.SeverityUnknown
+	}
+
+	// a new gateway doesn't have any alerts
+	start := time.Now()
+	g.managedCheckConnectivity(start)
+	if s := alertSeverity(modules.AlertIDGatewayNoOutboundPeers); s != modules.SeverityUnknown {
+		t.Fatal("unexpected alert", s)
+	}
+
+	// without outbound peers the warning is registered after the threshold
+	now := start.Add(noOutboundPeersAlertThreshold)
+	g.managedCheckConnectivity(now)
+	if s := alertSeverity(modules.AlertIDGatewayNoOutboundPeers); s != modules.SeverityWarning {
+		t.Fatal("expected a warning", s)
+	}
+
+	// the alert clears once the gateway has an outbound peer
+	if err := connectToNode(g, g2, false); err != nil {
+		t.Fatal(err)
+	}
+	g.managedCheckConnectivity(now)
+	if s := alertSeverity(modules.AlertIDGatewayNoOutboundPeers); s != modules.SeverityUnknown {
+		t.Fatal("alert should be cleared", s)
+	}
+
+	// without a consensus set there is no sync stall alert
+	now = now.Add(syncStallAlertThreshold)
+	g.managedCheckConnectivity(now)
+	if s := alertSeverity(modules.AlertIDGatewaySyncStalled); s != modules.SeverityUnknown {
+		t.Fatal("unexpected alert", s)
+	}
+
+	// a height that doesn't advance while there are peers causes an error
+	height := types.BlockHeight(10)
+	g.staticConnectivity.managedSetHeightFunc(func() types.BlockHeight { return height }, now)
+	g.managedCheckConnectivity(now.Add(syncStallAlertThreshold / 2))
+	if s := alertSeverity(modules.AlertIDGatewaySyncStalled); s != modules.SeverityUnknown {
+		t.Fatal("unexpected alert", s)
+	}
+	now = now.Add(syncStallAlertThreshold)
+	g.managedCheckConnectivity(now)
+	if s := alertSeverity(modules.AlertIDGatewaySyncStalled); s != modules.SeverityError {
+		t.Fatal("expected an error", s)
+	}
+
+	// the alert clears once the height advances
+	height++
+	g.managedCheckConnectivity(now)
+	if s := alertSeverity(modules.AlertIDGatewaySyncStalled); s != modules.SeverityUnknown {
+		t.Fatal("alert should be cleared", s)
+	}
+
+	// without peers the height isn't expected to advance
+	addr := g2.Address()
+	if err := g2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	err := build.Retry(100, 100*time.Millisecond, func() error {
+		_ = g.Disconnect(addr)
+		if len(g.Peers()) != 0 {
+			return errors.New("gateway still has peers")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * syncStallAlertThreshold)
+	g.managedCheckConnectivity(now)
+	if s := alertSeverity(modules.AlertIDGatewaySyncStalled); s != modules.SeverityUnknown {
+		t.Fatal("unexpected alert", s)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
 )
 
 // Constants related to the gateway's alerts.
@@ -12,6 +13,14 @@ var (
 	// AlertMSGGatewayOffline indicates that the last time the gateway checked
 	// the network status it was offline.
 	AlertMSGGatewayOffline = "not connected to the internet"
+
+	// AlertMSGGatewayNoOutboundPeers indicates that the gateway hasn't had
+	// any outbound peers for a while.
+	AlertMSGGatewayNoOutboundPeers = "no outbound peer connections"
+
+	// AlertMSGGatewaySyncStalled indicates that the consensus height hasn't
+	// advanced for a while even though the gateway is connected to peers.
+	AlertMSGGatewaySyncStalled = "no new blocks received from peers"
 )
 
 const (
@@ -43,6 +52,16 @@ const (
 )
 
 var (
+	// connectivityCheckFrequency defines how often the gateway checks its
+	// outbound peers and the progress of the consensus set in
+	// threadedMonitorConnectivity.
+	connectivityCheckFrequency = build.Select(build.Var{
+		Standard: time.Minute,
+		Testnet:  time.Minute,
+		Dev:      10 * time.Second,
+		Testing:  500 * time.Millisecond,
+	}).(time.Duration)
+
 	// fastNodePurgeDelay defines the amount of time that is waited between each
 	// iteration of the purge loop when the gateway has enough nodes to be
 	// needing to purge quickly.
@@ -89,6 +108,25 @@ var (
 		Testing:  500 * time.Millisecond,
 	}).(time.Duration)
 
+	// noOutboundPeersAlertThreshold is the amount of time the gateway needs
+	// to be without outbound peers before it registers an alert.
+	noOutboundPeersAlertThreshold = build.Select(build.Var{
+		Standard: 10 * time.Minute,
+		Testnet:  10 * time.Minute,
+		Dev:      2 * time.Minute,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// offlineAlertTTL is the time after which the offline alert expires if
+	// it isn't registered again by a call to 'Online'. It is a multiple of
+	// onlineCheckFrequency to tolerate a few missed checks.
+	offlineAlertTTL = build.Select(build.Var{
+		Standard: 5 * time.Minute,
+		Testnet:  5 * time.Minute,
+		Dev:      time.Minute,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
 	// onlineCheckFrequency defines how often the gateway calls 'Online' in
 	// threadedOnlineCheck.
 	onlineCheckFrequency = build.Select(build.Var{
@@ -125,6 +163,16 @@ var (
 		Dev:      int(40),
 		Testing:  int(20),
 	}).(int)
+
+	// syncStallAlertThreshold is the amount of time the consensus height
+	// needs to be unchanged while the gateway is connected to peers before it
+	// registers an alert. It is roughly 3 times the expected block time.
+	syncStallAlertThreshold = build.Select(build.Var{
+		Standard: 3 * time.Duration(types.BlockFrequency) * time.Second,
+		Testnet:  3 * time.Duration(types.BlockFrequency) * time.Second,
+		Dev:      3 * time.Duration(types.BlockFrequency) * time.Second,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
 )
 
 var (
@@ -160,6 +160,10 @@ type Gateway struct {
 	staticAlerter *modules.GenericAlerter
 	staticDeps    modules.Dependencies
 
+	// staticConnectivity tracks the outbound peers of the gateway and the
+	// progress of the consensus set for the connectivity alerts.
+	staticConnectivity *connectivityMonitor
+
 	// Unique ID
 	staticID gatewayID
 
@@ -384,6 +388,8 @@ func NewCustomGateway(addr string, bootstrap bool, useUPNP bool, persistDir stri
 		staticAlerter: modules.NewAlerter("gateway"),
 		staticDeps:    deps,
 		staticUseUPNP: useUPNP,
+
+		staticConnectivity: newConnectivityMonitor(time.Now()),
 	}
 
 	// Set Unique GatewayID
@@ -536,6 +542,9 @@ func NewCustomGateway(addr string, bootstrap bool, useUPNP bool, persistDir stri
 	// Spawn thread to periodically check if the gateway is online.
 	go g.threadedOnlineCheck()
 
+	// Spawn thread to monitor the outbound peers and the consensus height.
+	go g.threadedMonitorConnectivity()
+
 	return g, nil
 }
 
@@ -606,7 +606,7 @@ func (g *Gateway) Online() (online bool) {
 		if online {
 			g.staticAlerter.UnregisterAlert(modules.AlertIDGatewayOffline)
 		} else {
-			g.staticAlerter.RegisterAlert(modules.AlertIDGatewayOffline, AlertMSGGatewayOffline, "", modules.SeverityWarning)
+			g.staticAlerter.RegisterAlertWithTTL(modules.AlertIDGatewayOffline, AlertMSGGatewayOffline, "", modules.SeverityWarning, offlineAlertTTL)
 		}
 	}()
 	disableAutoOnline := g.staticDeps.Disrupt("DisableGatewayAutoOnline")
@@ -290,6 +290,11 @@ type (
 
 		CustomRegistryPath string `json:"customregistrypath"`
 		RegistrySize       uint64 `json:"registrysize"`
+
+		ScrubEnabled           bool          `json:"scrubenabled"`
+		ScrubInterval          time.Duration `json:"scrubinterval"`
+		ScrubMarkCorrupt       bool          `json:"scrubmarkcorrupt"`
+		ScrubMaxBytesPerSecond uint64        `json:"scrubmaxbytespersecond"`
 	}
 
 	// HostNetworkMetrics reports the quantity of each type of RPC call that
@@ -458,6 +463,13 @@ type (
 		// of all at once.
 		MarkSectorsForRemoval(sectorRoots []crypto.Hash) error
 
+		// MoveSectors will move sectors from the source storage folder of the
+		// host into the destination storage folder until the source folder is
+		// empty, maxBytes of sectors were moved or the destination folder is
+		// full. A maxBytes of 0 moves all sectors. The number of moved sectors
+		// is returned.
+		MoveSectors(source, dest uint16, maxBytes uint64) (uint64, error)
+
 		// RemoveStorageFolder will remove a storage folder from the host. All
 		// storage on the folder will be moved to other storage folders, meaning
 		// that no data will be lost. If the host is unable to save data, an
@@ -481,6 +493,17 @@ type (
 		// and the resize operation completed, meaning that data will be lost.
 		ResizeStorageFolder(index uint16, newSize uint64, force bool) error
 
+		// SetStorageFolderReadOnly will mark a storage folder of the host as
+		// read-only or writable again. No new sectors are placed in read-only
+		// storage folders, but the sectors they store can still be read and
+		// deleted.
+		SetStorageFolderReadOnly(index uint16, readOnly bool) error
+
+		// CancelStorageFolderResize will cancel the ongoing resize of a
+		// storage folder on the host. The storage folder keeps its old size
+		// and the resize can be issued again later.
+		CancelStorageFolderResize(index uint16) error
+
 		// SetInternalSettings sets the hosting parameters of the host.
 		SetInternalSettings(HostInternalSettings) error
 
@@ -514,6 +537,17 @@ func (his HostInternalSettings) MaxSectorAccessPrice() types.Currency {
 	return his.MinDownloadBandwidthPrice.Mul64(MaxSectorAccessPriceVsBandwidth)
 }
 
+// ScrubSettings returns the settings of the storage manager's background scrub
+// that are part of the host's internal settings.
+func (his HostInternalSettings) ScrubSettings() StorageScrubSettings {
+	return StorageScrubSettings{
+		Enabled:           his.ScrubEnabled,
+		Interval:          his.ScrubInterval,
+		MarkCorrupt:       his.ScrubMarkCorrupt,
+		MaxBytesPerSecond: his.ScrubMaxBytesPerSecond,
+	}
+}
+
 // DefaultHostExternalSettings returns HostExternalSettings with certain default
 // fields set. NetAddress, RemainingStorage, TotalStorage, UnlockHash, RevisionNumber and SiaMuxPort are not set.
 func DefaultHostExternalSettings() HostExternalSettings {
@@ -1,6 +1,12 @@
 package host
 
-import "go.sia.tech/siad/modules"
+import (
+	"fmt"
+	"time"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
 
 // Alerts implements the modules.Alerter interface for the host.
 func (h *Host) Alerts() (crit, err, warn, info []modules.Alert) {
@@ -23,3 +29,138 @@ func (h *Host) tryUnregisterInsufficientCollateralBudgetAlert() {
 		h.staticAlerter.UnregisterAlert(modules.AlertIDHostInsufficientCollateral)
 	}
 }
+
+// collateralBudgetLockedCause returns the cause of the collateral budget alert
+// and whether the alert should be registered, which is the case if more than
+// the threshold fraction of the budget is locked.
+func collateralBudgetLockedCause(locked, budget types.Currency, threshold float64) (string, bool) {
+	if budget.IsZero() || locked.Cmp(budget.MulFloat(threshold)) <= 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%v of the collateral budget of %v are locked", locked.HumanString(), budget.HumanString()), true
+}
+
+// lowStorageCause returns the cause of the low storage alert and whether the
+// alert should be registered, which is the case if less than the threshold
+// fraction of the total capacity of the storage folders is remaining.
+func lowStorageCause(folders []modules.StorageFolderMetadata, threshold float64) (string, bool) {
+	var capacity, remaining uint64
+	for _, sf := range folders {
+		capacity += sf.Capacity
+		remaining += sf.CapacityRemaining
+	}
+	if capacity == 0 || float64(remaining) >= float64(capacity)*threshold {
+		return "", false
+	}
+	return fmt.Sprintf("%v of %v remaining across %v storage folders", modules.FilesizeUnits(remaining), modules.FilesizeUnits(capacity), len(folders)), true
+}
+
+// walletCollateralCause returns the cause of the wallet collateral alert and
+// whether the alert should be registered, which is the case if the balance
+// can't cover the max collateral of a contract.
+func walletCollateralCause(balance, maxCollateral types.Currency) (string, bool) {
+	if balance.Cmp(maxCollateral) >= 0 {
+		return "", false
+	}
+	return fmt.Sprintf("wallet balance of %v is below the max collateral of %v", balance.HumanString(), maxCollateral.HumanString()), true
+}
+
+// managedCheckAlerts registers or unregisters the host's storage and
+// collateral alerts depending on the current state of the host.
+func (h *Host) managedCheckAlerts() {
+	// Check the remaining storage.
+	if cause, low := lowStorageCause(h.StorageFolders(), lowStorageAlertThreshold); low {
+		h.staticAlerter.RegisterAlert(modules.AlertIDHostLowStorage, AlertMSGHostLowStorage, cause, modules.SeverityWarning)
+	} else {
+		h.staticAlerter.UnregisterAlert(modules.AlertIDHostLowStorage)
+	}
+
+	h.mu.RLock()
+	accepting := h.settings.AcceptingContracts
+	budget := h.settings.CollateralBudget
+	maxCollateral := h.settings.MaxCollateral
+	locked := h.financialMetrics.LockedStorageCollateral
+	h.mu.RUnlock()
+
+	// The collateral alerts are only relevant for hosts that accept
+	// contracts.
+	if !accepting {
+		h.staticAlerter.UnregisterAlert(modules.AlertIDHostCollateralBudgetLocked)
+		h.staticAlerter.UnregisterAlert(modules.AlertIDHostWalletInsufficientCollateral)
+		return
+	}
+
+	// Check the locked collateral.
+	if cause, register := collateralBudgetLockedCause(locked, budget, collateralBudgetLockedAlertThreshold); register {
+		h.staticAlerter.RegisterAlert(modules.AlertIDHostCollateralBudgetLocked, AlertMSGHostCollateralBudgetLocked, cause, modules.SeverityWarning)
+	} else {
+		h.staticAlerter.UnregisterAlert(modules.AlertIDHostCollateralBudgetLocked)
+	}
+
+	// Check the wallet balance, a locked wallet is a problem of its own and
+	// doesn't say anything about the balance.
+	if unlocked, err := h.wallet.Unlocked(); err != nil || !unlocked {
+		return
+	}
+	balance, _, _, err := h.wallet.ConfirmedBalance()
+	if err != nil {
+		h.log.Debugln("unable to check the wallet balance for the collateral alert:", err)
+		return
+	}
+	if cause, register := walletCollateralCause(balance, maxCollateral); register {
+		h.staticAlerter.RegisterAlert(modules.AlertIDHostWalletInsufficientCollateral, AlertMSGHostWalletInsufficientCollateral, cause, modules.SeverityWarning)
+	} else {
+		h.staticAlerter.UnregisterAlert(modules.AlertIDHostWalletInsufficientCollateral)
+	}
+}
+
+// threadedCheckAlerts periodically checks the conditions of the host's
+// storage and collateral alerts.
+//
+// Note: threadgroup counter must be inside for loop. If not, calling 'Flush'
+// on the threadgroup would deadlock.
+func (h *Host) threadedCheckAlerts() {
+	for {
+		func() {
+			if err := h.tg.Add(); err != nil {
+				return
+			}
+			defer h.tg.Done()
+			h.managedCheckAlerts()
+		}()
+
+		// Block until next cycle.
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(alertCheckFrequency):
+			continue
+		}
+	}
+}
+
+// RegisterSubscriber implements the modules.AlertSubscriber interface for the
+// host. The subscriber receives the alert events of the host and its storage
+// manager.
+func (h *Host) RegisterSubscriber(ch chan<- modules.AlertEvent) {
+	modules.AlertAggregator{h.staticAlerter, h.StorageManager}.RegisterSubscriber(ch)
+}
+
+// UnregisterSubscriber implements the modules.AlertSubscriber interface for
+// the host.
+func (h *Host) UnregisterSubscriber(ch chan<- modules.AlertEvent) {
+	modules.AlertAggregator{h.staticAlerter, h.StorageManager}.UnregisterSubscriber(ch)
+}
+
+// DismissModuleAlert implements the modules.AlertDismisser interface for the
+// host. The alerts of the host's
+// storage manager can be dismissed as well.
+func (h *Host) DismissModuleAlert(module string, id modules.AlertID) error {
+	return modules.AlertAggregator{h.staticAlerter, h.StorageManager}.DismissModuleAlert(module, id)
+}
+
+// SnoozeModuleAlert implements the modules.AlertDismisser interface for the
+// host.
+func (h *Host) SnoozeModuleAlert(module string, id modules.AlertID, d time.Duration) error {
+	return modules.AlertAggregator{h.staticAlerter, h.StorageManager}.SnoozeModuleAlert(module, id, d)
+}
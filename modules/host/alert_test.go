@@ -0,0 +1,216 @@
+package host
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestLowStorageCause is a unit test for lowStorageCause.
+func TestLowStorageCause(t *testing.T) {
+	t.Parallel()
+
+	folders := []modules.StorageFolderMetadata{
+		{Capacity: 100, CapacityRemaining: 5},
+		{Capacity: 100, CapacityRemaining: 20},
+	}
+	if _, low := lowStorageCause(nil, 0.1); low {
+		t.Fatal("host without storage shouldn't have low storage")
+	}
+	if _, low := lowStorageCause(folders, 0.1); low {
+		t.Fatal("12.5% remaining isn't low")
+	}
+	cause, low := lowStorageCause(folders, 0.2)
+	if !low {
+		t.Fatal("12.5% remaining should be low")
+	}
+	if !strings.Contains(cause, "2 storage folders") {
+		t.Fatal("unexpected cause", cause)
+	}
+}
+
+// TestCollateralBudgetLockedCause is a unit test for
+// collateralBudgetLockedCause.
+func TestCollateralBudgetLockedCause(t *testing.T) {
+	t.Parallel()
+
+	budget := types.SiacoinPrecision.Mul64(100)
+	if _, register := collateralBudgetLockedCause(types.SiacoinPrecision, types.ZeroCurrency, 0.9); register {
+		t.Fatal("alert shouldn't be registered without a budget")
+	}
+	if _, register := collateralBudgetLockedCause(types.SiacoinPrecision.Mul64(90), budget, 0.9); register {
+		t.Fatal("alert shouldn't be registered at the threshold")
+	}
+	cause, register := collateralBudgetLockedCause(types.SiacoinPrecision.Mul64(91), budget, 0.9)
+	if !register {
+		t.Fatal("alert should be registered above the threshold")
+	}
+	if !strings.Contains(cause, "91 SC") || !strings.Contains(cause, "100 SC") {
+		t.Fatal("unexpected cause", cause)
+	}
+}
+
+// TestWalletCollateralCause is a unit test for walletCollateralCause.
+func TestWalletCollateralCause(t *testing.T) {
+	t.Parallel()
+
+	maxCollateral := types.SiacoinPrecision.Mul64(10)
+	if _, register := walletCollateralCause(maxCollateral, maxCollateral); register {
+		t.Fatal("alert shouldn't be registered if the balance covers the collateral")
+	}
+	cause, register := walletCollateralCause(types.SiacoinPrecision, maxCollateral)
+	if !register {
+		t.Fatal("alert should be registered if the balance doesn't cover the collateral")
+	}
+	if !strings.Contains(cause, "1 SC") || !strings.Contains(cause, "10 SC") {
+		t.Fatal("unexpected cause", cause)
+	}
+}
+
+// TestHostAlerts verifies that the host registers and unregisters its storage
+// and collateral alerts.
+func TestHostAlerts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create a host with a funded wallet and a single small storage folder
+	ht, err := blankHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ht.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = ht.initWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := types.BlockHeight(0); i <= types.MaturityDelay; i++ {
+		_, err = ht.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := ht.host
+	storageFolder := filepath.Join(ht.persistDir, "storage")
+	err = os.Mkdir(storageFolder, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numSectors := uint64(64)
+	err = h.AddStorageFolder(storageFolder, modules.SectorSize*numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// hasAlert returns the cause of the alert with the given id if it is
+	// registered.
+	hasAlert := func(id modules.AlertID) (string, bool) {
+		_, _, warns, _ := h.Alerts()
+		for _, alert := range warns {
+			if alert.ID == id {
+				return alert.Cause, true
+			}
+		}
+		return "", false
+	}
+
+	// a host that doesn't accept contracts only checks its storage
+	h.managedCheckAlerts()
+	for _, id := range []modules.AlertID{modules.AlertIDHostLowStorage, modules.AlertIDHostCollateralBudgetLocked, modules.AlertIDHostWalletInsufficientCollateral} {
+		if _, ok := hasAlert(id); ok {
+			t.Fatal("unexpected alert", id)
+		}
+	}
+
+	// fill the storage folder until less than 10% are remaining
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var addErr error
+	for i := uint64(0); i < numSectors-4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := fastrand.Bytes(int(modules.SectorSize))
+			err := h.AddSector(crypto.MerkleRoot(data), data)
+			mu.Lock()
+			addErr = errors.Compose(addErr, err)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if addErr != nil {
+		t.Fatal(addErr)
+	}
+	h.managedCheckAlerts()
+	if cause, ok := hasAlert(modules.AlertIDHostLowStorage); !ok || !strings.Contains(cause, "1 storage folders") {
+		t.Fatal("expected low storage alert", cause)
+	}
+
+	// accept contracts with a max collateral that exceeds the wallet balance
+	balance, _, _, err := ht.wallet.ConfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	is := h.InternalSettings()
+	is.AcceptingContracts = true
+	is.MaxCollateral = balance.Mul64(2)
+	is.CollateralBudget = balance.Mul64(4)
+	err = h.SetInternalSettings(is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.managedCheckAlerts()
+	if _, ok := hasAlert(modules.AlertIDHostWalletInsufficientCollateral); !ok {
+		t.Fatal("expected wallet collateral alert")
+	}
+	if _, ok := hasAlert(modules.AlertIDHostCollateralBudgetLocked); ok {
+		t.Fatal("unexpected collateral budget alert")
+	}
+
+	// lock most of the collateral budget
+	h.mu.Lock()
+	h.financialMetrics.LockedStorageCollateral = is.CollateralBudget.MulFloat(0.95)
+	h.mu.Unlock()
+	h.managedCheckAlerts()
+	if _, ok := hasAlert(modules.AlertIDHostCollateralBudgetLocked); !ok {
+		t.Fatal("expected collateral budget alert")
+	}
+
+	// resolve the conditions, the alerts should be cleared
+	h.mu.Lock()
+	h.financialMetrics.LockedStorageCollateral = types.ZeroCurrency
+	h.mu.Unlock()
+	is.MaxCollateral = types.SiacoinPrecision
+	err = h.SetInternalSettings(is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageFolder2 := filepath.Join(ht.persistDir, "storage2")
+	err = os.Mkdir(storageFolder2, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.AddStorageFolder(storageFolder2, modules.SectorSize*numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.managedCheckAlerts()
+	for _, id := range []modules.AlertID{modules.AlertIDHostLowStorage, modules.AlertIDHostCollateralBudgetLocked, modules.AlertIDHostWalletInsufficientCollateral} {
+		if _, ok := hasAlert(id); ok {
+			t.Fatal("alert should be cleared", id)
+		}
+	}
+}
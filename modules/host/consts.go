@@ -12,6 +12,17 @@ const (
 	// AlertMSGHostInsufficientCollateral indicates that a host has insufficient
 	// collateral budget remaining
 	AlertMSGHostInsufficientCollateral = "host has insufficient collateral budget"
+
+	// AlertMSGHostLowStorage indicates that the host is running out of storage
+	AlertMSGHostLowStorage = "host is running out of storage"
+
+	// AlertMSGHostCollateralBudgetLocked indicates that most of the host's
+	// collateral budget is locked in contracts
+	AlertMSGHostCollateralBudgetLocked = "host's collateral budget is almost fully locked"
+
+	// AlertMSGHostWalletInsufficientCollateral indicates that the host's wallet
+	// can't cover the collateral of a contract with the maximum collateral
+	AlertMSGHostWalletInsufficientCollateral = "host's wallet balance can't cover the max collateral of a contract"
 )
 
 const (
@@ -37,6 +48,20 @@ const (
 )
 
 var (
+	// alertCheckFrequency defines how often the host checks the conditions
+	// of its storage and collateral alerts.
+	alertCheckFrequency = build.Select(build.Var{
+		Standard: time.Minute * 10,
+		Testnet:  time.Minute * 10,
+		Dev:      time.Minute * 1,
+		Testing:  time.Second * 3,
+	}).(time.Duration)
+
+	// collateralBudgetLockedAlertThreshold is the fraction of the collateral
+	// budget that needs to be locked in contracts for the host to register
+	// the collateral budget alert.
+	collateralBudgetLockedAlertThreshold = 0.9
+
 	// connectablityCheckFirstWait defines how often the host's connectability
 	// check is run.
 	connectabilityCheckFirstWait = build.Select(build.Var{
@@ -77,6 +102,25 @@ var (
 	// prevent the host from having too much money at risk.
 	defaultMaxEphemeralAccountRisk = types.SiacoinPrecision.Mul64(5)
 
+	// defaultScrubInterval is the amount of time between two scrubs of the
+	// same storage folder.
+	defaultScrubInterval = build.Select(build.Var{
+		Standard: time.Hour * 24 * 30,
+		Testnet:  time.Hour * 24 * 30,
+		Dev:      time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// defaultScrubMaxBytesPerSecond is the rate at which the scrub reads
+	// sectors by default. At 4 MiB/s a terabyte of sectors is scrubbed in
+	// about three days.
+	defaultScrubMaxBytesPerSecond = build.Select(build.Var{
+		Standard: uint64(1 << 22),
+		Testnet:  uint64(1 << 22),
+		Dev:      uint64(1 << 22),
+		Testing:  uint64(0),
+	}).(uint64)
+
 	// logAllLimit is the number of errors of each type that the host will log
 	// before switching to probabilistic logging. If there are not many errors,
 	// it is reasonable that all errors get logged. If there are lots of
@@ -98,6 +142,11 @@ var (
 		Testing:  uint64(500),
 	}).(uint64)
 
+	// lowStorageAlertThreshold is the fraction of the total capacity of the
+	// host's storage folders that needs to be remaining for the host to not
+	// register the low storage alert.
+	lowStorageAlertThreshold = 0.1
+
 	// obligationLockTimeout defines how long a thread will wait to get a lock
 	// on a storage obligation before timing out and reporting an error to the
 	// renter.
@@ -12,6 +12,30 @@ const (
 	// AlertMSGHostDiskTrouble indicates that one or multiple of a host's disks
 	// are encountering problems
 	AlertMSGHostDiskTrouble = "disk problem detected"
+
+	// AlertMSGUnknownWALEntries indicates that the WAL contained entries
+	// written by a newer version which were partially skipped during recovery.
+	AlertMSGUnknownWALEntries = "unknown WAL entries were skipped during recovery"
+
+	// AlertMSGCorruptWALEntries indicates that the WAL contained corrupt
+	// entries which were skipped during recovery.
+	AlertMSGCorruptWALEntries = "corrupt WAL entries were skipped during recovery"
+
+	// AlertMSGSlowWALCommits indicates that committing the WAL took long on
+	// average recently, which is a sign of a failing disk.
+	AlertMSGSlowWALCommits = "WAL commits are slow, a disk might be failing"
+
+	// AlertMSGNoWritableStorageFolders indicates that all storage folders are
+	// read-only and new sectors can't be stored.
+	AlertMSGNoWritableStorageFolders = "all storage folders are read-only, no new sectors can be stored"
+
+	// AlertMSGCorruptSectors indicates that the background scrub found
+	// sectors whose data doesn't match their sector root.
+	AlertMSGCorruptSectors = "corrupt sectors were found by the integrity scrub"
+
+	// AlertMSGStorageFolderQuarantined indicates that a storage folder was
+	// quarantined because its disk returned too many errors.
+	AlertMSGStorageFolderQuarantined = "storage folder was quarantined because its disk is failing"
 )
 
 const (
@@ -23,6 +47,10 @@ const (
 	// metadata associated with a storage folder.
 	metadataFile = "siahostmetadata.dat"
 
+	// probeFile is the name of the file that is temporarily written into a
+	// quarantined storage folder to check whether its disk recovered.
+	probeFile = "siahostprobe.dat"
+
 	// sectorFile is the file that is placed inside of a storage folder to
 	// house all of the sectors associated with a storage folder.
 	sectorFile = "siahostdata.dat"
@@ -50,6 +78,15 @@ const (
 	// counter becomes greater than the max value of a uint16.
 	sectorOverflowFile = "sector_overflow.dat"
 
+	// sectorLocationSnapshotFile is the name of the file that holds the
+	// snapshot of the sector locations.
+	sectorLocationSnapshotFile = "sectorlocations.dat"
+
+	// sectorLocationSnapshotFileTmp is the name of the file that is used to
+	// write a new snapshot of the sector locations before it is atomically
+	// renamed to sectorLocationSnapshotFile.
+	sectorLocationSnapshotFileTmp = "sectorlocations.dat_temp"
+
 	// sectorRemovalFile is the path to the file used to store the sector removal
 	// queue.
 	sectorRemovalQueueFile = "sector_removal.dat"
@@ -79,6 +116,11 @@ const (
 	// which is a high granluarity relative the to the TiBs of storage that
 	// hosts are expected to provide.
 	storageFolderGranularity = 64
+
+	// maxSyncWindow is the longest amount of time that the sync loop waits
+	// for more changes to join a commit, and also the interval at which the
+	// sync loop commits while no changes arrive.
+	maxSyncWindow = 500 * time.Millisecond
 )
 
 var (
@@ -148,4 +190,97 @@ var (
 		Testnet:  time.Second * 60 * 5,
 		Testing:  time.Second * 8,
 	}).(time.Duration)
+
+	// folderOpAlertInterval specifies how often the alert of a long running
+	// storage folder operation is updated with the operation's progress.
+	folderOpAlertInterval = build.Select(build.Var{
+		Dev:      time.Second * 5,
+		Standard: time.Second * 10,
+		Testnet:  time.Second * 10,
+		Testing:  time.Millisecond * 100,
+	}).(time.Duration)
+
+	// scrubIdleInterval is the maximum amount of time the scrub waits before
+	// checking again whether a storage folder is due for a scrub.
+	scrubIdleInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: time.Minute * 10,
+		Testnet:  time.Minute * 10,
+		Testing:  time.Millisecond * 100,
+	}).(time.Duration)
+
+	// sectorLocationSnapshotInterval is the minimum amount of time between
+	// two snapshots of the sector locations that are saved by the sync loop.
+	// A snapshot is also saved at clean shutdown.
+	sectorLocationSnapshotInterval = build.Select(build.Var{
+		Dev:      time.Minute * 10,
+		Standard: time.Hour,
+		Testnet:  time.Hour,
+		Testing:  time.Second * 2,
+	}).(time.Duration)
+
+	// minSyncWindow is the shortest amount of time that the sync loop waits
+	// for more changes to join a commit after the first change arrived.
+	minSyncWindow = build.Select(build.Var{
+		Dev:      time.Millisecond * 20,
+		Standard: time.Millisecond * 20,
+		Testnet:  time.Millisecond * 20,
+		Testing:  time.Millisecond * 10,
+	}).(time.Duration)
+
+	// slowCommitThreshold is the average latency of the last
+	// slowCommitWindow WAL commits above which the contract manager registers
+	// an alert about slow commits.
+	slowCommitThreshold = build.Select(build.Var{
+		Dev:      time.Second * 3,
+		Standard: time.Second * 3,
+		Testnet:  time.Second * 3,
+		Testing:  time.Millisecond * 200,
+	}).(time.Duration)
+
+	// slowCommitWindow is the number of recent WAL commits whose average
+	// latency is compared to slowCommitThreshold.
+	slowCommitWindow = build.Select(build.Var{
+		Dev:      20,
+		Standard: 20,
+		Testnet:  20,
+		Testing:  4,
+	}).(int)
+
+	// folderMaxConsecutiveErrors is the number of consecutive failed reads and
+	// writes after which a storage folder is quarantined.
+	folderMaxConsecutiveErrors = build.Select(build.Var{
+		Dev:      uint64(10),
+		Standard: uint64(20),
+		Testnet:  uint64(20),
+		Testing:  uint64(5),
+	}).(uint64)
+
+	// folderErrorRateWindow is the number of reads and writes over which the
+	// error rate of a storage folder is measured.
+	folderErrorRateWindow = build.Select(build.Var{
+		Dev:      uint64(100),
+		Standard: uint64(1000),
+		Testnet:  uint64(1000),
+		Testing:  uint64(20),
+	}).(uint64)
+
+	// folderMaxErrorRate is the fraction of failed reads and writes within
+	// folderErrorRateWindow operations above which a storage folder is
+	// quarantined.
+	folderMaxErrorRate = build.Select(build.Var{
+		Dev:      0.2,
+		Standard: 0.2,
+		Testnet:  0.2,
+		Testing:  0.25,
+	}).(float64)
+
+	// folderProbeInterval is the amount of time between two probes of a
+	// quarantined storage folder that check whether its disk recovered.
+	folderProbeInterval = build.Select(build.Var{
+		Dev:      time.Second * 10,
+		Standard: time.Minute,
+		Testnet:  time.Minute,
+		Testing:  time.Millisecond * 100,
+	}).(time.Duration)
 )
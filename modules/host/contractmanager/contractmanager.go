@@ -26,9 +26,11 @@ package contractmanager
 // it's a rare situation, but it should be addressed eventually.
 
 import (
+	"os"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/crypto"
@@ -118,6 +120,14 @@ type ContractManager struct {
 	// lock contention on extra large contracts.
 	sectorRemoval *sectorRemovalMap
 
+	// staticScrubber holds the settings of the background scrub that
+	// verifies the integrity of the stored sectors.
+	staticScrubber *sectorScrubber
+
+	// sectorMigration is the migration of sectors between storage folders
+	// that is in progress, if any. It is protected by sectorMu.
+	sectorMigration *sectorMigration
+
 	// Utilities.
 	dependencies  modules.Dependencies
 	staticAlerter *modules.GenericAlerter
@@ -144,7 +154,8 @@ func newContractManager(dependencies modules.Dependencies, persistDir string) (_
 		dependencies: dependencies,
 		persistDir:   persistDir,
 
-		staticAlerter: modules.NewAlerter("contractmanager"),
+		staticAlerter:  modules.NewAlerter("contractmanager"),
+		staticScrubber: newSectorScrubber(),
 	}
 	cm.wal.cm = cm
 	cm.tg.AfterStop(func() {
@@ -195,6 +206,15 @@ func newContractManager(dependencies modules.Dependencies, persistDir string) (_
 		return nil, errors.AddContext(err, "error while loading contract manager atomic data")
 	}
 
+	// Load the snapshot of the sector locations. If there is a usable
+	// snapshot, the WAL recovery updates it and the sector locations don't
+	// need to be rebuilt from the storage folders.
+	snapshotErr := cm.loadSectorLocationSnapshot()
+	snapshotLoaded := snapshotErr == nil
+	if snapshotErr != nil && !os.IsNotExist(snapshotErr) {
+		cm.log.Println("WARN: unable to load the sector location snapshot, rebuilding the sector locations:", snapshotErr)
+	}
+
 	// Load the WAL, repairing any corruption caused by unclean shutdown.
 	err = cm.wal.load()
 	if err != nil {
@@ -229,8 +249,21 @@ func newContractManager(dependencies modules.Dependencies, persistDir string) (_
 	})
 
 	// Load the sector location data; any corruption that happened during
-	// unclean shutdown has already been fixed by the WAL.
+	// unclean shutdown has already been fixed by the WAL. If the sector
+	// locations were loaded from a snapshot, they only need to be checked
+	// against the storage folders.
 	cm.sectorMu.Lock()
+	if snapshotLoaded {
+		if err := cm.checkSnapshotSectorLocations(); err != nil {
+			cm.log.Println("WARN: sector location snapshot doesn't match the storage folders, rebuilding the sector locations:", err)
+			snapshotLoaded = false
+		}
+	}
+	if !snapshotLoaded {
+		// Drop the sector locations of the snapshot or the WAL recovery,
+		// they are all read from the storage folders.
+		cm.sectorLocations = make(map[sectorID]sectorLocation)
+	}
 	for _, sf := range cm.storageFolders {
 		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
 			// Metadata unavailable, just count the number of sectors instead of
@@ -238,9 +271,13 @@ func newContractManager(dependencies modules.Dependencies, persistDir string) (_
 			sf.sectors = uint64(len(usageSectors(sf.usage)))
 			continue
 		}
-		cm.loadSectorLocations(sf)
+		if !snapshotLoaded {
+			cm.loadSectorLocations(sf)
+		}
 	}
 	cm.sectorMu.Unlock()
+	cm.managedUpdateWritableStorageFoldersAlert()
+	cm.managedUpdateCorruptSectorsAlerts()
 
 	// Launch the sync loop that periodically flushes changes from the WAL to
 	// disk.
@@ -254,6 +291,18 @@ func newContractManager(dependencies modules.Dependencies, persistDir string) (_
 	// and adds them if they are discovered.
 	go cm.threadedFolderRecheck()
 
+	// Spin up the thread that scrubs the storage folders in the background
+	// once the scrub is enabled.
+	go cm.threadedScrubSectors()
+
+	// Spin up the thread that probes quarantined storage folders and lifts
+	// their quarantine once their disks recover.
+	go cm.threadedProbeQuarantinedFolders()
+
+	// Resume the migration of sectors that was interrupted by the previous
+	// shutdown.
+	go cm.threadedResumeSectorMigration()
+
 	// the removal map is loaded last so that the WAL and metadata is loaded.
 	cm.sectorRemoval, err = newSectorRemovalMap(filepath.Join(persistDir, sectorRemovalQueueFile), cm)
 	if err != nil {
@@ -287,3 +336,27 @@ func NewCustomContractManager(dependencies modules.Dependencies, persistDir stri
 func (cm *ContractManager) Alerts() (crit, err, warn, info []modules.Alert) {
 	return cm.staticAlerter.Alerts()
 }
+
+// RegisterSubscriber implements the modules.AlertSubscriber interface for the
+// contract manager.
+func (cm *ContractManager) RegisterSubscriber(ch chan<- modules.AlertEvent) {
+	cm.staticAlerter.RegisterSubscriber(ch)
+}
+
+// UnregisterSubscriber implements the modules.AlertSubscriber interface for
+// the contract manager.
+func (cm *ContractManager) UnregisterSubscriber(ch chan<- modules.AlertEvent) {
+	cm.staticAlerter.UnregisterSubscriber(ch)
+}
+
+// DismissModuleAlert implements the modules.AlertDismisser interface for the
+// contract manager.
+func (cm *ContractManager) DismissModuleAlert(module string, id modules.AlertID) error {
+	return cm.staticAlerter.DismissModuleAlert(module, id)
+}
+
+// SnoozeModuleAlert implements the modules.AlertDismisser interface for the
+// contract manager.
+func (cm *ContractManager) SnoozeModuleAlert(module string, id modules.AlertID, d time.Duration) error {
+	return cm.staticAlerter.SnoozeModuleAlert(module, id, d)
+}
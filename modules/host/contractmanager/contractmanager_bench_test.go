@@ -1,11 +1,18 @@
 package contractmanager
 
 import (
+	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"gitlab.com/NebulousLabs/fastrand"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
 )
 
 // BenchmarkSectorLocations explores the cost of creating the sectorLocations
@@ -78,3 +85,67 @@ func BenchmarkStorageFolders(b *testing.B) {
 		randFreeSector(usage)
 	}
 }
+
+// BenchmarkAddSector measures the throughput of AddSector in sectors per
+// second for different numbers of concurrent uploads. Every call blocks until
+// the sector was committed by the WAL, which makes the throughput depend on
+// how many sectors the sync loop commits at once and how often it syncs the
+// storage folders.
+func BenchmarkAddSector(b *testing.B) {
+	for _, uploads := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("Uploads%v", uploads), func(b *testing.B) {
+			benchmarkAddSector(b, uploads)
+		})
+	}
+}
+
+// benchmarkAddSector adds b.N sectors to a contract manager with storage
+// folders in a temp dir using the given number of concurrent uploads.
+func benchmarkAddSector(b *testing.B, uploads int) {
+	cmt, err := newContractManagerTester(b.Name())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add enough storage folders to fit all sectors.
+	numFolders := (uint64(b.N) + MaximumSectorsPerStorageFolder - 1) / MaximumSectorsPerStorageFolder
+	if numFolders > maximumStorageFolders {
+		b.Fatal("too many sectors for the storage folders", b.N)
+	}
+	for i := uint64(0); i < numFolders; i++ {
+		dir := filepath.Join(cmt.persistDir, fmt.Sprintf("storageFolder%v", i))
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			b.Fatal(err)
+		}
+		if err := cmt.cm.AddStorageFolder(dir, modules.SectorSize*MaximumSectorsPerStorageFolder); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	// Create the sectors up front.
+	roots := make([]crypto.Hash, b.N)
+	datas := make([][]byte, b.N)
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+	}
+
+	// Add the sectors using the given number of concurrent uploads.
+	b.ResetTimer()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for u := 0; u < uploads; u++ {
+		wg.Add(1)
+		go func(u int) {
+			defer wg.Done()
+			for i := u; i < b.N; i += uploads {
+				if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}(u)
+	}
+	wg.Wait()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "sectors/s")
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"sort"
 	"sync/atomic"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
@@ -19,31 +20,61 @@ type (
 	// savedStorageFolder contains fields that are saved automatically to disk
 	// for each storage folder.
 	savedStorageFolder struct {
-		Index uint16
-		Path  string
-		Usage []uint64
+		Index    uint16
+		Path     string
+		ReadOnly bool `json:",omitempty"`
+		Usage    []uint64
+
+		CorruptSectors  []savedCorruptSector `json:",omitempty"`
+		ScrubCompleted  time.Time            `json:",omitempty"`
+		ScrubPosition   uint32               `json:",omitempty"`
+		ScrubbedSectors uint64               `json:",omitempty"`
+	}
+
+	// savedCorruptSector is a sector of a storage folder that the background
+	// scrub found to be corrupt.
+	savedCorruptSector struct {
+		Index uint32
+		ID    sectorID
 	}
 
 	// savedSettings contains fields that are saved atomically to disk inside
 	// of the contract manager directory, alongside the WAL and log.
+	//
+	// Generation is the generation of the WAL that the settings were saved
+	// at, see writeAheadLog.generation. SectorMigration is the unfinished
+	// migration of sectors between storage folders, if any.
 	savedSettings struct {
-		SectorSalt     crypto.Hash
-		StorageFolders []savedStorageFolder
+		Generation      uint64           `json:",omitempty"`
+		SectorMigration *sectorMigration `json:",omitempty"`
+		SectorSalt      crypto.Hash
+		StorageFolders  []savedStorageFolder
 	}
 )
 
 // equals tests if all settings are equal between two savedSettings.
 func (s *savedSettings) equals(sb savedSettings) bool {
-	if s.SectorSalt != sb.SectorSalt || len(s.StorageFolders) != len(sb.StorageFolders) {
+	if s.Generation != sb.Generation || s.SectorSalt != sb.SectorSalt || len(s.StorageFolders) != len(sb.StorageFolders) {
+		return false
+	}
+	if (s.SectorMigration == nil) != (sb.SectorMigration == nil) || (s.SectorMigration != nil && *s.SectorMigration != *sb.SectorMigration) {
 		return false
 	}
 
 	for i, sf := range s.StorageFolders {
 		sfb := sb.StorageFolders[i]
 
-		if sf.Index != sfb.Index || sf.Path != sfb.Path || len(sf.Usage) != len(sfb.Usage) {
+		if sf.Index != sfb.Index || sf.Path != sfb.Path || sf.ReadOnly != sfb.ReadOnly || len(sf.Usage) != len(sfb.Usage) {
+			return false
+		}
+		if !sf.ScrubCompleted.Equal(sfb.ScrubCompleted) || sf.ScrubPosition != sfb.ScrubPosition || sf.ScrubbedSectors != sfb.ScrubbedSectors || len(sf.CorruptSectors) != len(sfb.CorruptSectors) {
 			return false
 		}
+		for i := range sf.CorruptSectors {
+			if sf.CorruptSectors[i] != sfb.CorruptSectors[i] {
+				return false
+			}
+		}
 
 		for i := range sf.Usage {
 			if sf.Usage[i] != sfb.Usage[i] {
@@ -58,11 +89,22 @@ func (s *savedSettings) equals(sb savedSettings) bool {
 // savedStorageFolder returns the persistent version of the storage folder.
 func (sf *storageFolder) savedStorageFolder() savedStorageFolder {
 	ssf := savedStorageFolder{
-		Index: sf.index,
-		Path:  sf.path,
-		Usage: make([]uint64, len(sf.usage)),
+		Index:    sf.index,
+		Path:     sf.path,
+		ReadOnly: sf.readOnly,
+		Usage:    make([]uint64, len(sf.usage)),
+
+		ScrubCompleted:  sf.scrubCompleted,
+		ScrubPosition:   sf.scrubPosition,
+		ScrubbedSectors: sf.scrubbedSectors,
 	}
 	copy(ssf.Usage, sf.usage)
+	for index, id := range sf.corruptSectors {
+		ssf.CorruptSectors = append(ssf.CorruptSectors, savedCorruptSector{Index: index, ID: id})
+	}
+	sort.Slice(ssf.CorruptSectors, func(i, j int) bool {
+		return ssf.CorruptSectors[i].Index < ssf.CorruptSectors[j].Index
+	})
 	return ssf
 }
 
@@ -97,11 +139,20 @@ func (cm *ContractManager) loadSettings() error {
 
 	// Copy the saved settings into the contract manager.
 	cm.sectorSalt = ss.SectorSalt
+	cm.wal.generation = ss.Generation
+	cm.sectorMigration = ss.SectorMigration
 	for i := range ss.StorageFolders {
 		sf := new(storageFolder)
 		sf.index = ss.StorageFolders[i].Index
 		sf.path = ss.StorageFolders[i].Path
+		sf.readOnly = ss.StorageFolders[i].ReadOnly
 		sf.usage = ss.StorageFolders[i].Usage
+		sf.scrubCompleted = ss.StorageFolders[i].ScrubCompleted
+		sf.scrubPosition = ss.StorageFolders[i].ScrubPosition
+		sf.scrubbedSectors = ss.StorageFolders[i].ScrubbedSectors
+		for _, cs := range ss.StorageFolders[i].CorruptSectors {
+			sf.markCorrupt(cs.Index, cs.ID)
+		}
 		sf.metadataFile, err = cm.dependencies.OpenFile(filepath.Join(ss.StorageFolders[i].Path, metadataFile), os.O_RDWR, 0700)
 		if err != nil {
 			// Mark the folder as unavailable and log an error.
@@ -172,9 +223,14 @@ func (cm *ContractManager) loadSectorLocations(sf *storageFolder) {
 // easily-serializable form.
 func (cm *ContractManager) savedSettings() savedSettings {
 	ss := savedSettings{
+		Generation: cm.wal.generation,
 		SectorSalt: cm.sectorSalt,
 	}
 	cm.sectorMu.Lock()
+	if cm.sectorMigration != nil {
+		m := *cm.sectorMigration
+		ss.SectorMigration = &m
+	}
 	for _, sf := range cm.storageFolders {
 		// Unset all of the usage bits in the storage folder for the queued sectors.
 		for _, sectorIndex := range sf.availableSectors {
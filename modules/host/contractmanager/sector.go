@@ -15,6 +15,11 @@ var (
 	// ErrSectorNotFound is returned when a lookup for a sector fails.
 	ErrSectorNotFound = errors.New("could not find the desired sector")
 
+	// ErrSectorCorrupt is returned when a sector is read that the background
+	// scrub found to be corrupt, if the scrub is configured to mark corrupt
+	// sectors.
+	ErrSectorCorrupt = errors.New("sector is corrupt")
+
 	// errDiskTrouble is returned when the host is supposed to have enough
 	// storage to hold a new sector but failures that are likely related to the
 	// disk have prevented the host from successfully adding the sector.
@@ -134,6 +139,7 @@ func (cm *ContractManager) ReadPartialSector(root crypto.Hash, offset, length ui
 	cm.sectorMu.Lock()
 	sl, exists1 := cm.sectorLocations[id]
 	sf, exists2 := cm.storageFolders[sl.storageFolder]
+	corrupt := exists1 && exists2 && sf.isCorrupt(sl.index, id)
 	cm.sectorMu.Unlock()
 	if !exists1 {
 		return nil, ErrSectorNotFound
@@ -146,14 +152,17 @@ func (cm *ContractManager) ReadPartialSector(root crypto.Hash, offset, length ui
 		// TODO: Pick a new error instead.
 		return nil, ErrSectorNotFound
 	}
+	if corrupt && cm.staticScrubber.managedMarkCorrupt() {
+		return nil, ErrSectorCorrupt
+	}
 
 	// Read the sector.
 	sectorData, err := readPartialSector(sf.sectorFile, sl.index, offset, length)
 	if err != nil {
-		atomic.AddUint64(&sf.atomicFailedReads, 1)
+		cm.managedRecordRead(sf, err)
 		return nil, build.ExtendErr("unable to fetch sector", err)
 	}
-	atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	cm.managedRecordRead(sf, nil)
 	return sectorData, nil
 }
 
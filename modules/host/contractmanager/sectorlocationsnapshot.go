@@ -0,0 +1,259 @@
+package contractmanager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+// The sector location snapshot is a copy of the sectorLocations map that is
+// persisted periodically by the sync loop and at clean shutdown. Rebuilding
+// the map at startup requires reading the metadata of every sector in every
+// storage folder, which takes a long time on hosts with millions of sectors.
+// Instead, the snapshot is loaded and only the changes of the WAL are applied
+// on top of it.
+//
+// Every commit of the WAL that contains changes increments the generation of
+// the contract manager, which is saved in the settings file alongside the
+// usage of the storage folders. A snapshot is only used if it was taken at the
+// same generation as the settings, which guarantees that the WAL contains all
+// of the changes that were committed since the snapshot was taken. A missing,
+// corrupt or stale snapshot falls back to rebuilding the sector locations from
+// the storage folders.
+
+const (
+	// sectorLocationSnapshotEntrySize is the size of a single sector location
+	// in the snapshot. An entry consists of the sector id, the index of the
+	// storage folder, the index of the sector within the storage folder and the
+	// sector's count.
+	sectorLocationSnapshotEntrySize = 12 + 2 + 4 + 8
+
+	// sectorLocationSnapshotHeaderSize is the size of the header of the
+	// snapshot, which consists of the version, the generation and the number
+	// of entries.
+	sectorLocationSnapshotHeaderSize = types.SpecifierLen + 8 + 8
+)
+
+var (
+	// errSectorLocationSnapshotCorrupt is returned when the snapshot of the
+	// sector locations can't be decoded or its checksum doesn't match.
+	errSectorLocationSnapshotCorrupt = errors.New("sector location snapshot is corrupt")
+
+	// errSectorLocationSnapshotStale is returned when the snapshot of the
+	// sector locations was taken at a different generation than the settings
+	// were saved at.
+	errSectorLocationSnapshotStale = errors.New("sector location snapshot is stale")
+
+	// sectorLocationSnapshotVersion is the version at the start of the
+	// snapshot of the sector locations.
+	sectorLocationSnapshotVersion = types.NewSpecifier("SectorLocs-1.6")
+)
+
+// encodeSectorLocations encodes the snapshot of the given sector locations
+// taken at the given generation.
+func encodeSectorLocations(generation uint64, locations map[sectorID]sectorLocation) []byte {
+	b := make([]byte, sectorLocationSnapshotHeaderSize, sectorLocationSnapshotHeaderSize+len(locations)*sectorLocationSnapshotEntrySize+crypto.HashSize)
+	copy(b, sectorLocationSnapshotVersion[:])
+	binary.LittleEndian.PutUint64(b[types.SpecifierLen:], generation)
+	binary.LittleEndian.PutUint64(b[types.SpecifierLen+8:], uint64(len(locations)))
+	var entry [sectorLocationSnapshotEntrySize]byte
+	for id, sl := range locations {
+		copy(entry[:12], id[:])
+		binary.LittleEndian.PutUint16(entry[12:], sl.storageFolder)
+		binary.LittleEndian.PutUint32(entry[14:], sl.index)
+		binary.LittleEndian.PutUint64(entry[18:], sl.count)
+		b = append(b, entry[:]...)
+	}
+	checksum := crypto.HashBytes(b)
+	return append(b, checksum[:]...)
+}
+
+// decodeSectorLocations decodes a snapshot of the sector locations and returns
+// the generation it was taken at.
+func decodeSectorLocations(b []byte) (uint64, map[sectorID]sectorLocation, error) {
+	if len(b) < sectorLocationSnapshotHeaderSize+crypto.HashSize {
+		return 0, nil, errors.AddContext(errSectorLocationSnapshotCorrupt, "snapshot is too short")
+	}
+	data, checksum := b[:len(b)-crypto.HashSize], b[len(b)-crypto.HashSize:]
+	if hash := crypto.HashBytes(data); !bytes.Equal(hash[:], checksum) {
+		return 0, nil, errors.AddContext(errSectorLocationSnapshotCorrupt, "checksum mismatch")
+	}
+	if !bytes.Equal(data[:types.SpecifierLen], sectorLocationSnapshotVersion[:]) {
+		return 0, nil, errors.AddContext(errSectorLocationSnapshotCorrupt, "unknown version")
+	}
+	generation := binary.LittleEndian.Uint64(data[types.SpecifierLen:])
+	numEntries := binary.LittleEndian.Uint64(data[types.SpecifierLen+8:])
+	entries := data[sectorLocationSnapshotHeaderSize:]
+	if uint64(len(entries)) != numEntries*sectorLocationSnapshotEntrySize {
+		return 0, nil, errors.AddContext(errSectorLocationSnapshotCorrupt, "wrong number of entries")
+	}
+
+	locations := make(map[sectorID]sectorLocation, numEntries)
+	for len(entries) > 0 {
+		var id sectorID
+		copy(id[:], entries[:12])
+		locations[id] = sectorLocation{
+			storageFolder: binary.LittleEndian.Uint16(entries[12:]),
+			index:         binary.LittleEndian.Uint32(entries[14:]),
+			count:         binary.LittleEndian.Uint64(entries[18:]),
+		}
+		entries = entries[sectorLocationSnapshotEntrySize:]
+	}
+	return generation, locations, nil
+}
+
+// snapshotSectorLocations encodes a snapshot of the current sector locations.
+// The caller must hold wal.mu and there must not be any uncommitted changes,
+// so that the snapshot matches the state of the current generation.
+func (wal *writeAheadLog) snapshotSectorLocations() []byte {
+	wal.cm.sectorMu.Lock()
+	defer wal.cm.sectorMu.Unlock()
+	wal.snapshotGeneration = wal.generation
+	wal.snapshotTime = time.Now()
+	return encodeSectorLocations(wal.generation, wal.cm.sectorLocations)
+}
+
+// managedSaveSectorLocationSnapshot atomically replaces the snapshot of the
+// sector locations on disk with the provided one.
+func (wal *writeAheadLog) managedSaveSectorLocationSnapshot(snapshot []byte) (err error) {
+	wal.snapshotMu.Lock()
+	defer wal.snapshotMu.Unlock()
+
+	tmpFilename := filepath.Join(wal.cm.persistDir, sectorLocationSnapshotFileTmp)
+	filename := filepath.Join(wal.cm.persistDir, sectorLocationSnapshotFile)
+	f, err := wal.cm.dependencies.CreateFile(tmpFilename)
+	if err != nil {
+		return errors.AddContext(err, "unable to create the temporary snapshot file")
+	}
+	_, err = f.Write(snapshot)
+	if err != nil {
+		return errors.Compose(errors.AddContext(err, "unable to write the snapshot"), f.Close())
+	}
+	err = f.Sync()
+	if err != nil {
+		return errors.Compose(errors.AddContext(err, "unable to sync the snapshot"), f.Close())
+	}
+	err = f.Close()
+	if err != nil {
+		return errors.AddContext(err, "unable to close the snapshot")
+	}
+	return errors.AddContext(wal.cm.dependencies.RenameFile(tmpFilename, filename), "unable to rename the snapshot")
+}
+
+// queueSectorLocationSnapshot saves a snapshot of the sector locations if the
+// last one is older than sectorLocationSnapshotInterval and the state changed
+// since. The caller must hold wal.mu and there must not be any uncommitted
+// changes. The snapshot is written to disk in the background, shutdown waits
+// for it to finish before saving the final snapshot.
+func (wal *writeAheadLog) queueSectorLocationSnapshot() {
+	if wal.generation == wal.snapshotGeneration || time.Since(wal.snapshotTime) < sectorLocationSnapshotInterval {
+		return
+	}
+	if err := wal.cm.tg.Add(); err != nil {
+		return
+	}
+	snapshot := wal.snapshotSectorLocations()
+	go func() {
+		defer wal.cm.tg.Done()
+		err := wal.managedSaveSectorLocationSnapshot(snapshot)
+		if err != nil {
+			wal.cm.log.Println("ERROR: unable to save the sector location snapshot:", err)
+		}
+	}()
+}
+
+// loadSectorLocationSnapshot loads the sector locations from the snapshot on
+// disk. The snapshot is only loaded if it was taken at the generation that
+// the settings were saved at. An unusable snapshot is removed, so that it
+// can't be mistaken for a snapshot of a later generation.
+func (cm *ContractManager) loadSectorLocationSnapshot() (err error) {
+	filename := filepath.Join(cm.persistDir, sectorLocationSnapshotFile)
+	f, err := cm.dependencies.OpenFile(filename, os.O_RDONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Compose(err, cm.dependencies.RemoveFile(filename))
+		}
+	}()
+	var b []byte
+	fi, err := f.Stat()
+	if err == nil {
+		b = make([]byte, fi.Size())
+		_, err = io.ReadFull(f, b)
+	}
+	err = errors.Compose(err, f.Close())
+	if err != nil {
+		return errors.AddContext(err, "unable to read the snapshot")
+	}
+	generation, locations, err := decodeSectorLocations(b)
+	if err != nil {
+		return err
+	}
+	if generation == 0 || generation != cm.wal.generation {
+		return errors.AddContext(errSectorLocationSnapshotStale, fmt.Sprintf("snapshot was taken at generation %v, settings were saved at generation %v", generation, cm.wal.generation))
+	}
+	cm.sectorLocations = locations
+	cm.wal.snapshotGeneration = generation
+	cm.wal.snapshotTime = time.Now()
+	return nil
+}
+
+// checkSnapshotSectorLocations checks that the sector locations that were
+// loaded from a snapshot and updated by the WAL recovery match the usage of
+// the available storage folders, and sets the number of sectors of every
+// storage folder. Only the sectors of available storage folders are kept, the
+// sectors of the other storage folders are loaded from disk once they become
+// available. If the sector locations don't match the storage folders, the
+// snapshot is removed and the sector locations have to be rebuilt from the
+// storage folders. The caller must hold sectorMu.
+func (cm *ContractManager) checkSnapshotSectorLocations() (err error) {
+	defer func() {
+		if err != nil {
+			err = errors.Compose(errSectorLocationSnapshotStale, err)
+			err = errors.Compose(err, cm.dependencies.RemoveFile(filepath.Join(cm.persistDir, sectorLocationSnapshotFile)))
+		}
+	}()
+	counts := make(map[uint16]uint64)
+	for id, sl := range cm.sectorLocations {
+		sf, exists := cm.storageFolders[sl.storageFolder]
+		if !exists {
+			return fmt.Errorf("snapshot contains sectors of unknown storage folder %v", sl.storageFolder)
+		}
+		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+			delete(cm.sectorLocations, id)
+			continue
+		}
+		counts[sl.storageFolder]++
+	}
+	for _, sf := range cm.storageFolders {
+		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+			continue
+		}
+		var used uint64
+		for _, u := range sf.usage {
+			used += uint64(bits.OnesCount64(u))
+		}
+		if counts[sf.index] != used {
+			return fmt.Errorf("snapshot has %v sectors in storage folder %v, but %v are in use", counts[sf.index], sf.path, used)
+		}
+	}
+	for _, sf := range cm.storageFolders {
+		if atomic.LoadUint64(&sf.atomicUnavailable) == 0 {
+			sf.sectors = counts[sf.index]
+		}
+	}
+	return nil
+}
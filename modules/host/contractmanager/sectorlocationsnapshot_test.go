@@ -0,0 +1,472 @@
+package contractmanager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+)
+
+// dependencyNoSettingsRename is a mocked dependency that prevents the
+// settings file from being renamed and the WAL file from being removed, which
+// leaves the changes of the session in the WAL only.
+type dependencyNoSettingsRename struct {
+	modules.ProductionDependencies
+}
+
+// Disrupt prevents the settings from being renamed and the WAL file from being
+// removed.
+func (d *dependencyNoSettingsRename) Disrupt(s string) bool {
+	return s == "settingsSyncRename" || s == "cleanWALFile"
+}
+
+// snapshotPath returns the path of the sector location snapshot of the tester.
+func (cmt *contractManagerTester) snapshotPath() string {
+	return filepath.Join(cmt.persistDir, modules.ContractManagerDir, sectorLocationSnapshotFile)
+}
+
+// checkSectors checks that the sectors with the given roots can be read and
+// hold the given data.
+func (cmt *contractManagerTester) checkSectors(roots []crypto.Hash, datas [][]byte) error {
+	for i, root := range roots {
+		data, err := cmt.cm.ReadSector(root)
+		if err != nil {
+			return errors.AddContext(err, "unable to read sector")
+		}
+		if !bytes.Equal(data, datas[i]) {
+			return errors.New("sector data doesn't match")
+		}
+	}
+	return nil
+}
+
+// TestSectorLocationSnapshotEncoding is a unit test for encoding and decoding
+// the snapshot of the sector locations.
+func TestSectorLocationSnapshotEncoding(t *testing.T) {
+	t.Parallel()
+
+	locations := make(map[sectorID]sectorLocation)
+	for i := 0; i < 100; i++ {
+		var id sectorID
+		fastrand.Read(id[:])
+		locations[id] = sectorLocation{
+			index:         uint32(fastrand.Intn(1 << 20)),
+			storageFolder: uint16(fastrand.Intn(1 << 16)),
+			count:         fastrand.Uint64n(1 << 40),
+		}
+	}
+	b := encodeSectorLocations(42, locations)
+	generation, decoded, err := decodeSectorLocations(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generation != 42 || !reflect.DeepEqual(decoded, locations) {
+		t.Fatal("decoded snapshot doesn't match", generation)
+	}
+
+	// An empty snapshot is valid.
+	generation, decoded, err = decodeSectorLocations(encodeSectorLocations(1, nil))
+	if err != nil || generation != 1 || len(decoded) != 0 {
+		t.Fatal("unexpected", err, generation, len(decoded))
+	}
+
+	// Any corruption is detected.
+	corrupt := append([]byte(nil), b...)
+	corrupt[fastrand.Intn(len(corrupt))]++
+	if _, _, err := decodeSectorLocations(corrupt); !errors.Contains(err, errSectorLocationSnapshotCorrupt) {
+		t.Fatal("expected errSectorLocationSnapshotCorrupt, got", err)
+	}
+	if _, _, err := decodeSectorLocations(b[:len(b)-1]); !errors.Contains(err, errSectorLocationSnapshotCorrupt) {
+		t.Fatal("expected errSectorLocationSnapshotCorrupt, got", err)
+	}
+	if _, _, err := decodeSectorLocations(nil); !errors.Contains(err, errSectorLocationSnapshotCorrupt) {
+		t.Fatal("expected errSectorLocationSnapshotCorrupt, got", err)
+	}
+}
+
+// TestSectorLocationSnapshot checks that the sector locations are loaded from
+// the snapshot after a clean shutdown and that a corrupt snapshot falls back
+// to rebuilding them from the storage folders.
+func TestSectorLocationSnapshot(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderOne, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderOne, modules.SectorSize*storageFolderGranularity*2); err != nil {
+		t.Fatal(err)
+	}
+	roots := make([]crypto.Hash, 10)
+	datas := make([][]byte, len(roots))
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Add a virtual sector and delete a sector.
+	if err := cmt.cm.AddSector(roots[0], datas[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.DeleteSector(roots[9]); err != nil {
+		t.Fatal(err)
+	}
+	roots, datas = roots[:9], datas[:9]
+
+	// Close the contract manager, which saves the snapshot.
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cmt.snapshotPath()); err != nil {
+		t.Fatal("snapshot wasn't saved", err)
+	}
+
+	// Wipe the sector metadata of the storage folder. The sectors can only be
+	// found after a restart if their locations are loaded from the snapshot.
+	metadataPath := filepath.Join(storageFolderOne, metadataFile)
+	metadata, err := ioutil.ReadFile(metadataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(metadataPath, make([]byte, len(metadata)), 0700); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.checkSectors(roots, datas); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm.sectorMu.Lock()
+	count := cmt.cm.sectorLocations[cmt.cm.managedSectorID(roots[0])].count
+	cmt.cm.sectorMu.Unlock()
+	if count != 2 {
+		t.Fatal("virtual sector count wasn't loaded from the snapshot", count)
+	}
+	sf := cmt.cm.StorageFolders()[0]
+	if sf.Capacity-sf.CapacityRemaining != uint64(len(roots))*modules.SectorSize {
+		t.Fatal("wrong number of sectors", (sf.Capacity-sf.CapacityRemaining)/modules.SectorSize)
+	}
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore the metadata and corrupt the snapshot. The sector locations are
+	// rebuilt from the storage folder and the corrupt snapshot is removed.
+	if err := ioutil.WriteFile(metadataPath, metadata, 0700); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := ioutil.ReadFile(cmt.snapshotPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot[sectorLocationSnapshotHeaderSize]++
+	if err := ioutil.WriteFile(cmt.snapshotPath(), snapshot, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cmt.snapshotPath()); !os.IsNotExist(err) {
+		t.Fatal("corrupt snapshot wasn't removed", err)
+	}
+	if err := cmt.checkSectors(roots, datas); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSectorLocationSnapshotWALReplay checks that the changes in the WAL that
+// are newer than the snapshot are applied to the sector locations loaded from
+// the snapshot after an unclean shutdown.
+func TestSectorLocationSnapshotWALReplay(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderOne, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderOne, modules.SectorSize*storageFolderGranularity*2); err != nil {
+		t.Fatal(err)
+	}
+	roots := make([]crypto.Hash, 5)
+	datas := make([][]byte, len(roots))
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := ioutil.ReadFile(cmt.snapshotPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart without saving the settings and add another sector, which is
+	// only recorded in the WAL. Restore the snapshot of the previous session
+	// to simulate an unclean shutdown before a new snapshot was saved.
+	cmt.cm, err = newContractManager(new(dependencyNoSettingsRename), filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, data := randSector()
+	if err := cmt.cm.AddSector(root, data); err != nil {
+		t.Fatal(err)
+	}
+	roots, datas = append(roots, root), append(datas, data)
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cmt.snapshotPath(), snapshot, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wipe the sector metadata of the storage folder. The recovery of the WAL
+	// rewrites the metadata of the new sector, the locations of the other
+	// sectors can only be loaded from the snapshot.
+	metadataPath := filepath.Join(storageFolderOne, metadataFile)
+	fi, err := os.Stat(metadataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(metadataPath, make([]byte, fi.Size()), 0700); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.checkSectors(roots, datas); err != nil {
+		t.Fatal(err)
+	}
+	sf := cmt.cm.StorageFolders()[0]
+	if sf.Capacity-sf.CapacityRemaining != uint64(len(roots))*modules.SectorSize {
+		t.Fatal("wrong number of sectors", (sf.Capacity-sf.CapacityRemaining)/modules.SectorSize)
+	}
+}
+
+// TestSectorLocationSnapshotStale checks that a snapshot that is older than
+// the changes committed by the WAL isn't used, and that the sync loop saves
+// new snapshots periodically.
+func TestSectorLocationSnapshotStale(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := new(dependencyLeaveWAL)
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderOne, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderOne, modules.SectorSize*storageFolderGranularity*2); err != nil {
+		t.Fatal(err)
+	}
+	roots := make([]crypto.Hash, 5)
+	datas := make([][]byte, len(roots))
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := ioutil.ReadFile(cmt.snapshotPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart, delete a sector and add new ones. The WAL is left on disk at
+	// shutdown.
+	cmt.cm, err = newContractManager(d, filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.DeleteSector(roots[0]); err != nil {
+		t.Fatal(err)
+	}
+	deletedRoot := roots[0]
+	roots, datas = roots[1:], datas[1:]
+	for i := 0; i < 5; i++ {
+		root, data := randSector()
+		if err := cmt.cm.AddSector(root, data); err != nil {
+			t.Fatal(err)
+		}
+		roots, datas = append(roots, root), append(datas, data)
+	}
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore the snapshot of the first session. It's stale, so the sector
+	// locations are rebuilt from the storage folder.
+	if err := ioutil.WriteFile(cmt.snapshotPath(), snapshot, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = newContractManager(d, filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cmt.snapshotPath()); !os.IsNotExist(err) {
+		t.Fatal("stale snapshot wasn't removed", err)
+	}
+	if err := cmt.checkSectors(roots, datas); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmt.cm.ReadSector(deletedRoot); err == nil {
+		t.Fatal("deleted sector shouldn't be found")
+	}
+
+	// Once the state changes, the sync loop saves a new snapshot.
+	root, data := randSector()
+	if err := cmt.cm.AddSector(root, data); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		b, err := ioutil.ReadFile(cmt.snapshotPath())
+		if err != nil {
+			return err
+		}
+		generation, locations, err := decodeSectorLocations(b)
+		if err != nil {
+			return err
+		}
+		cmt.cm.wal.mu.Lock()
+		expected := cmt.cm.wal.generation
+		cmt.cm.wal.mu.Unlock()
+		if generation != expected || len(locations) != len(roots)+1 {
+			return errors.New("snapshot is outdated")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSectorLocationSnapshotStartupTime compares the startup time of a
+// contract manager with ~50k sectors when the sector locations are rebuilt
+// from the storage folders and when they are loaded from the snapshot.
+func TestSectorLocationSnapshotStartupTime(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderOne, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderOne, modules.SectorSize*storageFolderGranularity); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fabricate a storage folder that is full of sectors by writing their
+	// metadata and marking them as used in the settings. The sector data
+	// isn't needed to load the sector locations.
+	numSectors := 782 * storageFolderGranularity
+	settingsPath := filepath.Join(cmt.persistDir, modules.ContractManagerDir, settingsFile)
+	var ss savedSettings
+	if err := persist.LoadJSON(settingsMetadata, &ss, settingsPath); err != nil {
+		t.Fatal(err)
+	}
+	ss.StorageFolders[0].Usage = make([]uint64, numSectors/storageFolderGranularity)
+	for i := range ss.StorageFolders[0].Usage {
+		ss.StorageFolders[0].Usage[i] = ^uint64(0)
+	}
+	if err := persist.SaveJSON(settingsMetadata, ss, settingsPath); err != nil {
+		t.Fatal(err)
+	}
+	metadata := make([]byte, numSectors*sectorMetadataDiskSize)
+	for i := 0; i < numSectors; i++ {
+		entry := metadata[i*sectorMetadataDiskSize:]
+		fastrand.Read(entry[:12])
+		binary.LittleEndian.PutUint16(entry[12:], 1)
+	}
+	if err := ioutil.WriteFile(filepath.Join(storageFolderOne, metadataFile), metadata, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(cmt.snapshotPath()); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	// startup starts the contract manager and returns how long it took.
+	startup := func() time.Duration {
+		start := time.Now()
+		cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		elapsed := time.Since(start)
+		cmt.cm.sectorMu.Lock()
+		numLocations := len(cmt.cm.sectorLocations)
+		cmt.cm.sectorMu.Unlock()
+		if numLocations != numSectors {
+			t.Fatalf("expected %v sectors, got %v", numSectors, numLocations)
+		}
+		return elapsed
+	}
+
+	// The first startup rebuilds the sector locations from the storage folder
+	// and saves the snapshot at shutdown.
+	rebuildTime := startup()
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wipe the metadata to make sure that the second startup loads the sector
+	// locations from the snapshot.
+	if err := ioutil.WriteFile(filepath.Join(storageFolderOne, metadataFile), make([]byte, len(metadata)), 0700); err != nil {
+		t.Fatal(err)
+	}
+	snapshotTime := startup()
+	t.Logf("startup with %v sectors: rebuild %v, snapshot %v", numSectors, rebuildTime, snapshotTime)
+}
@@ -0,0 +1,398 @@
+package contractmanager
+
+import (
+	"sync/atomic"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+var (
+	// ErrDestinationFolderFull is returned by MoveSectors if the destination
+	// storage folder ran out of space before the migration completed.
+	ErrDestinationFolderFull = errors.New("destination storage folder is full")
+
+	// ErrSectorMigrationInProgress is returned by MoveSectors if another
+	// migration of sectors is still in progress.
+	ErrSectorMigrationInProgress = errors.New("a sector migration is already in progress")
+
+	// errSectorMigrationInterrupted is returned if a sector migration is
+	// interrupted by a shutdown. The migration is resumed at startup.
+	errSectorMigrationInterrupted = errors.New("sector migration was interrupted")
+
+	// errSectorMigrationQuarantined is returned if sectors are migrated into a
+	// storage folder that is quarantined because its disk is failing.
+	errSectorMigrationQuarantined = errors.New("can't migrate sectors into a quarantined storage folder")
+
+	// errSectorMigrationReadOnly is returned if sectors are migrated into a
+	// read-only storage folder.
+	errSectorMigrationReadOnly = errors.New("can't migrate sectors into a read-only storage folder")
+
+	// errSectorMigrationSameFolder is returned if the source and destination
+	// of a sector migration are the same storage folder.
+	errSectorMigrationSameFolder = errors.New("source and destination storage folder are the same")
+)
+
+type (
+	// sectorMigration records the progress of a migration of sectors from one
+	// storage folder to another. Every sector move is submitted to the WAL
+	// together with the updated migration, so that the number of moved
+	// sectors always matches the moved sectors. An unfinished migration is
+	// saved with the settings and resumed at startup.
+	sectorMigration struct {
+		Source       uint16
+		Destination  uint16
+		MaxBytes     uint64
+		SectorsMoved uint64
+		Done         bool
+	}
+)
+
+// budgetExhausted returns whether the migration moved as many sectors as its
+// MaxBytes allow. A MaxBytes of 0 doesn't limit the migration.
+func (m sectorMigration) budgetExhausted() bool {
+	return m.MaxBytes != 0 && (m.SectorsMoved+1)*modules.SectorSize > m.MaxBytes
+}
+
+// commitSectorMigrationUpdate commits the progress of a sector migration to
+// the state.
+func (wal *writeAheadLog) commitSectorMigrationUpdate(m sectorMigration) {
+	wal.cm.sectorMu.Lock()
+	defer wal.cm.sectorMu.Unlock()
+	if m.Done {
+		wal.cm.sectorMigration = nil
+		return
+	}
+	wal.cm.sectorMigration = &m
+}
+
+// managedMigrateSector moves a sector from the source storage folder of the
+// migration into its destination storage folder. The sector is copied into a
+// free slot of the destination first. Only then the new location, the removal
+// of the old location and the progress of the migration are submitted to the
+// WAL in a single state change. If the contract manager shuts down before the
+// state change is committed, the sector stays in the source folder and the
+// slot in the destination is free again. The slot in the source folder stays
+// in use until the state change is committed, the returned channel is closed
+// once that happened. A nil channel is returned if the sector doesn't need to
+// be moved anymore.
+func (wal *writeAheadLog) managedMigrateSector(id sectorID, source, dest *storageFolder, m sectorMigration) (chan struct{}, error) {
+	wal.managedLockSector(id)
+	defer wal.managedUnlockSector(id)
+
+	// Sectors that were removed since the migration started don't need to be
+	// moved anymore.
+	wal.cm.sectorMu.Lock()
+	oldLocation, exists := wal.cm.sectorLocations[id]
+	wal.cm.sectorMu.Unlock()
+	if !exists || oldLocation.storageFolder != source.index {
+		return nil, nil
+	}
+
+	sectorData, err := readSector(source.sectorFile, oldLocation.index)
+	if err != nil {
+		wal.cm.managedRecordRead(source, err)
+		return nil, build.ExtendErr("unable to read sector selected for migration", err)
+	}
+	wal.cm.managedRecordRead(source, nil)
+
+	// Reserve a slot in the destination folder. The usage is set but the
+	// sector is marked as uncommitted, so the slot isn't persisted until the
+	// move is committed.
+	wal.mu.Lock()
+	wal.cm.sectorMu.Lock()
+	sectorIndex, err := randFreeSector(dest.usage)
+	if err != nil {
+		wal.cm.sectorMu.Unlock()
+		wal.mu.Unlock()
+		return nil, ErrDestinationFolderFull
+	}
+	dest.setUsage(sectorIndex)
+	dest.availableSectors[id] = sectorIndex
+	wal.cm.sectorMu.Unlock()
+	wal.mu.Unlock()
+
+	// releaseSlot frees the reserved slot if the sector can't be written.
+	releaseSlot := func() {
+		wal.mu.Lock()
+		wal.cm.sectorMu.Lock()
+		dest.clearUsage(sectorIndex)
+		delete(dest.availableSectors, id)
+		wal.cm.sectorMu.Unlock()
+		wal.mu.Unlock()
+	}
+
+	// Copy the sector and its metadata to the destination.
+	err = writeSector(dest.sectorFile, sectorIndex, sectorData)
+	if err != nil {
+		wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", dest.path, err)
+		wal.cm.managedRecordWrite(dest, err)
+		releaseSlot()
+		return nil, errDiskTrouble
+	}
+	su := sectorUpdate{
+		Count:  oldLocation.count,
+		ID:     id,
+		Folder: dest.index,
+		Index:  sectorIndex,
+	}
+	err = wal.writeSectorMetadata(dest, su)
+	if err != nil {
+		wal.cm.log.Printf("ERROR: Unable to write sector metadata for folder %v: %v\n", dest.path, err)
+		releaseSlot()
+		return nil, errDiskTrouble
+	}
+
+	// Allow an unclean shutdown between copying the sector and committing its
+	// new location to be simulated.
+	if wal.cm.dependencies.Disrupt("sectorMigrationCopied") {
+		return nil, errSectorMigrationInterrupted
+	}
+
+	// Commit the move together with the progress of the migration.
+	m.SectorsMoved++
+	oldSU := sectorUpdate{
+		Count:  0,
+		ID:     id,
+		Folder: source.index,
+		Index:  oldLocation.index,
+	}
+	wal.mu.Lock()
+	wal.cm.sectorMu.Lock()
+	wal.appendChange(stateChange{
+		SectorUpdates:          []sectorUpdate{oldSU, su},
+		SectorMigrationUpdates: []sectorMigration{m},
+		// Older versions may ignore the progress of the migration, the
+		// sector is moved either way.
+		Skippable: true,
+	})
+	// Mark the old slot as available, its usage is only cleared once the move
+	// has been committed to disk fully. Otherwise a new sector could overwrite
+	// the data that the sector location still points to after an unclean
+	// shutdown.
+	source.availableSectors[id] = oldLocation.index
+	delete(dest.availableSectors, id)
+	wal.cm.sectorLocations[id] = sectorLocation{
+		index:         sectorIndex,
+		storageFolder: dest.index,
+		count:         oldLocation.count,
+	}
+	wal.cm.sectorMigration = &m
+	syncChan := wal.syncChan
+	wal.cm.sectorMu.Unlock()
+	wal.mu.Unlock()
+	return syncChan, nil
+}
+
+// managedMigrateSectors moves the sectors of the migration's source folder
+// into its destination folder until the source folder is empty, the
+// migration's MaxBytes are reached or the destination folder is full. The
+// updated migration is returned.
+func (wal *writeAheadLog) managedMigrateSectors(m sectorMigration) (sectorMigration, error) {
+	wal.cm.sectorMu.Lock()
+	source, exists1 := wal.cm.storageFolders[m.Source]
+	dest, exists2 := wal.cm.storageFolders[m.Destination]
+	wal.cm.sectorMu.Unlock()
+	if !exists1 || !exists2 {
+		return m, errStorageFolderNotFound
+	}
+
+	// Lock the source folder for the duration of the migration, so that no new
+	// sectors are placed in it while it's being drained. The destination is
+	// read-locked like it is when sectors are added to it. Neither folder can
+	// be resized or removed during the migration.
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	dest.mu.RLock()
+	defer dest.mu.RUnlock()
+	if atomic.LoadUint64(&source.atomicUnavailable) == 1 || atomic.LoadUint64(&dest.atomicUnavailable) == 1 {
+		return m, errStorageFolderNotFound
+	}
+
+	// Free the old slots of the moved sectors once the moves have been
+	// committed, before the source folder is unlocked again.
+	var moved []sectorID
+	var syncChan chan struct{}
+	defer func() {
+		if syncChan == nil {
+			return
+		}
+		<-syncChan
+		wal.mu.Lock()
+		wal.cm.sectorMu.Lock()
+		for _, id := range moved {
+			source.clearUsage(source.availableSectors[id])
+			delete(source.availableSectors, id)
+		}
+		wal.cm.sectorMu.Unlock()
+		wal.mu.Unlock()
+	}()
+
+	// Collect the sectors of the source folder.
+	sectorLookupBytes, err := readFullMetadata(source.metadataFile, len(source.usage)*storageFolderGranularity)
+	if err != nil {
+		wal.cm.managedRecordRead(source, err)
+		return m, build.ExtendErr("unable to read sector metadata", err)
+	}
+	wal.cm.managedRecordRead(source, nil)
+	var ids []sectorID
+	wal.cm.sectorMu.Lock()
+	for _, sectorIndex := range usageSectors(source.usage) {
+		readHead := sectorMetadataDiskSize * sectorIndex
+		var id sectorID
+		copy(id[:], sectorLookupBytes[readHead:readHead+12])
+		if sl, exists := wal.cm.sectorLocations[id]; exists && sl.storageFolder == source.index {
+			ids = append(ids, id)
+		}
+	}
+	wal.cm.sectorMu.Unlock()
+
+	for _, id := range ids {
+		if m.budgetExhausted() {
+			break
+		}
+		select {
+		case <-wal.cm.tg.StopChan():
+			return m, errSectorMigrationInterrupted
+		default:
+		}
+		if atomic.LoadUint64(&dest.atomicQuarantined) == 1 {
+			return m, errSectorMigrationQuarantined
+		}
+		sectorSyncChan, err := wal.managedMigrateSector(id, source, dest, m)
+		if err != nil {
+			return m, err
+		}
+		if sectorSyncChan != nil {
+			m.SectorsMoved++
+			moved = append(moved, id)
+			syncChan = sectorSyncChan
+		}
+	}
+	return m, nil
+}
+
+// managedRunSectorMigration runs the migration and marks it as done once it
+// completed or failed. Interrupted migrations stay unfinished, so that they
+// are resumed at startup. The total number of sectors moved by the migration
+// is returned.
+func (cm *ContractManager) managedRunSectorMigration(m sectorMigration) (uint64, error) {
+	m, err := cm.wal.managedMigrateSectors(m)
+	if errors.Contains(err, errSectorMigrationInterrupted) {
+		return m.SectorsMoved, err
+	}
+
+	// Mark the migration as done and wait until the moves and the completion
+	// of the migration have been synchronized.
+	m.Done = true
+	cm.wal.mu.Lock()
+	cm.sectorMu.Lock()
+	cm.sectorMigration = nil
+	cm.sectorMu.Unlock()
+	cm.wal.appendChange(stateChange{
+		SectorMigrationUpdates: []sectorMigration{m},
+		Skippable:              true,
+	})
+	syncChan := cm.wal.syncChan
+	cm.wal.mu.Unlock()
+	<-syncChan
+	return m.SectorsMoved, err
+}
+
+// threadedResumeSectorMigration resumes a migration of sectors that was
+// interrupted by the previous shutdown.
+func (cm *ContractManager) threadedResumeSectorMigration() {
+	err := cm.tg.Add()
+	if err != nil {
+		return
+	}
+	defer cm.tg.Done()
+
+	cm.sectorMu.Lock()
+	migration := cm.sectorMigration
+	cm.sectorMu.Unlock()
+	if migration == nil {
+		return
+	}
+	m := *migration
+	cm.log.Printf("Resuming the migration of sectors from storage folder %v to storage folder %v, %v sectors were moved so far\n", m.Source, m.Destination, m.SectorsMoved)
+	moved, err := cm.managedRunSectorMigration(m)
+	if errors.Contains(err, errSectorMigrationInterrupted) {
+		return
+	}
+	if err != nil {
+		cm.log.Printf("ERROR: migration of sectors from storage folder %v to storage folder %v failed after moving %v sectors: %v\n", m.Source, m.Destination, moved, err)
+		return
+	}
+	cm.log.Printf("Completed the migration of sectors from storage folder %v to storage folder %v, %v sectors were moved\n", m.Source, m.Destination, moved)
+}
+
+// MoveSectors moves sectors from the source storage folder into the
+// destination storage folder until the source folder is empty, maxBytes of
+// sectors were moved or the destination folder is full, in which case
+// ErrDestinationFolderFull is returned. A maxBytes of 0 moves all sectors.
+// Every sector is copied before its new location is committed, so no sector
+// is lost if the contract manager shuts down during the migration. An
+// interrupted migration is resumed at startup. The total number of sectors
+// that were moved is returned.
+func (cm *ContractManager) MoveSectors(source, dest uint16, maxBytes uint64) (uint64, error) {
+	err := cm.tg.Add()
+	if err != nil {
+		return 0, err
+	}
+	defer cm.tg.Done()
+	if source == dest {
+		return 0, errSectorMigrationSameFolder
+	}
+
+	// Submit the start of the migration to the WAL and wait until it is
+	// synchronized, so that the migration is resumed after an unclean
+	// shutdown.
+	cm.wal.mu.Lock()
+	cm.sectorMu.Lock()
+	sourceFolder, exists1 := cm.storageFolders[source]
+	destFolder, exists2 := cm.storageFolders[dest]
+	if !exists1 || !exists2 {
+		cm.sectorMu.Unlock()
+		cm.wal.mu.Unlock()
+		return 0, errStorageFolderNotFound
+	}
+	if atomic.LoadUint64(&sourceFolder.atomicUnavailable) == 1 || atomic.LoadUint64(&destFolder.atomicUnavailable) == 1 {
+		cm.sectorMu.Unlock()
+		cm.wal.mu.Unlock()
+		return 0, errStorageFolderNotFound
+	}
+	if destFolder.readOnly {
+		cm.sectorMu.Unlock()
+		cm.wal.mu.Unlock()
+		return 0, errSectorMigrationReadOnly
+	}
+	if atomic.LoadUint64(&destFolder.atomicQuarantined) == 1 {
+		cm.sectorMu.Unlock()
+		cm.wal.mu.Unlock()
+		return 0, errSectorMigrationQuarantined
+	}
+	if cm.sectorMigration != nil {
+		cm.sectorMu.Unlock()
+		cm.wal.mu.Unlock()
+		return 0, ErrSectorMigrationInProgress
+	}
+	m := sectorMigration{
+		Source:      source,
+		Destination: dest,
+		MaxBytes:    maxBytes,
+	}
+	cm.sectorMigration = &m
+	cm.sectorMu.Unlock()
+	cm.wal.appendChange(stateChange{
+		SectorMigrationUpdates: []sectorMigration{m},
+		Skippable:              true,
+	})
+	syncChan := cm.wal.syncChan
+	cm.wal.mu.Unlock()
+	<-syncChan
+
+	return cm.managedRunSectorMigration(m)
+}
@@ -0,0 +1,480 @@
+package contractmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// dependencyInterruptMigration simulates an unclean shutdown after a sector
+// of a migration was copied to its destination, but before its new location
+// was committed.
+type dependencyInterruptMigration struct {
+	modules.ProductionDependencies
+
+	// atomicCopies is the number of copied sectors after which the migration
+	// is interrupted.
+	atomicCopies int64
+}
+
+// Disrupt interrupts the migration once enough sectors were copied and leaves
+// the WAL behind at shutdown.
+func (d *dependencyInterruptMigration) Disrupt(s string) bool {
+	if s == "sectorMigrationCopied" {
+		return atomic.AddInt64(&d.atomicCopies, -1) < 0
+	}
+	return s == "cleanWALFile"
+}
+
+// dependencyCrashMigration simulates an unclean shutdown while the commit that
+// contains the last moves of a migration is in progress. The commit is blocked
+// at the WAL rename until the test unblocks it. From then on, nothing reaches
+// the disk anymore: neither the WAL nor the settings are renamed and all
+// writes to the storage folders fail.
+type dependencyCrashMigration struct {
+	modules.ProductionDependencies
+
+	// atomicCopies is the number of copied sectors after which the next
+	// commit is blocked. atomicBlock is 1 once the next commit should be
+	// blocked and 2 once it was blocked. atomicCrashed is 1 after the crash.
+	atomicCopies  int64
+	atomicBlock   uint64
+	atomicCrashed uint64
+
+	blocked chan struct{}
+	unblock chan struct{}
+}
+
+// crashedFile is a storage folder file whose writes fail after the crash.
+type crashedFile struct {
+	d *dependencyCrashMigration
+	*os.File
+}
+
+// newDependencyCrashMigration creates a dependency that blocks the commit
+// following the given number of copied sectors.
+func newDependencyCrashMigration(copies int64) *dependencyCrashMigration {
+	return &dependencyCrashMigration{
+		atomicCopies: copies,
+		blocked:      make(chan struct{}),
+		unblock:      make(chan struct{}),
+	}
+}
+
+// wrap returns a file whose writes fail after the crash if it belongs to a
+// storage folder.
+func (d *dependencyCrashMigration) wrap(f *os.File) modules.File {
+	if !strings.Contains(f.Name(), "storageFolder") {
+		return f
+	}
+	return &crashedFile{d: d, File: f}
+}
+
+// CreateFile creates a file whose writes fail after the crash.
+func (d *dependencyCrashMigration) CreateFile(s string) (modules.File, error) {
+	f, err := os.Create(s)
+	if err != nil {
+		return nil, err
+	}
+	return d.wrap(f), nil
+}
+
+// OpenFile opens a file whose writes fail after the crash.
+func (d *dependencyCrashMigration) OpenFile(s string, flags int, perms os.FileMode) (modules.File, error) {
+	f, err := os.OpenFile(s, flags, perms)
+	if err != nil {
+		return nil, err
+	}
+	return d.wrap(f), nil
+}
+
+// Disrupt blocks the commit following the last copy of the migration, crashes
+// once it is unblocked and leaves the WAL behind at shutdown.
+func (d *dependencyCrashMigration) Disrupt(s string) bool {
+	switch s {
+	case "sectorMigrationCopied":
+		if atomic.AddInt64(&d.atomicCopies, -1) == 0 {
+			atomic.StoreUint64(&d.atomicBlock, 1)
+		}
+		return false
+	case "walRename":
+		if atomic.CompareAndSwapUint64(&d.atomicBlock, 1, 2) {
+			close(d.blocked)
+			<-d.unblock
+			atomic.StoreUint64(&d.atomicCrashed, 1)
+		}
+		return atomic.LoadUint64(&d.atomicCrashed) == 1
+	case "settingsSyncRename":
+		return atomic.LoadUint64(&d.atomicCrashed) == 1
+	}
+	return s == "cleanWALFile"
+}
+
+// WriteAt fails after the crash.
+func (f *crashedFile) WriteAt(b []byte, off int64) (int, error) {
+	if atomic.LoadUint64(&f.d.atomicCrashed) == 1 {
+		return 0, errors.New("contract manager crashed")
+	}
+	return f.File.WriteAt(b, off)
+}
+
+// addMigrationFolders adds two storage folders with the given number of
+// sectors of capacity and fills the first one with the given number of
+// sectors. It returns the indices of the folders and the added sectors.
+func (cmt *contractManagerTester) addMigrationFolders(sourceSectors, destSectors uint64, numSectors int) (uint16, uint16, []crypto.Hash, [][]byte, error) {
+	sourceDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	destDir := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	for _, dir := range []string{sourceDir, destDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return 0, 0, nil, nil, err
+		}
+	}
+	if err := cmt.cm.AddStorageFolder(sourceDir, modules.SectorSize*sourceSectors); err != nil {
+		return 0, 0, nil, nil, err
+	}
+	roots := make([]crypto.Hash, numSectors)
+	datas := make([][]byte, numSectors)
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+			return 0, 0, nil, nil, err
+		}
+	}
+	if err := cmt.cm.AddStorageFolder(destDir, modules.SectorSize*destSectors); err != nil {
+		return 0, 0, nil, nil, err
+	}
+	var source, dest uint16
+	for _, sf := range cmt.cm.StorageFolders() {
+		if sf.Path == sourceDir {
+			source = sf.Index
+		} else {
+			dest = sf.Index
+		}
+	}
+	return source, dest, roots, datas, nil
+}
+
+// checkMigration checks that every sector is stored exactly once, that the
+// source and destination folder store the expected number of sectors, and that
+// every sector can still be read.
+func (cmt *contractManagerTester) checkMigration(source, dest uint16, sourceSectors, destSectors uint64, roots []crypto.Hash, datas [][]byte) error {
+	cmt.cm.sectorMu.Lock()
+	numLocations := len(cmt.cm.sectorLocations)
+	counts := make(map[uint16]uint64)
+	for _, sl := range cmt.cm.sectorLocations {
+		counts[sl.storageFolder]++
+	}
+	for _, sf := range cmt.cm.storageFolders {
+		if used := uint64(len(usageSectors(sf.usage))); used != sf.sectors || used != counts[sf.index] {
+			cmt.cm.sectorMu.Unlock()
+			return fmt.Errorf("folder %v has %v sectors, %v used slots and %v locations", sf.index, sf.sectors, used, counts[sf.index])
+		}
+	}
+	cmt.cm.sectorMu.Unlock()
+	if numLocations != len(roots) {
+		return fmt.Errorf("expected %v sectors, got %v", len(roots), numLocations)
+	}
+	if counts[source] != sourceSectors || counts[dest] != destSectors {
+		return fmt.Errorf("expected %v and %v sectors in the folders, got %v and %v", sourceSectors, destSectors, counts[source], counts[dest])
+	}
+	for i, root := range roots {
+		data, err := cmt.cm.ReadSector(root)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(data, datas[i]) {
+			return fmt.Errorf("sector %v is corrupt", i)
+		}
+	}
+	return nil
+}
+
+// TestMoveSectors checks that MoveSectors moves sectors between storage
+// folders up to the requested amount of data and that the moves persist.
+func TestMoveSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	numSectors := 10
+	source, dest, roots, datas, err := cmt.addMigrationFolders(storageFolderGranularity, storageFolderGranularity, numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid migrations are rejected.
+	if _, err := cmt.cm.MoveSectors(source, source, 0); !errors.Contains(err, errSectorMigrationSameFolder) {
+		t.Fatal("expected errSectorMigrationSameFolder, got", err)
+	}
+	if _, err := cmt.cm.MoveSectors(source, dest+1, 0); !errors.Contains(err, errStorageFolderNotFound) {
+		t.Fatal("expected errStorageFolderNotFound, got", err)
+	}
+	if err := cmt.cm.SetStorageFolderReadOnly(dest, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmt.cm.MoveSectors(source, dest, 0); !errors.Contains(err, errSectorMigrationReadOnly) {
+		t.Fatal("expected errSectorMigrationReadOnly, got", err)
+	}
+	if err := cmt.cm.SetStorageFolderReadOnly(dest, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Move a limited amount of data, partial sectors aren't moved.
+	moved, err := cmt.cm.MoveSectors(source, dest, 3*modules.SectorSize+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 3 {
+		t.Fatal("expected 3 moved sectors, got", moved)
+	}
+	if err := cmt.checkMigration(source, dest, uint64(numSectors)-3, 3, roots, datas); err != nil {
+		t.Fatal(err)
+	}
+
+	// Move the remaining sectors.
+	moved, err = cmt.cm.MoveSectors(source, dest, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != uint64(numSectors)-3 {
+		t.Fatalf("expected %v moved sectors, got %v", numSectors-3, moved)
+	}
+	if err := cmt.checkMigration(source, dest, 0, uint64(numSectors), roots, datas); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm.sectorMu.Lock()
+	migration := cmt.cm.sectorMigration
+	cmt.cm.sectorMu.Unlock()
+	if migration != nil {
+		t.Fatal("migration wasn't finished")
+	}
+
+	// The moves persist across restarts.
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.checkMigration(source, dest, 0, uint64(numSectors), roots, datas); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMoveSectorsDestinationFull checks that MoveSectors stops once the
+// destination folder is full.
+func TestMoveSectorsDestinationFull(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	numSectors := storageFolderGranularity + 5
+	source, dest, roots, datas, err := cmt.addMigrationFolders(2*storageFolderGranularity, storageFolderGranularity, numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved, err := cmt.cm.MoveSectors(source, dest, 0)
+	if !errors.Contains(err, ErrDestinationFolderFull) {
+		t.Fatal("expected ErrDestinationFolderFull, got", err)
+	}
+	if moved != storageFolderGranularity {
+		t.Fatalf("expected %v moved sectors, got %v", storageFolderGranularity, moved)
+	}
+	if err := cmt.checkMigration(source, dest, uint64(numSectors)-storageFolderGranularity, storageFolderGranularity, roots, datas); err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed migration is finished, another one can be started.
+	if _, err := cmt.cm.MoveSectors(dest, source, modules.SectorSize); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMoveSectorsInterrupted simulates an unclean shutdown between copying a
+// sector to the destination folder and committing its new location. After a
+// restart, the migration is resumed and no sector is lost or double-counted.
+func TestMoveSectorsInterrupted(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := &dependencyInterruptMigration{atomicCopies: 4}
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	numSectors := 10
+	source, dest, roots, datas, err := cmt.addMigrationFolders(storageFolderGranularity, storageFolderGranularity, numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fifth sector is copied but its move is never committed.
+	moved, err := cmt.cm.MoveSectors(source, dest, 8*modules.SectorSize)
+	if !errors.Contains(err, errSectorMigrationInterrupted) {
+		t.Fatal("expected errSectorMigrationInterrupted, got", err)
+	}
+	if moved != 4 {
+		t.Fatal("expected 4 moved sectors, got", moved)
+	}
+	if _, err := cmt.cm.MoveSectors(source, dest, 0); !errors.Contains(err, ErrSectorMigrationInProgress) {
+		t.Fatal("expected ErrSectorMigrationInProgress, got", err)
+	}
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// After the restart, the migration is resumed and moves the remaining
+	// sectors of its budget.
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		cmt.cm.sectorMu.Lock()
+		defer cmt.cm.sectorMu.Unlock()
+		if cmt.cm.sectorMigration != nil {
+			return errors.New("migration wasn't finished")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.checkMigration(source, dest, uint64(numSectors)-8, 8, roots, datas); err != nil {
+		t.Fatal(err)
+	}
+
+	// The state is the same after another restart.
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.checkMigration(source, dest, uint64(numSectors)-8, 8, roots, datas); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMoveSectorsConcurrentAddSector adds sectors while a migration is running
+// and crashes the contract manager before the last moves of the migration are
+// committed. The old slots of the moved sectors must not be reused before the
+// moves are committed, otherwise the new sectors overwrite data that the
+// sectors still point to after the restart.
+func TestMoveSectorsConcurrentAddSector(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	numSectors := storageFolderGranularity
+	d := newDependencyCrashMigration(int64(numSectors))
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Fill the source folder, every free slot in it is freed by the migration.
+	source, dest, roots, datas, err := cmt.addMigrationFolders(uint64(numSectors), 2*uint64(numSectors), numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Move all sectors while sectors are added concurrently.
+	moveErr := make(chan error, 1)
+	go func() {
+		_, err := cmt.cm.MoveSectors(source, dest, 0)
+		moveErr <- err
+	}()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// Adding sectors fails after the crash.
+				_ = cmt.cm.AddSector(randSector())
+			}
+		}()
+	}
+
+	// Give the sectors that are being added time to be written before
+	// crashing.
+	select {
+	case <-d.blocked:
+	case <-time.After(time.Minute):
+		t.Fatal("commit of the migration wasn't blocked")
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(d.unblock)
+	if err := <-moveErr; err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// After the restart, the migration is resumed and every sector can still
+	// be read.
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		cmt.cm.sectorMu.Lock()
+		defer cmt.cm.sectorMu.Unlock()
+		if cmt.cm.sectorMigration != nil {
+			return errors.New("migration wasn't finished")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, root := range roots {
+		data, err := cmt.cm.ReadSector(root)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("sector was overwritten", i)
+		}
+	}
+}
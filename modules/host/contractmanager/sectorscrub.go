@@ -0,0 +1,336 @@
+package contractmanager
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// sectorScrubber holds the settings of the background scrub. The scrub reads
+// the sectors of one storage folder after the other and verifies that their
+// data still hashes to the sector root that the sector was stored under. The
+// position of the scrub in every storage folder is persisted with the
+// storage folder, so that the scrub resumes after a restart.
+type sectorScrubber struct {
+	settings modules.StorageScrubSettings
+
+	// staticWake is signaled whenever the settings change, so that the scrub
+	// picks them up right away.
+	staticWake chan struct{}
+	mu         sync.Mutex
+}
+
+// newSectorScrubber creates a scrubber that is disabled until it is configured.
+func newSectorScrubber() *sectorScrubber {
+	return &sectorScrubber{
+		staticWake: make(chan struct{}, 1),
+	}
+}
+
+// managedMarkCorrupt returns whether reads of corrupt sectors should fail.
+func (s *sectorScrubber) managedMarkCorrupt() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings.MarkCorrupt
+}
+
+// managedSettings returns the current settings of the scrub.
+func (s *sectorScrubber) managedSettings() modules.StorageScrubSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings
+}
+
+// readSectorID reads the id of the sector at the given index from the metadata
+// file of a storage folder.
+func readSectorID(f modules.File, sectorIndex uint32) (id sectorID, err error) {
+	_, err = f.ReadAt(id[:], sectorMetadataDiskSize*int64(sectorIndex))
+	if err != nil {
+		return sectorID{}, build.ExtendErr("unable to read sector id", err)
+	}
+	return id, nil
+}
+
+// isCorrupt returns whether the sector with the given id that is stored at the
+// given index was found to be corrupt. The caller must hold the contract
+// manager's sectorMu.
+func (sf *storageFolder) isCorrupt(sectorIndex uint32, id sectorID) bool {
+	corruptID, exists := sf.corruptSectors[sectorIndex]
+	return exists && corruptID == id
+}
+
+// markCorrupt records that the sector with the given id that is stored at the
+// given index is corrupt. The caller must hold the contract manager's
+// sectorMu.
+func (sf *storageFolder) markCorrupt(sectorIndex uint32, id sectorID) {
+	if sf.corruptSectors == nil {
+		sf.corruptSectors = make(map[uint32]sectorID)
+	}
+	sf.corruptSectors[sectorIndex] = id
+}
+
+// nextScrubIndex returns the index of the first sector in use at or after the
+// scrub position of the storage folder. The caller must hold the contract
+// manager's sectorMu.
+func (sf *storageFolder) nextScrubIndex() (uint32, bool) {
+	numSectors := uint64(len(sf.usage)) * storageFolderGranularity
+	for i := uint64(sf.scrubPosition); i < numSectors; i++ {
+		usageElement := sf.usage[i/storageFolderGranularity]
+		if usageElement == 0 {
+			// Skip to the end of the empty usage element.
+			i += storageFolderGranularity - 1 - i%storageFolderGranularity
+			continue
+		}
+		if usageElement&(1<<(i%storageFolderGranularity)) != 0 {
+			return uint32(i), true
+		}
+	}
+	return 0, false
+}
+
+// numCorruptSectors returns the number of corrupt sectors of the storage folder
+// that are still stored where they were found to be corrupt. The caller must
+// hold the contract manager's sectorMu.
+func (cm *ContractManager) numCorruptSectors(sf *storageFolder) uint64 {
+	var n uint64
+	for index, id := range sf.corruptSectors {
+		sl, exists := cm.sectorLocations[id]
+		if exists && sl.storageFolder == sf.index && sl.index == index {
+			n++
+		}
+	}
+	return n
+}
+
+// pruneCorruptSectors forgets about the corrupt sectors of the storage folder
+// that were removed or moved since they were found to be corrupt. The caller
+// must hold the contract manager's sectorMu.
+func (cm *ContractManager) pruneCorruptSectors(sf *storageFolder) {
+	for index, id := range sf.corruptSectors {
+		sl, exists := cm.sectorLocations[id]
+		if !exists || sl.storageFolder != sf.index || sl.index != index {
+			delete(sf.corruptSectors, index)
+		}
+	}
+}
+
+// managedUpdateCorruptSectorsAlert registers an alert naming the storage folder
+// and its number of corrupt sectors if there are any, and unregisters it
+// otherwise.
+func (cm *ContractManager) managedUpdateCorruptSectorsAlert(sf *storageFolder) {
+	cm.sectorMu.Lock()
+	numCorrupt := cm.numCorruptSectors(sf)
+	path := sf.path
+	cm.sectorMu.Unlock()
+
+	id := modules.AlertIDHostCorruptSectors(path)
+	if numCorrupt == 0 {
+		cm.staticAlerter.UnregisterAlert(id)
+		return
+	}
+	cause := fmt.Sprintf("%v corrupt sectors in storage folder %v", numCorrupt, path)
+	cm.staticAlerter.RegisterAlert(id, AlertMSGCorruptSectors, cause, modules.SeverityError)
+}
+
+// managedUpdateCorruptSectorsAlerts updates the corrupt sectors alerts of all
+// storage folders that are known to have corrupt sectors.
+func (cm *ContractManager) managedUpdateCorruptSectorsAlerts() {
+	cm.sectorMu.Lock()
+	var sfs []*storageFolder
+	for _, sf := range cm.storageFolders {
+		if len(sf.corruptSectors) > 0 {
+			sfs = append(sfs, sf)
+		}
+	}
+	cm.sectorMu.Unlock()
+	for _, sf := range sfs {
+		cm.managedUpdateCorruptSectorsAlert(sf)
+	}
+}
+
+// managedNextScrubFolder returns the storage folder that should be scrubbed
+// next. A storage folder with an unfinished scrub takes precedence over the
+// storage folder whose last scrub completed the longest time ago. If no
+// storage folder is due, the amount of time until the next one is due is
+// returned.
+func (cm *ContractManager) managedNextScrubFolder(interval time.Duration, now time.Time) (*storageFolder, time.Duration) {
+	cm.sectorMu.Lock()
+	defer cm.sectorMu.Unlock()
+
+	var next *storageFolder
+	wait := scrubIdleInterval
+	for _, sf := range cm.storageFolders {
+		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+			continue
+		}
+		if sf.scrubPosition > 0 {
+			if next == nil || next.scrubPosition == 0 || sf.index < next.index {
+				next = sf
+			}
+			continue
+		}
+		if due := sf.scrubCompleted.Add(interval); due.After(now) {
+			if d := due.Sub(now); d < wait {
+				wait = d
+			}
+			continue
+		}
+		if next == nil || (next.scrubPosition == 0 && sf.scrubCompleted.Before(next.scrubCompleted)) {
+			next = sf
+		}
+	}
+	return next, wait
+}
+
+// managedScrubSector verifies the next sector of the storage folder and
+// advances the folder's scrub position past it. Once there are no sectors left,
+// the scrub of the storage folder is completed. It returns the number of bytes
+// that were read.
+func (cm *ContractManager) managedScrubSector(sf *storageFolder) uint64 {
+	// Find the next sector that is in use.
+	cm.sectorMu.Lock()
+	index, exists := sf.nextScrubIndex()
+	if !exists {
+		cm.pruneCorruptSectors(sf)
+		numCorrupt := len(sf.corruptSectors)
+		sf.scrubPosition = 0
+		sf.scrubCompleted = time.Now()
+		cm.sectorMu.Unlock()
+		cm.log.Printf("Completed the scrub of storage folder %v, %v sectors are corrupt\n", sf.path, numCorrupt)
+		cm.managedUpdateCorruptSectorsAlert(sf)
+		return 0
+	}
+	sf.scrubPosition = index + 1
+	cm.sectorMu.Unlock()
+
+	// Look up which sector is stored at the index.
+	id, err := readSectorID(sf.metadataFile, index)
+	if err != nil {
+		cm.managedRecordRead(sf, err)
+		cm.log.Printf("ERROR: unable to read sector metadata during the scrub of folder %v: %v\n", sf.path, err)
+		return 0
+	}
+
+	// Lock the sector and make sure that it wasn't moved or removed in the
+	// meantime.
+	cm.wal.managedLockSector(id)
+	defer cm.wal.managedUnlockSector(id)
+	cm.sectorMu.Lock()
+	sl, exists := cm.sectorLocations[id]
+	cm.sectorMu.Unlock()
+	if !exists || sl.storageFolder != sf.index || sl.index != index {
+		return 0
+	}
+
+	// Read the sector and verify it against its root.
+	data, err := readSector(sf.sectorFile, index)
+	if err != nil {
+		cm.managedRecordRead(sf, err)
+		cm.log.Printf("ERROR: unable to read sector during the scrub of folder %v: %v\n", sf.path, err)
+		return 0
+	}
+	cm.managedRecordRead(sf, nil)
+	corrupt := cm.managedSectorID(crypto.MerkleRoot(data)) != id
+
+	cm.sectorMu.Lock()
+	sf.scrubbedSectors++
+	wasCorrupt := sf.isCorrupt(index, id)
+	if corrupt {
+		sf.markCorrupt(index, id)
+	} else {
+		delete(sf.corruptSectors, index)
+	}
+	cm.sectorMu.Unlock()
+	if corrupt && !wasCorrupt {
+		cm.log.Printf("WARN: sector %v of storage folder %v is corrupt\n", index, sf.path)
+	}
+	if corrupt != wasCorrupt {
+		cm.managedUpdateCorruptSectorsAlert(sf)
+	}
+	return uint64(len(data))
+}
+
+// managedScrubStep scrubs the next sector of the storage folder. The storage
+// folder is locked for reading the same way it is when sectors are written to
+// it. If the storage folder is busy with an operation like a resize, false is
+// returned and the scrub is postponed.
+func (cm *ContractManager) managedScrubStep(sf *storageFolder) (uint64, bool) {
+	err := cm.tg.Add()
+	if err != nil {
+		return 0, false
+	}
+	defer cm.tg.Done()
+	if !sf.mu.TryRLock() {
+		return 0, false
+	}
+	defer sf.mu.RUnlock()
+	if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+		return 0, false
+	}
+	return cm.managedScrubSector(sf), true
+}
+
+// managedScrub scrubs the next sector of the storage folder that is due and
+// returns how long to wait before scrubbing the next one to stay below the
+// configured rate.
+func (cm *ContractManager) managedScrub(settings modules.StorageScrubSettings) time.Duration {
+	sf, wait := cm.managedNextScrubFolder(settings.Interval, time.Now())
+	if sf == nil {
+		return wait
+	}
+	start := time.Now()
+	n, ok := cm.managedScrubStep(sf)
+	if !ok {
+		return scrubIdleInterval
+	}
+	if settings.MaxBytesPerSecond == 0 {
+		return 0
+	}
+	return time.Duration(n*uint64(time.Second)/settings.MaxBytesPerSecond) - time.Since(start)
+}
+
+// threadedScrubSectors is the background loop that scrubs the storage folders
+// while the scrub is enabled.
+func (cm *ContractManager) threadedScrubSectors() {
+	for {
+		// Without a timer the loop waits until the settings change.
+		var timer <-chan time.Time
+		if settings := cm.staticScrubber.managedSettings(); settings.Enabled {
+			timer = time.After(cm.managedScrub(settings))
+		}
+		select {
+		case <-cm.tg.StopChan():
+			return
+		case <-cm.staticScrubber.staticWake:
+		case <-timer:
+		}
+	}
+}
+
+// SetScrubSettings updates the settings of the background scrub that verifies
+// the integrity of the stored sectors. The scrub reads every sector at a rate
+// of at most MaxBytesPerSecond and checks it against its sector root. Corrupt
+// sectors are counted per storage folder and reported through an alert. If
+// MarkCorrupt is set, reading a corrupt sector fails with ErrSectorCorrupt
+// instead of returning the corrupt data.
+func (cm *ContractManager) SetScrubSettings(settings modules.StorageScrubSettings) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	cm.staticScrubber.mu.Lock()
+	cm.staticScrubber.settings = settings
+	cm.staticScrubber.mu.Unlock()
+	select {
+	case cm.staticScrubber.staticWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package contractmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// corruptSector flips a byte of the sector with the given root on disk.
+func (cmt *contractManagerTester) corruptSector(root crypto.Hash) error {
+	id := cmt.cm.managedSectorID(root)
+	cmt.cm.sectorMu.Lock()
+	sl, exists := cmt.cm.sectorLocations[id]
+	sf, exists2 := cmt.cm.storageFolders[sl.storageFolder]
+	cmt.cm.sectorMu.Unlock()
+	if !exists || !exists2 {
+		return errors.New("sector not found")
+	}
+
+	f, err := os.OpenFile(filepath.Join(sf.path, sectorFile), os.O_RDWR, 0700)
+	if err != nil {
+		return err
+	}
+	offset := int64(sl.index) * int64(modules.SectorSize)
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, offset); err != nil {
+		return errors.Compose(err, f.Close())
+	}
+	b[0]++
+	if _, err := f.WriteAt(b, offset); err != nil {
+		return errors.Compose(err, f.Close())
+	}
+	return f.Close()
+}
+
+// TestSectorScrub checks that the background scrub detects a sector that was
+// corrupted on disk, reports it and optionally fails reads of it.
+func TestSectorScrub(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a small storage folder and store some sectors in it.
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderOne, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderOne, modules.SectorSize*storageFolderGranularity); err != nil {
+		t.Fatal(err)
+	}
+	roots := make([]crypto.Hash, 5)
+	datas := make([][]byte, len(roots))
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Corrupt one of the sectors and start the scrub.
+	corruptRoot := roots[2]
+	if err := cmt.corruptSector(corruptRoot); err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.SetScrubSettings(modules.StorageScrubSettings{
+		Enabled:  true,
+		Interval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The scrub should verify all sectors and find the corrupt one.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		sf := cmt.cm.StorageFolders()[0]
+		if sf.ScrubbedSectors != uint64(len(roots)) {
+			return fmt.Errorf("expected %v scrubbed sectors, got %v", len(roots), sf.ScrubbedSectors)
+		}
+		if sf.CorruptSectors != 1 {
+			return fmt.Errorf("expected 1 corrupt sector, got %v", sf.CorruptSectors)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// hasAlert returns whether the corrupt sectors alert of the storage folder
+	// is registered with the right cause.
+	hasAlert := func() bool {
+		_, errs, _, _ := cmt.cm.Alerts()
+		for _, a := range errs {
+			if a.ID == modules.AlertIDHostCorruptSectors(storageFolderOne) {
+				return a.Severity == modules.SeverityError && strings.Contains(a.Cause, "1 corrupt sectors")
+			}
+		}
+		return false
+	}
+	if !hasAlert() {
+		t.Fatal("corrupt sectors alert should be registered")
+	}
+
+	// Without marking corrupt sectors, the corrupt data is still served.
+	data, err := cmt.cm.ReadSector(corruptRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(data, datas[2]) {
+		t.Fatal("sector should be corrupt")
+	}
+
+	// Once corrupt sectors are marked, reading the corrupt sector fails while
+	// the other sectors can still be read.
+	err = cmt.cm.SetScrubSettings(modules.StorageScrubSettings{
+		Enabled:     true,
+		Interval:    time.Hour,
+		MarkCorrupt: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmt.cm.ReadSector(corruptRoot); !errors.Contains(err, ErrSectorCorrupt) {
+		t.Fatal("expected ErrSectorCorrupt, got", err)
+	}
+	if _, err := cmt.cm.ReadPartialSector(corruptRoot, 0, 64); !errors.Contains(err, ErrSectorCorrupt) {
+		t.Fatal("expected ErrSectorCorrupt, got", err)
+	}
+	for i, root := range roots {
+		if root == corruptRoot {
+			continue
+		}
+		data, err := cmt.cm.ReadSector(root)
+		if err != nil || !bytes.Equal(data, datas[i]) {
+			t.Fatal("sector is missing or corrupt", i, err)
+		}
+	}
+
+	// The results of the scrub should persist across restarts.
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := cmt.cm.StorageFolders()[0]
+	if sf.ScrubbedSectors != uint64(len(roots)) || sf.CorruptSectors != 1 {
+		t.Fatal("scrub results weren't persisted", sf.ScrubbedSectors, sf.CorruptSectors)
+	}
+	if !hasAlert() {
+		t.Fatal("corrupt sectors alert should be registered after a restart")
+	}
+	err = cmt.cm.SetScrubSettings(modules.StorageScrubSettings{
+		Interval:    time.Hour,
+		MarkCorrupt: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmt.cm.ReadSector(corruptRoot); !errors.Contains(err, ErrSectorCorrupt) {
+		t.Fatal("expected ErrSectorCorrupt, got", err)
+	}
+
+	// Deleting the corrupt sector clears it from the folder's count.
+	if err := cmt.cm.DeleteSector(corruptRoot); err != nil {
+		t.Fatal(err)
+	}
+	if sf := cmt.cm.StorageFolders()[0]; sf.CorruptSectors != 0 {
+		t.Fatal("deleted sector is still counted as corrupt", sf.CorruptSectors)
+	}
+}
+
+// TestSectorScrubResume checks that an interrupted scrub resumes at the
+// persisted position after a restart.
+func TestSectorScrubResume(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderOne, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderOne, modules.SectorSize*storageFolderGranularity); err != nil {
+		t.Fatal(err)
+	}
+	numSectors := 5
+	for i := 0; i < numSectors; i++ {
+		root, data := randSector()
+		if err := cmt.cm.AddSector(root, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// folder returns the only storage folder of the contract manager.
+	folder := func() *storageFolder {
+		cmt.cm.sectorMu.Lock()
+		defer cmt.cm.sectorMu.Unlock()
+		for _, sf := range cmt.cm.storageFolders {
+			return sf
+		}
+		t.Fatal("storage folder not found")
+		return nil
+	}
+
+	// Scrub two sectors by hand, the background scrub is disabled.
+	sf := folder()
+	for i := 0; i < 2; i++ {
+		if n, ok := cmt.cm.managedScrubStep(sf); !ok || n != modules.SectorSize {
+			t.Fatal("scrub step failed", n, ok)
+		}
+	}
+	cmt.cm.sectorMu.Lock()
+	position := sf.scrubPosition
+	cmt.cm.sectorMu.Unlock()
+	if position == 0 {
+		t.Fatal("scrub position wasn't advanced")
+	}
+
+	// Restart the contract manager, the scrub should continue where it left
+	// off.
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf = folder()
+	cmt.cm.sectorMu.Lock()
+	restoredPosition := sf.scrubPosition
+	cmt.cm.sectorMu.Unlock()
+	if restoredPosition != position {
+		t.Fatalf("expected scrub position %v, got %v", position, restoredPosition)
+	}
+	if next, _ := cmt.cm.managedNextScrubFolder(time.Hour, time.Now()); next != sf {
+		t.Fatal("storage folder with an unfinished scrub should be scrubbed next")
+	}
+
+	// Finish the scrub. Only the remaining sectors should be scrubbed.
+	for i := 0; ; i++ {
+		n, ok := cmt.cm.managedScrubStep(sf)
+		if !ok {
+			t.Fatal("scrub step failed")
+		}
+		if n == 0 {
+			break
+		}
+		if i > numSectors {
+			t.Fatal("scrub didn't complete")
+		}
+	}
+	cmt.cm.sectorMu.Lock()
+	scrubbed, completed := sf.scrubbedSectors, sf.scrubCompleted
+	cmt.cm.sectorMu.Unlock()
+	if scrubbed != uint64(numSectors) {
+		t.Fatalf("expected %v scrubbed sectors, got %v", numSectors, scrubbed)
+	}
+	if completed.IsZero() {
+		t.Fatal("scrub wasn't completed")
+	}
+	if next, _ := cmt.cm.managedNextScrubFolder(time.Hour, time.Now()); next != nil {
+		t.Fatal("storage folder shouldn't be due for a scrub")
+	}
+}
@@ -23,9 +23,14 @@ func (wal *writeAheadLog) commitUpdateSector(su sectorUpdate) {
 		return
 	}
 
-	// If the sector is being cleaned from disk, unset the usage flag.
+	// If the sector is being cleaned from disk, unset the usage flag. The
+	// sector locations are kept up to date for the case that they were loaded
+	// from a snapshot, otherwise they are rebuilt after the recovery.
 	if su.Count == 0 {
 		sf.clearUsage(su.Index)
+		if sl, exists := wal.cm.sectorLocations[su.ID]; exists && sl.storageFolder == su.Folder && sl.index == su.Index {
+			delete(wal.cm.sectorLocations, su.ID)
+		}
 		return
 	}
 
@@ -37,6 +42,11 @@ func (wal *writeAheadLog) commitUpdateSector(su sectorUpdate) {
 		return
 	}
 	sf.setUsage(su.Index)
+	wal.cm.sectorLocations[su.ID] = sectorLocation{
+		index:         su.Index,
+		storageFolder: su.Folder,
+		count:         su.Count,
+	}
 }
 
 // managedAddPhysicalSector is a WAL operation to add a physical sector to the
@@ -52,7 +62,7 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte) err
 	// this sector. Keep trying new storage folders if some return
 	// errors during disk operations.
 	wal.mu.Lock()
-	storageFolders := wal.cm.availableStorageFolders()
+	storageFolders := wal.cm.writableStorageFolders()
 	wal.mu.Unlock()
 	var syncChan chan struct{}
 	for len(storageFolders) >= 1 {
@@ -100,7 +110,7 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte) err
 			err = writeSector(sf.sectorFile, sectorIndex, data)
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", sf.path, err)
-				atomic.AddUint64(&sf.atomicFailedWrites, 1)
+				wal.cm.managedRecordWrite(sf, err)
 				wal.mu.Lock()
 				wal.cm.sectorMu.Lock()
 				sf.clearUsage(sectorIndex)
@@ -121,7 +131,6 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte) err
 			err = wal.writeSectorMetadata(sf, su)
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector metadata for folder %v: %v\n", sf.path, err)
-				atomic.AddUint64(&sf.atomicFailedWrites, 1)
 				wal.mu.Lock()
 				wal.cm.sectorMu.Lock()
 				sf.clearUsage(sectorIndex)
@@ -509,14 +518,14 @@ func (wal *writeAheadLog) writeSectorMetadata(sf *storageFolder, su sectorUpdate
 	err := writeSectorMetadata(sf.metadataFile, su.Index, su.ID, count)
 	if err != nil {
 		wal.cm.log.Printf("ERROR: unable to write sector metadata to folder %v when adding sector: %v\n", su.Folder, err)
-		atomic.AddUint64(&sf.atomicFailedWrites, 1)
+		wal.cm.managedRecordWrite(sf, err)
 		return err
 	}
 
 	// We should only ever need to update the overflow file when the count has
 	// reached the maximum.
 	if count != math.MaxUint16 {
-		atomic.AddUint64(&sf.atomicSuccessfulWrites, 1)
+		wal.cm.managedRecordWrite(sf, nil)
 		return nil
 	}
 
@@ -530,11 +539,11 @@ func (wal *writeAheadLog) writeSectorMetadata(sf *storageFolder, su sectorUpdate
 		if err != nil {
 			err = errors.AddContext(err, "ERROR: unable to set overflow")
 			wal.cm.log.Printf(err.Error())
-			atomic.AddUint64(&sf.atomicFailedWrites, 1)
+			wal.cm.managedRecordWrite(sf, err)
 			return err
 		}
 	}
-	atomic.AddUint64(&sf.atomicSuccessfulWrites, 1)
+	wal.cm.managedRecordWrite(sf, nil)
 	return nil
 }
 
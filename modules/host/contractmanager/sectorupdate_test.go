@@ -17,7 +17,9 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"gitlab.com/NebulousLabs/fastrand"
 
@@ -2345,3 +2347,171 @@ func TestAddVirtualSectorOverflow(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// dependencyBlockWALRename is a mocked dependency that blocks the sync loop
+// right before the WAL is renamed once it was triggered.
+type dependencyBlockWALRename struct {
+	modules.ProductionDependencies
+	triggered uint64
+	blocked   chan struct{}
+	unblock   chan struct{}
+}
+
+// Disrupt blocks the first rename of the WAL after the dependency was
+// triggered until it is unblocked.
+func (d *dependencyBlockWALRename) Disrupt(s string) bool {
+	if s == "walRename" && atomic.CompareAndSwapUint64(&d.triggered, 1, 0) {
+		close(d.blocked)
+		<-d.unblock
+	}
+	return false
+}
+
+// TestAddSectorWaitsForCommit checks that AddSector doesn't return before the
+// WAL that contains the sector was committed to disk.
+func TestAddSectorWaitsForCommit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := &dependencyBlockWALRename{
+		blocked: make(chan struct{}),
+		unblock: make(chan struct{}),
+	}
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a batch of sectors while the commit is blocked before the WAL is
+	// renamed.
+	atomic.StoreUint64(&d.triggered, 1)
+	numSectors := 8
+	done := make(chan error, numSectors)
+	for i := 0; i < numSectors; i++ {
+		go func() {
+			root, data := randSector()
+			done <- cmt.cm.AddSector(root, data)
+		}()
+	}
+	<-d.blocked
+	select {
+	case err := <-done:
+		t.Fatal("AddSector returned before the WAL was committed", err)
+	case <-time.After(maxSyncWindow):
+	}
+
+	// Once the commit completes, all calls return.
+	close(d.unblock)
+	for i := 0; i < numSectors; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestAddSectorBatchRecovery checks that an unclean shutdown in the middle of
+// committing a batch of sectors leaves the contract manager consistent. The
+// sectors of the interrupted batch are lost, while the sectors of the
+// previous batch, which were acknowledged, survive.
+func TestAddSectorBatchRecovery(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := new(dependencyNoSettingsSave)
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity); err != nil {
+		t.Fatal(err)
+	}
+
+	// addBatch adds the given number of sectors concurrently.
+	addBatch := func(n int) ([]crypto.Hash, [][]byte) {
+		roots := make([]crypto.Hash, n)
+		datas := make([][]byte, n)
+		var wg sync.WaitGroup
+		for i := range roots {
+			roots[i], datas[i] = randSector()
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+					t.Error(err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		return roots, datas
+	}
+
+	// Add a batch of sectors that is acknowledged, then interrupt the commit
+	// of the next batch by preventing the WAL and the settings from being
+	// saved.
+	committedRoots, committedDatas := addBatch(8)
+	d.mu.Lock()
+	d.triggered = true
+	d.mu.Unlock()
+	lostRoots, lostDatas := addBatch(8)
+
+	// Restart the contract manager.
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The acknowledged sectors survived, the interrupted ones were rolled
+	// back.
+	for i, root := range committedRoots {
+		data, err := cmt.cm.ReadSector(root)
+		if err != nil || !bytes.Equal(data, committedDatas[i]) {
+			t.Fatal("acknowledged sector is missing or corrupt", i, err)
+		}
+	}
+	for _, root := range lostRoots {
+		if cmt.cm.HasSector(root) {
+			t.Fatal("sector of the interrupted batch shouldn't exist")
+		}
+	}
+	sfs := cmt.cm.StorageFolders()
+	if len(sfs) != 1 {
+		t.Fatal("expected one storage folder, got", len(sfs))
+	}
+	if used := (sfs[0].Capacity - sfs[0].CapacityRemaining) / modules.SectorSize; used != uint64(len(committedRoots)) {
+		t.Fatalf("expected %v used sectors, got %v", len(committedRoots), used)
+	}
+	if len(cmt.cm.sectorLocations) != len(committedRoots) {
+		t.Fatalf("expected %v sector locations, got %v", len(committedRoots), len(cmt.cm.sectorLocations))
+	}
+
+	// The lost sectors can be added again.
+	for i, root := range lostRoots {
+		if err := cmt.cm.AddSector(root, lostDatas[i]); err != nil {
+			t.Fatal(err)
+		}
+		data, err := cmt.cm.ReadSector(root)
+		if err != nil || !bytes.Equal(data, lostDatas[i]) {
+			t.Fatal("sector is missing or corrupt", i, err)
+		}
+	}
+}
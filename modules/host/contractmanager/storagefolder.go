@@ -95,6 +95,12 @@ type storageFolder struct {
 	// an error if it is queried.
 	atomicUnavailable uint64 // uint64 for alignment
 
+	// Atomic bool indicating whether or not the storage folder is quarantined
+	// because its disk returned too many errors. No new sectors are placed in
+	// a quarantined storage folder, but reads of its sectors are still
+	// attempted.
+	atomicQuarantined uint64
+
 	// The index, path, and usage are all saved directly to disk.
 	index uint16
 	path  string
@@ -110,6 +116,30 @@ type storageFolder struct {
 	availableSectors map[sectorID]uint32
 	sectors          uint64
 
+	// readOnly indicates that no new sectors are placed in the storage
+	// folder. The field is protected by the contract manager's sectorMu.
+	readOnly bool
+
+	// resize tracks the ongoing resize of the storage folder. It is nil if
+	// the storage folder isn't being resized. The field is protected by the
+	// contract manager's sectorMu.
+	resize *storageFolderResize
+
+	// The state of the background scrub of the storage folder. scrubPosition
+	// is the sector index at which the ongoing scrub continues and
+	// scrubCompleted the time at which the last scrub completed.
+	// corruptSectors maps the indices of the sectors that failed the
+	// verification to the sectors that were stored there at the time. The
+	// fields are protected by the contract manager's sectorMu.
+	corruptSectors  map[uint32]sectorID
+	scrubCompleted  time.Time
+	scrubPosition   uint32
+	scrubbedSectors uint64
+
+	// health tracks the recent reads and writes of the storage folder to
+	// detect a failing disk.
+	health storageFolderHealth
+
 	// An open file handle is kept so that writes can easily be made to the
 	// storage folder without needing to grab a new file handle. This also
 	// makes it easy to do delayed-syncing.
@@ -279,15 +309,16 @@ func (sf *storageFolder) setUsage(sectorIndex uint32) {
 	}
 }
 
-// availableStorageFolders returns the contract manager's storage folders as a
-// slice, excluding any unavailable storeage folders.
-func (cm *ContractManager) availableStorageFolders() []*storageFolder {
+// writableStorageFolders returns the contract manager's storage folders that
+// new sectors can be placed in as a slice, excluding any unavailable,
+// quarantined or read-only storage folders.
+func (cm *ContractManager) writableStorageFolders() []*storageFolder {
 	sfs := make([]*storageFolder, 0)
 	cm.sectorMu.Lock()
 	defer cm.sectorMu.Unlock()
 	for _, sf := range cm.storageFolders {
-		// Skip unavailable storage folders.
-		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+		// Skip unavailable, quarantined and read-only storage folders.
+		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 || atomic.LoadUint64(&sf.atomicQuarantined) == 1 || sf.readOnly {
 			continue
 		}
 		sfs = append(sfs, sf)
@@ -373,6 +404,7 @@ func (cm *ContractManager) ResetStorageFolderHealth(index uint16) error {
 	atomic.StoreUint64(&sf.atomicFailedWrites, 0)
 	atomic.StoreUint64(&sf.atomicSuccessfulReads, 0)
 	atomic.StoreUint64(&sf.atomicSuccessfulWrites, 0)
+	sf.health.managedReset()
 	return nil
 }
 
@@ -380,6 +412,8 @@ func (cm *ContractManager) ResetStorageFolderHealth(index uint16) error {
 // necessary. The resize operation will stop and return an error if any of the
 // sector move operations fail. If the force flag is set to true, the resize
 // operation will continue through failures, meaning that data will be lost.
+// The progress of the resize is reported by StorageFolders and the resize can
+// be cancelled with CancelStorageFolderResize.
 func (cm *ContractManager) ResizeStorageFolder(index uint16, newSize uint64, force bool) error {
 	err := cm.tg.Add()
 	if err != nil {
@@ -406,22 +440,74 @@ func (cm *ContractManager) ResizeStorageFolder(index uint16, newSize uint64, for
 		return ErrNoResize
 	}
 
+	// Register the resize so that its progress is reported and it can be
+	// cancelled.
+	resize := newStorageFolderResize()
+	cm.sectorMu.Lock()
+	if sf.resize != nil {
+		cm.sectorMu.Unlock()
+		return ErrResizeInProgress
+	}
+	sf.resize = resize
+	cm.sectorMu.Unlock()
+	defer func() {
+		cm.sectorMu.Lock()
+		sf.resize = nil
+		cm.sectorMu.Unlock()
+		atomic.StoreUint64(&sf.atomicProgressNumerator, 0)
+		atomic.StoreUint64(&sf.atomicProgressDenominator, 0)
+	}()
+
 	// create a unique alert ID per storage folder resize and unregister it after completion.
 	alertID := modules.AlertID("cm-resize-folder-" + hex.EncodeToString(fastrand.Bytes(12)))
 	defer cm.staticAlerter.UnregisterAlert(alertID)
 
-	cm.staticAlerter.RegisterAlert(alertID,
-		fmt.Sprintf("Resizing folder %s from %s to %s",
-			sf.path,
-			modules.FilesizeUnits(uint64(len(sf.usage))*64*modules.SectorSize),
-			modules.FilesizeUnits(newSize)),
-		"folder op", modules.SeverityInfo)
+	msg := fmt.Sprintf("Resizing folder %s from %s to %s",
+		sf.path,
+		modules.FilesizeUnits(uint64(len(sf.usage))*64*modules.SectorSize),
+		modules.FilesizeUnits(newSize))
+	cm.staticAlerter.RegisterAlert(alertID, msg, "folder op", modules.SeverityInfo)
+
+	// Keep the alert updated with the progress of the resize. The update
+	// thread is stopped before the alert is unregistered.
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		cm.threadedUpdateFolderOpAlert(alertID, msg, sf, stop)
+		close(stopped)
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
 
 	newSectorCount := uint32(newSize / modules.SectorSize)
 	if oldSize > newSize {
-		return cm.wal.shrinkStorageFolder(index, newSectorCount, force)
+		return cm.wal.shrinkStorageFolder(index, newSectorCount, force, resize)
+	}
+	return cm.wal.growStorageFolder(index, newSectorCount, resize)
+}
+
+// threadedUpdateFolderOpAlert periodically updates the alert with the given id
+// with the progress of the operation that is performed on the storage folder,
+// until stop is closed.
+func (cm *ContractManager) threadedUpdateFolderOpAlert(id modules.AlertID, msg string, sf *storageFolder, stop <-chan struct{}) {
+	ticker := time.NewTicker(folderOpAlertInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		numerator := atomic.LoadUint64(&sf.atomicProgressNumerator)
+		denominator := atomic.LoadUint64(&sf.atomicProgressDenominator)
+		if denominator == 0 {
+			continue
+		}
+		progressMsg := fmt.Sprintf("%s, %v%% complete", msg, numerator*100/denominator)
+		cm.staticAlerter.RegisterAlert(id, progressMsg, "folder op", modules.SeverityInfo)
 	}
-	return cm.wal.growStorageFolder(index, newSectorCount)
 }
 
 // StorageFolders will return a list of storage folders in the host, each
@@ -454,6 +540,15 @@ func (cm *ContractManager) StorageFolders() []modules.StorageFolderMetadata {
 			CapacityRemaining: ((64 * uint64(len(sf.usage))) - sf.sectors) * modules.SectorSize,
 			Index:             sf.index,
 			Path:              sf.path,
+			Quarantined:       atomic.LoadUint64(&sf.atomicQuarantined) == 1,
+			ReadOnly:          sf.readOnly,
+
+			ScrubbedSectors: sf.scrubbedSectors,
+			CorruptSectors:  cm.numCorruptSectors(sf),
+		}
+		if sf.resize != nil {
+			progress := sf.resize.progress(sf, time.Now())
+			sfm.ResizeProgress = &progress
 		}
 
 		// Set some of the values to extreme numbers if the storage folder is
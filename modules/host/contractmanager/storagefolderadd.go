@@ -356,6 +356,9 @@ func (cm *ContractManager) AddStorageFolder(path string, size uint64) error {
 		return err
 	}
 	defer cm.tg.Done()
+	// Adding or removing a storage folder changes whether there are writable
+	// storage folders left.
+	defer cm.managedUpdateWritableStorageFoldersAlert()
 
 	// Check that the storage folder being added meets the size requirements.
 	sectors := size / modules.SectorSize
@@ -38,10 +38,10 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 	// new storage folder.
 	sectorData, err := readSector(oldFolder.sectorFile, oldLocation.index)
 	if err != nil {
-		atomic.AddUint64(&oldFolder.atomicFailedReads, 1)
+		wal.cm.managedRecordRead(oldFolder, err)
 		return build.ExtendErr("unable to read sector selected for migration", err)
 	}
-	atomic.AddUint64(&oldFolder.atomicSuccessfulReads, 1)
+	wal.cm.managedRecordRead(oldFolder, nil)
 
 	// Create the sector update that will remove the old sector.
 	oldSU := sectorUpdate{
@@ -53,7 +53,7 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 
 	// Place the sector into its new folder and add the atomic move to the WAL.
 	wal.mu.Lock()
-	storageFolders := wal.cm.availableStorageFolders()
+	storageFolders := wal.cm.writableStorageFolders()
 	wal.mu.Unlock()
 	for len(storageFolders) >= 1 {
 		var storageFolderIndex int
@@ -100,7 +100,7 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 			err = writeSector(sf.sectorFile, sectorIndex, sectorData)
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", sf.path, err)
-				atomic.AddUint64(&sf.atomicFailedWrites, 1)
+				wal.cm.managedRecordWrite(sf, err)
 				wal.mu.Lock()
 				sf.clearUsage(sectorIndex)
 				delete(sf.availableSectors, id)
@@ -118,7 +118,6 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 			err = wal.writeSectorMetadata(sf, su)
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector metadata for folder %v: %v\n", sf.path, err)
-				atomic.AddUint64(&sf.atomicFailedWrites, 1)
 				wal.mu.Lock()
 				sf.clearUsage(sectorIndex)
 				delete(sf.availableSectors, id)
@@ -170,7 +169,11 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 // This function assumes that the storage folder has already been made
 // invisible to AddSector, and that this is the only thread that will be
 // interacting with the storage folder.
-func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoint uint32) (uint64, error) {
+//
+// If the storage folder is emptied for a resize, the resize's progress is
+// updated with every sector that is moved. Once the resize is cancelled, no
+// more sectors are moved and ErrResizeCancelled is returned.
+func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoint uint32, resize *storageFolderResize) (uint64, error) {
 	// Allow disk trouble simulation, for testing purposes
 	if wal.cm.dependencies.Disrupt("diskTrouble") {
 		wal.cm.staticAlerter.RegisterAlert(modules.AlertIDHostDiskTrouble, AlertMSGHostDiskTrouble, "", modules.SeverityCritical)
@@ -189,13 +192,44 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 	// what sectors are in which locations.
 	sectorLookupBytes, err := readFullMetadata(sf.metadataFile, len(sf.usage)*storageFolderGranularity)
 	if err != nil {
-		atomic.AddUint64(&sf.atomicFailedReads, 1)
+		wal.cm.managedRecordRead(sf, err)
 		return 0, build.ExtendErr("unable to read sector metadata", err)
 	}
-	atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	wal.cm.managedRecordRead(sf, nil)
+
+	// Collect the sectors that need to be moved, so that the progress can be
+	// reported against their total.
+	var ids []sectorID
+	readHead := startingPoint * sectorMetadataDiskSize
+	wal.cm.sectorMu.Lock()
+	for _, usage := range sf.usage[startingPoint/storageFolderGranularity:] {
+		// The usage is a bitfield indicating where sectors exist. Iterate
+		// through each bit to check for a sector.
+		usageMask := uint64(1)
+		for j := 0; j < storageFolderGranularity; j++ {
+			if usage&usageMask == usageMask {
+				// Fetch the id of the sector in this location. Sectors that
+				// have been deleted but whose usage has not been updated yet
+				// don't need to be moved.
+				var id sectorID
+				copy(id[:], sectorLookupBytes[readHead:readHead+12])
+				if _, exists := wal.cm.sectorLocations[id]; exists {
+					ids = append(ids, id)
+				}
+			}
+			readHead += sectorMetadataDiskSize
+			usageMask = usageMask << 1
+		}
+	}
+	wal.cm.sectorMu.Unlock()
 
 	var errCount, movedCount uint64
-	totalSectors := (64 * uint64(len(sf.usage))) - uint64(startingPoint)
+	totalSectors := uint64(len(ids))
+	if resize != nil {
+		atomic.StoreUint64(&resize.atomicSectorsTotal, totalSectors)
+		atomic.StoreUint64(&sf.atomicProgressNumerator, 0)
+		atomic.StoreUint64(&sf.atomicProgressDenominator, totalSectors*modules.SectorSize)
+	}
 
 	// create a unique alert ID per empty and unregister it after completion.
 	alertID := modules.AlertID("cm-empty-folder-" + hex.EncodeToString(fastrand.Bytes(12)))
@@ -213,6 +247,14 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 			for {
 				select {
 				case id := <-workChan:
+					// Allow the moves to be held up, for testing purposes.
+					wal.cm.dependencies.Disrupt("emptyStorageFolderMove")
+					// Moves that were queued before the resize was
+					// cancelled are skipped.
+					if resize.cancelled() {
+						wg.Done()
+						continue
+					}
 					err := wal.managedMoveSector(id)
 					if errors.Contains(err, errDiskTrouble) {
 						wal.cm.staticAlerter.RegisterAlert(modules.AlertIDHostDiskTrouble, AlertMSGHostDiskTrouble, "", modules.SeverityCritical)
@@ -222,6 +264,10 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 						wal.cm.log.Println("Unable to write sector:", err)
 					} else {
 						atomic.AddUint64(&movedCount, 1)
+						if resize != nil {
+							atomic.AddUint64(&resize.atomicSectorsMoved, 1)
+							atomic.AddUint64(&sf.atomicProgressNumerator, modules.SectorSize)
+						}
 					}
 
 					wal.cm.staticAlerter.RegisterAlert(alertID,
@@ -242,39 +288,32 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 
 	// Iterate through all of the sectors and perform the move operation on
 	// them.
-	readHead := startingPoint * sectorMetadataDiskSize
-	for _, usage := range sf.usage[startingPoint/storageFolderGranularity:] {
-		// The usage is a bitfield indicating where sectors exist. Iterate
-		// through each bit to check for a sector.
-		usageMask := uint64(1)
-		for j := 0; j < storageFolderGranularity; j++ {
-			// Perform a move operation if a sector exists in this location.
-			if usage&usageMask == usageMask {
-				// Fetch the id of the sector in this location.
-				var id sectorID
-				copy(id[:], sectorLookupBytes[readHead:readHead+12])
-				// Reference the sector locations map to get the most
-				// up-to-date status for the sector.
-				wal.cm.sectorMu.Lock()
-				_, exists := wal.cm.sectorLocations[id]
-				wal.cm.sectorMu.Unlock()
-				if !exists {
-					// The sector has been deleted, but the usage has not been
-					// updated yet. Safe to ignore.
-					continue
-				}
-
-				// Queue the sector move.
-				wg.Add(1)
-				workChan <- id
-			}
-			readHead += sectorMetadataDiskSize
-			usageMask = usageMask << 1
+	for _, id := range ids {
+		if resize.cancelled() {
+			break
 		}
+		// Reference the sector locations map to get the most up-to-date
+		// status for the sector.
+		wal.cm.sectorMu.Lock()
+		_, exists := wal.cm.sectorLocations[id]
+		wal.cm.sectorMu.Unlock()
+		if !exists {
+			// The sector has been deleted, but the usage has not been updated
+			// yet. Safe to ignore.
+			continue
+		}
+
+		// Queue the sector move.
+		wg.Add(1)
+		workChan <- id
 	}
 	wg.Wait()
 	close(doneChan)
 
+	if resize.cancelled() {
+		return errCount, ErrResizeCancelled
+	}
+
 	// Return errPartialRelocation if not every sector was migrated out
 	// successfully.
 	if errCount > 0 {
@@ -103,8 +103,9 @@ func (wal *writeAheadLog) commitStorageFolderExtension(sfe storageFolderExtensio
 }
 
 // growStorageFolder will extend the storage folder files so that they may hold
-// more sectors.
-func (wal *writeAheadLog) growStorageFolder(index uint16, newSectorCount uint32) error {
+// more sectors. If the resize is cancelled, the storage folder is truncated
+// back to its original size.
+func (wal *writeAheadLog) growStorageFolder(index uint16, newSectorCount uint32, resize *storageFolderResize) error {
 	// Retrieve the specified storage folder.
 	wal.cm.sectorMu.Lock()
 	sf, exists := wal.cm.storageFolders[index]
@@ -167,6 +168,10 @@ func (wal *writeAheadLog) growStorageFolder(index uint16, newSectorCount uint32)
 
 	stepCount := housingWriteSize / folderAllocationStepSize
 	for i := int64(0); i < stepCount; i++ {
+		if resize.cancelled() {
+			err = ErrResizeCancelled
+			return err
+		}
 		err = sf.sectorFile.Truncate(currentHousingSize + (folderAllocationStepSize * (i + 1)))
 		if err != nil {
 			return build.ExtendErr("could not allocate storage folder", err)
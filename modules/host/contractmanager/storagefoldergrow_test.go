@@ -2,11 +2,15 @@ package contractmanager
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/modules"
 )
 
@@ -537,3 +541,51 @@ func TestGrowStorageFolderWAL(t *testing.T) {
 		t.Error("sector file is the wrong size:", sfi.Size(), modules.SectorSize*storageFolderGranularity*25)
 	}
 }
+
+// TestUpdateFolderOpAlert checks that the alert of a storage folder operation
+// is updated with the progress of the operation.
+func TestUpdateFolderOpAlert(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	cm := &ContractManager{
+		staticAlerter: modules.NewAlerter("contractmanager"),
+	}
+	sf := new(storageFolder)
+	atomic.StoreUint64(&sf.atomicProgressNumerator, 43)
+	atomic.StoreUint64(&sf.atomicProgressDenominator, 100)
+
+	id := modules.AlertID("folder-op")
+	cm.staticAlerter.RegisterAlert(id, "Resizing folder", "folder op", modules.SeverityInfo)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		cm.threadedUpdateFolderOpAlert(id, "Resizing folder", sf, stop)
+		close(stopped)
+	}()
+
+	// the alert should show the progress
+	err := build.Retry(50, folderOpAlertInterval, func() error {
+		_, _, _, info := cm.staticAlerter.Alerts()
+		if len(info) != 1 {
+			return fmt.Errorf("expected 1 info alert, got %v", len(info))
+		}
+		if info[0].Msg != "Resizing folder, 43% complete" {
+			return fmt.Errorf("unexpected msg '%v'", info[0].Msg)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the thread stops when signaled
+	close(stop)
+	select {
+	case <-stopped:
+	case <-time.After(time.Minute):
+		t.Fatal("thread didn't stop")
+	}
+}
@@ -0,0 +1,205 @@
+package contractmanager
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// A storage folder whose disk fails keeps returning errors for every read and
+// write. To avoid placing new sectors on a dead disk, the contract manager
+// tracks the results of the reads and writes of every storage folder and
+// quarantines a storage folder once too many of them fail in a row or its
+// error rate gets too high. No new sectors are placed in a quarantined storage
+// folder, but reads of its sectors are still attempted and their failures
+// don't escalate any further. A lightweight probe periodically checks whether
+// the disk recovered and lifts the quarantine if it did. The quarantine isn't
+// persisted, a storage folder starts out healthy after a restart.
+
+// storageFolderHealth tracks the recent reads and writes of a storage folder.
+type storageFolderHealth struct {
+	// consecutiveErrors is the number of reads and writes that failed since
+	// the last successful one. windowOps and windowErrors are the number of
+	// reads and writes and the number of failures among them since the error
+	// rate was last measured. lastErr is the most recent failure.
+	consecutiveErrors uint64
+	windowOps         uint64
+	windowErrors      uint64
+	lastErr           error
+
+	mu sync.Mutex
+}
+
+// managedRecord records the result of a read or write. If the storage folder
+// exceeded one of the error thresholds, the reason and the most recent error
+// are returned.
+func (h *storageFolderHealth) managedRecord(err error) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.windowOps++
+	if err != nil {
+		h.consecutiveErrors++
+		h.windowErrors++
+		h.lastErr = err
+	} else {
+		h.consecutiveErrors = 0
+	}
+	if h.consecutiveErrors >= folderMaxConsecutiveErrors {
+		return fmt.Sprintf("%v consecutive failed reads and writes", h.consecutiveErrors), h.lastErr
+	}
+	if h.windowOps < folderErrorRateWindow {
+		return "", nil
+	}
+	ops, errs := h.windowOps, h.windowErrors
+	h.windowOps, h.windowErrors = 0, 0
+	if float64(errs) > folderMaxErrorRate*float64(ops) {
+		return fmt.Sprintf("%v of the last %v reads and writes failed", errs, ops), h.lastErr
+	}
+	return "", nil
+}
+
+// managedReset forgets about the recorded reads and writes.
+func (h *storageFolderHealth) managedReset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.consecutiveErrors = 0
+	h.windowOps = 0
+	h.windowErrors = 0
+	h.lastErr = nil
+}
+
+// managedProbeStorageFolder performs a lightweight check of the disk of the
+// storage folder. It reads the metadata and the first segment of the folder's
+// first sector, and writes and syncs a small probe file next to them.
+func (cm *ContractManager) managedProbeStorageFolder(sf *storageFolder) error {
+	if _, err := readSectorID(sf.metadataFile, 0); err != nil {
+		return err
+	}
+	if _, err := readPartialSector(sf.sectorFile, 0, 0, crypto.SegmentSize); err != nil {
+		return err
+	}
+	filename := filepath.Join(sf.path, probeFile)
+	f, err := cm.dependencies.CreateFile(filename)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteAt(fastrand.Bytes(crypto.SegmentSize), 0)
+	if err == nil {
+		err = f.Sync()
+	}
+	err = errors.Compose(err, f.Close())
+	return errors.Compose(err, cm.dependencies.RemoveFile(filename))
+}
+
+// managedRecordRead updates the read statistics of the storage folder with the
+// result of a read.
+func (cm *ContractManager) managedRecordRead(sf *storageFolder, err error) {
+	if err != nil {
+		atomic.AddUint64(&sf.atomicFailedReads, 1)
+	} else {
+		atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	}
+	cm.managedRecordDiskResult(sf, err)
+}
+
+// managedRecordWrite updates the write statistics of the storage folder with
+// the result of a write.
+func (cm *ContractManager) managedRecordWrite(sf *storageFolder, err error) {
+	if err != nil {
+		atomic.AddUint64(&sf.atomicFailedWrites, 1)
+	} else {
+		atomic.AddUint64(&sf.atomicSuccessfulWrites, 1)
+	}
+	cm.managedRecordDiskResult(sf, err)
+}
+
+// managedRecordDiskResult tracks the result of a read or write of the storage
+// folder and quarantines it if it exceeded one of the error thresholds. The
+// failures of a quarantined storage folder are tolerated until the probe
+// decides whether the disk recovered. The caller may hold any of the contract
+// manager's locks.
+func (cm *ContractManager) managedRecordDiskResult(sf *storageFolder, err error) {
+	if atomic.LoadUint64(&sf.atomicQuarantined) == 1 {
+		return
+	}
+	if reason, sample := sf.health.managedRecord(err); reason != "" {
+		cm.managedQuarantineStorageFolder(sf, reason, sample)
+	}
+}
+
+// managedQuarantineStorageFolder quarantines the storage folder and registers
+// a critical alert naming the storage folder, the reason and a sample of the
+// errors.
+func (cm *ContractManager) managedQuarantineStorageFolder(sf *storageFolder, reason string, sample error) {
+	if !atomic.CompareAndSwapUint64(&sf.atomicQuarantined, 0, 1) {
+		return
+	}
+	cause := fmt.Sprintf("storage folder %v was quarantined after %v, last error: %v", sf.path, reason, sample)
+	cm.log.Println("ERROR:", cause)
+	cm.staticAlerter.RegisterAlert(modules.AlertIDHostStorageFolderQuarantined(sf.path), AlertMSGStorageFolderQuarantined, cause, modules.SeverityCritical)
+}
+
+// managedLiftQuarantine lifts the quarantine of the storage folder and
+// unregisters its alert.
+func (cm *ContractManager) managedLiftQuarantine(sf *storageFolder) {
+	sf.health.managedReset()
+	if !atomic.CompareAndSwapUint64(&sf.atomicQuarantined, 1, 0) {
+		return
+	}
+	cm.log.Printf("Storage folder %v recovered, the quarantine was lifted\n", sf.path)
+	cm.staticAlerter.UnregisterAlert(modules.AlertIDHostStorageFolderQuarantined(sf.path))
+}
+
+// managedProbeQuarantinedFolders probes the disks of the quarantined storage
+// folders and lifts the quarantine of the ones that recovered.
+func (cm *ContractManager) managedProbeQuarantinedFolders() {
+	if err := cm.tg.Add(); err != nil {
+		return
+	}
+	defer cm.tg.Done()
+
+	cm.sectorMu.Lock()
+	var quarantined []*storageFolder
+	for _, sf := range cm.storageFolders {
+		if atomic.LoadUint64(&sf.atomicQuarantined) == 1 && atomic.LoadUint64(&sf.atomicUnavailable) == 0 {
+			quarantined = append(quarantined, sf)
+		}
+	}
+	cm.sectorMu.Unlock()
+
+	for _, sf := range quarantined {
+		// Skip storage folders that are being resized or removed, their files
+		// might be closed in the meantime.
+		if !sf.mu.TryRLock() {
+			continue
+		}
+		err := cm.managedProbeStorageFolder(sf)
+		sf.mu.RUnlock()
+		if err != nil {
+			continue
+		}
+		cm.managedLiftQuarantine(sf)
+	}
+}
+
+// threadedProbeQuarantinedFolders periodically probes the quarantined storage
+// folders until shutdown.
+func (cm *ContractManager) threadedProbeQuarantinedFolders() {
+	for {
+		select {
+		case <-cm.tg.StopChan():
+			return
+		case <-time.After(folderProbeInterval):
+		}
+		cm.managedProbeQuarantinedFolders()
+	}
+}
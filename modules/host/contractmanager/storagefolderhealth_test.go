@@ -0,0 +1,304 @@
+package contractmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// errDiskFailing is returned by the files of a failing disk.
+var errDiskFailing = errors.New("disk is failing")
+
+// dependencyFailingDisk is a mocked dependency that simulates a failing disk
+// for the files of "storageFolderOne" while it is triggered.
+type dependencyFailingDisk struct {
+	modules.ProductionDependencies
+	atomicFailing uint64
+}
+
+// failingDiskFile is a file that returns errors for all reads, writes and
+// syncs while the dependency it was created by is triggered.
+type failingDiskFile struct {
+	d *dependencyFailingDisk
+	*os.File
+}
+
+// wrap returns a file that fails while the dependency is triggered if it
+// belongs to "storageFolderOne".
+func (d *dependencyFailingDisk) wrap(f *os.File) modules.File {
+	if !strings.Contains(f.Name(), "storageFolderOne") {
+		return f
+	}
+	return &failingDiskFile{d: d, File: f}
+}
+
+// CreateFile creates a file that fails while the dependency is triggered.
+func (d *dependencyFailingDisk) CreateFile(s string) (modules.File, error) {
+	f, err := os.Create(s)
+	if err != nil {
+		return nil, err
+	}
+	return d.wrap(f), nil
+}
+
+// OpenFile opens a file that fails while the dependency is triggered.
+func (d *dependencyFailingDisk) OpenFile(s string, flags int, perms os.FileMode) (modules.File, error) {
+	f, err := os.OpenFile(s, flags, perms)
+	if err != nil {
+		return nil, err
+	}
+	return d.wrap(f), nil
+}
+
+// ReadAt fails if the disk is failing.
+func (f *failingDiskFile) ReadAt(b []byte, off int64) (int, error) {
+	if atomic.LoadUint64(&f.d.atomicFailing) == 1 {
+		return 0, errDiskFailing
+	}
+	return f.File.ReadAt(b, off)
+}
+
+// WriteAt fails if the disk is failing.
+func (f *failingDiskFile) WriteAt(b []byte, off int64) (int, error) {
+	if atomic.LoadUint64(&f.d.atomicFailing) == 1 {
+		return 0, errDiskFailing
+	}
+	return f.File.WriteAt(b, off)
+}
+
+// Sync fails if the disk is failing.
+func (f *failingDiskFile) Sync() error {
+	if atomic.LoadUint64(&f.d.atomicFailing) == 1 {
+		return errDiskFailing
+	}
+	return f.File.Sync()
+}
+
+// TestStorageFolderHealthErrorRate checks that a storage folder exceeds the
+// error thresholds after too many consecutive errors or if too many of its
+// reads and writes fail.
+func TestStorageFolderHealthErrorRate(t *testing.T) {
+	t.Parallel()
+
+	// Consecutive errors exceed the threshold once there are
+	// folderMaxConsecutiveErrors of them.
+	var h storageFolderHealth
+	for i := uint64(1); i < folderMaxConsecutiveErrors; i++ {
+		if reason, _ := h.managedRecord(errDiskFailing); reason != "" {
+			t.Fatal("threshold exceeded early", i, reason)
+		}
+	}
+	reason, sample := h.managedRecord(errDiskFailing)
+	if !strings.Contains(reason, "consecutive") || sample != errDiskFailing {
+		t.Fatal("consecutive errors should exceed the threshold", reason, sample)
+	}
+
+	// A success resets the consecutive errors.
+	h.managedReset()
+	for i := uint64(0); i < 3*folderMaxConsecutiveErrors; i++ {
+		err := errDiskFailing
+		if i%(folderMaxConsecutiveErrors-1) == 0 {
+			err = nil
+		}
+		if reason, _ := h.managedRecord(err); strings.Contains(reason, "consecutive") {
+			t.Fatal("successes should reset the consecutive errors", i)
+		}
+	}
+
+	// An error rate below the threshold is tolerated.
+	h.managedReset()
+	maxErrors := uint64(folderMaxErrorRate * float64(folderErrorRateWindow))
+	for i := uint64(0); i < 3*folderErrorRateWindow; i++ {
+		var err error
+		if i%folderErrorRateWindow < maxErrors && i%2 == 0 {
+			err = errDiskFailing
+		}
+		if reason, _ := h.managedRecord(err); reason != "" {
+			t.Fatal("error rate below the threshold shouldn't be reported", i, reason)
+		}
+	}
+
+	// An error rate above the threshold is reported once the window is full.
+	h.managedReset()
+	var reported bool
+	for i := uint64(0); i < folderErrorRateWindow; i++ {
+		var err error
+		if i%2 == 0 {
+			err = errDiskFailing
+		}
+		reason, sample := h.managedRecord(err)
+		if reason == "" {
+			continue
+		}
+		if i != folderErrorRateWindow-1 || !strings.Contains(reason, fmt.Sprintf("of the last %v", folderErrorRateWindow)) || sample != errDiskFailing {
+			t.Fatal("unexpected report", i, reason, sample)
+		}
+		reported = true
+	}
+	if !reported {
+		t.Fatal("error rate above the threshold should be reported")
+	}
+}
+
+// TestStorageFolderQuarantine checks that a storage folder whose disk fails is
+// quarantined, that no new sectors are placed in it while reads are still
+// attempted, and that the quarantine is lifted once the disk recovers.
+func TestStorageFolderQuarantine(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := new(dependencyFailingDisk)
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Fill the first storage folder with some sectors and add a second one.
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	storageFolderTwo := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	for _, dir := range []string{storageFolderOne, storageFolderTwo} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderOne, modules.SectorSize*storageFolderGranularity); err != nil {
+		t.Fatal(err)
+	}
+	roots := make([]crypto.Hash, 5)
+	datas := make([][]byte, len(roots))
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderTwo, modules.SectorSize*storageFolderGranularity); err != nil {
+		t.Fatal(err)
+	}
+
+	// folder returns the metadata of the storage folder with the given path.
+	folder := func(path string) modules.StorageFolderMetadata {
+		for _, sf := range cmt.cm.StorageFolders() {
+			if sf.Path == path {
+				return sf
+			}
+		}
+		t.Fatal("storage folder not found", path)
+		return modules.StorageFolderMetadata{}
+	}
+	// quarantineAlert returns the quarantine alert of the first storage
+	// folder.
+	quarantineAlert := func() (modules.Alert, bool) {
+		crit, _, _, _ := cmt.cm.Alerts()
+		for _, a := range crit {
+			if a.ID == modules.AlertIDHostStorageFolderQuarantined(storageFolderOne) {
+				return a, true
+			}
+		}
+		return modules.Alert{}, false
+	}
+
+	// Let the disk of the first storage folder fail. Reading its sectors fails
+	// until the folder is quarantined.
+	atomic.StoreUint64(&d.atomicFailing, 1)
+	for i := uint64(0); i < folderMaxConsecutiveErrors; i++ {
+		if folder(storageFolderOne).Quarantined {
+			t.Fatal("storage folder was quarantined early", i)
+		}
+		if _, err := cmt.cm.ReadSector(roots[0]); err == nil || !strings.Contains(err.Error(), errDiskFailing.Error()) {
+			t.Fatal("expected errDiskFailing, got", err)
+		}
+	}
+	if !folder(storageFolderOne).Quarantined {
+		t.Fatal("storage folder should be quarantined")
+	}
+	if folder(storageFolderTwo).Quarantined {
+		t.Fatal("healthy storage folder shouldn't be quarantined")
+	}
+	alert, exists := quarantineAlert()
+	if !exists {
+		t.Fatal("quarantine alert should be registered")
+	}
+	if alert.Severity != modules.SeverityCritical || !strings.Contains(alert.Cause, storageFolderOne) || !strings.Contains(alert.Cause, errDiskFailing.Error()) {
+		t.Fatal("alert doesn't name the storage folder and the error", alert)
+	}
+
+	// Reads of the quarantined storage folder are still attempted.
+	failedReads := folder(storageFolderOne).FailedReads
+	if _, err := cmt.cm.ReadSector(roots[1]); err == nil || !strings.Contains(err.Error(), errDiskFailing.Error()) {
+		t.Fatal("expected errDiskFailing, got", err)
+	}
+	if folder(storageFolderOne).FailedReads != failedReads+1 {
+		t.Fatal("read of the quarantined storage folder wasn't attempted")
+	}
+
+	// New sectors are only placed in the healthy storage folder and sectors
+	// can't be moved into the quarantined one.
+	for i := 0; i < 10; i++ {
+		if err := cmt.cm.AddSector(randSector()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if used := folder(storageFolderTwo).Capacity - folder(storageFolderTwo).CapacityRemaining; used != 10*modules.SectorSize {
+		t.Fatal("new sectors should be placed in the healthy storage folder", used)
+	}
+	if folder(storageFolderOne).FailedWrites != 0 {
+		t.Fatal("no writes should be attempted in the quarantined storage folder")
+	}
+	if _, err := cmt.cm.MoveSectors(folder(storageFolderTwo).Index, folder(storageFolderOne).Index, 0); !errors.Contains(err, errSectorMigrationQuarantined) {
+		t.Fatal("expected errSectorMigrationQuarantined, got", err)
+	}
+
+	// The quarantine isn't lifted while the disk is still failing.
+	time.Sleep(5 * folderProbeInterval)
+	if !folder(storageFolderOne).Quarantined {
+		t.Fatal("quarantine was lifted while the disk is failing")
+	}
+
+	// Once the disk recovers, the probe lifts the quarantine.
+	atomic.StoreUint64(&d.atomicFailing, 0)
+	err = build.Retry(100, folderProbeInterval, func() error {
+		if folder(storageFolderOne).Quarantined {
+			return errors.New("storage folder is still quarantined")
+		}
+		if _, exists := quarantineAlert(); exists {
+			return errors.New("quarantine alert is still registered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, root := range roots {
+		data, err := cmt.cm.ReadSector(root)
+		if err != nil || !bytes.Equal(data, datas[i]) {
+			t.Fatal("sector is missing or corrupt", i, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(storageFolderOne, probeFile)); !os.IsNotExist(err) {
+		t.Fatal("probe file wasn't removed", err)
+	}
+
+	// New sectors can be placed in the recovered storage folder again.
+	quarantinedIndex := folder(storageFolderOne).Index
+	var writable bool
+	for _, sf := range cmt.cm.writableStorageFolders() {
+		writable = writable || sf.index == quarantinedIndex
+	}
+	if !writable {
+		t.Fatal("recovered storage folder should be writable")
+	}
+}
@@ -0,0 +1,98 @@
+package contractmanager
+
+import (
+	"sync/atomic"
+
+	"go.sia.tech/siad/modules"
+)
+
+type (
+	// storageFolderReadOnlyUpdate marks a storage folder as read-only or as
+	// writable again.
+	storageFolderReadOnlyUpdate struct {
+		Index    uint16
+		ReadOnly bool
+	}
+)
+
+// commitStorageFolderReadOnlyUpdate commits a change of the read-only flag of
+// a storage folder to the state.
+func (wal *writeAheadLog) commitStorageFolderReadOnlyUpdate(u storageFolderReadOnlyUpdate) {
+	wal.cm.sectorMu.Lock()
+	defer wal.cm.sectorMu.Unlock()
+	sf, exists := wal.cm.storageFolders[u.Index]
+	if !exists {
+		// The storage folder was removed after the update.
+		return
+	}
+	sf.readOnly = u.ReadOnly
+}
+
+// managedUpdateWritableStorageFoldersAlert registers an alert if the contract
+// manager has storage folders but none of them are writable, and unregisters
+// it otherwise.
+func (cm *ContractManager) managedUpdateWritableStorageFoldersAlert() {
+	cm.sectorMu.Lock()
+	numFolders := 0
+	numWritable := 0
+	for _, sf := range cm.storageFolders {
+		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+			continue
+		}
+		numFolders++
+		if !sf.readOnly {
+			numWritable++
+		}
+	}
+	cm.sectorMu.Unlock()
+
+	if numFolders > 0 && numWritable == 0 {
+		cm.log.Println("WARN: all storage folders are read-only, no new sectors can be stored")
+		cm.staticAlerter.RegisterAlert(modules.AlertIDHostNoWritableStorageFolders, AlertMSGNoWritableStorageFolders, "", modules.SeverityWarning)
+		return
+	}
+	cm.staticAlerter.UnregisterAlert(modules.AlertIDHostNoWritableStorageFolders)
+}
+
+// SetStorageFolderReadOnly marks the storage folder with the given index as
+// read-only or as writable again. No new sectors are placed in a read-only
+// storage folder, neither by AddSector nor when sectors are moved out of
+// another storage folder, but the sectors it stores can still be read and
+// deleted. Marking the last writable storage folder as read-only succeeds,
+// but registers a warning since the host can't store new sectors anymore.
+func (cm *ContractManager) SetStorageFolderReadOnly(index uint16, readOnly bool) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	// Change the flag and submit the change to the WAL, so that it survives
+	// an unclean shutdown.
+	cm.wal.mu.Lock()
+	cm.sectorMu.Lock()
+	sf, exists := cm.storageFolders[index]
+	if !exists {
+		cm.sectorMu.Unlock()
+		cm.wal.mu.Unlock()
+		return errStorageFolderNotFound
+	}
+	sf.readOnly = readOnly
+	cm.sectorMu.Unlock()
+	cm.wal.appendChange(stateChange{
+		StorageFolderReadOnlyUpdates: []storageFolderReadOnlyUpdate{{
+			Index:    index,
+			ReadOnly: readOnly,
+		}},
+		// Older versions may ignore the update, the storage folder is
+		// writable for them.
+		Skippable: true,
+	})
+	syncChan := cm.wal.syncChan
+	cm.wal.mu.Unlock()
+
+	// Wait until the change has been synchronized.
+	<-syncChan
+	cm.managedUpdateWritableStorageFoldersAlert()
+	return nil
+}
@@ -0,0 +1,222 @@
+package contractmanager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestStorageFolderReadOnly checks that no new sectors are placed in a
+// read-only storage folder while its existing sectors can still be read and
+// deleted.
+func TestStorageFolderReadOnly(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder and store some sectors in it.
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderOne, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderOne, modules.SectorSize*storageFolderGranularity*2); err != nil {
+		t.Fatal(err)
+	}
+	sfOneIndex := cmt.cm.StorageFolders()[0].Index
+	roots := make([]crypto.Hash, 3)
+	datas := make([][]byte, len(roots))
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add a second storage folder and mark the first one read-only.
+	storageFolderTwo := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	if err := os.MkdirAll(storageFolderTwo, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderTwo, modules.SectorSize*storageFolderGranularity*2); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.SetStorageFolderReadOnly(sfOneIndex, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.SetStorageFolderReadOnly(sfOneIndex+100, true); !errors.Contains(err, errStorageFolderNotFound) {
+		t.Fatal("expected errStorageFolderNotFound, got", err)
+	}
+
+	// folder returns the metadata of the storage folder with the given index.
+	folder := func(index uint16) modules.StorageFolderMetadata {
+		t.Helper()
+		for _, sf := range cmt.cm.StorageFolders() {
+			if sf.Index == index {
+				return sf
+			}
+		}
+		t.Fatal("storage folder not found", index)
+		return modules.StorageFolderMetadata{}
+	}
+	sfOne := folder(sfOneIndex)
+	if !sfOne.ReadOnly {
+		t.Fatal("storage folder should be read-only")
+	}
+
+	// New sectors should only land in the writable storage folder.
+	for i := 0; i < 10; i++ {
+		root, data := randSector()
+		if err := cmt.cm.AddSector(root, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if folder(sfOneIndex).CapacityRemaining != sfOne.CapacityRemaining {
+		t.Fatal("new sectors were stored in the read-only storage folder")
+	}
+	for _, sf := range cmt.cm.StorageFolders() {
+		if sf.Index != sfOneIndex && sf.Capacity-sf.CapacityRemaining != 10*modules.SectorSize {
+			t.Fatal("new sectors should be stored in the writable storage folder", sf.CapacityRemaining)
+		}
+	}
+
+	// The existing sectors can still be read and deleted.
+	for i := range roots {
+		data, err := cmt.cm.ReadSector(roots[i])
+		if err != nil || !bytes.Equal(data, datas[i]) {
+			t.Fatal("sector is missing or corrupt", i, err)
+		}
+	}
+	if err := cmt.cm.DeleteSector(roots[0]); err != nil {
+		t.Fatal(err)
+	}
+	if folder(sfOneIndex).CapacityRemaining != sfOne.CapacityRemaining+modules.SectorSize {
+		t.Fatal("sector wasn't deleted from the read-only storage folder")
+	}
+
+	// The flag should persist across restarts.
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !folder(sfOneIndex).ReadOnly {
+		t.Fatal("read-only flag wasn't persisted")
+	}
+	for i := 1; i < len(roots); i++ {
+		if _, err := cmt.cm.ReadSector(roots[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Clearing the flag makes the storage folder writable again.
+	if err := cmt.cm.SetStorageFolderReadOnly(sfOneIndex, false); err != nil {
+		t.Fatal(err)
+	}
+	if folder(sfOneIndex).ReadOnly {
+		t.Fatal("storage folder should be writable")
+	}
+}
+
+// TestStorageFolderReadOnlyLastWritable checks that marking the last writable
+// storage folder read-only registers an alert and that the host runs out of
+// storage until a storage folder is writable again.
+func TestStorageFolderReadOnlyLastWritable(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderOne, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderOne, modules.SectorSize*storageFolderGranularity*2); err != nil {
+		t.Fatal(err)
+	}
+	sfIndex := cmt.cm.StorageFolders()[0].Index
+
+	// hasAlert returns whether the no writable storage folders alert is
+	// registered.
+	hasAlert := func() bool {
+		_, _, warn, _ := cmt.cm.Alerts()
+		for _, a := range warn {
+			if a.ID == modules.AlertIDHostNoWritableStorageFolders {
+				return true
+			}
+		}
+		return false
+	}
+	if hasAlert() {
+		t.Fatal("alert shouldn't be registered")
+	}
+
+	// Mark the only storage folder read-only.
+	if err := cmt.cm.SetStorageFolderReadOnly(sfIndex, true); err != nil {
+		t.Fatal(err)
+	}
+	if !hasAlert() {
+		t.Fatal("alert should be registered")
+	}
+	root, data := randSector()
+	if err := cmt.cm.AddSector(root, data); err == nil || !strings.Contains(err.Error(), modules.V1420HostOutOfStorageErrString) {
+		t.Fatal("expected out of storage error, got", err)
+	}
+
+	// Adding a writable storage folder clears the alert.
+	storageFolderTwo := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	if err := os.MkdirAll(storageFolderTwo, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderTwo, modules.SectorSize*storageFolderGranularity*2); err != nil {
+		t.Fatal(err)
+	}
+	if hasAlert() {
+		t.Fatal("alert should be unregistered")
+	}
+	if err := cmt.cm.AddSector(root, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the writable storage folder registers the alert again, clearing
+	// the flag unregisters it.
+	for _, sf := range cmt.cm.StorageFolders() {
+		if sf.Index != sfIndex {
+			if err := cmt.cm.RemoveStorageFolder(sf.Index, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !hasAlert() {
+		t.Fatal("alert should be registered")
+	}
+	if err := cmt.cm.SetStorageFolderReadOnly(sfIndex, false); err != nil {
+		t.Fatal(err)
+	}
+	if hasAlert() {
+		t.Fatal("alert should be unregistered")
+	}
+	root, data = randSector()
+	if err := cmt.cm.AddSector(root, data); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -60,6 +60,9 @@ func (cm *ContractManager) RemoveStorageFolder(index uint16, force bool) error {
 		return err
 	}
 	defer cm.tg.Done()
+	// Adding or removing a storage folder changes whether there are writable
+	// storage folders left.
+	defer cm.managedUpdateWritableStorageFoldersAlert()
 
 	// Retrieve the specified storage folder.
 	cm.sectorMu.Lock()
@@ -85,7 +88,7 @@ func (cm *ContractManager) RemoveStorageFolder(index uint16, force bool) error {
 		"folder op", modules.SeverityInfo)
 
 	// Clear out the sectors in the storage folder.
-	_, err = cm.wal.managedEmptyStorageFolder(index, 0)
+	_, err = cm.wal.managedEmptyStorageFolder(index, 0, nil)
 	if err != nil && !force {
 		return err
 	}
@@ -111,5 +114,9 @@ func (cm *ContractManager) RemoveStorageFolder(index uint16, force bool) error {
 	syncChan = cm.wal.syncChan
 	cm.wal.mu.Unlock()
 	<-syncChan
+
+	// The corrupt sectors of the storage folder are gone with it.
+	cm.staticAlerter.UnregisterAlert(modules.AlertIDHostCorruptSectors(sf.path))
+	cm.staticAlerter.UnregisterAlert(modules.AlertIDHostStorageFolderQuarantined(sf.path))
 	return nil
 }
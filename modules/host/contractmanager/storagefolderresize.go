@@ -0,0 +1,116 @@
+package contractmanager
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.sia.tech/siad/modules"
+)
+
+var (
+	// ErrNoResizeInProgress is returned if a resize is cancelled for a storage
+	// folder that isn't being resized.
+	ErrNoResizeInProgress = errors.New("storage folder is not being resized")
+
+	// ErrResizeCancelled is returned by ResizeStorageFolder if the resize was
+	// cancelled before it completed.
+	ErrResizeCancelled = errors.New("storage folder resize was cancelled")
+
+	// ErrResizeInProgress is returned if a storage folder is resized while
+	// another resize of the folder is still in progress.
+	ErrResizeInProgress = errors.New("storage folder is already being resized")
+)
+
+// storageFolderResize tracks an ongoing resize of a storage folder. The
+// progress in bytes is tracked by the storage folder's progress fields, the
+// resize additionally tracks the sectors that are relocated when the folder is
+// shrunk.
+type storageFolderResize struct {
+	atomicSectorsMoved uint64
+	atomicSectorsTotal uint64
+
+	cancelOnce   sync.Once
+	staticCancel chan struct{}
+	staticStart  time.Time
+}
+
+// newStorageFolderResize creates a tracker for a resize that starts now.
+func newStorageFolderResize() *storageFolderResize {
+	return &storageFolderResize{
+		staticCancel: make(chan struct{}),
+		staticStart:  time.Now(),
+	}
+}
+
+// cancel signals the resize to stop. It is safe to call cancel more than
+// once.
+func (r *storageFolderResize) cancel() {
+	r.cancelOnce.Do(func() {
+		close(r.staticCancel)
+	})
+}
+
+// cancelled returns whether the resize was cancelled. A nil resize, which is
+// used when a storage folder is emptied for removal, is never cancelled.
+func (r *storageFolderResize) cancelled() bool {
+	if r == nil {
+		return false
+	}
+	select {
+	case <-r.staticCancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// progress returns the progress of the resize of the given storage folder. The
+// speed and estimated completion are extrapolated from the bytes that were
+// processed since the resize started.
+func (r *storageFolderResize) progress(sf *storageFolder, now time.Time) modules.StorageFolderResizeProgress {
+	p := modules.StorageFolderResizeProgress{
+		SectorsMoved: atomic.LoadUint64(&r.atomicSectorsMoved),
+		SectorsTotal: atomic.LoadUint64(&r.atomicSectorsTotal),
+		Started:      r.staticStart,
+	}
+	done := atomic.LoadUint64(&sf.atomicProgressNumerator)
+	total := atomic.LoadUint64(&sf.atomicProgressDenominator)
+	elapsed := now.Sub(r.staticStart).Seconds()
+	if done == 0 || elapsed <= 0 {
+		return p
+	}
+	bytesPerSecond := float64(done) / elapsed
+	p.BytesPerSecond = uint64(bytesPerSecond)
+	p.EstimatedCompletion = now
+	if total > done {
+		remaining := float64(total-done) / bytesPerSecond
+		p.EstimatedCompletion = now.Add(time.Duration(remaining * float64(time.Second)))
+	}
+	return p
+}
+
+// CancelStorageFolderResize cancels the ongoing resize of the storage folder
+// with the given index. ResizeStorageFolder returns ErrResizeCancelled once the
+// sector moves that are in flight have finished. The storage folder keeps its
+// old size, so the resize can be issued again later.
+func (cm *ContractManager) CancelStorageFolderResize(index uint16) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+	cm.sectorMu.Lock()
+	defer cm.sectorMu.Unlock()
+
+	sf, exists := cm.storageFolders[index]
+	if !exists {
+		return errStorageFolderNotFound
+	}
+	if sf.resize == nil {
+		return ErrNoResizeInProgress
+	}
+	sf.resize.cancel()
+	return nil
+}
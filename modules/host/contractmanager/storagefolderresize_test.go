@@ -0,0 +1,230 @@
+package contractmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// dependencyBlockSectorMoves holds up every sector move of a storage folder
+// that is emptied until it receives a value on release.
+type dependencyBlockSectorMoves struct {
+	modules.ProductionDependencies
+	release chan struct{}
+}
+
+// Disrupt blocks the sector moves until they are released.
+func (d *dependencyBlockSectorMoves) Disrupt(s string) bool {
+	if s == "emptyStorageFolderMove" {
+		<-d.release
+	}
+	return false
+}
+
+// TestStorageFolderResizeProgress checks the progress that is reported for a
+// resize.
+func TestStorageFolderResizeProgress(t *testing.T) {
+	t.Parallel()
+
+	sf := new(storageFolder)
+	r := newStorageFolderResize()
+	r.atomicSectorsTotal = 10
+
+	// no progress yet
+	p := r.progress(sf, r.staticStart.Add(time.Second))
+	if p.SectorsTotal != 10 || p.BytesPerSecond != 0 || !p.EstimatedCompletion.IsZero() || !p.Started.Equal(r.staticStart) {
+		t.Fatal("unexpected progress", p)
+	}
+
+	// 100 of 400 bytes after 2 seconds
+	r.atomicSectorsMoved = 2
+	sf.atomicProgressNumerator = 100
+	sf.atomicProgressDenominator = 400
+	now := r.staticStart.Add(2 * time.Second)
+	p = r.progress(sf, now)
+	if p.SectorsMoved != 2 || p.BytesPerSecond != 50 {
+		t.Fatal("unexpected progress", p)
+	}
+	if !p.EstimatedCompletion.Equal(now.Add(6 * time.Second)) {
+		t.Fatal("unexpected estimated completion", p.EstimatedCompletion.Sub(now))
+	}
+
+	// cancelling is idempotent and nil resizes are never cancelled
+	var nilResize *storageFolderResize
+	if r.cancelled() || nilResize.cancelled() {
+		t.Fatal("resize shouldn't be cancelled")
+	}
+	r.cancel()
+	r.cancel()
+	if !r.cancelled() {
+		t.Fatal("resize should be cancelled")
+	}
+}
+
+// TestShrinkStorageFolderProgressCancel checks that the progress of a shrink
+// that relocates sectors advances with every moved sector and that the shrink
+// can be cancelled and issued again.
+func TestShrinkStorageFolderProgressCancel(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := &dependencyBlockSectorMoves{release: make(chan struct{})}
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder and fill it with sectors.
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(storageFolderOne, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderOne, modules.SectorSize*storageFolderGranularity*8); err != nil {
+		t.Fatal(err)
+	}
+	sfIndex := cmt.cm.StorageFolders()[0].Index
+	roots := make([]crypto.Hash, storageFolderGranularity*3)
+	datas := make([][]byte, len(roots))
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add a second storage folder for the relocated sectors.
+	storageFolderTwo := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	if err := os.MkdirAll(storageFolderTwo, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(storageFolderTwo, modules.SectorSize*storageFolderGranularity*3); err != nil {
+		t.Fatal(err)
+	}
+
+	// resizeProgress returns the resize progress of the first storage folder.
+	resizeProgress := func() (*modules.StorageFolderResizeProgress, modules.StorageFolderMetadata) {
+		for _, sf := range cmt.cm.StorageFolders() {
+			if sf.Index == sfIndex {
+				return sf.ResizeProgress, sf
+			}
+		}
+		t.Fatal("storage folder not found")
+		return nil, modules.StorageFolderMetadata{}
+	}
+
+	// Shrink the storage folder. All moves are held up by the dependency.
+	resizeErr := make(chan error)
+	go func() {
+		resizeErr <- cmt.cm.ResizeStorageFolder(sfIndex, modules.SectorSize*storageFolderGranularity*2, false)
+	}()
+	var total uint64
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		p, _ := resizeProgress()
+		if p == nil || p.SectorsTotal == 0 {
+			return errors.New("resize hasn't started")
+		}
+		total = p.SectorsTotal
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total < 24 {
+		t.Fatal("expected dozens of sectors to be relocated", total)
+	}
+	if err := cmt.cm.ResizeStorageFolder(sfIndex, modules.SectorSize*storageFolderGranularity*4, false); !errors.Contains(err, ErrResizeInProgress) {
+		t.Fatal("expected ErrResizeInProgress, got", err)
+	}
+
+	// Release the moves one at a time, the progress should advance with every
+	// one of them.
+	numMoves := 10
+	var last modules.StorageFolderResizeProgress
+	for i := 1; i <= numMoves; i++ {
+		d.release <- struct{}{}
+		err = build.Retry(100, 10*time.Millisecond, func() error {
+			p, sfm := resizeProgress()
+			if p == nil {
+				return errors.New("resize progress missing")
+			}
+			if p.SectorsMoved < last.SectorsMoved || p.SectorsTotal != total {
+				t.Fatalf("progress went backwards: %v -> %v", last, *p)
+			}
+			last = *p
+			if p.SectorsMoved != uint64(i) {
+				return fmt.Errorf("expected %v moved sectors, got %v", i, p.SectorsMoved)
+			}
+			if sfm.ProgressNumerator != uint64(i)*modules.SectorSize || sfm.ProgressDenominator != total*modules.SectorSize {
+				return fmt.Errorf("unexpected progress %v/%v", sfm.ProgressNumerator, sfm.ProgressDenominator)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.BytesPerSecond == 0 || last.EstimatedCompletion.Before(last.Started) {
+			t.Fatal("speed and estimated completion should be known", last)
+		}
+	}
+
+	// Cancel the resize and release the remaining moves, they should be
+	// skipped.
+	if err := cmt.cm.CancelStorageFolderResize(sfIndex); err != nil {
+		t.Fatal(err)
+	}
+	close(d.release)
+	select {
+	case err := <-resizeErr:
+		if !errors.Contains(err, ErrResizeCancelled) {
+			t.Fatal("expected ErrResizeCancelled, got", err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("resize wasn't cancelled")
+	}
+	if err := cmt.cm.CancelStorageFolderResize(sfIndex); !errors.Contains(err, ErrNoResizeInProgress) {
+		t.Fatal("expected ErrNoResizeInProgress, got", err)
+	}
+
+	// The storage folder keeps its size and the moved sectors stay moved.
+	p, sfm := resizeProgress()
+	if p != nil || sfm.ProgressNumerator != 0 || sfm.ProgressDenominator != 0 {
+		t.Fatal("progress should be reset", p, sfm.ProgressNumerator, sfm.ProgressDenominator)
+	}
+	if sfm.Capacity != modules.SectorSize*storageFolderGranularity*8 {
+		t.Fatal("cancelled resize shouldn't change the capacity", sfm.Capacity)
+	}
+	used := (sfm.Capacity - sfm.CapacityRemaining) / modules.SectorSize
+	if used != uint64(len(roots)-numMoves) {
+		t.Fatalf("expected %v sectors in the folder, got %v", len(roots)-numMoves, used)
+	}
+	checkSectors := func() {
+		t.Helper()
+		for i := range roots {
+			data, err := cmt.cm.ReadSector(roots[i])
+			if err != nil || !bytes.Equal(data, datas[i]) {
+				t.Fatal("sector is missing or corrupt", i, err)
+			}
+		}
+	}
+	checkSectors()
+
+	// The resize can be issued again.
+	if err := cmt.cm.ResizeStorageFolder(sfIndex, modules.SectorSize*storageFolderGranularity*2, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, sfm = resizeProgress(); sfm.Capacity != modules.SectorSize*storageFolderGranularity*2 {
+		t.Fatal("storage folder wasn't shrunk", sfm.Capacity)
+	}
+	checkSectors()
+}
@@ -3,6 +3,8 @@ package contractmanager
 import (
 	"sync/atomic"
 
+	"gitlab.com/NebulousLabs/errors"
+
 	"go.sia.tech/siad/modules"
 )
 
@@ -54,8 +56,10 @@ func (wal *writeAheadLog) commitStorageFolderReduction(sfr storageFolderReductio
 }
 
 // shrinkStoragefolder will truncate a storage folder, moving all of the
-// sectors in the truncated space to new storage folders.
-func (wal *writeAheadLog) shrinkStorageFolder(index uint16, newSectorCount uint32, force bool) error {
+// sectors in the truncated space to new storage folders. If the resize is
+// cancelled, the storage folder keeps its size and the sectors that were moved
+// already stay in their new storage folders.
+func (wal *writeAheadLog) shrinkStorageFolder(index uint16, newSectorCount uint32, force bool, resize *storageFolderResize) error {
 	// Retrieve the specified storage folder.
 	wal.cm.sectorMu.Lock()
 	sf, exists := wal.cm.storageFolders[index]
@@ -73,7 +77,17 @@ func (wal *writeAheadLog) shrinkStorageFolder(index uint16, newSectorCount uint3
 	defer sf.mu.Unlock()
 
 	// Clear out the sectors in the storage folder.
-	_, err := wal.managedEmptyStorageFolder(index, newSectorCount)
+	_, err := wal.managedEmptyStorageFolder(index, newSectorCount, resize)
+	if errors.Contains(err, ErrResizeCancelled) {
+		// Wait for the moves that did complete to be synced before
+		// returning, so that the folder is consistent once the resize
+		// returns.
+		wal.mu.Lock()
+		syncChan := wal.syncChan
+		wal.mu.Unlock()
+		<-syncChan
+		return err
+	}
 	if err != nil && !force {
 		return err
 	}
@@ -2,10 +2,15 @@ package contractmanager
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/build"
@@ -13,6 +18,23 @@ import (
 	"go.sia.tech/siad/persist"
 )
 
+var (
+	// errUnknownWALEntry is returned when the WAL contains an entry with
+	// fields that are unknown to this version and which isn't skippable.
+	errUnknownWALEntry = errors.New("WAL entry contains unknown fields and isn't skippable")
+
+	// stateChangeFields contains the lowercase names of the fields of a
+	// stateChange known to this version.
+	stateChangeFields = func() map[string]struct{} {
+		fields := make(map[string]struct{})
+		t := reflect.TypeOf(stateChange{})
+		for i := 0; i < t.NumField(); i++ {
+			fields[strings.ToLower(t.Field(i).Name)] = struct{}{}
+		}
+		return fields
+	}()
+)
+
 type (
 	// sectorUpdate is an idempotent update to the sector metadata.
 	sectorUpdate struct {
@@ -54,6 +76,7 @@ type (
 		StorageFolderExtensions           []storageFolderExtension
 		StorageFolderRemovals             []storageFolderRemoval
 		StorageFolderReductions           []storageFolderReduction
+		StorageFolderReadOnlyUpdates      []storageFolderReadOnlyUpdate `json:",omitempty"`
 		UnfinishedStorageFolderAdditions  []savedStorageFolder
 		UnfinishedStorageFolderExtensions []unfinishedStorageFolderExtension
 
@@ -61,6 +84,17 @@ type (
 		// that a sector update will not make it into the synced WAL unless the
 		// sector data is already on-disk and synced.
 		SectorUpdates []sectorUpdate
+
+		// SectorMigrationUpdates record the progress of a migration of
+		// sectors between storage folders. They are part of the same state
+		// change as the sector updates of the moves they account for.
+		SectorMigrationUpdates []sectorMigration `json:",omitempty"`
+
+		// Skippable is set by versions that add new fields to the state
+		// change if older versions may ignore those fields. Older versions
+		// refuse to recover entries with unknown fields that aren't
+		// skippable.
+		Skippable bool `json:",omitempty"`
 	}
 
 	// writeAheadLog coordinates ACID transactions which update the state of
@@ -96,6 +130,36 @@ type (
 		uncommittedChanges []stateChange
 		committedSettings  savedSettings
 
+		// changeChan is signaled whenever a change is appended, so that the
+		// sync loop can open a sync window for the next commit. Every change
+		// that arrives within the sync window is committed together.
+		//
+		// dirtyFolders are the indices of the storage folders with sector
+		// updates since the last commit. Only their files need to be synced
+		// by the next commit, unless syncAllFolders is set because a change
+		// affected the storage folders themselves.
+		changeChan     chan struct{}
+		dirtyFolders   map[uint16]struct{}
+		syncAllFolders bool
+
+		// commitLatencies are the latencies of the most recent commits of the
+		// sync loop. slowCommits indicates whether their average latency
+		// exceeded slowCommitThreshold the last time it was checked.
+		commitLatencies []time.Duration
+		slowCommits     bool
+
+		// generation is incremented by every commit that contains changes
+		// and by the recovery of a WAL, and is saved with the settings. A
+		// snapshot of the sector locations that was taken at the same
+		// generation as the settings matches the state that the settings
+		// describe. snapshotGeneration and snapshotTime are the generation
+		// and time of the most recent snapshot, snapshotMu serializes writing
+		// snapshots to disk.
+		generation         uint64
+		snapshotGeneration uint64
+		snapshotTime       time.Time
+		snapshotMu         sync.Mutex
+
 		// Utilities. The WAL needs access to the ContractManager because all
 		// mutations to ACID fields of the contract manager happen through the
 		// WAL.
@@ -121,6 +185,39 @@ func readWALMetadata(decoder *json.Decoder) error {
 	return nil
 }
 
+// decodeStateChange decodes a single WAL entry. It returns the fields of the
+// entry that are unknown to this version, which are skipped if the entry is
+// skippable. An entry with unknown fields that isn't skippable can't be
+// recovered safely.
+func decodeStateChange(entry json.RawMessage) (sc stateChange, unknown []string, err error) {
+	err = json.Unmarshal(entry, &sc)
+	if err != nil {
+		return stateChange{}, nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(entry, &fields)
+	if err != nil {
+		return stateChange{}, nil, err
+	}
+	for field := range fields {
+		if _, exists := stateChangeFields[strings.ToLower(field)]; !exists {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+	if len(unknown) > 0 && !sc.Skippable {
+		return stateChange{}, unknown, errors.AddContext(errUnknownWALEntry, fmt.Sprint("unknown fields ", unknown))
+	}
+	return sc, unknown, nil
+}
+
+// isCorruptJSON returns true if the error returned by a json decoder indicates
+// that the input isn't valid json.
+func isCorruptJSON(err error) bool {
+	_, isSyntaxErr := err.(*json.SyntaxError)
+	return isSyntaxErr || err == io.ErrUnexpectedEOF
+}
+
 // writeWALMetadata writes WAL metadata to the input file.
 func writeWALMetadata(f modules.File) error {
 	changeBytes, err := json.MarshalIndent(walMetadata, "", "\t")
@@ -157,6 +254,37 @@ func (wal *writeAheadLog) appendChange(sc stateChange) {
 	// Update the WAL to include the new storage folder in the uncommitted
 	// changes.
 	wal.uncommittedChanges = append(wal.uncommittedChanges, sc)
+
+	// Remember which storage folders need to be synced by the next commit.
+	if sc.changesStorageFolders() {
+		wal.syncAllFolders = true
+	}
+	for _, su := range sc.SectorUpdates {
+		if wal.dirtyFolders == nil {
+			wal.dirtyFolders = make(map[uint16]struct{})
+		}
+		wal.dirtyFolders[su.Folder] = struct{}{}
+	}
+
+	// Let the sync loop know that there is a change to commit.
+	select {
+	case wal.changeChan <- struct{}{}:
+	default:
+	}
+}
+
+// changesStorageFolders returns whether the state change adds, removes, resizes
+// or otherwise updates storage folders rather than only updating sectors.
+func (sc stateChange) changesStorageFolders() bool {
+	return len(sc.ErroredStorageFolderAdditions) > 0 ||
+		len(sc.ErroredStorageFolderExtensions) > 0 ||
+		len(sc.StorageFolderAdditions) > 0 ||
+		len(sc.StorageFolderExtensions) > 0 ||
+		len(sc.StorageFolderRemovals) > 0 ||
+		len(sc.StorageFolderReductions) > 0 ||
+		len(sc.StorageFolderReadOnlyUpdates) > 0 ||
+		len(sc.UnfinishedStorageFolderAdditions) > 0 ||
+		len(sc.UnfinishedStorageFolderExtensions) > 0
 }
 
 // commitChange will commit the provided change to the contract manager,
@@ -187,11 +315,21 @@ func (wal *writeAheadLog) commitChange(sc stateChange) {
 			wal.commitStorageFolderRemoval(sfr)
 		}
 	}
+	for _, u := range sc.StorageFolderReadOnlyUpdates {
+		for i := uint64(0); i < wal.cm.dependencies.AtLeastOne(); i++ {
+			wal.commitStorageFolderReadOnlyUpdate(u)
+		}
+	}
 	for _, su := range sc.SectorUpdates {
 		for i := uint64(0); i < wal.cm.dependencies.AtLeastOne(); i++ {
 			wal.commitUpdateSector(su)
 		}
 	}
+	for _, m := range sc.SectorMigrationUpdates {
+		for i := uint64(0); i < wal.cm.dependencies.AtLeastOne(); i++ {
+			wal.commitSectorMigrationUpdate(m)
+		}
+	}
 }
 
 // createWALTmp will open up the temporary WAL file.
@@ -225,24 +363,75 @@ func (wal *writeAheadLog) recoverWAL(walFile modules.File) error {
 	// Read changes from the WAL one at a time and load them back into memory.
 	// A full list of changes is kept so that modifications to long running
 	// changes can be parsed properly.
-	var sc stateChange
 	var scs []stateChange
+	var numSkipped, numCorrupt int
+	var corruptTail bool
+	skippedFields := make(map[string]struct{})
 	for err == nil {
-		err = decoder.Decode(&sc)
-		if err == nil {
-			// The uncommitted changes are loaded into memory using a simple
-			// append, because the tmp WAL file has not been created yet, and
-			// will not be created until the sync loop is spawned. The sync
-			// loop spawner will make sure that the uncommitted changes are
-			// written to the tmp WAL file.
-			wal.commitChange(sc)
-			scs = append(scs, sc)
+		var entry json.RawMessage
+		err = decoder.Decode(&entry)
+		if isCorruptJSON(err) {
+			// The remainder of the WAL can't be decoded anymore, e.g. because
+			// the last entry was only partially written. It's skipped.
+			corruptTail = true
+			err = io.EOF
+		}
+		if err != nil {
+			break
+		}
+		var sc stateChange
+		var unknown []string
+		sc, unknown, err = decodeStateChange(entry)
+		if err != nil && !errors.Contains(err, errUnknownWALEntry) {
+			// The entry is valid json but not a valid state change. Skip it
+			// and continue with the next one.
+			wal.cm.log.Println("ERROR: skipping corrupt WAL entry:", err)
+			numCorrupt++
+			err = nil
+			continue
+		}
+		if err != nil {
+			break
 		}
+		// Entries written by a newer version might contain fields this
+		// version doesn't know about. If the entry is skippable, the known
+		// fields are recovered and the unknown ones are ignored.
+		if len(unknown) > 0 {
+			numSkipped++
+			for _, field := range unknown {
+				skippedFields[field] = struct{}{}
+			}
+		}
+		// The uncommitted changes are loaded into memory using a simple
+		// append, because the tmp WAL file has not been created yet, and
+		// will not be created until the sync loop is spawned. The sync loop
+		// spawner will make sure that the uncommitted changes are written to
+		// the tmp WAL file.
+		wal.commitChange(sc)
+		scs = append(scs, sc)
 	}
 	if !errors.Contains(err, io.EOF) {
 		wal.cm.log.Println("ERROR: could not load WAL json:", err)
 		return build.ExtendErr("error loading WAL json", err)
 	}
+	if numSkipped > 0 {
+		fields := make([]string, 0, len(skippedFields))
+		for field := range skippedFields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		cause := fmt.Sprintf("skipped unknown fields %v of %v WAL entries", fields, numSkipped)
+		wal.cm.log.Println("ERROR:", cause)
+		wal.cm.staticAlerter.RegisterAlert(modules.AlertIDHostUnknownWALEntries, AlertMSGUnknownWALEntries, cause, modules.SeverityError)
+	}
+	if numCorrupt > 0 || corruptTail {
+		cause := fmt.Sprintf("skipped %v corrupt WAL entries", numCorrupt)
+		if corruptTail {
+			cause += " and the corrupt end of the WAL"
+		}
+		wal.cm.log.Println("ERROR:", cause)
+		wal.cm.staticAlerter.RegisterAlert(modules.AlertIDHostCorruptWALEntries, AlertMSGCorruptWALEntries, cause, modules.SeverityError)
+	}
 
 	// Do any cleanup regarding long-running unfinished tasks. Long running
 	// task cleanup cannot be handled in the 'commitChange' loop because future
@@ -294,6 +483,10 @@ func (wal *writeAheadLog) load() error {
 		if err != nil {
 			return build.ExtendErr("error closing WAL after performing a recovery", err)
 		}
+		// The recovered changes are part of a new generation, which makes
+		// any existing snapshot of the sector locations stale once the
+		// settings are saved.
+		wal.generation++
 	} else if !os.IsNotExist(err) {
 		return build.ExtendErr("walFile was not opened successfully", err)
 	}
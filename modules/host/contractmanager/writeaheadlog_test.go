@@ -4,9 +4,15 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/persist"
 )
@@ -47,4 +53,464 @@ func TestLoadWAL(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	// newDirWithWAL stores the legacy wal with an additional entry in a new
+	// dir.
+	newDirWithWAL := func(name, entry string) string {
+		dir := filepath.Join(testdir, name)
+		err := os.MkdirAll(dir, persist.DefaultDiskPermissionsTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := append(append([]byte{}, wal...), []byte(entry)...)
+		err = ioutil.WriteFile(filepath.Join(dir, walFile), b, persist.DefaultDiskPermissionsTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+
+	// An entry written by a future version with an unknown field that is
+	// skippable should be recovered with an alert.
+	dir := newDirWithWAL("skippable", `{"SectorUpdates":null,"FutureUpdates":[1,2,3],"Skippable":true}`)
+	cm, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasAlert := func(cm *ContractManager, id modules.AlertID, cause string) bool {
+		_, errAlerts, _, _ := cm.Alerts()
+		for _, alert := range errAlerts {
+			if alert.ID == id && strings.Contains(alert.Cause, cause) {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasAlert(cm, modules.AlertIDHostUnknownWALEntries, "FutureUpdates") {
+		t.Fatal("expected alert for the skipped entry")
+	}
+	err = cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries that are valid json but not valid state changes and a corrupt
+	// end of the WAL are skipped with an alert.
+	dir = newDirWithWAL("corrupt", `{"SectorUpdates":"corrupt"}{"SectorUpdates":null}{"SectorUpd`)
+	cm, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasAlert(cm, modules.AlertIDHostCorruptWALEntries, "skipped 1 corrupt WAL entries and the corrupt end of the WAL") {
+		t.Fatal("expected alert for the corrupt entries")
+	}
+	err = cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After a clean shutdown, the alerts are gone.
+	cm, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasAlert(cm, modules.AlertIDHostCorruptWALEntries, "") {
+		t.Fatal("alert should be gone after a clean restart")
+	}
+	err = cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An unknown field in an entry that isn't skippable is fatal.
+	dir = newDirWithWAL("fatal", `{"SectorUpdates":null,"FutureUpdates":[1,2,3]}`)
+	_, err = New(dir)
+	if err == nil || !strings.Contains(err.Error(), errUnknownWALEntry.Error()) {
+		t.Fatal("expected errUnknownWALEntry, got", err)
+	}
+}
+
+// TestDecodeStateChange is a unit test for decodeStateChange.
+func TestDecodeStateChange(t *testing.T) {
+	t.Parallel()
+
+	// known fields are decoded, field names are matched case-insensitively
+	sc, unknown, err := decodeStateChange([]byte(`{"sectorupdates":[{"Count":2}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unknown) != 0 || len(sc.SectorUpdates) != 1 || sc.SectorUpdates[0].Count != 2 {
+		t.Fatal("unexpected result", sc, unknown)
+	}
+
+	// unknown fields in a skippable entry are reported
+	sc, unknown, err = decodeStateChange([]byte(`{"SectorUpdates":[{"Count":3}],"B":1,"A":2,"Skippable":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unknown) != 2 || unknown[0] != "A" || unknown[1] != "B" || sc.SectorUpdates[0].Count != 3 {
+		t.Fatal("unexpected result", sc, unknown)
+	}
+
+	// unknown fields in an entry that isn't skippable are an error
+	_, _, err = decodeStateChange([]byte(`{"A":2}`))
+	if !errors.Contains(err, errUnknownWALEntry) {
+		t.Fatal("expected errUnknownWALEntry, got", err)
+	}
+}
+
+// dependencySlowSync is a mocked dependency that slows down syncing files once
+// it was triggered.
+type dependencySlowSync struct {
+	modules.ProductionDependencies
+	slow *uint64
+}
+
+// slowSyncFile is a file that sleeps before syncing while the dependency it
+// was created by is triggered.
+type slowSyncFile struct {
+	slow *uint64
+	*os.File
+}
+
+// CreateFile returns a file which syncs slowly once the dependency was
+// triggered.
+func (d *dependencySlowSync) CreateFile(s string) (modules.File, error) {
+	f, err := os.Create(s)
+	if err != nil {
+		return nil, err
+	}
+	return &slowSyncFile{slow: d.slow, File: f}, nil
+}
+
+// OpenFile returns a file which syncs slowly once the dependency was
+// triggered.
+func (d *dependencySlowSync) OpenFile(s string, flags int, perms os.FileMode) (modules.File, error) {
+	f, err := os.OpenFile(s, flags, perms)
+	if err != nil {
+		return nil, err
+	}
+	return &slowSyncFile{slow: d.slow, File: f}, nil
+}
+
+// Sync sleeps before syncing the file if the dependency was triggered.
+func (f *slowSyncFile) Sync() error {
+	if atomic.LoadUint64(f.slow) == 1 {
+		time.Sleep(2 * slowCommitThreshold)
+	}
+	return f.File.Sync()
+}
+
+// TestSlowWALCommitsAlert verifies that the contract manager registers an alert
+// if its WAL commits are slow and unregisters it once they are fast again.
+func TestSlowWALCommitsAlert(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	d := &dependencySlowSync{slow: new(uint64)}
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder. The sector overflow file is synced by every commit.
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slowCommitsAlert := func() (modules.Alert, bool) {
+		_, _, warn, _ := cmt.cm.Alerts()
+		for _, alert := range warn {
+			if alert.ID == modules.AlertIDHostSlowWALCommits {
+				return alert, true
+			}
+		}
+		return modules.Alert{}, false
+	}
+	if _, found := slowCommitsAlert(); found {
+		t.Fatal("commits shouldn't be slow yet")
+	}
+
+	// Slow down syncing, the alert should be registered.
+	atomic.StoreUint64(d.slow, 1)
+	var alert modules.Alert
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		var found bool
+		alert, found = slowCommitsAlert()
+		if !found {
+			return errors.New("alert wasn't registered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(alert.Cause, "average latency") {
+		t.Fatal("unexpected cause", alert.Cause)
+	}
+
+	// Once syncing is fast again, the alert is unregistered.
+	atomic.StoreUint64(d.slow, 0)
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if _, found := slowCommitsAlert(); found {
+			return errors.New("alert wasn't unregistered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTriggerWALCompaction verifies that TriggerWALCompaction commits and
+// compacts the WAL while sectors are added concurrently and that it reports
+// the reclaimed size.
+func TestTriggerWALCompaction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	d := new(dependencyNoSyncLoop)
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The closing of this channel must happen after the call to panicClose.
+	stop := make(chan struct{})
+	defer close(stop)
+	defer cmt.panicClose()
+
+	// The sync loop is disabled, the WAL is only committed by compacting it.
+	// Once the contract manager is shutting down, compacting fails and the
+	// loop only signals that a commit completed to allow for a clean
+	// shutdown.
+	var reclaimed uint64
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+			n, err := cmt.cm.TriggerWALCompaction()
+			if err != nil {
+				cmt.cm.wal.mu.Lock()
+				close(cmt.cm.wal.syncChan)
+				cmt.cm.wal.syncChan = make(chan struct{})
+				cmt.cm.wal.mu.Unlock()
+				continue
+			}
+			atomic.AddUint64(&reclaimed, n)
+		}
+	}()
+
+	// Add a storage folder and sectors in parallel.
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	roots := make([]crypto.Hash, 10)
+	errs := make([]error, len(roots))
+	for i := range roots {
+		var data []byte
+		roots[i], data = randSector()
+		wg.Add(1)
+		go func(i int, data []byte) {
+			defer wg.Done()
+			errs[i] = cmt.cm.AddSector(roots[i], data)
+		}(i, data)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, root := range roots {
+		if !cmt.cm.HasSector(root) {
+			t.Fatal("sector wasn't added")
+		}
+	}
+	if atomic.LoadUint64(&reclaimed) == 0 {
+		t.Fatal("compacting the WAL should have reclaimed space")
+	}
+
+	// The WAL is compact now, both WAL files only contain the metadata and
+	// empty changes.
+	n, err := cmt.cm.TriggerWALCompaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal("WAL should have been compact already", n)
+	}
+	walInfo, err := os.Stat(filepath.Join(cmt.cm.persistDir, walFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpInfo, err := os.Stat(filepath.Join(cmt.cm.persistDir, walFileTmp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if walInfo.Size() >= 2*tmpInfo.Size() {
+		t.Fatal("WAL file should be compact", walInfo.Size(), tmpInfo.Size())
+	}
+}
+
+// TestSyncWindow checks that the sync window follows the average latency of
+// the recent commits within its bounds.
+func TestSyncWindow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		latencies []time.Duration
+		window    time.Duration
+	}{
+		{nil, minSyncWindow},
+		{[]time.Duration{minSyncWindow / 2}, minSyncWindow},
+		{[]time.Duration{maxSyncWindow / 4, maxSyncWindow / 2}, maxSyncWindow * 3 / 8},
+		{[]time.Duration{maxSyncWindow, 3 * maxSyncWindow}, maxSyncWindow},
+	}
+	for i, test := range tests {
+		wal := writeAheadLog{commitLatencies: test.latencies}
+		if window := wal.syncWindow(); window != test.window {
+			t.Errorf("%v: expected window %v, got %v", i, test.window, window)
+		}
+	}
+}
+
+// dependencyCountSyncs is a mocked dependency that counts how often the files
+// it opens are synced.
+type dependencyCountSyncs struct {
+	modules.ProductionDependencies
+	syncs map[string]int
+	mu    sync.Mutex
+}
+
+// countSyncsFile is a file that counts its syncs.
+type countSyncsFile struct {
+	d *dependencyCountSyncs
+	*os.File
+}
+
+// CreateFile returns a file which counts its syncs.
+func (d *dependencyCountSyncs) CreateFile(s string) (modules.File, error) {
+	f, err := os.Create(s)
+	if err != nil {
+		return nil, err
+	}
+	return &countSyncsFile{d: d, File: f}, nil
+}
+
+// OpenFile returns a file which counts its syncs.
+func (d *dependencyCountSyncs) OpenFile(s string, flags int, perms os.FileMode) (modules.File, error) {
+	f, err := os.OpenFile(s, flags, perms)
+	if err != nil {
+		return nil, err
+	}
+	return &countSyncsFile{d: d, File: f}, nil
+}
+
+// Sync counts the sync before syncing the file.
+func (f *countSyncsFile) Sync() error {
+	f.d.mu.Lock()
+	f.d.syncs[f.Name()]++
+	f.d.mu.Unlock()
+	return f.File.Sync()
+}
+
+// numSyncs returns how often the file at the given path was synced.
+func (d *dependencyCountSyncs) numSyncs(path string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.syncs[path]
+}
+
+// resetSyncs resets the counters of all files.
+func (d *dependencyCountSyncs) resetSyncs() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.syncs = make(map[string]int)
+}
+
+// TestBatchedCommitSyncs checks that sectors which are added concurrently are
+// committed together with a single sync of the files of their storage folder,
+// and that the files of other storage folders aren't synced.
+func TestBatchedCommitSyncs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	d := &dependencyCountSyncs{syncs: make(map[string]int)}
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add two storage folders and mark the second one read-only, so that all
+	// sectors are added to the first one.
+	storageFolderOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	storageFolderTwo := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	for _, dir := range []string{storageFolderOne, storageFolderTwo} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmt.cm.AddStorageFolder(dir, modules.SectorSize*storageFolderGranularity); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sf := range cmt.cm.StorageFolders() {
+		if sf.Path != storageFolderTwo {
+			continue
+		}
+		if err := cmt.cm.SetStorageFolderReadOnly(sf.Index, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add sectors concurrently.
+	d.resetSyncs()
+	numSectors := 32
+	var wg sync.WaitGroup
+	for i := 0; i < numSectors; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			root, data := randSector()
+			if err := cmt.cm.AddSector(root, data); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The files of the first storage folder should have been synced at least
+	// once, but not once per sector. The files of the second storage folder
+	// shouldn't have been synced at all.
+	for _, file := range []string{metadataFile, sectorFile} {
+		n := d.numSyncs(filepath.Join(storageFolderOne, file))
+		if n == 0 || n >= numSectors {
+			t.Errorf("%v of the first storage folder was synced %v times for %v sectors", file, n, numSectors)
+		}
+		if n := d.numSyncs(filepath.Join(storageFolderTwo, file)); n != 0 {
+			t.Errorf("%v of the untouched storage folder was synced %v times", file, n)
+		}
+	}
 }
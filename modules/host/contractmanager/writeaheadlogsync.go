@@ -2,17 +2,21 @@ package contractmanager
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
 )
 
-// syncResources will call Sync on all resources that the WAL has open. The
-// storage folder files will be left open, as they are not updated atomically.
-// The settings file and WAL tmp files will be synced and closed, to perform an
+// syncResources will call Sync on all resources that the WAL has open. Only
+// the files of storage folders that were written to since the last commit are
+// synced, and they are left open, as they are not updated atomically. The
+// settings file and WAL tmp files will be synced and closed, to perform an
 // atomic update to the files.
 func (wal *writeAheadLog) syncResources() {
 	// Syncing occurs over multiple files and disks, and is done in parallel to
@@ -40,6 +44,7 @@ func (wal *writeAheadLog) syncResources() {
 		if err != nil {
 			wal.cm.log.Println("unable to close the temporary contract manager settings file:", err)
 		}
+		wal.fileSettingsTmp = nil
 
 		// For testing, provide a place to interrupt the saving of the sync
 		// file. This makes it easy to simulate certain types of unclean
@@ -56,13 +61,19 @@ func (wal *writeAheadLog) syncResources() {
 		}
 	}()
 
-	// Sync all of the storage folders.
+	// Sync the storage folders that were written to since the last commit.
+	// Every file is synced once per commit, no matter how many sectors of the
+	// commit it houses.
 	wal.cm.sectorMu.Lock()
-	sfs := make([]*storageFolder, 0, len(wal.cm.storageFolders))
+	sfs := make([]*storageFolder, 0, len(wal.dirtyFolders))
 	for _, sf := range wal.cm.storageFolders {
-		sfs = append(sfs, sf)
+		if _, dirty := wal.dirtyFolders[sf.index]; dirty || wal.syncAllFolders {
+			sfs = append(sfs, sf)
+		}
 	}
 	wal.cm.sectorMu.Unlock()
+	wal.dirtyFolders = nil
+	wal.syncAllFolders = false
 	for _, sf := range sfs {
 		// Skip operation on unavailable storage folders.
 		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
@@ -74,6 +85,7 @@ func (wal *writeAheadLog) syncResources() {
 			defer wg.Done()
 			err := sf.metadataFile.Sync()
 			if err != nil {
+				wal.cm.managedRecordWrite(sf, err)
 				wal.cm.log.Severe("ERROR: unable to sync a storage folder:", err)
 			}
 		}(sf)
@@ -81,6 +93,7 @@ func (wal *writeAheadLog) syncResources() {
 			defer wg.Done()
 			err := sf.sectorFile.Sync()
 			if err != nil {
+				wal.cm.managedRecordWrite(sf, err)
 				wal.cm.log.Severe("ERROR: unable to sync a storage folder:", err)
 			}
 		}(sf)
@@ -169,6 +182,11 @@ func (wal *writeAheadLog) commit() {
 	// Sync all open, non-WAL files on the host.
 	wal.syncResources()
 
+	// The changes were committed, the new state is a new generation.
+	if len(wal.uncommittedChanges) != 0 {
+		wal.generation++
+	}
+
 	// Begin writing to the settings file.
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -245,6 +263,35 @@ func (wal *writeAheadLog) commit() {
 	wg.Wait()
 }
 
+// trackCommitLatency adds the latency of a commit to the recent commit
+// latencies. If the average latency of the last slowCommitWindow commits
+// exceeds slowCommitThreshold, an alert is registered since slow syncs are an
+// early sign of a failing disk. The alert is unregistered once the average
+// drops below the threshold again.
+func (wal *writeAheadLog) trackCommitLatency(latency time.Duration) {
+	wal.commitLatencies = append(wal.commitLatencies, latency)
+	if len(wal.commitLatencies) > slowCommitWindow {
+		wal.commitLatencies = wal.commitLatencies[len(wal.commitLatencies)-slowCommitWindow:]
+	}
+	if len(wal.commitLatencies) < slowCommitWindow {
+		return
+	}
+	var total time.Duration
+	for _, l := range wal.commitLatencies {
+		total += l
+	}
+	avg := total / time.Duration(len(wal.commitLatencies))
+	slow := avg > slowCommitThreshold
+	if slow && !wal.slowCommits {
+		cause := fmt.Sprintf("average latency of the last %v commits was %v, expected less than %v", len(wal.commitLatencies), avg.Round(time.Millisecond), slowCommitThreshold)
+		wal.cm.log.Println("WARN:", cause)
+		wal.cm.staticAlerter.RegisterAlert(modules.AlertIDHostSlowWALCommits, AlertMSGSlowWALCommits, cause, modules.SeverityWarning)
+	} else if !slow && wal.slowCommits {
+		wal.cm.staticAlerter.UnregisterAlert(modules.AlertIDHostSlowWALCommits)
+	}
+	wal.slowCommits = slow
+}
+
 // spawnSyncLoop prepares and establishes the loop which will be running in the
 // background to coordinate disk syncronizations. Disk syncing is done in a
 // background loop to help with performance, and to allow multiple things to
@@ -255,6 +302,7 @@ func (wal *writeAheadLog) spawnSyncLoop() (err error) {
 	threadsStopped := make(chan struct{})
 	syncLoopStopped := make(chan struct{})
 	wal.syncChan = make(chan struct{})
+	wal.changeChan = make(chan struct{}, 1)
 	go wal.threadedSyncLoop(threadsStopped, syncLoopStopped)
 	wal.cm.tg.AfterStop(func() {
 		// Wait for another iteration of the sync loop, so that the in-progress
@@ -275,6 +323,20 @@ func (wal *writeAheadLog) spawnSyncLoop() (err error) {
 		// should be zero.
 		<-syncLoopStopped // Wait for the sync loop to signal proper termination.
 
+		// Save a snapshot of the final sector locations, so that they don't
+		// need to be rebuilt from the storage folders at the next startup.
+		wal.mu.Lock()
+		var snapshot []byte
+		if wal.generation != 0 {
+			snapshot = wal.snapshotSectorLocations()
+		}
+		wal.mu.Unlock()
+		if snapshot != nil {
+			if err := wal.managedSaveSectorLocationSnapshot(snapshot); err != nil {
+				wal.cm.log.Println("ERROR: unable to save the sector location snapshot during contract manager shutdown:", err)
+			}
+		}
+
 		// Allow unclean shutdown to be simulated by disrupting the removal of
 		// the WAL file.
 		if !wal.cm.dependencies.Disrupt("cleanWALFile") {
@@ -287,10 +349,35 @@ func (wal *writeAheadLog) spawnSyncLoop() (err error) {
 	return nil
 }
 
+// syncWindow returns how long the sync loop waits for more changes to join a
+// commit. The window matches the average latency of the recent commits, so
+// that under light load changes are committed right away while under heavy
+// load, when syncing takes long, more changes are batched into each commit.
+func (wal *writeAheadLog) syncWindow() time.Duration {
+	var total time.Duration
+	for _, l := range wal.commitLatencies {
+		total += l
+	}
+	var window time.Duration
+	if len(wal.commitLatencies) > 0 {
+		window = total / time.Duration(len(wal.commitLatencies))
+	}
+	if window > maxSyncWindow {
+		window = maxSyncWindow
+	}
+	if window < minSyncWindow {
+		window = minSyncWindow
+	}
+	return window
+}
+
 // threadedSyncLoop is a background thread that occasionally commits the WAL to
 // the state as an ACID transaction. This process can be very slow, so
 // transactions to the contract manager are batched automatically and
-// occasionally committed together.
+// committed together. The first change after a commit opens a sync window,
+// and all changes that arrive within the window are committed with a single
+// WAL commit and a single sync of every affected file. Without changes, the
+// loop still commits every maxSyncWindow to save the settings.
 func (wal *writeAheadLog) threadedSyncLoop(threadsStopped chan struct{}, syncLoopStopped chan struct{}) {
 	// Provide a place for the testing to disable the sync loop.
 	if wal.cm.dependencies.Disrupt("threadedSyncLoopStart") {
@@ -298,18 +385,83 @@ func (wal *writeAheadLog) threadedSyncLoop(threadsStopped chan struct{}, syncLoo
 		return
 	}
 
-	syncInterval := 500 * time.Millisecond
+	window := minSyncWindow
 	for {
 		select {
 		case <-threadsStopped:
 			close(syncLoopStopped)
 			return
-		case <-time.After(syncInterval):
-			// Commit all of the changes in the WAL to disk, and then apply the
-			// changes.
-			wal.mu.Lock()
-			wal.commit()
-			wal.mu.Unlock()
+		case <-wal.changeChan:
+			// Give other changes the sync window to join the commit.
+			select {
+			case <-threadsStopped:
+				close(syncLoopStopped)
+				return
+			case <-time.After(window):
+			}
+		case <-time.After(maxSyncWindow):
+		}
+
+		// Commit all of the changes in the WAL to disk, and then apply the
+		// changes.
+		wal.mu.Lock()
+		start := time.Now()
+		wal.commit()
+		wal.trackCommitLatency(time.Since(start))
+		window = wal.syncWindow()
+		wal.queueSectorLocationSnapshot()
+
+		// The commit itself appends a change to the new WAL file which
+		// doesn't need to be committed right away.
+		select {
+		case <-wal.changeChan:
+		default:
 		}
+		wal.mu.Unlock()
+	}
+}
+
+// diskSize returns the combined size of the WAL file and the temporary
+// WAL file on disk. Files that don't exist count as empty.
+func (wal *writeAheadLog) diskSize() uint64 {
+	var size uint64
+	for _, name := range []string{walFile, walFileTmp} {
+		fi, err := os.Stat(filepath.Join(wal.cm.persistDir, name))
+		if err == nil {
+			size += uint64(fi.Size())
+		}
+	}
+	return size
+}
+
+// TriggerWALCompaction commits the WAL outside of the regular schedule of the
+// sync loop and compacts it. The first commit applies the pending changes and
+// leaves the temporary WAL file with the unfinished long-running changes only.
+// An empty change is appended so that the second commit replaces the WAL file
+// with the compacted temporary file. The call blocks until the compaction is
+// done and returns the number of bytes that were reclaimed on disk. It is safe
+// to call concurrently with other operations, which are blocked for the
+// duration of the commits.
+func (cm *ContractManager) TriggerWALCompaction() (uint64, error) {
+	err := cm.tg.Add()
+	if err != nil {
+		return 0, err
+	}
+	defer cm.tg.Done()
+
+	cm.wal.mu.Lock()
+	defer cm.wal.mu.Unlock()
+	before := cm.wal.diskSize()
+	start := time.Now()
+	cm.wal.commit()
+	cm.wal.trackCommitLatency(time.Since(start))
+	cm.wal.appendChange(stateChange{})
+	start = time.Now()
+	cm.wal.commit()
+	cm.wal.trackCommitLatency(time.Since(start))
+	after := cm.wal.diskSize()
+	if after >= before {
+		return 0, nil
 	}
+	return before - after, nil
 }
@@ -447,7 +447,6 @@ func newHost(dependencies modules.Dependencies, smDeps modules.Dependencies, cs
 		g:                        g,
 		tpool:                    tpool,
 		wallet:                   wallet,
-		staticAlerter:            modules.NewAlerter("host"),
 		staticMux:                mux,
 		dependencies:             dependencies,
 		lockedStorageObligations: make(map[types.FileContractID]*lockedObligation),
@@ -493,6 +492,20 @@ func newHost(dependencies modules.Dependencies, smDeps modules.Dependencies, cs
 		}
 	})
 
+	// Load the alerts that were registered before the last shutdown, and save
+	// them again when shutting down.
+	var alertErr error
+	h.staticAlerter, alertErr = modules.NewPersistedAlerter("host", h.persistDir)
+	if alertErr != nil {
+		h.log.Println("WARN: discarding persisted alerts:", alertErr)
+	}
+	h.tg.AfterStop(func() {
+		err := h.staticAlerter.Close()
+		if err != nil {
+			h.log.Println("Could not save alerts upon shutdown:", err)
+		}
+	})
+
 	// Add the storage manager to the host, and set up the stop call that will
 	// close the storage manager.
 	h.StorageManager, err = contractmanager.NewCustomContractManager(smDeps, filepath.Join(persistDir, "contractmanager"))
@@ -513,6 +526,18 @@ func newHost(dependencies modules.Dependencies, smDeps modules.Dependencies, cs
 	if err != nil {
 		return nil, err
 	}
+
+	// Configure the storage manager's scrub with the loaded settings.
+	err = h.StorageManager.SetScrubSettings(h.managedInternalSettings().ScrubSettings())
+	if err != nil {
+		return nil, err
+	}
+
+	// A restored insufficient collateral alert might no longer apply with the
+	// loaded settings and financial metrics.
+	h.mu.Lock()
+	h.tryUnregisterInsufficientCollateralBudgetAlert()
+	h.mu.Unlock()
 	h.tg.AfterStop(func() {
 		err := h.saveSync()
 		if err != nil {
@@ -563,6 +588,9 @@ func newHost(dependencies modules.Dependencies, smDeps modules.Dependencies, cs
 	// Ensure the expired RPC tables get pruned as to not leak memory
 	go h.threadedPruneExpiredPriceTables()
 
+	// Periodically check the conditions of the storage and collateral alerts
+	go h.threadedCheckAlerts()
+
 	return h, nil
 }
 
@@ -725,6 +753,18 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 		}
 	}
 
+	// Apply the new scrub settings to the storage manager. An enabled scrub
+	// needs an interval, otherwise it would never stop scrubbing.
+	if settings.ScrubEnabled && settings.ScrubInterval <= 0 {
+		return errors.New("internal settings not updated, the scrub interval must be positive")
+	}
+	if h.settings.ScrubSettings() != settings.ScrubSettings() {
+		err := h.StorageManager.SetScrubSettings(settings.ScrubSettings())
+		if err != nil {
+			return errors.AddContext(err, "scrub settings not updated")
+		}
+	}
+
 	h.settings = settings
 	h.revisionNumber++
 
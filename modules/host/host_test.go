@@ -1396,10 +1396,21 @@ func TestSetAndGetInternalSettings(t *testing.T) {
 	if !settings.MaxEphemeralAccountRisk.Equals(defaultMaxEphemeralAccountRisk) {
 		t.Error("settings retrieval did not return default value")
 	}
+	if settings.ScrubEnabled || settings.ScrubMarkCorrupt {
+		t.Error("settings retrieval did not return default value")
+	}
+	if settings.ScrubInterval != defaultScrubInterval {
+		t.Error("settings retrieval did not return default value")
+	}
+	if settings.ScrubMaxBytesPerSecond != defaultScrubMaxBytesPerSecond {
+		t.Error("settings retrieval did not return default value")
+	}
 
 	// Check that calling SetInternalSettings with valid settings updates the settings.
 	settings.AcceptingContracts = true
 	settings.NetAddress = "foo.com:123"
+	settings.ScrubEnabled = true
+	settings.ScrubMarkCorrupt = true
 	err = ht.host.SetInternalSettings(settings)
 	if err != nil {
 		t.Fatal(err)
@@ -1411,6 +1422,9 @@ func TestSetAndGetInternalSettings(t *testing.T) {
 	if settings.NetAddress != "foo.com:123" {
 		t.Fatal("SetInternalSettings failed to update settings")
 	}
+	if !settings.ScrubEnabled || !settings.ScrubMarkCorrupt {
+		t.Fatal("SetInternalSettings failed to update settings")
+	}
 
 	// Check that calling SetInternalSettings with invalid settings does not update the settings.
 	settings.NetAddress = "invalid"
@@ -1423,6 +1437,14 @@ func TestSetAndGetInternalSettings(t *testing.T) {
 		t.Fatal("SetInternalSettings should not modify the settings if the new settings are invalid")
 	}
 
+	// An enabled scrub needs an interval.
+	settings.ScrubInterval = 0
+	err = ht.host.SetInternalSettings(settings)
+	if err == nil {
+		t.Fatal("expected SetInternalSettings to error without a scrub interval")
+	}
+	settings = ht.host.InternalSettings()
+
 	// Reload the host and verify that the altered settings persisted.
 	err = ht.host.Close()
 	if err != nil {
@@ -1439,6 +1461,9 @@ func TestSetAndGetInternalSettings(t *testing.T) {
 	if rebootSettings.NetAddress != settings.NetAddress {
 		t.Error("settings retrieval did not return updated value")
 	}
+	if rebootSettings.ScrubSettings() != settings.ScrubSettings() {
+		t.Error("settings retrieval did not return updated value")
+	}
 
 	// Set ht.host to 'rebootHost' so that the 'ht.Close()' method will close
 	// everything cleanly.
@@ -75,6 +75,9 @@ func (h *Host) establishDefaults() error {
 		EphemeralAccountExpiry:     modules.DefaultEphemeralAccountExpiry,
 		MaxEphemeralAccountBalance: modules.DefaultMaxEphemeralAccountBalance,
 		MaxEphemeralAccountRisk:    defaultMaxEphemeralAccountRisk,
+
+		ScrubInterval:          defaultScrubInterval,
+		ScrubMaxBytesPerSecond: defaultScrubMaxBytesPerSecond,
 	}
 
 	// Load the host's key pair, use the same keys as the SiaMux.
@@ -221,7 +224,14 @@ func (h *Host) load() error {
 		h.settings.MinSectorAccessPrice = maxSectorAccessPrice
 		updated = true
 	}
-	// If we updated the Price values we should save the changes to disk
+	// Hosts that were created before the scrub was added don't have a scrub
+	// interval yet.
+	if h.settings.ScrubInterval == 0 {
+		h.settings.ScrubInterval = defaultScrubInterval
+		h.settings.ScrubMaxBytesPerSecond = defaultScrubMaxBytesPerSecond
+		updated = true
+	}
+	// If we updated any values we should save the changes to disk
 	if updated {
 		err = h.saveSync()
 		if err != nil {
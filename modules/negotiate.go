@@ -1044,6 +1044,79 @@ func RenterPayoutsPreTax(host HostDBEntry, funding, txnFee, basePrice, baseColla
 	return
 }
 
+// EstimateContractCost estimates the total cost of a contract with the host
+// over the allowance's period, given the resources the allowance expects to use
+// in a single contract. This includes the contract price and transaction fees
+// of one expected early renewal, the expected storage, upload and download
+// costs and the siafund fee.
+//
+// REMINDER: The allowance contains an absolute number of bytes for expected
+// storage on a per-renter basis that doesn't account for redundancy. This value
+// needs to be adjusted to a per-contract basis that accounts for redundancy.
+// The upload and download values also do not account for redundancy, and they
+// are on a per-block basis, meaning you need to multiply be the allowance
+// period when working with these values.
+func EstimateContractCost(entry HostDBEntry, allowance Allowance, txnFees types.Currency) (types.Currency, error) {
+	// Divide by zero mitigation.
+	if allowance.Hosts == 0 {
+		allowance.Hosts = 1
+	}
+	if allowance.Period == 0 {
+		allowance.Period = 1
+	}
+	if allowance.ExpectedStorage == 0 {
+		allowance.ExpectedStorage = 1
+	}
+	if allowance.ExpectedUpload == 0 {
+		allowance.ExpectedUpload = 1
+	}
+	if allowance.ExpectedDownload == 0 {
+		allowance.ExpectedDownload = 1
+	}
+	if allowance.ExpectedRedundancy == 0 {
+		allowance.ExpectedRedundancy = 1
+	}
+
+	// Convert each element of the allowance into a number of resources that we
+	// expect to use in this contract.
+	contractExpectedDownload := types.NewCurrency64(allowance.ExpectedDownload).Mul64(uint64(allowance.Period)).Div64(allowance.Hosts)
+	contractExpectedFunds := allowance.Funds.Div64(allowance.Hosts)
+	contractExpectedStorage := uint64(float64(allowance.ExpectedStorage) * allowance.ExpectedRedundancy / float64(allowance.Hosts))
+	contractExpectedStorageTime := types.NewCurrency64(contractExpectedStorage).Mul64(uint64(allowance.Period))
+	contractExpectedUpload := types.NewCurrency64(allowance.ExpectedUpload).Mul64(uint64(allowance.Period)).MulFloat(allowance.ExpectedRedundancy).Div64(allowance.Hosts)
+
+	// Get the extra costs expected for downloads and uploads from the sector access
+	// price and base price.
+	extraCostsPerRPC := entry.BaseRPCPrice.Add(entry.SectorAccessPrice)
+
+	contractExpectedDownloadRPCs := contractExpectedDownload.Div64(StreamDownloadSize)
+	extraDownloadRPCCost := contractExpectedDownloadRPCs.Mul(extraCostsPerRPC)
+
+	contractExpectedUploadRPCs := contractExpectedUpload.Div64(StreamUploadSize)
+	extraUploadRPCCost := contractExpectedUploadRPCs.Mul(extraCostsPerRPC)
+
+	// Calculate the hostCollateral the renter would expect the host to put
+	// into a contract.
+	contractTxnFees := txnFees.Mul64(EstimatedFileContractTransactionSetSize)
+	_, _, hostCollateral, err := RenterPayoutsPreTax(entry, contractExpectedFunds, contractTxnFees, types.ZeroCurrency, types.ZeroCurrency, allowance.Period, contractExpectedStorage)
+	if err != nil {
+		return types.ZeroCurrency, err
+	}
+
+	// Determine the pricing for each type of resource in the contract. We have
+	// already converted the resources into absolute terms for this contract.
+	//
+	// The contract price and transaction fees get doubled because we expect
+	// that there will be on average one early renewal per contract, due to
+	// spending all of the contract's money.
+	contractPrice := entry.ContractPrice.Add(txnFees).Mul64(2)
+	downloadPrice := entry.DownloadBandwidthPrice.Mul(contractExpectedDownload).Add(extraDownloadRPCCost)
+	storagePrice := entry.StoragePrice.Mul(contractExpectedStorageTime)
+	uploadPrice := entry.UploadBandwidthPrice.Mul(contractExpectedUpload).Add(extraUploadRPCCost)
+	siafundFee := contractPrice.Add(hostCollateral).Add(downloadPrice).Add(storagePrice).Add(uploadPrice).MulTax()
+	return contractPrice.Add(downloadPrice).Add(storagePrice).Add(uploadPrice).Add(siafundFee), nil
+}
+
 // RPCBeginSubscription begins a subscription on a new stream and returns
 // it.
 func RPCBeginSubscription(stream siamux.Stream, host types.SiaPublicKey, pt *RPCPriceTable, accID AccountID, accSK crypto.SecretKey, initialBudget types.Currency, bh types.BlockHeight, subscriber types.Specifier) error {
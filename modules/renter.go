@@ -612,6 +612,68 @@ type RenterPriceEstimation struct {
 	UploadTerabyte types.Currency `json:"uploadterabyte"`
 }
 
+// The following consts are the gouging checks that a host can be exempted from
+// through a GougingExemption.
+const (
+	// GougingCheckDownload is the gouging check performed before downloading
+	// data from a host. It also covers reading registry entries from a host.
+	GougingCheckDownload GougingCheck = "download"
+	// GougingCheckFundAccount is the gouging check performed before refilling
+	// an ephemeral account on a host.
+	GougingCheckFundAccount GougingCheck = "fundaccount"
+	// GougingCheckHasSector is the gouging check performed before querying a
+	// host for the sectors it stores.
+	GougingCheckHasSector GougingCheck = "hassector"
+	// GougingCheckPriceTable is the gouging check performed before paying for
+	// a host's price table.
+	GougingCheckPriceTable GougingCheck = "pricetable"
+	// GougingCheckSnapshot is the gouging check performed before uploading or
+	// downloading a snapshot to or from a host.
+	GougingCheckSnapshot GougingCheck = "snapshot"
+	// GougingCheckUpload is the gouging check performed before uploading data
+	// to a host. It also covers updating registry entries on a host.
+	GougingCheckUpload GougingCheck = "upload"
+)
+
+type (
+	// GougingCheck identifies one of the renter's price gouging checks.
+	GougingCheck string
+
+	// GougingExemption exempts a host from the renter's price gouging checks.
+	// This allows users to keep using a host regardless of its prices, e.g. a
+	// host that they run themselves.
+	GougingExemption struct {
+		HostKey types.SiaPublicKey `json:"hostkey"`
+
+		// Checks is the set of checks the host is exempt from. If empty, the
+		// host is exempt from all checks.
+		Checks []GougingCheck `json:"checks"`
+	}
+)
+
+// Exempts returns true if the exemption covers the given gouging check.
+func (ge GougingExemption) Exempts(check GougingCheck) bool {
+	if len(ge.Checks) == 0 {
+		return true
+	}
+	for _, c := range ge.Checks {
+		if c == check {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid returns an error if the gouging check is unknown.
+func (gc GougingCheck) IsValid() error {
+	switch gc {
+	case GougingCheckDownload, GougingCheckFundAccount, GougingCheckHasSector,
+		GougingCheckPriceTable, GougingCheckSnapshot, GougingCheckUpload:
+		return nil
+	}
+	return fmt.Errorf("unknown gouging check '%v'", gc)
+}
+
 // RenterSettings control the behavior of the Renter.
 type RenterSettings struct {
 	Allowance        Allowance     `json:"allowance"`
@@ -619,6 +681,13 @@ type RenterSettings struct {
 	MaxUploadSpeed   int64         `json:"maxuploadspeed"`
 	MaxDownloadSpeed int64         `json:"maxdownloadspeed"`
 	UploadsStatus    UploadsStatus `json:"uploadsstatus"`
+
+	// PriceAnomalyDetection indicates whether the workers compare a host's
+	// price table against the settings recorded during its most recent scan.
+	// PriceAnomalyFactor is the factor by which a price is allowed to increase
+	// in between scans before the host's price table is rejected.
+	PriceAnomalyDetection bool    `json:"priceanomalydetection"`
+	PriceAnomalyFactor    float64 `json:"priceanomalyfactor"`
 }
 
 // UploadsStatus contains information about the Renter's Uploads
@@ -742,6 +811,15 @@ type RenterContract struct {
 	ContractFee types.Currency
 	TxnFee      types.Currency
 	SiafundFee  types.Currency
+
+	// NumSectors is the number of sectors tracked by the contract's
+	// refcounter. GarbageSectors is the number of those sectors that aren't
+	// referenced anymore and can be removed from the contract.
+	// RefCounterSize is the size of the refcounter's file in bytes. They are
+	// zero for contracts without a refcounter.
+	NumSectors     uint64
+	GarbageSectors uint64
+	RefCounterSize uint64
 }
 
 // SpendingDetails is a helper struct that contains a breakdown of where exactly
@@ -781,6 +859,43 @@ func (x MaintenanceSpending) Sum() types.Currency {
 	return x.AccountBalanceCost.Add(x.FundAccountCost).Add(x.UpdatePriceTableCost)
 }
 
+// GougingSpending contains a breakdown of the money the renter spent on RPCs
+// with hosts, grouped by the prices that are protected by the gouging checks.
+// Comparing these numbers to the allowance makes it possible to audit the
+// assumptions the gouging checks make about how the allowance is spent.
+//
+// AccountFees covers funding ephemeral accounts and syncing their balances.
+// BaseRPC covers the base cost of executing programs as well as updating price
+// tables. Registry covers reading and updating registry entries, SectorAccess
+// covers looking up and reading sectors. Bandwidth is reported separately.
+type GougingSpending struct {
+	AccountFees       types.Currency `json:"accountfees"`
+	BaseRPC           types.Currency `json:"baserpc"`
+	DownloadBandwidth types.Currency `json:"downloadbandwidth"`
+	Registry          types.Currency `json:"registry"`
+	SectorAccess      types.Currency `json:"sectoraccess"`
+	UploadBandwidth   types.Currency `json:"uploadbandwidth"`
+}
+
+// Add is a convenience function that sums the fields of the spending object
+// with the corresponding fields of the given object.
+func (x GougingSpending) Add(y GougingSpending) GougingSpending {
+	return GougingSpending{
+		AccountFees:       x.AccountFees.Add(y.AccountFees),
+		BaseRPC:           x.BaseRPC.Add(y.BaseRPC),
+		DownloadBandwidth: x.DownloadBandwidth.Add(y.DownloadBandwidth),
+		Registry:          x.Registry.Add(y.Registry),
+		SectorAccess:      x.SectorAccess.Add(y.SectorAccess),
+		UploadBandwidth:   x.UploadBandwidth.Add(y.UploadBandwidth),
+	}
+}
+
+// Sum is a convenience function that sums up all of the fields in the spending
+// object and returns the total as a types.Currency.
+func (x GougingSpending) Sum() types.Currency {
+	return x.AccountFees.Add(x.BaseRPC).Add(x.DownloadBandwidth).Add(x.Registry).Add(x.SectorAccess).Add(x.UploadBandwidth)
+}
+
 // Size returns the contract size
 func (rc *RenterContract) Size() uint64 {
 	var size uint64
@@ -874,6 +989,31 @@ type (
 		TotalMaintenanceCoolDown int            `json:"totalmaintenancecooldown"`
 		TotalUploadCoolDown      int            `json:"totaluploadcooldown"`
 		Workers                  []WorkerStatus `json:"workers"`
+
+		// TotalPCWSGougingRejections is the number of times a worker was
+		// rejected by a chunk worker set because its host was price gouging.
+		TotalPCWSGougingRejections uint64 `json:"totalpcwsgougingrejections"`
+
+		// HasSectorRateLimitUtilization is the fraction of the HasSector rate
+		// limiter's burst that is currently used up, TotalHasSectorRateLimitSkips
+		// is the number of times a worker was skipped because the limiter was
+		// saturated.
+		HasSectorRateLimitUtilization float64 `json:"hassectorratelimitutilization"`
+		TotalHasSectorRateLimitSkips  uint64  `json:"totalhassectorratelimitskips"`
+
+		// HasSectorCostHistogram is an exponential histogram of the costs of
+		// the HasSector jobs that the chunk worker sets computed for the
+		// workers. It helps with picking a price gouging threshold.
+		HasSectorCostHistogram []HasSectorCostBucket `json:"hassectorcosthistogram"`
+	}
+
+	// HasSectorCostBucket is a bucket of the HasSector cost histogram. It
+	// contains the decayed number of HasSector jobs whose cost was at least
+	// Min and less than Max.
+	HasSectorCostBucket struct {
+		Min   types.Currency `json:"min"`
+		Max   types.Currency `json:"max"`
+		Count float64        `json:"count"`
 	}
 
 	// WorkerStatus contains information about the status of a worker
@@ -883,6 +1023,10 @@ type (
 		ContractUtility ContractUtility      `json:"contractutility"`
 		HostPubKey      types.SiaPublicKey   `json:"hostpubkey"`
 
+		// Gouging exemption information
+		GougingExempt       bool           `json:"gougingexempt"`
+		GougingExemptChecks []GougingCheck `json:"gougingexemptchecks"`
+
 		// Download status information
 		DownloadCoolDownError string        `json:"downloadcooldownerror"`
 		DownloadCoolDownTime  time.Duration `json:"downloadcooldowntime"`
@@ -897,6 +1041,11 @@ type (
 		UploadQueueSize     int           `json:"uploadqueuesize"`
 		UploadTerminated    bool          `json:"uploadterminated"`
 
+		// Chunk worker set gouging information
+		PCWSGougingRejections    uint64    `json:"pcwsgougingrejections"`
+		PCWSGougingRecentErr     string    `json:"pcwsgougingrecenterr"`
+		PCWSGougingRecentErrTime time.Time `json:"pcwsgougingrecenterrtime"`
+
 		// Maintenance Cooldown information
 		MaintenanceOnCooldown    bool          `json:"maintenanceoncooldown"`
 		MaintenanceCoolDownError string        `json:"maintenancecooldownerror"`
@@ -956,6 +1105,20 @@ type (
 
 		RecentErr     string    `json:"recenterr"`
 		RecentErrTime time.Time `json:"recenterrtime"`
+
+		RecentPriceAnomaly *PriceAnomaly `json:"recentpriceanomaly,omitempty"`
+	}
+
+	// PriceAnomaly describes a price in a host's price table that increased by
+	// more than the allowed factor compared to the settings the hostdb recorded
+	// during the host's most recent scan.
+	PriceAnomaly struct {
+		Dimension  string         `json:"dimension"`
+		Factor     float64        `json:"factor"`
+		ScanPrice  types.Currency `json:"scanprice"`
+		ScanTime   time.Time      `json:"scantime"`
+		TablePrice types.Currency `json:"tableprice"`
+		Time       time.Time      `json:"time"`
 	}
 
 	// WorkerReadJobsStatus contains detailed information about the read jobs
@@ -1070,6 +1233,10 @@ type Renter interface {
 	// billing period.
 	PeriodSpending() (ContractorSpending, error)
 
+	// GougingSpending returns the amount spent on RPCs in the current billing
+	// period, grouped by the prices that are protected by the gouging checks.
+	GougingSpending() (GougingSpending, error)
+
 	// RecoverableContracts returns the contracts that the contractor deems
 	// recoverable. That means they are not expired yet and also not part of the
 	// active contracts. Usually this should return an empty slice unless the host
@@ -1136,6 +1303,18 @@ type Renter interface {
 	// FileHosts returns a list of hosts that are storing the file data.
 	FileHosts(SiaPath) ([]HostDBEntry, error)
 
+	// AddGougingExemption exempts the host with the given key from the given
+	// price gouging checks. If no checks are provided, the host is exempt from
+	// all checks.
+	AddGougingExemption(hostKey types.SiaPublicKey, checks []GougingCheck) error
+
+	// GougingExemptions returns all of the renter's gouging exemptions.
+	GougingExemptions() ([]GougingExemption, error)
+
+	// RemoveGougingExemption removes the gouging exemption of the host with the
+	// given key.
+	RemoveGougingExemption(hostKey types.SiaPublicKey) error
+
 	// Filter returns the renter's hostdb's filterMode and filteredHosts
 	Filter() (FilterMode, map[string]types.SiaPublicKey, []string, error)
 
@@ -1351,6 +1530,9 @@ type HostDB interface {
 	// renter.
 	RandomHostsWithAllowance(int, []types.SiaPublicKey, []types.SiaPublicKey, Allowance) ([]HostDBEntry, error)
 
+	// ScheduleRescan queues a scan of the host with the given public key.
+	ScheduleRescan(types.SiaPublicKey) error
+
 	// ScoreBreakdown returns a detailed explanation of the various properties
 	// of the host.
 	ScoreBreakdown(HostDBEntry) (HostScoreBreakdown, error)
@@ -1,18 +1,39 @@
 package renter
 
 import (
+	"time"
+
 	"go.sia.tech/siad/modules"
 )
 
 // Alerts implements the modules.Alerter interface for the renter. It returns
 // all alerts of the renter and its submodules.
 func (r *Renter) Alerts() (crit, err, warn, info []modules.Alert) {
-	renterCrit, renterErr, renterWarn, renterInfo := r.staticAlerter.Alerts()
-	contractorCrit, contractorErr, contractorWarn, contractorInfo := r.hostContractor.Alerts()
-	hostdbCrit, hostdbErr, hostdbWarn, hostdbInfo := r.hostDB.Alerts()
-	crit = append(append(renterCrit, contractorCrit...), hostdbCrit...)
-	err = append(append(renterErr, contractorErr...), hostdbErr...)
-	warn = append(append(renterWarn, contractorWarn...), hostdbWarn...)
-	info = append(append(renterInfo, contractorInfo...), hostdbInfo...)
-	return
+	return r.staticAlerter.Alerts()
+}
+
+// RegisterSubscriber implements the modules.AlertSubscriber interface for the
+// renter. The subscriber receives the alert events of the renter and its
+// submodules.
+func (r *Renter) RegisterSubscriber(ch chan<- modules.AlertEvent) {
+	r.staticAlerter.RegisterSubscriber(ch)
+}
+
+// UnregisterSubscriber implements the modules.AlertSubscriber interface for
+// the renter.
+func (r *Renter) UnregisterSubscriber(ch chan<- modules.AlertEvent) {
+	r.staticAlerter.UnregisterSubscriber(ch)
+}
+
+// DismissModuleAlert implements the modules.AlertDismisser interface for the
+// renter. The alerts of the renter's
+// submodules can be dismissed as well.
+func (r *Renter) DismissModuleAlert(module string, id modules.AlertID) error {
+	return r.staticAlerter.DismissModuleAlert(module, id)
+}
+
+// SnoozeModuleAlert implements the modules.AlertDismisser interface for the
+// renter.
+func (r *Renter) SnoozeModuleAlert(module string, id modules.AlertID, d time.Duration) error {
+	return r.staticAlerter.SnoozeModuleAlert(module, id, d)
 }
@@ -21,6 +21,20 @@ const (
 	// AlertSiafileLowRedundancyThreshold is the health threshold at which we start
 	// registering the LowRedundancy alert for a Siafile.
 	AlertSiafileLowRedundancyThreshold = 0.75
+	// AlertMSGHasSectorErrors indicates that a large fraction of the workers
+	// failed their HasSector jobs, which usually points at a systemic problem
+	// rather than at individual hosts.
+	AlertMSGHasSectorErrors = "A large fraction of the workers failed to look up sectors on their hosts"
+	// AlertMSGUnrecoverableFiles indicates that there are files which can't be
+	// recovered since too few pieces are available and there is no local copy
+	// to repair them from.
+	AlertMSGUnrecoverableFiles = "Some files have too few pieces available to be recovered"
+	// AlertMSGLowRedundancyDirs indicates that the redundancy of some
+	// directories is low.
+	AlertMSGLowRedundancyDirs = "Some directories have a low redundancy"
+	// AlertDirLowRedundancyThreshold is the min redundancy of a directory's
+	// files below which we register the low redundancy alert for directories.
+	AlertDirLowRedundancyThreshold = 1.5
 )
 
 // AlertCauseSiafileLowRedundancy creates a customized "cause" for a siafile
@@ -163,6 +177,14 @@ const (
 	DefaultMaxUploadSpeed = 0
 )
 
+// Default price anomaly detection parameters.
+const (
+	// DefaultPriceAnomalyFactor is the default factor by which a price in a
+	// host's price table is allowed to exceed the price recorded during the
+	// host's most recent scan.
+	DefaultPriceAnomalyFactor = 3.0
+)
+
 // Naming conventions for code readability.
 const (
 	// destinationTypeSeekStream is the destination type used for downloads
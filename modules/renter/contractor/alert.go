@@ -1,9 +1,37 @@
 package contractor
 
-import "go.sia.tech/siad/modules"
+import (
+	"time"
+
+	"go.sia.tech/siad/modules"
+)
 
 // Alerts implements the modules.Alerter interface for the contractor. It returns
 // all alerts of the contractor.
 func (c *Contractor) Alerts() (crit, err, warn, info []modules.Alert) {
 	return c.staticAlerter.Alerts()
 }
+
+// RegisterSubscriber implements the modules.AlertSubscriber interface for the
+// contractor.
+func (c *Contractor) RegisterSubscriber(ch chan<- modules.AlertEvent) {
+	c.staticAlerter.RegisterSubscriber(ch)
+}
+
+// UnregisterSubscriber implements the modules.AlertSubscriber interface for
+// the contractor.
+func (c *Contractor) UnregisterSubscriber(ch chan<- modules.AlertEvent) {
+	c.staticAlerter.UnregisterSubscriber(ch)
+}
+
+// DismissModuleAlert implements the modules.AlertDismisser interface for the
+// contractor.
+func (c *Contractor) DismissModuleAlert(module string, id modules.AlertID) error {
+	return c.staticAlerter.DismissModuleAlert(module, id)
+}
+
+// SnoozeModuleAlert implements the modules.AlertDismisser interface for the
+// contractor.
+func (c *Contractor) SnoozeModuleAlert(module string, id modules.AlertID, d time.Duration) error {
+	return c.staticAlerter.SnoozeModuleAlert(module, id, d)
+}
@@ -1,6 +1,8 @@
 package contractor
 
 import (
+	"time"
+
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
@@ -17,12 +19,68 @@ var (
 	// funds.
 	AlertMSGAllowanceLowFunds = "At least one contract formation/renewal failed due to the allowance being low on funds"
 
+	// AlertMSGAllowanceInsufficientHosts indicates that the allowance can't
+	// fund contracts with the requested number of hosts at current prices.
+	AlertMSGAllowanceInsufficientHosts = "The allowance can't support the requested number of hosts at current prices"
+
 	// AlertMSGFailedContractRenewal indicates that the contract renewal failed
 	AlertMSGFailedContractRenewal = "Contractor is attempting to renew/refresh contracts but failed"
 
 	// AlertMSGWalletLockedDuringMaintenance indicates that forming/renewing a
 	// contract during contract maintenance isn't possible due to a locked wallet.
 	AlertMSGWalletLockedDuringMaintenance = "At least one contract failed to form/renew due to the wallet being locked"
+
+	// AlertMSGWalletLowBalance indicates that the wallet balance might not be
+	// enough to fund the contracts for the remainder of the period.
+	AlertMSGWalletLowBalance = "Wallet balance is low compared to the funds needed for the remainder of the period"
+
+	// contractRenewalErrorAlertTTL is the time after which the contract
+	// renewal error alert expires if contract maintenance didn't register it
+	// again. Maintenance runs on every block, an alert that wasn't confirmed
+	// in that time is stale.
+	contractRenewalErrorAlertTTL = build.Select(build.Var{
+		Dev:      time.Hour,
+		Standard: 24 * time.Hour,
+		Testnet:  24 * time.Hour,
+		Testing:  10 * time.Minute,
+	}).(time.Duration)
+
+	// walletLockedAlertEscalation is the time after which the alert about a
+	// wallet that is locked during contract maintenance is escalated from a
+	// warning to an error. Contracts can't be renewed while the wallet is
+	// locked, so a wallet that stays locked puts the renter's files at risk.
+	walletLockedAlertEscalation = build.Select(build.Var{
+		Dev:      time.Hour,
+		Standard: 24 * time.Hour,
+		Testnet:  24 * time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// walletLowBalanceErrorFactor and walletLowBalanceWarningFactor are the
+	// multiples of the funds needed for the remainder of the period below
+	// which the wallet balance causes an error or warning alert.
+	walletLowBalanceErrorFactor = build.Select(build.Var{
+		Dev:      1.0,
+		Standard: 1.0,
+		Testnet:  1.0,
+		Testing:  1.0,
+	}).(float64)
+	walletLowBalanceWarningFactor = build.Select(build.Var{
+		Dev:      1.5,
+		Standard: 1.5,
+		Testnet:  1.5,
+		Testing:  1.5,
+	}).(float64)
+
+	// walletUnlockCheckInterval is the interval at which the contractor
+	// checks whether the wallet was unlocked after contract maintenance was
+	// interrupted by a locked wallet.
+	walletUnlockCheckInterval = build.Select(build.Var{
+		Dev:      5 * time.Second,
+		Standard: 10 * time.Second,
+		Testnet:  10 * time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
 )
 
 // Constants related to contract formation parameters.
@@ -988,6 +988,143 @@ func (c *Contractor) callUpdateUtility(safeContract *proto.SafeContract, newUtil
 	return safeContract.UpdateUtility(newUtility)
 }
 
+// walletLowBalanceSeverity returns the severity of the low wallet balance
+// alert for the given balance and the funds needed for the remainder of the
+// period. SeverityUnknown is returned if no alert is needed.
+func walletLowBalanceSeverity(balance, need types.Currency) modules.AlertSeverity {
+	if need.IsZero() {
+		return modules.SeverityUnknown
+	}
+	if balance.Cmp(need.MulFloat(walletLowBalanceErrorFactor)) < 0 {
+		return modules.SeverityError
+	}
+	if balance.Cmp(need.MulFloat(walletLowBalanceWarningFactor)) < 0 {
+		return modules.SeverityWarning
+	}
+	return modules.SeverityUnknown
+}
+
+// managedCheckWalletBalance registers the low wallet balance alert if the
+// confirmed wallet balance is low compared to the funds needed for the
+// remainder of the period, and unregisters it otherwise. The funds needed are
+// the remaining allowance plus the fees of the pending renewals. A locked
+// wallet is covered by its own alert.
+func (c *Contractor) managedCheckWalletBalance(fundsRemaining types.Currency, numRenewals int) {
+	unlocked, err := c.wallet.Unlocked()
+	if err != nil || !unlocked {
+		return
+	}
+	balance, _, _, err := c.wallet.ConfirmedBalance()
+	if err != nil {
+		c.log.Println("WARN: unable to get the confirmed wallet balance:", err)
+		return
+	}
+	_, maxFee := c.tpool.FeeEstimation()
+	renewalFees := maxFee.Mul64(modules.EstimatedFileContractTransactionSetSize).Mul64(uint64(numRenewals))
+	need := fundsRemaining.Add(renewalFees)
+	severity := walletLowBalanceSeverity(balance, need)
+	if severity == modules.SeverityUnknown {
+		c.staticAlerter.UnregisterAlert(modules.AlertIDRenterWalletLowBalance)
+		return
+	}
+	cause := fmt.Sprintf("have %v, need ~%v", balance.HumanString(), need.HumanString())
+	c.staticAlerter.RegisterAlert(modules.AlertIDRenterWalletLowBalance, AlertMSGWalletLowBalance, cause, severity)
+}
+
+// allowanceHostsSupported estimates the number of hosts the allowance can
+// form contracts with at the median contract cost of the given hosts. The
+// estimate is capped at the number of hosts requested by the allowance. Hosts
+// whose contract price exceeds the allowance's funding per contract are
+// considered more expensive than all other hosts. The bool is false if there
+// are no hosts to estimate the cost from.
+func allowanceHostsSupported(allowance modules.Allowance, hosts []modules.HostDBEntry, txnFee types.Currency) (uint64, bool) {
+	if len(hosts) == 0 || allowance.Hosts == 0 {
+		return 0, false
+	}
+	var costs []types.Currency
+	for _, host := range hosts {
+		cost, err := modules.EstimateContractCost(host, allowance, txnFee)
+		if err != nil {
+			continue
+		}
+		costs = append(costs, cost)
+	}
+	median := len(hosts) / 2
+	if median >= len(costs) {
+		return 0, true
+	}
+	sort.Slice(costs, func(i, j int) bool {
+		return costs[i].Cmp(costs[j]) < 0
+	})
+	if costs[median].IsZero() {
+		return allowance.Hosts, true
+	}
+	supported := allowance.Funds.Div(costs[median])
+	if supported.Cmp64(allowance.Hosts) >= 0 {
+		return allowance.Hosts, true
+	}
+	n, err := supported.Uint64()
+	if err != nil {
+		return allowance.Hosts, true
+	}
+	return n, true
+}
+
+// managedCheckAllowanceHosts registers the insufficient allowance hosts alert
+// if the allowance can't fund contracts with the requested number of hosts at
+// the current median host prices, and unregisters it otherwise.
+func (c *Contractor) managedCheckAllowanceHosts() {
+	c.mu.RLock()
+	allowance := c.allowance
+	c.mu.RUnlock()
+	if allowance.Hosts == 0 {
+		c.staticAlerter.UnregisterAlert(modules.AlertIDRenterAllowanceInsufficientHosts)
+		return
+	}
+	hosts, err := c.hdb.ActiveHosts()
+	if err != nil {
+		c.log.Println("WARN: unable to get the active hosts:", err)
+		return
+	}
+	_, maxFee := c.tpool.FeeEstimation()
+	supported, ok := allowanceHostsSupported(allowance, hosts, maxFee)
+	if !ok || supported >= allowance.Hosts {
+		c.staticAlerter.UnregisterAlert(modules.AlertIDRenterAllowanceInsufficientHosts)
+		return
+	}
+	cause := fmt.Sprintf("allowance supports ~%v of the requested %v hosts at current prices", supported, allowance.Hosts)
+	c.staticAlerter.RegisterAlert(modules.AlertIDRenterAllowanceInsufficientHosts, AlertMSGAllowanceInsufficientHosts, cause, modules.SeverityWarning)
+}
+
+// threadedWatchWalletUnlock periodically checks whether the wallet was unlocked
+// after contract maintenance was interrupted by a locked wallet. If so, it runs
+// contract maintenance right away instead of waiting for the next block, which
+// also clears the wallet locked alert.
+func (c *Contractor) threadedWatchWalletUnlock() {
+	if err := c.tg.Add(); err != nil {
+		return
+	}
+	defer c.tg.Done()
+	for {
+		select {
+		case <-c.tg.StopChan():
+			return
+		case <-time.After(walletUnlockCheckInterval):
+		}
+		c.mu.RLock()
+		interrupted := !c.walletLockedSince.IsZero()
+		c.mu.RUnlock()
+		if !interrupted {
+			continue
+		}
+		if unlocked, err := c.wallet.Unlocked(); err != nil || !unlocked {
+			continue
+		}
+		c.log.Debugln("wallet was unlocked, resuming contract maintenance")
+		c.threadedContractMaintenance()
+	}
+}
+
 // threadedContractMaintenance checks the set of contracts that the contractor
 // has against the allownace, renewing any contracts that need to be renewed,
 // dropping contracts which are no longer worthwhile, and adding contracts if
@@ -1027,8 +1164,18 @@ func (c *Contractor) threadedContractMaintenance() {
 	var registerWalletLockedDuringMaintenance bool
 	defer func() {
 		if registerWalletLockedDuringMaintenance {
-			c.staticAlerter.RegisterAlert(modules.AlertIDWalletLockedDuringMaintenance, AlertMSGWalletLockedDuringMaintenance, modules.ErrLockedWallet.Error(), modules.SeverityWarning)
+			c.mu.Lock()
+			if c.walletLockedSince.IsZero() {
+				c.walletLockedSince = time.Now()
+			}
+			since := c.walletLockedSince
+			c.mu.Unlock()
+			cause := fmt.Sprintf("%v since %v", modules.ErrLockedWallet, since.Format(time.RFC3339))
+			c.staticAlerter.RegisterAlertWithEscalation(modules.AlertIDWalletLockedDuringMaintenance, AlertMSGWalletLockedDuringMaintenance, cause, modules.SeverityWarning, walletLockedAlertEscalation, modules.SeverityError)
 		} else {
+			c.mu.Lock()
+			c.walletLockedSince = time.Time{}
+			c.mu.Unlock()
 			c.staticAlerter.UnregisterAlert(modules.AlertIDWalletLockedDuringMaintenance)
 		}
 	}()
@@ -1061,8 +1208,11 @@ func (c *Contractor) threadedContractMaintenance() {
 	c.mu.RUnlock()
 	if wantedHosts <= 0 {
 		c.log.Debugln("Exiting contract maintenance because the number of desired hosts is <= zero.")
+		c.staticAlerter.UnregisterAlert(modules.AlertIDRenterWalletLowBalance)
+		c.staticAlerter.UnregisterAlert(modules.AlertIDRenterAllowanceInsufficientHosts)
 		return
 	}
+	c.managedCheckAllowanceHosts()
 
 	// The rest of this function needs to know a few of the stateful variables
 	// from the contractor, build those up under a lock so that the rest of the
@@ -1219,6 +1369,9 @@ func (c *Contractor) threadedContractMaintenance() {
 	}
 	c.log.Debugln("Remaining funds in allowance:", fundsRemaining.HumanString())
 
+	// Check whether the wallet can fund the remainder of the period.
+	c.managedCheckWalletBalance(fundsRemaining, len(renewSet)+len(refreshSet))
+
 	// Keep track of the total number of renews that failed for any reason.
 	var numRenewFails int
 
@@ -1242,7 +1395,7 @@ func (c *Contractor) threadedContractMaintenance() {
 		if renewErr != nil {
 			c.log.Debugln("SEVERE", numRenewFails, float64(allowance.Hosts)*MaxCriticalRenewFailThreshold)
 			c.log.Debugln("alert err: ", renewErr)
-			c.staticAlerter.RegisterAlert(modules.AlertIDRenterContractRenewalError, AlertMSGFailedContractRenewal, renewErr.Error(), modules.AlertSeverity(alertSeverity))
+			c.staticAlerter.RegisterAlertWithTTL(modules.AlertIDRenterContractRenewalError, AlertMSGFailedContractRenewal, renewErr.Error(), modules.AlertSeverity(alertSeverity), contractRenewalErrorAlertTTL)
 		} else {
 			c.staticAlerter.UnregisterAlert(modules.AlertIDRenterContractRenewalError)
 		}
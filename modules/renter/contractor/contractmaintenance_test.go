@@ -1,9 +1,12 @@
 package contractor
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/alerttest"
 	"go.sia.tech/siad/types"
 )
 
@@ -62,3 +65,132 @@ func TestCheckFormContractGouging(t *testing.T) {
 		t.Fatal("expecting price gouging check to fail")
 	}
 }
+
+// TestWalletLowBalanceSeverity is a unit test for walletLowBalanceSeverity.
+func TestWalletLowBalanceSeverity(t *testing.T) {
+	need := types.SiacoinPrecision.Mul64(100)
+	tests := []struct {
+		balance  types.Currency
+		need     types.Currency
+		severity modules.AlertSeverity
+	}{
+		{types.ZeroCurrency, types.ZeroCurrency, modules.SeverityUnknown},
+		{types.ZeroCurrency, need, modules.SeverityError},
+		{need.Sub64(1), need, modules.SeverityError},
+		{need, need, modules.SeverityWarning},
+		{need.MulFloat(1.5).Sub64(1), need, modules.SeverityWarning},
+		{need.MulFloat(1.5), need, modules.SeverityUnknown},
+	}
+	for i, test := range tests {
+		if severity := walletLowBalanceSeverity(test.balance, test.need); severity != test.severity {
+			t.Errorf("%v: expected %v, got %v", i, test.severity, severity)
+		}
+	}
+}
+
+// pricedHostDB is a hostdb that returns a fixed set of active hosts.
+type pricedHostDB struct {
+	modules.HostDB
+	hosts []modules.HostDBEntry
+}
+
+// ActiveHosts returns the seeded hosts.
+func (hdb *pricedHostDB) ActiveHosts() ([]modules.HostDBEntry, error) {
+	return hdb.hosts, nil
+}
+
+// fixedFeeTpool is a transaction pool with a fixed fee estimation.
+type fixedFeeTpool struct {
+	modules.TransactionPool
+	fee types.Currency
+}
+
+// FeeEstimation returns the fixed fee.
+func (tp fixedFeeTpool) FeeEstimation() (types.Currency, types.Currency) {
+	return tp.fee, tp.fee
+}
+
+// TestCheckAllowanceHosts verifies that the insufficient allowance hosts alert
+// is registered if the allowance can't support the requested number of hosts
+// at the median host price and unregistered once prices improve.
+func TestCheckAllowanceHosts(t *testing.T) {
+	hostWithPrice := func(sc uint64) modules.HostDBEntry {
+		var entry modules.HostDBEntry
+		entry.ContractPrice = types.SiacoinPrecision.Mul64(sc)
+		return entry
+	}
+	hdb := &pricedHostDB{}
+	alerter := alerttest.New("contractor")
+	c := &Contractor{
+		allowance: modules.Allowance{
+			Funds:  types.SiacoinPrecision.Mul64(1000),
+			Hosts:  10,
+			Period: 100,
+		},
+		hdb:           hdb,
+		tpool:         fixedFeeTpool{fee: types.ZeroCurrency},
+		staticAlerter: alerter,
+	}
+	const id = modules.AlertIDRenterAllowanceInsufficientHosts
+
+	// without hosts there is no estimate and no alert
+	c.managedCheckAllowanceHosts()
+	if alerter.HasAlert(id) {
+		t.Fatal("alert shouldn't be registered without hosts")
+	}
+
+	// a cheap median host doesn't trigger the alert
+	hdb.hosts = []modules.HostDBEntry{hostWithPrice(1), hostWithPrice(10), hostWithPrice(90)}
+	c.managedCheckAllowanceHosts()
+	if alerter.HasAlert(id) {
+		t.Fatal("alert shouldn't be registered for cheap hosts")
+	}
+
+	// an expensive median host does
+	hdb.hosts = []modules.HostDBEntry{hostWithPrice(1), hostWithPrice(60), hostWithPrice(90)}
+	cost, err := modules.EstimateContractCost(hdb.hosts[1], c.allowance, types.ZeroCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	supported, err := c.allowance.Funds.Div(cost).Uint64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if supported >= c.allowance.Hosts {
+		t.Fatal("expected median host to be too expensive", supported)
+	}
+	c.managedCheckAllowanceHosts()
+	a, ok := alerter.Alert(id)
+	if !ok {
+		t.Fatal("alert should be registered")
+	}
+	if a.Severity != modules.SeverityWarning || !strings.Contains(a.Cause, fmt.Sprintf("~%v of the requested %v hosts", supported, c.allowance.Hosts)) {
+		t.Fatal("unexpected cause", a.Cause)
+	}
+
+	// hosts whose contract price exceeds the funding per contract count as
+	// the most expensive ones
+	hdb.hosts = []modules.HostDBEntry{hostWithPrice(1), hostWithPrice(200), hostWithPrice(300)}
+	c.managedCheckAllowanceHosts()
+	if a, ok := alerter.Alert(id); !ok || !strings.Contains(a.Cause, "~0 of the requested") {
+		t.Fatal("unexpected alert", a, ok)
+	}
+
+	// the alert is unregistered once prices improve
+	hdb.hosts = []modules.HostDBEntry{hostWithPrice(1), hostWithPrice(2), hostWithPrice(300)}
+	c.managedCheckAllowanceHosts()
+	if alerter.HasAlert(id) {
+		t.Fatal("alert should be unregistered")
+	}
+
+	// every check either registered or unregistered the alert
+	events := alerter.Events()
+	if len(events) != 5 {
+		t.Fatal("unexpected number of events", events)
+	}
+	for i, registered := range []bool{false, false, true, true, false} {
+		if events[i].ID != id || events[i].Registered != registered {
+			t.Fatal("unexpected event", i, events[i])
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/ratelimit"
@@ -55,7 +56,7 @@ type Contractor struct {
 	log           *persist.Logger
 	mu            sync.RWMutex
 	persistDir    string
-	staticAlerter *modules.GenericAlerter
+	staticAlerter modules.ModuleAlerter
 	staticDeps    modules.Dependencies
 	tg            threadgroup.ThreadGroup
 	tpool         modules.TransactionPool
@@ -77,6 +78,11 @@ type Contractor struct {
 	currentPeriod types.BlockHeight
 	lastChange    modules.ConsensusChangeID
 
+	// walletLockedSince is the time at which contract maintenance was first
+	// interrupted by a locked wallet. It is reset once maintenance completes
+	// without being interrupted by a locked wallet.
+	walletLockedSince time.Time
+
 	// recentRecoveryChange is the first ConsensusChange that was missed while
 	// trying to find recoverable contracts. This is where we need to start
 	// rescanning the blockchain for recoverable contracts the next time the wallet
@@ -467,10 +473,10 @@ func New(cs modules.ConsensusSet, wallet modules.Wallet, tpool modules.Transacti
 }
 
 // contractorBlockingStartup handles the blocking portion of NewCustomContractor.
-func contractorBlockingStartup(cs modules.ConsensusSet, w modules.Wallet, tp modules.TransactionPool, hdb modules.HostDB, persistDir string, contractSet *proto.ContractSet, l *persist.Logger, deps modules.Dependencies) (*Contractor, error) {
+func contractorBlockingStartup(cs modules.ConsensusSet, w modules.Wallet, tp modules.TransactionPool, hdb modules.HostDB, persistDir string, contractSet *proto.ContractSet, l *persist.Logger, deps modules.Dependencies, alerter modules.ModuleAlerter) (*Contractor, error) {
 	// Create the Contractor object.
 	c := &Contractor{
-		staticAlerter: modules.NewAlerter("contractor"),
+		staticAlerter: alerter,
 		cs:            cs,
 		staticDeps:    deps,
 		hdb:           hdb,
@@ -498,8 +504,17 @@ func contractorBlockingStartup(cs modules.ConsensusSet, w modules.Wallet, tp mod
 	c.staticChurnLimiter = newChurnLimiter(c)
 	c.staticWatchdog = newWatchdog(c)
 
-	// Close the contract set and logger upon shutdown.
-	err := c.tg.AfterStop(func() error {
+	// Surface the alerts of the contract set through the contractor.
+	err := c.staticAlerter.AddSubAlerter(contractSet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Close the contract set, alerter and logger upon shutdown.
+	err = c.tg.AfterStop(func() error {
+		if err := c.staticAlerter.Close(); err != nil {
+			c.log.Println("WARN: failed to save the contractor alerts:", err)
+		}
 		if err := c.staticContracts.Close(); err != nil {
 			return errors.AddContext(err, "failed to close contract set")
 		}
@@ -521,6 +536,12 @@ func contractorBlockingStartup(cs modules.ConsensusSet, w modules.Wallet, tp mod
 	// Update the pubkeyToContractID map
 	c.managedUpdatePubKeyToContractIDMap()
 
+	// A restored wallet locked alert no longer applies if the wallet is
+	// unlocked. The other alerts are re-validated by the next maintenance.
+	if unlocked, err := w.Unlocked(); err == nil && unlocked {
+		c.staticAlerter.UnregisterAlert(modules.AlertIDWalletLockedDuringMaintenance)
+	}
+
 	// Unsubscribe from the consensus set upon shutdown.
 	err = c.tg.OnStop(func() error {
 		cs.Unsubscribe(c)
@@ -572,15 +593,30 @@ func contractorAsyncStartup(c *Contractor, cs modules.ConsensusSet) error {
 
 // NewCustomContractor creates a Contractor using the provided dependencies.
 func NewCustomContractor(cs modules.ConsensusSet, w modules.Wallet, tp modules.TransactionPool, hdb modules.HostDB, persistDir string, contractSet *proto.ContractSet, l *persist.Logger, deps modules.Dependencies) (*Contractor, <-chan error) {
+	// Load the alerts that were registered before the last shutdown.
+	alerter, alertErr := modules.NewPersistedAlerter("contractor", persistDir)
+	if alertErr != nil {
+		l.Println("WARN: discarding persisted alerts:", alertErr)
+	}
+	return NewCustomContractorWithAlerter(cs, w, tp, hdb, persistDir, contractSet, l, deps, alerter)
+}
+
+// NewCustomContractorWithAlerter creates a Contractor using the provided
+// dependencies that registers its alerts with the provided alerter. The
+// contractor closes the alerter on shutdown.
+func NewCustomContractorWithAlerter(cs modules.ConsensusSet, w modules.Wallet, tp modules.TransactionPool, hdb modules.HostDB, persistDir string, contractSet *proto.ContractSet, l *persist.Logger, deps modules.Dependencies, alerter modules.ModuleAlerter) (*Contractor, <-chan error) {
 	errChan := make(chan error, 1)
 
 	// Handle blocking startup.
-	c, err := contractorBlockingStartup(cs, w, tp, hdb, persistDir, contractSet, l, deps)
+	c, err := contractorBlockingStartup(cs, w, tp, hdb, persistDir, contractSet, l, deps, alerter)
 	if err != nil {
 		errChan <- err
 		return nil, errChan
 	}
 
+	// Resume interrupted maintenance as soon as the wallet is unlocked.
+	go c.threadedWatchWalletUnlock()
+
 	// non-blocking startup.
 	go func() {
 		// Subscribe to the consensus set in a separate goroutine.
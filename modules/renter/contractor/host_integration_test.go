@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -333,6 +334,94 @@ func TestFormContractSmallAllowance(t *testing.T) {
 	}
 }
 
+// TestIntegrationWalletLowBalanceAlert tests that contract maintenance
+// registers an alert once the wallet can't fund the remainder of the period.
+func TestIntegrationWalletLowBalanceAlert(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	_, c, _, cf, err := newTestingTrio(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tryClose(cf, t)
+
+	// lowBalanceAlert returns the low balance alert if it is registered.
+	lowBalanceAlert := func() (modules.Alert, bool) {
+		_, errs, warns, _ := c.Alerts()
+		for _, alert := range append(errs, warns...) {
+			if alert.ID == modules.AlertIDRenterWalletLowBalance {
+				return alert, true
+			}
+		}
+		return modules.Alert{}, false
+	}
+
+	// set an allowance but don't use SetAllowance to avoid automatic contract
+	// formation, the funded wallet doesn't trigger the alert
+	c.mu.Lock()
+	c.allowance = modules.DefaultAllowance
+	c.allowance.Funds = types.SiacoinPrecision.Mul64(100)
+	c.allowance.Hosts = 1
+	c.mu.Unlock()
+	c.threadedContractMaintenance()
+	if alert, ok := lowBalanceAlert(); ok {
+		t.Fatal("unexpected alert", alert)
+	}
+
+	// have the allowance exceed the wallet balance by far, as if the wallet
+	// was drained
+	balance, _, _, err := c.wallet.ConfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	c.allowance.Funds = balance.Mul64(10)
+	c.mu.Unlock()
+
+	// the alert should be registered as an error
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		c.threadedContractMaintenance()
+		alert, ok := lowBalanceAlert()
+		if !ok {
+			return errors.New("alert not registered")
+		}
+		if alert.Severity != modules.SeverityError || !strings.Contains(alert.Cause, "need ~") {
+			return fmt.Errorf("unexpected alert %v", alert)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// once the balance is sufficient again, the alert is unregistered
+	c.mu.Lock()
+	c.allowance.Funds = types.SiacoinPrecision.Mul64(100)
+	c.mu.Unlock()
+	c.threadedContractMaintenance()
+	if alert, ok := lowBalanceAlert(); ok {
+		t.Fatal("alert should be unregistered", alert)
+	}
+
+	// the alert is unregistered as well if the allowance is cancelled
+	c.mu.Lock()
+	c.allowance.Funds = balance.Mul64(10)
+	c.mu.Unlock()
+	c.threadedContractMaintenance()
+	if _, ok := lowBalanceAlert(); !ok {
+		t.Fatal("alert should be registered")
+	}
+	c.mu.Lock()
+	c.allowance.Hosts = 0
+	c.mu.Unlock()
+	c.threadedContractMaintenance()
+	if alert, ok := lowBalanceAlert(); ok {
+		t.Fatal("alert should be unregistered", alert)
+	}
+}
+
 // TestIntegrationReviseContract tests that the contractor can revise a
 // contract previously formed with a host.
 func TestIntegrationReviseContract(t *testing.T) {
@@ -26,7 +26,12 @@ func (r *Renter) DeleteDir(siaPath modules.SiaPath) error {
 		return err
 	}
 	defer r.tg.Done()
-	return r.staticFileSystem.DeleteDir(siaPath)
+	err := r.staticFileSystem.DeleteDir(siaPath)
+	if err != nil {
+		return err
+	}
+	r.staticRedundancyAlerts.callRemoveDirectory(siaPath)
+	return nil
 }
 
 // DirList lists the directories in a siadir
@@ -69,5 +74,10 @@ func (r *Renter) RenameDir(oldPath, newPath modules.SiaPath) error {
 	if newPath.IsRoot() {
 		return errors.New("cannot rename a file to the root directory")
 	}
-	return r.staticFileSystem.RenameDir(oldPath, newPath)
+	err := r.staticFileSystem.RenameDir(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+	r.staticRedundancyAlerts.callRemoveDirectory(oldPath)
+	return nil
 }
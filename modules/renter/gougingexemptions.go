@@ -0,0 +1,102 @@
+package renter
+
+import (
+	"sort"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// errNoGougingExemption is returned when trying to remove a gouging
+	// exemption for a host that isn't exempt.
+	errNoGougingExemption = errors.New("host does not have a gouging exemption")
+)
+
+// AddGougingExemption exempts the host with the given key from the given price
+// gouging checks. If no checks are provided, the host is exempt from all
+// checks. An existing exemption for the host is overwritten.
+//
+// NOTE: the workers pick up the change the next time their cache is updated.
+func (r *Renter) AddGougingExemption(hostKey types.SiaPublicKey, checks []modules.GougingCheck) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	// Validate the checks.
+	for _, check := range checks {
+		if err := check.IsValid(); err != nil {
+			return err
+		}
+	}
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if r.persist.GougingExemptions == nil {
+		r.persist.GougingExemptions = make(map[string]modules.GougingExemption)
+	}
+	r.persist.GougingExemptions[hostKey.String()] = modules.GougingExemption{
+		HostKey: hostKey,
+		Checks:  append([]modules.GougingCheck{}, checks...),
+	}
+	return r.saveSync()
+}
+
+// GougingExemptions returns all of the renter's gouging exemptions, sorted by
+// host key.
+func (r *Renter) GougingExemptions() ([]modules.GougingExemption, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	exemptions := make([]modules.GougingExemption, 0, len(r.persist.GougingExemptions))
+	for _, ge := range r.persist.GougingExemptions {
+		exemptions = append(exemptions, ge)
+	}
+	sort.Slice(exemptions, func(i, j int) bool {
+		return exemptions[i].HostKey.String() < exemptions[j].HostKey.String()
+	})
+	return exemptions, nil
+}
+
+// RemoveGougingExemption removes the gouging exemption of the host with the
+// given key.
+func (r *Renter) RemoveGougingExemption(hostKey types.SiaPublicKey) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if _, exists := r.persist.GougingExemptions[hostKey.String()]; !exists {
+		return errNoGougingExemption
+	}
+	delete(r.persist.GougingExemptions, hostKey.String())
+	return r.saveSync()
+}
+
+// managedGougingExemption returns the gouging exemption of the host with the
+// given key, or nil if the host isn't exempt.
+func (r *Renter) managedGougingExemption(hostKey types.SiaPublicKey) *modules.GougingExemption {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	ge, exists := r.persist.GougingExemptions[hostKey.String()]
+	if !exists {
+		return nil
+	}
+	return &ge
+}
+
+// staticGougingExempt returns true if the user exempted the worker's host from
+// the given gouging check.
+func (w *worker) staticGougingExempt(check modules.GougingCheck) bool {
+	ge := w.staticCache().staticGougingExemption
+	return ge != nil && ge.Exempts(check)
+}
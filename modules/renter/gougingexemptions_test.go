@@ -0,0 +1,81 @@
+package renter
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestGougingExemptions verifies the renter's gouging exemptions can be
+// added, listed in a deterministic order and removed.
+func TestGougingExemptions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// add a couple of exemptions
+	var keys []types.SiaPublicKey
+	for i := 0; i < 5; i++ {
+		_, pk := crypto.GenerateKeyPair()
+		hostKey := types.Ed25519PublicKey(pk)
+		keys = append(keys, hostKey)
+		err = r.AddGougingExemption(hostKey, []modules.GougingCheck{modules.GougingCheckDownload})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// an invalid check is rejected
+	err = r.AddGougingExemption(keys[0], []modules.GougingCheck{"invalid"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	// the exemptions should be sorted by host key, every time
+	for i := 0; i < 5; i++ {
+		exemptions, err := r.GougingExemptions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(exemptions) != len(keys) {
+			t.Fatal("unexpected number of exemptions", len(exemptions))
+		}
+		for j := 1; j < len(exemptions); j++ {
+			if exemptions[j-1].HostKey.String() >= exemptions[j].HostKey.String() {
+				t.Fatal("exemptions are not sorted")
+			}
+		}
+	}
+
+	// remove an exemption
+	err = r.RemoveGougingExemption(keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.RemoveGougingExemption(keys[0])
+	if !errors.Contains(err, errNoGougingExemption) {
+		t.Fatal("unexpected error", err)
+	}
+	exemptions, err := r.GougingExemptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exemptions) != len(keys)-1 {
+		t.Fatal("unexpected number of exemptions", len(exemptions))
+	}
+}
@@ -0,0 +1,108 @@
+package renter
+
+import (
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// gougingspending.go keeps track of the money the renter spends on RPCs with
+// hosts, grouped by the prices that are protected by the gouging checks. The
+// gouging checks assume that certain costs only consume a fraction of the
+// allowance, the spending allows auditing those assumptions. The spending is
+// reset when a new period starts.
+
+var (
+	// gougingSpendingSaveInterval is the minimum amount of time between two
+	// saves of the gouging spending. The spending is updated for every RPC, so
+	// saving it every time would be too expensive. It is also saved when the
+	// renter shuts down.
+	gougingSpendingSaveInterval = build.Select(build.Var{
+		Standard: 5 * time.Minute,
+		Testnet:  5 * time.Minute,
+		Dev:      time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+)
+
+// GougingSpending returns the amount spent on RPCs in the current period,
+// grouped by the prices that are protected by the gouging checks.
+func (r *Renter) GougingSpending() (modules.GougingSpending, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.GougingSpending{}, err
+	}
+	defer r.tg.Done()
+
+	period := r.hostContractor.CurrentPeriod()
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	if r.persist.GougingSpendingPeriod != period {
+		return modules.GougingSpending{}, nil
+	}
+	return r.persist.GougingSpending, nil
+}
+
+// managedSaveGougingSpending saves the gouging spending to disk.
+func (r *Renter) managedSaveGougingSpending() error {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	r.lastGougingSpendingSave = time.Now()
+	return r.saveSync()
+}
+
+// managedTrackGougingSpending adds the given spending to the spending of the
+// current period. If a new period started since the last time spending was
+// tracked, the spending is reset first.
+func (r *Renter) managedTrackGougingSpending(spending modules.GougingSpending) {
+	period := r.hostContractor.CurrentPeriod()
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if r.persist.GougingSpendingPeriod != period {
+		r.persist.GougingSpending = modules.GougingSpending{}
+		r.persist.GougingSpendingPeriod = period
+	}
+	r.persist.GougingSpending = r.persist.GougingSpending.Add(spending)
+
+	// Save the spending periodically.
+	if time.Since(r.lastGougingSpendingSave) < gougingSpendingSaveInterval {
+		return
+	}
+	r.lastGougingSpendingSave = time.Now()
+	if err := r.saveSync(); err != nil {
+		r.log.Println("WARN: failed to save gouging spending:", err)
+	}
+}
+
+// programGougingSpending breaks down the amount spent on executing a program
+// on the host into the prices that are protected by the gouging checks. The
+// bandwidth costs are based on the bandwidth that was actually used, the base
+// cost of the program is taken from the price table and the remainder is
+// attributed to either the registry or sector access, depending on the
+// program's spending category.
+func programGougingSpending(pt modules.RPCPriceTable, category spendingCategory, spent types.Currency, downloaded, uploaded uint64) modules.GougingSpending {
+	// take subtracts the given cost from the remaining amount and returns the
+	// amount that was actually taken. The cost is capped at the remaining
+	// amount to avoid underflows when the host charged less than expected.
+	remaining := spent
+	take := func(cost types.Currency) types.Currency {
+		if cost.Cmp(remaining) > 0 {
+			cost = remaining
+		}
+		remaining = remaining.Sub(cost)
+		return cost
+	}
+
+	var spending modules.GougingSpending
+	spending.BaseRPC = take(pt.InitBaseCost)
+	spending.DownloadBandwidth = take(pt.DownloadBandwidthCost.Mul64(downloaded))
+	spending.UploadBandwidth = take(pt.UploadBandwidthCost.Mul64(uploaded))
+	switch category {
+	case categoryRegistryRead, categoryRegistryWrite:
+		spending.Registry = remaining
+	default:
+		spending.SectorAccess = remaining
+	}
+	return spending
+}
@@ -0,0 +1,204 @@
+package renter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/ratelimit"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestProgramGougingSpending is a unit test for programGougingSpending.
+func TestProgramGougingSpending(t *testing.T) {
+	t.Parallel()
+
+	var pt modules.RPCPriceTable
+	pt.InitBaseCost = types.NewCurrency64(10)
+	pt.DownloadBandwidthCost = types.NewCurrency64(2)
+	pt.UploadBandwidthCost = types.NewCurrency64(3)
+
+	// the remainder of a sector lookup is attributed to sector access
+	spent := types.NewCurrency64(100)
+	spending := programGougingSpending(pt, categoryDownload, spent, 20, 5)
+	expected := modules.GougingSpending{
+		BaseRPC:           types.NewCurrency64(10),
+		DownloadBandwidth: types.NewCurrency64(40),
+		UploadBandwidth:   types.NewCurrency64(15),
+		SectorAccess:      types.NewCurrency64(35),
+	}
+	if !gougingSpendingEqual(spending, expected) {
+		t.Fatal("unexpected spending", spending)
+	}
+
+	// the remainder of a registry update is attributed to the registry
+	spending = programGougingSpending(pt, categoryRegistryWrite, spent, 20, 5)
+	expected.Registry, expected.SectorAccess = expected.SectorAccess, types.ZeroCurrency
+	if !gougingSpendingEqual(spending, expected) {
+		t.Fatal("unexpected spending", spending)
+	}
+
+	// the breakdown never exceeds the amount spent
+	spent = types.NewCurrency64(30)
+	spending = programGougingSpending(pt, categoryDownload, spent, 20, 5)
+	expected = modules.GougingSpending{
+		BaseRPC:           types.NewCurrency64(10),
+		DownloadBandwidth: types.NewCurrency64(20),
+	}
+	if !gougingSpendingEqual(spending, expected) {
+		t.Fatal("unexpected spending", spending)
+	}
+	if !spending.Sum().Equals(spent) {
+		t.Fatal("unexpected sum", spending.Sum())
+	}
+}
+
+// TestGougingSpending verifies the renter accumulates the gouging spending,
+// persists it and resets it when a new period starts.
+func TestGougingSpending(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// track some spending
+	spending := modules.GougingSpending{
+		AccountFees:       types.NewCurrency64(1),
+		BaseRPC:           types.NewCurrency64(2),
+		DownloadBandwidth: types.NewCurrency64(3),
+		Registry:          types.NewCurrency64(4),
+		SectorAccess:      types.NewCurrency64(5),
+		UploadBandwidth:   types.NewCurrency64(6),
+	}
+	rt.renter.managedTrackGougingSpending(spending)
+	rt.renter.managedTrackGougingSpending(spending)
+	expected := spending.Add(spending)
+	gs, err := rt.renter.GougingSpending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gougingSpendingEqual(gs, expected) {
+		t.Fatal("unexpected spending", gs)
+	}
+
+	// the spending should survive a restart
+	err = rt.renter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errChan <-chan error
+	rl := ratelimit.NewRateLimit(0, 0, 0)
+	rt.renter, errChan = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.mux, rl, filepath.Join(rt.dir, modules.RenterDir))
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+	gs, err = rt.renter.GougingSpending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gougingSpendingEqual(gs, expected) {
+		t.Fatal("spending not persisted", gs)
+	}
+
+	// pretend the spending was tracked in a previous period, it should be
+	// reset
+	id := rt.renter.mu.Lock()
+	rt.renter.persist.GougingSpendingPeriod++
+	rt.renter.mu.Unlock(id)
+	gs, err = rt.renter.GougingSpending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gougingSpendingEqual(gs, modules.GougingSpending{}) {
+		t.Fatal("spending not reset", gs)
+	}
+	rt.renter.managedTrackGougingSpending(spending)
+	gs, err = rt.renter.GougingSpending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gougingSpendingEqual(gs, spending) {
+		t.Fatal("unexpected spending", gs)
+	}
+}
+
+// TestWorkerGougingSpending verifies the worker's RPCs are reflected in the
+// renter's gouging spending.
+func TestWorkerGougingSpending(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	w := wt.worker
+
+	// wait until the worker updated its price table and funded its account
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if !w.managedMaintenanceSucceeded() {
+			return errors.New("worker not ready with maintenance")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run a has sector job
+	rc := make(chan *jobHasSectorResponse, 1)
+	jhs := w.newJobHasSector(context.Background(), rc, crypto.Hash{})
+	if !w.staticJobHasSectorQueue.callAdd(jhs) {
+		t.Fatal("could not add job to queue")
+	}
+	select {
+	case resp := <-rc:
+		if resp.staticErr != nil {
+			t.Fatal(resp.staticErr)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("timed out")
+	}
+
+	// the maintenance and the job should be reflected in the spending
+	gs, err := wt.rt.renter.GougingSpending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.AccountFees.IsZero() || gs.BaseRPC.IsZero() || gs.SectorAccess.IsZero() || gs.DownloadBandwidth.IsZero() {
+		t.Fatal("spending not tracked", gs)
+	}
+}
+
+// gougingSpendingEqual is a helper that returns whether the given spendings
+// are equal.
+func gougingSpendingEqual(x, y modules.GougingSpending) bool {
+	return x.AccountFees.Equals(y.AccountFees) &&
+		x.BaseRPC.Equals(y.BaseRPC) &&
+		x.DownloadBandwidth.Equals(y.DownloadBandwidth) &&
+		x.Registry.Equals(y.Registry) &&
+		x.SectorAccess.Equals(y.SectorAccess) &&
+		x.UploadBandwidth.Equals(y.UploadBandwidth)
+}
@@ -0,0 +1,103 @@
+package renter
+
+import (
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// hasSectorCostCacheTTL is the amount of time the cost of a HasSector job
+	// is cached for. A host's price table changes its UID whenever it is
+	// updated, so the TTL only bounds the size of the cache and the age of its
+	// entries.
+	hasSectorCostCacheTTL = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 5 * time.Minute,
+		Testnet:  5 * time.Minute,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+)
+
+type (
+	// hasSectorCostCache caches the cost of the HasSector jobs that the chunk
+	// worker sets compute for their workers when checking for price gouging.
+	// Computing the cost requires building a program with one instruction per
+	// root, which is expensive for nodes with many workers that refresh their
+	// chunk worker sets frequently. A nil cache doesn't cache anything.
+	hasSectorCostCache struct {
+		entries   map[hasSectorCostCacheKey]hasSectorCostCacheEntry
+		nextPrune time.Time
+		mu        sync.Mutex
+	}
+
+	// hasSectorCostCacheKey identifies a cached cost by the host, the price
+	// table that was used to compute it and the number of roots of the job.
+	hasSectorCostCacheKey struct {
+		hostKey  string
+		uid      modules.UniqueID
+		numRoots int
+	}
+
+	// hasSectorCostCacheEntry is a cached cost and the time it expires.
+	hasSectorCostCacheEntry struct {
+		cost   types.Currency
+		expiry time.Time
+	}
+)
+
+// newHasSectorCostCache creates a new, empty cache.
+func newHasSectorCostCache() *hasSectorCostCache {
+	return &hasSectorCostCache{
+		entries: make(map[hasSectorCostCacheKey]hasSectorCostCacheEntry),
+	}
+}
+
+// managedCost returns the cost of a HasSector job for the given number of
+// roots against the host with the given key and price table. The cost is only
+// computed if it isn't cached yet or if the cached entry expired.
+func (c *hasSectorCostCache) managedCost(hostKey string, pt modules.RPCPriceTable, numRoots int, now time.Time) types.Currency {
+	if c == nil {
+		return pcwsHasSectorJobCost(pt, numRoots)
+	}
+	key := hasSectorCostCacheKey{
+		hostKey:  hostKey,
+		uid:      pt.UID,
+		numRoots: numRoots,
+	}
+	c.mu.Lock()
+	entry, exists := c.entries[key]
+	c.mu.Unlock()
+	if exists && now.Before(entry.expiry) {
+		return entry.cost
+	}
+
+	// Compute the cost without holding the lock.
+	cost := pcwsHasSectorJobCost(pt, numRoots)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune(now)
+	c.entries[key] = hasSectorCostCacheEntry{
+		cost:   cost,
+		expiry: now.Add(hasSectorCostCacheTTL),
+	}
+	return cost
+}
+
+// prune removes the expired entries from the cache. To avoid iterating over
+// the entries on every miss, the cache is pruned at most once per TTL.
+func (c *hasSectorCostCache) prune(now time.Time) {
+	if now.Before(c.nextPrune) {
+		return
+	}
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiry) {
+			delete(c.entries, key)
+		}
+	}
+	c.nextPrune = now.Add(hasSectorCostCacheTTL)
+}
@@ -0,0 +1,58 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestHasSectorCostCache is a unit test for the hasSectorCostCache.
+func TestHasSectorCostCache(t *testing.T) {
+	t.Parallel()
+
+	c := newHasSectorCostCache()
+	now := time.Now()
+	pt := newDefaultPriceTable()
+	expected := pcwsHasSectorJobCost(pt, 10)
+	if cost := c.managedCost("host", pt, 10, now); !cost.Equals(expected) {
+		t.Fatal("unexpected cost", cost, expected)
+	}
+
+	// change the prices without changing the UID, the cached cost is returned
+	pt.InitBaseCost = pt.InitBaseCost.Add(types.SiacoinPrecision)
+	if cost := c.managedCost("host", pt, 10, now); !cost.Equals(expected) {
+		t.Fatal("expected cached cost", cost, expected)
+	}
+
+	// a different host, UID or number of roots is a miss
+	updated := pcwsHasSectorJobCost(pt, 10)
+	if cost := c.managedCost("other", pt, 10, now); !cost.Equals(updated) {
+		t.Fatal("unexpected cost", cost, updated)
+	}
+	if cost := c.managedCost("host", pt, 5, now); !cost.Equals(pcwsHasSectorJobCost(pt, 5)) {
+		t.Fatal("unexpected cost", cost)
+	}
+	uidChanged := pt
+	uidChanged.UID = modules.UniqueID{1}
+	if cost := c.managedCost("host", uidChanged, 10, now); !cost.Equals(updated) {
+		t.Fatal("unexpected cost", cost, updated)
+	}
+
+	// once the entry expired, the cost is computed again and the expired
+	// entries are pruned
+	now = now.Add(hasSectorCostCacheTTL)
+	if cost := c.managedCost("host", pt, 10, now); !cost.Equals(updated) {
+		t.Fatal("expected updated cost", cost, updated)
+	}
+	if len(c.entries) != 1 {
+		t.Fatal("expired entries should have been pruned", len(c.entries))
+	}
+
+	// a nil cache always computes the cost
+	var nilCache *hasSectorCostCache
+	if cost := nilCache.managedCost("host", pt, 10, now); !cost.Equals(updated) {
+		t.Fatal("unexpected cost", cost, updated)
+	}
+}
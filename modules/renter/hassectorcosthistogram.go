@@ -0,0 +1,110 @@
+package renter
+
+import (
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// hasSectorCostHistogramDecayInterval is the interval at which the counts
+	// of the HasSector cost histogram are decayed. Decaying the counts makes
+	// the histogram reflect the recent prices of the hosts.
+	hasSectorCostHistogramDecayInterval = build.Select(build.Var{
+		Dev:      10 * time.Minute,
+		Standard: time.Hour,
+		Testnet:  time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+)
+
+const (
+	// hasSectorCostHistogramDecay is the factor the counts of the HasSector
+	// cost histogram are multiplied with every decay interval.
+	hasSectorCostHistogramDecay = 0.9
+
+	// hasSectorCostHistogramMinCount is the count below which a bucket is
+	// dropped from the histogram after decaying.
+	hasSectorCostHistogramMinCount = 0.01
+)
+
+// hasSectorCostHistogram is an exponential histogram of the costs of the
+// HasSector jobs that the chunk worker sets of the renter compute for their
+// workers. Bucket i contains the costs c with 2^(i-1) <= c < 2^i hastings,
+// bucket 0 contains the jobs that are free. The counts decay over time. A nil
+// histogram doesn't record anything.
+type hasSectorCostHistogram struct {
+	buckets   map[int]float64
+	lastDecay time.Time
+	mu        sync.Mutex
+}
+
+// newHasSectorCostHistogram creates a new, empty histogram.
+func newHasSectorCostHistogram() *hasSectorCostHistogram {
+	return &hasSectorCostHistogram{
+		buckets:   make(map[int]float64),
+		lastDecay: time.Now(),
+	}
+}
+
+// decay applies the decay of the intervals that passed since the last decay.
+func (h *hasSectorCostHistogram) decay(now time.Time) {
+	intervals := int(now.Sub(h.lastDecay) / hasSectorCostHistogramDecayInterval)
+	if intervals <= 0 {
+		return
+	}
+	factor := math.Pow(hasSectorCostHistogramDecay, float64(intervals))
+	for bucket, count := range h.buckets {
+		count *= factor
+		if count < hasSectorCostHistogramMinCount {
+			delete(h.buckets, bucket)
+			continue
+		}
+		h.buckets[bucket] = count
+	}
+	h.lastDecay = h.lastDecay.Add(time.Duration(intervals) * hasSectorCostHistogramDecayInterval)
+}
+
+// managedAdd records the cost of a HasSector job.
+func (h *hasSectorCostHistogram) managedAdd(cost types.Currency, now time.Time) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.decay(now)
+	h.buckets[cost.Big().BitLen()]++
+}
+
+// managedBuckets returns the non-empty buckets of the histogram, sorted by
+// their bounds.
+func (h *hasSectorCostHistogram) managedBuckets(now time.Time) []modules.HasSectorCostBucket {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.decay(now)
+	var buckets []modules.HasSectorCostBucket
+	for bucket := 0; len(buckets) < len(h.buckets); bucket++ {
+		count, exists := h.buckets[bucket]
+		if !exists {
+			continue
+		}
+		var min types.Currency
+		if bucket > 0 {
+			min = types.NewCurrency(new(big.Int).Lsh(big.NewInt(1), uint(bucket-1)))
+		}
+		buckets = append(buckets, modules.HasSectorCostBucket{
+			Min:   min,
+			Max:   types.NewCurrency(new(big.Int).Lsh(big.NewInt(1), uint(bucket))),
+			Count: count,
+		})
+	}
+	return buckets
+}
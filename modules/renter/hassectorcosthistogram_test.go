@@ -0,0 +1,66 @@
+package renter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/types"
+)
+
+// TestHasSectorCostHistogram is a unit test for the hasSectorCostHistogram.
+func TestHasSectorCostHistogram(t *testing.T) {
+	t.Parallel()
+
+	h := newHasSectorCostHistogram()
+	now := h.lastDecay
+	if buckets := h.managedBuckets(now); len(buckets) != 0 {
+		t.Fatal("histogram should be empty", buckets)
+	}
+
+	// costs are sorted into exponential buckets
+	for _, cost := range []uint64{0, 1, 2, 3, 4, 7, 8, 1000} {
+		h.managedAdd(types.NewCurrency64(cost), now)
+	}
+	expected := []struct {
+		min, max uint64
+		count    float64
+	}{
+		{0, 1, 1},
+		{1, 2, 1},
+		{2, 4, 2},
+		{4, 8, 2},
+		{8, 16, 1},
+		{512, 1024, 1},
+	}
+	buckets := h.managedBuckets(now)
+	if len(buckets) != len(expected) {
+		t.Fatal("unexpected number of buckets", buckets)
+	}
+	for i, b := range buckets {
+		e := expected[i]
+		if !b.Min.Equals64(e.min) || !b.Max.Equals64(e.max) || b.Count != e.count {
+			t.Fatalf("bucket %v: expected %v, got %v", i, e, b)
+		}
+	}
+
+	// the counts decay every interval, buckets with a negligible count are
+	// dropped
+	now = now.Add(2*hasSectorCostHistogramDecayInterval + time.Second)
+	buckets = h.managedBuckets(now)
+	decay := hasSectorCostHistogramDecay * hasSectorCostHistogramDecay
+	if len(buckets) != len(expected) || math.Abs(buckets[2].Count-2*decay) > 1e-9 {
+		t.Fatal("unexpected buckets after decay", buckets)
+	}
+	now = now.Add(100 * hasSectorCostHistogramDecayInterval)
+	if buckets := h.managedBuckets(now); len(buckets) != 0 {
+		t.Fatal("buckets should have been dropped", buckets)
+	}
+
+	// a nil histogram doesn't record anything
+	var nilHistogram *hasSectorCostHistogram
+	nilHistogram.managedAdd(types.NewCurrency64(1), now)
+	if buckets := nilHistogram.managedBuckets(now); buckets != nil {
+		t.Fatal("nil histogram should be empty", buckets)
+	}
+}
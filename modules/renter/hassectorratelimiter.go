@@ -0,0 +1,156 @@
+package renter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+)
+
+var (
+	// hasSectorRateLimit is the number of HasSector jobs per second that the
+	// chunk worker sets of the renter can launch in aggregate. A rate of 0
+	// disables the limit.
+	hasSectorRateLimit = build.Select(build.Var{
+		Dev:      float64(1000),
+		Standard: float64(500),
+		Testnet:  float64(500),
+		Testing:  float64(0),
+	}).(float64)
+
+	// hasSectorRateLimitBurst is the number of HasSector jobs that can be
+	// launched at once if the limiter was idle for long enough.
+	hasSectorRateLimitBurst = build.Select(build.Var{
+		Dev:      2000,
+		Standard: 1000,
+		Testnet:  1000,
+		Testing:  100,
+	}).(int)
+
+	// hasSectorRateLimitMaxWait is the maximum amount of time a chunk worker
+	// set waits for the rate limiter before it skips a worker. A value of 0
+	// means that workers are skipped right away while the limiter is
+	// saturated.
+	hasSectorRateLimitMaxWait = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: time.Second,
+		Testnet:  time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+)
+
+var (
+	// errHasSectorRateLimited is returned if a HasSector job wasn't launched
+	// because the rate limiter was saturated.
+	errHasSectorRateLimited = errors.New("HasSector rate limit reached")
+)
+
+// hasSectorRateLimiter is a token bucket which limits the rate at which the
+// chunk worker sets of the renter launch HasSector jobs. A nil limiter, or a
+// limiter with a rate of 0, doesn't limit anything.
+type hasSectorRateLimiter struct {
+	staticBurst   float64
+	staticMaxWait time.Duration
+	staticRate    float64
+
+	lastRefill time.Time
+	skipped    uint64
+	tokens     float64
+	mu         sync.Mutex
+}
+
+// newHasSectorRateLimiter creates a new rate limiter which allows for 'rate'
+// jobs per second with bursts of up to 'burst' jobs. Acquiring a token waits up
+// to maxWait for the limiter to refill before giving up.
+func newHasSectorRateLimiter(rate float64, burst int, maxWait time.Duration) *hasSectorRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &hasSectorRateLimiter{
+		staticBurst:   float64(burst),
+		staticMaxWait: maxWait,
+		staticRate:    rate,
+
+		lastRefill: time.Now(),
+		tokens:     float64(burst),
+	}
+}
+
+// enabled returns whether the limiter limits the rate of jobs.
+func (l *hasSectorRateLimiter) enabled() bool {
+	return l != nil && l.staticRate > 0
+}
+
+// refill adds the tokens that accumulated since the last refill.
+func (l *hasSectorRateLimiter) refill(now time.Time) {
+	elapsed := now.Sub(l.lastRefill)
+	if elapsed <= 0 {
+		return
+	}
+	l.tokens += elapsed.Seconds() * l.staticRate
+	if l.tokens > l.staticBurst {
+		l.tokens = l.staticBurst
+	}
+	l.lastRefill = now
+}
+
+// managedAcquire takes a token from the limiter. If the limiter is saturated,
+// it blocks until a token becomes available, unless that takes longer than the
+// limiter's max wait, in which case errHasSectorRateLimited is returned right
+// away.
+func (l *hasSectorRateLimiter) managedAcquire(ctx context.Context) error {
+	if !l.enabled() {
+		return nil
+	}
+	deadline := time.Now().Add(l.staticMaxWait)
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.refill(now)
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.staticRate * float64(time.Second))
+		if now.Add(wait).After(deadline) {
+			l.skipped++
+			l.mu.Unlock()
+			return errHasSectorRateLimited
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// managedSkipped returns the number of times a token couldn't be acquired
+// because the limiter was saturated.
+func (l *hasSectorRateLimiter) managedSkipped() uint64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.skipped
+}
+
+// managedUtilization returns the fraction of the limiter's burst that is
+// currently used up. 0 means that the limiter is idle, 1 means that it is
+// saturated. A limiter that is disabled always returns 0.
+func (l *hasSectorRateLimiter) managedUtilization() float64 {
+	if !l.enabled() {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	return (l.staticBurst - l.tokens) / l.staticBurst
+}
@@ -0,0 +1,99 @@
+package renter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestHasSectorRateLimiter is a unit test for the hasSectorRateLimiter.
+func TestHasSectorRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Disabled", testHasSectorRateLimiterDisabled)
+	t.Run("Skip", testHasSectorRateLimiterSkip)
+	t.Run("Wait", testHasSectorRateLimiterWait)
+}
+
+// testHasSectorRateLimiterDisabled verifies that a nil limiter and a limiter
+// without a rate never limit.
+func testHasSectorRateLimiterDisabled(t *testing.T) {
+	t.Parallel()
+
+	var nilLimiter *hasSectorRateLimiter
+	for _, l := range []*hasSectorRateLimiter{nilLimiter, newHasSectorRateLimiter(0, 1, 0)} {
+		for i := 0; i < 10; i++ {
+			if err := l.managedAcquire(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if l.managedUtilization() != 0 || l.managedSkipped() != 0 {
+			t.Fatal("disabled limiter shouldn't be utilized", l.managedUtilization(), l.managedSkipped())
+		}
+	}
+}
+
+// testHasSectorRateLimiterSkip verifies that a limiter without a max wait
+// skips jobs right away once its burst is used up.
+func testHasSectorRateLimiterSkip(t *testing.T) {
+	t.Parallel()
+
+	// use a low rate so the limiter doesn't refill during the test
+	burst := 4
+	l := newHasSectorRateLimiter(0.01, burst, 0)
+	if l.managedUtilization() != 0 {
+		t.Fatal("new limiter should be idle", l.managedUtilization())
+	}
+	for i := 0; i < burst; i++ {
+		if err := l.managedAcquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if u := l.managedUtilization(); u < 0.99 {
+		t.Fatal("limiter should be saturated", u)
+	}
+	if err := l.managedAcquire(context.Background()); err != errHasSectorRateLimited {
+		t.Fatal("expected the job to be skipped, got", err)
+	}
+	if l.managedSkipped() != 1 {
+		t.Fatal("unexpected number of skips", l.managedSkipped())
+	}
+}
+
+// testHasSectorRateLimiterWait verifies that a limiter with a max wait blocks
+// until a token becomes available.
+func testHasSectorRateLimiterWait(t *testing.T) {
+	t.Parallel()
+
+	// 20 tokens per second means a new token every 50ms
+	l := newHasSectorRateLimiter(20, 1, time.Second)
+	if err := l.managedAcquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := l.managedAcquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatal("acquire should have blocked", elapsed)
+	}
+
+	// a cancelled context stops the wait
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.managedAcquire(ctx); err != context.Canceled {
+		t.Fatal("expected the context error, got", err)
+	}
+
+	// a max wait shorter than the time until the next token skips the job
+	l = newHasSectorRateLimiter(1, 1, 10*time.Millisecond)
+	if err := l.managedAcquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.managedAcquire(context.Background()); err != errHasSectorRateLimited {
+		t.Fatal("expected the job to be skipped, got", err)
+	}
+	if l.managedSkipped() != 1 {
+		t.Fatal("unexpected number of skips", l.managedSkipped())
+	}
+}
@@ -1,9 +1,37 @@
 package hostdb
 
-import "go.sia.tech/siad/modules"
+import (
+	"time"
+
+	"go.sia.tech/siad/modules"
+)
 
 // Alerts implements the modules.Alerter interface for the hostdb. It returns
 // all alerts of the hostdb.
 func (hdb *HostDB) Alerts() (crit, err, warn, info []modules.Alert) {
 	return hdb.staticAlerter.Alerts()
 }
+
+// RegisterSubscriber implements the modules.AlertSubscriber interface for the
+// hostdb.
+func (hdb *HostDB) RegisterSubscriber(ch chan<- modules.AlertEvent) {
+	hdb.staticAlerter.RegisterSubscriber(ch)
+}
+
+// UnregisterSubscriber implements the modules.AlertSubscriber interface for
+// the hostdb.
+func (hdb *HostDB) UnregisterSubscriber(ch chan<- modules.AlertEvent) {
+	hdb.staticAlerter.UnregisterSubscriber(ch)
+}
+
+// DismissModuleAlert implements the modules.AlertDismisser interface for the
+// hostdb.
+func (hdb *HostDB) DismissModuleAlert(module string, id modules.AlertID) error {
+	return hdb.staticAlerter.DismissModuleAlert(module, id)
+}
+
+// SnoozeModuleAlert implements the modules.AlertDismisser interface for the
+// hostdb.
+func (hdb *HostDB) SnoozeModuleAlert(module string, id modules.AlertID, d time.Duration) error {
+	return hdb.staticAlerter.SnoozeModuleAlert(module, id, d)
+}
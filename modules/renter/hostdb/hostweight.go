@@ -241,49 +241,7 @@ func (hdb *HostDB) interactionAdjustments(entry modules.HostDBEntry) float64 {
 // are on a per-block basis, meaning you need to multiply be the allowance
 // period when working with these values.
 func (hdb *HostDB) priceAdjustments(entry modules.HostDBEntry, allowance modules.Allowance, txnFees types.Currency) float64 {
-	// Divide by zero mitigation.
-	if allowance.Hosts == 0 {
-		allowance.Hosts = 1
-	}
-	if allowance.Period == 0 {
-		allowance.Period = 1
-	}
-	if allowance.ExpectedStorage == 0 {
-		allowance.ExpectedStorage = 1
-	}
-	if allowance.ExpectedUpload == 0 {
-		allowance.ExpectedUpload = 1
-	}
-	if allowance.ExpectedDownload == 0 {
-		allowance.ExpectedDownload = 1
-	}
-	if allowance.ExpectedRedundancy == 0 {
-		allowance.ExpectedRedundancy = 1
-	}
-
-	// Convert each element of the allowance into a number of resources that we
-	// expect to use in this contract.
-	contractExpectedDownload := types.NewCurrency64(allowance.ExpectedDownload).Mul64(uint64(allowance.Period)).Div64(allowance.Hosts)
-	contractExpectedFunds := allowance.Funds.Div64(allowance.Hosts)
-	contractExpectedStorage := uint64(float64(allowance.ExpectedStorage) * allowance.ExpectedRedundancy / float64(allowance.Hosts))
-	contractExpectedStorageTime := types.NewCurrency64(contractExpectedStorage).Mul64(uint64(allowance.Period))
-	contractExpectedUpload := types.NewCurrency64(allowance.ExpectedUpload).Mul64(uint64(allowance.Period)).MulFloat(allowance.ExpectedRedundancy).Div64(allowance.Hosts)
-
-	// Get the extra costs expected for downloads and uploads from the sector access
-	// price and base price.
-	extraCostsPerRPC := entry.BaseRPCPrice.Add(entry.SectorAccessPrice)
-
-	contractExpectedDownloadRPCs := contractExpectedDownload.Div64(modules.StreamDownloadSize)
-	extraDownloadRPCCost := contractExpectedDownloadRPCs.Mul(extraCostsPerRPC)
-
-	contractExpectedUploadRPCs := contractExpectedUpload.Div64(modules.StreamUploadSize)
-	extraUploadRPCCost := contractExpectedUploadRPCs.Mul(extraCostsPerRPC)
-
-	// Calculate the hostCollateral the renter would expect the host to put
-	// into a contract.
-	//
-	contractTxnFees := txnFees.Mul64(modules.EstimatedFileContractTransactionSetSize)
-	_, _, hostCollateral, err := modules.RenterPayoutsPreTax(entry, contractExpectedFunds, contractTxnFees, types.ZeroCurrency, types.ZeroCurrency, allowance.Period, contractExpectedStorage)
+	totalPrice, err := modules.EstimateContractCost(entry, allowance, txnFees)
 	if err != nil {
 		// Errors containing 'exceeds funding' are not logged. All it means is
 		// that the contract price (or some other price) of the host is too high
@@ -298,18 +256,11 @@ func (hdb *HostDB) priceAdjustments(entry modules.HostDBEntry, allowance modules
 		return math.SmallestNonzeroFloat64
 	}
 
-	// Determine the pricing for each type of resource in the contract. We have
-	// already converted the resources into absolute terms for this contract.
-	//
-	// The contract price and transaction fees get doubled because we expect
-	// that there will be on average one early renewal per contract, due to
-	// spending all of the contract's money.
-	contractPrice := entry.ContractPrice.Add(txnFees).Mul64(2)
-	downloadPrice := entry.DownloadBandwidthPrice.Mul(contractExpectedDownload).Add(extraDownloadRPCCost)
-	storagePrice := entry.StoragePrice.Mul(contractExpectedStorageTime)
-	uploadPrice := entry.UploadBandwidthPrice.Mul(contractExpectedUpload).Add(extraUploadRPCCost)
-	siafundFee := contractPrice.Add(hostCollateral).Add(downloadPrice).Add(storagePrice).Add(uploadPrice).MulTax()
-	totalPrice := contractPrice.Add(downloadPrice).Add(storagePrice).Add(uploadPrice).Add(siafundFee)
+	// Divide by zero mitigation.
+	if allowance.Hosts == 0 {
+		allowance.Hosts = 1
+	}
+	contractExpectedFunds := allowance.Funds.Div64(allowance.Hosts)
 
 	// Determine a cutoff for whether the total price is considered a high price
 	// or a low price. This cutoff attempts to determine where the price becomes
@@ -92,6 +92,26 @@ func (hdb *HostDB) managedUpdateTxnFees() {
 	hdb.staticLog.Println("Updated the hostdb txnFees to", newTxnFees.HumanString())
 }
 
+// ScheduleRescan queues a scan of the host with the given public key. The
+// renter uses this when it observes prices that suggest the settings we have
+// on record for the host are out of date.
+func (hdb *HostDB) ScheduleRescan(pk types.SiaPublicKey) error {
+	if err := hdb.tg.Add(); err != nil {
+		return errors.AddContext(err, "error adding hostdb threadgroup:")
+	}
+	defer hdb.tg.Done()
+
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+
+	host, exists := hdb.staticHostTree.Select(pk)
+	if !exists {
+		return errors.AddContext(errHostNotFoundInTree, "unable to schedule rescan:")
+	}
+	hdb.queueScan(host)
+	return nil
+}
+
 // queueScan will add a host to the queue to be scanned. The host will be added
 // at a random position which means that the order in which queueScan is called
 // is not necessarily the order in which the hosts get scanned. That guarantees
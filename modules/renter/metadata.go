@@ -109,6 +109,7 @@ func (r *Renter) callCalculateDirectoryMetadata(siaPath modules.SiaPath) (siadir
 	if err != nil {
 		r.log.Printf("failed to calculate file metadata: %v", err)
 	}
+	fileMetadatas := bubbledMetadatas
 
 	// Get all the Directory Metadata
 	//
@@ -275,6 +276,8 @@ func (r *Renter) callCalculateDirectoryMetadata(siaPath modules.SiaPath) (siadir
 		metadata.MinRedundancy = -1
 	}
 
+	// Update the redundancy alerts with the directory's files.
+	r.managedUpdateRedundancyAlerts(siaPath, fileMetadatas, metadata.MinRedundancy)
 	return metadata, nil
 }
 
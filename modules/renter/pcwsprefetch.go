@@ -0,0 +1,184 @@
+package renter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// pcwsPrefetchCacheMaxEntries is the maximum number of prefetched chunks
+	// that are kept in the prefetch cache.
+	pcwsPrefetchCacheMaxEntries = build.Select(build.Var{
+		Dev:      100,
+		Standard: 1000,
+		Testnet:  1000,
+		Testing:  3,
+	}).(int)
+
+	// pcwsPrefetchCacheTTL is the amount of time for which prefetched data is
+	// kept in the prefetch cache. The data is only meant to speed up the
+	// download that immediately follows the creation of the pcws.
+	pcwsPrefetchCacheTTL = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 2 * time.Minute,
+		Testnet:  2 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
+	// pcwsPrefetchTimeout is the amount of time after which a prefetch is
+	// abandoned.
+	pcwsPrefetchTimeout = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: time.Minute,
+		Testnet:  time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+)
+
+type (
+	// pcwsPrefetchCache is a short-lived cache for the data of chunks that
+	// was prefetched when their pcws was created. The data is keyed by the
+	// root of the chunk's first piece and always starts at the beginning of
+	// the chunk.
+	pcwsPrefetchCache struct {
+		entries map[crypto.Hash]pcwsPrefetchEntry
+		mu      sync.Mutex
+	}
+
+	// pcwsPrefetchEntry is the prefetched data of a chunk.
+	pcwsPrefetchEntry struct {
+		data    []byte
+		expires time.Time
+	}
+)
+
+// newPCWSPrefetchCache creates a new, empty prefetch cache.
+func newPCWSPrefetchCache() *pcwsPrefetchCache {
+	return &pcwsPrefetchCache{
+		entries: make(map[crypto.Hash]pcwsPrefetchEntry),
+	}
+}
+
+// managedAdd adds the prefetched data of the chunk with the given root to the
+// cache. If the cache is full, the entry that expires first is evicted.
+func (pc *pcwsPrefetchCache) managedAdd(root crypto.Hash, data []byte, now time.Time) {
+	if pc == nil {
+		return
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.pruneExpired(now)
+	if _, exists := pc.entries[root]; !exists && len(pc.entries) >= pcwsPrefetchCacheMaxEntries {
+		var evict crypto.Hash
+		var evictExpires time.Time
+		for r, entry := range pc.entries {
+			if evictExpires.IsZero() || entry.expires.Before(evictExpires) {
+				evict, evictExpires = r, entry.expires
+			}
+		}
+		delete(pc.entries, evict)
+	}
+	pc.entries[root] = pcwsPrefetchEntry{
+		data:    data,
+		expires: now.Add(pcwsPrefetchCacheTTL),
+	}
+}
+
+// managedGet returns a copy of the prefetched data of the chunk with the
+// given root in the range [offset, offset+length). The bool is false if the
+// range wasn't prefetched.
+func (pc *pcwsPrefetchCache) managedGet(root crypto.Hash, offset, length uint64, now time.Time) ([]byte, bool) {
+	if pc == nil {
+		return nil, false
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.pruneExpired(now)
+	entry, exists := pc.entries[root]
+	if !exists || offset+length > uint64(len(entry.data)) {
+		return nil, false
+	}
+	return append([]byte{}, entry.data[offset:offset+length]...), true
+}
+
+// pruneExpired removes the expired entries from the cache.
+func (pc *pcwsPrefetchCache) pruneExpired(now time.Time) {
+	for root, entry := range pc.entries {
+		if !now.Before(entry.expires) {
+			delete(pc.entries, root)
+		}
+	}
+}
+
+// pcwsPrefetchLength returns the number of bytes at the beginning of a chunk
+// that are prefetched. That's the size of a streaming download, unless the
+// chunk needs to be downloaded in full due to its encryption overhead.
+func pcwsPrefetchLength(ec modules.ErasureCoder, key crypto.CipherKey) uint64 {
+	chunkSize := modules.SectorSize * uint64(ec.MinPieces())
+	if key.Type().Overhead() != 0 || chunkSize < modules.StreamDownloadSize {
+		return chunkSize
+	}
+	return modules.StreamDownloadSize
+}
+
+// newPCWSByRootsWithPrefetch will create a worker set to download a chunk
+// given just the set of sector roots associated with the pieces, like
+// newPCWSByRoots, but also speculatively downloads the beginning of the chunk
+// into the renter's prefetch cache. This makes the first download from a
+// chunk that is known to be popular instant.
+func (r *Renter) newPCWSByRootsWithPrefetch(ctx context.Context, roots []crypto.Hash, ec modules.ErasureCoder, masterKey crypto.CipherKey, chunkIndex uint64) (*projectChunkWorkerSet, error) {
+	pcws, err := r.newPCWS(ctx, roots, ec, masterKey, chunkIndex, nil, types.ZeroCurrency, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	go pcws.threadedPrefetch()
+	return pcws, nil
+}
+
+// threadedPrefetch downloads the beginning of the chunk and adds it to the
+// renter's prefetch cache. The download goes through the regular download
+// code, so the download gouging checks apply to the prefetch as well.
+func (pcws *projectChunkWorkerSet) threadedPrefetch() {
+	r := pcws.staticRenter
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pcwsPrefetchTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-r.tg.StopChan():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// A prefetch isn't worth paying for faster workers, use a zero price per
+	// millisecond.
+	length := pcwsPrefetchLength(pcws.staticErasureCoder, pcws.staticMasterKey)
+	respChan, err := pcws.managedDownload(ctx, types.ZeroCurrency, 0, length)
+	if err != nil {
+		pcws.staticDebugf("unable to prefetch chunk, err %v", err)
+		return
+	}
+	var resp *downloadResponse
+	select {
+	case resp = <-respChan:
+	case <-ctx.Done():
+		pcws.staticDebugf("prefetch timed out")
+		return
+	}
+	if resp.err != nil {
+		pcws.staticDebugf("prefetch failed, err %v", resp.err)
+		return
+	}
+	r.staticPCWSPrefetchCache.managedAdd(pcws.staticPieceRoots[0], resp.data, time.Now())
+}
@@ -0,0 +1,129 @@
+package renter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestPCWSPrefetchCache is a unit test for the pcwsPrefetchCache.
+func TestPCWSPrefetchCache(t *testing.T) {
+	t.Parallel()
+
+	pc := newPCWSPrefetchCache()
+	now := time.Now()
+	root := crypto.Hash{1}
+	data := fastrand.Bytes(100)
+	pc.managedAdd(root, data, now)
+
+	// ranges within the data are served
+	got, ok := pc.managedGet(root, 10, 20, now)
+	if !ok || !bytes.Equal(got, data[10:30]) {
+		t.Fatal("unexpected data", ok)
+	}
+	got[0]++
+	if got, _ := pc.managedGet(root, 10, 20, now); !bytes.Equal(got, data[10:30]) {
+		t.Fatal("cache should return a copy")
+	}
+
+	// ranges beyond the data and unknown roots are not
+	if _, ok := pc.managedGet(root, 90, 20, now); ok {
+		t.Fatal("range beyond the data shouldn't be served")
+	}
+	if _, ok := pc.managedGet(crypto.Hash{2}, 0, 10, now); ok {
+		t.Fatal("unknown root shouldn't be served")
+	}
+
+	// entries expire
+	if _, ok := pc.managedGet(root, 0, 10, now.Add(pcwsPrefetchCacheTTL)); ok {
+		t.Fatal("expired entry shouldn't be served")
+	}
+	if len(pc.entries) != 0 {
+		t.Fatal("expired entry should be pruned", len(pc.entries))
+	}
+
+	// once full, the entry that expires first is evicted
+	for i := 0; i < pcwsPrefetchCacheMaxEntries+1; i++ {
+		pc.managedAdd(crypto.Hash{byte(i)}, data, now.Add(time.Duration(i)*time.Second))
+	}
+	if len(pc.entries) != pcwsPrefetchCacheMaxEntries {
+		t.Fatal("unexpected number of entries", len(pc.entries))
+	}
+	if _, ok := pc.managedGet(crypto.Hash{0}, 0, 10, now); ok {
+		t.Fatal("oldest entry should have been evicted")
+	}
+
+	// a nil cache is never hit
+	var nilCache *pcwsPrefetchCache
+	nilCache.managedAdd(root, data, now)
+	if _, ok := nilCache.managedGet(root, 0, 10, now); ok {
+		t.Fatal("nil cache shouldn't be hit")
+	}
+}
+
+// testNewPCWSByRootsWithPrefetch verifies that a pcws created with prefetch
+// downloads the beginning of the chunk into the prefetch cache and serves the
+// first download from it.
+func testNewPCWSByRootsWithPrefetch(t *testing.T, wt *workerTester) {
+	// add a random sector to the host
+	sectorData := fastrand.Bytes(int(modules.SectorSize))
+	sectorRoot := crypto.MerkleRoot(sectorData)
+	err := wt.host.AddSector(sectorRoot, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create a passthrough EC and a passhtrough cipher key
+	ptec := modules.NewPassthroughErasureCoder()
+	ptck, err := crypto.NewSiaKey(crypto.TypePlain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create the pcws and wait for the prefetch to land in the cache
+	pcws, err := wt.renter.newPCWSByRootsWithPrefetch(context.Background(), []crypto.Hash{sectorRoot}, ptec, ptck, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	length := pcwsPrefetchLength(ptec, ptck)
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		data, ok := wt.renter.staticPCWSPrefetchCache.managedGet(sectorRoot, 0, length, time.Now())
+		if !ok {
+			return errors.New("chunk wasn't prefetched")
+		}
+		if !bytes.Equal(data, sectorData[:length]) {
+			return errors.New("prefetched data is wrong")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// remove the sector from the host, the download should still succeed
+	// since it is served from the cache
+	err = wt.host.RemoveSector(sectorRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	respChan, err := pcws.managedDownload(context.Background(), types.ZeroCurrency, 0, length/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resp := <-respChan:
+		if resp.err != nil || !bytes.Equal(resp.data, sectorData[:length/2]) {
+			t.Fatal("unexpected response", resp.err)
+		}
+	default:
+		t.Fatal("download from the cache should be instant")
+	}
+}
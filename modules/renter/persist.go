@@ -60,6 +60,21 @@ type (
 		MaxUploadSpeed   int64
 		UploadedBackups  []modules.UploadedBackup
 		SyncedContracts  []types.FileContractID
+
+		// GougingExemptions maps host public keys to the gouging checks that
+		// the host is exempt from.
+		GougingExemptions map[string]modules.GougingExemption
+
+		// DisablePriceAnomalyDetection is stored inverted so that renters
+		// upgrading from an older version have the detection enabled.
+		// PriceAnomalyFactor uses DefaultPriceAnomalyFactor when zero.
+		DisablePriceAnomalyDetection bool
+		PriceAnomalyFactor           float64
+
+		// GougingSpending is the amount spent on RPCs during the period that
+		// started at GougingSpendingPeriod.
+		GougingSpending       modules.GougingSpending
+		GougingSpendingPeriod types.BlockHeight
 	}
 )
 
@@ -183,6 +198,11 @@ func (r *Renter) managedInitPersist() error {
 	if err := r.managedLoadSettings(); err != nil {
 		return errors.AddContext(err, "failed to load renter's persistence structrue")
 	}
+	// Save the gouging spending on shutdown, it is only saved periodically
+	// while the renter is running.
+	if err := r.tg.AfterStop(r.managedSaveGougingSpending); err != nil {
+		return err
+	}
 
 	// Create the essential dirs in the filesystem.
 	err = fs.NewSiaDir(modules.HomeFolder, modules.DefaultDirPerm)
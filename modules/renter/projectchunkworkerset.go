@@ -3,6 +3,8 @@ package renter
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
 	"go.sia.tech/siad/types"
 
 	"gitlab.com/NebulousLabs/errors"
@@ -23,6 +26,18 @@ var (
 	// ErrProjectTimedOut is returned when the project timed out
 	ErrProjectTimedOut = errors.New("project timed out")
 
+	// ErrAllWorkersGouging is returned when a refresh of a pcws can't launch
+	// any workers because all of them are rejected due to price gouging.
+	ErrAllWorkersGouging = errors.New("all workers were rejected due to price gouging")
+
+	// errPCWSGouging is returned when a worker isn't launched because the
+	// cost of its HasSector job indicates price gouging.
+	errPCWSGouging = errors.New("has sector job rejected due to price gouging")
+
+	// errPCWSHostsInsufficient is returned when a pcws is restricted to a set
+	// of hosts that can't possibly provide enough pieces to recover the chunk.
+	errPCWSHostsInsufficient = errors.New("not enough workers for the given hosts to recover the chunk")
+
 	// pcwsWorkerStateResetTime defines the amount of time that the pcws will
 	// wait before resetting / refreshing the worker state, meaning that all of
 	// the workers will do another round of HasSector queries on the network.
@@ -33,6 +48,71 @@ var (
 		Testing:  time.Second * 15,
 	}).(time.Duration)
 
+	// pcwsWorkerStateResetJitter is the maximum fraction by which the reset
+	// time of a pcws deviates from pcwsWorkerStateResetTime. Every pcws uses a
+	// random reset time within that range, which prevents pcws that were
+	// created around the same time from refreshing their worker state all at
+	// once.
+	pcwsWorkerStateResetJitter = 0.1
+
+	// pcwsWorkerStateResetTimeMin and pcwsWorkerStateResetTimeMax bound the
+	// reset time of a pcws. The reset time starts out at the jittered
+	// pcwsWorkerStateResetTime and adapts to the churn of the worker set that
+	// is observed on every refresh.
+	pcwsWorkerStateResetTimeMin = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: time.Hour,
+		Testnet:  time.Hour,
+		Testing:  time.Second * 5,
+	}).(time.Duration)
+	pcwsWorkerStateResetTimeMax = build.Select(build.Var{
+		Dev:      time.Hour,
+		Standard: time.Hour * 48,
+		Testnet:  time.Hour * 48,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// pcwsWorkerStateResetGrowth is the factor by which the reset time grows
+	// after a refresh that didn't change the worker set. Consecutive refreshes
+	// without changes therefore grow the reset time exponentially.
+	pcwsWorkerStateResetGrowth = 1.5
+
+	// pcwsWorkerStateResetShrink is the factor by which the reset time shrinks
+	// after a refresh with high churn.
+	pcwsWorkerStateResetShrink = 0.5
+
+	// pcwsWorkerStateHighChurn is the fraction of hosts with pieces that have
+	// to change between two refreshes for the churn to be considered high.
+	// Refreshes with a lower, non-zero churn keep the reset time.
+	pcwsWorkerStateHighChurn = 0.25
+
+	// pcwsGougingRetryWindow is the maximum amount of time that a download
+	// keeps retrying to refresh the worker state of a pcws while all workers
+	// are rejected due to price gouging. Gouging is potentially transient,
+	// because a host might lower its prices again, so the download waits for
+	// the next price table update of the workers and re-resolves them. Workers
+	// update their price table halfway through its validity, which hosts set to
+	// 10 minutes, so within the window every worker with a working connection
+	// gets at least one new price table. If the workers are still gouging
+	// after the window, the download fails with ErrAllWorkersGouging.
+	pcwsGougingRetryWindow = build.Select(build.Var{
+		Dev:      time.Minute * 5,
+		Standard: time.Minute * 10,
+		Testnet:  time.Minute * 10,
+		Testing:  time.Second * 5,
+	}).(time.Duration)
+
+	// pcwsGougingRetryDelay is the amount of time that a download waits after
+	// the next scheduled price table update before it retries, which gives the
+	// worker time to complete the update. It is also the minimum amount of
+	// time between two retries.
+	pcwsGougingRetryDelay = build.Select(build.Var{
+		Dev:      time.Second * 2,
+		Standard: time.Second * 5,
+		Testnet:  time.Second * 5,
+		Testing:  time.Millisecond * 100,
+	}).(time.Duration)
+
 	// pcwsHasSectorTimeout defines the amount of time that the pcws will wait
 	// before giving up on receiving a HasSector response from a single worker.
 	// This value is set as a global timeout because different download queries
@@ -44,6 +124,60 @@ var (
 		Testing:  time.Second * 10,
 	}).(time.Duration)
 
+	// pcwsOverResolutionFactor is the fraction of the chunk's pieces that the
+	// pcws tries to resolve as extra usable workers on top of the number of
+	// pieces. Resolved workers may still fail during the actual download, the
+	// buffer of extra workers makes it more likely that the download can
+	// continue without having to wait for a refresh of the worker state.
+	pcwsOverResolutionFactor = 0.5
+
+	// pcwsHasSectorErrorAlertThreshold is the fraction of the resolved workers
+	// that need to have failed their HasSector jobs during a refresh of the
+	// worker state for the renter to register an alert.
+	pcwsHasSectorErrorAlertThreshold = 0.5
+
+	// pcwsHasSectorErrorAlertMinWorkers is the minimum number of resolved
+	// workers for a refresh to update the HasSector error alert. Failures in
+	// smaller refreshes don't indicate a systemic problem.
+	pcwsHasSectorErrorAlertMinWorkers = 4
+
+	// pcwsHasSectorErrorAlertTTL is the time after which the HasSector error
+	// alert expires unless a later refresh registers it again. Without
+	// downloads there are no refreshes that could unregister it.
+	pcwsHasSectorErrorAlertTTL = build.Select(build.Var{
+		Dev:      10 * time.Minute,
+		Standard: time.Hour,
+		Testnet:  time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// pcwsLaunchWorkerMaxRetries is the number of times adding a HasSector
+	// job to a worker's queue is retried if the queue is temporarily
+	// unavailable.
+	pcwsLaunchWorkerMaxRetries = 2
+
+	// pcwsLaunchWorkerRetryBackoff is the amount of time the pcws waits before
+	// retrying to add a HasSector job for the first time. The wait is doubled
+	// with every further retry.
+	pcwsLaunchWorkerRetryBackoff = build.Select(build.Var{
+		Dev:      50 * time.Millisecond,
+		Standard: 100 * time.Millisecond,
+		Testnet:  100 * time.Millisecond,
+		Testing:  10 * time.Millisecond,
+	}).(time.Duration)
+
+	// pcwsLaunchWorkerRetryBudget is the total amount of time a refresh of the
+	// worker state may spend waiting to retry adding HasSector jobs. Workers
+	// are launched one after another, the budget keeps the retries from
+	// delaying the launch of the remaining workers by more than a fraction of
+	// pcwsHasSectorTimeout.
+	pcwsLaunchWorkerRetryBudget = build.Select(build.Var{
+		Dev:      2 * time.Second,
+		Standard: 5 * time.Second,
+		Testnet:  5 * time.Second,
+		Testing:  time.Second,
+	}).(time.Duration)
+
 	// sectorLookupToDownloadRatio is an arbitrary ratio that resembles the
 	// amount of lookups vs downloads. It is used in price gouging checks.
 	sectorLookupToDownloadRatio = 16
@@ -63,6 +197,10 @@ const (
 	pcwsGougingFractionDenom = 25
 )
 
+// pcwsGougingCallback is the signature of the callback that is called when a
+// projectChunkWorkerSet rejects a worker because its host is price gouging.
+type pcwsGougingCallback func(hostKey types.SiaPublicKey, reason error)
+
 // pcwsUnreseovledWorker tracks an unresolved worker that is associated with a
 // specific projectChunkWorkerSet. The timestamp indicates when the unresolved
 // worker is expected to have a resolution, and is an estimate based on historic
@@ -80,10 +218,16 @@ type pcwsUnresolvedWorker struct {
 // is a list of piece indices where the worker responded that they had the piece
 // at that index. There is also an error field that will be set in the event an
 // error occurred while performing the HasSector query.
+//
+// If the host turns out to be too expensive to read the pieces from, the
+// worker remains in the set of resolved workers but 'readGougingErr' is set to
+// the reason it was skipped by the downloads.
 type pcwsWorkerResponse struct {
 	worker       *worker
 	pieceIndices []uint64
 	err          error
+
+	readGougingErr error
 }
 
 // pcwsWorkerState contains the worker state for a single thread that is
@@ -118,11 +262,112 @@ type pcwsWorkerState struct {
 	// pcwsWorkerSet as a whole can be reset by replacing the worker state.
 	workerUpdateChans []chan struct{}
 
+	// workerUpdateStateChans serve the same purpose as the workerUpdateChans,
+	// except that a resolveUpdate describing the new state of the worker state
+	// is sent down the channel before it is closed. This allows the caller to
+	// act on the update without having to re-acquire the lock.
+	workerUpdateStateChans []chan resolveUpdate
+
+	// staticResolutionDone is closed once resolution of the worker state is
+	// complete, meaning all launched workers have resolved or the HasSector
+	// timeout fired. resolutionComplete indicates whether the channel was
+	// closed already.
+	staticResolutionDone chan struct{}
+	resolutionComplete   bool
+
+	// numErrored and numSucceeded count the resolved workers whose HasSector
+	// jobs failed and succeeded. The counts are reported to the renter once
+	// resolution is complete.
+	numErrored   int
+	numSucceeded int
+
+	// numLaunched is the number of workers that were launched for the worker
+	// state and costCeilingReached indicates whether launching more workers
+	// was stopped by the cost ceiling of the pcws. Both are set before the
+	// worker state is used.
+	numLaunched        int
+	costCeilingReached bool
+
+	// numKnown is the number of workers that were resolved from the known
+	// host-root pairs of a siafile without launching HasSector jobs. It is
+	// set before the worker state is used.
+	numKnown int
+
+	// numAttempted is the number of workers that a launch was attempted for
+	// and numGouging the number of those that were rejected due to price
+	// gouging. Both are set before the worker state is used.
+	numAttempted int
+	numGouging   int
+
+	// launchRetryTime is the amount of time spent waiting to retry adding
+	// HasSector jobs while launching the workers. It is bounded by
+	// pcwsLaunchWorkerRetryBudget.
+	launchRetryTime time.Duration
+
+	// findStartTime is the time at which threadedFindWorkers started to find
+	// the workers of the worker state. timeToFirstResolve is the duration
+	// from findStartTime until the first worker resolved, it is recorded only
+	// once, guarded by firstResolveOnce, and firstResolved indicates whether
+	// it was recorded. It bounds how early a download from the worker state
+	// can start.
+	findStartTime      time.Time
+	timeToFirstResolve time.Duration
+	firstResolved      bool
+	firstResolveOnce   sync.Once
+
+	// numUsable is the number of resolved workers that have at least one of
+	// the chunk's pieces. Once it reaches staticResolutionTarget, the worker
+	// state has a buffer of staticResolutionTarget - staticNumPieces extra
+	// workers. The worker state keeps collecting responses beyond the target.
+	numUsable              int
+	staticNumPieces        int
+	staticResolutionTarget int
+
 	// Utilities.
 	staticRenter *Renter
 	mu           sync.Mutex
 }
 
+// resolveUpdate is the payload sent down the channels returned by
+// registerForWorkerUpdateWithState. It reflects the state of the worker state
+// at the time the update was sent.
+type resolveUpdate struct {
+	// numResolved is the number of workers that have responded to their
+	// HasSector query.
+	numResolved int
+
+	// numTotal is the total number of workers that were launched, which is the
+	// sum of the resolved and unresolved workers.
+	numTotal int
+}
+
+// pcwsInitMode describes how a pcws was initialized.
+type pcwsInitMode int
+
+const (
+	// pcwsInitModeRoots is the mode of a pcws that was initialized with only
+	// the roots of the chunk's pieces. The hosts that store the pieces are
+	// found by querying the network, see newPCWSByRoots.
+	pcwsInitModeRoots pcwsInitMode = iota
+
+	// pcwsInitModeSiaFile is the mode of a pcws that was initialized with a
+	// siafile, where the host-root pairs of the chunk's pieces are already
+	// known.
+	pcwsInitModeSiaFile
+)
+
+// String implements the fmt.Stringer interface.
+func (m pcwsInitMode) String() string {
+	switch m {
+	case pcwsInitModeRoots:
+		return "roots"
+	case pcwsInitModeSiaFile:
+		return "siafile"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(m))
+	}
+}
+
 // projectChunkWorkerSet is an object that contains a set of workers that can be
 // used to download a single chunk. The object can be initialized with a siafile
 // where the host-root pairs are already known (for traditional renter
@@ -132,6 +377,8 @@ type pcwsWorkerState struct {
 // up a bunch of worker jobs to locate those roots on the network using
 // HasSector programs.
 //
+// The way a pcws was initialized is recorded in its staticInitMode.
+//
 // Once the pcws has been initialized, it can be used repeatedly to download
 // data from the chunk, and it will not need to repeat the network lookups.
 // Every few hours (pcwsWorkerStateResetTime), it will re-do the lookups to
@@ -161,18 +408,129 @@ type projectChunkWorkerSet struct {
 	workerState           *pcwsWorkerState
 	workerStateLaunchTime time.Time
 
+	// workerSetChanged indicates whether the most recent refresh of the
+	// worker state resolved a different set of workers than the worker state
+	// it replaced. Workers are compared by their host pubkey and the piece
+	// indices they have. workerSetChangedChan is closed and replaced every
+	// time a refresh changes the worker set, which allows downloads to only
+	// re-plan when something moved. The initial worker state doesn't count
+	// as a change.
+	workerSetChanged     bool
+	workerSetChangedChan chan struct{}
+
+	// staticWorkerStateResetTime is the initial amount of time after which
+	// the worker state is refreshed. It is pcwsWorkerStateResetTime with some
+	// random jitter applied.
+	//
+	// workerStateResetTime is the current amount of time after which the
+	// worker state is refreshed. It starts out at staticWorkerStateResetTime
+	// and is adapted after every refresh depending on how much the worker set
+	// changed, see pcwsAdaptedResetTime.
+	staticWorkerStateResetTime time.Duration
+	workerStateResetTime       time.Duration
+
+	// numRefreshes is the number of worker states that were launched over the
+	// lifetime of the pcws, including the initial one. staticMaxRefreshes caps
+	// that number, once it is reached the worker state is treated as current
+	// forever. A zero cap means the worker state is refreshed indefinitely.
+	numRefreshes       uint64
+	staticMaxRefreshes uint64
+
+	// staticID is a short identifier of the pcws which is derived from the
+	// root of its first piece. It is used to correlate log lines.
+	staticID string
+
+	// staticInitMode indicates whether the pcws was initialized with just the
+	// roots of the chunk's pieces or with a siafile. It is set at creation.
+	staticInitMode pcwsInitMode
+
 	// Decoding and decryption information for the chunk.
 	staticChunkIndex   uint64
 	staticErasureCoder modules.ErasureCoder
 	staticMasterKey    crypto.CipherKey
 	staticPieceRoots   []crypto.Hash
 
+	// staticGougingCallback is called when a worker is rejected because its
+	// host is price gouging. It is optional and never called with the worker
+	// state lock held.
+	staticGougingCallback pcwsGougingCallback
+
+	// staticHosts restricts the workers that are queried to the workers of
+	// the hosts in the set. If the set is nil, all workers are queried.
+	staticHosts map[string]struct{}
+
+	// staticKnownPieces maps the hosts that are known to store pieces of the
+	// chunk to the indices of those pieces. It is only set for a pcws that was
+	// initialized with a siafile. The workers of these hosts are resolved
+	// without launching HasSector jobs.
+	staticKnownPieces map[string][]uint64
+
+	// staticWorkerPools are the worker pools whose workers are queried. A
+	// renter that partitions its workers across multiple pools can resolve
+	// a chunk across all of them. The responses are merged into a single
+	// worker state. If no pools are set, the renter's worker pool is used.
+	staticWorkerPools []*workerPool
+
+	// staticCostCeiling is the maximum expected cost of the HasSector jobs
+	// that are launched during a single refresh of the worker state. Once the
+	// ceiling is reached no more workers are launched. A zero ceiling means
+	// there is no limit.
+	staticCostCeiling types.Currency
+
+	// staticResolutionBuffer is the number of usable workers that the worker
+	// state tries to resolve on top of the number of pieces of the chunk. It
+	// is derived from pcwsOverResolutionFactor.
+	staticResolutionBuffer int
+
 	// Utilities
 	staticCtx    context.Context
 	staticRenter *Renter
 	mu           sync.Mutex
 }
 
+// pcwsDebugSnapshot is a serializable snapshot of the full state of a
+// projectChunkWorkerSet. It is meant to help with diagnosing stuck downloads.
+type pcwsDebugSnapshot struct {
+	// Chunk metadata.
+	ID         string        `json:"id"`
+	InitMode   string        `json:"initmode"`
+	ChunkIndex uint64        `json:"chunkindex"`
+	MinPieces  int           `json:"minpieces"`
+	NumPieces  int           `json:"numpieces"`
+	PieceRoots []crypto.Hash `json:"pieceroots"`
+
+	// State of the pcws.
+	LaunchTime       time.Time     `json:"launchtime"`
+	ResetTime        time.Duration `json:"resettime"`
+	UpdateInProgress bool          `json:"updateinprogress"`
+
+	// State of the current worker state.
+	NumLaunched        int                         `json:"numlaunched"`
+	NumKnown           int                         `json:"numknown"`
+	NumResolved        int                         `json:"numresolved"`
+	NumUnresolved      int                         `json:"numunresolved"`
+	CostCeilingReached bool                        `json:"costceilingreached"`
+	ResolutionComplete bool                        `json:"resolutioncomplete"`
+	TimeToFirstResolve time.Duration               `json:"timetofirstresolve"`
+	ResolvedWorkers    []pcwsDebugResolvedWorker   `json:"resolvedworkers"`
+	UnresolvedWorkers  []pcwsDebugUnresolvedWorker `json:"unresolvedworkers"`
+}
+
+// pcwsDebugResolvedWorker is the debug representation of a resolved worker.
+type pcwsDebugResolvedWorker struct {
+	HostPubKey     string   `json:"hostpubkey"`
+	PieceIndices   []uint64 `json:"pieceindices"`
+	Err            string   `json:"err,omitempty"`
+	ReadGougingErr string   `json:"readgougingerr,omitempty"`
+}
+
+// pcwsDebugUnresolvedWorker is the debug representation of an unresolved
+// worker.
+type pcwsDebugUnresolvedWorker struct {
+	HostPubKey           string    `json:"hostpubkey"`
+	ExpectedResolvedTime time.Time `json:"expectedresolvedtime"`
+}
+
 // chunkFetcher is an interface that exposes a download function, the PCWS
 // implements this interface.
 type chunkFetcher interface {
@@ -184,6 +542,33 @@ func (pcws *projectChunkWorkerSet) Download(ctx context.Context, pricePerMS type
 	return pcws.managedDownload(ctx, pricePerMS, offset, length)
 }
 
+// pcwsJitteredResetTime returns pcwsWorkerStateResetTime with a random jitter
+// of up to pcwsWorkerStateResetJitter applied in either direction.
+func pcwsJitteredResetTime() time.Duration {
+	jitter := time.Duration(float64(pcwsWorkerStateResetTime) * pcwsWorkerStateResetJitter)
+	return pcwsWorkerStateResetTime - jitter + time.Duration(fastrand.Uint64n(uint64(2*jitter)+1))
+}
+
+// pcwsAdaptedResetTime returns the reset time that follows the current reset
+// time after a refresh that observed the given churn. Without churn the reset
+// time grows, with high churn it shrinks. The result is always within
+// pcwsWorkerStateResetTimeMin and pcwsWorkerStateResetTimeMax.
+func pcwsAdaptedResetTime(current time.Duration, churn float64) time.Duration {
+	next := current
+	if churn == 0 {
+		next = time.Duration(float64(current) * pcwsWorkerStateResetGrowth)
+	} else if churn >= pcwsWorkerStateHighChurn {
+		next = time.Duration(float64(current) * pcwsWorkerStateResetShrink)
+	}
+	if next < pcwsWorkerStateResetTimeMin {
+		return pcwsWorkerStateResetTimeMin
+	}
+	if next > pcwsWorkerStateResetTimeMax {
+		return pcwsWorkerStateResetTimeMax
+	}
+	return next
+}
+
 // checkPCWSGouging verifies the cost of grabbing the HasSector information from
 // a host is reasonble. The cost of completing the download is not checked.
 //
@@ -193,14 +578,30 @@ func (pcws *projectChunkWorkerSet) Download(ctx context.Context, pricePerMS type
 // frequently open large movies without watching the full movie), or
 // significantly more than one download per pcws (for multi-user nodes where
 // users most commonly are using the same file over and over).
-func checkPCWSGouging(pt modules.RPCPriceTable, allowance modules.Allowance, numWorkers int, numRoots int) error {
+//
+// The HasSector jobs look up every root of the chunk, but a download only
+// fetches MinPieces pieces. The number of downloads that are needed to reach
+// the expected download is therefore based on the amount of data that is
+// actually fetched from the hosts per download.
+//
+// Any returned error contains errPCWSGouging, which isRetriableDownloadErr
+// classifies as retriable.
+func checkPCWSGouging(pt modules.RPCPriceTable, allowance modules.Allowance, numWorkers int, ec modules.ErasureCoder) error {
+	return checkPCWSGougingCost(pt, allowance, numWorkers, ec, pcwsHasSectorJobCost(pt, ec.NumPieces()))
+}
+
+// checkPCWSGougingCost is a helper for checkPCWSGouging that accepts the
+// precomputed cost of the HasSector job, which allows the caller to cache it.
+func checkPCWSGougingCost(pt modules.RPCPriceTable, allowance modules.Allowance, numWorkers int, ec modules.ErasureCoder, costHasSectorJob types.Currency) error {
 	// Check whether the download bandwidth price is too high.
 	if !allowance.MaxDownloadBandwidthPrice.IsZero() && allowance.MaxDownloadBandwidthPrice.Cmp(pt.DownloadBandwidthCost) < 0 {
-		return fmt.Errorf("download bandwidth price of host is %v, which is above the maximum allowed by the allowance: %v - price gouging protection enabled", pt.DownloadBandwidthCost, allowance.MaxDownloadBandwidthPrice)
+		err := fmt.Errorf("download bandwidth price of host is %v, which is above the maximum allowed by the allowance: %v - price gouging protection enabled", pt.DownloadBandwidthCost, allowance.MaxDownloadBandwidthPrice)
+		return errors.Compose(err, errPCWSGouging)
 	}
 	// Check whether the upload bandwidth price is too high.
 	if !allowance.MaxUploadBandwidthPrice.IsZero() && allowance.MaxUploadBandwidthPrice.Cmp(pt.UploadBandwidthCost) < 0 {
-		return fmt.Errorf("upload bandwidth price of host is %v, which is above the maximum allowed by the allowance: %v - price gouging protection enabled", pt.UploadBandwidthCost, allowance.MaxUploadBandwidthPrice)
+		err := fmt.Errorf("upload bandwidth price of host is %v, which is above the maximum allowed by the allowance: %v - price gouging protection enabled", pt.UploadBandwidthCost, allowance.MaxUploadBandwidthPrice)
+		return errors.Compose(err, errPCWSGouging)
 	}
 	// If there is no allowance, price gouging checks have to be disabled,
 	// because there is no baseline for understanding what might count as price
@@ -209,20 +610,10 @@ func checkPCWSGouging(pt modules.RPCPriceTable, allowance modules.Allowance, num
 		return nil
 	}
 
-	// Calculate the cost of a has sector job.
-	pb := modules.NewProgramBuilder(&pt, 0)
-	for i := 0; i < numRoots; i++ {
-		pb.AddHasSectorInstruction(crypto.Hash{})
-	}
-	programCost, _, _ := pb.Cost(true)
-	ulbw, dlbw := hasSectorJobExpectedBandwidth(numRoots)
-	bandwidthCost := modules.MDMBandwidthCost(pt, ulbw, dlbw)
-	costHasSectorJob := programCost.Add(bandwidthCost)
-
 	// Determine based on the allowance the number of HasSector jobs that would
 	// need to be performed under normal conditions to reach the desired amount
 	// of total data.
-	requiredProjects := allowance.ExpectedDownload / modules.StreamDownloadSize
+	requiredProjects := allowance.ExpectedDownload / pcwsDownloadVolume(ec)
 	requiredHasSectorQueries := requiredProjects * uint64(numWorkers)
 
 	// Determine the total amount that we'd be willing to spend on all of those
@@ -233,11 +624,76 @@ func checkPCWSGouging(pt modules.RPCPriceTable, allowance modules.Allowance, num
 	// Check that we do not consider the host complicit in gouging.
 	if totalCost.Cmp(reducedAllowance) > 0 {
 		errStr := fmt.Sprintf("the cost of performing a HasSector job is too high - price gouging protection enabled")
-		return errors.New(errStr)
+		return errors.Compose(errors.New(errStr), errPCWSGouging)
 	}
 	return nil
 }
 
+// isRetriableDownloadErr returns whether a download that failed with the given
+// error might succeed when it is retried later. This is the case if the
+// workers were rejected due to price gouging, since the hosts might lower their
+// prices with the next price table update. All other errors are considered
+// permanent.
+func isRetriableDownloadErr(err error) bool {
+	return errors.Contains(err, ErrAllWorkersGouging) || errors.Contains(err, errPCWSGouging)
+}
+
+// pcwsDownloadVolume returns the amount of data that is fetched from the hosts
+// by a streaming download from a chunk with the given erasure coder. The
+// download fetches MinPieces pieces, each of which is rounded up to the
+// erasure coder's segment size.
+func pcwsDownloadVolume(ec modules.ErasureCoder) uint64 {
+	_, pieceLength := getPieceOffsetAndLen(ec, 0, modules.StreamDownloadSize)
+	return pieceLength * uint64(ec.MinPieces())
+}
+
+// pcwsHasSectorJobCost returns the expected cost of a HasSector job for the
+// given number of roots, including the bandwidth cost.
+func pcwsHasSectorJobCost(pt modules.RPCPriceTable, numRoots int) types.Currency {
+	pb := modules.NewProgramBuilder(&pt, 0)
+	for i := 0; i < numRoots; i++ {
+		pb.AddHasSectorInstruction(crypto.Hash{})
+	}
+	programCost, _, _ := pb.Cost(true)
+	ulbw, dlbw := hasSectorJobExpectedBandwidth(numRoots)
+	bandwidthCost := modules.MDMBandwidthCost(pt, ulbw, dlbw)
+	return programCost.Add(bandwidthCost)
+}
+
+// newPCWSWorkerStateForTesting creates a worker state in which the given
+// workers are already resolved and resolution is complete. This allows testing
+// the download algorithms in isolation, without running any HasSector jobs.
+// It may only be used in testing builds.
+func newPCWSWorkerStateForTesting(r *Renter, numPieces int, workers []*pcwsWorkerResponse) *pcwsWorkerState {
+	if build.Release != "testing" {
+		build.Critical("newPCWSWorkerStateForTesting may only be used in testing builds")
+	}
+	ws := &pcwsWorkerState{
+		unresolvedWorkers: make(map[string]*pcwsUnresolvedWorker),
+
+		numLaunched:            len(workers),
+		staticNumPieces:        numPieces,
+		staticResolutionTarget: numPieces,
+
+		resolutionComplete:   true,
+		staticResolutionDone: make(chan struct{}),
+		staticRenter:         r,
+	}
+	close(ws.staticResolutionDone)
+	for _, resp := range workers {
+		if resp.err != nil {
+			ws.numErrored++
+		} else {
+			ws.numSucceeded++
+		}
+		if resp.err == nil && len(resp.pieceIndices) > 0 {
+			ws.numUsable++
+		}
+		ws.resolvedWorkers = append(ws.resolvedWorkers, resp)
+	}
+	return ws
+}
+
 // closeUpdateChans will close all of the update chans and clear out the slice.
 // This will cause any threads waiting for more results from the unresolved
 // workers to unblock.
@@ -248,6 +704,59 @@ func (ws *pcwsWorkerState) closeUpdateChans() {
 		close(c)
 	}
 	ws.workerUpdateChans = nil
+
+	// The state chans are buffered, so sending the update will never block.
+	update := ws.resolveUpdate()
+	for _, c := range ws.workerUpdateStateChans {
+		c <- update
+		close(c)
+	}
+	ws.workerUpdateStateChans = nil
+}
+
+// resolveUpdate returns a resolveUpdate that reflects the current state of the
+// worker state.
+func (ws *pcwsWorkerState) resolveUpdate() resolveUpdate {
+	return resolveUpdate{
+		numResolved: len(ws.resolvedWorkers),
+		numTotal:    len(ws.resolvedWorkers) + len(ws.unresolvedWorkers),
+	}
+}
+
+// managedMarkResolutionDone marks the resolution of the worker state as
+// complete, closing the resolution done channel. It is safe to call this
+// method more than once.
+func (ws *pcwsWorkerState) managedMarkResolutionDone() {
+	ws.mu.Lock()
+	if ws.resolutionComplete || ws.staticResolutionDone == nil {
+		ws.mu.Unlock()
+		return
+	}
+	ws.resolutionComplete = true
+	close(ws.staticResolutionDone)
+	numErrored, numSucceeded := ws.numErrored, ws.numSucceeded
+	ws.mu.Unlock()
+
+	// Report the final counts of the refresh to the renter.
+	if ws.staticRenter != nil {
+		ws.staticRenter.managedUpdateHasSectorErrorAlert(numErrored, numSucceeded)
+	}
+}
+
+// managedUpdateHasSectorErrorAlert registers or unregisters the HasSector error
+// alert depending on the number of workers that failed and succeeded their
+// HasSector jobs during a refresh of a chunk worker set.
+func (r *Renter) managedUpdateHasSectorErrorAlert(numErrored, numSucceeded int) {
+	total := numErrored + numSucceeded
+	if total < pcwsHasSectorErrorAlertMinWorkers {
+		return
+	}
+	if float64(numErrored)/float64(total) <= pcwsHasSectorErrorAlertThreshold {
+		r.staticAlerter.UnregisterAlert(modules.AlertIDRenterHasSectorErrors)
+		return
+	}
+	cause := fmt.Sprintf("%v of %v workers failed their HasSector jobs during a chunk worker set refresh", numErrored, total)
+	r.staticAlerter.RegisterAlertWithTTL(modules.AlertIDRenterHasSectorErrors, AlertMSGHasSectorErrors, cause, modules.SeverityWarning, pcwsHasSectorErrorAlertTTL)
 }
 
 // registerForWorkerUpdate will create a channel and append it to the list of
@@ -266,6 +775,28 @@ func (ws *pcwsWorkerState) registerForWorkerUpdate() <-chan struct{} {
 	return c
 }
 
+// registerForWorkerUpdateWithState is similar to registerForWorkerUpdate, but
+// the returned channel will receive a resolveUpdate containing the updated
+// resolved and total worker counts before it is closed. The current state is
+// returned alongside the channel so the caller does not need to recount.
+//
+// Similar to registerForWorkerUpdate, a nil channel is returned if there are no
+// more unresolved workers.
+func (ws *pcwsWorkerState) registerForWorkerUpdateWithState() (<-chan resolveUpdate, resolveUpdate) {
+	current := ws.resolveUpdate()
+
+	// Return a nil channel if there are no more unresolved workers.
+	if len(ws.unresolvedWorkers) == 0 {
+		return nil, current
+	}
+
+	// Create the channel that will receive the update. It is buffered so that
+	// sending the update never blocks the thread handling the response.
+	c := make(chan resolveUpdate, 1)
+	ws.workerUpdateStateChans = append(ws.workerUpdateStateChans, c)
+	return c, current
+}
+
 // managedHandleResponse will handle a HasSector response from a worker,
 // updating the workerState accordingly.
 //
@@ -281,6 +812,9 @@ func (ws *pcwsWorkerState) managedHandleResponse(resp *jobHasSectorResponse) {
 	// HS response.
 	defer ws.closeUpdateChans()
 
+	// Record how long it took for the first worker to resolve.
+	ws.recordFirstResolve()
+
 	// Delete the worker from the set of unresolved workers.
 	w := resp.staticWorker
 	if w == nil {
@@ -291,12 +825,14 @@ func (ws *pcwsWorkerState) managedHandleResponse(resp *jobHasSectorResponse) {
 	// If the response contained an error, add this worker to the set of
 	// resolved workers as supporting no indices.
 	if resp.staticErr != nil {
+		ws.numErrored++
 		ws.resolvedWorkers = append(ws.resolvedWorkers, &pcwsWorkerResponse{
 			worker: w,
 			err:    resp.staticErr,
 		})
 		return
 	}
+	ws.numSucceeded++
 
 	// Create the list of pieces that the worker supports and add it to the
 	// worker set.
@@ -306,6 +842,9 @@ func (ws *pcwsWorkerState) managedHandleResponse(resp *jobHasSectorResponse) {
 			indices = append(indices, uint64(i))
 		}
 	}
+	if len(indices) > 0 {
+		ws.numUsable++
+	}
 	// Add this worker to the set of resolved workers (even if there are no
 	// indices that the worker can fetch).
 	ws.resolvedWorkers = append(ws.resolvedWorkers, &pcwsWorkerResponse{
@@ -314,6 +853,98 @@ func (ws *pcwsWorkerState) managedHandleResponse(resp *jobHasSectorResponse) {
 	})
 }
 
+// recordFirstResolve records the time from the start of finding the workers
+// until the first worker resolved. Only the first call has an effect. Worker
+// states that weren't created by threadedFindWorkers don't record it.
+func (ws *pcwsWorkerState) recordFirstResolve() {
+	if ws.findStartTime.IsZero() {
+		return
+	}
+	ws.firstResolveOnce.Do(func() {
+		ws.timeToFirstResolve = time.Since(ws.findStartTime)
+		ws.firstResolved = true
+	})
+}
+
+// managedTimeToFirstResolve returns the time it took from the start of finding
+// the workers of the worker state until the first worker resolved. The
+// boolean is false if no worker has resolved yet.
+func (ws *pcwsWorkerState) managedTimeToFirstResolve() (time.Duration, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.timeToFirstResolve, ws.firstResolved
+}
+
+// managedAllWorkersGouging returns whether launching the workers of the worker
+// state failed because all of them were rejected due to price gouging. Workers
+// that were resolved from known host-root pairs keep the worker state usable.
+func (ws *pcwsWorkerState) managedAllWorkersGouging() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.numKnown == 0 && ws.numGouging > 0 && ws.numGouging == ws.numAttempted
+}
+
+// managedMarkUnusableForReads marks the resolved workers in the given map as
+// unusable for reads, the map's values are the reasons why the workers can't
+// be used. The workers remain in the set of resolved workers.
+func (ws *pcwsWorkerState) managedMarkUnusableForReads(errs map[string]error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for _, resp := range ws.resolvedWorkers {
+		err, exists := errs[resp.worker.staticHostPubKeyStr]
+		if exists {
+			resp.readGougingErr = err
+		}
+	}
+}
+
+// managedResolutionBuffer returns the number of usable resolved workers beyond
+// the number of pieces of the chunk, and whether the resolution target, the
+// number of pieces plus the desired buffer, was reached.
+func (ws *pcwsWorkerState) managedResolutionBuffer() (int, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	buffer := ws.numUsable - ws.staticNumPieces
+	if buffer < 0 {
+		buffer = 0
+	}
+	return buffer, ws.numUsable >= ws.staticResolutionTarget
+}
+
+// managedResolvedPieceMap returns a snapshot of the resolved workers, mapping
+// every worker's host pubkey string to the piece indices it has. Workers that
+// errored or have none of the pieces map to an empty slice.
+func (ws *pcwsWorkerState) managedResolvedPieceMap() map[string][]uint64 {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	pieceMap := make(map[string][]uint64, len(ws.resolvedWorkers))
+	for _, resp := range ws.resolvedWorkers {
+		indices := make([]uint64, len(resp.pieceIndices))
+		copy(indices, resp.pieceIndices)
+		pieceMap[resp.worker.staticHostPubKeyStr] = indices
+	}
+	return pieceMap
+}
+
+// managedResolutionProgress returns the number of distinct hosts that
+// resolved successfully, that are still unresolved and whose HasSector job
+// failed. Hosts that resolved successfully but have none of the pieces count as
+// resolved.
+func (ws *pcwsWorkerState) managedResolutionProgress() (resolved, unresolved, errored int) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	resolvedHosts := make(map[string]struct{}, len(ws.resolvedWorkers))
+	erroredHosts := make(map[string]struct{})
+	for _, resp := range ws.resolvedWorkers {
+		if resp.err != nil && len(resp.pieceIndices) == 0 {
+			erroredHosts[resp.worker.staticHostPubKeyStr] = struct{}{}
+			continue
+		}
+		resolvedHosts[resp.worker.staticHostPubKeyStr] = struct{}{}
+	}
+	return len(resolvedHosts), len(ws.unresolvedWorkers), len(erroredHosts)
+}
+
 // managedLaunchWorker will launch a job to determine which sectors of a chunk
 // are available through that worker. The resulting unresolved worker is
 // returned so it can be added to the pending worker state.
@@ -321,10 +952,16 @@ func (pcws *projectChunkWorkerSet) managedLaunchWorker(ctx context.Context, w *w
 	// Check for gouging.
 	cache := w.staticCache()
 	pt := w.staticPriceTable().staticPriceTable
-	numWorkers := pcws.staticRenter.staticWorkerPool.callNumWorkers()
-	err := checkPCWSGouging(pt, cache.staticRenterAllowance, numWorkers, len(pcws.staticPieceRoots))
-	if err != nil {
-		pcws.staticRenter.log.Debugf("price gouging for chunk worker set detected in worker %v, err %v", w.staticHostPubKeyStr, err)
+	wp := pcws.staticWorkerPool(w)
+	numWorkers := pcws.staticNumWorkers()
+	cost := wp.staticHasSectorCostCache.managedCost(w.staticHostPubKeyStr, pt, len(pcws.staticPieceRoots), time.Now())
+	wp.staticHasSectorCostHistogram.managedAdd(cost, time.Now())
+	err := checkPCWSGougingCost(pt, cache.staticRenterAllowance, numWorkers, pcws.staticErasureCoder, cost)
+	if err != nil && !w.staticGougingExempt(modules.GougingCheckHasSector) {
+		pcws.staticDebugf("price gouging detected in worker %v, err %v", w.staticHostPubKeyStr, err)
+		if pcws.staticGougingCallback != nil {
+			pcws.staticGougingCallback(w.staticHostPubKey, err)
+		}
 		return err
 	}
 
@@ -340,11 +977,19 @@ func (pcws *projectChunkWorkerSet) managedLaunchWorker(ctx context.Context, w *w
 		wms.mu.Unlock()
 	}
 
+	// Wait for the worker pool's HasSector rate limiter. If it stays
+	// saturated, the worker is skipped.
+	err = wp.staticHasSectorLimiter.managedAcquire(ctx)
+	if err != nil {
+		pcws.staticDebugf("unable to launch has sector job for %v, err %v", w.staticHostPubKeyStr, err)
+		return err
+	}
+
 	// Create and launch the job.
 	jhs := w.newJobHasSector(ctx, responseChan, pcws.staticPieceRoots...)
-	expectedJobTime, err := w.staticJobHasSectorQueue.callAddWithEstimate(jhs)
+	expectedJobTime, err := pcws.managedAddHasSectorJob(ctx, w, jhs, ws)
 	if err != nil {
-		pcws.staticRenter.log.Debugf("unable to add has sector job to %v, err %v", w.staticHostPubKeyStr, err)
+		pcws.staticDebugf("unable to add has sector job to %v, err %v", w.staticHostPubKeyStr, err)
 		return err
 	}
 	expectedResolveTime := expectedJobTime.Add(coolDownPenalty)
@@ -365,16 +1010,190 @@ func (pcws *projectChunkWorkerSet) managedLaunchWorker(ctx context.Context, w *w
 	return nil
 }
 
+// managedAddHasSectorJob adds the HasSector job to the worker's queue. If the
+// queue is temporarily unavailable because it is on a cooldown, adding the job
+// is retried up to pcwsLaunchWorkerMaxRetries times with an exponential
+// backoff, as long as the worker state's retry budget isn't used up. Other
+// errors are returned right away.
+func (pcws *projectChunkWorkerSet) managedAddHasSectorJob(ctx context.Context, w *worker, jhs *jobHasSector, ws *pcwsWorkerState) (time.Time, error) {
+	backoff := pcwsLaunchWorkerRetryBackoff
+	for retry := 0; ; retry++ {
+		expectedJobTime, err := w.staticJobHasSectorQueue.callAddWithEstimate(jhs)
+		if err == nil || !errors.Contains(err, errJobQueueOnCooldown) || retry == pcwsLaunchWorkerMaxRetries {
+			return expectedJobTime, err
+		}
+
+		// Reserve the backoff from the retry budget.
+		ws.mu.Lock()
+		if ws.launchRetryTime+backoff > pcwsLaunchWorkerRetryBudget {
+			ws.mu.Unlock()
+			return expectedJobTime, err
+		}
+		ws.launchRetryTime += backoff
+		ws.mu.Unlock()
+
+		pcws.staticDebugf("retrying to add has sector job to %v in %v, err %v", w.staticHostPubKeyStr, backoff, err)
+		select {
+		case <-ctx.Done():
+			return time.Time{}, errors.Compose(err, ctx.Err())
+		case <-pcws.staticRenter.tg.StopChan():
+			return time.Time{}, errors.Compose(err, errors.New("renter is shutting down"))
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// pcwsPieceMapsEqual returns whether two resolved piece maps, as returned by
+// managedResolvedPieceMap, contain the same hosts with the same piece indices.
+// Hosts without any pieces are ignored since they can't be used for downloads.
+func pcwsPieceMapsEqual(a, b map[string][]uint64) bool {
+	// subset returns whether all hosts with pieces in x have the same pieces
+	// in y.
+	subset := func(x, y map[string][]uint64) bool {
+		for host, indices := range x {
+			if len(indices) == 0 {
+				continue
+			}
+			other := y[host]
+			if len(indices) != len(other) {
+				return false
+			}
+			for i := range indices {
+				if indices[i] != other[i] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return subset(a, b) && subset(b, a)
+}
+
+// pcwsPieceMapChurn returns the fraction of hosts with pieces in either of two
+// resolved piece maps that don't have the same piece indices in both. Hosts
+// without any pieces are ignored, like in pcwsPieceMapsEqual. Two maps without
+// any pieces have no churn.
+func pcwsPieceMapChurn(a, b map[string][]uint64) float64 {
+	hosts := make(map[string]struct{})
+	for _, m := range []map[string][]uint64{a, b} {
+		for host, indices := range m {
+			if len(indices) > 0 {
+				hosts[host] = struct{}{}
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		return 0
+	}
+	var changed int
+	for host := range hosts {
+		x, y := a[host], b[host]
+		if len(x) != len(y) {
+			changed++
+			continue
+		}
+		for i := range x {
+			if x[i] != y[i] {
+				changed++
+				break
+			}
+		}
+	}
+	return float64(changed) / float64(len(hosts))
+}
+
+// managedUpdateWorkerSetChanged compares the resolved workers of a refreshed
+// worker state to the ones of the worker state it replaced and signals a
+// change of the worker set. The observed churn is used to adapt the reset time
+// of the pcws. A nil previous worker state means that ws is the initial worker
+// state, which is not considered a change.
+func (pcws *projectChunkWorkerSet) managedUpdateWorkerSetChanged(previous, ws *pcwsWorkerState) {
+	// Worker states that couldn't launch any workers due to gouging never
+	// replace the previous worker state.
+	if previous == nil || ws.managedAllWorkersGouging() {
+		return
+	}
+	churn := pcwsPieceMapChurn(previous.managedResolvedPieceMap(), ws.managedResolvedPieceMap())
+	changed := churn > 0
+	pcws.mu.Lock()
+	defer pcws.mu.Unlock()
+	pcws.workerSetChanged = changed
+	pcws.workerStateResetTime = pcwsAdaptedResetTime(pcws.workerStateResetTime, churn)
+	if !changed {
+		return
+	}
+	if pcws.workerSetChangedChan != nil {
+		close(pcws.workerSetChangedChan)
+	}
+	pcws.workerSetChangedChan = make(chan struct{})
+	pcws.staticDebugf("worker set changed after refresh")
+}
+
+// managedResolveKnownWorkers adds the workers of the hosts that are known to
+// store pieces of the chunk to the resolved workers of the worker state,
+// without launching HasSector jobs. It returns the remaining workers and
+// whether they need to be queried, which is the case if there are known pieces
+// that none of the resolved workers can provide because their hosts aren't
+// workers. Without known pieces, all workers need to be queried.
+func (pcws *projectChunkWorkerSet) managedResolveKnownWorkers(ws *pcwsWorkerState, workers []*worker) ([]*worker, bool) {
+	if pcws.staticKnownPieces == nil {
+		return workers, true
+	}
+	var remaining []*worker
+	covered := make(map[uint64]struct{})
+	ws.mu.Lock()
+	for _, w := range workers {
+		indices, known := pcws.staticKnownPieces[w.staticHostPubKeyStr]
+		if !known {
+			remaining = append(remaining, w)
+			continue
+		}
+		ws.resolvedWorkers = append(ws.resolvedWorkers, &pcwsWorkerResponse{
+			worker:       w,
+			pieceIndices: append([]uint64{}, indices...),
+		})
+		ws.numKnown++
+		ws.numUsable++
+		ws.recordFirstResolve()
+		for _, pieceIndex := range indices {
+			covered[pieceIndex] = struct{}{}
+		}
+	}
+	ws.mu.Unlock()
+
+	for _, indices := range pcws.staticKnownPieces {
+		for _, pieceIndex := range indices {
+			if _, exists := covered[pieceIndex]; !exists {
+				return remaining, true
+			}
+		}
+	}
+	return remaining, false
+}
+
 // threadedFindWorkers will spin up a bunch of jobs to determine which workers
 // have what pieces for the pcws, and then update the input worker state with
 // the results.
-func (pcws *projectChunkWorkerSet) threadedFindWorkers(allWorkersLaunchedChan chan<- struct{}, ws *pcwsWorkerState) {
+func (pcws *projectChunkWorkerSet) threadedFindWorkers(allWorkersLaunchedChan chan<- struct{}, ws, previous *pcwsWorkerState) {
+	// Resolution is complete when this thread returns, either all workers
+	// have responded or the timeout fired. The resolved workers are then
+	// compared to the ones of the previous worker state.
+	defer func() {
+		ws.managedMarkResolutionDone()
+		pcws.managedUpdateWorkerSetChanged(previous, ws)
+	}()
+
 	err := pcws.staticRenter.tg.Add()
 	if err != nil {
 		return
 	}
 	defer pcws.staticRenter.tg.Done()
 
+	ws.mu.Lock()
+	ws.findStartTime = time.Now()
+	ws.mu.Unlock()
+
 	// Create a context for finding jobs which has a timeout for waiting on
 	// HasSector requests to return.
 	ctx, cancel := context.WithTimeout(pcws.staticCtx, pcwsHasSectorTimeout)
@@ -384,15 +1203,46 @@ func (pcws *projectChunkWorkerSet) threadedFindWorkers(allWorkersLaunchedChan ch
 	// receive the responses, and the channel needs to be buffered to be equal
 	// in size to the number of queries so that none of the workers sending
 	// reponses get blocked sending down the channel.
-	workers := ws.staticRenter.staticWorkerPool.callWorkers()
+	//
+	// The workers of hosts that are known to store pieces of the chunk are
+	// resolved right away. The other workers are only queried if there are
+	// known pieces that none of those workers can provide.
+	workers, lookup := pcws.managedResolveKnownWorkers(ws, pcws.staticWorkers())
+	if !lookup {
+		workers = nil
+	}
 	workersLaunched := 0
+	workersAttempted, workersGouging := 0, 0
+	ceilingReached := false
+	launchedCost := types.ZeroCurrency
 	responseChan := make(chan *jobHasSectorResponse, len(workers))
 	for _, w := range workers {
+		// Stop launching workers once the expected cost of their jobs would
+		// exceed the cost ceiling. Workers that were launched keep running.
+		var cost types.Currency
+		if !pcws.staticCostCeiling.IsZero() {
+			pt := w.staticPriceTable().staticPriceTable
+			cost = pcwsHasSectorJobCost(pt, len(pcws.staticPieceRoots))
+			if launchedCost.Add(cost).Cmp(pcws.staticCostCeiling) > 0 {
+				ceilingReached = true
+				break
+			}
+		}
+		workersAttempted++
 		err := pcws.managedLaunchWorker(ctx, w, responseChan, ws)
 		if err == nil {
 			workersLaunched++
+			launchedCost = launchedCost.Add(cost)
+		} else if errors.Contains(err, errPCWSGouging) {
+			workersGouging++
 		}
 	}
+	ws.mu.Lock()
+	ws.numLaunched = workersLaunched
+	ws.numAttempted = workersAttempted
+	ws.numGouging = workersGouging
+	ws.costCeilingReached = ceilingReached
+	ws.mu.Unlock()
 
 	// Signal that all of the workers have launched.
 	close(allWorkersLaunchedChan)
@@ -400,6 +1250,12 @@ func (pcws *projectChunkWorkerSet) threadedFindWorkers(allWorkersLaunchedChan ch
 	// Because there are timeouts on the HasSector programs, the longest that
 	// this loop should be active is a little bit longer than the full timeout
 	// for a single HasSector job.
+	//
+	// NOTE: The loop doesn't stop once enough workers have resolved to
+	// download the chunk, or once the resolution target is reached. Every
+	// extra usable worker adds to the buffer of workers that the downloads
+	// can fall back to if resolved workers fail.
+	targetReached := false
 	workersResponded := 0
 	for workersResponded < workersLaunched {
 		// Block until there is a worker response. Give up if the context times
@@ -423,7 +1279,117 @@ func (pcws *projectChunkWorkerSet) threadedFindWorkers(allWorkersLaunchedChan ch
 
 		// Parse the response.
 		ws.managedHandleResponse(resp)
+		if !targetReached {
+			var buffer int
+			buffer, targetReached = ws.managedResolutionBuffer()
+			if targetReached {
+				pcws.staticDebugf("resolution target reached after %v responses, buffer of %v extra workers", workersResponded, buffer)
+			}
+		}
+	}
+}
+
+// managedWorkersLaunched returns the number of workers that were launched for
+// the current worker state and whether launching more workers was stopped by
+// the cost ceiling of the pcws.
+func (pcws *projectChunkWorkerSet) managedWorkersLaunched() (int, bool) {
+	ws := pcws.managedWorkerState()
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.numLaunched, ws.costCeilingReached
+}
+
+// staticDebugf writes a debug line to the renter's log, prefixed with the
+// pcws' identifier, chunk index and number of roots.
+func (pcws *projectChunkWorkerSet) staticDebugf(format string, args ...interface{}) {
+	prefix := fmt.Sprintf("pcws %v (%v, chunk %v, %v roots): ", pcws.staticID, pcws.staticInitMode, pcws.staticChunkIndex, len(pcws.staticPieceRoots))
+	pcws.staticRenter.log.Debugf(prefix+format, args...)
+}
+
+// staticGetInitMode returns how the pcws was initialized. Callers can use it
+// to treat pcws with known host-root pairs differently from pcws that need to
+// find the hosts on the network.
+func (pcws *projectChunkWorkerSet) staticGetInitMode() pcwsInitMode {
+	return pcws.staticInitMode
+}
+
+// staticPools returns the worker pools whose workers the pcws queries.
+func (pcws *projectChunkWorkerSet) staticPools() []*workerPool {
+	if len(pcws.staticWorkerPools) == 0 {
+		return []*workerPool{pcws.staticRenter.staticWorkerPool}
+	}
+	return pcws.staticWorkerPools
+}
+
+// staticNumWorkers returns the total number of workers of the worker pools of
+// the pcws.
+func (pcws *projectChunkWorkerSet) staticNumWorkers() int {
+	numWorkers := 0
+	for _, wp := range pcws.staticPools() {
+		numWorkers += wp.callNumWorkers()
+	}
+	return numWorkers
+}
+
+// staticWorkerPool returns the worker pool of the pcws that the worker belongs
+// to. Its rate limiter and cost tracking are used for the worker's HasSector
+// jobs. Workers that don't belong to any of the pools use the renter's pool.
+func (pcws *projectChunkWorkerSet) staticWorkerPool(w *worker) *workerPool {
+	for _, wp := range pcws.staticPools() {
+		if pw, err := wp.callWorker(w.staticHostPubKey); err == nil && pw == w {
+			return wp
+		}
+	}
+	return pcws.staticRenter.staticWorkerPool
+}
+
+// staticWorkers returns the workers of the worker pools that the pcws is
+// allowed to query. If a host has a worker in multiple pools, only the worker
+// of the first pool is returned.
+func (pcws *projectChunkWorkerSet) staticWorkers() []*worker {
+	var workers []*worker
+	seen := make(map[string]struct{})
+	for _, wp := range pcws.staticPools() {
+		for _, w := range wp.callWorkers() {
+			if _, ok := seen[w.staticHostPubKeyStr]; ok {
+				continue
+			}
+			if _, ok := pcws.staticHosts[w.staticHostPubKeyStr]; pcws.staticHosts != nil && !ok {
+				continue
+			}
+			seen[w.staticHostPubKeyStr] = struct{}{}
+			workers = append(workers, w)
+		}
+	}
+	return workers
+}
+
+// managedResolutionDone returns a channel that is closed once the current
+// worker state is fully resolved, meaning that all workers have responded to
+// their HasSector query or the HasSector timeout fired. If resolution is
+// already complete, the returned channel is already closed.
+func (pcws *projectChunkWorkerSet) managedResolutionDone() <-chan struct{} {
+	return pcws.managedWorkerState().staticResolutionDone
+}
+
+// managedWorkerSetChanged returns whether the most recent refresh of the
+// worker state changed the set of resolved workers or their pieces.
+func (pcws *projectChunkWorkerSet) managedWorkerSetChanged() bool {
+	pcws.mu.Lock()
+	defer pcws.mu.Unlock()
+	return pcws.workerSetChanged
+}
+
+// managedWorkerSetChangedChan returns a channel that is closed the next time a
+// refresh of the worker state changes the set of resolved workers or their
+// pieces. Refreshes that resolve the same workers don't close it.
+func (pcws *projectChunkWorkerSet) managedWorkerSetChangedChan() <-chan struct{} {
+	pcws.mu.Lock()
+	defer pcws.mu.Unlock()
+	if pcws.workerSetChangedChan == nil {
+		pcws.workerSetChangedChan = make(chan struct{})
 	}
+	return pcws.workerSetChangedChan
 }
 
 // managedWorkerState returns a pointer to the current worker state object
@@ -433,13 +1399,78 @@ func (pcws *projectChunkWorkerSet) managedWorkerState() *pcwsWorkerState {
 	return pcws.workerState
 }
 
+// managedDebugSnapshot returns a snapshot of the full state of the pcws and its
+// current worker state. The snapshot doesn't share any memory with the pcws.
+func (pcws *projectChunkWorkerSet) managedDebugSnapshot() pcwsDebugSnapshot {
+	pcws.mu.Lock()
+	snapshot := pcwsDebugSnapshot{
+		ID:         pcws.staticID,
+		InitMode:   pcws.staticInitMode.String(),
+		ChunkIndex: pcws.staticChunkIndex,
+		MinPieces:  pcws.staticErasureCoder.MinPieces(),
+		NumPieces:  pcws.staticErasureCoder.NumPieces(),
+		PieceRoots: append([]crypto.Hash{}, pcws.staticPieceRoots...),
+
+		LaunchTime:       pcws.workerStateLaunchTime,
+		ResetTime:        pcws.workerStateResetTime,
+		UpdateInProgress: pcws.updateInProgress,
+	}
+	ws := pcws.workerState
+	pcws.mu.Unlock()
+	if ws == nil {
+		return snapshot
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	snapshot.NumLaunched = ws.numLaunched
+	snapshot.NumKnown = ws.numKnown
+	snapshot.NumResolved = len(ws.resolvedWorkers)
+	snapshot.NumUnresolved = len(ws.unresolvedWorkers)
+	snapshot.CostCeilingReached = ws.costCeilingReached
+	snapshot.ResolutionComplete = ws.resolutionComplete
+	snapshot.TimeToFirstResolve = ws.timeToFirstResolve
+	snapshot.ResolvedWorkers = make([]pcwsDebugResolvedWorker, 0, len(ws.resolvedWorkers))
+	for _, resp := range ws.resolvedWorkers {
+		rw := pcwsDebugResolvedWorker{
+			HostPubKey:   resp.worker.staticHostPubKeyStr,
+			PieceIndices: append([]uint64{}, resp.pieceIndices...),
+		}
+		if resp.err != nil {
+			rw.Err = resp.err.Error()
+		}
+		if resp.readGougingErr != nil {
+			rw.ReadGougingErr = resp.readGougingErr.Error()
+		}
+		snapshot.ResolvedWorkers = append(snapshot.ResolvedWorkers, rw)
+	}
+	snapshot.UnresolvedWorkers = make([]pcwsDebugUnresolvedWorker, 0, len(ws.unresolvedWorkers))
+	for hostKey, uw := range ws.unresolvedWorkers {
+		snapshot.UnresolvedWorkers = append(snapshot.UnresolvedWorkers, pcwsDebugUnresolvedWorker{
+			HostPubKey:           hostKey,
+			ExpectedResolvedTime: uw.staticExpectedResolvedTime,
+		})
+	}
+	sort.Slice(snapshot.UnresolvedWorkers, func(i, j int) bool {
+		return snapshot.UnresolvedWorkers[i].HostPubKey < snapshot.UnresolvedWorkers[j].HostPubKey
+	})
+	return snapshot
+}
+
+// refreshesExhausted returns whether the pcws launched as many worker states as
+// it is allowed to.
+func (pcws *projectChunkWorkerSet) refreshesExhausted() bool {
+	return pcws.staticMaxRefreshes > 0 && pcws.numRefreshes >= pcws.staticMaxRefreshes
+}
+
 // managedTryUpdateWorkerState will check whether the worker state needs to be
 // refreshed. If so, it will refresh the worker state.
 func (pcws *projectChunkWorkerSet) managedTryUpdateWorkerState() error {
-	// The worker state does not need to be refreshed if it is recent or if
-	// there is another refresh currently in progress.
+	// The worker state does not need to be refreshed if it is recent, if
+	// there is another refresh currently in progress or if the pcws isn't
+	// allowed to refresh anymore.
 	pcws.mu.Lock()
-	if pcws.updateInProgress || time.Since(pcws.workerStateLaunchTime) < pcwsWorkerStateResetTime {
+	if pcws.updateInProgress || time.Since(pcws.workerStateLaunchTime) < pcws.workerStateResetTime || pcws.refreshesExhausted() {
 		c := pcws.updateFinishedChan
 		pcws.mu.Unlock()
 		// If there is no update in progress, the channel will already be
@@ -451,6 +1482,8 @@ func (pcws *projectChunkWorkerSet) managedTryUpdateWorkerState() error {
 	// An update is needed. Set the flag that an update is in progress.
 	pcws.updateInProgress = true
 	pcws.updateFinishedChan = make(chan struct{})
+	pcws.numRefreshes++
+	previous := pcws.workerState
 	pcws.mu.Unlock()
 
 	// Create the new worker state and launch the thread that will create worker
@@ -463,21 +1496,28 @@ func (pcws *projectChunkWorkerSet) managedTryUpdateWorkerState() error {
 	// responses. Though there are a lot of concurrency patterns at play here,
 	// it was the cleanest thing I could come up with.
 	allWorkersLaunchedChan := make(chan struct{})
+	numPieces := pcws.staticErasureCoder.NumPieces()
 	ws := &pcwsWorkerState{
 		unresolvedWorkers: make(map[string]*pcwsUnresolvedWorker),
 
-		staticRenter: pcws.staticRenter,
+		staticNumPieces:        numPieces,
+		staticResolutionTarget: numPieces + pcws.staticResolutionBuffer,
+
+		staticResolutionDone: make(chan struct{}),
+		staticRenter:         pcws.staticRenter,
 	}
 
 	// Launch the thread to find the workers for this launch state.
 	err := pcws.staticRenter.tg.Launch(func() {
-		pcws.threadedFindWorkers(allWorkersLaunchedChan, ws)
+		pcws.threadedFindWorkers(allWorkersLaunchedChan, ws, previous)
 	})
 	if err != nil {
+		pcws.staticDebugf("unable to launch worker state refresh, err %v", err)
 		// If there is an error, need to reset the in-progress fields. This will
 		// result in the worker set continuing to use the previous worker state.
 		pcws.mu.Lock()
 		pcws.updateInProgress = false
+		pcws.numRefreshes--
 		pcws.mu.Unlock()
 		close(pcws.updateFinishedChan)
 		return errors.AddContext(err, "unable to launch worker set")
@@ -488,6 +1528,22 @@ func (pcws *projectChunkWorkerSet) managedTryUpdateWorkerState() error {
 	// update the pcws so that the workerState in the pcws is the newest worker
 	// state.
 	<-allWorkersLaunchedChan
+	ws.mu.Lock()
+	numLaunched, numKnown, ceilingReached := ws.numLaunched, ws.numKnown, ws.costCeilingReached
+	ws.mu.Unlock()
+
+	// If all workers were rejected due to price gouging, the worker state is
+	// useless. Fail fast instead of letting the downloads find out. The
+	// previous worker state is kept.
+	if ws.managedAllWorkersGouging() {
+		pcws.staticDebugf("unable to refresh worker state, all workers are price gouging")
+		pcws.mu.Lock()
+		pcws.updateInProgress = false
+		pcws.mu.Unlock()
+		close(pcws.updateFinishedChan)
+		return ErrAllWorkersGouging
+	}
+	pcws.staticDebugf("refreshed worker state, resolved %v known workers, launched %v workers, cost ceiling reached %v", numKnown, numLaunched, ceilingReached)
 	pcws.mu.Lock()
 	pcws.updateInProgress = false
 	pcws.workerState = ws
@@ -497,6 +1553,56 @@ func (pcws *projectChunkWorkerSet) managedTryUpdateWorkerState() error {
 	return nil
 }
 
+// staticNextPriceTableUpdate returns the earliest time at which one of the
+// workers of the pcws is scheduled to update its price table. If the pcws has
+// no workers, the zero time is returned.
+func (pcws *projectChunkWorkerSet) staticNextPriceTableUpdate() time.Time {
+	var next time.Time
+	for _, w := range pcws.staticWorkers() {
+		updateTime := w.staticPriceTable().staticUpdateTime
+		if next.IsZero() || updateTime.Before(next) {
+			next = updateTime
+		}
+	}
+	return next
+}
+
+// managedTryUpdateWorkerStateWithRetry will refresh the worker state like
+// managedTryUpdateWorkerState. If the refresh fails with a retriable error, it
+// will wait for the next price table update of the workers and try again, until
+// pcwsGougingRetryWindow has passed or the context is closed.
+func (pcws *projectChunkWorkerSet) managedTryUpdateWorkerStateWithRetry(ctx context.Context) error {
+	deadline := time.Now().Add(pcwsGougingRetryWindow)
+	for {
+		err := pcws.managedTryUpdateWorkerState()
+		if !isRetriableDownloadErr(err) {
+			return err
+		}
+		now := time.Now()
+		if !now.Before(deadline) {
+			return errors.AddContext(err, fmt.Sprintf("workers were still gouging after retrying for %v", pcwsGougingRetryWindow))
+		}
+
+		// Wait until shortly after the next price table update, but no longer
+		// than the retry window allows.
+		retryTime := pcws.staticNextPriceTableUpdate().Add(pcwsGougingRetryDelay)
+		if minRetryTime := now.Add(pcwsGougingRetryDelay); retryTime.Before(minRetryTime) {
+			retryTime = minRetryTime
+		}
+		if retryTime.After(deadline) {
+			retryTime = deadline
+		}
+		pcws.staticDebugf("all workers are price gouging, retrying at %v", retryTime)
+		select {
+		case <-time.After(retryTime.Sub(now)):
+		case <-ctx.Done():
+			return errors.Compose(err, ctx.Err())
+		case <-pcws.staticRenter.tg.StopChan():
+			return errors.Compose(err, errors.New("renter is shutting down"))
+		}
+	}
+}
+
 // managedDownload will download a range from a chunk. This call is
 // asynchronous. It will return as soon as the initial sector download requests
 // have been sent to the workers. This means that it will block until enough
@@ -519,6 +1625,10 @@ func (pcws *projectChunkWorkerSet) managedTryUpdateWorkerState() error {
 // expected to trim 100 milliseconds off of the download time, the download code
 // will select those workers only if the additional expense of using those
 // workers is less than 100 * pricePerMS.
+//
+// If all workers are rejected due to price gouging, the download waits for the
+// price tables of the workers to be updated and retries for up to
+// pcwsGougingRetryWindow before it fails with ErrAllWorkersGouging.
 func (pcws *projectChunkWorkerSet) managedDownload(ctx context.Context, pricePerMS types.Currency, offset, length uint64) (chan *downloadResponse, error) {
 	// Potentially force a timeout via a disrupt for testing.
 	if pcws.staticRenter.deps.Disrupt("timeoutProjectDownloadByRoot") {
@@ -541,8 +1651,16 @@ func (pcws *projectChunkWorkerSet) managedDownload(ctx context.Context, pricePer
 		return nil, errors.New("invalid request performed - this chunk has encryption overhead and therefore the full chunk must be downloaded")
 	}
 
+	// Serve the download from the prefetch cache if the range was
+	// prefetched.
+	if data, ok := pcws.staticRenter.staticPCWSPrefetchCache.managedGet(pcws.staticPieceRoots[0], offset, length, time.Now()); ok {
+		downloadResponseChan := make(chan *downloadResponse, 1)
+		downloadResponseChan <- &downloadResponse{data: data}
+		return downloadResponseChan, nil
+	}
+
 	// Refresh the pcws. This will only cause a refresh if one is necessary.
-	err := pcws.managedTryUpdateWorkerState()
+	err := pcws.managedTryUpdateWorkerStateWithRetry(ctx)
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to initiate download")
 	}
@@ -626,6 +1744,112 @@ func (pcws *projectChunkWorkerSet) managedDownload(ctx context.Context, pricePer
 // HasSector queries. Once opened, the projectChunkWorkerSet can be used to
 // initiate many downloads.
 func (r *Renter) newPCWSByRoots(ctx context.Context, roots []crypto.Hash, ec modules.ErasureCoder, masterKey crypto.CipherKey, chunkIndex uint64) (*projectChunkWorkerSet, error) {
+	return r.newPCWS(ctx, roots, ec, masterKey, chunkIndex, nil, types.ZeroCurrency, nil, nil, 0)
+}
+
+// newPCWSByRootsWithCostCeiling will create a worker set to download a chunk
+// given just the set of sector roots associated with the pieces, like
+// newPCWSByRoots, but stops launching HasSector jobs once their expected cost
+// exceeds the given ceiling. managedWorkersLaunched reports whether the
+// ceiling was reached.
+func (r *Renter) newPCWSByRootsWithCostCeiling(ctx context.Context, roots []crypto.Hash, ec modules.ErasureCoder, masterKey crypto.CipherKey, chunkIndex uint64, costCeiling types.Currency) (*projectChunkWorkerSet, error) {
+	return r.newPCWS(ctx, roots, ec, masterKey, chunkIndex, nil, costCeiling, nil, nil, 0)
+}
+
+// newPCWSByRootsWithMaxRefreshes will create a worker set to download a chunk
+// given just the set of sector roots associated with the pieces, like
+// newPCWSByRoots, but the worker state is launched at most maxRefreshes times
+// over the lifetime of the worker set, including the initial launch. Once the
+// cap is reached, the worker state is considered current forever. A cap of 1
+// never refreshes the worker state, which avoids paying for HasSector jobs
+// when a chunk is downloaded once and discarded. A cap of 0 means there is no
+// limit.
+func (r *Renter) newPCWSByRootsWithMaxRefreshes(ctx context.Context, roots []crypto.Hash, ec modules.ErasureCoder, masterKey crypto.CipherKey, chunkIndex uint64, maxRefreshes uint64) (*projectChunkWorkerSet, error) {
+	return r.newPCWS(ctx, roots, ec, masterKey, chunkIndex, nil, types.ZeroCurrency, nil, nil, maxRefreshes)
+}
+
+// newPCWSByRootsWithHosts will create a worker set to download a chunk given
+// just the set of sector roots associated with the pieces, like
+// newPCWSByRoots, but only the workers of the given hosts are queried. This is
+// useful for downloading a chunk from a specific set of hosts, e.g. to verify
+// the redundancy of a chunk. An error is returned if there are fewer workers
+// for the given hosts than are required to recover the chunk.
+func (r *Renter) newPCWSByRootsWithHosts(ctx context.Context, roots []crypto.Hash, ec modules.ErasureCoder, masterKey crypto.CipherKey, chunkIndex uint64, hosts []types.SiaPublicKey) (*projectChunkWorkerSet, error) {
+	allowed := make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		allowed[host.String()] = struct{}{}
+	}
+	return r.newPCWS(ctx, roots, ec, masterKey, chunkIndex, allowed, types.ZeroCurrency, nil, nil, 0)
+}
+
+// newPCWSByRootsWithWorkerPools will create a worker set to download a chunk
+// given just the set of sector roots associated with the pieces, like
+// newPCWSByRoots, but the HasSector jobs are launched across the workers of
+// all the given worker pools instead of just the renter's worker pool. This is
+// used by renters that partition their workers across multiple pools.
+func (r *Renter) newPCWSByRootsWithWorkerPools(ctx context.Context, roots []crypto.Hash, ec modules.ErasureCoder, masterKey crypto.CipherKey, chunkIndex uint64, pools []*workerPool) (*projectChunkWorkerSet, error) {
+	return r.newPCWS(ctx, roots, ec, masterKey, chunkIndex, nil, types.ZeroCurrency, pools, nil, 0)
+}
+
+// newPCWSBySiaFile will create a worker set to download a chunk of a siafile.
+// The siafile already knows which hosts store which pieces of the chunk, so
+// the workers of those hosts are resolved without launching HasSector jobs and
+// the worker state is usable right away. HasSector jobs are only launched to
+// find the pieces whose hosts aren't currently workers. Pieces that the
+// siafile doesn't have a host for can't be found, since their roots are
+// unknown.
+func (r *Renter) newPCWSBySiaFile(ctx context.Context, file *siafile.Snapshot, chunkIndex uint64) (*projectChunkWorkerSet, error) {
+	if chunkIndex >= file.NumChunks() {
+		return nil, fmt.Errorf("chunk index %v is out of bounds, the file has %v chunks", chunkIndex, file.NumChunks())
+	}
+	ec := file.ErasureCode()
+	pieces := file.Pieces(chunkIndex)
+	if len(pieces) != ec.NumPieces() {
+		return nil, fmt.Errorf("chunk has %v pieces, but erasure coder specifies %v pieces", len(pieces), ec.NumPieces())
+	}
+
+	// Collect the roots of the pieces and the pieces of every host.
+	roots := make([]crypto.Hash, len(pieces))
+	known := make(map[string][]uint64)
+	for pieceIndex, hostPieces := range pieces {
+		for _, piece := range hostPieces {
+			roots[pieceIndex] = piece.MerkleRoot
+			hostKey := piece.HostPubKey.String()
+			indices := known[hostKey]
+			if len(indices) > 0 && indices[len(indices)-1] == uint64(pieceIndex) {
+				continue
+			}
+			known[hostKey] = append(indices, uint64(pieceIndex))
+		}
+	}
+	return r.newPCWS(ctx, roots, ec, file.MasterKey(), chunkIndex, nil, types.ZeroCurrency, nil, known, 0)
+}
+
+// pcwsResolutionBuffer returns the number of extra usable workers a pcws tries
+// to resolve for a chunk with the given number of pieces.
+func pcwsResolutionBuffer(numPieces int, factor float64) int {
+	if factor <= 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(numPieces) * factor))
+}
+
+// pcwsID returns a short identifier for a pcws with the given piece roots.
+func pcwsID(roots []crypto.Hash) string {
+	if len(roots) == 0 {
+		return "unknown"
+	}
+	return roots[0].String()[:8]
+}
+
+// newPCWS will create a worker set to download a chunk given the set of
+// sector roots associated with the pieces. If hosts is not nil, only the
+// workers of the hosts in the set are queried. If costCeiling is not zero, no
+// more HasSector jobs are launched once their expected cost exceeds it. If no
+// worker pools are given, the workers of the renter's worker pool are queried.
+// If knownPieces is not nil, the pcws was initialized with a siafile and the
+// workers of the known hosts are resolved without HasSector jobs.
+func (r *Renter) newPCWS(ctx context.Context, roots []crypto.Hash, ec modules.ErasureCoder, masterKey crypto.CipherKey, chunkIndex uint64, hosts map[string]struct{}, costCeiling types.Currency, pools []*workerPool, knownPieces map[string][]uint64, maxRefreshes uint64) (*projectChunkWorkerSet, error) {
 	// Check that the number of roots provided is consistent with the erasure
 	// coder provided.
 	//
@@ -641,16 +1865,43 @@ func (r *Renter) newPCWSByRoots(ctx context.Context, roots []crypto.Hash, ec mod
 	}
 
 	// Create the worker set.
+	initMode := pcwsInitModeRoots
+	if knownPieces != nil {
+		initMode = pcwsInitModeSiaFile
+	}
+	resetTime := pcwsJitteredResetTime()
 	pcws := &projectChunkWorkerSet{
+		staticID:           pcwsID(roots),
+		staticInitMode:     initMode,
 		staticChunkIndex:   chunkIndex,
 		staticErasureCoder: ec,
 		staticMasterKey:    masterKey,
 		staticPieceRoots:   roots,
+		staticHosts:        hosts,
+		staticWorkerPools:  pools,
+		staticKnownPieces:  knownPieces,
+		staticCostCeiling:  costCeiling,
+		staticMaxRefreshes: maxRefreshes,
+
+		staticResolutionBuffer: pcwsResolutionBuffer(ec.NumPieces(), pcwsOverResolutionFactor),
+
+		staticGougingCallback: r.staticPCWSGougingCallback,
+
+		staticWorkerStateResetTime: resetTime,
+		workerStateResetTime:       resetTime,
 
 		staticCtx:    ctx,
 		staticRenter: r,
 	}
 
+	// If the worker set is restricted to a set of hosts, check that there are
+	// enough workers for those hosts to recover the chunk.
+	if hosts != nil {
+		if numWorkers := len(pcws.staticWorkers()); numWorkers < ec.MinPieces() {
+			return nil, errors.AddContext(errPCWSHostsInsufficient, fmt.Sprintf("found %v workers for %v hosts, but the chunk requires %v pieces", numWorkers, len(hosts), ec.MinPieces()))
+		}
+	}
+
 	// The worker state is blank, ensure that everything can get started.
 	err := pcws.managedTryUpdateWorkerState()
 	if err != nil {
@@ -2,9 +2,12 @@ package renter
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -14,6 +17,9 @@ import (
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+	"go.sia.tech/siad/persist"
 	"go.sia.tech/siad/types"
 )
 
@@ -38,7 +44,63 @@ func TestPCWS(t *testing.T) {
 	t.Run("basic", func(t *testing.T) { testBasic(t, wt) })
 	t.Run("multiple", func(t *testing.T) { testMultiple(t, wt) })
 	t.Run("newPCWSByRoots", testNewPCWSByRoots)
+	t.Run("newPCWSByRootsWithHosts", func(t *testing.T) { testNewPCWSByRootsWithHosts(t, wt) })
+	t.Run("newPCWSByRootsWithWorkerPools", func(t *testing.T) { testNewPCWSByRootsWithWorkerPools(t, wt) })
 	t.Run("gouging", testGouging)
+	t.Run("resolutionDone", func(t *testing.T) { testResolutionDone(t, wt) })
+	t.Run("costCeiling", func(t *testing.T) { testCostCeiling(t, wt) })
+	t.Run("newPCWSByRootsWithPrefetch", func(t *testing.T) { testNewPCWSByRootsWithPrefetch(t, wt) })
+	t.Run("newPCWSBySiaFile", func(t *testing.T) { testNewPCWSBySiaFile(t, wt) })
+}
+
+// testCostCeiling verifies that a pcws stops launching workers once the
+// expected cost of their HasSector jobs exceeds the pcws' cost ceiling.
+func testCostCeiling(t *testing.T, wt *workerTester) {
+	// create a passthrough EC and a passhtrough cipher key
+	ptec := modules.NewPassthroughErasureCoder()
+	ptck, err := crypto.NewSiaKey(crypto.TypePlain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := []crypto.Hash{{}}
+
+	// sanity check the HasSector job isn't free
+	pt := wt.worker.staticPriceTable().staticPriceTable
+	cost := pcwsHasSectorJobCost(pt, len(roots))
+	if cost.IsZero() {
+		t.Fatal("expected HasSector job to have a cost")
+	}
+
+	// a ceiling below the cost of a single job prevents all launches
+	pcws, err := wt.renter.newPCWSByRootsWithCostCeiling(context.Background(), roots, ptec, ptck, 0, cost.Sub64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	launched, reached := pcws.managedWorkersLaunched()
+	if launched != 0 || !reached {
+		t.Fatal("unexpected", launched, reached)
+	}
+
+	// a ceiling that covers the jobs of all workers doesn't limit the launches
+	numWorkers := len(pcws.staticWorkers())
+	pcws, err = wt.renter.newPCWSByRootsWithCostCeiling(context.Background(), roots, ptec, ptck, 0, cost.Mul64(uint64(10*numWorkers)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	launched, reached = pcws.managedWorkersLaunched()
+	if launched == 0 || reached {
+		t.Fatal("unexpected", launched, reached)
+	}
+
+	// no ceiling doesn't limit the launches either
+	pcws, err = wt.renter.newPCWSByRoots(context.Background(), roots, ptec, ptck, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	launched, reached = pcws.managedWorkersLaunched()
+	if launched == 0 || reached {
+		t.Fatal("unexpected", launched, reached)
+	}
 }
 
 // testBasic verifies the PCWS using a simple setup with a single host, looking
@@ -132,6 +194,56 @@ func testBasic(t *testing.T, wt *workerTester) {
 	if len(resolved) != 1 || len(resolved[0].pieceIndices) != 1 {
 		t.Fatal("unexpected")
 	}
+
+	// a single worker can't provide a buffer beyond the chunk's only piece
+	if buffer, reached := ws.managedResolutionBuffer(); buffer != 0 || reached {
+		t.Fatal("unexpected", buffer, reached)
+	}
+}
+
+// testResolutionDone verifies the channel returned by managedResolutionDone is
+// closed once all workers have resolved.
+func testResolutionDone(t *testing.T, wt *workerTester) {
+	// create PCWS
+	ptec := modules.NewPassthroughErasureCoder()
+	ptck, err := crypto.NewSiaKey(crypto.TypePlain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcws, err := wt.renter.newPCWSByRoots(context.Background(), []crypto.Hash{{}}, ptec, ptck, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for resolution to complete
+	select {
+	case <-pcws.managedResolutionDone():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+
+	// verify all workers resolved
+	ws := pcws.managedWorkerState()
+	ws.mu.Lock()
+	numResolved := len(ws.resolvedWorkers)
+	numUnresolved := len(ws.unresolvedWorkers)
+	ws.mu.Unlock()
+	if numResolved == 0 || numUnresolved != 0 {
+		t.Fatal("unexpected", numResolved, numUnresolved)
+	}
+	if _, resolved := ws.managedTimeToFirstResolve(); !resolved {
+		t.Fatal("time to first resolve wasn't recorded")
+	}
+
+	// the channel should be closed already when resolution is complete
+	select {
+	case <-pcws.managedResolutionDone():
+	default:
+		t.Fatal("expected closed channel")
+	}
+
+	// marking resolution as done again should not panic
+	ws.managedMarkResolutionDone()
 }
 
 // testMultiple verifies the PCWS for a multiple sector lookup on multiple
@@ -301,6 +413,11 @@ func testMultiple(t *testing.T, wt *workerTester) {
 func testNewPCWSByRoots(t *testing.T) {
 	r := new(Renter)
 	r.staticWorkerPool = new(workerPool)
+	logger, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.log = logger
 
 	// create random roots
 	var root1 crypto.Hash
@@ -317,10 +434,13 @@ func testNewPCWSByRoots(t *testing.T) {
 	}
 
 	// verify basic case
-	_, err = r.newPCWSByRoots(context.Background(), roots[:1], ptec, ptck, 0)
+	pcws, err := r.newPCWSByRoots(context.Background(), roots[:1], ptec, ptck, 0)
 	if err != nil {
 		t.Fatal("unexpected")
 	}
+	if pcws.staticGetInitMode() != pcwsInitModeRoots {
+		t.Fatal("unexpected init mode", pcws.staticGetInitMode())
+	}
 
 	// verify the case where we the amount of roots does not equal num pieces
 	// defined in the erasure coder
@@ -352,6 +472,268 @@ func testNewPCWSByRoots(t *testing.T) {
 	}
 }
 
+// testNewPCWSByRootsWithHosts verifies the 'newPCWSByRootsWithHosts'
+// constructor function only queries the workers of the given hosts.
+func testNewPCWSByRootsWithHosts(t *testing.T, wt *workerTester) {
+	// add a random sector to the host
+	sectorData := fastrand.Bytes(int(modules.SectorSize))
+	sectorRoot := crypto.MerkleRoot(sectorData)
+	err := wt.host.AddSector(sectorRoot, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := []crypto.Hash{sectorRoot}
+
+	// create a passthrough EC and a passhtrough cipher key
+	ptec := modules.NewPassthroughErasureCoder()
+	ptck, err := crypto.NewSiaKey(crypto.TypePlain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create a random host key that has no worker
+	_, pk := crypto.GenerateKeyPair()
+	unknown := types.Ed25519PublicKey(pk)
+
+	// verify hosts without workers can't cover the chunk
+	_, err = wt.renter.newPCWSByRootsWithHosts(context.Background(), roots, ptec, ptck, 0, []types.SiaPublicKey{unknown})
+	if !errors.Contains(err, errPCWSHostsInsufficient) {
+		t.Fatal("expected errPCWSHostsInsufficient, got", err)
+	}
+	_, err = wt.renter.newPCWSByRootsWithHosts(context.Background(), roots, ptec, ptck, 0, nil)
+	if !errors.Contains(err, errPCWSHostsInsufficient) {
+		t.Fatal("expected errPCWSHostsInsufficient, got", err)
+	}
+
+	// verify only the workers of the given hosts are queried
+	hosts := []types.SiaPublicKey{unknown, wt.staticHostPubKey}
+	pcws, err := wt.renter.newPCWSByRootsWithHosts(context.Background(), roots, ptec, ptck, 0, hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers := pcws.staticWorkers()
+	if len(workers) != 1 || workers[0].staticHostPubKeyStr != wt.staticHostPubKey.String() {
+		t.Fatal("unexpected workers", len(workers))
+	}
+
+	// wait until the worker resolved, it should have found the sector
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		ws := pcws.managedWorkerState()
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		if len(ws.unresolvedWorkers) != 0 {
+			return errors.New("worker not resolved yet")
+		}
+		if len(ws.resolvedWorkers) != 1 || len(ws.resolvedWorkers[0].pieceIndices) != 1 {
+			return errors.New("unexpected resolved workers")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testNewPCWSBySiaFile verifies that a pcws created from a siafile resolves the
+// workers of the hosts in the siafile without launching HasSector jobs.
+func testNewPCWSBySiaFile(t *testing.T, wt *workerTester) {
+	// create a siafile with a single chunk of two pieces
+	rsc, err := modules.NewRSCode(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSiaFile := func() *filesystem.FileNode {
+		sp := modules.RandomSiaPath()
+		err := wt.renter.staticFileSystem.NewSiaFile(sp, "", rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 100, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sf, err := wt.renter.staticFileSystem.OpenSiaFile(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sf
+	}
+	snapshot := func(sf *filesystem.FileNode) *siafile.Snapshot {
+		snap, err := sf.Snapshot(modules.RandomSiaPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snap
+	}
+
+	// verify the chunk index is checked
+	sf := newSiaFile()
+	defer func() {
+		if err := sf.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	_, err = wt.renter.newPCWSBySiaFile(context.Background(), snapshot(sf), 1)
+	if err == nil {
+		t.Fatal("expected an error for an out of bounds chunk")
+	}
+
+	// store both pieces on the worker's host
+	for pieceIndex := uint64(0); pieceIndex < 2; pieceIndex++ {
+		if err := sf.AddPiece(wt.staticHostPubKey, 0, pieceIndex, crypto.Hash{byte(pieceIndex)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pcws, err := wt.renter.newPCWSBySiaFile(context.Background(), snapshot(sf), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pcws.staticGetInitMode() != pcwsInitModeSiaFile {
+		t.Fatal("unexpected init mode", pcws.staticGetInitMode())
+	}
+
+	// no HasSector jobs are launched and resolution is done right away
+	select {
+	case <-pcws.managedResolutionDone():
+	default:
+		t.Fatal("expected resolution to be done")
+	}
+	ws := pcws.managedWorkerState()
+	ws.mu.Lock()
+	numLaunched, numKnown, resolved := ws.numLaunched, ws.numKnown, ws.resolvedWorkers
+	ws.mu.Unlock()
+	if numLaunched != 0 || numKnown != 1 {
+		t.Fatal("unexpected workers", numLaunched, numKnown)
+	}
+	if len(resolved) != 1 || resolved[0].worker != wt.worker || !reflect.DeepEqual(resolved[0].pieceIndices, []uint64{0, 1}) {
+		t.Fatal("unexpected resolved workers", resolved)
+	}
+	if pcws.managedDebugSnapshot().NumKnown != 1 {
+		t.Fatal("known workers should be part of the debug snapshot")
+	}
+
+	// store the second piece of another file on a host without a worker, the
+	// other workers are queried for it
+	_, pk := crypto.GenerateKeyPair()
+	unknown := types.Ed25519PublicKey(pk)
+	sf2 := newSiaFile()
+	defer func() {
+		if err := sf2.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := sf2.AddPiece(wt.staticHostPubKey, 0, 0, crypto.Hash{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sf2.AddPiece(unknown, 0, 1, crypto.Hash{2}); err != nil {
+		t.Fatal(err)
+	}
+	pcws, err = wt.renter.newPCWSBySiaFile(context.Background(), snapshot(sf2), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws = pcws.managedWorkerState()
+	ws.mu.Lock()
+	numAttempted, numKnown, known := ws.numAttempted, ws.numKnown, ws.resolvedWorkers[0]
+	ws.mu.Unlock()
+	if numKnown != 1 || known.worker != wt.worker || !reflect.DeepEqual(known.pieceIndices, []uint64{0}) {
+		t.Fatal("unexpected known worker", numKnown, known.pieceIndices)
+	}
+	if numWorkers := len(pcws.staticWorkers()); numAttempted != numWorkers-1 {
+		t.Fatalf("expected %v workers to be queried, got %v", numWorkers-1, numAttempted)
+	}
+}
+
+// testNewPCWSByRootsWithWorkerPools verifies that a pcws created with multiple
+// worker pools queries the workers of all of them.
+func testNewPCWSByRootsWithWorkerPools(t *testing.T, wt *workerTester) {
+	// add a random sector to the host
+	sectorData := fastrand.Bytes(int(modules.SectorSize))
+	sectorRoot := crypto.MerkleRoot(sectorData)
+	err := wt.host.AddSector(sectorRoot, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := []crypto.Hash{sectorRoot}
+
+	// create a passthrough EC and a passhtrough cipher key
+	ptec := modules.NewPassthroughErasureCoder()
+	ptck, err := crypto.NewSiaKey(crypto.TypePlain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create an empty pool and a pool that holds the worker
+	newPool := func(workers ...*worker) *workerPool {
+		wp := &workerPool{
+			workers: make(map[string]*worker),
+			renter:  wt.renter,
+
+			staticHasSectorLimiter:       newHasSectorRateLimiter(hasSectorRateLimit, hasSectorRateLimitBurst, hasSectorRateLimitMaxWait),
+			staticHasSectorCostHistogram: newHasSectorCostHistogram(),
+			staticHasSectorCostCache:     newHasSectorCostCache(),
+		}
+		for _, w := range workers {
+			wp.workers[w.staticHostPubKeyStr] = w
+		}
+		return wp
+	}
+	empty := newPool()
+	shard := newPool(wt.worker)
+
+	// verify the worker of the shard is queried through the shard's pool
+	pcws, err := wt.renter.newPCWSByRootsWithWorkerPools(context.Background(), roots, ptec, ptck, 0, []*workerPool{empty, shard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers := pcws.staticWorkers()
+	if len(workers) != 1 || workers[0] != wt.worker {
+		t.Fatal("unexpected workers", len(workers))
+	}
+	if numWorkers := pcws.staticNumWorkers(); numWorkers != 1 {
+		t.Fatal("unexpected number of workers", numWorkers)
+	}
+	if wp := pcws.staticWorkerPool(wt.worker); wp != shard {
+		t.Fatal("worker should belong to the shard")
+	}
+
+	// wait until the worker resolved, it should have found the sector
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		ws := pcws.managedWorkerState()
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		if len(ws.unresolvedWorkers) != 0 {
+			return errors.New("worker not resolved yet")
+		}
+		if len(ws.resolvedWorkers) != 1 || len(ws.resolvedWorkers[0].pieceIndices) != 1 {
+			return errors.New("unexpected resolved workers")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the cost of the job was tracked by the shard's pool
+	if buckets := shard.staticHasSectorCostHistogram.managedBuckets(time.Now()); len(buckets) == 0 {
+		t.Fatal("expected the shard to record the job's cost")
+	}
+	if buckets := empty.staticHasSectorCostHistogram.managedBuckets(time.Now()); len(buckets) != 0 {
+		t.Fatal("expected the empty pool not to record any costs")
+	}
+
+	// a host with workers in multiple pools is only queried once, but the
+	// workers of all pools count towards the gouging check
+	pcws, err = wt.renter.newPCWSByRootsWithWorkerPools(context.Background(), roots, ptec, ptck, 0, []*workerPool{wt.renter.staticWorkerPool, shard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	numRenterWorkers := wt.renter.staticWorkerPool.callNumWorkers()
+	if workers := pcws.staticWorkers(); len(workers) != numRenterWorkers {
+		t.Fatalf("expected %v workers, got %v", numRenterWorkers, len(workers))
+	}
+	expected := numRenterWorkers + 1
+	if numWorkers := pcws.staticNumWorkers(); numWorkers != expected {
+		t.Fatalf("expected %v workers, got %v", expected, numWorkers)
+	}
+}
+
 // testGouging checks that the gouging check is triggering at the right
 // times.
 func testGouging(t *testing.T) {
@@ -374,84 +756,116 @@ func testGouging(t *testing.T) {
 		ExpectedDownload: 1e9, // 1 GiB
 	}
 	numWorkers := 100
-	numRoots := 30
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// Check that the gouging passes for normal values.
-	err := checkPCWSGouging(pt, allowance, numWorkers, numRoots)
+	err = checkPCWSGouging(pt, allowance, numWorkers, ec)
 	if err != nil {
 		t.Error(err)
 	}
 
 	// Check with high init base cost.
 	pt.InitBaseCost = types.NewCurrency64(1e12)
-	err = checkPCWSGouging(pt, allowance, numWorkers, numRoots)
-	if err == nil {
-		t.Error("bad")
+	err = checkPCWSGouging(pt, allowance, numWorkers, ec)
+	if !errors.Contains(err, errPCWSGouging) {
+		t.Error("bad", err)
 	}
 	pt.InitBaseCost = types.NewCurrency64(1e3)
 
 	// Check with high upload bandwidth cost.
 	pt.UploadBandwidthCost = types.NewCurrency64(1e12)
-	err = checkPCWSGouging(pt, allowance, numWorkers, numRoots)
-	if err == nil {
-		t.Error("bad")
+	err = checkPCWSGouging(pt, allowance, numWorkers, ec)
+	if !errors.Contains(err, errPCWSGouging) {
+		t.Error("bad", err)
 	}
 	pt.UploadBandwidthCost = types.NewCurrency64(1e3)
 
 	// Check with high download bandwidth cost.
 	pt.DownloadBandwidthCost = types.NewCurrency64(1e12)
-	err = checkPCWSGouging(pt, allowance, numWorkers, numRoots)
-	if err == nil {
-		t.Error("bad")
+	err = checkPCWSGouging(pt, allowance, numWorkers, ec)
+	if !errors.Contains(err, errPCWSGouging) {
+		t.Error("bad", err)
 	}
 	pt.DownloadBandwidthCost = types.NewCurrency64(1e3)
 
 	// Check with high HasSector cost.
 	pt.HasSectorBaseCost = types.NewCurrency64(1e12)
-	err = checkPCWSGouging(pt, allowance, numWorkers, numRoots)
-	if err == nil {
-		t.Error("bad")
+	err = checkPCWSGouging(pt, allowance, numWorkers, ec)
+	if !errors.Contains(err, errPCWSGouging) {
+		t.Error("bad", err)
 	}
 	pt.HasSectorBaseCost = types.NewCurrency64(1e6)
 
 	// Check with low MaxDownloadBandwidthPrice.
 	allowance.MaxDownloadBandwidthPrice = types.NewCurrency64(100)
-	err = checkPCWSGouging(pt, allowance, numWorkers, numRoots)
-	if err == nil {
-		t.Error("bad")
+	err = checkPCWSGouging(pt, allowance, numWorkers, ec)
+	if !errors.Contains(err, errPCWSGouging) {
+		t.Error("bad", err)
 	}
 	allowance.MaxDownloadBandwidthPrice = types.NewCurrency64(2e3)
 
 	// Check with low MaxUploadBandwidthPrice.
 	allowance.MaxUploadBandwidthPrice = types.NewCurrency64(100)
-	err = checkPCWSGouging(pt, allowance, numWorkers, numRoots)
-	if err == nil {
-		t.Error("bad")
+	err = checkPCWSGouging(pt, allowance, numWorkers, ec)
+	if !errors.Contains(err, errPCWSGouging) {
+		t.Error("bad", err)
 	}
 	allowance.MaxUploadBandwidthPrice = types.NewCurrency64(2e3)
 
 	// Check with reduced funds.
 	allowance.Funds = types.NewCurrency64(1e15)
-	err = checkPCWSGouging(pt, allowance, numWorkers, numRoots)
-	if err == nil {
-		t.Error("bad")
+	err = checkPCWSGouging(pt, allowance, numWorkers, ec)
+	if !errors.Contains(err, errPCWSGouging) {
+		t.Error("bad", err)
 	}
 	allowance.Funds = types.NewCurrency64(1e18)
 
 	// Check with increased expected download.
 	allowance.ExpectedDownload = 1e12
-	err = checkPCWSGouging(pt, allowance, numWorkers, numRoots)
-	if err == nil {
-		t.Error("bad")
+	err = checkPCWSGouging(pt, allowance, numWorkers, ec)
+	if !errors.Contains(err, errPCWSGouging) {
+		t.Error("bad", err)
 	}
 	allowance.ExpectedDownload = 1e9
 
 	// Check that the base allowanace still passes. (ensures values have been
 	// reset correctly)
-	err = checkPCWSGouging(pt, allowance, numWorkers, numRoots)
+	err = checkPCWSGouging(pt, allowance, numWorkers, ec)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// A download only fetches the min pieces, rounded up to the segment size.
+	volume := pcwsDownloadVolume(ec)
+	if volume < modules.StreamDownloadSize || volume >= modules.StreamDownloadSize+uint64(ec.MinPieces())*crypto.SegmentSize {
+		t.Fatal("unexpected download volume", volume)
+	}
+
+	// Without partial encoding a download fetches full sectors, so fewer
+	// downloads and HasSector jobs are needed to reach a large expected
+	// download that would be considered gouging with partial encoding.
+	rs, err := modules.NewRSCode(10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if volume := pcwsDownloadVolume(rs); volume < modules.StreamDownloadSize || volume%(10*modules.SectorSize) != 0 {
+		t.Fatal("unexpected download volume", volume)
+	}
+	maxJobs := allowance.Funds.Div64(pcwsGougingFractionDenom).Div(pcwsHasSectorJobCost(pt, rs.NumPieces()).Mul64(uint64(numWorkers)))
+	maxProjects, err := maxJobs.Uint64()
 	if err != nil {
+		t.Fatal(err)
+	}
+	allowance.ExpectedDownload = maxProjects * pcwsDownloadVolume(rs)
+	if err := checkPCWSGouging(pt, allowance, numWorkers, rs); err != nil {
 		t.Error(err)
 	}
+	if err := checkPCWSGouging(pt, allowance, numWorkers, ec); err == nil {
+		t.Error("bad")
+	}
 }
 
 // TestProjectChunkWorsetSet_managedLaunchWorker probes the
@@ -469,6 +883,7 @@ func TestProjectChunkWorsetSet_managedLaunchWorker(t *testing.T) {
 	// create renter
 	renter := new(Renter)
 	renter.staticWorkerPool = new(workerPool)
+	renter.staticWorkerPool.staticHasSectorCostHistogram = newHasSectorCostHistogram()
 
 	// create PCWS
 	pcws := &projectChunkWorkerSet{
@@ -539,4 +954,1340 @@ func TestProjectChunkWorsetSet_managedLaunchWorker(t *testing.T) {
 		t.Log(expectedDurInS)
 		t.Fatal("unexpected")
 	}
+
+	// verify the cost of both jobs was recorded
+	buckets := renter.staticWorkerPool.staticHasSectorCostHistogram.managedBuckets(time.Now())
+	if len(buckets) != 1 || buckets[0].Count != 2 {
+		t.Fatal("unexpected histogram", buckets)
+	}
+}
+
+// TestProjectChunkWorkerSet_managedLaunchWorkerRetry verifies that launching a
+// worker is retried while its HasSector queue is on a short cooldown, and that
+// retries are bounded.
+func TestProjectChunkWorkerSet_managedLaunchWorkerRetry(t *testing.T) {
+	t.Parallel()
+
+	// create EC + key
+	ec := modules.NewPassthroughErasureCoder()
+	ck, err := crypto.NewSiaKey(crypto.TypePlain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create renter and PCWS
+	logger, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renter := new(Renter)
+	renter.log = logger
+	renter.staticWorkerPool = new(workerPool)
+	pcws := &projectChunkWorkerSet{
+		staticErasureCoder: ec,
+		staticMasterKey:    ck,
+		staticPieceRoots:   []crypto.Hash{},
+
+		staticCtx:    context.Background(),
+		staticRenter: renter,
+	}
+	newWorkerState := func() *pcwsWorkerState {
+		return &pcwsWorkerState{
+			unresolvedWorkers: make(map[string]*pcwsUnresolvedWorker),
+			staticRenter:      renter,
+		}
+	}
+
+	// mock the worker
+	w := new(worker)
+	w.newCache()
+	w.newPriceTable()
+	w.newMaintenanceState()
+	w.initJobHasSectorQueue()
+	w.staticHostPubKeyStr = "myworker"
+	w.staticPriceTable().staticExpiryTime = time.Now().Add(time.Hour)
+	responseChan := make(chan *jobHasSectorResponse, 1)
+	setCooldown := func(d time.Duration) {
+		jq := w.staticJobHasSectorQueue
+		jq.mu.Lock()
+		jq.cooldownUntil = time.Now().Add(d)
+		jq.mu.Unlock()
+	}
+
+	// a cooldown that ends before the first retry is retried successfully
+	ws := newWorkerState()
+	setCooldown(pcwsLaunchWorkerRetryBackoff / 2)
+	err = pcws.managedLaunchWorker(context.Background(), w, responseChan, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := ws.unresolvedWorkers["myworker"]; !exists {
+		t.Fatal("worker should have been launched")
+	}
+	if ws.launchRetryTime != pcwsLaunchWorkerRetryBackoff {
+		t.Fatal("unexpected retry time", ws.launchRetryTime)
+	}
+
+	// a long cooldown exhausts the retries
+	ws = newWorkerState()
+	setCooldown(time.Minute)
+	err = pcws.managedLaunchWorker(context.Background(), w, responseChan, ws)
+	if !errors.Contains(err, errJobQueueOnCooldown) {
+		t.Fatal("unexpected error", err)
+	}
+	if ws.launchRetryTime != 3*pcwsLaunchWorkerRetryBackoff {
+		t.Fatal("unexpected retry time", ws.launchRetryTime)
+	}
+
+	// no retries are attempted once the budget is used up
+	ws = newWorkerState()
+	ws.launchRetryTime = pcwsLaunchWorkerRetryBudget
+	err = pcws.managedLaunchWorker(context.Background(), w, responseChan, ws)
+	if !errors.Contains(err, errJobQueueOnCooldown) {
+		t.Fatal("unexpected error", err)
+	}
+	if ws.launchRetryTime != pcwsLaunchWorkerRetryBudget {
+		t.Fatal("unexpected retry time", ws.launchRetryTime)
+	}
+
+	// a killed queue isn't retried
+	ws = newWorkerState()
+	jq := w.staticJobHasSectorQueue
+	jq.mu.Lock()
+	jq.killed = true
+	jq.mu.Unlock()
+	err = pcws.managedLaunchWorker(context.Background(), w, responseChan, ws)
+	if err == nil || errors.Contains(err, errJobQueueOnCooldown) {
+		t.Fatal("unexpected error", err)
+	}
+	if ws.launchRetryTime != 0 {
+		t.Fatal("killed queue shouldn't be retried", ws.launchRetryTime)
+	}
+}
+
+// TestPCWSWorkerState_registerForWorkerUpdateWithState verifies the update
+// channels returned by 'registerForWorkerUpdateWithState' receive the updated
+// worker counts.
+func TestPCWSWorkerState_registerForWorkerUpdateWithState(t *testing.T) {
+	t.Parallel()
+
+	// create a worker state with two unresolved workers
+	w1 := &worker{staticHostPubKeyStr: "w1"}
+	w2 := &worker{staticHostPubKeyStr: "w2"}
+	ws := &pcwsWorkerState{
+		unresolvedWorkers: map[string]*pcwsUnresolvedWorker{
+			"w1": {staticWorker: w1},
+			"w2": {staticWorker: w2},
+		},
+		staticRenter: new(Renter),
+	}
+
+	// register for an update and verify the current state
+	ws.mu.Lock()
+	wu, current := ws.registerForWorkerUpdateWithState()
+	ws.mu.Unlock()
+	if current.numResolved != 0 || current.numTotal != 2 {
+		t.Fatal("unexpected", current)
+	}
+
+	// resolve the first worker and verify the update
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w1, staticAvailables: []bool{true}})
+	select {
+	case update := <-wu:
+		if update.numResolved != 1 || update.numTotal != 2 {
+			t.Fatal("unexpected", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	// verify the channel got closed
+	if _, ok := <-wu; ok {
+		t.Fatal("expected channel to be closed")
+	}
+
+	// register again, resolve the last worker and verify the update
+	ws.mu.Lock()
+	wu, current = ws.registerForWorkerUpdateWithState()
+	ws.mu.Unlock()
+	if current.numResolved != 1 || current.numTotal != 2 {
+		t.Fatal("unexpected", current)
+	}
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w2, staticErr: errors.New("failure")})
+	select {
+	case update := <-wu:
+		if update.numResolved != 2 || update.numTotal != 2 {
+			t.Fatal("unexpected", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	// verify a nil channel is returned now that all workers are resolved
+	ws.mu.Lock()
+	wu, current = ws.registerForWorkerUpdateWithState()
+	ws.mu.Unlock()
+	if wu != nil {
+		t.Fatal("expected nil channel")
+	}
+	if current.numResolved != 2 || current.numTotal != 2 {
+		t.Fatal("unexpected", current)
+	}
+}
+
+// TestProjectChunkWorkerSet_GougingExemption verifies that a host that is
+// exempt from gouging checks gets HasSector jobs even though it is overpriced,
+// while a host that isn't exempt does not.
+func TestProjectChunkWorkerSet_GougingExemption(t *testing.T) {
+	t.Parallel()
+
+	// create renter
+	renter := new(Renter)
+	renter.staticWorkerPool = new(workerPool)
+	logger, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renter.log = logger
+
+	// create PCWS
+	pcws := &projectChunkWorkerSet{
+		staticPieceRoots: []crypto.Hash{},
+		staticCtx:        context.Background(),
+		staticRenter:     renter,
+	}
+	ws := &pcwsWorkerState{
+		unresolvedWorkers: make(map[string]*pcwsUnresolvedWorker),
+		staticRenter:      pcws.staticRenter,
+	}
+
+	// create a helper to mock an overpriced worker
+	newOverpricedWorker := func(name string) *worker {
+		w := new(worker)
+		w.newCache()
+		w.newPriceTable()
+		w.newMaintenanceState()
+		w.initJobHasSectorQueue()
+		w.staticHostPubKeyStr = name
+		w.staticPriceTable().staticExpiryTime = time.Now().Add(time.Hour)
+		w.staticPriceTable().staticPriceTable.DownloadBandwidthCost = types.NewCurrency64(2)
+		w.staticCache().staticRenterAllowance.MaxDownloadBandwidthPrice = types.NewCurrency64(1)
+		return w
+	}
+
+	// exempt the first worker from the hassector check
+	exempt := newOverpricedWorker("exempt")
+	exempt.staticCache().staticGougingExemption = &modules.GougingExemption{
+		Checks: []modules.GougingCheck{modules.GougingCheckHasSector},
+	}
+	responseChan := make(chan *jobHasSectorResponse, 2)
+	err = pcws.managedLaunchWorker(context.Background(), exempt, responseChan, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := ws.unresolvedWorkers["exempt"]; !exists {
+		t.Fatal("expected exempt worker to be launched")
+	}
+
+	// exempt the second worker from a different check
+	notExempt := newOverpricedWorker("notexempt")
+	notExempt.staticCache().staticGougingExemption = &modules.GougingExemption{
+		Checks: []modules.GougingCheck{modules.GougingCheckUpload},
+	}
+	err = pcws.managedLaunchWorker(context.Background(), notExempt, responseChan, ws)
+	if err == nil {
+		t.Fatal("expected gouging error")
+	}
+	if _, exists := ws.unresolvedWorkers["notexempt"]; exists {
+		t.Fatal("expected non-exempt worker not to be launched")
+	}
+}
+
+// TestProjectChunkWorkerSet_GougingCallback verifies the gouging callback is
+// called when a worker is rejected for price gouging and that the worker pool
+// records the rejections.
+func TestProjectChunkWorkerSet_GougingCallback(t *testing.T) {
+	t.Parallel()
+
+	// create renter
+	renter := new(Renter)
+	renter.staticWorkerPool = new(workerPool)
+	logger, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renter.log = logger
+
+	// create PCWS with a callback that records the rejected hosts
+	var rejected []types.SiaPublicKey
+	var reasons []error
+	pcws := &projectChunkWorkerSet{
+		staticPieceRoots: []crypto.Hash{},
+		staticCtx:        context.Background(),
+		staticRenter:     renter,
+		staticGougingCallback: func(hostKey types.SiaPublicKey, reason error) {
+			rejected = append(rejected, hostKey)
+			reasons = append(reasons, reason)
+			renter.staticWorkerPool.callRecordPCWSGouging(hostKey, reason)
+		},
+	}
+	ws := &pcwsWorkerState{
+		unresolvedWorkers: make(map[string]*pcwsUnresolvedWorker),
+		staticRenter:      pcws.staticRenter,
+	}
+
+	// mock an overpriced worker
+	_, pk := crypto.GenerateKeyPair()
+	w := new(worker)
+	w.newCache()
+	w.newPriceTable()
+	w.newMaintenanceState()
+	w.initJobHasSectorQueue()
+	w.staticHostPubKey = types.Ed25519PublicKey(pk)
+	w.staticHostPubKeyStr = w.staticHostPubKey.String()
+	w.staticPriceTable().staticExpiryTime = time.Now().Add(time.Hour)
+	w.staticPriceTable().staticPriceTable.DownloadBandwidthCost = types.NewCurrency64(2)
+	w.staticCache().staticRenterAllowance.MaxDownloadBandwidthPrice = types.NewCurrency64(1)
+
+	// launch the worker twice, the callback should be called both times
+	responseChan := make(chan *jobHasSectorResponse, 2)
+	for i := 0; i < 2; i++ {
+		err = pcws.managedLaunchWorker(context.Background(), w, responseChan, ws)
+		if err == nil {
+			t.Fatal("expected gouging error")
+		}
+	}
+	if len(rejected) != 2 || !rejected[0].Equals(w.staticHostPubKey) || reasons[1] == nil {
+		t.Fatal("unexpected callbacks", rejected, reasons)
+	}
+
+	// the worker pool should have recorded the rejections
+	wp := renter.staticWorkerPool
+	wp.pcwsGougingMu.Lock()
+	record, exists := wp.pcwsGouging[w.staticHostPubKeyStr]
+	wp.pcwsGougingMu.Unlock()
+	if !exists || record.count != 2 || record.recentErr.Error() != reasons[1].Error() || record.recentErrTime.IsZero() {
+		t.Fatal("unexpected record", record)
+	}
+
+	// a worker that isn't gouging doesn't trigger the callback
+	w.staticPriceTable().staticPriceTable.DownloadBandwidthCost = types.NewCurrency64(1)
+	err = pcws.managedLaunchWorker(context.Background(), w, responseChan, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 2 {
+		t.Fatal("unexpected callback", len(rejected))
+	}
+}
+
+// TestProjectChunkWorkerSet_AllWorkersGouging verifies that refreshing the
+// worker state fails fast with ErrAllWorkersGouging if every worker is
+// rejected due to price gouging, and that the previous worker state is kept.
+func TestProjectChunkWorkerSet_AllWorkersGouging(t *testing.T) {
+	t.Parallel()
+
+	// create renter
+	renter := new(Renter)
+	renter.staticWorkerPool = &workerPool{workers: make(map[string]*worker)}
+	logger, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renter.log = logger
+
+	// add overpriced workers to the worker pool
+	newOverpricedWorker := func(name string) *worker {
+		w := new(worker)
+		w.newCache()
+		w.newPriceTable()
+		w.newMaintenanceState()
+		w.initJobHasSectorQueue()
+		w.staticHostPubKeyStr = name
+		w.staticPriceTable().staticExpiryTime = time.Now().Add(time.Hour)
+		w.staticPriceTable().staticPriceTable.DownloadBandwidthCost = types.NewCurrency64(2)
+		w.staticCache().staticRenterAllowance.MaxDownloadBandwidthPrice = types.NewCurrency64(1)
+		renter.staticWorkerPool.workers[name] = w
+		return w
+	}
+	newOverpricedWorker("w1")
+	w2 := newOverpricedWorker("w2")
+
+	// create PCWS
+	ec, err := modules.NewRSCode(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pcws := &projectChunkWorkerSet{
+		staticErasureCoder: ec,
+		staticPieceRoots:   []crypto.Hash{{}, {}},
+		staticCtx:          ctx,
+		staticRenter:       renter,
+	}
+
+	// the refresh fails and no worker state is set
+	err = pcws.managedTryUpdateWorkerState()
+	if !errors.Contains(err, ErrAllWorkersGouging) {
+		t.Fatal("expected ErrAllWorkersGouging, got", err)
+	}
+	pcws.mu.Lock()
+	ws, updateInProgress := pcws.workerState, pcws.updateInProgress
+	pcws.mu.Unlock()
+	if ws != nil || updateInProgress {
+		t.Fatal("worker state shouldn't have been updated", ws, updateInProgress)
+	}
+
+	// once a single worker isn't gouging anymore the refresh succeeds
+	w2.staticPriceTable().staticPriceTable.DownloadBandwidthCost = types.NewCurrency64(1)
+	err = pcws.managedTryUpdateWorkerState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws = pcws.managedWorkerState()
+	if ws == nil || ws.managedAllWorkersGouging() {
+		t.Fatal("worker state should have been updated")
+	}
+	if numLaunched, _ := pcws.managedWorkersLaunched(); numLaunched != 1 {
+		t.Fatal("expected 1 launched worker, got", numLaunched)
+	}
+}
+
+// TestIsRetriableDownloadErr is a unit test for isRetriableDownloadErr.
+func TestIsRetriableDownloadErr(t *testing.T) {
+	t.Parallel()
+
+	pt := newDefaultPriceTable()
+	allowance := modules.DefaultAllowance
+	allowance.MaxDownloadBandwidthPrice = pt.DownloadBandwidthCost.Div64(2)
+	gougingErr := checkPCWSGouging(pt, allowance, 10, modules.NewRSSubCodeDefault())
+
+	tests := []struct {
+		err       error
+		retriable bool
+	}{
+		{nil, false},
+		{errors.New("unknown"), false},
+		{ErrRootNotFound, false},
+		{errors.Compose(ErrProjectTimedOut, ErrRootNotFound), false},
+		{ErrAllWorkersGouging, true},
+		{errors.AddContext(ErrAllWorkersGouging, "unable to initiate download"), true},
+		{gougingErr, true},
+		{errors.AddContext(gougingErr, "price gouging detected"), true},
+	}
+	for i, test := range tests {
+		if retriable := isRetriableDownloadErr(test.err); retriable != test.retriable {
+			t.Errorf("%v: expected %v, got %v for %v", i, test.retriable, retriable, test.err)
+		}
+	}
+}
+
+// TestProjectChunkWorkerSet_GougingRetry verifies that a download waits for the
+// next price table update and re-resolves the workers if all of them are
+// rejected due to price gouging.
+func TestProjectChunkWorkerSet_GougingRetry(t *testing.T) {
+	t.Parallel()
+
+	// create renter
+	renter := new(Renter)
+	renter.staticWorkerPool = &workerPool{workers: make(map[string]*worker)}
+	logger, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renter.log = logger
+
+	// add an overpriced worker to the worker pool, its price table is
+	// updated shortly
+	updateTime := time.Now().Add(500 * time.Millisecond)
+	w := new(worker)
+	w.newCache()
+	w.newPriceTable()
+	w.newMaintenanceState()
+	w.initJobHasSectorQueue()
+	w.staticHostPubKeyStr = "w1"
+	w.staticPriceTable().staticExpiryTime = time.Now().Add(time.Hour)
+	w.staticPriceTable().staticUpdateTime = updateTime
+	w.staticPriceTable().staticPriceTable.DownloadBandwidthCost = types.NewCurrency64(2)
+	w.staticCache().staticRenterAllowance.MaxDownloadBandwidthPrice = types.NewCurrency64(1)
+	renter.staticWorkerPool.workers["w1"] = w
+
+	// create PCWS
+	ec, err := modules.NewRSCode(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pcws := &projectChunkWorkerSet{
+		staticErasureCoder: ec,
+		staticPieceRoots:   []crypto.Hash{{}, {}},
+		staticCtx:          ctx,
+		staticRenter:       renter,
+	}
+	if next := pcws.staticNextPriceTableUpdate(); !next.Equal(updateTime) {
+		t.Fatal("unexpected next price table update", next)
+	}
+
+	// the retry gives up once the context is closed
+	shortCtx, shortCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer shortCancel()
+	err = pcws.managedTryUpdateWorkerStateWithRetry(shortCtx)
+	if !errors.Contains(err, ErrAllWorkersGouging) || !errors.Contains(err, context.DeadlineExceeded) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// update the price table at the scheduled time, the retry should pick up
+	// the new prices
+	go func() {
+		time.Sleep(time.Until(updateTime))
+		pt := *w.staticPriceTable()
+		pt.staticPriceTable.DownloadBandwidthCost = types.NewCurrency64(1)
+		pt.staticUpdateTime = time.Now().Add(time.Hour)
+		w.staticSetPriceTable(&pt)
+	}()
+	err = pcws.managedTryUpdateWorkerStateWithRetry(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Now().Before(updateTime) {
+		t.Fatal("retry didn't wait for the price table update")
+	}
+	if numLaunched, _ := pcws.managedWorkersLaunched(); numLaunched != 1 {
+		t.Fatal("expected 1 launched worker, got", numLaunched)
+	}
+}
+
+// TestProjectChunkWorkerSet_managedResolveKnownWorkers is a unit test for
+// managedResolveKnownWorkers.
+func TestProjectChunkWorkerSet_managedResolveKnownWorkers(t *testing.T) {
+	t.Parallel()
+
+	w1 := &worker{staticHostPubKeyStr: "w1"}
+	w2 := &worker{staticHostPubKeyStr: "w2"}
+	w3 := &worker{staticHostPubKeyStr: "w3"}
+
+	// without known pieces all workers are queried
+	pcws := &projectChunkWorkerSet{}
+	ws := &pcwsWorkerState{}
+	workers, lookup := pcws.managedResolveKnownWorkers(ws, []*worker{w1, w2})
+	if !lookup || len(workers) != 2 || len(ws.resolvedWorkers) != 0 {
+		t.Fatal("unexpected", lookup, len(workers), len(ws.resolvedWorkers))
+	}
+
+	// the pieces of w3 can't be provided without a worker for it
+	pcws.staticKnownPieces = map[string][]uint64{
+		"w1": {0, 1},
+		"w3": {2},
+	}
+	workers, lookup = pcws.managedResolveKnownWorkers(ws, []*worker{w1, w2})
+	if !lookup || len(workers) != 1 || workers[0] != w2 {
+		t.Fatal("unexpected", lookup, workers)
+	}
+	if len(ws.resolvedWorkers) != 1 || ws.resolvedWorkers[0].worker != w1 || !reflect.DeepEqual(ws.resolvedWorkers[0].pieceIndices, []uint64{0, 1}) {
+		t.Fatal("unexpected resolved workers", ws.resolvedWorkers)
+	}
+	if ws.numKnown != 1 || ws.numUsable != 1 || ws.numSucceeded != 0 {
+		t.Fatal("unexpected counts", ws.numKnown, ws.numUsable, ws.numSucceeded)
+	}
+
+	// the resolved indices don't share memory with the known pieces
+	ws.resolvedWorkers[0].pieceIndices[0] = 5
+	if pcws.staticKnownPieces["w1"][0] != 0 {
+		t.Fatal("known pieces were modified")
+	}
+
+	// once all known pieces are covered no worker needs to be queried
+	ws = &pcwsWorkerState{}
+	workers, lookup = pcws.managedResolveKnownWorkers(ws, []*worker{w1, w2, w3})
+	if lookup || len(workers) != 1 || workers[0] != w2 {
+		t.Fatal("unexpected", lookup, workers)
+	}
+	if ws.numKnown != 2 || len(ws.resolvedWorkers) != 2 {
+		t.Fatal("unexpected counts", ws.numKnown, len(ws.resolvedWorkers))
+	}
+
+	// known workers keep a worker state usable if all queried workers are
+	// gouging
+	ws.numAttempted, ws.numGouging = 1, 1
+	if ws.managedAllWorkersGouging() {
+		t.Fatal("known workers should keep the worker state usable")
+	}
+}
+
+// TestPCWSJitteredResetTime verifies the reset time of a pcws is jittered
+// within the expected range.
+func TestPCWSJitteredResetTime(t *testing.T) {
+	t.Parallel()
+
+	jitter := time.Duration(float64(pcwsWorkerStateResetTime) * pcwsWorkerStateResetJitter)
+	minReset := pcwsWorkerStateResetTime - jitter
+	maxReset := pcwsWorkerStateResetTime + jitter
+
+	seen := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		resetTime := pcwsJitteredResetTime()
+		if resetTime < minReset || resetTime > maxReset {
+			t.Fatalf("reset time %v outside of range [%v, %v]", resetTime, minReset, maxReset)
+		}
+		seen[resetTime] = struct{}{}
+	}
+	if len(seen) == 1 {
+		t.Fatal("reset time is not jittered")
+	}
+
+	// verify a new pcws uses a jittered reset time
+	r := new(Renter)
+	r.staticWorkerPool = new(workerPool)
+	logger, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.log = logger
+	ptec := modules.NewPassthroughErasureCoder()
+	ptck, err := crypto.NewSiaKey(crypto.TypePlain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcws, err := r.newPCWSByRoots(context.Background(), []crypto.Hash{{}}, ptec, ptck, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pcws.staticWorkerStateResetTime < minReset || pcws.staticWorkerStateResetTime > maxReset {
+		t.Fatal("unexpected reset time", pcws.staticWorkerStateResetTime)
+	}
+	if pcws.workerStateResetTime != pcws.staticWorkerStateResetTime {
+		t.Fatal("adaptive reset time should start out at the jittered reset time", pcws.workerStateResetTime)
+	}
+}
+
+// TestPCWSInitMode is a unit test for the String method of pcwsInitMode.
+func TestPCWSInitMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		mode     pcwsInitMode
+		expected string
+	}{
+		{pcwsInitModeRoots, "roots"},
+		{pcwsInitModeSiaFile, "siafile"},
+		{pcwsInitMode(5), "unknown(5)"},
+	}
+	for _, test := range tests {
+		if s := test.mode.String(); s != test.expected {
+			t.Errorf("expected %v, got %v", test.expected, s)
+		}
+	}
+}
+
+// TestPCWSPieceMapChurn is a unit test for pcwsPieceMapChurn.
+func TestPCWSPieceMapChurn(t *testing.T) {
+	t.Parallel()
+
+	base := map[string][]uint64{"w1": {0, 1}, "w2": {2}, "w3": {}, "w4": {3}}
+	tests := []struct {
+		other map[string][]uint64
+		churn float64
+	}{
+		{map[string][]uint64{"w1": {0, 1}, "w2": {2}, "w4": {3}}, 0},
+		{map[string][]uint64{"w1": {0, 1}, "w2": {2}, "w4": {3}, "w5": {}}, 0},
+		{map[string][]uint64{"w1": {0, 1}, "w2": {2}, "w4": {4}}, 1.0 / 3},
+		{map[string][]uint64{"w1": {0}, "w2": {2}, "w4": {3}}, 1.0 / 3},
+		{map[string][]uint64{"w1": {0, 1}, "w2": {2}, "w4": {3}, "w5": {4}}, 0.25},
+		{map[string][]uint64{"w1": {0, 1}}, 2.0 / 3},
+		{nil, 1},
+	}
+	for i, test := range tests {
+		if churn := pcwsPieceMapChurn(base, test.other); math.Abs(churn-test.churn) > 1e-9 {
+			t.Errorf("%v: expected churn %v, got %v", i, test.churn, churn)
+		}
+		if churn := pcwsPieceMapChurn(test.other, base); math.Abs(churn-test.churn) > 1e-9 {
+			t.Errorf("%v: churn isn't symmetric, expected %v, got %v", i, test.churn, churn)
+		}
+		if (pcwsPieceMapChurn(base, test.other) == 0) != pcwsPieceMapsEqual(base, test.other) {
+			t.Errorf("%v: churn doesn't match pcwsPieceMapsEqual", i)
+		}
+	}
+	if churn := pcwsPieceMapChurn(nil, map[string][]uint64{"w1": {}}); churn != 0 {
+		t.Fatal("maps without pieces shouldn't have churn", churn)
+	}
+}
+
+// TestPCWSAdaptedResetTime is a unit test for pcwsAdaptedResetTime.
+func TestPCWSAdaptedResetTime(t *testing.T) {
+	t.Parallel()
+
+	mid := (pcwsWorkerStateResetTimeMin + pcwsWorkerStateResetTimeMax) / 2
+	tests := []struct {
+		current  time.Duration
+		churn    float64
+		expected time.Duration
+	}{
+		// no churn grows the reset time up to the max
+		{pcwsWorkerStateResetTimeMin, 0, time.Duration(float64(pcwsWorkerStateResetTimeMin) * pcwsWorkerStateResetGrowth)},
+		{mid, 0, time.Duration(float64(mid) * pcwsWorkerStateResetGrowth)},
+		{pcwsWorkerStateResetTimeMax - pcwsWorkerStateResetTimeMin, 0, pcwsWorkerStateResetTimeMax},
+		{pcwsWorkerStateResetTimeMax, 0, pcwsWorkerStateResetTimeMax},
+
+		// low churn keeps the reset time
+		{mid, pcwsWorkerStateHighChurn / 2, mid},
+
+		// high churn shrinks the reset time down to the min
+		{mid, pcwsWorkerStateHighChurn, time.Duration(float64(mid) * pcwsWorkerStateResetShrink)},
+		{mid, 1, time.Duration(float64(mid) * pcwsWorkerStateResetShrink)},
+		{pcwsWorkerStateResetTimeMin, 1, pcwsWorkerStateResetTimeMin},
+
+		// out of bounds reset times are clamped
+		{0, pcwsWorkerStateHighChurn / 2, pcwsWorkerStateResetTimeMin},
+		{2 * pcwsWorkerStateResetTimeMax, pcwsWorkerStateHighChurn / 2, pcwsWorkerStateResetTimeMax},
+	}
+	for i, test := range tests {
+		if next := pcwsAdaptedResetTime(test.current, test.churn); next != test.expected {
+			t.Errorf("%v: expected %v, got %v", i, test.expected, next)
+		}
+	}
+}
+
+// TestPCWSWorkerState_HasSectorErrorAlert verifies the worker state counts the
+// failed and successful HasSector responses and that the renter registers an
+// alert if too many of them failed during a refresh.
+func TestPCWSWorkerState_HasSectorErrorAlert(t *testing.T) {
+	t.Parallel()
+
+	r := &Renter{staticAlerter: modules.NewAlerter("renter")}
+	numWarnings := func() int {
+		_, _, warn, _ := r.staticAlerter.Alerts()
+		return len(warn)
+	}
+
+	// refresh creates a worker state, resolves 'numErr' workers with an error
+	// and 'numOK' workers successfully and marks the resolution as done
+	refresh := func(numErr, numOK int) *pcwsWorkerState {
+		ws := &pcwsWorkerState{
+			unresolvedWorkers:    make(map[string]*pcwsUnresolvedWorker),
+			staticResolutionDone: make(chan struct{}),
+			staticRenter:         r,
+		}
+		for i := 0; i < numErr+numOK; i++ {
+			w := &worker{staticHostPubKeyStr: fmt.Sprint(i)}
+			ws.unresolvedWorkers[w.staticHostPubKeyStr] = &pcwsUnresolvedWorker{staticWorker: w}
+			resp := &jobHasSectorResponse{staticWorker: w, staticAvailables: []bool{true}}
+			if i < numErr {
+				resp = &jobHasSectorResponse{staticWorker: w, staticErr: errors.New("failure")}
+			}
+			ws.managedHandleResponse(resp)
+		}
+		ws.managedMarkResolutionDone()
+		return ws
+	}
+
+	// a healthy refresh doesn't register an alert
+	ws := refresh(1, 4)
+	if ws.numErrored != 1 || ws.numSucceeded != 4 {
+		t.Fatal("unexpected counts", ws.numErrored, ws.numSucceeded)
+	}
+	if numWarnings() != 0 {
+		t.Fatal("unexpected alert")
+	}
+
+	// a refresh where most workers fail registers the alert
+	ws = refresh(4, 1)
+	if ws.numErrored != 4 || ws.numSucceeded != 1 {
+		t.Fatal("unexpected counts", ws.numErrored, ws.numSucceeded)
+	}
+	if numWarnings() != 1 {
+		t.Fatal("expected alert")
+	}
+
+	// a refresh with too few workers doesn't change the alert
+	refresh(0, 1)
+	if numWarnings() != 1 {
+		t.Fatal("alert shouldn't have been cleared")
+	}
+
+	// the alert is cleared on a subsequent healthy refresh
+	refresh(2, 3)
+	if numWarnings() != 0 {
+		t.Fatal("alert should have been cleared")
+	}
+}
+
+// TestPCWSID is a unit test for pcwsID.
+func TestPCWSID(t *testing.T) {
+	t.Parallel()
+
+	if id := pcwsID(nil); id != "unknown" {
+		t.Fatal("unexpected id", id)
+	}
+	var root crypto.Hash
+	fastrand.Read(root[:])
+	id := pcwsID([]crypto.Hash{root, {}})
+	if len(id) != 8 || !strings.HasPrefix(root.String(), id) {
+		t.Fatal("unexpected id", id, root)
+	}
+}
+
+// TestPCWSWorkerState_managedResolvedPieceMap is a unit test for
+// managedResolvedPieceMap.
+func TestPCWSWorkerState_managedResolvedPieceMap(t *testing.T) {
+	t.Parallel()
+
+	w1 := &worker{staticHostPubKeyStr: "w1"}
+	w2 := &worker{staticHostPubKeyStr: "w2"}
+	w3 := &worker{staticHostPubKeyStr: "w3"}
+	w4 := &worker{staticHostPubKeyStr: "w4"}
+	ws := &pcwsWorkerState{
+		unresolvedWorkers: map[string]*pcwsUnresolvedWorker{
+			"w1": {staticWorker: w1},
+			"w2": {staticWorker: w2},
+			"w3": {staticWorker: w3},
+			"w4": {staticWorker: w4},
+		},
+		staticRenter: new(Renter),
+	}
+
+	// no resolved workers yet
+	if pieceMap := ws.managedResolvedPieceMap(); len(pieceMap) != 0 {
+		t.Fatal("unexpected", pieceMap)
+	}
+
+	// resolve all workers but the last one
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w1, staticAvailables: []bool{true, false, true}})
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w2, staticAvailables: []bool{false, false, false}})
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w3, staticErr: errors.New("failure")})
+
+	pieceMap := ws.managedResolvedPieceMap()
+	expected := map[string][]uint64{
+		"w1": {0, 2},
+		"w2": {},
+		"w3": {},
+	}
+	if !reflect.DeepEqual(pieceMap, expected) {
+		t.Fatal("unexpected", pieceMap)
+	}
+
+	// the map is a snapshot, modifying it doesn't affect the worker state
+	pieceMap["w1"][0] = 1
+	if indices := ws.managedResolvedPieceMap()["w1"]; !reflect.DeepEqual(indices, []uint64{0, 2}) {
+		t.Fatal("unexpected", indices)
+	}
+}
+
+// TestPCWSWorkerState_managedResolutionProgress is a unit test for
+// managedResolutionProgress.
+func TestPCWSWorkerState_managedResolutionProgress(t *testing.T) {
+	t.Parallel()
+
+	w1 := &worker{staticHostPubKeyStr: "w1"}
+	w2 := &worker{staticHostPubKeyStr: "w2"}
+	w3 := &worker{staticHostPubKeyStr: "w3"}
+	w4 := &worker{staticHostPubKeyStr: "w4"}
+	ws := &pcwsWorkerState{
+		unresolvedWorkers: map[string]*pcwsUnresolvedWorker{
+			"w1": {staticWorker: w1},
+			"w2": {staticWorker: w2},
+			"w3": {staticWorker: w3},
+			"w4": {staticWorker: w4},
+		},
+		staticRenter: new(Renter),
+	}
+	assertProgress := func(resolved, unresolved, errored int) {
+		t.Helper()
+		r, u, e := ws.managedResolutionProgress()
+		if r != resolved || u != unresolved || e != errored {
+			t.Fatal("unexpected progress", r, u, e)
+		}
+	}
+	assertProgress(0, 4, 0)
+
+	// workers without pieces count as resolved, failed workers as errored
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w1, staticAvailables: []bool{true, false}})
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w2, staticAvailables: []bool{false, false}})
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w3, staticErr: errors.New("failure")})
+	assertProgress(2, 1, 1)
+
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w4, staticAvailables: []bool{false, true}})
+	assertProgress(3, 0, 1)
+}
+
+// TestPCWSWorkerState_managedTimeToFirstResolve verifies that the time to the
+// first resolved worker is recorded once, by the first response.
+func TestPCWSWorkerState_managedTimeToFirstResolve(t *testing.T) {
+	t.Parallel()
+
+	w1 := &worker{staticHostPubKeyStr: "w1"}
+	w2 := &worker{staticHostPubKeyStr: "w2"}
+	newWorkerState := func(start time.Time) *pcwsWorkerState {
+		return &pcwsWorkerState{
+			unresolvedWorkers: map[string]*pcwsUnresolvedWorker{
+				"w1": {staticWorker: w1},
+				"w2": {staticWorker: w2},
+			},
+			findStartTime: start,
+			staticRenter:  new(Renter),
+		}
+	}
+
+	// nothing is recorded before the first response
+	start := time.Now().Add(-time.Second)
+	ws := newWorkerState(start)
+	if _, resolved := ws.managedTimeToFirstResolve(); resolved {
+		t.Fatal("no worker resolved yet")
+	}
+
+	// the first response records the time, even if it failed
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w1, staticErr: errors.New("failure")})
+	first, resolved := ws.managedTimeToFirstResolve()
+	if !resolved || first < time.Second || first > time.Since(start) {
+		t.Fatal("unexpected time to first resolve", first, resolved)
+	}
+
+	// later responses don't change it
+	time.Sleep(10 * time.Millisecond)
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w2, staticAvailables: []bool{true}})
+	if d, _ := ws.managedTimeToFirstResolve(); d != first {
+		t.Fatal("time to first resolve changed", first, d)
+	}
+	if snapshot := (&projectChunkWorkerSet{workerState: ws, staticErasureCoder: modules.NewPassthroughErasureCoder()}).managedDebugSnapshot(); snapshot.TimeToFirstResolve != first {
+		t.Fatal("unexpected time to first resolve in snapshot", snapshot.TimeToFirstResolve)
+	}
+
+	// known workers resolve right away
+	ws = newWorkerState(start)
+	pcws := &projectChunkWorkerSet{staticKnownPieces: map[string][]uint64{"w1": {0}}}
+	pcws.managedResolveKnownWorkers(ws, []*worker{w1, w2})
+	if d, resolved := ws.managedTimeToFirstResolve(); !resolved || d < time.Second {
+		t.Fatal("unexpected time to first resolve", d, resolved)
+	}
+
+	// worker states that weren't created by threadedFindWorkers don't record
+	// it
+	ws = newWorkerState(time.Time{})
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w1, staticAvailables: []bool{true}})
+	if _, resolved := ws.managedTimeToFirstResolve(); resolved {
+		t.Fatal("time to first resolve shouldn't be recorded without a start time")
+	}
+}
+
+// TestNewPCWSWorkerStateForTesting verifies that the testing constructor
+// creates a fully resolved worker state from the given workers.
+func TestNewPCWSWorkerStateForTesting(t *testing.T) {
+	t.Parallel()
+
+	w1 := &worker{staticHostPubKeyStr: "w1"}
+	w2 := &worker{staticHostPubKeyStr: "w2"}
+	w3 := &worker{staticHostPubKeyStr: "w3"}
+	ws := newPCWSWorkerStateForTesting(new(Renter), 2, []*pcwsWorkerResponse{
+		{worker: w1, pieceIndices: []uint64{0, 1}},
+		{worker: w2},
+		{worker: w3, err: errors.New("failure")},
+	})
+
+	// all workers are resolved
+	pieceMap := ws.managedResolvedPieceMap()
+	expected := map[string][]uint64{
+		"w1": {0, 1},
+		"w2": {},
+		"w3": {},
+	}
+	if !reflect.DeepEqual(pieceMap, expected) {
+		t.Fatal("unexpected", pieceMap)
+	}
+	if ws.numSucceeded != 2 || ws.numErrored != 1 || ws.numUsable != 1 || ws.numLaunched != 3 {
+		t.Fatal("unexpected counts", ws.numSucceeded, ws.numErrored, ws.numUsable, ws.numLaunched)
+	}
+
+	// resolution is complete and there won't be any updates
+	select {
+	case <-ws.staticResolutionDone:
+	default:
+		t.Fatal("resolution should be done")
+	}
+	ws.mu.Lock()
+	c := ws.registerForWorkerUpdate()
+	ws.mu.Unlock()
+	if c != nil {
+		t.Fatal("there should be no more updates")
+	}
+	ws.managedMarkResolutionDone()
+}
+
+// TestPCWSPieceMapsEqual is a unit test for pcwsPieceMapsEqual.
+func TestPCWSPieceMapsEqual(t *testing.T) {
+	t.Parallel()
+
+	base := map[string][]uint64{"w1": {0, 1}, "w2": {2}, "w3": {}}
+	tests := []struct {
+		other map[string][]uint64
+		equal bool
+	}{
+		{map[string][]uint64{"w1": {0, 1}, "w2": {2}, "w3": {}}, true},
+		{map[string][]uint64{"w1": {0, 1}, "w2": {2}}, true},
+		{map[string][]uint64{"w1": {0, 1}, "w2": {2}, "w4": {}}, true},
+		{map[string][]uint64{"w1": {0, 1}, "w2": {2}, "w3": {1}}, false},
+		{map[string][]uint64{"w1": {0, 1}, "w2": {1}, "w3": {}}, false},
+		{map[string][]uint64{"w1": {0}, "w2": {2}, "w3": {}}, false},
+		{map[string][]uint64{"w1": {0, 1}}, false},
+		{nil, false},
+	}
+	for i, test := range tests {
+		if pcwsPieceMapsEqual(base, test.other) != test.equal || pcwsPieceMapsEqual(test.other, base) != test.equal {
+			t.Errorf("%v: expected equal to be %v", i, test.equal)
+		}
+	}
+}
+
+// TestProjectChunkWorkerSet_managedUpdateWorkerSetChanged verifies that a
+// refreshed worker state only signals a change if it resolved different
+// workers or pieces than the worker state it replaced.
+func TestProjectChunkWorkerSet_managedUpdateWorkerSetChanged(t *testing.T) {
+	t.Parallel()
+
+	logger, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renter := new(Renter)
+	renter.log = logger
+	pcws := &projectChunkWorkerSet{staticRenter: renter}
+	w1 := &worker{staticHostPubKeyStr: "w1"}
+	w2 := &worker{staticHostPubKeyStr: "w2"}
+	newWS := func(workers ...*pcwsWorkerResponse) *pcwsWorkerState {
+		return newPCWSWorkerStateForTesting(renter, 2, workers)
+	}
+	isClosed := func(c <-chan struct{}) bool {
+		select {
+		case <-c:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// the initial worker state is not a change
+	initial := newWS(&pcwsWorkerResponse{worker: w1, pieceIndices: []uint64{0}})
+	c := pcws.managedWorkerSetChangedChan()
+	pcws.managedUpdateWorkerSetChanged(nil, initial)
+	if pcws.managedWorkerSetChanged() || isClosed(c) {
+		t.Fatal("initial worker state shouldn't be a change")
+	}
+
+	// resolving the same pieces isn't a change, workers without pieces are
+	// ignored
+	same := newWS(&pcwsWorkerResponse{worker: w1, pieceIndices: []uint64{0}}, &pcwsWorkerResponse{worker: w2, err: errors.New("failure")})
+	pcws.managedUpdateWorkerSetChanged(initial, same)
+	if pcws.managedWorkerSetChanged() || isClosed(c) {
+		t.Fatal("refresh with the same pieces shouldn't be a change")
+	}
+
+	// a new piece is a change
+	changed := newWS(&pcwsWorkerResponse{worker: w1, pieceIndices: []uint64{0}}, &pcwsWorkerResponse{worker: w2, pieceIndices: []uint64{1}})
+	pcws.managedUpdateWorkerSetChanged(same, changed)
+	if !pcws.managedWorkerSetChanged() || !isClosed(c) {
+		t.Fatal("refresh with new pieces should be a change")
+	}
+
+	// the channel is replaced, the flag reflects the most recent refresh
+	c = pcws.managedWorkerSetChangedChan()
+	if isClosed(c) {
+		t.Fatal("channel should have been replaced")
+	}
+	pcws.managedUpdateWorkerSetChanged(changed, changed)
+	if pcws.managedWorkerSetChanged() || isClosed(c) {
+		t.Fatal("refresh with the same pieces shouldn't be a change")
+	}
+}
+
+// TestProjectChunkWorkerSet_AdaptiveResetTime verifies that the reset time of
+// a pcws grows with consecutive refreshes that don't change the worker set and
+// shrinks when the worker set churns.
+func TestProjectChunkWorkerSet_AdaptiveResetTime(t *testing.T) {
+	t.Parallel()
+
+	logger, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renter := new(Renter)
+	renter.log = logger
+	pcws := &projectChunkWorkerSet{
+		staticErasureCoder:   modules.NewPassthroughErasureCoder(),
+		staticRenter:         renter,
+		workerStateResetTime: pcwsWorkerStateResetTimeMin,
+	}
+	w1 := &worker{staticHostPubKeyStr: "w1"}
+	w2 := &worker{staticHostPubKeyStr: "w2"}
+	resetTime := func() time.Duration {
+		pcws.mu.Lock()
+		defer pcws.mu.Unlock()
+		return pcws.workerStateResetTime
+	}
+
+	// the initial worker state doesn't adapt the reset time
+	ws := newPCWSWorkerStateForTesting(renter, 2, []*pcwsWorkerResponse{{worker: w1, pieceIndices: []uint64{0}}})
+	pcws.managedUpdateWorkerSetChanged(nil, ws)
+	if rt := resetTime(); rt != pcwsWorkerStateResetTimeMin {
+		t.Fatal("initial worker state shouldn't adapt the reset time", rt)
+	}
+
+	// consecutive refreshes without changes grow the reset time up to the max
+	prev := pcwsWorkerStateResetTimeMin
+	for prev < pcwsWorkerStateResetTimeMax {
+		pcws.managedUpdateWorkerSetChanged(ws, ws)
+		rt := resetTime()
+		if rt <= prev || rt > pcwsWorkerStateResetTimeMax {
+			t.Fatalf("reset time should have grown from %v, got %v", prev, rt)
+		}
+		prev = rt
+	}
+	pcws.managedUpdateWorkerSetChanged(ws, ws)
+	if rt := resetTime(); rt != pcwsWorkerStateResetTimeMax {
+		t.Fatal("reset time should be capped at the max", rt)
+	}
+
+	// a refresh with high churn shrinks the reset time
+	churned := newPCWSWorkerStateForTesting(renter, 2, []*pcwsWorkerResponse{{worker: w2, pieceIndices: []uint64{0}}})
+	pcws.managedUpdateWorkerSetChanged(ws, churned)
+	if rt := resetTime(); rt >= pcwsWorkerStateResetTimeMax {
+		t.Fatal("reset time should have shrunk", rt)
+	}
+	for i := 0; i < 100; i++ {
+		pcws.managedUpdateWorkerSetChanged(ws, churned)
+	}
+	if rt := resetTime(); rt != pcwsWorkerStateResetTimeMin {
+		t.Fatal("reset time should be capped at the min", rt)
+	}
+
+	// the adapted reset time is part of the debug snapshot
+	if snapshot := pcws.managedDebugSnapshot(); snapshot.ResetTime != pcwsWorkerStateResetTimeMin {
+		t.Fatal("unexpected reset time in snapshot", snapshot.ResetTime)
+	}
+}
+
+// TestPCWSResolutionBuffer is a unit test for pcwsResolutionBuffer.
+func TestPCWSResolutionBuffer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		numPieces int
+		factor    float64
+		buffer    int
+	}{
+		{30, 0, 0},
+		{30, -1, 0},
+		{30, 0.5, 15},
+		{1, 0.5, 1},
+		{10, 0.25, 3},
+	}
+	for _, test := range tests {
+		if buffer := pcwsResolutionBuffer(test.numPieces, test.factor); buffer != test.buffer {
+			t.Fatal("unexpected", test, buffer)
+		}
+	}
+}
+
+// TestPCWSWorkerState_managedResolutionBuffer verifies the worker state counts
+// the usable workers beyond the number of pieces and reports whether the
+// resolution target was reached.
+func TestPCWSWorkerState_managedResolutionBuffer(t *testing.T) {
+	t.Parallel()
+
+	// create a worker state for a chunk with 2 pieces and a buffer of 1
+	ws := &pcwsWorkerState{
+		unresolvedWorkers:      make(map[string]*pcwsUnresolvedWorker),
+		staticNumPieces:        2,
+		staticResolutionTarget: 3,
+		staticRenter:           new(Renter),
+	}
+	resolve := func(name string, availables []bool, err error) {
+		w := &worker{staticHostPubKeyStr: name}
+		ws.unresolvedWorkers[name] = &pcwsUnresolvedWorker{staticWorker: w}
+		ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w, staticAvailables: availables, staticErr: err})
+	}
+	assertBuffer := func(expected int, expectedReached bool) {
+		t.Helper()
+		buffer, reached := ws.managedResolutionBuffer()
+		if buffer != expected || reached != expectedReached {
+			t.Fatal("unexpected", buffer, reached)
+		}
+	}
+
+	// errored workers and workers without pieces aren't usable
+	resolve("w1", nil, errors.New("failure"))
+	resolve("w2", []bool{false, false}, nil)
+	assertBuffer(0, false)
+
+	// enough workers to download the chunk, but no buffer
+	resolve("w3", []bool{true, false}, nil)
+	resolve("w4", []bool{false, true}, nil)
+	assertBuffer(0, false)
+
+	// the first extra worker reaches the target, more workers add to the
+	// buffer
+	resolve("w5", []bool{true, true}, nil)
+	assertBuffer(1, true)
+	resolve("w6", []bool{true, false}, nil)
+	assertBuffer(2, true)
+}
+
+// TestProjectChunkWorkerSet_managedDebugSnapshot is a unit test for the
+// managedDebugSnapshot method of the pcws.
+func TestProjectChunkWorkerSet_managedDebugSnapshot(t *testing.T) {
+	t.Parallel()
+
+	w1 := &worker{staticHostPubKeyStr: "w1"}
+	w2 := &worker{staticHostPubKeyStr: "w2"}
+	w3 := &worker{staticHostPubKeyStr: "w3"}
+	w4 := &worker{staticHostPubKeyStr: "w4"}
+	expectedResolvedTime := time.Now().Add(time.Minute)
+	ws := &pcwsWorkerState{
+		unresolvedWorkers: map[string]*pcwsUnresolvedWorker{
+			"w1": {staticWorker: w1},
+			"w2": {staticWorker: w2},
+			"w3": {staticWorker: w3},
+			"w4": {staticWorker: w4, staticExpectedResolvedTime: expectedResolvedTime},
+		},
+		numLaunched:  4,
+		staticRenter: new(Renter),
+	}
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w1, staticAvailables: []bool{true, false, true}})
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w2, staticAvailables: []bool{false, false, false}})
+	ws.managedHandleResponse(&jobHasSectorResponse{staticWorker: w3, staticErr: errors.New("failure")})
+
+	ec := modules.NewRSCodeDefault()
+	roots := []crypto.Hash{{1}, {2}, {3}}
+	launchTime := time.Now()
+	pcws := &projectChunkWorkerSet{
+		updateInProgress:      true,
+		workerState:           ws,
+		workerStateLaunchTime: launchTime,
+
+		staticID:           "id",
+		staticChunkIndex:   5,
+		staticErasureCoder: ec,
+		staticPieceRoots:   roots,
+	}
+
+	snapshot := pcws.managedDebugSnapshot()
+	expected := pcwsDebugSnapshot{
+		ID:         "id",
+		InitMode:   "roots",
+		ChunkIndex: 5,
+		MinPieces:  ec.MinPieces(),
+		NumPieces:  ec.NumPieces(),
+		PieceRoots: roots,
+
+		LaunchTime:       launchTime,
+		UpdateInProgress: true,
+
+		NumLaunched:   4,
+		NumResolved:   3,
+		NumUnresolved: 1,
+		ResolvedWorkers: []pcwsDebugResolvedWorker{
+			{HostPubKey: "w1", PieceIndices: []uint64{0, 2}},
+			{HostPubKey: "w2", PieceIndices: []uint64{}},
+			{HostPubKey: "w3", PieceIndices: []uint64{}, Err: "failure"},
+		},
+		UnresolvedWorkers: []pcwsDebugUnresolvedWorker{
+			{HostPubKey: "w4", ExpectedResolvedTime: expectedResolvedTime},
+		},
+	}
+	if !reflect.DeepEqual(snapshot, expected) {
+		t.Fatalf("unexpected snapshot\n%+v\n%+v", snapshot, expected)
+	}
+
+	// the snapshot is serializable
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded pcwsDebugSnapshot
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.NumResolved != 3 || len(decoded.ResolvedWorkers) != 3 || decoded.ResolvedWorkers[2].Err != "failure" {
+		t.Fatal("unexpected decoded snapshot", decoded)
+	}
+
+	// the snapshot doesn't share memory with the pcws
+	snapshot.PieceRoots[0] = crypto.Hash{}
+	snapshot.ResolvedWorkers[0].PieceIndices[0] = 1
+	snapshot = pcws.managedDebugSnapshot()
+	if snapshot.PieceRoots[0] != roots[0] || snapshot.ResolvedWorkers[0].PieceIndices[0] != 0 {
+		t.Fatal("snapshot shares memory with the pcws")
+	}
+}
+
+// TestProjectChunkWorkerSet_MaxRefreshes verifies that a pcws stops refreshing
+// its worker state once it launched the maximum number of worker states.
+func TestProjectChunkWorkerSet_MaxRefreshes(t *testing.T) {
+	t.Parallel()
+
+	r := new(Renter)
+	r.staticWorkerPool = new(workerPool)
+	logger, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.log = logger
+	ptec := modules.NewPassthroughErasureCoder()
+	ptck, err := crypto.NewSiaKey(crypto.TypePlain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// refresh forces a refresh of the worker state and returns the number of
+	// refreshes and whether the worker state was replaced.
+	refresh := func(pcws *projectChunkWorkerSet) (uint64, bool) {
+		pcws.mu.Lock()
+		pcws.workerStateLaunchTime = time.Time{}
+		prev := pcws.workerState
+		pcws.mu.Unlock()
+		if err := pcws.managedTryUpdateWorkerState(); err != nil {
+			t.Fatal(err)
+		}
+		pcws.mu.Lock()
+		defer pcws.mu.Unlock()
+		return pcws.numRefreshes, pcws.workerState != prev
+	}
+
+	// a pcws without a cap keeps refreshing
+	pcws, err := r.newPCWSByRoots(context.Background(), []crypto.Hash{{}}, ptec, ptck, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(2); i < 5; i++ {
+		if n, replaced := refresh(pcws); n != i || !replaced {
+			t.Fatal("unexpected refresh", n, replaced)
+		}
+	}
+
+	// a cap of 1 never refreshes after the initial worker state
+	pcws, err = r.newPCWSByRootsWithMaxRefreshes(context.Background(), []crypto.Hash{{}}, ptec, ptck, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pcws.managedWorkerState() == nil {
+		t.Fatal("initial worker state should be launched")
+	}
+	if n, replaced := refresh(pcws); n != 1 || replaced {
+		t.Fatal("unexpected refresh", n, replaced)
+	}
+
+	// a cap of 3 allows for 2 refreshes
+	pcws, err = r.newPCWSByRootsWithMaxRefreshes(context.Background(), []crypto.Hash{{}}, ptec, ptck, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(2); i <= 3; i++ {
+		if n, replaced := refresh(pcws); n != i || !replaced {
+			t.Fatal("unexpected refresh", n, replaced)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if n, replaced := refresh(pcws); n != 3 || replaced {
+			t.Fatal("unexpected refresh", n, replaced)
+		}
+	}
 }
@@ -211,6 +211,31 @@ func (pdc *projectDownloadChunk) unresolvedWorkers() ([]*pcwsUnresolvedWorker, <
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
 
+	// If there are more unresolved workers, fetch a channel that will be closed
+	// when more results from unresolved workers are available.
+	return pdc.updateAvailablePieces(), ws.registerForWorkerUpdate()
+}
+
+// unresolvedWorkersWithState is similar to unresolvedWorkers, but the returned
+// channel receives the updated state of the worker state before it is closed.
+// This allows callers that loop on a timer to keep using the returned set of
+// unresolved workers until the channel fires, rather than re-acquiring the
+// worker state lock on every iteration.
+func (pdc *projectDownloadChunk) unresolvedWorkersWithState() ([]*pcwsUnresolvedWorker, <-chan resolveUpdate) {
+	ws := pdc.workerState
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	unresolvedWorkers := pdc.updateAvailablePieces()
+	updateChan, _ := ws.registerForWorkerUpdateWithState()
+	return unresolvedWorkers, updateChan
+}
+
+// updateAvailablePieces adds any newly resolved workers to the pdc's list of
+// available pieces and returns the current set of unresolved workers. The
+// worker state lock needs to be held when calling this function.
+func (pdc *projectDownloadChunk) updateAvailablePieces() []*pcwsUnresolvedWorker {
+	ws := pdc.workerState
 	var unresolvedWorkers []*pcwsUnresolvedWorker
 	for _, uw := range ws.unresolvedWorkers {
 		unresolvedWorkers = append(unresolvedWorkers, uw)
@@ -228,10 +253,7 @@ func (pdc *projectDownloadChunk) unresolvedWorkers() ([]*pcwsUnresolvedWorker, <
 	}
 	pdc.workersConsideredIndex = len(ws.resolvedWorkers)
 	pdc.unresolvedWorkersRemaining = len(ws.unresolvedWorkers)
-
-	// If there are more unresolved workers, fetch a channel that will be closed
-	// when more results from unresolved workers are available.
-	return unresolvedWorkers, ws.registerForWorkerUpdate()
+	return unresolvedWorkers
 }
 
 // handleJobReadResponse will take a jobReadResponse from a worker job
@@ -454,6 +454,54 @@ func TestProjectDownloadChunk_launchWorker(t *testing.T) {
 	}
 }
 
+// TestProjectDownloadChunk_unresolvedWorkersWithState is a unit test for the
+// 'unresolvedWorkersWithState' function on the pdc.
+func TestProjectDownloadChunk_unresolvedWorkersWithState(t *testing.T) {
+	t.Parallel()
+
+	// mock a pdc with two unresolved workers
+	w1 := &worker{staticHostPubKeyStr: "w1"}
+	w2 := &worker{staticHostPubKeyStr: "w2"}
+	pdc := new(projectDownloadChunk)
+	pdc.availablePieces = make([][]*pieceDownload, 2)
+	pdc.workerState = &pcwsWorkerState{
+		unresolvedWorkers: map[string]*pcwsUnresolvedWorker{
+			"w1": {staticWorker: w1},
+			"w2": {staticWorker: w2},
+		},
+		staticRenter: new(Renter),
+	}
+
+	// verify the initial state
+	unresolved, updateChan := pdc.unresolvedWorkersWithState()
+	if len(unresolved) != 2 || pdc.unresolvedWorkersRemaining != 2 {
+		t.Fatal("unexpected", len(unresolved), pdc.unresolvedWorkersRemaining)
+	}
+
+	// resolve a worker, the update should reflect the new state
+	pdc.workerState.managedHandleResponse(&jobHasSectorResponse{staticWorker: w1, staticAvailables: []bool{false, true}})
+	select {
+	case update := <-updateChan:
+		if update.numResolved != 1 || update.numTotal != 2 {
+			t.Fatal("unexpected", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	// refreshing should add the resolved worker to the available pieces
+	unresolved, _ = pdc.unresolvedWorkersWithState()
+	if len(unresolved) != 1 || pdc.unresolvedWorkersRemaining != 1 {
+		t.Fatal("unexpected", len(unresolved), pdc.unresolvedWorkersRemaining)
+	}
+	if len(pdc.availablePieces[0]) != 0 || len(pdc.availablePieces[1]) != 1 || pdc.availablePieces[1][0].worker != w1 {
+		t.Fatal("unexpected available pieces", pdc.availablePieces)
+	}
+	if pdc.workersConsideredIndex != 1 {
+		t.Fatal("unexpected", pdc.workersConsideredIndex)
+	}
+}
+
 // TestGetPieceOffsetAndLen is a unit test that probes the helper function
 // getPieceOffsetAndLength
 func TestGetPieceOffsetAndLen(t *testing.T) {
@@ -211,6 +211,7 @@ func (pdc *projectDownloadChunk) initialWorkerHeap(unresolvedWorkers []*pcwsUnre
 	// workers are organized as a series of available pieces, because that is
 	// what made the overdrive code the easiest.
 	resolvedWorkersMap := make(map[string]*pdcInitialWorker)
+	readGougingErrs := make(map[string]error)
 	for i, piece := range pdc.availablePieces {
 		for _, pieceDownload := range piece {
 			w := pieceDownload.worker
@@ -219,7 +220,17 @@ func (pdc *projectDownloadChunk) initialWorkerHeap(unresolvedWorkers []*pcwsUnre
 
 			// Ignore this worker if its host is considered to be price gouging.
 			err := checkProjectDownloadGouging(pt, allowance)
-			if err != nil {
+			if err != nil && !w.staticGougingExempt(modules.GougingCheckDownload) {
+				continue
+			}
+
+			// Ignore this worker if reading a piece of this chunk's piece
+			// length is too expensive. The HasSector check above only prices
+			// an average read, a host can be cheap on lookups and still gouge
+			// on the reads we are about to perform.
+			err = checkPieceReadGouging(pt, allowance, pdc.pieceLength)
+			if err != nil && !w.staticGougingExempt(modules.GougingCheckDownload) {
+				readGougingErrs[w.staticHostPubKeyStr] = err
 				continue
 			}
 
@@ -253,6 +264,12 @@ func (pdc *projectDownloadChunk) initialWorkerHeap(unresolvedWorkers []*pcwsUnre
 		}
 	}
 
+	// Mark the workers that failed the read gouging check in the worker state,
+	// they remain resolved but won't be used to read pieces.
+	if len(readGougingErrs) > 0 && pdc.workerState != nil {
+		pdc.workerState.managedMarkUnusableForReads(readGougingErrs)
+	}
+
 	// Push a pdcInitialWorker into the heap for each worker in the resolved
 	// workers map.
 	for _, rw := range resolvedWorkersMap {
@@ -481,11 +498,19 @@ func (pdc *projectDownloadChunk) createInitialWorkerSet(workerHeap pdcWorkerHeap
 func (pdc *projectDownloadChunk) launchInitialWorkers() error {
 	start := time.Now()
 
+	var unresolvedWorkers []*pcwsUnresolvedWorker
+	var updateChan <-chan resolveUpdate
+	refresh := true
 	for {
 		// Get the list of unresolved workers. This will also grab an update, so
 		// any workers that have resolved recently will be reflected in the
-		// newly returned set of values.
-		unresolvedWorkers, updateChan := pdc.unresolvedWorkers()
+		// newly returned set of values. The set only changes when the update
+		// chan fires, if we woke up because of the timeout we reuse the
+		// previous set and avoid grabbing the worker state lock.
+		if refresh {
+			unresolvedWorkers, updateChan = pdc.unresolvedWorkersWithState()
+			refresh = false
+		}
 
 		// Create a list of usable workers, sorted by the amount of time they
 		// are expected to take to return. We pass in the time since we've
@@ -516,6 +541,7 @@ func (pdc *projectDownloadChunk) launchInitialWorkers() error {
 
 		select {
 		case <-updateChan:
+			refresh = true
 		case <-time.After(maxWaitUnresolvedWorkerUpdate):
 			// We want to limit the amount of time spent waiting for unresolved
 			// workers to become resolved. This is because we assign a penalty
@@ -594,3 +620,59 @@ func checkProjectDownloadGouging(pt modules.RPCPriceTable, allowance modules.All
 
 	return nil
 }
+
+// checkPieceReadGouging verifies the cost of reading a piece of the given
+// length is reasonable. Where checkProjectDownloadGouging prices an average
+// download, this check builds the ReadSector program for the piece length that
+// is actually being requested and prices it using the host's price table. The
+// cost is compared against the explicit maximum prices in the allowance and
+// against the allowance heuristic scaled by the expected download.
+func checkPieceReadGouging(pt modules.RPCPriceTable, allowance modules.Allowance, pieceLength uint64) error {
+	// Nothing is read, so there is nothing to price.
+	if pieceLength == 0 {
+		return nil
+	}
+
+	// Check whether the base RPC price is too high.
+	if !allowance.MaxRPCPrice.IsZero() && allowance.MaxRPCPrice.Cmp(pt.InitBaseCost) < 0 {
+		return fmt.Errorf("rpc price of host is %v, which is above the maximum allowed by the allowance: %v - price gouging protection enabled", pt.InitBaseCost, allowance.MaxRPCPrice)
+	}
+
+	// Check whether the sector access price is too high.
+	if !allowance.MaxSectorAccessPrice.IsZero() && allowance.MaxSectorAccessPrice.Cmp(pt.ReadBaseCost) < 0 {
+		return fmt.Errorf("sector access price of host is %v, which is above the maximum allowed by the allowance: %v - price gouging protection enabled", pt.ReadBaseCost, allowance.MaxSectorAccessPrice)
+	}
+
+	// If there is no allowance, price gouging checks have to be disabled,
+	// because there is no baseline for understanding what might count as price
+	// gouging.
+	if allowance.Funds.IsZero() {
+		return nil
+	}
+
+	// Calculate the cost of reading a single piece.
+	pb := modules.NewProgramBuilder(&pt, 0)
+	pb.AddReadSectorInstruction(pieceLength, 0, crypto.Hash{}, true)
+	programCost, _, _ := pb.Cost(true)
+
+	ulbw, dlbw := readSectorJobExpectedBandwidth(pieceLength)
+	bandwidthCost := modules.MDMBandwidthCost(pt, ulbw, dlbw)
+	costRead := programCost.Add(bandwidthCost)
+
+	// Calculate the number of reads necessary to download the expected
+	// download amount using reads of this length.
+	numReads := allowance.ExpectedDownload / pieceLength
+	if numReads == 0 {
+		numReads = 1
+	}
+
+	// The read is considered too expensive if the allowance is insufficient to
+	// cover a fraction of the expense to download the amount of data the user
+	// intends to download.
+	totalCost := costRead.Mul64(numReads)
+	reducedCost := totalCost.Div64(downloadGougingFractionDenom)
+	if reducedCost.Cmp(allowance.Funds) > 0 {
+		return fmt.Errorf("reading pieces of %v bytes from host yields %v, which is more than the renter is willing to pay for downloads: %v - price gouging protection enabled", pieceLength, reducedCost, allowance.Funds)
+	}
+	return nil
+}
@@ -436,3 +436,112 @@ func TestProjectDownloadGouging(t *testing.T) {
 		t.Fatalf("expected PDBR price gouging error, instead error was '%v'", err)
 	}
 }
+
+// TestPieceReadGouging checks that `checkPieceReadGouging` detects a host that
+// is cheap on HasSector lookups but expensive on the reads that are performed
+// for the piece length being requested.
+func TestPieceReadGouging(t *testing.T) {
+	t.Parallel()
+
+	// allowance contains only the fields necessary to test the price gouging
+	hes := modules.DefaultHostExternalSettings()
+	allowance := modules.Allowance{
+		Funds:                     types.SiacoinPrecision.Mul64(1e3),
+		ExpectedDownload:          1 << 30, // 1GiB
+		MaxDownloadBandwidthPrice: hes.DownloadBandwidthPrice.Mul64(10),
+		MaxUploadBandwidthPrice:   hes.UploadBandwidthPrice.Mul64(10),
+	}
+
+	// verify happy case
+	pt := newDefaultPriceTable()
+	err := checkPieceReadGouging(pt, allowance, 1<<12)
+	if err != nil {
+		t.Fatal("unexpected price gouging failure", err)
+	}
+
+	// make the host expensive on reads, the PDBR check only prices an average
+	// read and should not detect the gouging
+	pt.ReadBaseCost = types.SiacoinPrecision.MulFloat(0.1)
+	err = checkPCWSGouging(pt, allowance, 10, modules.NewRSSubCodeDefault())
+	if err != nil {
+		t.Fatal("unexpected price gouging failure", err)
+	}
+	err = checkProjectDownloadGouging(pt, allowance)
+	if err != nil {
+		t.Fatal("unexpected price gouging failure", err)
+	}
+
+	// reading large pieces is fine, the number of reads is low
+	err = checkPieceReadGouging(pt, allowance, 1<<22)
+	if err != nil {
+		t.Fatal("unexpected price gouging failure", err)
+	}
+
+	// reading small pieces is too expensive
+	err = checkPieceReadGouging(pt, allowance, 1<<12)
+	if err == nil || !strings.Contains(err.Error(), "reading pieces of 4096 bytes") {
+		t.Fatalf("expected read price gouging error, instead error was '%v'", err)
+	}
+
+	// verify these checks are ignored if the funds are 0
+	allowance.Funds = types.ZeroCurrency
+	err = checkPieceReadGouging(pt, allowance, 1<<12)
+	if err != nil {
+		t.Fatal("unexpected price gouging failure", err)
+	}
+
+	// verify the explicit max sector access price is enforced, even without
+	// funds
+	allowance.MaxSectorAccessPrice = pt.ReadBaseCost.Sub64(1)
+	err = checkPieceReadGouging(pt, allowance, 1<<22)
+	if err == nil || !strings.Contains(err.Error(), "sector access price") {
+		t.Fatalf("expected sector access price gouging error, instead error was '%v'", err)
+	}
+	allowance.MaxSectorAccessPrice = types.ZeroCurrency
+
+	// verify the explicit max rpc price is enforced
+	pt.InitBaseCost = types.SiacoinPrecision.MulFloat(1e-6)
+	allowance.MaxRPCPrice = pt.InitBaseCost.Sub64(1)
+	err = checkPieceReadGouging(pt, allowance, 1<<22)
+	if err == nil || !strings.Contains(err.Error(), "rpc price") {
+		t.Fatalf("expected rpc price gouging error, instead error was '%v'", err)
+	}
+	allowance.MaxRPCPrice = types.ZeroCurrency
+
+	// verify a zero piece length is never considered gouging
+	allowance.Funds = types.SiacoinPrecision
+	err = checkPieceReadGouging(pt, allowance, 0)
+	if err != nil {
+		t.Fatal("unexpected price gouging failure", err)
+	}
+}
+
+// TestPCWSMarkUnusableForReads verifies resolved workers that fail the read
+// gouging check remain resolved but are marked as unusable for reads.
+func TestPCWSMarkUnusableForReads(t *testing.T) {
+	t.Parallel()
+
+	w1 := new(worker)
+	w1.staticHostPubKeyStr = "host1"
+	w2 := new(worker)
+	w2.staticHostPubKeyStr = "host2"
+
+	ws := &pcwsWorkerState{
+		resolvedWorkers: []*pcwsWorkerResponse{
+			{worker: w1, pieceIndices: []uint64{0}},
+			{worker: w2, pieceIndices: []uint64{1}},
+		},
+	}
+
+	gougingErr := errors.New("gouging")
+	ws.managedMarkUnusableForReads(map[string]error{"host2": gougingErr})
+	if len(ws.resolvedWorkers) != 2 {
+		t.Fatal("unexpected number of resolved workers", len(ws.resolvedWorkers))
+	}
+	if ws.resolvedWorkers[0].readGougingErr != nil {
+		t.Fatal("worker should be usable for reads")
+	}
+	if ws.resolvedWorkers[1].readGougingErr != gougingErr {
+		t.Fatal("worker should be marked unusable for reads", ws.resolvedWorkers[1].readGougingErr)
+	}
+}
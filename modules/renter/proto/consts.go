@@ -86,3 +86,19 @@ var (
 	// version of the renter-host protocol.
 	ErrBadHostVersion = errors.New("Bad host version; host does not support required protocols")
 )
+
+// Constants related to the contract set's alerts.
+var (
+	// AlertMSGRefCounterUnderflow indicates that a sector's reference count
+	// was decremented while it was already zero, which means that the sector
+	// was freed twice.
+	AlertMSGRefCounterUnderflow = "Reference counter detected an attempt to decrement a sector that isn't referenced anymore"
+
+	// AlertMSGRefCounterCorrupt indicates that a contract's reference counter
+	// is corrupt and can't be repaired.
+	AlertMSGRefCounterCorrupt = "Reference counter of a contract is corrupt"
+
+	// AlertMSGRefCounterRebuilt indicates that a contract's reference counter
+	// was missing or corrupt and was rebuilt from the contract's sector roots.
+	AlertMSGRefCounterRebuilt = "Reference counter of a contract was rebuilt from its sector roots"
+)
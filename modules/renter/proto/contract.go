@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/writeaheadlog"
@@ -228,6 +229,59 @@ func (c *SafeContract) clearUnappliedTxns() error {
 	return nil
 }
 
+// managedAuditRefCounter cross-checks the refcounter at the given path, which
+// is opened read-only, against the contract's sector roots and returns the
+// sectors on which they disagree.
+func (c *SafeContract) managedAuditRefCounter(path string) ([]RefCounterMismatch, error) {
+	c.mu.Lock()
+	id := c.header.ID()
+	roots, err := c.merkleRoots.merkleRoots()
+	c.mu.Unlock()
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to read sector roots")
+	}
+	rc, err := LoadRefCounterReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	numSectors, err := rc.NumSectors()
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []RefCounterMismatch
+	for secIdx := numSectors; secIdx < uint64(len(roots)); secIdx++ {
+		mismatches = append(mismatches, RefCounterMismatch{
+			ContractID:  id,
+			SectorIndex: secIdx,
+			Reason:      "sector root has no count",
+		})
+	}
+	err = rc.ForEach(func(secIdx uint64, count uint16) error {
+		if secIdx >= uint64(len(roots)) {
+			mismatches = append(mismatches, RefCounterMismatch{
+				ContractID:  id,
+				SectorIndex: secIdx,
+				Reason:      fmt.Sprintf("count %v has no sector root", count),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to read counts")
+	}
+	return mismatches, nil
+}
+
+// LastAccess returns the last time the sector at the given index was accessed.
+// ErrAccessTimesNotTracked is returned if the contract's reference counter
+// does not track access times.
+func (c *SafeContract) LastAccess(sectorIndex uint64) (time.Time, error) {
+	if c.staticRC == nil {
+		return time.Time{}, ErrAccessTimesNotTracked
+	}
+	return c.staticRC.callLastAccess(sectorIndex)
+}
+
 // LastRevision returns the most recent revision
 func (c *SafeContract) LastRevision() types.FileContractRevision {
 	c.mu.Lock()
@@ -241,7 +295,7 @@ func (c *SafeContract) Metadata() modules.RenterContract {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	h := c.header
-	return modules.RenterContract{
+	md := modules.RenterContract{
 		ID:                  h.ID(),
 		Transaction:         h.copyTransaction(),
 		HostPublicKey:       h.HostPublicKey(),
@@ -259,6 +313,15 @@ func (c *SafeContract) Metadata() modules.RenterContract {
 		SiafundFee:          h.SiafundFee,
 		Utility:             h.Utility,
 	}
+	// Add the refcounter's metrics if the contract has one.
+	if c.staticRC != nil {
+		md.NumSectors = c.staticRC.callNumSectors()
+		md.GarbageSectors = c.staticRC.callGarbageCount()
+		if size, err := c.staticRC.callFileSize(); err == nil {
+			md.RefCounterSize = uint64(size)
+		}
+	}
+	return md
 }
 
 // PublicKey returns the public key capable of verifying the renter's signature
@@ -523,7 +586,7 @@ func (c *SafeContract) managedCommitAppend(t *unappliedWalTxn, signedTxn types.T
 			if err := c.applySetRoot(sru.Root, sru.Index); err != nil {
 				return err
 			}
-		case updateNameRCWriteAt:
+		case updateNameRCWriteAt, updateNameRCWriteRangeAt:
 			if err = c.applyRefCounterUpdate(u); err != nil {
 				return errors.AddContext(err, "failed to apply refcounter update")
 			}
@@ -679,7 +742,7 @@ func (c *SafeContract) managedCommitTxns() error {
 				if err := c.applySetRoot(u.Root, u.Index); err != nil {
 					return err
 				}
-			case updateNameRCWriteAt:
+			case updateNameRCWriteAt, updateNameRCWriteRangeAt:
 				if err := c.applyRefCounterUpdate(update); err != nil {
 					return err
 				}
@@ -874,7 +937,7 @@ func (cs *ContractSet) managedApplyInsertContractUpdate(update writeaheadlog.Upd
 	}
 	var rc *refCounter
 	if build.Release == "testing" {
-		rc, err = newRefCounter(rcFilePath, uint64(len(roots)), cs.staticWal)
+		rc, err = cs.newRefCounter(rcFilePath, uint64(len(roots)))
 		if err != nil {
 			return modules.RenterContract{}, errors.AddContext(err, "failed to create a refcounter")
 		}
@@ -897,6 +960,86 @@ func (cs *ContractSet) managedApplyInsertContractUpdate(update writeaheadlog.Upd
 	return sc.Metadata(), nil
 }
 
+// newRefCounter creates a new reference counter for a contract. The reference
+// counter tracks sector access times if the contract set was created with
+// access tracking enabled.
+func (cs *ContractSet) newRefCounter(path string, numSec uint64) (rc *refCounter, err error) {
+	if cs.staticTrackSectorAccess {
+		rc, err = newRefCounterWithAccessTimes(path, numSec, cs.staticWal)
+	} else {
+		rc, err = newRefCounter(path, numSec, cs.staticWal)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rc.staticAlerter = cs.staticAlerter
+	return rc, nil
+}
+
+// loadRefCounter loads the reference counter of a contract from disk. A file
+// that ends with a partial counter is repaired and a file of an older version
+// is migrated to the current version. A missing or corrupt file can't be
+// repaired, see managedRebuildRefCounter.
+func (cs *ContractSet) loadRefCounter(path string) (*refCounter, error) {
+	rc, err := loadRefCounter(path, cs.staticWal)
+	if errors.Contains(err, ErrMisalignedFile) {
+		rc, err = openRefCounter(path, cs.staticWal)
+		if err == nil {
+			err = errors.AddContext(rc.callRepair(), "failed to repair refcounter")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = rc.callUpgrade(); err != nil {
+		return nil, errors.AddContext(err, "failed to migrate refcounter")
+	}
+	rc.staticAlerter = cs.staticAlerter
+	return rc, nil
+}
+
+// managedRebuildRefCounter rebuilds the missing or corrupt reference counter
+// of a contract at the given path from the contract's sector roots. cause is
+// the error that the reference counter failed to load with. A warning alert is
+// registered for the rebuild. If the rebuild fails, the reference counter is
+// unusable and a critical alert is registered instead.
+func (cs *ContractSet) managedRebuildRefCounter(id types.FileContractID, path string, roots []crypto.Hash, cause error) (*refCounter, error) {
+	_, err := os.Stat(accessTimeFilePath(path))
+	trackAccess := err == nil || cs.staticTrackSectorAccess
+	rc, err := rebuildRefCounter(path, roots, cs.staticWal, trackAccess)
+	if err != nil {
+		err = errors.Compose(cause, err)
+		cs.staticAlerter.RegisterAlert(modules.AlertIDRenterRefCounterCorrupt, AlertMSGRefCounterCorrupt, fmt.Sprintf("refcounter '%v': %v", path, err), modules.SeverityCritical)
+		return nil, err
+	}
+	rc.staticAlerter = cs.staticAlerter
+	cs.staticAlerter.RegisterAlert(modules.AlertIDRenterRefCounterRebuilt(id.String()), AlertMSGRefCounterRebuilt, fmt.Sprintf("refcounter '%v': %v", path, cause), modules.SeverityWarning)
+	return rc, nil
+}
+
+// RebuildRefCounter rebuilds the reference counter of the given contract from
+// the contract's sector roots. Every sector gets a count of 1, except for
+// duplicates of an earlier sector, which become garbage. The contract must
+// have been acquired with Acquire. Contracts without a reference counter are
+// left untouched.
+func (cs *ContractSet) RebuildRefCounter(c *SafeContract) error {
+	if c.staticRC == nil {
+		return nil
+	}
+	c.mu.Lock()
+	id := c.header.ID()
+	roots, err := c.merkleRoots.merkleRoots()
+	c.mu.Unlock()
+	if err != nil {
+		return errors.AddContext(err, "failed to read sector roots")
+	}
+	if err := c.staticRC.callRebuild(roots); err != nil {
+		return err
+	}
+	cs.staticAlerter.RegisterAlert(modules.AlertIDRenterRefCounterRebuilt(id.String()), AlertMSGRefCounterRebuilt, fmt.Sprintf("refcounter '%v' was rebuilt on request", c.staticRC.filepath), modules.SeverityWarning)
+	return nil
+}
+
 // loadSafeContractHeader will load a contract from disk, checking for legacy
 // encodings if initial attempts fail.
 func loadSafeContractHeader(f io.ReadSeeker, decodeMaxSize int) (contractHeader, error) {
@@ -981,13 +1124,18 @@ func (cs *ContractSet) loadSafeContract(headerFileName, rootsFileName, refCountF
 	}
 	var rc *refCounter
 	if build.Release == "testing" {
-		// load the reference counter or create a new one if it doesn't exist
-		rc, err = loadRefCounter(refCountFileName, cs.staticWal)
-		if errors.Contains(err, ErrRefCounterNotExist) {
-			rc, err = newRefCounter(refCountFileName, uint64(merkleRoots.numMerkleRoots), cs.staticWal)
+		// load the reference counter or rebuild it from the sector roots if
+		// it is missing or corrupt
+		rc, err = cs.loadRefCounter(refCountFileName)
+		if errors.Contains(err, ErrRefCounterNotExist) || isRefCounterCorruption(err) {
+			roots, rootsErr := merkleRoots.merkleRoots()
+			if rootsErr != nil {
+				return errors.AddContext(errors.Compose(err, rootsErr), "failed to read sector roots to rebuild refcounter")
+			}
+			rc, err = cs.managedRebuildRefCounter(header.ID(), refCountFileName, roots, err)
 		}
 		if err != nil {
-			return errors.AddContext(err, "failed to load or create a refcounter")
+			return errors.AddContext(err, "failed to load or rebuild a refcounter")
 		}
 	}
 	// add to set
@@ -2,16 +2,20 @@ package proto
 
 import (
 	"bytes"
+	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
 	"gitlab.com/NebulousLabs/ratelimit"
+	"gitlab.com/NebulousLabs/writeaheadlog"
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
@@ -1112,3 +1116,220 @@ func TestPanicOnOverwritingNewerRevision(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestContractSetSectorAccessTracking verifies that a contract set created with
+// sector access tracking creates reference counters that track access times,
+// and that a regular contract set doesn't.
+func TestContractSetSectorAccessTracking(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	header := contractHeader{
+		Transaction: types.Transaction{
+			FileContractRevisions: []types.FileContractRevision{{
+				NewRevisionNumber:    1,
+				NewValidProofOutputs: []types.SiacoinOutput{{}, {}},
+				UnlockConditions: types.UnlockConditions{
+					PublicKeys: []types.SiaPublicKey{{}, {}},
+				},
+			}},
+		},
+	}
+	roots := []crypto.Hash{{1}, {2}}
+	rl := ratelimit.NewRateLimit(0, 0, 0)
+
+	// a regular contract set doesn't track access times
+	dir := build.TempDir(filepath.Join("proto", t.Name(), "regular"))
+	cs, err := NewContractSet(dir, rl, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := cs.managedInsertContract(header, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := cs.managedMustAcquire(t, c.ID)
+	if _, err := sc.LastAccess(0); !errors.Contains(err, ErrAccessTimesNotTracked) {
+		t.Fatal("expected ErrAccessTimesNotTracked, got", err)
+	}
+	cs.Return(sc)
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a contract set with tracking enabled does
+	start := time.Now().Truncate(time.Second)
+	dir = build.TempDir(filepath.Join("proto", t.Name(), "tracking"))
+	cs, err = NewContractSetWithSectorAccessTracking(dir, rl, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = cs.managedInsertContract(header, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc = cs.managedMustAcquire(t, c.ID)
+	for i := range roots {
+		at, err := sc.LastAccess(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if at.Before(start) {
+			t.Fatalf("sector %v: unexpected access time %v", i, at)
+		}
+	}
+	cs.Return(sc)
+
+	// the tracking survives a reload, even by a regular contract set
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cs, err = NewContractSet(dir, rl, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc = cs.managedMustAcquire(t, c.ID)
+	if _, err := sc.LastAccess(0); err != nil {
+		t.Fatal(err)
+	}
+	cs.Return(sc)
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestContractSetRefCounterRenameRecovery verifies that a refcounter rename that
+// was interrupted after being written to the WAL is completed when the contract
+// set is loaded.
+func TestContractSetRefCounterRenameRecovery(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dir := build.TempDir(filepath.Join("proto", t.Name()))
+	rl := ratelimit.NewRateLimit(0, 0, 0)
+	cs, err := NewContractSet(dir, rl, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create a refcounter file and its access time file
+	oldPath := filepath.Join(dir, "old"+refCounterExtension)
+	newPath := filepath.Join(dir, "new"+refCounterExtension)
+	for _, path := range []string{oldPath, accessTimeFilePath(oldPath)} {
+		if err := ioutil.WriteFile(path, []byte{1}, modules.DefaultFilePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// write the rename to the WAL without applying it
+	txn, err := cs.staticWal.NewTransaction([]writeaheadlog.Update{createRenameUpdate(oldPath, newPath)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-txn.SignalSetupComplete(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reload the contract set, the rename should be completed
+	cs, err = NewContractSet(dir, rl, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cs.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, path := range []string{oldPath, accessTimeFilePath(oldPath)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatal("old file still exists", path, err)
+		}
+	}
+	for _, path := range []string{newPath, accessTimeFilePath(newPath)} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatal("new file doesn't exist", path, err)
+		}
+	}
+}
+
+// TestContractSetRefCounterUpdateRecovery verifies that refcounter updates that
+// were interrupted after being written to the WAL are applied when the contract
+// set is loaded.
+func TestContractSetRefCounterUpdateRecovery(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dir := build.TempDir(filepath.Join("proto", t.Name()))
+	rl := ratelimit.NewRateLimit(0, 0, 0)
+	cs, err := NewContractSet(dir, rl, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create a refcounter and write an increment to the WAL without applying
+	// it
+	path := filepath.Join(dir, "rc"+refCounterExtension)
+	rc, err := newRefCounter(path, 4, cs.staticWal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := rc.callIncrement(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cu, err := rc.checksumUpdate(f, []writeaheadlog.Update{u})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	txn, err := cs.staticWal.NewTransaction([]writeaheadlog.Update{u, cu})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-txn.SignalSetupComplete(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reload the contract set, the increment should be applied
+	cs, err = NewContractSet(dir, rl, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cs.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rc, err = loadRefCounter(path, cs.staticWal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := rc.callCount(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatal("Expected count 2, got", count)
+	}
+}
@@ -1,10 +1,12 @@
 package proto
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -29,6 +31,14 @@ type ContractSet struct {
 	mu         sync.Mutex
 	staticRL   *ratelimit.RateLimit
 	staticWal  *writeaheadlog.WAL
+
+	// staticTrackSectorAccess indicates whether newly created reference
+	// counters track the last access time of every sector.
+	staticTrackSectorAccess bool
+
+	// staticAlerter holds the alerts of the contract set's reference
+	// counters.
+	staticAlerter *modules.GenericAlerter
 }
 
 // Acquire looks up the contract for the specified host key and locks it before
@@ -179,14 +189,81 @@ func (cs *ContractSet) Close() error {
 	for _, c := range cs.contracts {
 		err = errors.Compose(err, c.staticHeaderFile.Close())
 		err = errors.Compose(err, c.merkleRoots.rootsFile.Close())
+		if c.staticRC != nil {
+			err = errors.Compose(err, c.staticRC.callClose())
+		}
 	}
 	_, errWal := cs.staticWal.CloseIncomplete()
 	return errors.Compose(err, errWal)
 }
 
+// Alerts implements the modules.Alerter interface.
+func (cs *ContractSet) Alerts() (crit, err, warn, info []modules.Alert) {
+	return cs.staticAlerter.Alerts()
+}
+
+// RefCounterMismatch describes a sector of a contract on which the contract's
+// reference counter and its sector roots disagree.
+type RefCounterMismatch struct {
+	ContractID  types.FileContractID
+	SectorIndex uint64
+	Reason      string
+}
+
+// AuditRefCounters cross-checks the reference counter of every contract
+// against the contract's sector roots and returns the mismatches, ordered by
+// contract and sector. Every sector root needs a count and every count needs
+// a sector root. The reference counters are opened read-only, so the audit
+// doesn't interfere with their update sessions. Contracts without a reference
+// counter are skipped. A mismatch found while a contract is being revised can
+// be transient.
+func (cs *ContractSet) AuditRefCounters() ([]RefCounterMismatch, error) {
+	cs.mu.Lock()
+	contracts := make([]*SafeContract, 0, len(cs.contracts))
+	for _, c := range cs.contracts {
+		contracts = append(contracts, c)
+	}
+	cs.mu.Unlock()
+
+	var mismatches []RefCounterMismatch
+	for _, c := range contracts {
+		path := filepath.Join(cs.staticDir, c.header.ID().String()+refCounterExtension)
+		m, err := c.managedAuditRefCounter(path)
+		if errors.Contains(err, ErrRefCounterNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.AddContext(err, fmt.Sprintf("failed to audit refcounter of contract %v", c.header.ID()))
+		}
+		mismatches = append(mismatches, m...)
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		if mismatches[i].ContractID != mismatches[j].ContractID {
+			return bytes.Compare(mismatches[i].ContractID[:], mismatches[j].ContractID[:]) < 0
+		}
+		return mismatches[i].SectorIndex < mismatches[j].SectorIndex
+	})
+	return mismatches, nil
+}
+
 // NewContractSet returns a ContractSet storing its contracts in the specified
 // dir.
 func NewContractSet(dir string, rl *ratelimit.RateLimit, deps modules.Dependencies) (*ContractSet, error) {
+	return newContractSet(dir, rl, deps, false)
+}
+
+// NewContractSetWithSectorAccessTracking returns a ContractSet like
+// NewContractSet, but the reference counters it creates also track the last
+// time each sector was accessed, which can be queried through
+// SafeContract.LastAccess. Existing reference counters only track access
+// times if they were created with tracking enabled.
+func NewContractSetWithSectorAccessTracking(dir string, rl *ratelimit.RateLimit, deps modules.Dependencies) (*ContractSet, error) {
+	return newContractSet(dir, rl, deps, true)
+}
+
+// newContractSet returns a ContractSet storing its contracts in the specified
+// dir.
+func newContractSet(dir string, rl *ratelimit.RateLimit, deps modules.Dependencies, trackSectorAccess bool) (*ContractSet, error) {
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return nil, err
 	}
@@ -223,6 +300,9 @@ func NewContractSet(dir string, rl *ratelimit.RateLimit, deps modules.Dependenci
 		staticDir:  dir,
 		staticRL:   rl,
 		staticWal:  wal,
+
+		staticTrackSectorAccess: trackSectorAccess,
+		staticAlerter:           modules.NewAlerter("contractset"),
 	}
 	// Set the initial rate limit to 'unlimited' bandwidth with 4kib packets.
 	cs.staticRL = ratelimit.NewRateLimit(0, 0, 0)
@@ -249,6 +329,43 @@ func NewContractSet(dir string, rl *ratelimit.RateLimit, deps modules.Dependenci
 	}
 	walTxns = remainingTxns
 
+	// Finish any refcounter renames that were interrupted, the refcounters
+	// are loaded from their new paths below.
+	remainingTxns = nil
+	for _, txn := range walTxns {
+		// txn with rename updates contain exactly one update and are named
+		// 'updateNameRCRename'.
+		if len(txn.Updates) != 1 || txn.Updates[0].Name != updateNameRCRename {
+			remainingTxns = append(remainingTxns, txn)
+			continue
+		}
+		if err := applyRenameUpdate(txn.Updates[0]); err != nil {
+			return nil, errors.AddContext(err, "failed to apply refcounter rename update on startup")
+		}
+		if err := txn.SignalUpdatesApplied(); err != nil {
+			return nil, errors.AddContext(err, "failed to apply refcounter rename update on startup")
+		}
+	}
+	walTxns = remainingTxns
+
+	// Replay any other refcounter transactions that were interrupted before
+	// the refcounters are loaded. Transactions that also update a contract
+	// are applied when the contract is loaded.
+	remainingTxns = nil
+	for _, txn := range walTxns {
+		if !isRefCounterTxn(txn) {
+			remainingTxns = append(remainingTxns, txn)
+			continue
+		}
+		if err := ApplyUpdates(txn.Updates...); err != nil {
+			return nil, errors.AddContext(err, "failed to apply refcounter updates on startup")
+		}
+		if err := txn.SignalUpdatesApplied(); err != nil {
+			return nil, errors.AddContext(err, "failed to apply refcounter updates on startup")
+		}
+	}
+	walTxns = remainingTxns
+
 	// Check for legacy contracts and split them up.
 	if err := cs.managedV146SplitContractHeaderAndRoots(dir); err != nil {
 		return nil, err
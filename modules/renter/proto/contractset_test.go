@@ -413,3 +413,90 @@ func TestInsertContractTotalCost(t *testing.T) {
 		t.Fatal("wrong TotalCost", contract.TotalCost, expectedTotalCost)
 	}
 }
+
+// TestContractSetAuditRefCounters verifies that the audit reports the sectors
+// on which the refcounter of a contract and its sector roots disagree.
+func TestContractSetAuditRefCounters(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	testDir := build.TempDir(t.Name())
+	rl := ratelimit.NewRateLimit(0, 0, 0)
+	cs, err := NewContractSet(testDir, rl, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cs.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	newHeader := func(id byte) contractHeader {
+		return contractHeader{Transaction: types.Transaction{
+			FileContractRevisions: []types.FileContractRevision{{
+				ParentID:             types.FileContractID{id},
+				NewValidProofOutputs: []types.SiacoinOutput{{}, {}},
+				UnlockConditions: types.UnlockConditions{
+					PublicKeys: []types.SiaPublicKey{{}, {}},
+				},
+			}},
+		}}
+	}
+	roots := []crypto.Hash{{1}, {2}, {3}}
+	for _, id := range []byte{1, 2} {
+		if _, err := cs.managedInsertContract(newHeader(id), roots); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// consistent contracts have no mismatches
+	mismatches, err := cs.AuditRefCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Fatal("unexpected mismatches", mismatches)
+	}
+
+	// give the first contract an extra count and drop two counts of the second
+	// one
+	update := func(id byte, fn func(rc *refCounter) (writeaheadlog.Update, error)) {
+		c := cs.managedMustAcquire(t, types.FileContractID{id})
+		defer cs.Return(c)
+		if err := c.staticRC.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		u, err := fn(c.staticRC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.staticRC.callCreateAndApplyTransaction(u); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.staticRC.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update(1, (*refCounter).callAppend)
+	update(2, func(rc *refCounter) (writeaheadlog.Update, error) { return rc.callDropSectors(2) })
+
+	mismatches, err = cs.AuditRefCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		id     byte
+		secIdx uint64
+	}{{1, 3}, {2, 1}, {2, 2}}
+	if len(mismatches) != len(expected) {
+		t.Fatal("unexpected mismatches", mismatches)
+	}
+	for i, e := range expected {
+		m := mismatches[i]
+		if m.ContractID != (types.FileContractID{e.id}) || m.SectorIndex != e.secIdx || m.Reason == "" {
+			t.Fatalf("unexpected mismatch %v: %+v", i, m)
+		}
+	}
+}
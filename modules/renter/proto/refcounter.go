@@ -3,9 +3,13 @@ package proto
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
 	"os"
+	"sort"
 	"sync"
+	"time"
 
 	siasync "go.sia.tech/siad/sync"
 
@@ -17,40 +21,124 @@ import (
 )
 
 var (
+	// ErrAccessTimesNotTracked is returned when the last access time of a
+	// sector is requested from a refcounter that doesn't track access times.
+	ErrAccessTimesNotTracked = errors.New("refcounter does not track access times")
+
+	// ErrCorruptRefCounter is returned when the refcounter fails its
+	// consistency check.
+	ErrCorruptRefCounter = errors.New("refcounter is corrupt")
+
 	// ErrInvalidHeaderData is returned when we try to deserialize the header from
-	// a []byte with incorrect data
+	// a []byte with incorrect data. Like ErrCorruptRefCounter, it means that
+	// the refcounter is corrupt.
 	ErrInvalidHeaderData = errors.New("invalid header data")
 
 	// ErrInvalidSectorNumber is returned when the requested sector doesnt' exist
 	ErrInvalidSectorNumber = errors.New("invalid sector given - it does not exist")
 
 	// ErrInvalidVersion is returned when the version of the file we are trying to
-	// read does not match the current refCounterHeaderSize
+	// read is neither the current version nor a version that can be migrated
 	ErrInvalidVersion = errors.New("invalid file version")
 
 	// ErrInvalidUpdateInstruction is returned when trying to parse a WAL update
 	// instruction that is too short to possibly contain all the required data.
 	ErrInvalidUpdateInstruction = errors.New("instructions slice is too short to contain the required data")
 
+	// ErrMisalignedFile is returned when the counter region of a refcounter's
+	// file isn't a multiple of the size of a counter, e.g. because a write or
+	// truncation of the file was interrupted.
+	ErrMisalignedFile = errors.New("refcounter file ends with a partial counter")
+
+	// ErrDuplicateSectorIndex is returned when a batch of sectors to remove
+	// contains the same sector more than once.
+	ErrDuplicateSectorIndex = errors.New("duplicate sector index")
+
+	// ErrMmapNotSupported is returned when trying to memory-map a refcounter
+	// on an operating system that isn't supported.
+	ErrMmapNotSupported = errors.New("memory-mapping refcounters is not supported on this operating system")
+
+	// ErrOverlappingSwapPairs is returned when a batch of swaps contains the
+	// same sector in more than one pair.
+	ErrOverlappingSwapPairs = errors.New("swap pairs overlap")
+
+	// ErrReadOnly is returned when a refcounter that was opened read-only is
+	// asked to change its files.
+	ErrReadOnly = errors.New("refcounter was opened read-only")
+
 	// ErrRefCounterNotExist is returned when there is no refcounter file with
 	// the given path
 	ErrRefCounterNotExist = errors.New("refcounter does not exist")
 
+	// ErrRefCounterOverflow is returned when trying to increment the count of
+	// a sector beyond math.MaxUint16. The count isn't changed, wrapping around
+	// to zero would mark a sector that is still referenced as garbage.
+	ErrRefCounterOverflow = errors.New("sector count overflow")
+
+	// ErrRefCounterUnderflow is returned when trying to decrement the count
+	// of a sector that is already at zero.
+	ErrRefCounterUnderflow = errors.New("sector count underflow")
+
+	// ErrRefCounterRenameTargetExists is returned when trying to rename a
+	// refcounter to a path where a file already exists.
+	ErrRefCounterRenameTargetExists = errors.New("refcounter rename target already exists")
+
+	// ErrRenameDuringUpdate is returned when trying to rename a refcounter
+	// while an update session is open.
+	ErrRenameDuringUpdate = errors.New("refcounter cannot be renamed during an update session")
+
+	// ErrUpgradeDuringUpdate is returned when trying to upgrade a refcounter
+	// while an update session is open.
+	ErrUpgradeDuringUpdate = errors.New("refcounter cannot be upgraded during an update session")
+
+	// ErrRebuildDuringUpdate is returned when a refcounter is rebuilt while
+	// there is an update session in progress.
+	ErrRebuildDuringUpdate = errors.New("refcounter cannot be rebuilt during an update session")
+
+	// ErrRepairDuringUpdate is returned when a refcounter is repaired while
+	// there is an update session in progress.
+	ErrRepairDuringUpdate = errors.New("refcounter cannot be repaired during an update session")
+
+	// ErrResetBeforeDelete is returned when trying to reset a refcounter that
+	// wasn't deleted.
+	ErrResetBeforeDelete = errors.New("only deleted refcounters can be reset")
+
+	// ErrResetDuringUpdate is returned when trying to reset a refcounter while
+	// an update session is open. This includes the session that deleted the
+	// refcounter, it needs to be applied before the refcounter can be reset.
+	ErrResetDuringUpdate = errors.New("refcounter cannot be reset during an update session")
+
 	// ErrUpdateWithoutUpdateSession is returned when an update operation is
 	// called without an open update session
 	ErrUpdateWithoutUpdateSession = errors.New("an update operation was called without an open update session")
 
+	// ErrUnknownUpdate is returned when a WAL update that isn't a refcounter
+	// update is applied to a refcounter, or when an update is passed to a
+	// helper that applies updates of a different type.
+	ErrUnknownUpdate = errors.New("unknown refcounter update")
+
 	// ErrUpdateAfterDelete is returned when an update operation is attempted to
 	// be created after a delete
 	ErrUpdateAfterDelete = errors.New("updates cannot be created after a deletion")
 
-	// refCounterVersion defines the latest version of the refCounter
-	refCounterVersion = [8]byte{1}
+	// refCounterVersion defines the latest version of the refCounter. Since
+	// version 2 every refcounter has a checksum file which is verified on
+	// load.
+	refCounterVersion = [8]byte{2}
+
+	// refCounterVersionLegacy is the version of refcounters without a
+	// checksum file. They are loaded without verifying any checksums and are
+	// migrated to the current version with callUpgrade.
+	refCounterVersionLegacy = [8]byte{1}
 
 	// updateNameRCDelete is the name of an idempotent update that deletes a file
 	// from the disk.
 	updateNameRCDelete = "RC_DELETE"
 
+	// updateNameRCRename is the name of an idempotent update that renames a
+	// refcounter file and its access time file.
+	updateNameRCRename = "RC_RENAME"
+
 	// updateNameRCTruncate is the name of an idempotent update that truncates a
 	// refcounter file by a number of sectors.
 	updateNameRCTruncate = "RC_TRUNCATE"
@@ -58,11 +146,41 @@ var (
 	// updateNameRCWriteAt is the name of an idempotent update that writes a
 	// value to a position in the file.
 	updateNameRCWriteAt = "RC_WRITE_AT"
+
+	// updateNameRCWriteRangeAt is the name of an idempotent update that writes
+	// the values of a range of adjacent sectors to the file.
+	updateNameRCWriteRangeAt = "RC_WRITE_RANGE_AT"
+
+	// updateNameRCWriteChecksums is the name of an idempotent update that
+	// writes the checksums of pages of the counter area to the checksum file.
+	updateNameRCWriteChecksums = "RC_WRITE_CHECKSUMS"
+
+	// updateNameRCWriteAccessAt is the name of an idempotent update that
+	// writes a sector's last access time to the access time file.
+	updateNameRCWriteAccessAt = "RC_WRITE_ACCESS_AT"
 )
 
 const (
 	// refCounterHeaderSize is the size of the header in bytes
 	refCounterHeaderSize = 8
+
+	// refCounterMaxSectors is the maximum number of sectors a refcounter can
+	// track. The offsets of every counter and access time need to fit into an
+	// int64 to be written to the files.
+	refCounterMaxSectors = (math.MaxInt64 - refCounterHeaderSize) / refCounterAccessTimeSize
+
+	// refCounterAccessTimeSize is the size of a single access time entry in
+	// the access time file in bytes. Access times are stored as unix
+	// timestamps with second granularity.
+	refCounterAccessTimeSize = 4
+
+	// refCounterForEachBatchSize is the number of counts callForEach reads from
+	// the refcounter file at once.
+	refCounterForEachBatchSize = 1 << 15
+
+	// refCounterAccessTimeSuffix is the suffix appended to the refcounter's
+	// path to get the path of its access time file.
+	refCounterAccessTimeSuffix = ".atime"
 )
 
 type (
@@ -79,7 +197,48 @@ type (
 		filepath   string // where the refcounter is persisted on disk
 		numSectors uint64 // used for sanity checks before we attempt mutation operations
 		staticWal  *writeaheadlog.WAL
-		mu         sync.Mutex
+
+		// mu protects the fields of the refcounter. Reading counts only
+		// requires a read lock, which allows any number of readers to read
+		// counts concurrently, also while an update session is being
+		// prepared. Readers see the pending counts of the session. Creating
+		// and applying updates requires the write lock and an open update
+		// session. callUpdateApplied and callAbortUpdate clear the pending
+		// counts while holding the write lock, so a reader either sees a
+		// pending count or the count that is on disk after the session.
+		mu sync.RWMutex
+
+		// staticTrackAccess indicates whether the refcounter keeps track of the
+		// last access time of every sector in a sibling file. This is opt-in
+		// at creation to avoid bloating existing refcounters.
+		staticTrackAccess bool
+
+		// staticMemory is set for in-memory refcounters. It replaces the file
+		// on disk, updates are applied to it without going through the WAL.
+		staticMemory *refCounterMemory
+
+		// staticPreload is set for preloaded refcounters. It holds a copy of
+		// the refcounter's file which counts are read from instead of the
+		// disk. It is updated together with the file whenever a transaction
+		// is applied.
+		staticPreload *refCounterMemory
+
+		// mmap is set for refcounters that read their counts from a read-only
+		// memory mapping of their file. Writes still go through the WAL and
+		// the file. The file is remapped whenever its size changes. If
+		// remapping fails, the refcounter falls back to reading from disk.
+		mmap *refCounterMmap
+
+		// staticReadOnly is set for refcounters that were opened with
+		// LoadRefCounterReadOnly. Their files are only ever opened for
+		// reading and all methods that change them return ErrReadOnly. The
+		// files might be changed concurrently by the process that owns them.
+		staticReadOnly bool
+
+		// staticAlerter is used to register an alert when an underflow of a
+		// sector's count is detected. It is optional, without an alerter the
+		// underflow is only reported through the returned error.
+		staticAlerter *modules.GenericAlerter
 
 		// utility fields
 		staticDeps modules.Dependencies
@@ -105,18 +264,72 @@ type (
 		// update session, so we can use them even before they are stored on
 		// disk
 		newSectorCounts map[uint64]uint16
+		// newAccessTimes holds the new access times of sectors during an
+		// update session. They are written to disk alongside the next
+		// transaction that is applied.
+		newAccessTimes map[uint64]uint32
+		// sessionNumSectors is the number of sectors on disk at the start of
+		// the update session or after the most recently applied transaction.
+		// Aborting an update session reverts numSectors to this value.
+		sessionNumSectors uint64
+		// numGarbage is the number of sectors with a count of zero, including
+		// the changes of the pending updates. It is counted once when the
+		// refcounter is loaded and kept up to date by every update that is
+		// created afterwards. sessionNumGarbage is the number of garbage
+		// sectors on disk, aborting an update session reverts numGarbage to
+		// it.
+		numGarbage        uint64
+		sessionNumGarbage uint64
 
 		// muUpdate serializes updates to the refcounter. It is acquired by
 		// callStartUpdate and released by callUpdateApplied.
 		muUpdate siasync.TryMutex
 	}
 
+	// refCounterFile is the subset of the modules.File interface that is
+	// required to read the counters of a refcounter. It is implemented by the
+	// refcounter's file on disk as well as by the refCounterMemory of an
+	// in-memory refcounter.
+	refCounterFile interface {
+		io.ReaderAt
+		io.Closer
+	}
+
+	// refCounterMemory is the in-memory replacement of a refcounter file. It
+	// holds the same bytes that would be stored on disk. It is protected by
+	// the refcounter's mutex.
+	refCounterMemory struct {
+		data []byte
+	}
+
+	// refCounterMmap is a read-only memory mapping of a refcounter file. It is
+	// protected by the refcounter's mutex.
+	refCounterMmap struct {
+		data []byte
+	}
+
 	// u16 is a utility type for ser/des of uint16 values
 	u16 [2]byte
 )
 
-// loadRefCounter loads a refcounter from disk
-func loadRefCounter(path string, wal *writeaheadlog.WAL) (_ *refCounter, err error) {
+// loadRefCounter loads a refcounter from disk. A file that ends with a partial
+// counter is rejected with ErrMisalignedFile, it can be opened with
+// openRefCounter and repaired with callRepair.
+func loadRefCounter(path string, wal *writeaheadlog.WAL) (*refCounter, error) {
+	rc, err := openRefCounter(path, wal)
+	if err != nil {
+		return nil, err
+	}
+	if err = rc.validate(); err != nil {
+		return nil, errors.AddContext(err, "failed to validate refcounter")
+	}
+	return rc, nil
+}
+
+// openRefCounter opens a refcounter from disk without validating it. The
+// number of sectors is derived from the size of the file, a trailing partial
+// counter is ignored.
+func openRefCounter(path string, wal *writeaheadlog.WAL) (_ *refCounter, err error) {
 	// Open the file and start loading the data.
 	f, err := os.Open(path)
 	if err != nil {
@@ -134,49 +347,147 @@ func loadRefCounter(path string, wal *writeaheadlog.WAL) (_ *refCounter, err err
 	if err = deserializeHeader(headerBytes, &header); err != nil {
 		return nil, errors.AddContext(err, "unable to load refcounter header")
 	}
-	if header.Version != refCounterVersion {
+	if !refCounterVersionSupported(header.Version) {
 		return nil, errors.AddContext(ErrInvalidVersion, fmt.Sprintf("expected version %d, got version %d", refCounterVersion, header.Version))
 	}
-	fi, err := os.Stat(path)
+	fi, err := f.Stat()
 	if err != nil {
 		return nil, errors.AddContext(err, "failed to read file stats")
 	}
 	numSectors := uint64((fi.Size() - refCounterHeaderSize) / 2)
-	return &refCounter{
-		refCounterHeader: header,
-		filepath:         path,
-		numSectors:       numSectors,
-		staticWal:        wal,
-		staticDeps:       modules.ProdDependencies,
+	// Access times are tracked if the refcounter was created with an access
+	// time file.
+	_, err = os.Stat(accessTimeFilePath(path))
+	trackAccess := err == nil
+	rc := &refCounter{
+		refCounterHeader:  header,
+		filepath:          path,
+		numSectors:        numSectors,
+		staticWal:         wal,
+		staticTrackAccess: trackAccess,
+		staticDeps:        modules.ProdDependencies,
 		refCounterUpdateControl: refCounterUpdateControl{
 			newSectorCounts: make(map[uint64]uint16),
+			newAccessTimes:  make(map[uint64]uint32),
 		},
-	}, nil
+	}
+	// Count the garbage sectors once, afterwards the count is kept up to
+	// date by the updates.
+	err = rc.callForEach(func(_ uint64, count uint16) error {
+		if count == 0 {
+			rc.numGarbage++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to count garbage sectors")
+	}
+	rc.sessionNumGarbage = rc.numGarbage
+	return rc, nil
 }
 
-// newCustomRefCounter creates a new sector reference counter file to accompany
-// a contract file and allows setting custom dependencies
-func newCustomRefCounter(path string, numSec uint64, wal *writeaheadlog.WAL, deps modules.Dependencies) (*refCounter, error) {
+// loadPreloadedRefCounter loads a refcounter from disk and reads all of its
+// counts into memory. Counting sectors doesn't touch the disk anymore, which
+// speeds up callers that read many counts at the cost of 2 bytes of memory per
+// sector.
+func loadPreloadedRefCounter(path string, wal *writeaheadlog.WAL) (*refCounter, error) {
+	rc, err := loadRefCounter(path, wal)
+	if err != nil {
+		return nil, err
+	}
+	if err = rc.preload(); err != nil {
+		return nil, errors.AddContext(err, "failed to preload refcounter")
+	}
+	return rc, nil
+}
+
+// loadMmapRefCounter loads a refcounter from disk and memory-maps its file.
+// Counts are read from the mapping instead of issuing a syscall for every read,
+// which speeds up read-heavy workloads. The mapping is released by callClose.
+func loadMmapRefCounter(path string, wal *writeaheadlog.WAL) (*refCounter, error) {
+	rc, err := loadRefCounter(path, wal)
+	if err != nil {
+		return nil, err
+	}
+	data, err := mmapRefCounterFile(path)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to memory-map refcounter")
+	}
+	rc.mmap = &refCounterMmap{data: data}
+	return rc, nil
+}
+
+// createRefCounterUpdates returns the updates that create the files of a new
+// refcounter with numSec sectors that each have a count of 1.
+func createRefCounterUpdates(path string, numSec uint64, trackAccess bool) []writeaheadlog.Update {
+	return createRefCounterDataUpdates(path, newRefCounterData(numSec), trackAccess)
+}
+
+// createRefCounterDataUpdates returns the updates that create the files of a
+// new refcounter with the given content.
+func createRefCounterDataUpdates(path string, data []byte, trackAccess bool) []writeaheadlog.Update {
+	numSec := uint64(len(data)-refCounterHeaderSize) / 2
+	updateHeader := writeaheadlog.WriteAtUpdate(path, 0, data[:refCounterHeaderSize])
+	updateCounters := writeaheadlog.WriteAtUpdate(path, refCounterHeaderSize, data[refCounterHeaderSize:])
+	updateChecksums := writeaheadlog.WriteAtUpdate(checksumFilePath(path), 0, refCounterChecksums(data))
+	updates := []writeaheadlog.Update{updateHeader, updateCounters, updateChecksums}
+
+	// All sectors are considered to be accessed at creation.
+	if trackAccess {
+		now := accessTimeNow()
+		at := make([]byte, numSec*refCounterAccessTimeSize)
+		for i := uint64(0); i < numSec; i++ {
+			binary.LittleEndian.PutUint32(at[i*refCounterAccessTimeSize:], now)
+		}
+		updates = append(updates, writeaheadlog.WriteAtUpdate(accessTimeFilePath(path), 0, at))
+	}
+	return updates
+}
+
+// newRefCounterData returns the contents of a new refcounter file with numSec
+// sectors that each have a count of 1.
+func newRefCounterData(numSec uint64) []byte {
 	h := refCounterHeader{
 		Version: refCounterVersion,
 	}
-	updateHeader := writeaheadlog.WriteAtUpdate(path, 0, serializeHeader(h))
-
-	b := make([]byte, numSec*2)
+	data := make([]byte, refCounterHeaderSize+numSec*2)
+	copy(data, serializeHeader(h))
 	for i := uint64(0); i < numSec; i++ {
-		binary.LittleEndian.PutUint16(b[i*2:i*2+2], 1)
+		binary.LittleEndian.PutUint16(data[offset(i):], 1)
 	}
-	updateCounters := writeaheadlog.WriteAtUpdate(path, refCounterHeaderSize, b)
+	return data
+}
+
+// newCustomRefCounter creates a new sector reference counter file to accompany
+// a contract file and allows setting custom dependencies
+func newCustomRefCounter(path string, numSec uint64, wal *writeaheadlog.WAL, deps modules.Dependencies) (*refCounter, error) {
+	return createRefCounter(path, numSec, wal, deps, false)
+}
 
-	err := wal.CreateAndApplyTransaction(writeaheadlog.ApplyUpdates, updateHeader, updateCounters)
+// newRefCounterWithAccessTimes creates a new sector reference counter file
+// which also tracks the last access time of every sector.
+func newRefCounterWithAccessTimes(path string, numSec uint64, wal *writeaheadlog.WAL) (*refCounter, error) {
+	return createRefCounter(path, numSec, wal, modules.ProdDependencies, true)
+}
+
+// createRefCounter creates a new sector reference counter file. If trackAccess
+// is set, an access time file is created alongside it.
+func createRefCounter(path string, numSec uint64, wal *writeaheadlog.WAL, deps modules.Dependencies, trackAccess bool) (*refCounter, error) {
+	h := refCounterHeader{
+		Version: refCounterVersion,
+	}
+	updates := createRefCounterUpdates(path, numSec, trackAccess)
+	err := wal.CreateAndApplyTransaction(writeaheadlog.ApplyUpdates, updates...)
 	return &refCounter{
-		refCounterHeader: h,
-		filepath:         path,
-		numSectors:       numSec,
-		staticWal:        wal,
-		staticDeps:       deps,
+		refCounterHeader:  h,
+		filepath:          path,
+		numSectors:        numSec,
+		staticWal:         wal,
+		staticTrackAccess: trackAccess,
+		staticDeps:        deps,
 		refCounterUpdateControl: refCounterUpdateControl{
 			newSectorCounts: make(map[uint64]uint16),
+			newAccessTimes:  make(map[uint64]uint32),
 		},
 	}, err
 }
@@ -187,9 +498,72 @@ func newRefCounter(path string, numSec uint64, wal *writeaheadlog.WAL) (*refCoun
 	return newCustomRefCounter(path, numSec, wal, modules.ProdDependencies)
 }
 
+// newPreloadedRefCounter creates a new sector reference counter file and keeps
+// a copy of its counts in memory, see loadPreloadedRefCounter.
+func newPreloadedRefCounter(path string, numSec uint64, wal *writeaheadlog.WAL) (*refCounter, error) {
+	rc, err := newRefCounter(path, numSec, wal)
+	if err != nil {
+		return rc, err
+	}
+	if err = rc.preload(); err != nil {
+		return nil, errors.AddContext(err, "failed to preload refcounter")
+	}
+	return rc, nil
+}
+
+// newInMemoryRefCounter creates a new sector reference counter which is backed
+// by memory instead of a file. Updates are applied without a WAL and nothing
+// is persisted. Access times are not tracked.
+func newInMemoryRefCounter(numSec uint64) *refCounter {
+	h := refCounterHeader{
+		Version: refCounterVersion,
+	}
+	return &refCounter{
+		refCounterHeader: h,
+		numSectors:       numSec,
+		staticDeps:       modules.ProdDependencies,
+		staticMemory:     &refCounterMemory{data: newRefCounterData(numSec)},
+		refCounterUpdateControl: refCounterUpdateControl{
+			newSectorCounts: make(map[uint64]uint16),
+			newAccessTimes:  make(map[uint64]uint32),
+		},
+	}
+}
+
+// callAbortUpdate closes the current update session without applying the
+// updates that were created during it. The in-memory overrides are dropped and
+// numSectors is reverted to the number of sectors on disk. Nothing is written
+// to disk. A session that created a delete update can't be aborted.
+func (rc *refCounter) callAbortUpdate() error {
+	if rc.staticReadOnly {
+		return ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.isUpdateInProgress {
+		return ErrUpdateWithoutUpdateSession
+	}
+	if rc.isDeleted {
+		return ErrUpdateAfterDelete
+	}
+	// drop the pending changes
+	rc.numSectors = rc.sessionNumSectors
+	rc.numGarbage = rc.sessionNumGarbage
+	rc.newSectorCounts = make(map[uint64]uint16)
+	rc.newAccessTimes = make(map[uint64]uint32)
+	// close the update session
+	rc.isUpdateInProgress = false
+	// release the update lock
+	rc.muUpdate.Unlock()
+	return nil
+}
+
 // callAppend appends one counter to the end of the refcounter file and
 // initializes it with `1`
 func (rc *refCounter) callAppend() (writeaheadlog.Update, error) {
+	if rc.staticReadOnly {
+		return writeaheadlog.Update{}, ErrReadOnly
+	}
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 	if !rc.isUpdateInProgress {
@@ -200,21 +574,86 @@ func (rc *refCounter) callAppend() (writeaheadlog.Update, error) {
 	}
 	rc.numSectors++
 	rc.newSectorCounts[rc.numSectors-1] = 1
+	rc.touch(rc.numSectors - 1)
 	return createWriteAtUpdate(rc.filepath, rc.numSectors-1, 1), nil
 }
 
-// callCount returns the number of references to the given sector
-func (rc *refCounter) callCount(secIdx uint64) (uint16, error) {
+// callClose releases the resources held by the refcounter. Memory-mapped
+// refcounters release their mapping and read from disk afterwards.
+func (rc *refCounter) callClose() error {
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
+	return rc.unmap()
+}
+
+// callCount returns the number of references to the given sector
+func (rc *refCounter) callCount(secIdx uint64) (uint16, error) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
 	return rc.readCount(secIdx)
 }
 
+// callCountRange returns the counts of the sectors in the range [start, end).
+// The counts are read from disk at once and overlaid with the counts of any
+// pending updates.
+func (rc *refCounter) callCountRange(start, end uint64) (_ []uint16, err error) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	if start > end || end > rc.numSectors {
+		return nil, errors.AddContext(ErrInvalidSectorNumber, "failed to read count range")
+	}
+	if start == end {
+		return []uint16{}, nil
+	}
+	// read the range from disk
+	f, err := rc.openFile()
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to open the refcounter file")
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	// sectors appended by a pending update are not on disk yet, their counts
+	// are taken from the pending update
+	b := make([]byte, (end-start)*2)
+	n, err := f.ReadAt(b, int64(offset(start)))
+	if err != nil && !errors.Contains(err, io.EOF) {
+		return nil, errors.AddContext(err, "failed to read from refcounter file")
+	}
+	counts := make([]uint16, end-start)
+	for i := 0; i < n/2; i++ {
+		counts[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	// overlay the values that are being changed by a pending update
+	for secIdx, count := range rc.newSectorCounts {
+		if secIdx >= start && secIdx < end {
+			counts[secIdx-start] = count
+		}
+	}
+	return counts, nil
+}
+
 // callCreateAndApplyTransaction is a helper method that creates a writeaheadlog
 // transaction and applies it.
 func (rc *refCounter) callCreateAndApplyTransaction(updates ...writeaheadlog.Update) error {
+	if rc.staticReadOnly {
+		return ErrReadOnly
+	}
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
+	// In-memory refcounters apply the updates directly.
+	if rc.staticMemory != nil {
+		if !rc.isUpdateInProgress {
+			return ErrUpdateWithoutUpdateSession
+		}
+		if err := rc.staticMemory.applyUpdates(updates...); err != nil {
+			return errors.AddContext(err, "failed to apply updates")
+		}
+		rc.numSectors = rc.staticMemory.numSectors()
+		rc.sessionNumSectors = rc.numSectors
+		rc.sessionNumGarbage = rc.numGarbage
+		return nil
+	}
 	// We allow the creation of the file here because of the case where we got
 	// interrupted during the creation of the refcounter after writing the
 	// header update to the Wal but before applying it.
@@ -228,6 +667,22 @@ func (rc *refCounter) callCreateAndApplyTransaction(updates ...writeaheadlog.Upd
 	if !rc.isUpdateInProgress {
 		return ErrUpdateWithoutUpdateSession
 	}
+	// Write the checksums of the changed pages and any pending access times
+	// alongside the updates. The preloaded counts only need the refcounter's
+	// own updates.
+	rcUpdates := updates
+	var extraUpdates []writeaheadlog.Update
+	if rc.Version == refCounterVersion && !rc.isDeleted {
+		u, err := rc.checksumUpdate(f, updates)
+		if err != nil {
+			return errors.AddContext(err, "failed to compute checksums")
+		}
+		extraUpdates = append(extraUpdates, u)
+	}
+	extraUpdates = append(extraUpdates, rc.accessTimeUpdates()...)
+	if len(extraUpdates) > 0 {
+		updates = append(append([]writeaheadlog.Update{}, updates...), extraUpdates...)
+	}
 	// Create the writeaheadlog transaction.
 	txn, err := rc.staticWal.NewTransaction(updates)
 	if err != nil {
@@ -255,14 +710,29 @@ func (rc *refCounter) callCreateAndApplyTransaction(updates ...writeaheadlog.Upd
 	}
 	// If the refcounter got deleted then we're done.
 	if rc.isDeleted {
-		return nil
+		return rc.unmap()
 	}
+	// Keep the preloaded counts in sync with the file. They are updated while
+	// holding the lock so readers never see a state the disk doesn't have.
+	if rc.staticPreload != nil {
+		if err = rc.staticPreload.applyUpdates(rcUpdates...); err != nil {
+			return errors.AddContext(err, "failed to apply updates to the preloaded counts")
+		}
+	}
+	// The pending access times are on disk now.
+	rc.newAccessTimes = make(map[uint64]uint32)
 	// Update the in-memory helper fields.
 	fi, err := os.Stat(rc.filepath)
 	if err != nil {
 		return errors.AddContext(err, "failed to read from disk after updates")
 	}
 	rc.numSectors = uint64((fi.Size() - refCounterHeaderSize) / 2)
+	rc.sessionNumSectors = rc.numSectors
+	rc.sessionNumGarbage = rc.numGarbage
+	// The mapping needs to cover the new size of the file.
+	if rc.mmap != nil && int64(len(rc.mmap.data)) != fi.Size() {
+		rc.remap()
+	}
 	return nil
 }
 
@@ -270,6 +740,9 @@ func (rc *refCounter) callCreateAndApplyTransaction(updates ...writeaheadlog.Upd
 // is specified by its sequential number (secIdx).
 // Returns the updated number of references or an error.
 func (rc *refCounter) callDecrement(secIdx uint64) (writeaheadlog.Update, error) {
+	if rc.staticReadOnly {
+		return writeaheadlog.Update{}, ErrReadOnly
+	}
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 	if !rc.isUpdateInProgress {
@@ -286,8 +759,13 @@ func (rc *refCounter) callDecrement(secIdx uint64) (writeaheadlog.Update, error)
 		return writeaheadlog.Update{}, errors.AddContext(err, "failed to read count from decrement")
 	}
 	if count == 0 {
-		return writeaheadlog.Update{}, errors.New("sector count underflow")
+		err := errors.AddContext(ErrRefCounterUnderflow, fmt.Sprintf("failed to decrement sector %v of refcounter '%v'", secIdx, rc.filepath))
+		if rc.staticAlerter != nil {
+			rc.staticAlerter.RegisterAlert(modules.AlertIDRenterRefCounterUnderflow, AlertMSGRefCounterUnderflow, err.Error(), modules.SeverityError)
+		}
+		return writeaheadlog.Update{}, err
 	}
+	rc.trackGarbage(count, count-1)
 	count--
 	rc.newSectorCounts[secIdx] = count
 	return createWriteAtUpdate(rc.filepath, secIdx, count), nil
@@ -295,6 +773,9 @@ func (rc *refCounter) callDecrement(secIdx uint64) (writeaheadlog.Update, error)
 
 // callDeleteRefCounter deletes the counter's file from disk
 func (rc *refCounter) callDeleteRefCounter() (writeaheadlog.Update, error) {
+	if rc.staticReadOnly {
+		return writeaheadlog.Update{}, ErrReadOnly
+	}
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 	if !rc.isUpdateInProgress {
@@ -310,6 +791,9 @@ func (rc *refCounter) callDeleteRefCounter() (writeaheadlog.Update, error) {
 
 // callDropSectors removes the last numSec sector counts from the refcounter file
 func (rc *refCounter) callDropSectors(numSec uint64) (writeaheadlog.Update, error) {
+	if rc.staticReadOnly {
+		return writeaheadlog.Update{}, ErrReadOnly
+	}
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 	if !rc.isUpdateInProgress {
@@ -321,14 +805,52 @@ func (rc *refCounter) callDropSectors(numSec uint64) (writeaheadlog.Update, erro
 	if numSec > rc.numSectors {
 		return writeaheadlog.Update{}, errors.AddContext(ErrInvalidSectorNumber, "failed to drop sectors")
 	}
+	// The dropped sectors are no longer garbage.
+	var numGarbage uint64
+	for secIdx := rc.numSectors - numSec; secIdx < rc.numSectors; secIdx++ {
+		count, err := rc.readCount(secIdx)
+		if err != nil {
+			return writeaheadlog.Update{}, errors.AddContext(err, "failed to read count from drop sectors")
+		}
+		if count == 0 {
+			numGarbage++
+		}
+	}
+	rc.numGarbage -= numGarbage
 	rc.numSectors -= numSec
+	// Pending counts of the dropped sectors are no longer valid.
+	for secIdx := range rc.newSectorCounts {
+		if secIdx >= rc.numSectors {
+			delete(rc.newSectorCounts, secIdx)
+		}
+	}
 	return createTruncateUpdate(rc.filepath, rc.numSectors), nil
 }
 
+// callFileSize returns the size of the refcounter's file in bytes.
+func (rc *refCounter) callFileSize() (int64, error) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.fileSize()
+}
+
+// callGarbageCount returns the number of sectors that aren't referenced
+// anymore, including the changes of the pending updates. Unlike
+// callZeroCountSectors it doesn't read the counts, the number is kept up to
+// date by the updates.
+func (rc *refCounter) callGarbageCount() uint64 {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.numGarbage
+}
+
 // callIncrement increments the reference counter of a given sector. The sector
 // is specified by its sequential number (secIdx).
 // Returns the updated number of references or an error.
 func (rc *refCounter) callIncrement(secIdx uint64) (writeaheadlog.Update, error) {
+	if rc.staticReadOnly {
+		return writeaheadlog.Update{}, ErrReadOnly
+	}
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 	if !rc.isUpdateInProgress {
@@ -345,104 +867,831 @@ func (rc *refCounter) callIncrement(secIdx uint64) (writeaheadlog.Update, error)
 		return writeaheadlog.Update{}, errors.AddContext(err, "failed to read count from increment")
 	}
 	if count == math.MaxUint16 {
-		return writeaheadlog.Update{}, errors.New("sector count overflow")
+		return writeaheadlog.Update{}, errors.AddContext(ErrRefCounterOverflow, fmt.Sprintf("failed to increment sector %v of refcounter '%v'", secIdx, rc.filepath))
 	}
+	rc.trackGarbage(count, count+1)
 	count++
 	rc.newSectorCounts[secIdx] = count
+	rc.touch(secIdx)
 	return createWriteAtUpdate(rc.filepath, secIdx, count), nil
 }
 
-// callSetCount sets the value of the reference counter of a given sector. The
-// sector is specified by its sequential number (secIdx).
-func (rc *refCounter) callSetCount(secIdx uint64, c uint16) (writeaheadlog.Update, error) {
-	rc.mu.Lock()
-	defer rc.mu.Unlock()
-	if !rc.isUpdateInProgress {
-		return writeaheadlog.Update{}, ErrUpdateWithoutUpdateSession
-	}
+// callForEach calls fn with the count of every sector of the refcounter in
+// order. The counts are read sequentially in large batches and overlaid with
+// the counts of any pending updates. Iteration stops at the first error
+// returned by fn. The refcounter is read-locked during the iteration, so fn
+// must not call any of its methods.
+func (rc *refCounter) callForEach(fn func(secIdx uint64, count uint16) error) (err error) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
 	if rc.isDeleted {
-		return writeaheadlog.Update{}, ErrUpdateAfterDelete
+		return ErrUpdateAfterDelete
 	}
-	// this allows the client to set multiple new counts in random order
-	if secIdx >= rc.numSectors {
-		rc.numSectors = secIdx + 1
+	if rc.numSectors == 0 {
+		return nil
 	}
-	rc.newSectorCounts[secIdx] = c
-	return createWriteAtUpdate(rc.filepath, secIdx, c), nil
-}
-
-// callStartUpdate acquires a lock, ensuring the caller is the only one currently
-// allowed to perform updates on this refcounter file. This lock is released by
-// calling callUpdateApplied after calling callCreateAndApplyTransaction in
-// order to apply the updates.
-func (rc *refCounter) callStartUpdate() error {
-	rc.muUpdate.Lock()
-	return rc.managedStartUpdate()
-}
-
-// callSwap swaps the two sectors at the given indices
-func (rc *refCounter) callSwap(firstIdx, secondIdx uint64) ([]writeaheadlog.Update, error) {
-	rc.mu.Lock()
-	defer rc.mu.Unlock()
-	if !rc.isUpdateInProgress {
-		return []writeaheadlog.Update{}, ErrUpdateWithoutUpdateSession
+	f, err := rc.openFile()
+	if err != nil {
+		return errors.AddContext(err, "failed to open the refcounter file")
 	}
-	if rc.isDeleted {
-		return []writeaheadlog.Update{}, ErrUpdateAfterDelete
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	batchSize := uint64(refCounterForEachBatchSize)
+	if rc.numSectors < batchSize {
+		batchSize = rc.numSectors
 	}
-	if firstIdx >= rc.numSectors || secondIdx >= rc.numSectors {
-		return []writeaheadlog.Update{}, errors.AddContext(ErrInvalidSectorNumber, "failed to swap sectors")
+	b := make([]byte, batchSize*2)
+	for start := uint64(0); start < rc.numSectors; start += batchSize {
+		end := start + batchSize
+		if end > rc.numSectors {
+			end = rc.numSectors
+		}
+		batch := b[:(end-start)*2]
+		// sectors appended by a pending update are not on disk yet, their
+		// counts are taken from the pending update
+		n, err := f.ReadAt(batch, int64(offset(start)))
+		if err != nil && !errors.Contains(err, io.EOF) {
+			return errors.AddContext(err, "failed to read from refcounter file")
+		}
+		// The file of a read-only refcounter can be truncated by the process
+		// that owns it, the sectors that are gone aren't reported.
+		if rc.staticReadOnly && n < len(batch) {
+			end = start + uint64(n)/2
+		}
+		for i := n; i < len(batch); i++ {
+			batch[i] = 0
+		}
+		for secIdx := start; secIdx < end; secIdx++ {
+			count := binary.LittleEndian.Uint16(batch[(secIdx-start)*2:])
+			if c, ok := rc.newSectorCounts[secIdx]; ok {
+				count = c
+			}
+			if err := fn(secIdx, count); err != nil {
+				return err
+			}
+		}
 	}
-	firstVal, err := rc.readCount(firstIdx)
-	if err != nil {
-		return []writeaheadlog.Update{}, errors.AddContext(err, "failed to read count from swap")
+	return nil
+}
+
+// callLastAccess returns the last time the given sector was accessed. The
+// returned time has second granularity. ErrAccessTimesNotTracked is returned
+// if the refcounter wasn't created with access time tracking.
+func (rc *refCounter) callLastAccess(secIdx uint64) (time.Time, error) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	if !rc.staticTrackAccess {
+		return time.Time{}, ErrAccessTimesNotTracked
 	}
-	secondVal, err := rc.readCount(secondIdx)
+	at, err := rc.readAccessTime(secIdx)
 	if err != nil {
-		return []writeaheadlog.Update{}, errors.AddContext(err, "failed to read count from swap")
+		return time.Time{}, err
 	}
-	rc.newSectorCounts[firstIdx] = secondVal
-	rc.newSectorCounts[secondIdx] = firstVal
-	return []writeaheadlog.Update{
-		createWriteAtUpdate(rc.filepath, firstIdx, secondVal),
-		createWriteAtUpdate(rc.filepath, secondIdx, firstVal),
-	}, nil
+	if at == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(int64(at), 0), nil
 }
 
-// callUpdateApplied cleans up temporary data and releases the update lock, thus
-// allowing other actors to acquire it in order to update the refcounter.
-func (rc *refCounter) callUpdateApplied() error {
-	rc.mu.Lock()
-	defer rc.mu.Unlock()
+// callValidate checks the refcounter for consistency and returns a
+// descriptive error if it is corrupt. It can be called at any time to catch
+// corruption early.
+func (rc *refCounter) callValidate() error {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.validate()
+}
 
-	// this method cannot be called if there is no active update session
-	if !rc.isUpdateInProgress {
-		return ErrUpdateWithoutUpdateSession
-	}
+// callMmapped returns whether the refcounter reads its counts from a memory
+// mapping of its file.
+func (rc *refCounter) callMmapped() bool {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.mmap != nil
+}
 
-	// clean up the temp counts
-	rc.newSectorCounts = make(map[uint64]uint16)
-	// close the update session
-	rc.isUpdateInProgress = false
-	// release the update lock
-	rc.muUpdate.Unlock()
-	return nil
+// callNumSectors returns the number of sectors tracked by the refcounter,
+// including the changes of the pending updates.
+func (rc *refCounter) callNumSectors() uint64 {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.numSectors
 }
 
-// managedStartUpdate does everything callStartUpdate needs, aside from acquiring a
-// lock
-func (rc *refCounter) managedStartUpdate() error {
+// callPreloaded returns whether the refcounter keeps a copy of its counts in
+// memory and serves reads from it instead of the disk.
+func (rc *refCounter) callPreloaded() bool {
+	return rc.staticPreload != nil
+}
+
+// callRename renames the refcounter's backing file, and its access time file
+// if it has one, to the given path. The rename goes through the WAL so that an
+// interrupted rename can be completed on startup. It is not possible to rename
+// the refcounter while an update session is open.
+func (rc *refCounter) callRename(newPath string) (err error) {
+	if rc.staticReadOnly {
+		return ErrReadOnly
+	}
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 	if rc.isDeleted {
 		return ErrUpdateAfterDelete
 	}
-	// open an update session
-	rc.isUpdateInProgress = true
-	return nil
-}
-
-// readCount reads the given sector count either from disk (if there are no
+	if rc.isUpdateInProgress {
+		return ErrRenameDuringUpdate
+	}
+	if newPath == rc.filepath {
+		return nil
+	}
+	// There is no file to rename for in-memory refcounters.
+	if rc.staticMemory != nil {
+		rc.filepath = newPath
+		return nil
+	}
+	// Don't overwrite an existing file.
+	if _, err := os.Stat(newPath); err == nil {
+		return ErrRefCounterRenameTargetExists
+	} else if !os.IsNotExist(err) {
+		return errors.AddContext(err, "failed to check rename target")
+	}
+	// Create the writeaheadlog transaction.
+	u := createRenameUpdate(rc.filepath, newPath)
+	txn, err := rc.staticWal.NewTransaction([]writeaheadlog.Update{u})
+	if err != nil {
+		return errors.AddContext(err, "failed to create wal txn")
+	}
+	if err := <-txn.SignalSetupComplete(); err != nil {
+		return errors.AddContext(err, "failed to signal setup completion")
+	}
+	// Same as in callCreateAndApplyTransaction, once the update is on disk we
+	// need to panic in case applying it fails.
+	defer func() {
+		if err != nil {
+			panic(err)
+		}
+	}()
+	if err = applyRenameUpdate(u); err != nil {
+		return errors.AddContext(err, "failed to apply rename update")
+	}
+	if err = txn.SignalUpdatesApplied(); err != nil {
+		return errors.AddContext(err, "failed to signal that updates are applied")
+	}
+	rc.filepath = newPath
+	return nil
+}
+
+// callRepair truncates a trailing partial counter from the refcounter's file,
+// which is left behind by an interrupted write or truncation of the file. The
+// truncation goes through the WAL. The repaired refcounter is validated, a file
+// that is corrupt in any other way can't be repaired. It is not possible to
+// repair the refcounter while an update session is open.
+func (rc *refCounter) callRepair() error {
+	if rc.staticReadOnly {
+		return ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.isDeleted {
+		return ErrUpdateAfterDelete
+	}
+	if rc.isUpdateInProgress {
+		return ErrRepairDuringUpdate
+	}
+	// The in-memory data is always aligned.
+	if rc.staticMemory != nil {
+		return nil
+	}
+	size, err := rc.fileSize()
+	if err != nil {
+		return errors.AddContext(err, "failed to read file stats")
+	}
+	if size < refCounterHeaderSize {
+		return errors.AddContext(ErrCorruptRefCounter, fmt.Sprintf("file size %d is smaller than the header size %d", size, refCounterHeaderSize))
+	}
+	if (size-refCounterHeaderSize)%2 != 0 {
+		if err := rc.truncateFile(uint64((size - refCounterHeaderSize) / 2)); err != nil {
+			return errors.AddContext(err, "failed to truncate partial counter")
+		}
+	}
+	return rc.validate()
+}
+
+// callReset recreates a deleted refcounter at the same path. Afterwards the
+// refcounter has numSec sectors with a count of 1 and accepts updates again,
+// just like a newly created refcounter. The refcounter can only be reset once
+// the session that deleted it is closed, i.e. after the delete update was
+// applied and callUpdateApplied was called. The files are recreated through
+// the WAL, starting with another delete update that removes anything that was
+// left behind, so an interrupted reset is completed by replaying the WAL. A
+// memory-mapped refcounter reads from disk after being reset.
+func (rc *refCounter) callReset(numSec uint64) error {
+	if rc.staticReadOnly {
+		return ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.isUpdateInProgress {
+		return ErrResetDuringUpdate
+	}
+	if !rc.isDeleted {
+		return ErrResetBeforeDelete
+	}
+	if rc.staticMemory != nil {
+		rc.staticMemory.data = newRefCounterData(numSec)
+	} else {
+		updates := append([]writeaheadlog.Update{createDeleteUpdate(rc.filepath)}, createRefCounterUpdates(rc.filepath, numSec, rc.staticTrackAccess)...)
+		if err := rc.staticWal.CreateAndApplyTransaction(ApplyUpdates, updates...); err != nil {
+			return errors.AddContext(err, "failed to recreate refcounter")
+		}
+		if rc.staticPreload != nil {
+			if err := rc.preload(); err != nil {
+				return errors.AddContext(err, "failed to preload refcounter")
+			}
+		}
+	}
+	rc.Version = refCounterVersion
+	rc.numSectors = numSec
+	rc.sessionNumSectors = numSec
+	rc.numGarbage = 0
+	rc.sessionNumGarbage = 0
+	rc.newSectorCounts = make(map[uint64]uint16)
+	rc.newAccessTimes = make(map[uint64]uint32)
+	rc.isDeleted = false
+	return nil
+}
+
+// callRemoveSectors removes the sectors at the given indices from the
+// refcounter. The removed sectors can be anywhere in the file. Every sector
+// that remains behind the new end of the file is moved into the slot of a
+// removed sector in front of it. This is a swap of the two sectors whose
+// second half is dropped by a single truncation of the file at the end, so
+// only one write per moved sector is needed. The returned map contains the new
+// index of every moved sector by its old index, the indices of all other
+// remaining sectors are unchanged. All indices are validated before any of
+// the updates are created, an index that is out of range or that is given more
+// than once fails the whole batch. The returned updates need to be applied in
+// the same transaction for the removal to be atomic.
+func (rc *refCounter) callRemoveSectors(indices []uint64) ([]writeaheadlog.Update, map[uint64]uint64, error) {
+	if rc.staticReadOnly {
+		return nil, nil, ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.isUpdateInProgress {
+		return []writeaheadlog.Update{}, nil, ErrUpdateWithoutUpdateSession
+	}
+	if rc.isDeleted {
+		return []writeaheadlog.Update{}, nil, ErrUpdateAfterDelete
+	}
+	// Validate all indices before creating any updates.
+	removed := make(map[uint64]struct{}, len(indices))
+	for _, secIdx := range indices {
+		if secIdx >= rc.numSectors {
+			return []writeaheadlog.Update{}, nil, errors.AddContext(ErrInvalidSectorNumber, "failed to remove sectors")
+		}
+		if _, exists := removed[secIdx]; exists {
+			return []writeaheadlog.Update{}, nil, errors.AddContext(ErrDuplicateSectorIndex, fmt.Sprintf("sector %v is removed more than once", secIdx))
+		}
+		removed[secIdx] = struct{}{}
+	}
+	newNumSectors := rc.numSectors - uint64(len(indices))
+	// Pair the removed sectors in front of the new end with the remaining
+	// sectors behind it. Both are sorted to keep the moves deterministic.
+	var holes, moved []uint64
+	for secIdx := range removed {
+		if secIdx < newNumSectors {
+			holes = append(holes, secIdx)
+		}
+	}
+	for secIdx := newNumSectors; secIdx < rc.numSectors; secIdx++ {
+		if _, exists := removed[secIdx]; !exists {
+			moved = append(moved, secIdx)
+		}
+	}
+	sort.Slice(holes, func(i, j int) bool {
+		return holes[i] < holes[j]
+	})
+	// Read all values before changing any of them, so that a failed read
+	// doesn't leave the batch partially applied. The removed sectors are no
+	// longer garbage.
+	var numGarbage uint64
+	for secIdx := range removed {
+		count, err := rc.readCount(secIdx)
+		if err != nil {
+			return []writeaheadlog.Update{}, nil, errors.AddContext(err, "failed to read count from remove sectors")
+		}
+		if count == 0 {
+			numGarbage++
+		}
+	}
+	counts := make([]uint16, len(moved))
+	accessTimes := make([]uint32, len(moved))
+	for i, secIdx := range moved {
+		var err error
+		counts[i], err = rc.readCount(secIdx)
+		if err != nil {
+			return []writeaheadlog.Update{}, nil, errors.AddContext(err, "failed to read count from remove sectors")
+		}
+		if rc.staticTrackAccess {
+			accessTimes[i], err = rc.readAccessTime(secIdx)
+			if err != nil {
+				return []writeaheadlog.Update{}, nil, errors.AddContext(err, "failed to read access time from remove sectors")
+			}
+		}
+	}
+	updates := make([]writeaheadlog.Update, 0, len(moved)+1)
+	remap := make(map[uint64]uint64, len(moved))
+	for i, secIdx := range moved {
+		rc.newSectorCounts[holes[i]] = counts[i]
+		if rc.staticTrackAccess {
+			rc.newAccessTimes[holes[i]] = accessTimes[i]
+		}
+		remap[secIdx] = holes[i]
+		updates = append(updates, createWriteAtUpdate(rc.filepath, holes[i], counts[i]))
+	}
+	// Pending counts of the truncated sectors are no longer valid.
+	rc.numGarbage -= numGarbage
+	rc.numSectors = newNumSectors
+	for secIdx := range rc.newSectorCounts {
+		if secIdx >= rc.numSectors {
+			delete(rc.newSectorCounts, secIdx)
+		}
+	}
+	updates = append(updates, createTruncateUpdate(rc.filepath, rc.numSectors))
+	return updates, remap, nil
+}
+
+// callSetCount sets the value of the reference counter of a given sector. The
+// sector is specified by its sequential number (secIdx). Setting a count
+// directly produces a single update no matter how far the count is off, which
+// makes it the cheapest way to restore counts that were computed elsewhere,
+// e.g. while rebuilding a refcounter. Setting the count of a sector beyond the
+// last one grows the refcounter. The sectors in between have a count of 0 once
+// the update is applied.
+func (rc *refCounter) callSetCount(secIdx uint64, c uint16) (writeaheadlog.Update, error) {
+	if rc.staticReadOnly {
+		return writeaheadlog.Update{}, ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.isUpdateInProgress {
+		return writeaheadlog.Update{}, ErrUpdateWithoutUpdateSession
+	}
+	if rc.isDeleted {
+		return writeaheadlog.Update{}, ErrUpdateAfterDelete
+	}
+	if secIdx >= refCounterMaxSectors {
+		return writeaheadlog.Update{}, errors.AddContext(ErrInvalidSectorNumber, "failed to set count")
+	}
+	// this allows the client to set multiple new counts in random order. The
+	// sectors in between are garbage.
+	if secIdx >= rc.numSectors {
+		rc.numGarbage += secIdx - rc.numSectors
+		if c == 0 {
+			rc.numGarbage++
+		}
+		rc.numSectors = secIdx + 1
+	} else {
+		count, err := rc.readCount(secIdx)
+		if err != nil {
+			return writeaheadlog.Update{}, errors.AddContext(err, "failed to read count from set count")
+		}
+		rc.trackGarbage(count, c)
+	}
+	rc.newSectorCounts[secIdx] = c
+	return createWriteAtUpdate(rc.filepath, secIdx, c), nil
+}
+
+// callStartUpdate acquires a lock, ensuring the caller is the only one currently
+// allowed to perform updates on this refcounter file. This lock is released by
+// calling callUpdateApplied after calling callCreateAndApplyTransaction in
+// order to apply the updates. The lock is released right away if the session
+// can't be started.
+func (rc *refCounter) callStartUpdate() error {
+	if rc.staticReadOnly {
+		return ErrReadOnly
+	}
+	rc.muUpdate.Lock()
+	if err := rc.managedStartUpdate(); err != nil {
+		rc.muUpdate.Unlock()
+		return err
+	}
+	return nil
+}
+
+// callSwap swaps the two sectors at the given indices
+func (rc *refCounter) callSwap(firstIdx, secondIdx uint64) ([]writeaheadlog.Update, error) {
+	if rc.staticReadOnly {
+		return nil, ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.isUpdateInProgress {
+		return []writeaheadlog.Update{}, ErrUpdateWithoutUpdateSession
+	}
+	if rc.isDeleted {
+		return []writeaheadlog.Update{}, ErrUpdateAfterDelete
+	}
+	if firstIdx >= rc.numSectors || secondIdx >= rc.numSectors {
+		return []writeaheadlog.Update{}, errors.AddContext(ErrInvalidSectorNumber, "failed to swap sectors")
+	}
+	firstVal, err := rc.readCount(firstIdx)
+	if err != nil {
+		return []writeaheadlog.Update{}, errors.AddContext(err, "failed to read count from swap")
+	}
+	secondVal, err := rc.readCount(secondIdx)
+	if err != nil {
+		return []writeaheadlog.Update{}, errors.AddContext(err, "failed to read count from swap")
+	}
+	rc.newSectorCounts[firstIdx] = secondVal
+	rc.newSectorCounts[secondIdx] = firstVal
+	// Swap the access times as well.
+	if rc.staticTrackAccess {
+		firstAt, err := rc.readAccessTime(firstIdx)
+		if err != nil {
+			return []writeaheadlog.Update{}, errors.AddContext(err, "failed to read access time from swap")
+		}
+		secondAt, err := rc.readAccessTime(secondIdx)
+		if err != nil {
+			return []writeaheadlog.Update{}, errors.AddContext(err, "failed to read access time from swap")
+		}
+		rc.newAccessTimes[firstIdx] = secondAt
+		rc.newAccessTimes[secondIdx] = firstAt
+	}
+	return []writeaheadlog.Update{
+		createWriteAtUpdate(rc.filepath, firstIdx, secondVal),
+		createWriteAtUpdate(rc.filepath, secondIdx, firstVal),
+	}, nil
+}
+
+// callSwapBatch swaps the sectors of every pair of indices. All indices are
+// validated before any of the swaps are performed and a sector may only be
+// part of a single pair. The returned updates need to be applied in the same
+// transaction for the swaps to be atomic.
+func (rc *refCounter) callSwapBatch(pairs [][2]uint64) ([]writeaheadlog.Update, error) {
+	if rc.staticReadOnly {
+		return nil, ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.isUpdateInProgress {
+		return []writeaheadlog.Update{}, ErrUpdateWithoutUpdateSession
+	}
+	if rc.isDeleted {
+		return []writeaheadlog.Update{}, ErrUpdateAfterDelete
+	}
+	// Validate all indices before creating any updates.
+	seen := make(map[uint64]struct{}, 2*len(pairs))
+	for _, pair := range pairs {
+		if pair[0] >= rc.numSectors || pair[1] >= rc.numSectors {
+			return []writeaheadlog.Update{}, errors.AddContext(ErrInvalidSectorNumber, "failed to swap sectors")
+		}
+		for i, idx := range pair {
+			if i == 1 && pair[0] == pair[1] {
+				break
+			}
+			if _, exists := seen[idx]; exists {
+				return []writeaheadlog.Update{}, errors.AddContext(ErrOverlappingSwapPairs, fmt.Sprintf("sector %v is part of multiple pairs", idx))
+			}
+			seen[idx] = struct{}{}
+		}
+	}
+	// Read all values before changing any of them, so that a failed read
+	// doesn't leave the batch partially applied.
+	counts := make([][2]uint16, len(pairs))
+	accessTimes := make([][2]uint32, len(pairs))
+	for i, pair := range pairs {
+		for j, idx := range pair {
+			var err error
+			counts[i][j], err = rc.readCount(idx)
+			if err != nil {
+				return []writeaheadlog.Update{}, errors.AddContext(err, "failed to read count from swap")
+			}
+			if rc.staticTrackAccess {
+				accessTimes[i][j], err = rc.readAccessTime(idx)
+				if err != nil {
+					return []writeaheadlog.Update{}, errors.AddContext(err, "failed to read access time from swap")
+				}
+			}
+		}
+	}
+	updates := make([]writeaheadlog.Update, 0, 2*len(pairs))
+	for i, pair := range pairs {
+		rc.newSectorCounts[pair[0]] = counts[i][1]
+		rc.newSectorCounts[pair[1]] = counts[i][0]
+		if rc.staticTrackAccess {
+			rc.newAccessTimes[pair[0]] = accessTimes[i][1]
+			rc.newAccessTimes[pair[1]] = accessTimes[i][0]
+		}
+		updates = append(updates,
+			createWriteAtUpdate(rc.filepath, pair[0], counts[i][1]),
+			createWriteAtUpdate(rc.filepath, pair[1], counts[i][0]),
+		)
+	}
+	return updates, nil
+}
+
+// callTotalReferences returns the sum of the counts of all sectors, including
+// the changes of the pending updates. The counts are streamed from disk, so
+// unlike callCountRange no slice of all counts is allocated.
+func (rc *refCounter) callTotalReferences() (uint64, error) {
+	var total uint64
+	err := rc.callForEach(func(_ uint64, count uint16) error {
+		total += uint64(count)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// callUpdateCounts applies the deltas to the counts of the given sectors. All
+// sector indices are validated and all new counts are computed before any of
+// them is changed, an underflow or overflow of any count fails the whole
+// batch. Sectors with a positive delta are marked as accessed. The returned
+// updates write adjacent sectors with a single ranged write and need to be
+// applied in the same transaction for the batch to be atomic.
+func (rc *refCounter) callUpdateCounts(deltas map[uint64]int) ([]writeaheadlog.Update, error) {
+	if rc.staticReadOnly {
+		return nil, ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.isUpdateInProgress {
+		return []writeaheadlog.Update{}, ErrUpdateWithoutUpdateSession
+	}
+	if rc.isDeleted {
+		return []writeaheadlog.Update{}, ErrUpdateAfterDelete
+	}
+	// Validate all indices before reading any counts.
+	indices := make([]uint64, 0, len(deltas))
+	for secIdx, delta := range deltas {
+		if secIdx >= rc.numSectors {
+			return []writeaheadlog.Update{}, errors.AddContext(ErrInvalidSectorNumber, "failed to update counts")
+		}
+		if delta != 0 {
+			indices = append(indices, secIdx)
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return indices[i] < indices[j]
+	})
+	// Compute all new counts before changing any of them.
+	counts := make([]uint16, len(indices))
+	oldCounts := make([]uint16, len(indices))
+	for i, secIdx := range indices {
+		count, err := rc.readCount(secIdx)
+		if err != nil {
+			return []writeaheadlog.Update{}, errors.AddContext(err, "failed to read count from update counts")
+		}
+		oldCounts[i] = count
+		newCount := int(count) + deltas[secIdx]
+		if newCount < 0 {
+			err := errors.AddContext(ErrRefCounterUnderflow, fmt.Sprintf("failed to decrement sector %v of refcounter '%v' by %v", secIdx, rc.filepath, -deltas[secIdx]))
+			if rc.staticAlerter != nil {
+				rc.staticAlerter.RegisterAlert(modules.AlertIDRenterRefCounterUnderflow, AlertMSGRefCounterUnderflow, err.Error(), modules.SeverityError)
+			}
+			return []writeaheadlog.Update{}, err
+		}
+		if newCount > math.MaxUint16 {
+			return []writeaheadlog.Update{}, errors.AddContext(ErrRefCounterOverflow, fmt.Sprintf("failed to increment sector %v by %v", secIdx, deltas[secIdx]))
+		}
+		counts[i] = uint16(newCount)
+	}
+	// Apply the counts and coalesce adjacent sectors into ranged writes.
+	var updates []writeaheadlog.Update
+	for start := 0; start < len(indices); {
+		end := start + 1
+		for end < len(indices) && indices[end] == indices[end-1]+1 {
+			end++
+		}
+		for i := start; i < end; i++ {
+			rc.trackGarbage(oldCounts[i], counts[i])
+			rc.newSectorCounts[indices[i]] = counts[i]
+			if deltas[indices[i]] > 0 {
+				rc.touch(indices[i])
+			}
+		}
+		if end-start == 1 {
+			updates = append(updates, createWriteAtUpdate(rc.filepath, indices[start], counts[start]))
+		} else {
+			updates = append(updates, createWriteRangeAtUpdate(rc.filepath, indices[start], counts[start:end]))
+		}
+		start = end
+	}
+	return updates, nil
+}
+
+// callUpdateApplied cleans up temporary data and releases the update lock, thus
+// allowing other actors to acquire it in order to update the refcounter. The
+// pending counts are cleared while holding the write lock, concurrent readers
+// never observe a partially cleared session.
+func (rc *refCounter) callUpdateApplied() error {
+	if rc.staticReadOnly {
+		return ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	// this method cannot be called if there is no active update session
+	if !rc.isUpdateInProgress {
+		return ErrUpdateWithoutUpdateSession
+	}
+
+	// clean up the temp counts
+	rc.newSectorCounts = make(map[uint64]uint16)
+	rc.newAccessTimes = make(map[uint64]uint32)
+	// close the update session
+	rc.isUpdateInProgress = false
+	// release the update lock
+	rc.muUpdate.Unlock()
+	return nil
+}
+
+// callZeroCountSectors returns the indices of all sectors which aren't
+// referenced anymore, in ascending order. These are the sectors that can be
+// garbage collected.
+func (rc *refCounter) callZeroCountSectors() ([]uint64, error) {
+	var sectors []uint64
+	err := rc.callForEach(func(secIdx uint64, count uint16) error {
+		if count == 0 {
+			sectors = append(sectors, secIdx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sectors, nil
+}
+
+// managedStartUpdate does everything callStartUpdate needs, aside from acquiring a
+// lock
+func (rc *refCounter) managedStartUpdate() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.isDeleted {
+		return ErrUpdateAfterDelete
+	}
+	// open an update session
+	rc.isUpdateInProgress = true
+	rc.sessionNumSectors = rc.numSectors
+	rc.sessionNumGarbage = rc.numGarbage
+	return nil
+}
+
+// validate checks that the refcounter's version is known, that the size of
+// its file matches the number of sectors, that the file matches its checksums
+// and that no pending count references a sector that doesn't exist. The file
+// is only checked if there is no update session in progress, since the file
+// lags behind the in-memory state until the session's updates are applied.
+// Legacy refcounters don't have checksums.
+func (rc *refCounter) validate() error {
+	if !refCounterVersionSupported(rc.Version) {
+		return errors.AddContext(ErrInvalidVersion, fmt.Sprintf("expected version %d, got version %d", refCounterVersion, rc.Version))
+	}
+	if !rc.isUpdateInProgress && !rc.isDeleted {
+		size, err := rc.fileSize()
+		if err != nil {
+			return errors.AddContext(err, "failed to read file stats")
+		}
+		if size < refCounterHeaderSize {
+			return errors.AddContext(ErrCorruptRefCounter, fmt.Sprintf("file size %d is smaller than the header size %d", size, refCounterHeaderSize))
+		}
+		if (size-refCounterHeaderSize)%2 != 0 {
+			return errors.AddContext(ErrMisalignedFile, fmt.Sprintf("counter region of %d bytes isn't a multiple of 2", size-refCounterHeaderSize))
+		}
+		expectedSize := int64(refCounterHeaderSize + rc.numSectors*2)
+		if size != expectedSize {
+			return errors.AddContext(ErrCorruptRefCounter, fmt.Sprintf("file size is %d, expected %d for %d sectors", size, expectedSize, rc.numSectors))
+		}
+		if rc.Version == refCounterVersion && rc.staticMemory == nil {
+			if err := rc.verifyChecksums(); err != nil {
+				return errors.AddContext(err, "failed to verify checksums")
+			}
+		}
+	}
+	for secIdx := range rc.newSectorCounts {
+		if secIdx >= rc.numSectors {
+			return errors.AddContext(ErrCorruptRefCounter, fmt.Sprintf("pending count for sector %d is out of range, the refcounter has %d sectors", secIdx, rc.numSectors))
+		}
+	}
+	return nil
+}
+
+// truncateFile truncates the refcounter's file to the given number of sectors
+// outside of an update session.
+func (rc *refCounter) truncateFile(numSectors uint64) (err error) {
+	f, err := rc.staticDeps.OpenFile(rc.filepath, os.O_RDWR, modules.DefaultFilePerm)
+	if err != nil {
+		return errors.AddContext(err, "failed to open refcounter file")
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	// Create the writeaheadlog transaction.
+	u := createTruncateUpdate(rc.filepath, numSectors)
+	txn, err := rc.staticWal.NewTransaction([]writeaheadlog.Update{u})
+	if err != nil {
+		return errors.AddContext(err, "failed to create wal txn")
+	}
+	if err := <-txn.SignalSetupComplete(); err != nil {
+		return errors.AddContext(err, "failed to signal setup completion")
+	}
+	// Same as in callCreateAndApplyTransaction, once the update is on disk we
+	// need to panic in case applying it fails.
+	defer func() {
+		if err != nil {
+			panic(err)
+		}
+	}()
+	if err = applyUpdates(f, u); err != nil {
+		return errors.AddContext(err, "failed to apply truncate update")
+	}
+	if err = txn.SignalUpdatesApplied(); err != nil {
+		return errors.AddContext(err, "failed to signal that updates are applied")
+	}
+	// Bring the in-memory copies of the file in line with it.
+	if rc.staticPreload != nil {
+		if err = rc.staticPreload.applyUpdates(u); err != nil {
+			return errors.AddContext(err, "failed to apply update to the preloaded counts")
+		}
+	}
+	rc.numSectors = numSectors
+	rc.sessionNumSectors = numSectors
+	if rc.mmap != nil {
+		rc.remap()
+	}
+	return nil
+}
+
+// fileSize returns the size of the refcounter's file, or of its in-memory
+// replacement.
+func (rc *refCounter) fileSize() (int64, error) {
+	if rc.staticMemory != nil {
+		return int64(len(rc.staticMemory.data)), nil
+	}
+	fi, err := os.Stat(rc.filepath)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// openFile opens the refcounter's file for reading. In-memory refcounters
+// return their in-memory replacement of the file, preloaded refcounters their
+// copy of it and memory-mapped refcounters their mapping.
+func (rc *refCounter) openFile() (refCounterFile, error) {
+	if rc.staticMemory != nil {
+		return rc.staticMemory, nil
+	}
+	if rc.staticPreload != nil {
+		return rc.staticPreload, nil
+	}
+	if rc.mmap != nil {
+		return rc.mmap, nil
+	}
+	return rc.staticDeps.Open(rc.filepath)
+}
+
+// preload reads the refcounter's file into memory. Subsequent reads of counts
+// are served from memory.
+func (rc *refCounter) preload() error {
+	data, err := ioutil.ReadFile(rc.filepath)
+	if err != nil {
+		return errors.AddContext(err, "failed to read refcounter file")
+	}
+	rc.staticPreload = &refCounterMemory{data: data}
+	return nil
+}
+
+// remap replaces the memory mapping of the refcounter's file with a new one
+// that covers the file's current size. If the file can't be mapped, the
+// refcounter falls back to reading from disk.
+func (rc *refCounter) remap() {
+	// Unmapping a valid mapping only fails on invalid arguments, the old
+	// mapping is dropped either way.
+	_ = rc.unmap()
+	data, err := mmapRefCounterFile(rc.filepath)
+	if err != nil {
+		return
+	}
+	rc.mmap = &refCounterMmap{data: data}
+}
+
+// unmap releases the memory mapping of the refcounter's file if it has one.
+func (rc *refCounter) unmap() error {
+	if rc.mmap == nil {
+		return nil
+	}
+	err := munmapRefCounterFile(rc.mmap.data)
+	rc.mmap = nil
+	return errors.AddContext(err, "failed to unmap refcounter")
+}
+
+// readCount reads the given sector count either from disk (if there are no
 // pending updates) or from the in-memory cache (if there are).
 func (rc *refCounter) readCount(secIdx uint64) (_ uint16, err error) {
 	// check if the secIdx is a valid sector index based on the number of
@@ -455,7 +1704,7 @@ func (rc *refCounter) readCount(secIdx uint64) (_ uint16, err error) {
 		return count, nil
 	}
 	// read the value from disk
-	f, err := rc.staticDeps.Open(rc.filepath)
+	f, err := rc.openFile()
 	if err != nil {
 		return 0, errors.AddContext(err, "failed to open the refcounter file")
 	}
@@ -464,32 +1713,396 @@ func (rc *refCounter) readCount(secIdx uint64) (_ uint16, err error) {
 	}()
 
 	var b u16
-	if _, err = f.ReadAt(b[:], int64(offset(secIdx))); err != nil {
+	_, err = f.ReadAt(b[:], int64(offset(secIdx)))
+	if rc.staticReadOnly && errors.Contains(err, io.EOF) {
+		// The file was truncated by the process that owns it after the
+		// number of sectors was read.
+		return 0, errors.AddContext(ErrInvalidSectorNumber, "refcounter file was truncated")
+	}
+	if err != nil {
 		return 0, errors.AddContext(err, "failed to read from refcounter file")
 	}
 	return binary.LittleEndian.Uint16(b[:]), nil
 }
 
-// applyUpdates takes a list of WAL updates and applies them.
-func applyUpdates(f modules.File, updates ...writeaheadlog.Update) (err error) {
+// accessTimeUpdates returns the update required to write the pending access
+// times to disk. All pending access times are combined into a single update so
+// that the access time file only needs to be opened and synced once per
+// transaction. Access times of sectors that were dropped or of a deleted
+// refcounter are ignored.
+func (rc *refCounter) accessTimeUpdates() []writeaheadlog.Update {
+	if !rc.staticTrackAccess || rc.isDeleted {
+		return nil
+	}
+	times := make(map[uint64]uint32, len(rc.newAccessTimes))
+	for secIdx, at := range rc.newAccessTimes {
+		if secIdx < rc.numSectors {
+			times[secIdx] = at
+		}
+	}
+	if len(times) == 0 {
+		return nil
+	}
+	return []writeaheadlog.Update{createWriteAccessAtUpdate(rc.filepath, times)}
+}
+
+// readAccessTime reads the given sector's access time either from disk or from
+// the in-memory cache if it is being changed by a pending update.
+func (rc *refCounter) readAccessTime(secIdx uint64) (_ uint32, err error) {
+	if secIdx >= rc.numSectors {
+		return 0, errors.AddContext(ErrInvalidSectorNumber, "failed to read access time")
+	}
+	if at, ok := rc.newAccessTimes[secIdx]; ok {
+		return at, nil
+	}
+	f, err := rc.staticDeps.Open(accessTimeFilePath(rc.filepath))
+	if err != nil {
+		return 0, errors.AddContext(err, "failed to open the access time file")
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	var b [refCounterAccessTimeSize]byte
+	_, err = f.ReadAt(b[:], int64(secIdx*refCounterAccessTimeSize))
+	if errors.Contains(err, io.EOF) {
+		// The sector was never accessed.
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.AddContext(err, "failed to read from access time file")
+	}
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
+
+// trackGarbage updates the number of garbage sectors for a sector whose count
+// changes from oldCount to newCount.
+func (rc *refCounter) trackGarbage(oldCount, newCount uint16) {
+	if oldCount == 0 && newCount != 0 {
+		rc.numGarbage--
+	} else if oldCount != 0 && newCount == 0 {
+		rc.numGarbage++
+	}
+}
+
+// touch marks the given sector as accessed now if the refcounter tracks access
+// times.
+func (rc *refCounter) touch(secIdx uint64) {
+	if rc.staticTrackAccess {
+		rc.newAccessTimes[secIdx] = accessTimeNow()
+	}
+}
+
+// Close implements io.Closer. There is nothing to close for an in-memory
+// refcounter.
+func (m *refCounterMemory) Close() error {
+	return nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (m *refCounterMemory) ReadAt(b []byte, off int64) (int, error) {
+	return readAtData(m.data, b, off)
+}
+
+// Close implements io.Closer. The mapping is only released when the
+// refcounter is closed.
+func (m *refCounterMmap) Close() error {
+	return nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (m *refCounterMmap) ReadAt(b []byte, off int64) (int, error) {
+	return readAtData(m.data, b, off)
+}
+
+// applyUpdates applies the given WAL updates to the in-memory data. A delete
+// update leaves the data in place since the refcounter doesn't accept any
+// updates after it.
+func (m *refCounterMemory) applyUpdates(updates ...writeaheadlog.Update) error {
 	for _, update := range updates {
 		switch update.Name {
 		case updateNameRCDelete:
-			err = applyDeleteUpdate(update)
 		case updateNameRCTruncate:
-			err = applyTruncateUpdate(f, update)
+			_, newNumSec, err := readTruncateUpdate(update)
+			if err != nil {
+				return err
+			}
+			if size := offset(newNumSec); size <= uint64(len(m.data)) {
+				m.data = m.data[:size]
+			} else {
+				m.data = append(m.data, make([]byte, size-uint64(len(m.data)))...)
+			}
+		case updateNameRCWriteAt:
+			_, secIdx, value, err := readWriteAtUpdate(update)
+			if err != nil {
+				return err
+			}
+			if end := offset(secIdx) + 2; end > uint64(len(m.data)) {
+				m.data = append(m.data, make([]byte, end-uint64(len(m.data)))...)
+			}
+			binary.LittleEndian.PutUint16(m.data[offset(secIdx):], value)
+		case updateNameRCWriteRangeAt:
+			_, start, values, err := readWriteRangeAtUpdate(update)
+			if err != nil {
+				return err
+			}
+			if end := offset(start) + 2*uint64(len(values)); end > uint64(len(m.data)) {
+				m.data = append(m.data, make([]byte, end-uint64(len(m.data)))...)
+			}
+			for i, value := range values {
+				binary.LittleEndian.PutUint16(m.data[offset(start+uint64(i)):], value)
+			}
+		default:
+			return errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("unknown update type: %v", update.Name))
+		}
+	}
+	return nil
+}
+
+// numSectors returns the number of sectors in the in-memory data.
+func (m *refCounterMemory) numSectors() uint64 {
+	return uint64(len(m.data)-refCounterHeaderSize) / 2
+}
+
+// ApplyUpdates applies refcounter updates that were found unapplied in the WAL
+// after a crash, e.g. while recovering a contract set. Unlike applyUpdates it
+// doesn't require the refcounter's file to be open, the files are taken from
+// the updates. Every update is idempotent, so updates that were applied before
+// the crash can be applied again. Besides the refcounter's own updates, the
+// writeaheadlog's WriteAt updates are accepted since they are used to create
+// and upgrade refcounters. Any other update results in ErrUnknownUpdate.
+func ApplyUpdates(updates ...writeaheadlog.Update) (err error) {
+	// Keep the refcounter files open while applying the updates to avoid
+	// reopening them for every update. The counts that are written to a file
+	// are collected in a batch. The batches are flushed before any other file
+	// is written with the writeaheadlog's WriteAt update and the files are
+	// synced and closed before files are deleted or renamed and once all the
+	// updates are applied.
+	files := make(map[string]*os.File)
+	batches := make(map[string]*refCounterWriteBatch)
+	flushBatches := func() (err error) {
+		for path, batch := range batches {
+			err = errors.Compose(err, batch.flush(files[path]))
+		}
+		return err
+	}
+	closeFiles := func() (err error) {
+		err = flushBatches()
+		for path, f := range files {
+			err = errors.Compose(err, f.Sync(), f.Close())
+			delete(files, path)
+			delete(batches, path)
+		}
+		return err
+	}
+	defer func() {
+		err = errors.Compose(err, closeFiles())
+	}()
+	// applyFileUpdate applies an update that modifies the refcounter file
+	// itself.
+	applyFileUpdate := func(u writeaheadlog.Update) (err error) {
+		var path string
+		switch u.Name {
+		case updateNameRCTruncate:
+			path, _, err = readTruncateUpdate(u)
 		case updateNameRCWriteAt:
-			err = applyWriteAtUpdate(f, update)
+			path, _, _, err = readWriteAtUpdate(u)
+		case updateNameRCWriteRangeAt:
+			path, _, _, err = readWriteRangeAtUpdate(u)
+		}
+		if err != nil {
+			return err
+		}
+		f, exists := files[path]
+		if !exists {
+			f, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR, modules.DefaultFilePerm)
+			if err != nil {
+				return errors.AddContext(err, "failed to open refcounter file")
+			}
+			files[path] = f
+			batches[path] = newRefCounterWriteBatch()
+		}
+		switch u.Name {
+		case updateNameRCTruncate:
+			if err := batches[path].flush(f); err != nil {
+				return err
+			}
+			return applyTruncateUpdate(f, u)
+		default:
+			return batches[path].add(u)
+		}
+	}
+
+	for _, update := range updates {
+		switch update.Name {
+		case updateNameRCDelete:
+			err = errors.Compose(closeFiles(), applyDeleteUpdate(update))
+		case updateNameRCRename:
+			err = errors.Compose(closeFiles(), applyRenameUpdate(update))
+		case updateNameRCTruncate, updateNameRCWriteAt, updateNameRCWriteRangeAt:
+			err = applyFileUpdate(update)
+		case updateNameRCWriteAccessAt:
+			err = applyWriteAccessAtUpdate(update)
+		case updateNameRCWriteChecksums:
+			err = applyWriteChecksumsUpdate(update)
+		case writeaheadlog.NameWriteAtUpdate:
+			if err = flushBatches(); err == nil {
+				err = writeaheadlog.ApplyWriteAtUpdate(update)
+			}
+		default:
+			err = errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("unknown update type: %v", update.Name))
+		}
+		if err != nil {
+			return errors.AddContext(err, fmt.Sprintf("failed to apply %v update", update.Name))
+		}
+	}
+	return nil
+}
+
+// isRefCounterTxn returns whether all of the transaction's updates are
+// refcounter updates that can be applied with ApplyUpdates. The writeaheadlog's
+// WriteAt updates are only used by refcounters within the contract set's WAL.
+func isRefCounterTxn(txn *writeaheadlog.Transaction) bool {
+	if len(txn.Updates) == 0 {
+		return false
+	}
+	for _, u := range txn.Updates {
+		switch u.Name {
+		case updateNameRCDelete, updateNameRCRename, updateNameRCTruncate, updateNameRCWriteAt, updateNameRCWriteRangeAt, updateNameRCWriteAccessAt, updateNameRCWriteChecksums, writeaheadlog.NameWriteAtUpdate:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// applyUpdates takes a list of WAL updates and applies them. The counts are
+// written to f in batches, see refCounterWriteBatch, and f is synced once after
+// all updates are applied.
+func applyUpdates(f modules.File, updates ...writeaheadlog.Update) (err error) {
+	// The counts are written in batches. A batch is flushed before the file
+	// is truncated, deleted or renamed and once all updates are applied.
+	batch := newRefCounterWriteBatch()
+	for _, update := range updates {
+		switch update.Name {
+		case updateNameRCDelete:
+			if err = batch.flush(f); err == nil {
+				err = applyDeleteUpdate(update)
+			}
+		case updateNameRCRename:
+			if err = batch.flush(f); err == nil {
+				err = applyRenameUpdate(update)
+			}
+		case updateNameRCTruncate:
+			if err = batch.flush(f); err == nil {
+				err = applyTruncateUpdate(f, update)
+			}
+		case updateNameRCWriteAt, updateNameRCWriteRangeAt:
+			err = batch.add(update)
+		case updateNameRCWriteAccessAt:
+			err = applyWriteAccessAtUpdate(update)
+		case updateNameRCWriteChecksums:
+			err = applyWriteChecksumsUpdate(update)
 		default:
-			err = fmt.Errorf("unknown update type: %v", update.Name)
+			err = errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("unknown update type: %v", update.Name))
 		}
 		if err != nil {
 			return err
 		}
 	}
+	if err = batch.flush(f); err != nil {
+		return err
+	}
 	return f.Sync()
 }
 
+// refCounterWriteBatch collects the counts that the WriteAt and WriteRangeAt
+// updates of a transaction write to a refcounter file. A later write to a
+// sector replaces an earlier one within the same batch. Flushing the batch
+// writes every run of adjacent sectors with a single WriteAt instead of
+// issuing a tiny write per update.
+type refCounterWriteBatch struct {
+	writes []refCounterWrite
+}
+
+// refCounterWrite is a single count in a refCounterWriteBatch. seq is the
+// position of the write within the batch.
+type refCounterWrite struct {
+	secIdx uint64
+	seq    int
+	value  uint16
+}
+
+// newRefCounterWriteBatch creates an empty batch.
+func newRefCounterWriteBatch() *refCounterWriteBatch {
+	return &refCounterWriteBatch{}
+}
+
+// add adds the counts of a WriteAt or WriteRangeAt update to the batch.
+func (b *refCounterWriteBatch) add(u writeaheadlog.Update) error {
+	switch u.Name {
+	case updateNameRCWriteAt:
+		_, secIdx, value, err := readWriteAtUpdate(u)
+		if err != nil {
+			return err
+		}
+		b.writes = append(b.writes, refCounterWrite{secIdx: secIdx, seq: len(b.writes), value: value})
+	case updateNameRCWriteRangeAt:
+		_, start, values, err := readWriteRangeAtUpdate(u)
+		if err != nil {
+			return err
+		}
+		for i, value := range values {
+			b.writes = append(b.writes, refCounterWrite{secIdx: start + uint64(i), seq: len(b.writes), value: value})
+		}
+	default:
+		return errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("refCounterWriteBatch can't add update of type %v", u.Name))
+	}
+	return nil
+}
+
+// flush writes the counts of the batch to f, sorted by sector and merged into
+// runs of adjacent sectors, and empties the batch. Multiple writes to the same
+// sector are ordered by their position in the batch, so the last one is the
+// one that is written.
+func (b *refCounterWriteBatch) flush(f io.WriterAt) error {
+	if len(b.writes) == 0 {
+		return nil
+	}
+	writes := b.writes
+	b.writes = nil
+	sort.Slice(writes, func(i, j int) bool {
+		if writes[i].secIdx != writes[j].secIdx {
+			return writes[i].secIdx < writes[j].secIdx
+		}
+		return writes[i].seq < writes[j].seq
+	})
+	buf := make([]byte, 0, 2*len(writes))
+	for i := 0; i < len(writes); {
+		// Collect the run of adjacent sectors starting at i. Of multiple
+		// writes to a sector only the last one is kept.
+		start := writes[i].secIdx
+		buf = buf[:0]
+		j := i
+		for ; j < len(writes); j++ {
+			secIdx := writes[j].secIdx
+			if secIdx > start+uint64(len(buf)/2) {
+				break
+			}
+			if secIdx < start+uint64(len(buf)/2) {
+				binary.LittleEndian.PutUint16(buf[len(buf)-2:], writes[j].value)
+				continue
+			}
+			buf = append(buf, 0, 0)
+			binary.LittleEndian.PutUint16(buf[len(buf)-2:], writes[j].value)
+		}
+		if _, err := f.WriteAt(buf, int64(offset(start))); err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
 // createDeleteUpdate is a helper function which creates a writeaheadlog update
 // for deleting a given refcounter file.
 func createDeleteUpdate(path string) writeaheadlog.Update {
@@ -502,10 +2115,59 @@ func createDeleteUpdate(path string) writeaheadlog.Update {
 // applyDeleteUpdate parses and applies a Delete update.
 func applyDeleteUpdate(update writeaheadlog.Update) error {
 	if update.Name != updateNameRCDelete {
-		return fmt.Errorf("applyDeleteUpdate called on update of type %v", update.Name)
+		return errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("applyDeleteUpdate called on update of type %v", update.Name))
 	}
 	// Remove the file and ignore the NotExist error
-	if err := os.Remove(string(update.Instructions)); !os.IsNotExist(err) {
+	path := string(update.Instructions)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// Remove the access time file and the checksum file if there are any.
+	if err := os.Remove(accessTimeFilePath(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(checksumFilePath(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// createRenameUpdate is a helper function which creates a writeaheadlog
+// update for renaming the refcounter file at oldPath to newPath.
+func createRenameUpdate(oldPath, newPath string) writeaheadlog.Update {
+	b := make([]byte, 8+len(oldPath)+len(newPath))
+	binary.LittleEndian.PutUint64(b[:8], uint64(len(oldPath)))
+	copy(b[8:8+len(oldPath)], oldPath)
+	copy(b[8+len(oldPath):], newPath)
+	return writeaheadlog.Update{
+		Name:         updateNameRCRename,
+		Instructions: b,
+	}
+}
+
+// applyRenameUpdate parses and applies a Rename update. The update is
+// idempotent, files that were already moved are skipped.
+func applyRenameUpdate(u writeaheadlog.Update) error {
+	if u.Name != updateNameRCRename {
+		return errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("applyRenameUpdate called on update of type %v", u.Name))
+	}
+	// Decode update.
+	oldPath, newPath, err := readRenameUpdate(u)
+	if err != nil {
+		return err
+	}
+	// Rename the file, the access time file and the checksum file if there
+	// are any, ignoring the NotExist error in case the rename was applied
+	// before.
+	if err := os.Rename(oldPath, newPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Rename(accessTimeFilePath(oldPath), accessTimeFilePath(newPath))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Rename(checksumFilePath(oldPath), checksumFilePath(newPath))
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	return nil
@@ -526,13 +2188,18 @@ func createTruncateUpdate(path string, newNumSec uint64) writeaheadlog.Update {
 // applyTruncateUpdate parses and applies a Truncate update.
 func applyTruncateUpdate(f modules.File, u writeaheadlog.Update) error {
 	if u.Name != updateNameRCTruncate {
-		return fmt.Errorf("applyAppendTruncate called on update of type %v", u.Name)
+		return errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("applyTruncateUpdate called on update of type %v", u.Name))
 	}
 	// Decode update.
-	_, newNumSec, err := readTruncateUpdate(u)
+	path, newNumSec, err := readTruncateUpdate(u)
 	if err != nil {
 		return err
 	}
+	// Truncate the access time file if there is one.
+	err = os.Truncate(accessTimeFilePath(path), int64(newNumSec)*refCounterAccessTimeSize)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	// Truncate the file to the needed size.
 	return f.Truncate(refCounterHeaderSize + int64(newNumSec)*2)
 }
@@ -553,7 +2220,7 @@ func createWriteAtUpdate(path string, secIdx uint64, value uint16) writeaheadlog
 // applyWriteAtUpdate parses and applies a WriteAt update.
 func applyWriteAtUpdate(f modules.File, u writeaheadlog.Update) error {
 	if u.Name != updateNameRCWriteAt {
-		return fmt.Errorf("applyAppendWriteAt called on update of type %v", u.Name)
+		return errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("applyWriteAtUpdate called on update of type %v", u.Name))
 	}
 	// Decode update.
 	_, secIdx, value, err := readWriteAtUpdate(u)
@@ -568,20 +2235,167 @@ func applyWriteAtUpdate(f modules.File, u writeaheadlog.Update) error {
 	return err
 }
 
+// createWriteRangeAtUpdate is a helper function which creates a writeaheadlog
+// update for writing the values of the adjacent sectors starting at start.
+func createWriteRangeAtUpdate(path string, start uint64, values []uint16) writeaheadlog.Update {
+	b := make([]byte, 8+8+2*len(values)+len(path))
+	binary.LittleEndian.PutUint64(b[:8], start)
+	binary.LittleEndian.PutUint64(b[8:16], uint64(len(values)))
+	for i, value := range values {
+		binary.LittleEndian.PutUint16(b[16+2*i:], value)
+	}
+	copy(b[16+2*len(values):], path)
+	return writeaheadlog.Update{
+		Name:         updateNameRCWriteRangeAt,
+		Instructions: b,
+	}
+}
+
+// applyWriteRangeAtUpdate parses and applies a WriteRangeAt update.
+func applyWriteRangeAtUpdate(f modules.File, u writeaheadlog.Update) error {
+	if u.Name != updateNameRCWriteRangeAt {
+		return errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("applyWriteRangeAtUpdate called on update of type %v", u.Name))
+	}
+	// Decode update.
+	_, start, values, err := readWriteRangeAtUpdate(u)
+	if err != nil {
+		return err
+	}
+
+	// Write the values to disk.
+	b := make([]byte, 2*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint16(b[2*i:], value)
+	}
+	_, err = f.WriteAt(b, int64(offset(start)))
+	return err
+}
+
+// createWriteAccessAtUpdate is a helper function which creates a writeaheadlog
+// update for writing the access times of a set of sectors to the access time
+// file of the refcounter at the given path. The entries are sorted by sector
+// index so that adjacent sectors can be written with a single write.
+func createWriteAccessAtUpdate(path string, times map[uint64]uint32) writeaheadlog.Update {
+	indices := make([]uint64, 0, len(times))
+	for secIdx := range times {
+		indices = append(indices, secIdx)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	entrySize := 8 + refCounterAccessTimeSize
+	b := make([]byte, 8+len(path)+len(indices)*entrySize)
+	binary.LittleEndian.PutUint64(b[:8], uint64(len(path)))
+	copy(b[8:8+len(path)], path)
+	entries := b[8+len(path):]
+	for i, secIdx := range indices {
+		binary.LittleEndian.PutUint64(entries[i*entrySize:], secIdx)
+		binary.LittleEndian.PutUint32(entries[i*entrySize+8:], times[secIdx])
+	}
+	return writeaheadlog.Update{
+		Name:         updateNameRCWriteAccessAt,
+		Instructions: b,
+	}
+}
+
+// applyWriteAccessAtUpdate parses and applies a WriteAccessAt update. The
+// access time file is opened and synced once, and runs of adjacent sectors are
+// merged into a single write.
+func applyWriteAccessAtUpdate(u writeaheadlog.Update) (err error) {
+	if u.Name != updateNameRCWriteAccessAt {
+		return errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("applyWriteAccessAtUpdate called on update of type %v", u.Name))
+	}
+	// Decode update.
+	path, indices, times, err := readWriteAccessAtUpdate(u)
+	if err != nil {
+		return err
+	}
+
+	// Write the access times to disk.
+	f, err := os.OpenFile(accessTimeFilePath(path), os.O_CREATE|os.O_RDWR, modules.DefaultFilePerm)
+	if err != nil {
+		return errors.AddContext(err, "failed to open access time file")
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	for start := 0; start < len(indices); {
+		end := start + 1
+		for end < len(indices) && indices[end] == indices[end-1]+1 {
+			end++
+		}
+		b := make([]byte, (end-start)*refCounterAccessTimeSize)
+		for i := start; i < end; i++ {
+			binary.LittleEndian.PutUint32(b[(i-start)*refCounterAccessTimeSize:], times[i])
+		}
+		if _, err = f.WriteAt(b, int64(indices[start]*refCounterAccessTimeSize)); err != nil {
+			return err
+		}
+		start = end
+	}
+	return f.Sync()
+}
+
+// accessTimeFilePath returns the path of the access time file that belongs to
+// the refcounter at the given path.
+func accessTimeFilePath(path string) string {
+	return path + refCounterAccessTimeSuffix
+}
+
+// accessTimeNow returns the current time in the format used by the access time
+// file.
+func accessTimeNow() uint32 {
+	return uint32(time.Now().Unix())
+}
+
 // deserializeHeader deserializes a header from []byte
 func deserializeHeader(b []byte, h *refCounterHeader) error {
 	if uint64(len(b)) < refCounterHeaderSize {
-		return ErrInvalidHeaderData
+		return errors.AddContext(ErrInvalidHeaderData, fmt.Sprintf("header has %d bytes, expected %d", len(b), refCounterHeaderSize))
 	}
 	copy(h.Version[:], b[:8])
 	return nil
 }
 
+// isRefCounterCorruption returns whether the error indicates that a
+// refcounter's files are corrupt. Unlike a misaligned file, which is repaired
+// by callRepair, a corrupt refcounter can't be recovered from its own files.
+func isRefCounterCorruption(err error) bool {
+	return errors.Contains(err, ErrCorruptRefCounter) || errors.Contains(err, ErrInvalidHeaderData)
+}
+
 // offset calculates the byte offset of the sector counter in the file on disk
 func offset(secIdx uint64) uint64 {
 	return refCounterHeaderSize + secIdx*2
 }
 
+// readAtData reads from data like io.ReaderAt reads from a file.
+func readAtData(data, b []byte, off int64) (int, error) {
+	if off >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readRenameUpdate decodes a Rename update
+func readRenameUpdate(u writeaheadlog.Update) (oldPath, newPath string, err error) {
+	if len(u.Instructions) < 8 {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
+	oldLen := binary.LittleEndian.Uint64(u.Instructions[:8])
+	if oldLen > uint64(len(u.Instructions)-8) {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
+	oldPath = string(u.Instructions[8 : 8+oldLen])
+	newPath = string(u.Instructions[8+oldLen:])
+	return
+}
+
 // readTruncateUpdate decodes a Truncate update
 func readTruncateUpdate(u writeaheadlog.Update) (path string, newNumSec uint64, err error) {
 	if len(u.Instructions) < 8 {
@@ -589,10 +2403,40 @@ func readTruncateUpdate(u writeaheadlog.Update) (path string, newNumSec uint64,
 		return
 	}
 	newNumSec = binary.LittleEndian.Uint64(u.Instructions[:8])
+	if newNumSec > refCounterMaxSectors {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
 	path = string(u.Instructions[8:])
 	return
 }
 
+// readWriteAccessAtUpdate decodes a WriteAccessAt update
+func readWriteAccessAtUpdate(u writeaheadlog.Update) (path string, indices []uint64, times []uint32, err error) {
+	if len(u.Instructions) < 8 {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
+	pathLen := binary.LittleEndian.Uint64(u.Instructions[:8])
+	entrySize := uint64(8 + refCounterAccessTimeSize)
+	rest := uint64(len(u.Instructions)) - 8
+	if pathLen > rest || (rest-pathLen)%entrySize != 0 {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
+	path = string(u.Instructions[8 : 8+pathLen])
+	entries := u.Instructions[8+pathLen:]
+	for i := uint64(0); i < uint64(len(entries)); i += entrySize {
+		secIdx := binary.LittleEndian.Uint64(entries[i : i+8])
+		if secIdx >= refCounterMaxSectors {
+			return "", nil, nil, ErrInvalidUpdateInstruction
+		}
+		indices = append(indices, secIdx)
+		times = append(times, binary.LittleEndian.Uint32(entries[i+8:i+entrySize]))
+	}
+	return
+}
+
 // readWriteAtUpdate decodes a WriteAt update
 func readWriteAtUpdate(u writeaheadlog.Update) (path string, secIdx uint64, value uint16, err error) {
 	if len(u.Instructions) < 10 {
@@ -600,11 +2444,35 @@ func readWriteAtUpdate(u writeaheadlog.Update) (path string, secIdx uint64, valu
 		return
 	}
 	secIdx = binary.LittleEndian.Uint64(u.Instructions[:8])
+	if secIdx >= refCounterMaxSectors {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
 	value = binary.LittleEndian.Uint16(u.Instructions[8:10])
 	path = string(u.Instructions[10:])
 	return
 }
 
+// readWriteRangeAtUpdate decodes a WriteRangeAt update
+func readWriteRangeAtUpdate(u writeaheadlog.Update) (path string, start uint64, values []uint16, err error) {
+	if len(u.Instructions) < 16 {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
+	start = binary.LittleEndian.Uint64(u.Instructions[:8])
+	n := binary.LittleEndian.Uint64(u.Instructions[8:16])
+	if n > uint64(len(u.Instructions)-16)/2 || start > refCounterMaxSectors-n {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
+	values = make([]uint16, n)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint16(u.Instructions[16+2*i:])
+	}
+	path = string(u.Instructions[16+2*n:])
+	return
+}
+
 // serializeHeader serializes a header to []byte
 func serializeHeader(h refCounterHeader) []byte {
 	b := make([]byte, refCounterHeaderSize)
@@ -0,0 +1,38 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package proto
+
+import (
+	"os"
+	"syscall"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// mmapRefCounterFile maps the refcounter file at the given path into memory
+// for reading. The mapping is shared, writes to the file are visible through
+// it without remapping as long as the file's size doesn't change.
+func mmapRefCounterFile(path string) (_ []byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to open refcounter file")
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to read file stats")
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to map refcounter file")
+	}
+	return data, nil
+}
+
+// munmapRefCounterFile releases a mapping created by mmapRefCounterFile.
+func munmapRefCounterFile(data []byte) error {
+	return syscall.Munmap(data)
+}
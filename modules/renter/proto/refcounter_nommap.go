@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package proto
+
+// mmapRefCounterFile returns ErrMmapNotSupported on operating systems without
+// support for memory-mapping refcounters.
+func mmapRefCounterFile(path string) ([]byte, error) {
+	return nil, ErrMmapNotSupported
+}
+
+// munmapRefCounterFile is a no-op on operating systems without support for
+// memory-mapping refcounters.
+func munmapRefCounterFile(data []byte) error {
+	return nil
+}
@@ -1,13 +1,18 @@
 package proto
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -43,7 +48,11 @@ func (rc *refCounter) managedStartUpdateWithTimeout(timeout time.Duration) error
 	if ok := rc.muUpdate.TryLockTimed(timeout); !ok {
 		return errTimeoutOnLock
 	}
-	return rc.managedStartUpdate()
+	if err := rc.managedStartUpdate(); err != nil {
+		rc.muUpdate.Unlock()
+		return err
+	}
+	return nil
 }
 
 // TestRefCounterCount tests that the Count method always returns the correct
@@ -94,6 +103,79 @@ func TestRefCounterCount(t *testing.T) {
 	}
 }
 
+// TestRefCounterCountRange tests that callCountRange returns the counts of a
+// range of sectors, overlaid with the counts of pending updates.
+func TestRefCounterCountRange(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// prepare a refcounter for the tests
+	numSec := 4 + fastrand.Uint64n(10)
+	rc := testPrepareRefCounter(numSec, t)
+	expected := make([]uint16, numSec)
+	for i := uint64(0); i < numSec; i++ {
+		expected[i] = uint16(fastrand.Intn(1000))
+		if err := writeVal(rc.filepath, i, expected[i]); err != nil {
+			t.Fatal("Failed to write a count to disk:", err)
+		}
+	}
+
+	// verify the whole range and a sub range
+	counts, err := rc.callCountRange(0, numSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Fatalf("wrong counts: expected %v, got %v", expected, counts)
+	}
+	counts, err = rc.callCountRange(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(counts, expected[1:3]) {
+		t.Fatalf("wrong counts: expected %v, got %v", expected[1:3], counts)
+	}
+	counts, err = rc.callCountRange(2, 2)
+	if err != nil || len(counts) != 0 {
+		t.Fatal("expected empty range", counts, err)
+	}
+
+	// check behaviour on bad bounds
+	_, err = rc.callCountRange(0, numSec+1)
+	if !errors.Contains(err, ErrInvalidSectorNumber) {
+		t.Fatal("Expected ErrInvalidSectorNumber, got:", err)
+	}
+	_, err = rc.callCountRange(2, 1)
+	if !errors.Contains(err, ErrInvalidSectorNumber) {
+		t.Fatal("Expected ErrInvalidSectorNumber, got:", err)
+	}
+
+	// pending updates, including appended sectors, are overlaid
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.callSetCount(1, 12); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.callAppend(); err != nil {
+		t.Fatal(err)
+	}
+	expected[1] = 12
+	expected = append(expected, 1)
+	counts, err = rc.callCountRange(0, numSec+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Fatalf("wrong counts: expected %v, got %v", expected, counts)
+	}
+	if err := rc.callAbortUpdate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestRefCounterAppend tests that the callDecrement method behaves correctly
 func TestRefCounterAppend(t *testing.T) {
 	if testing.Short() {
@@ -448,6 +530,438 @@ func TestRefCounterIncrement(t *testing.T) {
 	}
 }
 
+// TestRefCounterPreloaded verifies that a preloaded refcounter serves the same
+// counts as one that reads them from disk after a mixed update session, and
+// that its counts match the file on disk.
+func TestRefCounterPreloaded(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	td := build.TempDir(t.Name())
+	if err := os.MkdirAll(td, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	diskPath := filepath.Join(td, "disk"+refCounterExtension)
+	preloadedPath := filepath.Join(td, "preloaded"+refCounterExtension)
+	disk, err := newRefCounter(diskPath, 10, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preloaded, err := newPreloadedRefCounter(preloadedPath, 10, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disk.callPreloaded() || !preloaded.callPreloaded() {
+		t.Fatal("unexpected preloaded state")
+	}
+
+	// update both refcounters the same way
+	session := func(rc *refCounter) {
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		var updates []writeaheadlog.Update
+		add := func(us ...writeaheadlog.Update) {
+			updates = append(updates, us...)
+		}
+		u, err := rc.callIncrement(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(u)
+		u, err = rc.callDecrement(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(u)
+		u, err = rc.callSetCount(3, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(u)
+		us, err := rc.callUpdateCounts(map[uint64]int{4: 2, 5: 3, 6: -1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(us...)
+		us, err = rc.callSwap(3, 9)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(us...)
+		u, err = rc.callDropSectors(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(u)
+		u, err = rc.callAppend()
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(u)
+		if err := rc.callCreateAndApplyTransaction(updates...); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session(disk)
+	session(preloaded)
+
+	// the counts of both refcounters agree, and so do the counts of a
+	// refcounter loaded from the preloaded one's file
+	loaded, err := loadRefCounter(preloadedPath, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint16{1, 2, 0, 1, 3, 4, 0, 1, 1}
+	for _, rc := range []*refCounter{disk, preloaded, loaded} {
+		if rc.numSectors != uint64(len(expected)) {
+			t.Fatalf("expected %v sectors, got %v", len(expected), rc.numSectors)
+		}
+		counts, err := rc.callCountRange(0, rc.numSectors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for secIdx, count := range counts {
+			c, err := rc.callCount(uint64(secIdx))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != expected[secIdx] || c != expected[secIdx] {
+				t.Fatalf("wrong count for sector %v. Expected %v, got %v and %v", secIdx, expected[secIdx], count, c)
+			}
+		}
+	}
+
+	// preloading an existing refcounter reads its counts
+	reloaded, err := loadPreloadedRefCounter(preloadedPath, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts, err := reloaded.callCountRange(0, reloaded.numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Fatal("unexpected counts", counts)
+	}
+}
+
+// TestRefCounterMmap verifies that a memory-mapped refcounter reads the
+// counts written by an update session, including sectors that were appended
+// and dropped, and that it falls back to reading from disk once it's closed.
+func TestRefCounterMmap(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rc := testPrepareRefCounter(4, t)
+	mapped, err := loadMmapRefCounter(rc.filepath, testWAL)
+	if errors.Contains(err, ErrMmapNotSupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mapped.callMmapped() || rc.callMmapped() {
+		t.Fatal("unexpected mmapped state")
+	}
+
+	// verifyCounts checks the counts of the mapped refcounter against the
+	// expected ones.
+	verifyCounts := func(expected []uint16) {
+		t.Helper()
+		if mapped.numSectors != uint64(len(expected)) {
+			t.Fatalf("expected %v sectors, got %v", len(expected), mapped.numSectors)
+		}
+		counts, err := mapped.callCountRange(0, mapped.numSectors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for secIdx := range expected {
+			c, err := mapped.callCount(uint64(secIdx))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c != expected[secIdx] || counts[secIdx] != expected[secIdx] {
+				t.Fatalf("wrong count for sector %v. Expected %v, got %v and %v", secIdx, expected[secIdx], c, counts[secIdx])
+			}
+		}
+	}
+	verifyCounts([]uint16{1, 1, 1, 1})
+
+	// session applies the updates created by fn
+	session := func(fn func() ([]writeaheadlog.Update, error)) {
+		t.Helper()
+		if err := mapped.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		us, err := fn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mapped.callCreateAndApplyTransaction(us...); err != nil {
+			t.Fatal(err)
+		}
+		if err := mapped.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// writes are visible through the mapping
+	session(func() ([]writeaheadlog.Update, error) {
+		return mapped.callUpdateCounts(map[uint64]int{0: 1, 3: -1})
+	})
+	verifyCounts([]uint16{2, 1, 1, 0})
+
+	// appending sectors grows the mapping
+	session(func() ([]writeaheadlog.Update, error) {
+		u1, err := mapped.callAppend()
+		if err != nil {
+			return nil, err
+		}
+		u2, err := mapped.callIncrement(4)
+		return []writeaheadlog.Update{u1, u2}, err
+	})
+	verifyCounts([]uint16{2, 1, 1, 0, 2})
+
+	// dropping sectors shrinks it
+	session(func() ([]writeaheadlog.Update, error) {
+		u, err := mapped.callDropSectors(3)
+		return []writeaheadlog.Update{u}, err
+	})
+	verifyCounts([]uint16{2, 1})
+
+	// a closed refcounter reads from disk
+	if err := mapped.callClose(); err != nil {
+		t.Fatal(err)
+	}
+	if mapped.callMmapped() {
+		t.Fatal("refcounter should have been unmapped")
+	}
+	verifyCounts([]uint16{2, 1})
+}
+
+// TestRefCounterForEach verifies that callForEach and callZeroCountSectors
+// report the counts on disk overlaid with the pending counts of an update
+// session, across multiple batches.
+func TestRefCounterForEach(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	numSec := uint64(refCounterForEachBatchSize + 10)
+	rc := testPrepareRefCounter(numSec, t)
+
+	// verify checks that callForEach reports the expected counts in order and
+	// that callZeroCountSectors reports the expected zero count sectors.
+	verify := func(expected map[uint64]uint16, numSec uint64) {
+		t.Helper()
+		next := uint64(0)
+		var zeros []uint64
+		err := rc.callForEach(func(secIdx uint64, count uint16) error {
+			if secIdx != next {
+				return fmt.Errorf("expected sector %v, got %v", next, secIdx)
+			}
+			next++
+			want, ok := expected[secIdx]
+			if !ok {
+				want = 1
+			}
+			if count != want {
+				return fmt.Errorf("wrong count for sector %v. Expected %v, got %v", secIdx, want, count)
+			}
+			if want == 0 {
+				zeros = append(zeros, secIdx)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next != numSec {
+			t.Fatalf("expected %v sectors, got %v", numSec, next)
+		}
+		got, err := rc.callZeroCountSectors()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, zeros) {
+			t.Fatalf("expected zero count sectors %v, got %v", zeros, got)
+		}
+	}
+
+	// apply some counts
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	last := numSec - 1
+	us, err := rc.callUpdateCounts(map[uint64]int{0: -1, 1: 2, refCounterForEachBatchSize: -1, last: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callCreateAndApplyTransaction(us...); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	applied := map[uint64]uint16{0: 0, 1: 3, refCounterForEachBatchSize: 0, last: 0}
+	verify(applied, numSec)
+
+	// pending updates are reflected during an update session, including
+	// appended sectors which are not on disk yet
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.callIncrement(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.callSetCount(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.callAppend(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.callSetCount(numSec+1, 0); err != nil {
+		t.Fatal(err)
+	}
+	verify(map[uint64]uint16{0: 1, 1: 3, 2: 0, refCounterForEachBatchSize: 0, last: 0, numSec: 1, numSec + 1: 0}, numSec+2)
+
+	// aborting the session drops the pending counts again
+	if err := rc.callAbortUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	verify(applied, numSec)
+
+	// errors returned by fn stop the iteration
+	errStop := errors.New("stop")
+	calls := 0
+	err = rc.callForEach(func(uint64, uint16) error {
+		calls++
+		return errStop
+	})
+	if !errors.Contains(err, errStop) || calls != 1 {
+		t.Fatal("iteration should have stopped", err, calls)
+	}
+
+	// a deleted refcounter can't be iterated
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := rc.callDeleteRefCounter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callForEach(func(uint64, uint16) error { return nil }); !errors.Contains(err, ErrUpdateAfterDelete) {
+		t.Fatal("expected ErrUpdateAfterDelete, got", err)
+	}
+	if _, err := rc.callZeroCountSectors(); !errors.Contains(err, ErrUpdateAfterDelete) {
+		t.Fatal("expected ErrUpdateAfterDelete, got", err)
+	}
+}
+
+// TestRefCounterCountBounds drives counters to both of their bounds and
+// verifies that incrementing past math.MaxUint16 and decrementing past zero
+// fail with typed errors, leave the counts unchanged and don't abort the update
+// session.
+func TestRefCounterCountBounds(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// prepare a refcounter for the tests, sector 0 is at the upper bound,
+	// sector 1 at the lower bound
+	rc := testPrepareRefCounter(3, t)
+	err := rc.callStartUpdate()
+	if err != nil {
+		t.Fatal("Failed to start an update session", err)
+	}
+	var updates []writeaheadlog.Update
+	u, err := rc.callSetCount(0, math.MaxUint16-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, u)
+	u, err = rc.callIncrement(0)
+	if err != nil {
+		t.Fatal("Failed to increment to the upper bound", err)
+	}
+	updates = append(updates, u)
+	u, err = rc.callDecrement(1)
+	if err != nil {
+		t.Fatal("Failed to decrement to the lower bound", err)
+	}
+	updates = append(updates, u)
+	err = rc.callCreateAndApplyTransaction(updates...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callUpdateApplied()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// crossing the bounds fails without changing the counts, the session can
+	// still be used to update other sectors
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal("Failed to start an update session", err)
+	}
+	_, err = rc.callIncrement(0)
+	if !errors.Contains(err, ErrRefCounterOverflow) {
+		t.Fatal("Expected ErrRefCounterOverflow, got:", err)
+	}
+	_, err = rc.callDecrement(1)
+	if !errors.Contains(err, ErrRefCounterUnderflow) {
+		t.Fatal("Expected ErrRefCounterUnderflow, got:", err)
+	}
+	_, err = rc.callUpdateCounts(map[uint64]int{0: 1, 2: 1})
+	if !errors.Contains(err, ErrRefCounterOverflow) {
+		t.Fatal("Expected ErrRefCounterOverflow, got:", err)
+	}
+	_, err = rc.callUpdateCounts(map[uint64]int{1: -1, 2: 1})
+	if !errors.Contains(err, ErrRefCounterUnderflow) {
+		t.Fatal("Expected ErrRefCounterUnderflow, got:", err)
+	}
+	u, err = rc.callIncrement(2)
+	if err != nil {
+		t.Fatal("Failed to increment after crossing the bounds", err)
+	}
+	err = rc.callCreateAndApplyTransaction(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callUpdateApplied()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verify the counts on disk
+	for secIdx, expected := range []uint16{math.MaxUint16, 0, 2} {
+		count, err := rc.readCount(uint64(secIdx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != expected {
+			t.Fatalf("wrong count for sector %v. Expected %v, got %v", secIdx, expected, count)
+		}
+	}
+}
+
 // TestRefCounterLoad specifically tests refcounter's Load method
 func TestRefCounterLoad(t *testing.T) {
 	if testing.Short() {
@@ -647,15 +1161,44 @@ func TestRefCounterSetCount(t *testing.T) {
 	if val != count {
 		t.Fatalf("read wrong value from disk after set count. Expected %d, got %d", count, val)
 	}
-}
+	// the sectors in between have a count of 0
+	for i := oldNumSec; i < secIdx; i++ {
+		if val, err = rc.callCount(i); err != nil || val != 0 {
+			t.Fatalf("expected count 0 for sector %d, got %d (%v)", i, val, err)
+		}
+	}
 
-// TestRefCounterStartUpdate tests that the callStartUpdate method respects the
-// timeout limits set for it.
-func TestRefCounterStartUpdate(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
+	// test callSetCount outside of an update session
+	_, err = rc.callSetCount(0, 1)
+	if !errors.Contains(err, ErrUpdateWithoutUpdateSession) {
+		t.Fatal("Expected ErrUpdateWithoutUpdateSession, got:", err)
 	}
-	t.Parallel()
+
+	// test callSetCount on a sector that can't be addressed
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal("Failed to start an update session", err)
+	}
+	oldNumSec = rc.numSectors
+	_, err = rc.callSetCount(math.MaxUint64, 1)
+	if !errors.Contains(err, ErrInvalidSectorNumber) {
+		t.Fatal("Expected ErrInvalidSectorNumber, got:", err)
+	}
+	if rc.numSectors != oldNumSec {
+		t.Fatalf("number of sectors changed after failed set count. Expected %d, got %d", oldNumSec, rc.numSectors)
+	}
+	if err := rc.callAbortUpdate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRefCounterStartUpdate tests that the callStartUpdate method respects the
+// timeout limits set for it.
+func TestRefCounterStartUpdate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
 
 	// prepare a refcounter for the tests
 	rc := testPrepareRefCounter(2+fastrand.Uint64n(10), t)
@@ -755,6 +1298,261 @@ func TestRefCounterSwap(t *testing.T) {
 	}
 }
 
+// TestRefCounterSwapBatch tests that the callSwapBatch method results in
+// correct values
+func TestRefCounterSwapBatch(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// prepare a refcounter for the tests, the last sector must not be part
+	// of the other pairs
+	rc := testPrepareRefCounter(5+fastrand.Uint64n(10), t)
+	var updates []writeaheadlog.Update
+	err := rc.callStartUpdate()
+	if err != nil {
+		t.Fatal("Failed to start an update session", err)
+	}
+
+	// increment sectors 1 and 2 to different values, so we can tell the
+	// values apart
+	for _, secIdx := range []uint64{1, 2, 2} {
+		u, err := rc.callIncrement(secIdx)
+		if err != nil {
+			t.Fatal("Failed to create increment update", err)
+		}
+		updates = append(updates, u)
+	}
+
+	// check behaviour on bad sector numbers and overlapping pairs, no values
+	// should change
+	_, err = rc.callSwapBatch([][2]uint64{{0, 1}, {math.MaxInt64, 2}})
+	if !errors.Contains(err, ErrInvalidSectorNumber) {
+		t.Fatal("Expected ErrInvalidSectorNumber, got:", err)
+	}
+	_, err = rc.callSwapBatch([][2]uint64{{0, 1}, {1, 2}})
+	if !errors.Contains(err, ErrOverlappingSwapPairs) {
+		t.Fatal("Expected ErrOverlappingSwapPairs, got:", err)
+	}
+	if len(rc.newSectorCounts) != 2 || rc.newSectorCounts[1] != 2 || rc.newSectorCounts[2] != 3 {
+		t.Fatal("failed batch shouldn't change any counts", rc.newSectorCounts)
+	}
+
+	// test callSwapBatch, swapping a sector with itself is allowed
+	us, err := rc.callSwapBatch([][2]uint64{{0, 1}, {2, 3}, {rc.numSectors - 1, rc.numSectors - 1}})
+	if err != nil {
+		t.Fatal("Failed to create swap update", err)
+	}
+	if len(us) != 6 {
+		t.Fatal("unexpected number of updates", len(us))
+	}
+	updates = append(updates, us...)
+	expected := []uint16{2, 1, 1, 3}
+	for secIdx, v := range expected {
+		count, err := rc.readCount(uint64(secIdx))
+		if err != nil {
+			t.Fatal("Failed to read value after swap", err)
+		}
+		if count != v {
+			t.Fatalf("read wrong value after swap for sector %d. Expected %d, got %d", secIdx, v, count)
+		}
+	}
+
+	// apply the updates and check the values again
+	err = rc.callCreateAndApplyTransaction(updates...)
+	if err != nil {
+		t.Fatal("Failed to apply updates", err)
+	}
+	err = rc.callUpdateApplied()
+	if err != nil {
+		t.Fatal("Failed to finish the update session:", err)
+	}
+	// verify values on disk (the in-mem map is now gone)
+	for secIdx, v := range expected {
+		count, err := rc.readCount(uint64(secIdx))
+		if err != nil {
+			t.Fatal("Failed to read value from disk after swap", err)
+		}
+		if count != v {
+			t.Fatalf("read wrong value from disk after swap for sector %d. Expected %d, got %d", secIdx, v, count)
+		}
+	}
+}
+
+// TestRefCounterTotalReferences verifies that callTotalReferences sums the
+// counts on disk overlaid with the pending counts of an update session without
+// overflowing.
+func TestRefCounterTotalReferences(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	numSec := uint64(refCounterForEachBatchSize + 10)
+	rc := testPrepareRefCounter(numSec, t)
+	verify := func(expected uint64) {
+		t.Helper()
+		total, err := rc.callTotalReferences()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != expected {
+			t.Fatalf("expected %v total references, got %v", expected, total)
+		}
+	}
+	verify(numSec)
+
+	// max out the counts of two sectors in different batches, their sum
+	// doesn't fit into a uint16
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	last := numSec - 1
+	var updates []writeaheadlog.Update
+	for _, secIdx := range []uint64{0, last} {
+		u, err := rc.callSetCount(secIdx, math.MaxUint16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updates = append(updates, u)
+	}
+	if err := rc.callCreateAndApplyTransaction(updates...); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	applied := numSec - 2 + 2*math.MaxUint16
+	verify(applied)
+
+	// pending updates are reflected during an update session, including
+	// appended sectors which are not on disk yet
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.callSetCount(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.callAppend(); err != nil {
+		t.Fatal(err)
+	}
+	verify(applied)
+	if _, err := rc.callIncrement(numSec); err != nil {
+		t.Fatal(err)
+	}
+	verify(applied + 1)
+
+	// aborting the session drops the pending counts again
+	if err := rc.callAbortUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	verify(applied)
+}
+
+// TestRefCounterUpdateCounts tests that callUpdateCounts applies all deltas
+// in a batch, coalesces adjacent sectors and fails the whole batch on invalid
+// sectors, underflows and overflows.
+func TestRefCounterUpdateCounts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	run := func(t *testing.T, rc *refCounter) {
+		err := rc.callStartUpdate()
+		if err != nil {
+			t.Fatal("Failed to start an update session", err)
+		}
+
+		// failed batches don't change any counts
+		_, err = rc.callUpdateCounts(map[uint64]int{0: 1, rc.numSectors: 1})
+		if !errors.Contains(err, ErrInvalidSectorNumber) {
+			t.Fatal("Expected ErrInvalidSectorNumber, got:", err)
+		}
+		_, err = rc.callUpdateCounts(map[uint64]int{0: 1, 1: -2})
+		if !errors.Contains(err, ErrRefCounterUnderflow) {
+			t.Fatal("Expected ErrRefCounterUnderflow, got:", err)
+		}
+		_, err = rc.callUpdateCounts(map[uint64]int{0: 1, 1: math.MaxUint16})
+		if !errors.Contains(err, ErrRefCounterOverflow) {
+			t.Fatal("Expected ErrRefCounterOverflow, got:", err)
+		}
+		if len(rc.newSectorCounts) != 0 {
+			t.Fatal("failed batch shouldn't change any counts", rc.newSectorCounts)
+		}
+
+		// sectors 0-2 and 4-5 are adjacent, sector 7 isn't and sector 6 has
+		// no delta
+		us, err := rc.callUpdateCounts(map[uint64]int{0: 2, 1: -1, 2: 3, 4: 1, 5: -1, 6: 0, 7: 5})
+		if err != nil {
+			t.Fatal("Failed to create update counts updates", err)
+		}
+		if len(us) != 3 || us[0].Name != updateNameRCWriteRangeAt || us[1].Name != updateNameRCWriteRangeAt || us[2].Name != updateNameRCWriteAt {
+			t.Fatal("unexpected updates", us)
+		}
+		expected := []uint16{3, 0, 4, 1, 2, 0, 1, 6}
+		check := func() {
+			t.Helper()
+			for secIdx, v := range expected {
+				count, err := rc.readCount(uint64(secIdx))
+				if err != nil {
+					t.Fatal("Failed to read count", err)
+				}
+				if count != v {
+					t.Fatalf("wrong count for sector %d. Expected %d, got %d", secIdx, v, count)
+				}
+			}
+		}
+		check()
+
+		// apply the updates and check the values again
+		err = rc.callCreateAndApplyTransaction(us...)
+		if err != nil {
+			t.Fatal("Failed to apply updates", err)
+		}
+		err = rc.callUpdateApplied()
+		if err != nil {
+			t.Fatal("Failed to finish the update session:", err)
+		}
+		check()
+
+		// a batch of all sectors is written with a single update
+		err = rc.callStartUpdate()
+		if err != nil {
+			t.Fatal("Failed to start an update session", err)
+		}
+		deltas := make(map[uint64]int)
+		for secIdx := range expected {
+			deltas[uint64(secIdx)] = 1
+			expected[secIdx]++
+		}
+		us, err = rc.callUpdateCounts(deltas)
+		if err != nil {
+			t.Fatal("Failed to create update counts updates", err)
+		}
+		if len(us) != 1 {
+			t.Fatal("adjacent sectors should be written with a single update", len(us))
+		}
+		err = rc.callCreateAndApplyTransaction(us...)
+		if err != nil {
+			t.Fatal("Failed to apply updates", err)
+		}
+		err = rc.callUpdateApplied()
+		if err != nil {
+			t.Fatal("Failed to finish the update session:", err)
+		}
+		check()
+	}
+
+	t.Run("Disk", func(t *testing.T) {
+		run(t, testPrepareRefCounter(8, t))
+	})
+	t.Run("Memory", func(t *testing.T) {
+		run(t, newInMemoryRefCounter(8))
+	})
+}
+
 // TestRefCounterUpdateApplied tests that the callUpdateApplied method cleans up
 // after itself
 func TestRefCounterUpdateApplied(t *testing.T) {
@@ -863,6 +1661,105 @@ func TestRefCounterUpdateSessionConstraints(t *testing.T) {
 	}
 }
 
+// TestRefCounterAbortUpdate ensures that callAbortUpdate() discards the
+// pending updates of a session and enforces the same restrictions as
+// callUpdateApplied()
+func TestRefCounterAbortUpdate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// prepare a refcounter for the tests
+	numSec := 2 + fastrand.Uint64n(10)
+	rc := testPrepareRefCounter(numSec, t)
+
+	// make sure we cannot abort outside of an update session
+	if err := rc.callAbortUpdate(); !errors.Contains(err, ErrUpdateWithoutUpdateSession) {
+		t.Fatalf("expected %v but was %v", ErrUpdateWithoutUpdateSession, err)
+	}
+
+	// start an update session and create some updates
+	err := rc.callStartUpdate()
+	if err != nil {
+		t.Fatal("Failed to start an update session", err)
+	}
+	if _, err = rc.callIncrement(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rc.callAppend(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rc.callDropSectors(2); err != nil {
+		t.Fatal(err)
+	}
+
+	// abort the session and make sure nothing changed
+	err = rc.callAbortUpdate()
+	if err != nil {
+		t.Fatal("Failed to abort the update session:", err)
+	}
+	if rc.numSectors != numSec {
+		t.Fatalf("expected %v sectors, got %v", numSec, rc.numSectors)
+	}
+	if len(rc.newSectorCounts) != 0 || len(rc.newAccessTimes) != 0 {
+		t.Fatal("abort failed to clean up the in-mem overrides")
+	}
+	if count, err := rc.callCount(0); err != nil || count != 1 {
+		t.Fatalf("expected count 1, got %v (%v)", count, err)
+	}
+	fi, err := os.Stat(rc.filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(refCounterHeaderSize+numSec*2) {
+		t.Fatal("abort changed the refcounter file on disk")
+	}
+
+	// a new session can be started right away, applied transactions are kept
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal("Failed to start an update session", err)
+	}
+	u, err := rc.callAppend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rc.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rc.callAppend(); err != nil {
+		t.Fatal(err)
+	}
+	if err = rc.callAbortUpdate(); err != nil {
+		t.Fatal("Failed to abort the update session:", err)
+	}
+	if rc.numSectors != numSec+1 {
+		t.Fatalf("expected %v sectors, got %v", numSec+1, rc.numSectors)
+	}
+
+	// a session that created a delete update can't be aborted
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal("Failed to start an update session", err)
+	}
+	u, err = rc.callDeleteRefCounter()
+	if err != nil {
+		t.Fatal("Failed to create a delete update", err)
+	}
+	if err = rc.callAbortUpdate(); !errors.Contains(err, ErrUpdateAfterDelete) {
+		t.Fatalf("expected %v but was %v", ErrUpdateAfterDelete, err)
+	}
+	err = rc.callCreateAndApplyTransaction(u)
+	if err != nil {
+		t.Fatal("Failed to apply a delete update:", err)
+	}
+	err = rc.callUpdateApplied()
+	if err != nil {
+		t.Fatal("Failed to finish the update session:", err)
+	}
+}
+
 // TestRefCounterWALFunctions tests refCounter's functions for creating and
 // reading WAL updates
 func TestRefCounterWALFunctions(t *testing.T) {
@@ -889,6 +1786,170 @@ func TestRefCounterWALFunctions(t *testing.T) {
 	if wpath != rpath || wsec != rsec {
 		t.Fatalf("wrong values read from Truncate update. Expected %s, %d found %s, %d", wpath, wsec, rpath, rsec)
 	}
+
+	wvals := []uint16{1, 2, 3}
+	u = createWriteRangeAtUpdate(wpath, wsec, wvals)
+	rpath, rsec, rvals, err := readWriteRangeAtUpdate(u)
+	if err != nil {
+		t.Fatal("Failed to read writeRangeAt update:", err)
+	}
+	if wpath != rpath || wsec != rsec || !reflect.DeepEqual(wvals, rvals) {
+		t.Fatalf("wrong values read from WriteRangeAt update. Expected %s, %d, %v, found %s, %d, %v", wpath, wsec, wvals, rpath, rsec, rvals)
+	}
+	u.Instructions = u.Instructions[:17]
+	if _, _, _, err := readWriteRangeAtUpdate(u); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+		t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+	}
+
+	npath := "test/newPath"
+	u = createRenameUpdate(wpath, npath)
+	rpath, rnpath, err := readRenameUpdate(u)
+	if err != nil {
+		t.Fatal("Failed to read a rename update:", err)
+	}
+	if wpath != rpath || npath != rnpath {
+		t.Fatalf("wrong values read from Rename update. Expected %s, %s found %s, %s", wpath, npath, rpath, rnpath)
+	}
+}
+
+// TestRefCounterWALFunctionsFuzz feeds random and malformed instructions to
+// the decoders of the refcounter's WAL updates. The decoders must never panic,
+// must reject malformed instructions and must decode valid instructions to the
+// values they were encoded from.
+func TestRefCounterWALFunctionsFuzz(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// random instructions either fail to decode or decode to values that
+	// encode to the same instructions
+	for i := 0; i < 10000; i++ {
+		b := fastrand.Bytes(fastrand.Intn(64))
+		// the leading sector index or length is rarely valid, make it
+		// small half of the time to reach the rest of the decoders
+		if len(b) >= 16 && fastrand.Intn(2) == 0 {
+			copy(b[4:16], make([]byte, 12))
+		}
+		if path, secIdx, value, err := readWriteAtUpdate(writeaheadlog.Update{Instructions: b}); err == nil {
+			if u := createWriteAtUpdate(path, secIdx, value); !bytes.Equal(u.Instructions, b) {
+				t.Fatalf("WriteAt update %x decoded to %x", b, u.Instructions)
+			}
+		}
+		if path, newNumSec, err := readTruncateUpdate(writeaheadlog.Update{Instructions: b}); err == nil {
+			if u := createTruncateUpdate(path, newNumSec); !bytes.Equal(u.Instructions, b) {
+				t.Fatalf("Truncate update %x decoded to %x", b, u.Instructions)
+			}
+		}
+		if path, start, values, err := readWriteRangeAtUpdate(writeaheadlog.Update{Instructions: b}); err == nil {
+			if u := createWriteRangeAtUpdate(path, start, values); !bytes.Equal(u.Instructions, b) {
+				t.Fatalf("WriteRangeAt update %x decoded to %x", b, u.Instructions)
+			}
+		}
+		if oldPath, newPath, err := readRenameUpdate(writeaheadlog.Update{Instructions: b}); err == nil {
+			if u := createRenameUpdate(oldPath, newPath); !bytes.Equal(u.Instructions, b) {
+				t.Fatalf("Rename update %x decoded to %x", b, u.Instructions)
+			}
+		}
+		_, _, _, _ = readWriteAccessAtUpdate(writeaheadlog.Update{Instructions: b})
+		_, _, _, _, _ = readWriteChecksumsUpdate(writeaheadlog.Update{Instructions: b})
+	}
+
+	// valid instructions that are cut short are rejected
+	path := "test/path"
+	for n := 0; n < 10; n++ {
+		u := createWriteAtUpdate(path, 1, 2)
+		if _, _, _, err := readWriteAtUpdate(writeaheadlog.Update{Instructions: u.Instructions[:n]}); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+		}
+	}
+	for n := 0; n < 8; n++ {
+		u := createTruncateUpdate(path, 1)
+		if _, _, err := readTruncateUpdate(writeaheadlog.Update{Instructions: u.Instructions[:n]}); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+		}
+		u = createRenameUpdate(path, path)
+		if _, _, err := readRenameUpdate(writeaheadlog.Update{Instructions: u.Instructions[:n]}); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+		}
+	}
+	values := []uint16{1, 2, 3}
+	for n := 0; n < 16+2*len(values); n++ {
+		u := createWriteRangeAtUpdate("", 1, values)
+		if _, _, _, err := readWriteRangeAtUpdate(writeaheadlog.Update{Instructions: u.Instructions[:n]}); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+		}
+	}
+	// instructions that are cut at the end of an entry only lose entries
+	entrySize := 8 + refCounterAccessTimeSize
+	accessAt := createWriteAccessAtUpdate(path, map[uint64]uint32{1: 2, 3: 4})
+	for n := 0; n < len(accessAt.Instructions); n++ {
+		if n >= 8+len(path) && (n-8-len(path))%entrySize == 0 {
+			continue
+		}
+		if _, _, _, err := readWriteAccessAtUpdate(writeaheadlog.Update{Instructions: accessAt.Instructions[:n]}); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+		}
+	}
+	entrySize = 8 + refCounterChecksumSize
+	checksums := createWriteChecksumsUpdate(path, 4, map[uint64]uint32{1: 2, 3: 4})
+	for n := 0; n < len(checksums.Instructions); n++ {
+		if n >= 16+len(path) && (n-16-len(path))%entrySize == 0 {
+			continue
+		}
+		if _, _, _, _, err := readWriteChecksumsUpdate(writeaheadlog.Update{Instructions: checksums.Instructions[:n]}); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+		}
+	}
+
+	// path lengths that exceed the instructions are rejected
+	for _, pathLen := range []uint64{uint64(len(path)) + 1, math.MaxUint64} {
+		u := writeaheadlog.Update{Instructions: append([]byte(nil), accessAt.Instructions...)}
+		binary.LittleEndian.PutUint64(u.Instructions, pathLen)
+		if _, _, _, err := readWriteAccessAtUpdate(u); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+		}
+		u = writeaheadlog.Update{Instructions: append([]byte(nil), checksums.Instructions...)}
+		binary.LittleEndian.PutUint64(u.Instructions, pathLen)
+		if _, _, _, _, err := readWriteChecksumsUpdate(u); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+		}
+	}
+
+	// sector indices that can't be addressed in the files are rejected
+	if _, _, _, err := readWriteAtUpdate(createWriteAtUpdate(path, refCounterMaxSectors, 1)); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+		t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+	}
+	if _, _, err := readTruncateUpdate(createTruncateUpdate(path, math.MaxUint64)); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+		t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+	}
+	if _, _, _, err := readWriteRangeAtUpdate(createWriteRangeAtUpdate(path, refCounterMaxSectors-2, values)); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+		t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+	}
+	if _, _, _, err := readWriteRangeAtUpdate(createWriteRangeAtUpdate(path, refCounterMaxSectors-3, values)); err != nil {
+		t.Fatal(err)
+	}
+	u := createWriteAccessAtUpdate(path, map[uint64]uint32{refCounterMaxSectors: 1})
+	if _, _, _, err := readWriteAccessAtUpdate(u); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+		t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+	}
+	u = createWriteAccessAtUpdate(path, map[uint64]uint32{1: 1, math.MaxUint64: 1})
+	if _, _, _, err := readWriteAccessAtUpdate(u); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+		t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+	}
+
+	// pages beyond the number of pages and more pages than the counter area
+	// of a refcounter can have are rejected
+	for _, u := range []writeaheadlog.Update{
+		createWriteChecksumsUpdate(path, 3, map[uint64]uint32{1: 2, 3: 4}),
+		createWriteChecksumsUpdate(path, refCounterChecksumMaxPages, map[uint64]uint32{math.MaxUint64: 1}),
+		createWriteChecksumsUpdate(path, refCounterChecksumMaxPages+1, nil),
+		createWriteChecksumsUpdate(path, math.MaxUint64, map[uint64]uint32{1: 2}),
+	} {
+		if _, _, _, _, err := readWriteChecksumsUpdate(u); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+		}
+	}
 }
 
 // TestRefCounterNumSectorsUnderflow tests for and guards against an NDF that
@@ -1009,3 +2070,2175 @@ func writeVal(path string, secIdx uint64, val uint16) (err error) {
 	}
 	return nil
 }
+
+// TestRefCounterLastAccess tests that a refcounter created with access time
+// tracking keeps track of when its sectors were last accessed.
+func TestRefCounterLastAccess(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// a refcounter without access time tracking should return an error
+	rc := testPrepareRefCounter(2, t)
+	if _, err := rc.callLastAccess(0); !errors.Contains(err, ErrAccessTimesNotTracked) {
+		t.Fatal("Expected ErrAccessTimesNotTracked, got:", err)
+	}
+
+	// prepare a refcounter with access time tracking
+	td := build.TempDir(t.Name())
+	err := os.MkdirAll(td, modules.DefaultDirPerm)
+	if err != nil {
+		t.Fatal("Failed to create test directory:", err)
+	}
+	path := filepath.Join(td, "access"+refCounterExtension)
+	start := time.Now().Truncate(time.Second)
+	rc, err = newRefCounterWithAccessTimes(path, 2, testWAL)
+	if err != nil {
+		t.Fatal("Failed to create a reference counter:", err)
+	}
+
+	// all sectors should be considered accessed at creation
+	at0, err := rc.callLastAccess(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at0.Before(start) {
+		t.Fatalf("unexpected access time %v, expected at least %v", at0, start)
+	}
+
+	// wait a bit so the next access gets a distinct timestamp, then increment
+	// the second sector and append a third one
+	time.Sleep(time.Second)
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u1, err := rc.callIncrement(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u2, err := rc.callAppend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	at1, err := rc.callLastAccess(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !at1.After(at0) {
+		t.Fatalf("expected access time of incremented sector %v to be after %v", at1, at0)
+	}
+	err = rc.callCreateAndApplyTransaction(u1, u2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callUpdateApplied()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reload the refcounter and verify the access times were persisted
+	rc, err = loadRefCounter(path, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rc.staticTrackAccess {
+		t.Fatal("expected loaded refcounter to track access times")
+	}
+	for i, expected := range []time.Time{at0, at1, at1} {
+		at, err := rc.callLastAccess(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !at.Equal(expected) && !(i == 2 && at.After(at0)) {
+			t.Fatalf("sector %v: expected access time %v, got %v", i, expected, at)
+		}
+	}
+
+	// swap the first and the second sector and drop the last one
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	us, err := rc.callSwap(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ud, err := rc.callDropSectors(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callCreateAndApplyTransaction(append(us, ud)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callUpdateApplied()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at, err := rc.callLastAccess(0); err != nil || !at.Equal(at1) {
+		t.Fatal("access times weren't swapped", at, err)
+	}
+	if at, err := rc.callLastAccess(1); err != nil || !at.Equal(at0) {
+		t.Fatal("access times weren't swapped", at, err)
+	}
+	fi, err := os.Stat(accessTimeFilePath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 2*refCounterAccessTimeSize {
+		t.Fatalf("expected access time file to be truncated to %v bytes, got %v", 2*refCounterAccessTimeSize, fi.Size())
+	}
+
+	// delete the refcounter and verify the access time file is gone too
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := rc.callDeleteRefCounter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callCreateAndApplyTransaction(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callUpdateApplied()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(accessTimeFilePath(path)); !os.IsNotExist(err) {
+		t.Fatal("expected access time file to be deleted", err)
+	}
+}
+
+// TestRefCounterAccessTimeUpdate verifies that the pending access times of a
+// transaction are combined into a single update and that applying it writes
+// all of them.
+func TestRefCounterAccessTimeUpdate(t *testing.T) {
+	t.Parallel()
+
+	td := build.TempDir(t.Name())
+	err := os.MkdirAll(td, modules.DefaultDirPerm)
+	if err != nil {
+		t.Fatal("Failed to create test directory:", err)
+	}
+	path := filepath.Join(td, "access"+refCounterExtension)
+
+	// create an update for two runs of adjacent sectors and a lone one
+	times := map[uint64]uint32{
+		0: 10,
+		1: 11,
+		2: 12,
+		5: 15,
+		6: 16,
+		9: 19,
+	}
+	u := createWriteAccessAtUpdate(path, times)
+
+	// the update should decode to the sorted entries
+	p, indices, decoded, err := readWriteAccessAtUpdate(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != path {
+		t.Fatalf("expected path %v, got %v", path, p)
+	}
+	if len(indices) != len(times) {
+		t.Fatalf("expected %v entries, got %v", len(times), len(indices))
+	}
+	for i, secIdx := range indices {
+		if i > 0 && indices[i-1] >= secIdx {
+			t.Fatal("entries are not sorted", indices)
+		}
+		if decoded[i] != times[secIdx] {
+			t.Fatalf("sector %v: expected %v, got %v", secIdx, times[secIdx], decoded[i])
+		}
+	}
+
+	// a truncated update is rejected
+	truncated := u
+	truncated.Instructions = u.Instructions[:len(u.Instructions)-1]
+	if _, _, _, err := readWriteAccessAtUpdate(truncated); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+		t.Fatal("expected ErrInvalidUpdateInstruction, got", err)
+	}
+
+	// apply the update and verify the file contents
+	if err := applyWriteAccessAtUpdate(u); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(accessTimeFilePath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 10*refCounterAccessTimeSize {
+		t.Fatalf("unexpected file size %v", len(b))
+	}
+	for secIdx := uint64(0); secIdx < 10; secIdx++ {
+		at := binary.LittleEndian.Uint32(b[secIdx*refCounterAccessTimeSize:])
+		if at != times[secIdx] {
+			t.Fatalf("sector %v: expected %v, got %v", secIdx, times[secIdx], at)
+		}
+	}
+
+	// a transaction that touches many sectors creates a single update
+	rc, err := newRefCounterWithAccessTimes(filepath.Join(td, "many"+refCounterExtension), 100, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(0); i < 100; i += 3 {
+		if _, err := rc.callIncrement(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rc.mu.Lock()
+	updates := rc.accessTimeUpdates()
+	rc.mu.Unlock()
+	if len(updates) != 1 {
+		t.Fatalf("expected a single access time update, got %v", len(updates))
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRefCounterValidate tests that callValidate and loadRefCounter detect a
+// corrupt refcounter.
+func TestRefCounterValidate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// prepare a refcounter for the tests
+	numSec := 2 + fastrand.Uint64n(10)
+	rc := testPrepareRefCounter(numSec, t)
+
+	// happy case
+	if err := rc.callValidate(); err != nil {
+		t.Fatal("Failed to validate refcounter:", err)
+	}
+
+	// an unknown version is detected
+	rc.Version = [8]byte{3}
+	if err := rc.callValidate(); !errors.Contains(err, ErrInvalidVersion) {
+		t.Fatal("Expected ErrInvalidVersion, got:", err)
+	}
+	rc.Version = refCounterVersion
+
+	// a pending count for a sector that doesn't exist is detected
+	rc.newSectorCounts[rc.numSectors] = 1
+	if err := rc.callValidate(); !errors.Contains(err, ErrCorruptRefCounter) {
+		t.Fatal("Expected ErrCorruptRefCounter, got:", err)
+	}
+	delete(rc.newSectorCounts, rc.numSectors)
+
+	// dropping sectors discards their pending counts
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal("Failed to start an update session", err)
+	}
+	if _, err := rc.callIncrement(rc.numSectors - 1); err != nil {
+		t.Fatal("Failed to create increment update:", err)
+	}
+	u, err := rc.callDropSectors(1)
+	if err != nil {
+		t.Fatal("Failed to create truncate update:", err)
+	}
+	if err := rc.callValidate(); err != nil {
+		t.Fatal("Failed to validate refcounter during update session:", err)
+	}
+	if err := rc.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal("Failed to apply truncate update:", err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal("Failed to finish the update session:", err)
+	}
+	if err := rc.callValidate(); err != nil {
+		t.Fatal("Failed to validate refcounter:", err)
+	}
+
+	// a file that isn't a whole number of counters is detected, both by
+	// callValidate and on load
+	f, err := os.OpenFile(rc.filepath, os.O_APPEND|os.O_WRONLY, modules.DefaultFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callValidate(); !errors.Contains(err, ErrMisalignedFile) {
+		t.Fatal("Expected ErrMisalignedFile, got:", err)
+	}
+	if _, err := loadRefCounter(rc.filepath, testWAL); !errors.Contains(err, ErrMisalignedFile) {
+		t.Fatal("Expected ErrMisalignedFile, got:", err)
+	}
+
+	// a file that doesn't match the number of sectors in memory is detected
+	if _, err := f.Write([]byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callValidate(); !errors.Contains(err, ErrCorruptRefCounter) {
+		t.Fatal("Expected ErrCorruptRefCounter, got:", err)
+	}
+	// the counter that was appended without going through the refcounter
+	// doesn't match the checksums
+	if _, err := loadRefCounter(rc.filepath, testWAL); !errors.Contains(err, ErrCorruptRefCounter) {
+		t.Fatal("Expected ErrCorruptRefCounter, got:", err)
+	}
+}
+
+// TestRefCounterConcurrentReaders verifies that many readers can read counts
+// while update sessions are prepared and applied concurrently. Every session
+// moves a reference between two sectors, so every count is 1 or 2 and the sum
+// of the counts is the number of sectors plus at most one pending reference.
+// Run with the race detector.
+func TestRefCounterConcurrentReaders(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	numSec := uint64(100)
+	rc := testPrepareRefCounter(numSec, t)
+
+	// start the readers
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	errChan := make(chan error, 100)
+	checkCounts := func(counts []uint16) error {
+		var sum uint64
+		for _, c := range counts {
+			if c != 1 && c != 2 {
+				return fmt.Errorf("unexpected count %v", c)
+			}
+			sum += uint64(c)
+		}
+		if sum != numSec && sum != numSec+1 {
+			return fmt.Errorf("unexpected sum of counts %v", sum)
+		}
+		return nil
+	}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				var err error
+				switch i % 3 {
+				case 0:
+					var c uint16
+					c, err = rc.callCount(fastrand.Uint64n(numSec))
+					if err == nil && c != 1 && c != 2 {
+						err = fmt.Errorf("unexpected count %v", c)
+					}
+				case 1:
+					var counts []uint16
+					counts, err = rc.callCountRange(0, numSec)
+					if err == nil {
+						err = checkCounts(counts)
+					}
+				case 2:
+					counts := make([]uint16, 0, numSec)
+					err = rc.callForEach(func(_ uint64, c uint16) error {
+						counts = append(counts, c)
+						return nil
+					})
+					if err == nil {
+						err = checkCounts(counts)
+					}
+				}
+				if err != nil {
+					errChan <- err
+					return
+				}
+			}
+		}(i)
+	}
+
+	// run update sessions that increment a sector, swap it with another one
+	// and decrement the other one
+	for i := uint64(0); i < 50; i++ {
+		a, b := i%numSec, (i*7+1)%numSec
+		if a == b {
+			continue
+		}
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		u, err := rc.callIncrement(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		us := []writeaheadlog.Update{u}
+		swapUpdates, err := rc.callSwap(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		us = append(us, swapUpdates...)
+		u, err = rc.callDecrement(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		us = append(us, u)
+		if err := rc.callCreateAndApplyTransaction(us...); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	close(errChan)
+	for err := range errChan {
+		t.Fatal(err)
+	}
+}
+
+// TestRefCounterConcurrentReadersResize verifies that readers see a consistent
+// number of sectors while update sessions append and drop sectors
+// concurrently. Every session either appends a sector and is aborted, or
+// appends a sector and drops it again in a second session, so readers see
+// either numSec or numSec+1 sectors with a count of 1 each. Run with the race
+// detector.
+func TestRefCounterConcurrentReadersResize(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	numSec := uint64(10)
+	rc := testPrepareRefCounter(numSec, t)
+
+	// start the readers
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	errChan := make(chan error, 100)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				var err error
+				switch i % 3 {
+				case 0:
+					if n := rc.callNumSectors(); n != numSec && n != numSec+1 {
+						err = fmt.Errorf("unexpected number of sectors %v", n)
+					}
+				case 1:
+					// the appended sector either exists with a count of 1
+					// or not at all
+					var c uint16
+					c, err = rc.callCount(numSec)
+					if errors.Contains(err, ErrInvalidSectorNumber) {
+						err = nil
+					} else if err == nil && c != 1 {
+						err = fmt.Errorf("unexpected count %v", c)
+					}
+				case 2:
+					var n uint64
+					err = rc.callForEach(func(_ uint64, c uint16) error {
+						n++
+						if c != 1 {
+							return fmt.Errorf("unexpected count %v", c)
+						}
+						return nil
+					})
+					if err == nil && n != numSec && n != numSec+1 {
+						err = fmt.Errorf("unexpected number of counts %v", n)
+					}
+				}
+				if err != nil {
+					errChan <- err
+					return
+				}
+			}
+		}(i)
+	}
+
+	// append a sector in every session, then either abort the session or
+	// drop the sector again in the next one
+	for i := 0; i < 50; i++ {
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		u, err := rc.callAppend()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			if err := rc.callAbortUpdate(); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := rc.callCreateAndApplyTransaction(u); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		u, err = rc.callDropSectors(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callCreateAndApplyTransaction(u); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	close(errChan)
+	for err := range errChan {
+		t.Fatal(err)
+	}
+}
+
+// TestRefCounterRepair tests that a file that ends with a partial counter is
+// rejected on load and that it can be repaired, while other corruption can't.
+func TestRefCounterRepair(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// a clean file loads and repairing it is a no-op
+	numSec := uint64(10)
+	rc := testPrepareRefCounter(numSec, t)
+	if err := rc.callRepair(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRefCounter(rc.filepath, testWAL); err != nil {
+		t.Fatal(err)
+	}
+
+	// a file with an extra byte is rejected on load
+	f, err := os.OpenFile(rc.filepath, os.O_APPEND|os.O_WRONLY, modules.DefaultFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRefCounter(rc.filepath, testWAL); !errors.Contains(err, ErrMisalignedFile) {
+		t.Fatal("Expected ErrMisalignedFile, got:", err)
+	}
+
+	// it can't be repaired during an update session
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callRepair(); !errors.Contains(err, ErrRepairDuringUpdate) {
+		t.Fatal("Expected ErrRepairDuringUpdate, got:", err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+
+	// repair the file, the partial counter is dropped and the counts are
+	// unchanged
+	opened, err := openRefCounter(rc.filepath, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.numSectors != numSec {
+		t.Fatal("unexpected number of sectors", opened.numSectors)
+	}
+	if err := opened.callRepair(); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(rc.filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(offset(numSec)) {
+		t.Fatal("unexpected file size", fi.Size())
+	}
+	loaded, err := loadRefCounter(rc.filepath, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts, err := loaded.callCountRange(0, numSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range counts {
+		if c != 1 {
+			t.Fatalf("unexpected count %v for sector %v", c, i)
+		}
+	}
+
+	// a file that lost half of its last counter is misaligned as well, but
+	// dropping the partial counter doesn't match the checksums
+	if err := os.Truncate(rc.filepath, int64(offset(numSec))-1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRefCounter(rc.filepath, testWAL); !errors.Contains(err, ErrMisalignedFile) {
+		t.Fatal("Expected ErrMisalignedFile, got:", err)
+	}
+	if err := loaded.callRepair(); !errors.Contains(err, ErrCorruptRefCounter) {
+		t.Fatal("Expected ErrCorruptRefCounter, got:", err)
+	}
+	if loaded.numSectors != numSec-1 {
+		t.Fatal("unexpected number of sectors", loaded.numSectors)
+	}
+
+	// a file that is shorter than its header is corrupt and can't be repaired
+	if err := os.Truncate(rc.filepath, refCounterHeaderSize-1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRefCounter(rc.filepath, testWAL); !errors.Contains(err, io.EOF) {
+		t.Fatal("Expected EOF, got:", err)
+	}
+	if err := loaded.callRepair(); !errors.Contains(err, ErrCorruptRefCounter) {
+		t.Fatal("Expected ErrCorruptRefCounter, got:", err)
+	}
+}
+
+// TestRefCounterRename tests that a refcounter can be renamed and that it is
+// not possible to do so during an update session.
+func TestRefCounterRename(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// prepare a refcounter that tracks access times for the tests
+	td := build.TempDir(t.Name())
+	err := os.MkdirAll(td, modules.DefaultDirPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPath := filepath.Join(td, "old"+refCounterExtension)
+	newPath := filepath.Join(td, "new"+refCounterExtension)
+	numSec := 2 + fastrand.Uint64n(10)
+	rc, err := newRefCounterWithAccessTimes(oldPath, numSec, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// increment a counter to make sure the access time file exists
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := rc.callIncrement(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// renaming during an update session fails
+	if err := rc.callRename(newPath); !errors.Contains(err, ErrRenameDuringUpdate) {
+		t.Fatal("Expected ErrRenameDuringUpdate, got:", err)
+	}
+	if err := rc.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+
+	// renaming to an existing file fails
+	existingPath := filepath.Join(td, "existing"+refCounterExtension)
+	if err := ioutil.WriteFile(existingPath, []byte{}, modules.DefaultFilePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callRename(existingPath); !errors.Contains(err, ErrRefCounterRenameTargetExists) {
+		t.Fatal("Expected ErrRefCounterRenameTargetExists, got:", err)
+	}
+
+	// rename the refcounter
+	if err := rc.callRename(newPath); err != nil {
+		t.Fatal(err)
+	}
+	if rc.filepath != newPath {
+		t.Fatal("filepath wasn't updated", rc.filepath)
+	}
+	for _, path := range []string{oldPath, accessTimeFilePath(oldPath)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatal("old file still exists", path, err)
+		}
+	}
+	for _, path := range []string{newPath, accessTimeFilePath(newPath)} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatal("new file doesn't exist", path, err)
+		}
+	}
+
+	// the counts are still there and the refcounter can be loaded from the
+	// new path
+	if count, err := rc.callCount(0); err != nil || count != 2 {
+		t.Fatal("unexpected count", count, err)
+	}
+	rcLoaded, err := loadRefCounter(newPath, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := rcLoaded.callCount(0); err != nil || count != 2 {
+		t.Fatal("unexpected count", count, err)
+	}
+
+	// updates are written to the new path
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err = rc.callIncrement(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Fatal("old file was recreated", err)
+	}
+	if err := rc.callValidate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// applying the rename update again is a no-op
+	if err := applyRenameUpdate(createRenameUpdate(oldPath, newPath)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(newPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRefCounterInMemory tests that an in-memory refcounter supports the same
+// operations as a refcounter on disk.
+func TestRefCounterInMemory(t *testing.T) {
+	t.Parallel()
+
+	numSec := uint64(10)
+	rc := newInMemoryRefCounter(numSec)
+	if err := rc.callValidate(); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := rc.callCountRange(0, numSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, count := range counts {
+		if count != 1 {
+			t.Fatalf("sector %v has count %v, expected 1", i, count)
+		}
+	}
+
+	// update the refcounter
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates []writeaheadlog.Update
+	u, err := rc.callIncrement(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, u)
+	u, err = rc.callDecrement(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, u)
+	us, err := rc.callSwap(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, us...)
+	u, err = rc.callAppend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, u)
+	u, err = rc.callSetCount(numSec+1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, u)
+	err = rc.callCreateAndApplyTransaction(updates...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callUpdateApplied()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callValidate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// verify the counts
+	expected := []uint16{1, 0, 2, 1, 1, 1, 1, 1, 1, 1, 1, 5}
+	counts, err = rc.callCountRange(0, rc.numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Fatal("unexpected counts", counts)
+	}
+	for i := range expected {
+		count, err := rc.callCount(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != expected[i] {
+			t.Fatalf("sector %v has count %v, expected %v", i, count, expected[i])
+		}
+	}
+
+	// drop sectors, abort an update and rename the refcounter
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err = rc.callDropSectors(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callCreateAndApplyTransaction(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callUpdateApplied()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rc.callAppend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callAbortUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc.numSectors != numSec-2 {
+		t.Fatal("unexpected number of sectors", rc.numSectors)
+	}
+	if err := rc.callValidate(); err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callRename("renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("renamed"); !os.IsNotExist(err) {
+		t.Fatal("in-memory refcounter shouldn't create a file", err)
+	}
+
+	// access times aren't tracked
+	if _, err := rc.callLastAccess(0); !errors.Contains(err, ErrAccessTimesNotTracked) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// delete the refcounter
+	err = rc.callStartUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err = rc.callDeleteRefCounter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callCreateAndApplyTransaction(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.callUpdateApplied()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callStartUpdate(); !errors.Contains(err, ErrUpdateAfterDelete) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// BenchmarkRefCounterIncrement benchmarks incrementing a sector's count on
+// disk and in memory.
+func BenchmarkRefCounterIncrement(b *testing.B) {
+	run := func(b *testing.B, rc *refCounter) {
+		for i := 0; i < b.N; i++ {
+			if err := rc.callStartUpdate(); err != nil {
+				b.Fatal(err)
+			}
+			u, err := rc.callIncrement(uint64(i) % rc.numSectors)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := rc.callCreateAndApplyTransaction(u); err != nil {
+				b.Fatal(err)
+			}
+			if err := rc.callUpdateApplied(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.Run("Disk", func(b *testing.B) {
+		td := build.TempDir(b.Name())
+		if err := os.MkdirAll(td, modules.DefaultDirPerm); err != nil {
+			b.Fatal(err)
+		}
+		rc, err := newRefCounter(filepath.Join(td, "refcounter"+refCounterExtension), 1000, testWAL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		run(b, rc)
+	})
+	b.Run("Memory", func(b *testing.B) {
+		run(b, newInMemoryRefCounter(1000))
+	})
+}
+
+// BenchmarkRefCounterApplyUpdates compares applying 10k WriteAt updates of
+// scattered and of contiguous sectors with the batched writes of applyUpdates
+// and with a write per update.
+func BenchmarkRefCounterApplyUpdates(b *testing.B) {
+	const numSectors = 100000
+	const numUpdates = 10000
+	run := func(b *testing.B, sectors []uint64, applyFn func(modules.File, ...writeaheadlog.Update) error) {
+		td := build.TempDir(b.Name())
+		if err := os.MkdirAll(td, modules.DefaultDirPerm); err != nil {
+			b.Fatal(err)
+		}
+		path := filepath.Join(td, "refcounter"+refCounterExtension)
+		if err := ioutil.WriteFile(path, newRefCounterData(numSectors), modules.DefaultFilePerm); err != nil {
+			b.Fatal(err)
+		}
+		f, err := os.OpenFile(path, os.O_RDWR, modules.DefaultFilePerm)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}()
+		updates := make([]writeaheadlog.Update, 0, len(sectors))
+		for _, secIdx := range sectors {
+			updates = append(updates, createWriteAtUpdate(path, secIdx, 2))
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := applyFn(f, updates...); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	scattered := make([]uint64, numUpdates)
+	for i := range scattered {
+		scattered[i] = fastrand.Uint64n(numSectors)
+	}
+	contiguous := make([]uint64, numUpdates)
+	for i := range contiguous {
+		contiguous[i] = uint64(i)
+	}
+	for _, sectors := range []struct {
+		name    string
+		sectors []uint64
+	}{
+		{"Scattered", scattered},
+		{"Contiguous", contiguous},
+	} {
+		sectors := sectors
+		b.Run(sectors.name+"/Merged", func(b *testing.B) {
+			run(b, sectors.sectors, applyUpdates)
+		})
+		b.Run(sectors.name+"/Unmerged", func(b *testing.B) {
+			run(b, sectors.sectors, testApplyUpdatesUnmerged)
+		})
+	}
+}
+
+// BenchmarkRefCounterCount compares reading the counts of 100k sectors one by
+// one from disk, from the preloaded counts and from a memory mapping.
+func BenchmarkRefCounterCount(b *testing.B) {
+	const numSectors = 100000
+	run := func(b *testing.B, rc *refCounter) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for secIdx := uint64(0); secIdx < numSectors; secIdx++ {
+				if _, err := rc.callCount(secIdx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+	newPath := func(b *testing.B) string {
+		td := build.TempDir(b.Name())
+		if err := os.MkdirAll(td, modules.DefaultDirPerm); err != nil {
+			b.Fatal(err)
+		}
+		return filepath.Join(td, "refcounter"+refCounterExtension)
+	}
+	b.Run("Disk", func(b *testing.B) {
+		rc, err := newRefCounter(newPath(b), numSectors, testWAL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		run(b, rc)
+	})
+	b.Run("Preloaded", func(b *testing.B) {
+		rc, err := newPreloadedRefCounter(newPath(b), numSectors, testWAL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		run(b, rc)
+	})
+	b.Run("Mmap", func(b *testing.B) {
+		path := newPath(b)
+		if _, err := newRefCounter(path, numSectors, testWAL); err != nil {
+			b.Fatal(err)
+		}
+		rc, err := loadMmapRefCounter(path, testWAL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer func() {
+			if err := rc.callClose(); err != nil {
+				b.Fatal(err)
+			}
+		}()
+		run(b, rc)
+	})
+}
+
+// BenchmarkRefCounterForEach compares finding the zero count sectors of 100k
+// sectors with callForEach to reading the counts one by one.
+func BenchmarkRefCounterForEach(b *testing.B) {
+	const numSectors = 100000
+	td := build.TempDir(b.Name())
+	if err := os.MkdirAll(td, modules.DefaultDirPerm); err != nil {
+		b.Fatal(err)
+	}
+	rc, err := newRefCounter(filepath.Join(td, "refcounter"+refCounterExtension), numSectors, testWAL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("ForEach", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := rc.callZeroCountSectors(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Count", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var sectors []uint64
+			for secIdx := uint64(0); secIdx < numSectors; secIdx++ {
+				count, err := rc.callCount(secIdx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if count == 0 {
+					sectors = append(sectors, secIdx)
+				}
+			}
+		}
+	})
+}
+
+// BenchmarkRefCounterUpdateCounts compares updating the counts of 10k sectors
+// with a single batch to incrementing them one by one. The number of WAL
+// updates per batch is reported as updates/op.
+func BenchmarkRefCounterUpdateCounts(b *testing.B) {
+	const numSectors = 10000
+	newRC := func(b *testing.B) *refCounter {
+		td := build.TempDir(b.Name())
+		if err := os.MkdirAll(td, modules.DefaultDirPerm); err != nil {
+			b.Fatal(err)
+		}
+		rc, err := newRefCounter(filepath.Join(td, "refcounter"+refCounterExtension), numSectors, testWAL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return rc
+	}
+	apply := func(b *testing.B, rc *refCounter, us []writeaheadlog.Update) {
+		if err := rc.callCreateAndApplyTransaction(us...); err != nil {
+			b.Fatal(err)
+		}
+		if err := rc.callUpdateApplied(); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportMetric(float64(len(us)), "updates/op")
+	}
+	b.Run("Batch", func(b *testing.B) {
+		rc := newRC(b)
+		deltas := make(map[uint64]int, numSectors)
+		for secIdx := uint64(0); secIdx < numSectors; secIdx++ {
+			deltas[secIdx] = 1
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := rc.callStartUpdate(); err != nil {
+				b.Fatal(err)
+			}
+			us, err := rc.callUpdateCounts(deltas)
+			if err != nil {
+				b.Fatal(err)
+			}
+			apply(b, rc, us)
+		}
+	})
+	b.Run("Increment", func(b *testing.B) {
+		rc := newRC(b)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := rc.callStartUpdate(); err != nil {
+				b.Fatal(err)
+			}
+			us := make([]writeaheadlog.Update, 0, numSectors)
+			for secIdx := uint64(0); secIdx < numSectors; secIdx++ {
+				u, err := rc.callIncrement(secIdx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				us = append(us, u)
+			}
+			apply(b, rc, us)
+		}
+	})
+}
+
+// TestRefCounterUnderflowAlert tests that decrementing a sector which isn't
+// referenced anymore registers an alert if the refcounter has an alerter.
+func TestRefCounterUnderflowAlert(t *testing.T) {
+	t.Parallel()
+
+	// decrementTwice decrements the only sector of the refcounter twice and
+	// returns the error of the second decrement.
+	decrementTwice := func(rc *refCounter) error {
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := rc.callUpdateApplied(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		if _, err := rc.callDecrement(0); err != nil {
+			t.Fatal(err)
+		}
+		_, err := rc.callDecrement(0)
+		return err
+	}
+
+	// without an alerter the underflow is only returned as an error
+	rc := newInMemoryRefCounter(1)
+	if err := decrementTwice(rc); !errors.Contains(err, ErrRefCounterUnderflow) {
+		t.Fatal("expected underflow error, got", err)
+	}
+
+	// with an alerter an error alert is registered as well
+	alerter := modules.NewAlerter("test")
+	rc = newInMemoryRefCounter(1)
+	rc.staticAlerter = alerter
+	if err := decrementTwice(rc); !errors.Contains(err, ErrRefCounterUnderflow) {
+		t.Fatal("expected underflow error, got", err)
+	}
+	crit, errs, warn, info := alerter.Alerts()
+	if len(crit) != 0 || len(errs) != 1 || len(warn) != 0 || len(info) != 0 {
+		t.Fatal("unexpected alerts", len(crit), len(errs), len(warn), len(info))
+	}
+	if errs[0].ID != modules.AlertIDRenterRefCounterUnderflow {
+		t.Fatal("unexpected alert", errs[0].ID)
+	}
+	if !strings.Contains(errs[0].Cause, ErrRefCounterUnderflow.Error()) {
+		t.Fatal("unexpected cause", errs[0].Cause)
+	}
+}
+
+// TestRefCounterApplyUpdates tests that the updates of an interrupted update
+// session can be replayed with ApplyUpdates, no matter how many of them were
+// applied before the interruption.
+func TestRefCounterApplyUpdates(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// prepare a batch of updates without applying it
+	numSec := uint64(10)
+	rc := testPrepareRefCounter(numSec, t)
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	var updates []writeaheadlog.Update
+	u, err := rc.callIncrement(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, u)
+	u, err = rc.callSetCount(3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, u)
+	us, err := rc.callSwap(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, us...)
+	u, err = rc.callAppend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, u)
+	u, err = rc.callDropSectors(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, u)
+	f, err := os.Open(rc.filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err = rc.checksumUpdate(f, updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	updates = append(updates, u)
+	expected := []uint16{2, 5, 1, 1, 1, 1, 1, 1, 1}
+
+	// apply half of the updates before the "crash" and replay all of them
+	// twice afterwards
+	if err := ApplyUpdates(updates[:len(updates)/2]...); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := ApplyUpdates(updates...); err != nil {
+			t.Fatal(err)
+		}
+		rcLoaded, err := loadRefCounter(rc.filepath, testWAL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts, err := rcLoaded.callCountRange(0, rcLoaded.numSectors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(counts, expected) {
+			t.Fatalf("Expected counts %v, got %v", expected, counts)
+		}
+		fi, err := os.Stat(rc.filepath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() != int64(offset(uint64(len(expected)))) {
+			t.Fatalf("Expected file size %v, got %v", offset(uint64(len(expected))), fi.Size())
+		}
+	}
+
+	// updates that don't belong to a refcounter are rejected
+	if err := ApplyUpdates(writeaheadlog.Update{Name: "foo"}); !errors.Contains(err, ErrUnknownUpdate) {
+		t.Fatal("Expected ErrUnknownUpdate, got:", err)
+	}
+	if err := applyUpdates(nil, writeaheadlog.Update{Name: "foo"}); !errors.Contains(err, ErrUnknownUpdate) {
+		t.Fatal("Expected ErrUnknownUpdate, got:", err)
+	}
+}
+
+// countingFile is a modules.File that counts the calls to WriteAt and Sync.
+type countingFile struct {
+	modules.File
+	writes int
+	syncs  int
+}
+
+// WriteAt counts the call and writes to the wrapped file.
+func (f *countingFile) WriteAt(b []byte, off int64) (int, error) {
+	f.writes++
+	return f.File.WriteAt(b, off)
+}
+
+// Sync counts the call and syncs the wrapped file.
+func (f *countingFile) Sync() error {
+	f.syncs++
+	return f.File.Sync()
+}
+
+// testApplyUpdatesUnmerged applies the given updates to f one by one, with a
+// single WriteAt per update. It is the reference for the batched writes of
+// applyUpdates.
+func testApplyUpdatesUnmerged(f modules.File, updates ...writeaheadlog.Update) error {
+	for _, u := range updates {
+		var err error
+		switch u.Name {
+		case updateNameRCTruncate:
+			err = applyTruncateUpdate(f, u)
+		case updateNameRCWriteAt:
+			err = applyWriteAtUpdate(f, u)
+		case updateNameRCWriteRangeAt:
+			err = applyWriteRangeAtUpdate(f, u)
+		default:
+			err = fmt.Errorf("unexpected update %v", u.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
+
+// TestRefCounterWriteBatch verifies that the batched writes of applyUpdates
+// and ApplyUpdates result in the same file as applying every update with its
+// own write, and that the writes of adjacent sectors are merged.
+func TestRefCounterWriteBatch(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	td := build.TempDir(t.Name())
+	if err := os.MkdirAll(td, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	numSec := uint64(100)
+	data := newRefCounterData(numSec)
+	// prepare creates a refcounter file with numSec sectors and opens it
+	prepare := func(name string) (string, modules.File) {
+		path := filepath.Join(td, name+refCounterExtension)
+		if err := ioutil.WriteFile(path, data, modules.DefaultFilePerm); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(path, os.O_RDWR, modules.DefaultFilePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path, f
+	}
+	// apply applies the updates with all three methods and verifies the
+	// files are equal
+	apply := func(name string, updates []writeaheadlog.Update) *countingFile {
+		t.Helper()
+		unmergedPath, unmerged := prepare(name + "-unmerged")
+		mergedPath, merged := prepare(name + "-merged")
+		recoveredPath, recovered := prepare(name + "-recovered")
+		if err := recovered.Close(); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := errors.Compose(unmerged.Close(), merged.Close()); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		// the updates of the recovered file are applied from the WAL, the
+		// ones of the others only use the given file
+		recoveredUpdates := make([]writeaheadlog.Update, 0, len(updates))
+		for _, u := range updates {
+			var err error
+			switch u.Name {
+			case updateNameRCTruncate:
+				var newNumSec uint64
+				_, newNumSec, err = readTruncateUpdate(u)
+				u = createTruncateUpdate(recoveredPath, newNumSec)
+			case updateNameRCWriteAt:
+				var secIdx uint64
+				var value uint16
+				_, secIdx, value, err = readWriteAtUpdate(u)
+				u = createWriteAtUpdate(recoveredPath, secIdx, value)
+			case updateNameRCWriteRangeAt:
+				var start uint64
+				var values []uint16
+				_, start, values, err = readWriteRangeAtUpdate(u)
+				u = createWriteRangeAtUpdate(recoveredPath, start, values)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			recoveredUpdates = append(recoveredUpdates, u)
+		}
+
+		if err := testApplyUpdatesUnmerged(unmerged, updates...); err != nil {
+			t.Fatal(err)
+		}
+		cf := &countingFile{File: merged}
+		if err := applyUpdates(cf, updates...); err != nil {
+			t.Fatal(err)
+		}
+		if err := ApplyUpdates(recoveredUpdates...); err != nil {
+			t.Fatal(err)
+		}
+		expected, err := ioutil.ReadFile(unmergedPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range []string{mergedPath, recoveredPath} {
+			actual, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(actual, expected) {
+				t.Fatalf("%v doesn't match the file of the unmerged updates", path)
+			}
+		}
+		return cf
+	}
+
+	// adjacent writes are merged into a single write, a later write to a
+	// sector wins
+	var updates []writeaheadlog.Update
+	for i := uint64(0); i < numSec; i++ {
+		updates = append(updates, createWriteAtUpdate("", numSec-1-i, uint16(i)))
+	}
+	updates = append(updates, createWriteAtUpdate("", 3, 1000))
+	updates = append(updates, createWriteRangeAtUpdate("", 10, []uint16{1, 2, 3}))
+	updates = append(updates, createWriteAtUpdate("", 11, 2000))
+	cf := apply("contiguous", updates)
+	if cf.writes != 1 || cf.syncs != 1 {
+		t.Fatalf("expected 1 write and 1 sync, got %v writes and %v syncs", cf.writes, cf.syncs)
+	}
+
+	// writes are flushed before truncating the file
+	updates = []writeaheadlog.Update{
+		createWriteAtUpdate("", numSec+5, 7),
+		createTruncateUpdate("", numSec+2),
+		createWriteAtUpdate("", 0, 7),
+		createWriteAtUpdate("", 2, 7),
+	}
+	cf = apply("truncate", updates)
+	if cf.writes != 3 || cf.syncs != 1 {
+		t.Fatalf("expected 3 writes and 1 sync, got %v writes and %v syncs", cf.writes, cf.syncs)
+	}
+
+	// random sequences of updates
+	for i := 0; i < 20; i++ {
+		size := numSec
+		var updates []writeaheadlog.Update
+		for j := 0; j < 200; j++ {
+			switch n := fastrand.Intn(20); {
+			case n == 0:
+				size = fastrand.Uint64n(2 * numSec)
+				updates = append(updates, createTruncateUpdate("", size))
+			case n < 5:
+				values := make([]uint16, 1+fastrand.Intn(10))
+				for k := range values {
+					values[k] = uint16(fastrand.Intn(math.MaxUint16 + 1))
+				}
+				updates = append(updates, createWriteRangeAtUpdate("", fastrand.Uint64n(size+1), values))
+			default:
+				updates = append(updates, createWriteAtUpdate("", fastrand.Uint64n(size+1), uint16(fastrand.Intn(math.MaxUint16+1))))
+			}
+		}
+		cf := apply(fmt.Sprintf("random%v", i), updates)
+		if cf.syncs != 1 {
+			t.Fatal("expected 1 sync, got", cf.syncs)
+		}
+	}
+}
+
+// TestRefCounterReset tests that a deleted refcounter can be recreated at the
+// same path with callReset.
+func TestRefCounterReset(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// deleteRC deletes the refcounter and verifies that resetting it is only
+	// possible once the delete was applied
+	deleteRC := func(rc *refCounter) {
+		t.Helper()
+		if err := rc.callReset(1); !errors.Contains(err, ErrResetBeforeDelete) {
+			t.Fatal("Expected ErrResetBeforeDelete, got:", err)
+		}
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		u, err := rc.callDeleteRefCounter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callReset(1); !errors.Contains(err, ErrResetDuringUpdate) {
+			t.Fatal("Expected ErrResetDuringUpdate, got:", err)
+		}
+		if err := rc.callCreateAndApplyTransaction(u); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callReset(1); !errors.Contains(err, ErrResetDuringUpdate) {
+			t.Fatal("Expected ErrResetDuringUpdate, got:", err)
+		}
+		if err := rc.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callStartUpdate(); !errors.Contains(err, ErrUpdateAfterDelete) {
+			t.Fatal("Expected ErrUpdateAfterDelete, got:", err)
+		}
+	}
+	// verify checks that the refcounter has numSec sectors with a count of 1
+	// and accepts updates again
+	verify := func(rc *refCounter, numSec uint64) {
+		t.Helper()
+		if rc.numSectors != numSec {
+			t.Fatalf("Expected %v sectors, got %v", numSec, rc.numSectors)
+		}
+		counts, err := rc.callCountRange(0, numSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, c := range counts {
+			if c != 1 {
+				t.Fatalf("Expected count 1 for sector %v, got %v", i, c)
+			}
+		}
+		size, err := rc.fileSize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != int64(offset(numSec)) {
+			t.Fatalf("Expected size %v, got %v", offset(numSec), size)
+		}
+		if err := rc.callValidate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		u, err := rc.callIncrement(numSec - 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callCreateAndApplyTransaction(u); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+		if count, err := rc.callCount(numSec - 1); err != nil || count != 2 {
+			t.Fatal("unexpected count after increment", count, err)
+		}
+	}
+
+	// reset a refcounter on disk to a smaller and a larger number of sectors
+	numSec := uint64(10)
+	rc := testPrepareRefCounter(numSec, t)
+	for _, n := range []uint64{numSec / 2, 2 * numSec} {
+		deleteRC(rc)
+		if err := rc.callReset(n); err != nil {
+			t.Fatal(err)
+		}
+		verify(rc, n)
+		rcLoaded, err := loadRefCounter(rc.filepath, testWAL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rcLoaded.numSectors != n {
+			t.Fatalf("Expected %v sectors after loading, got %v", n, rcLoaded.numSectors)
+		}
+	}
+
+	// a refcounter that tracks access times gets a new access time file
+	path := filepath.Join(filepath.Dir(rc.filepath), "atime"+refCounterExtension)
+	rc, err := newRefCounterWithAccessTimes(path, numSec, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleteRC(rc)
+	if err := rc.callReset(numSec / 2); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(accessTimeFilePath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(numSec/2*refCounterAccessTimeSize) {
+		t.Fatalf("Expected access time file size %v, got %v", numSec/2*refCounterAccessTimeSize, fi.Size())
+	}
+	verify(rc, numSec/2)
+
+	// preloaded and in-memory refcounters are reset as well
+	path = filepath.Join(filepath.Dir(rc.filepath), "preloaded"+refCounterExtension)
+	rc, err = newPreloadedRefCounter(path, numSec, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleteRC(rc)
+	if err := rc.callReset(numSec + 1); err != nil {
+		t.Fatal(err)
+	}
+	verify(rc, numSec+1)
+	rc = newInMemoryRefCounter(numSec)
+	deleteRC(rc)
+	if err := rc.callReset(numSec - 1); err != nil {
+		t.Fatal(err)
+	}
+	verify(rc, numSec-1)
+}
+
+// TestRefCounterRemoveSectors tests that callRemoveSectors removes sectors from
+// anywhere in the refcounter and reports where the remaining sectors moved.
+func TestRefCounterRemoveSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// prepare a refcounter with a unique count for every sector
+	numSec := uint64(50)
+	rc := testPrepareRefCounter(numSec, t)
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	var updates []writeaheadlog.Update
+	for i := uint64(0); i < numSec; i++ {
+		u, err := rc.callSetCount(i, uint16(i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		updates = append(updates, u)
+	}
+	if err := rc.callCreateAndApplyTransaction(updates...); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+
+	// invalid batches fail without changing anything
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := rc.callRemoveSectors([]uint64{1, numSec}); !errors.Contains(err, ErrInvalidSectorNumber) {
+		t.Fatal("Expected ErrInvalidSectorNumber, got:", err)
+	}
+	if _, _, err := rc.callRemoveSectors([]uint64{1, 2, 1}); !errors.Contains(err, ErrDuplicateSectorIndex) {
+		t.Fatal("Expected ErrDuplicateSectorIndex, got:", err)
+	}
+	if rc.numSectors != numSec || len(rc.newSectorCounts) != 0 {
+		t.Fatal("refcounter changed after invalid batch", rc.numSectors, len(rc.newSectorCounts))
+	}
+	if err := rc.callAbortUpdate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// remove random sets of sectors and verify the remapping matches the
+	// layout on disk
+	for i := 0; i < 5 && rc.numSectors > 0; i++ {
+		before, err := rc.callCountRange(0, rc.numSectors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		perm := fastrand.Perm(int(rc.numSectors))
+		indices := make([]uint64, fastrand.Intn(len(perm)/2+1))
+		for j := range indices {
+			indices[j] = uint64(perm[j])
+		}
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		us, remap, err := rc.callRemoveSectors(indices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callCreateAndApplyTransaction(us...); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callValidate(); err != nil {
+			t.Fatal(err)
+		}
+
+		// verify the number of sectors and the layout
+		expectedNumSec := uint64(len(before) - len(indices))
+		if rc.numSectors != expectedNumSec {
+			t.Fatalf("Expected %v sectors, got %v", expectedNumSec, rc.numSectors)
+		}
+		rcLoaded, err := loadRefCounter(rc.filepath, testWAL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		after, err := rcLoaded.callCountRange(0, rcLoaded.numSectors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isRemoved := make(map[uint64]bool)
+		for _, secIdx := range indices {
+			isRemoved[secIdx] = true
+		}
+		numMoved := 0
+		for oldIdx, count := range before {
+			if isRemoved[uint64(oldIdx)] {
+				if _, exists := remap[uint64(oldIdx)]; exists {
+					t.Fatal("removed sector was remapped", oldIdx)
+				}
+				continue
+			}
+			newIdx, moved := remap[uint64(oldIdx)]
+			if moved {
+				numMoved++
+				if uint64(oldIdx) < expectedNumSec || newIdx >= expectedNumSec {
+					t.Fatalf("unexpected move from %v to %v", oldIdx, newIdx)
+				}
+			} else {
+				newIdx = uint64(oldIdx)
+			}
+			if after[newIdx] != count {
+				t.Fatalf("sector %v moved to %v has count %v, expected %v", oldIdx, newIdx, after[newIdx], count)
+			}
+		}
+		if numMoved != len(remap) {
+			t.Fatal("remap contains unknown sectors", remap)
+		}
+		// only the moved sectors are written
+		if len(us) != len(remap)+1 {
+			t.Fatalf("Expected %v updates, got %v", len(remap)+1, len(us))
+		}
+	}
+
+	// moved sectors keep their access times
+	path := filepath.Join(filepath.Dir(rc.filepath), "atime"+refCounterExtension)
+	rcAt, err := newRefCounterWithAccessTimes(path, 4, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := accessTimeNow() - 100
+	f, err := os.OpenFile(accessTimeFilePath(path), os.O_RDWR, modules.DefaultFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, refCounterAccessTimeSize)
+	binary.LittleEndian.PutUint32(b, past)
+	if _, err := f.WriteAt(b, 3*refCounterAccessTimeSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rcAt.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	us, remap, err := rcAt.callRemoveSectors([]uint64{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remap) != 1 || remap[3] != 0 {
+		t.Fatal("unexpected remapping", remap)
+	}
+	if err := rcAt.callCreateAndApplyTransaction(us...); err != nil {
+		t.Fatal(err)
+	}
+	if err := rcAt.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	if at, err := rcAt.callLastAccess(0); err != nil || at.Unix() != int64(past) {
+		t.Fatal("unexpected access time", at, past, err)
+	}
+}
+
+// TestRefCounterGarbageCount applies random sequences of updates to a
+// refcounter and verifies that the garbage count that is kept up to date by
+// the updates matches a recount of the sectors without references.
+func TestRefCounterGarbageCount(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// verify compares the garbage count against a recount
+	verify := func(rc *refCounter) {
+		t.Helper()
+		sectors, err := rc.callZeroCountSectors()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count := rc.callGarbageCount(); count != uint64(len(sectors)) {
+			t.Fatalf("Expected %v garbage sectors, got %v", len(sectors), count)
+		}
+	}
+
+	rc := testPrepareRefCounter(20, t)
+	verify(rc)
+	for session := 0; session < 50; session++ {
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		var updates []writeaheadlog.Update
+		for i := 0; i < 10; i++ {
+			numSec := rc.callNumSectors()
+			secIdx := fastrand.Uint64n(numSec + 1)
+			var us []writeaheadlog.Update
+			var err error
+			switch fastrand.Intn(8) {
+			case 0:
+				var u writeaheadlog.Update
+				u, err = rc.callAppend()
+				us = []writeaheadlog.Update{u}
+			case 1:
+				if secIdx >= numSec {
+					continue
+				}
+				var u writeaheadlog.Update
+				u, err = rc.callIncrement(secIdx)
+				us = []writeaheadlog.Update{u}
+			case 2:
+				if secIdx >= numSec {
+					continue
+				}
+				if count, _ := rc.callCount(secIdx); count == 0 {
+					continue
+				}
+				var u writeaheadlog.Update
+				u, err = rc.callDecrement(secIdx)
+				us = []writeaheadlog.Update{u}
+			case 3:
+				if secIdx >= numSec {
+					continue
+				}
+				us, err = rc.callSwap(secIdx, fastrand.Uint64n(numSec))
+			case 4:
+				var u writeaheadlog.Update
+				u, err = rc.callDropSectors(fastrand.Uint64n(numSec/4 + 1))
+				us = []writeaheadlog.Update{u}
+			case 5:
+				var u writeaheadlog.Update
+				u, err = rc.callSetCount(secIdx, uint16(fastrand.Intn(3)))
+				us = []writeaheadlog.Update{u}
+			case 6:
+				deltas := make(map[uint64]int)
+				for j := uint64(0); j < numSec; j++ {
+					count, err := rc.callCount(j)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if delta := fastrand.Intn(3) - 1; int(count)+delta >= 0 {
+						deltas[j] = delta
+					}
+				}
+				us, err = rc.callUpdateCounts(deltas)
+			case 7:
+				var indices []uint64
+				for _, idx := range fastrand.Perm(int(numSec))[:fastrand.Intn(int(numSec)/4+1)] {
+					indices = append(indices, uint64(idx))
+				}
+				us, _, err = rc.callRemoveSectors(indices)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			updates = append(updates, us...)
+			// the count includes the pending updates
+			verify(rc)
+		}
+		// abort some of the sessions
+		if fastrand.Intn(5) == 0 {
+			if err := rc.callAbortUpdate(); err != nil {
+				t.Fatal(err)
+			}
+			verify(rc)
+			continue
+		}
+		if err := rc.callCreateAndApplyTransaction(updates...); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+		verify(rc)
+	}
+
+	// the garbage is counted when the refcounter is loaded
+	rcLoaded, err := loadRefCounter(rc.filepath, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rcLoaded.callGarbageCount() != rc.callGarbageCount() {
+		t.Fatalf("Expected %v garbage sectors after loading, got %v", rc.callGarbageCount(), rcLoaded.callGarbageCount())
+	}
+	verify(rcLoaded)
+	size, err := rcLoaded.callFileSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(offset(rcLoaded.callNumSectors())) {
+		t.Fatalf("Expected file size %v, got %v", offset(rcLoaded.callNumSectors()), size)
+	}
+}
+
+// TestRefCounterErrorSentinels verifies that every failure path of the
+// refcounter returns an error that matches its exported sentinel, even after
+// context was added to it.
+func TestRefCounterErrorSentinels(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// startUpdate prepares a refcounter with a single sector with the given
+	// count and opens an update session on it.
+	startUpdate := func(count uint16) *refCounter {
+		rc := newInMemoryRefCounter(1)
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rc.callSetCount(0, count); err != nil {
+			t.Fatal(err)
+		}
+		return rc
+	}
+
+	// prepareFile creates a refcounter on disk and returns its path.
+	prepareFile := func(name string) string {
+		rc := testPrepareRefCounter(3, t)
+		path := filepath.Join(filepath.Dir(rc.filepath), name+refCounterExtension)
+		if err := rc.callRename(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callClose(); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	bogus := writeaheadlog.Update{Name: "bogus"}
+
+	tests := []struct {
+		name     string
+		fn       func() error
+		sentinel error
+		corrupt  bool
+	}{
+		{
+			name: "underflow",
+			fn: func() error {
+				_, err := startUpdate(0).callDecrement(0)
+				return err
+			},
+			sentinel: ErrRefCounterUnderflow,
+		},
+		{
+			name: "underflowUpdateCounts",
+			fn: func() error {
+				_, err := startUpdate(1).callUpdateCounts(map[uint64]int{0: -2})
+				return err
+			},
+			sentinel: ErrRefCounterUnderflow,
+		},
+		{
+			name: "overflow",
+			fn: func() error {
+				_, err := startUpdate(math.MaxUint16).callIncrement(0)
+				return err
+			},
+			sentinel: ErrRefCounterOverflow,
+		},
+		{
+			name: "overflowUpdateCounts",
+			fn: func() error {
+				_, err := startUpdate(math.MaxUint16).callUpdateCounts(map[uint64]int{0: 1})
+				return err
+			},
+			sentinel: ErrRefCounterOverflow,
+		},
+		{
+			name: "misalignedFile",
+			fn: func() error {
+				path := prepareFile("misaligned")
+				f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, modules.DefaultFilePerm)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := f.Write([]byte{1}); err != nil {
+					t.Fatal(err)
+				}
+				if err := f.Close(); err != nil {
+					t.Fatal(err)
+				}
+				_, err = loadRefCounter(path, testWAL)
+				return err
+			},
+			sentinel: ErrMisalignedFile,
+		},
+		{
+			name: "corruptHeader",
+			fn: func() error {
+				var h refCounterHeader
+				return deserializeHeader(make([]byte, refCounterHeaderSize-1), &h)
+			},
+			sentinel: ErrInvalidHeaderData,
+			corrupt:  true,
+		},
+		{
+			name: "corruptChecksum",
+			fn: func() error {
+				path := prepareFile("corrupt")
+				flipByte(t, path, int64(refCounterHeaderSize))
+				_, err := loadRefCounter(path, testWAL)
+				return err
+			},
+			sentinel: ErrCorruptRefCounter,
+			corrupt:  true,
+		},
+		{
+			name: "unknownUpdate",
+			fn: func() error {
+				return ApplyUpdates(bogus)
+			},
+			sentinel: ErrUnknownUpdate,
+		},
+		{
+			name: "unknownUpdateBatch",
+			fn: func() error {
+				return new(refCounterWriteBatch).add(bogus)
+			},
+			sentinel: ErrUnknownUpdate,
+		},
+		{
+			name: "unknownUpdateDelete",
+			fn: func() error {
+				return applyDeleteUpdate(bogus)
+			},
+			sentinel: ErrUnknownUpdate,
+		},
+		{
+			name: "unknownUpdateRename",
+			fn: func() error {
+				return applyRenameUpdate(bogus)
+			},
+			sentinel: ErrUnknownUpdate,
+		},
+		{
+			name: "unknownUpdateTruncate",
+			fn: func() error {
+				return applyTruncateUpdate(nil, bogus)
+			},
+			sentinel: ErrUnknownUpdate,
+		},
+		{
+			name: "unknownUpdateWriteAt",
+			fn: func() error {
+				return applyWriteAtUpdate(nil, bogus)
+			},
+			sentinel: ErrUnknownUpdate,
+		},
+		{
+			name: "unknownUpdateWriteRangeAt",
+			fn: func() error {
+				return applyWriteRangeAtUpdate(nil, bogus)
+			},
+			sentinel: ErrUnknownUpdate,
+		},
+		{
+			name: "unknownUpdateWriteAccessAt",
+			fn: func() error {
+				return applyWriteAccessAtUpdate(bogus)
+			},
+			sentinel: ErrUnknownUpdate,
+		},
+		{
+			name: "unknownUpdateWriteChecksums",
+			fn: func() error {
+				return applyWriteChecksumsUpdate(bogus)
+			},
+			sentinel: ErrUnknownUpdate,
+		},
+	}
+	for _, test := range tests {
+		err := test.fn()
+		if !errors.Contains(err, test.sentinel) {
+			t.Fatalf("%v: expected %v, got %v", test.name, test.sentinel, err)
+		}
+		if err == test.sentinel {
+			t.Fatalf("%v: expected context to be added to the sentinel", test.name)
+		}
+		if isRefCounterCorruption(err) != test.corrupt {
+			t.Fatalf("%v: expected corruption to be %v", test.name, test.corrupt)
+		}
+	}
+}
+
+// TestContractSetLoadRefCounterErrors verifies that the contract set repairs a
+// misaligned refcounter but registers an alert for a corrupt one.
+func TestContractSetLoadRefCounterErrors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	cs := &ContractSet{
+		staticAlerter: modules.NewAlerter("test"),
+		staticWal:     testWAL,
+	}
+	rc := testPrepareRefCounter(3, t)
+	if err := rc.callClose(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a misaligned refcounter is repaired without an alert
+	f, err := os.OpenFile(rc.filepath, os.O_APPEND|os.O_WRONLY, modules.DefaultFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := cs.loadRefCounter(rc.filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.callClose(); err != nil {
+		t.Fatal(err)
+	}
+	if crit, _, _, _ := cs.staticAlerter.Alerts(); len(crit) != 0 {
+		t.Fatal("unexpected alerts", len(crit))
+	}
+
+	// a corrupt refcounter fails to load and registers an alert
+	flipByte(t, rc.filepath, int64(refCounterHeaderSize))
+	_, err = cs.loadRefCounter(rc.filepath)
+	if !errors.Contains(err, ErrCorruptRefCounter) {
+		t.Fatal("Expected ErrCorruptRefCounter, got:", err)
+	}
+
+	// if it can't be rebuilt either, a critical alert is registered
+	cs.staticWal, _ = newTestWAL()
+	missingDir := filepath.Join(filepath.Dir(rc.filepath), "missing", "contract"+refCounterExtension)
+	_, err = cs.managedRebuildRefCounter(types.FileContractID{}, missingDir, []crypto.Hash{{}}, err)
+	if !errors.Contains(err, ErrCorruptRefCounter) {
+		t.Fatal("Expected the cause of the rebuild, got:", err)
+	}
+	crit, _, _, _ := cs.staticAlerter.Alerts()
+	if len(crit) != 1 || crit[0].ID != modules.AlertIDRenterRefCounterCorrupt {
+		t.Fatal("expected a corruption alert", crit)
+	}
+	if !strings.Contains(crit[0].Cause, missingDir) {
+		t.Fatal("unexpected cause", crit[0].Cause)
+	}
+}
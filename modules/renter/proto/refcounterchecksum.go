@@ -0,0 +1,349 @@
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/writeaheadlog"
+
+	"go.sia.tech/siad/modules"
+)
+
+// The checksums of a refcounter are stored in a sibling file. The file starts
+// with the checksum of the refcounter's header, followed by the checksum of
+// every page of the counter area. Every checksum is a CRC32 (Castagnoli).
+// Only the pages that are changed by a transaction are updated, the checksum
+// updates are applied as part of the same WAL transaction as the counts.
+const (
+	// refCounterChecksumPageSize is the number of bytes of the counter area
+	// that are covered by a single checksum.
+	refCounterChecksumPageSize = 4096
+
+	// refCounterChecksumSize is the size of a single checksum in bytes.
+	refCounterChecksumSize = 4
+
+	// refCounterChecksumMaxPages is the number of pages of the counter area
+	// of a refcounter that tracks refCounterMaxSectors sectors.
+	refCounterChecksumMaxPages = (2*refCounterMaxSectors + refCounterChecksumPageSize - 1) / refCounterChecksumPageSize
+
+	// refCounterChecksumSuffix is the suffix appended to the refcounter's path
+	// to get the path of its checksum file.
+	refCounterChecksumSuffix = ".checksum"
+)
+
+var (
+	// refCounterChecksumTable is the CRC32 table used for the checksums.
+	refCounterChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// checksumPages is an overlay of the pages of a refcounter's counter area on
+// disk. It is used to compute the checksums of the pages that are changed by a
+// transaction before the transaction is applied.
+type checksumPages struct {
+	f     io.ReaderAt
+	pages map[uint64][]byte
+	dirty map[uint64]struct{}
+
+	// initialSize is the size of the counter area on disk and size its size
+	// after the updates that were applied to the overlay. floor is the
+	// smallest size of the counter area since the overlay was created, bytes
+	// beyond it are not read from disk.
+	initialSize uint64
+	size        uint64
+	floor       uint64
+}
+
+// checksumUpdate returns the update that writes the checksums of the pages
+// which are changed by the given updates. The pages are read from f, the
+// refcounter's file before the updates are applied.
+func (rc *refCounter) checksumUpdate(f io.ReaderAt, updates []writeaheadlog.Update) (writeaheadlog.Update, error) {
+	fi, err := os.Stat(rc.filepath)
+	if err != nil {
+		return writeaheadlog.Update{}, errors.AddContext(err, "failed to read file stats")
+	}
+	size := uint64(0)
+	if fi.Size() > refCounterHeaderSize {
+		size = uint64(fi.Size() - refCounterHeaderSize)
+	}
+	cp := &checksumPages{
+		f:           f,
+		pages:       make(map[uint64][]byte),
+		dirty:       make(map[uint64]struct{}),
+		initialSize: size,
+		size:        size,
+		floor:       size,
+	}
+	for _, u := range updates {
+		switch u.Name {
+		case updateNameRCTruncate:
+			_, newNumSec, err := readTruncateUpdate(u)
+			if err != nil {
+				return writeaheadlog.Update{}, err
+			}
+			cp.truncate(newNumSec * 2)
+		case updateNameRCWriteAt:
+			_, secIdx, value, err := readWriteAtUpdate(u)
+			if err != nil {
+				return writeaheadlog.Update{}, err
+			}
+			if err := cp.writeCount(secIdx, value); err != nil {
+				return writeaheadlog.Update{}, err
+			}
+		case updateNameRCWriteRangeAt:
+			_, start, values, err := readWriteRangeAtUpdate(u)
+			if err != nil {
+				return writeaheadlog.Update{}, err
+			}
+			for i, value := range values {
+				if err := cp.writeCount(start+uint64(i), value); err != nil {
+					return writeaheadlog.Update{}, err
+				}
+			}
+		}
+	}
+	return cp.update(rc.filepath)
+}
+
+// truncate changes the size of the counter area to the given number of bytes.
+func (cp *checksumPages) truncate(size uint64) {
+	for p, page := range cp.pages {
+		start := p * refCounterChecksumPageSize
+		if start >= size {
+			delete(cp.pages, p)
+			continue
+		}
+		if size-start < refCounterChecksumPageSize {
+			for i := size - start; i < refCounterChecksumPageSize; i++ {
+				page[i] = 0
+			}
+		}
+	}
+	if size < cp.floor {
+		cp.floor = size
+	}
+	cp.size = size
+}
+
+// writeCount writes the count of a sector to its page. A count never spans two
+// pages.
+func (cp *checksumPages) writeCount(secIdx uint64, value uint16) error {
+	off := secIdx * 2
+	page, err := cp.page(off / refCounterChecksumPageSize)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint16(page[off%refCounterChecksumPageSize:], value)
+	cp.dirty[off/refCounterChecksumPageSize] = struct{}{}
+	if off+2 > cp.size {
+		cp.size = off + 2
+	}
+	return nil
+}
+
+// page returns the contents of the given page, reading it from disk if it
+// wasn't read before.
+func (cp *checksumPages) page(p uint64) ([]byte, error) {
+	if page, exists := cp.pages[p]; exists {
+		return page, nil
+	}
+	page := make([]byte, refCounterChecksumPageSize)
+	start := p * refCounterChecksumPageSize
+	if start < cp.floor {
+		end := start + refCounterChecksumPageSize
+		if end > cp.floor {
+			end = cp.floor
+		}
+		_, err := cp.f.ReadAt(page[:end-start], int64(refCounterHeaderSize+start))
+		if err != nil && !errors.Contains(err, io.EOF) {
+			return nil, errors.AddContext(err, "failed to read page from refcounter file")
+		}
+	}
+	cp.pages[p] = page
+	return page, nil
+}
+
+// update returns the update that writes the checksums of the changed pages to
+// the checksum file of the refcounter at the given path. Besides the pages
+// that were written to, every page between the floor and the size of the
+// counter area changed if the size changed, since it was either cut off or
+// extended.
+func (cp *checksumPages) update(path string) (writeaheadlog.Update, error) {
+	numPages := (cp.size + refCounterChecksumPageSize - 1) / refCounterChecksumPageSize
+	if cp.floor < cp.initialSize || cp.size != cp.initialSize {
+		for p := cp.floor / refCounterChecksumPageSize; p < numPages; p++ {
+			cp.dirty[p] = struct{}{}
+		}
+	}
+	sums := make(map[uint64]uint32, len(cp.dirty))
+	for p := range cp.dirty {
+		if p >= numPages {
+			continue
+		}
+		page, err := cp.page(p)
+		if err != nil {
+			return writeaheadlog.Update{}, err
+		}
+		end := cp.size - p*refCounterChecksumPageSize
+		if end > refCounterChecksumPageSize {
+			end = refCounterChecksumPageSize
+		}
+		sums[p] = crc32.Checksum(page[:end], refCounterChecksumTable)
+	}
+	return createWriteChecksumsUpdate(path, numPages, sums), nil
+}
+
+// verifyChecksums verifies the refcounter's file against its checksum file. An
+// error wrapping ErrCorruptRefCounter is returned if they don't match, which
+// means that the refcounter needs to be rebuilt from the contract.
+func (rc *refCounter) verifyChecksums() error {
+	data, err := ioutil.ReadFile(rc.filepath)
+	if err != nil {
+		return errors.AddContext(err, "failed to read refcounter file")
+	}
+	sums, err := ioutil.ReadFile(checksumFilePath(rc.filepath))
+	if os.IsNotExist(err) {
+		return errors.AddContext(ErrCorruptRefCounter, "checksum file is missing")
+	}
+	if err != nil {
+		return errors.AddContext(err, "failed to read checksum file")
+	}
+	expected := refCounterChecksums(data)
+	if len(sums) != len(expected) {
+		return errors.AddContext(ErrCorruptRefCounter, fmt.Sprintf("checksum file has %d bytes, expected %d", len(sums), len(expected)))
+	}
+	if binary.LittleEndian.Uint32(sums) != binary.LittleEndian.Uint32(expected) {
+		return errors.AddContext(ErrCorruptRefCounter, "header checksum mismatch")
+	}
+	for off := refCounterChecksumSize; off < len(sums); off += refCounterChecksumSize {
+		if binary.LittleEndian.Uint32(sums[off:]) != binary.LittleEndian.Uint32(expected[off:]) {
+			p := uint64(off/refCounterChecksumSize - 1)
+			first := p * refCounterChecksumPageSize / 2
+			return errors.AddContext(ErrCorruptRefCounter, fmt.Sprintf("checksum mismatch in page %d, starting at sector %d", p, first))
+		}
+	}
+	return nil
+}
+
+// checksumFilePath returns the path of the checksum file that belongs to the
+// refcounter at the given path.
+func checksumFilePath(path string) string {
+	return path + refCounterChecksumSuffix
+}
+
+// refCounterChecksums returns the contents of the checksum file for the given
+// contents of a refcounter file.
+func refCounterChecksums(data []byte) []byte {
+	header, counts := data[:refCounterHeaderSize], data[refCounterHeaderSize:]
+	numPages := (len(counts) + refCounterChecksumPageSize - 1) / refCounterChecksumPageSize
+	sums := make([]byte, refCounterChecksumSize*(numPages+1))
+	binary.LittleEndian.PutUint32(sums, crc32.Checksum(header, refCounterChecksumTable))
+	for p := 0; p < numPages; p++ {
+		start := p * refCounterChecksumPageSize
+		end := start + refCounterChecksumPageSize
+		if end > len(counts) {
+			end = len(counts)
+		}
+		binary.LittleEndian.PutUint32(sums[refCounterChecksumSize*(p+1):], crc32.Checksum(counts[start:end], refCounterChecksumTable))
+	}
+	return sums
+}
+
+// createWriteChecksumsUpdate is a helper function which creates a
+// writeaheadlog update for writing the checksums of the given pages to the
+// checksum file of the refcounter at the given path. The checksum file is
+// truncated to numPages pages.
+func createWriteChecksumsUpdate(path string, numPages uint64, sums map[uint64]uint32) writeaheadlog.Update {
+	pages := make([]uint64, 0, len(sums))
+	for p := range sums {
+		pages = append(pages, p)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i] < pages[j] })
+
+	entrySize := 8 + refCounterChecksumSize
+	b := make([]byte, 16+len(path)+len(pages)*entrySize)
+	binary.LittleEndian.PutUint64(b[:8], uint64(len(path)))
+	copy(b[8:8+len(path)], path)
+	binary.LittleEndian.PutUint64(b[8+len(path):], numPages)
+	entries := b[16+len(path):]
+	for i, p := range pages {
+		binary.LittleEndian.PutUint64(entries[i*entrySize:], p)
+		binary.LittleEndian.PutUint32(entries[i*entrySize+8:], sums[p])
+	}
+	return writeaheadlog.Update{
+		Name:         updateNameRCWriteChecksums,
+		Instructions: b,
+	}
+}
+
+// applyWriteChecksumsUpdate parses and applies a WriteChecksums update.
+func applyWriteChecksumsUpdate(u writeaheadlog.Update) (err error) {
+	if u.Name != updateNameRCWriteChecksums {
+		return errors.AddContext(ErrUnknownUpdate, fmt.Sprintf("applyWriteChecksumsUpdate called on update of type %v", u.Name))
+	}
+	// Decode update.
+	path, numPages, pages, sums, err := readWriteChecksumsUpdate(u)
+	if err != nil {
+		return err
+	}
+
+	// Write the checksums to disk.
+	f, err := os.OpenFile(checksumFilePath(path), os.O_CREATE|os.O_RDWR, modules.DefaultFilePerm)
+	if err != nil {
+		return errors.AddContext(err, "failed to open checksum file")
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	var b [refCounterChecksumSize]byte
+	for i, p := range pages {
+		binary.LittleEndian.PutUint32(b[:], sums[i])
+		if _, err = f.WriteAt(b[:], int64((p+1)*refCounterChecksumSize)); err != nil {
+			return err
+		}
+	}
+	if err = f.Truncate(int64((numPages + 1) * refCounterChecksumSize)); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// readWriteChecksumsUpdate decodes a WriteChecksums update.
+func readWriteChecksumsUpdate(u writeaheadlog.Update) (path string, numPages uint64, pages []uint64, sums []uint32, err error) {
+	if len(u.Instructions) < 8 {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
+	pathLen := binary.LittleEndian.Uint64(u.Instructions[:8])
+	rest := uint64(len(u.Instructions)) - 8
+	if pathLen > rest || rest-pathLen < 8 {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
+	path = string(u.Instructions[8 : 8+pathLen])
+	numPages = binary.LittleEndian.Uint64(u.Instructions[8+pathLen:])
+	if numPages > refCounterChecksumMaxPages {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
+	entries := u.Instructions[16+pathLen:]
+	entrySize := uint64(8 + refCounterChecksumSize)
+	if uint64(len(entries))%entrySize != 0 {
+		err = ErrInvalidUpdateInstruction
+		return
+	}
+	for i := uint64(0); i < uint64(len(entries)); i += entrySize {
+		p := binary.LittleEndian.Uint64(entries[i:])
+		if p >= numPages {
+			return "", 0, nil, nil, ErrInvalidUpdateInstruction
+		}
+		pages = append(pages, p)
+		sums = append(sums, binary.LittleEndian.Uint32(entries[i+8:]))
+	}
+	return
+}
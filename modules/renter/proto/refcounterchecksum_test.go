@@ -0,0 +1,363 @@
+package proto
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/writeaheadlog"
+
+	"go.sia.tech/siad/modules"
+)
+
+// flipByte flips the bits of the byte at the given offset of the file.
+func flipByte(t *testing.T, path string, off int64) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_RDWR, modules.DefaultFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, off); err != nil {
+		t.Fatal(err)
+	}
+	b[0] = ^b[0]
+	if _, err := f.WriteAt(b, off); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRefCounterChecksums verifies that the checksums are kept up to date by
+// update sessions that change counts across pages, append sectors and drop
+// them again.
+func TestRefCounterChecksums(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	countsPerPage := uint64(refCounterChecksumPageSize / 2)
+	rc := testPrepareRefCounter(2*countsPerPage+10, t)
+
+	// session applies the updates created by fn and verifies the checksums
+	session := func(fn func() ([]writeaheadlog.Update, error)) {
+		t.Helper()
+		if err := rc.callStartUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		us, err := fn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callCreateAndApplyTransaction(us...); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.callValidate(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadRefCounter(rc.filepath, testWAL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rc.callValidate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// change counts on every page
+	session(func() ([]writeaheadlog.Update, error) {
+		return rc.callUpdateCounts(map[uint64]int{0: 1, countsPerPage - 1: 2, countsPerPage: 3, 2 * countsPerPage: -1})
+	})
+	session(func() ([]writeaheadlog.Update, error) {
+		return rc.callSwap(1, 2*countsPerPage+9)
+	})
+
+	// append sectors until a new page is needed
+	session(func() ([]writeaheadlog.Update, error) {
+		var us []writeaheadlog.Update
+		for rc.numSectors <= 3*countsPerPage {
+			u, err := rc.callAppend()
+			if err != nil {
+				return nil, err
+			}
+			us = append(us, u)
+		}
+		return us, nil
+	})
+
+	// drop sectors across a page boundary and append one again in the same
+	// session
+	session(func() ([]writeaheadlog.Update, error) {
+		u1, err := rc.callDropSectors(countsPerPage + 5)
+		if err != nil {
+			return nil, err
+		}
+		u2, err := rc.callAppend()
+		return []writeaheadlog.Update{u1, u2}, err
+	})
+
+	// drop all sectors
+	session(func() ([]writeaheadlog.Update, error) {
+		u, err := rc.callDropSectors(rc.numSectors)
+		return []writeaheadlog.Update{u}, err
+	})
+
+	// deleting the refcounter deletes the checksum file
+	session(func() ([]writeaheadlog.Update, error) {
+		u, err := rc.callAppend()
+		return []writeaheadlog.Update{u}, err
+	})
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := rc.callDeleteRefCounter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(checksumFilePath(rc.filepath)); !os.IsNotExist(err) {
+		t.Fatal("checksum file should have been deleted", err)
+	}
+}
+
+// TestRefCounterChecksumsCorruption verifies that flipped bytes in the counter
+// area and in the checksum file, as well as a missing checksum file, are
+// detected on load.
+func TestRefCounterChecksumsCorruption(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	countsPerPage := uint64(refCounterChecksumPageSize / 2)
+	rc := testPrepareRefCounter(2*countsPerPage+10, t)
+	fileSize := int64(offset(rc.numSectors))
+
+	// flipped bytes in the counter area and the checksum file are detected
+	tests := []struct {
+		path string
+		off  int64
+	}{
+		{rc.filepath, refCounterHeaderSize},
+		{rc.filepath, refCounterHeaderSize + refCounterChecksumPageSize - 1},
+		{rc.filepath, refCounterHeaderSize + refCounterChecksumPageSize},
+		{rc.filepath, fileSize - 1},
+		{checksumFilePath(rc.filepath), 0},
+		{checksumFilePath(rc.filepath), refCounterChecksumSize + 1},
+		{checksumFilePath(rc.filepath), 3*refCounterChecksumSize + 3},
+	}
+	for _, test := range tests {
+		flipByte(t, test.path, test.off)
+		if _, err := loadRefCounter(rc.filepath, testWAL); !errors.Contains(err, ErrCorruptRefCounter) {
+			t.Fatalf("flipped byte at offset %v of %v wasn't detected: %v", test.off, test.path, err)
+		}
+		if err := rc.callValidate(); !errors.Contains(err, ErrCorruptRefCounter) {
+			t.Fatalf("flipped byte at offset %v of %v wasn't detected: %v", test.off, test.path, err)
+		}
+		flipByte(t, test.path, test.off)
+		if _, err := loadRefCounter(rc.filepath, testWAL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a flipped byte in the version is detected as well
+	flipByte(t, rc.filepath, 0)
+	if _, err := loadRefCounter(rc.filepath, testWAL); !errors.Contains(err, ErrInvalidVersion) {
+		t.Fatal("Expected ErrInvalidVersion, got:", err)
+	}
+	flipByte(t, rc.filepath, 0)
+
+	// a missing checksum file is detected
+	if err := os.Remove(checksumFilePath(rc.filepath)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRefCounter(rc.filepath, testWAL); !errors.Contains(err, ErrCorruptRefCounter) {
+		t.Fatal("Expected ErrCorruptRefCounter, got:", err)
+	}
+}
+
+// TestRefCounterLegacy verifies that refcounters of the legacy version load
+// without checksums and that they can be upgraded to the current version.
+func TestRefCounterLegacy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// turn a new refcounter into a legacy one
+	rc := testPrepareRefCounter(10, t)
+	if err := os.Remove(checksumFilePath(rc.filepath)); err != nil {
+		t.Fatal(err)
+	}
+	legacyHeader := serializeHeader(refCounterHeader{Version: refCounterVersionLegacy})
+	f, err := os.OpenFile(rc.filepath, os.O_RDWR, modules.DefaultFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(legacyHeader, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the legacy refcounter loads and can be updated without checksums
+	legacy, err := loadRefCounter(rc.filepath, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Version != refCounterVersionLegacy {
+		t.Fatal("unexpected version", legacy.Version)
+	}
+	if err := legacy.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := legacy.callIncrement(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(checksumFilePath(rc.filepath)); !os.IsNotExist(err) {
+		t.Fatal("legacy refcounter shouldn't have a checksum file", err)
+	}
+
+	// it can't be upgraded during an update session
+	if err := legacy.callUpgrade(); !errors.Contains(err, ErrUpgradeDuringUpdate) {
+		t.Fatal("Expected ErrUpgradeDuringUpdate, got:", err)
+	}
+	if err := legacy.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a flipped count isn't detected
+	flipByte(t, rc.filepath, refCounterHeaderSize)
+	if _, err := loadRefCounter(rc.filepath, testWAL); err != nil {
+		t.Fatal(err)
+	}
+	flipByte(t, rc.filepath, refCounterHeaderSize)
+
+	// upgrade the refcounter, the counts are unchanged
+	if err := legacy.callUpgrade(); err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Version != refCounterVersion {
+		t.Fatal("unexpected version", legacy.Version)
+	}
+	upgraded, err := loadRefCounter(rc.filepath, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upgraded.Version != refCounterVersion {
+		t.Fatal("unexpected version", upgraded.Version)
+	}
+	counts, err := upgraded.callCountRange(0, upgraded.numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint16{1, 1, 1, 2, 1, 1, 1, 1, 1, 1}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Fatal("unexpected counts", counts)
+	}
+
+	// the upgraded refcounter keeps its checksums up to date and detects
+	// corruption
+	if err := legacy.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err = legacy.callDecrement(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRefCounter(rc.filepath, testWAL); err != nil {
+		t.Fatal(err)
+	}
+	flipByte(t, rc.filepath, refCounterHeaderSize)
+	if _, err := loadRefCounter(rc.filepath, testWAL); !errors.Contains(err, ErrCorruptRefCounter) {
+		t.Fatal("Expected ErrCorruptRefCounter, got:", err)
+	}
+
+	// upgrading a current refcounter is a no-op
+	if err := upgraded.callUpgrade(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRefCounterWriteChecksumsUpdate tests the WAL functions of the
+// WriteChecksums update.
+func TestRefCounterWriteChecksumsUpdate(t *testing.T) {
+	t.Parallel()
+
+	rc := testPrepareRefCounter(10, t)
+	sums := map[uint64]uint32{0: 1, 2: 3, 5: 4}
+	u := createWriteChecksumsUpdate(rc.filepath, 6, sums)
+	path, numPages, pages, values, err := readWriteChecksumsUpdate(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != rc.filepath || numPages != 6 || !reflect.DeepEqual(pages, []uint64{0, 2, 5}) || !reflect.DeepEqual(values, []uint32{1, 3, 4}) {
+		t.Fatal("unexpected update", path, numPages, pages, values)
+	}
+
+	// apply the update, the checksum file is resized to the number of pages
+	if err := applyWriteChecksumsUpdate(u); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(checksumFilePath(rc.filepath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 7*refCounterChecksumSize || b[refCounterChecksumSize] != 1 || b[3*refCounterChecksumSize] != 3 || b[6*refCounterChecksumSize] != 4 {
+		t.Fatal("unexpected checksum file", b)
+	}
+
+	// invalid instructions are rejected
+	for _, instructions := range [][]byte{{1}, u.Instructions[:8+len(path)], u.Instructions[:len(u.Instructions)-1]} {
+		invalid := writeaheadlog.Update{Name: updateNameRCWriteChecksums, Instructions: instructions}
+		if _, _, _, _, err := readWriteChecksumsUpdate(invalid); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("Expected ErrInvalidUpdateInstruction, got:", err)
+		}
+	}
+
+	// pages beyond the number of pages and more pages than a refcounter can
+	// have are rejected
+	for _, invalid := range []writeaheadlog.Update{
+		createWriteChecksumsUpdate(rc.filepath, 5, sums),
+		createWriteChecksumsUpdate(rc.filepath, 0, map[uint64]uint32{0: 1}),
+		createWriteChecksumsUpdate(rc.filepath, refCounterChecksumMaxPages+1, nil),
+		createWriteChecksumsUpdate(rc.filepath, math.MaxUint64, nil),
+	} {
+		if _, _, _, _, err := readWriteChecksumsUpdate(invalid); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("Expected ErrInvalidUpdateInstruction, got:", err)
+		}
+		if err := applyWriteChecksumsUpdate(invalid); !errors.Contains(err, ErrInvalidUpdateInstruction) {
+			t.Fatal("Expected ErrInvalidUpdateInstruction, got:", err)
+		}
+	}
+	u = createWriteChecksumsUpdate(rc.filepath, refCounterChecksumMaxPages, map[uint64]uint32{refCounterChecksumMaxPages - 1: 1})
+	if _, _, _, _, err := readWriteChecksumsUpdate(u); err != nil {
+		t.Fatal(err)
+	}
+}
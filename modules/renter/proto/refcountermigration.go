@@ -0,0 +1,126 @@
+package proto
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/writeaheadlog"
+)
+
+// Refcounter files are versioned by their header. Every version except for the
+// current one has a migration to the version that followed it. A refcounter of
+// an older version is migrated one version at a time. Every step is a single
+// WAL transaction which first adjusts the layout of the files and then
+// rewrites the header, so the version is only bumped once the layout of the
+// next version is on disk. A crash during a step is recovered by replaying
+// the transaction, a crash between steps leaves a valid refcounter of an
+// intermediate version behind which is migrated further the next time it is
+// loaded.
+
+type (
+	// refCounterMigration migrates a refcounter file to the next version.
+	refCounterMigration struct {
+		// next is the version of the refcounter after the migration.
+		next [8]byte
+
+		// updates returns the updates that adjust the layout of the
+		// refcounter to the one of the next version. data is the content of
+		// the refcounter file with the header of the next version. The
+		// updates must be idempotent, the header is rewritten after them.
+		updates func(path string, data []byte) ([]writeaheadlog.Update, error)
+	}
+)
+
+var (
+	// refCounterMigrations are the migrations of the refcounter file format,
+	// keyed by the version they migrate from.
+	refCounterMigrations = map[[8]byte]refCounterMigration{
+		refCounterVersionLegacy: {
+			next:    refCounterVersion,
+			updates: migrateRefCounterChecksums,
+		},
+	}
+)
+
+// migrateRefCounterChecksums migrates a legacy refcounter by writing its
+// checksum file. A checksum file that might be left over from an interrupted
+// migration is removed first, it is only trusted once the header was bumped.
+func migrateRefCounterChecksums(path string, data []byte) ([]writeaheadlog.Update, error) {
+	if err := os.Remove(checksumFilePath(path)); err != nil && !os.IsNotExist(err) {
+		return nil, errors.AddContext(err, "failed to remove stale checksum file")
+	}
+	return []writeaheadlog.Update{writeaheadlog.WriteAtUpdate(checksumFilePath(path), 0, refCounterChecksums(data))}, nil
+}
+
+// refCounterVersionSupported returns whether a refcounter of the given version
+// can be loaded. These are the current version and every version with a
+// migration.
+func refCounterVersionSupported(version [8]byte) bool {
+	if version == refCounterVersion {
+		return true
+	}
+	_, exists := refCounterMigrations[version]
+	return exists
+}
+
+// refCounterMigrationUpdates returns the updates of a single migration step of
+// the refcounter at the given path with the given content, as well as the
+// header after the step. The header update is always the last one.
+func refCounterMigrationUpdates(path string, data []byte) ([]writeaheadlog.Update, refCounterHeader, error) {
+	var h refCounterHeader
+	if err := deserializeHeader(data, &h); err != nil {
+		return nil, refCounterHeader{}, errors.AddContext(err, "failed to read refcounter header")
+	}
+	m, exists := refCounterMigrations[h.Version]
+	if !exists {
+		return nil, refCounterHeader{}, errors.AddContext(ErrInvalidVersion, fmt.Sprintf("no migration from version %d", h.Version))
+	}
+	next := refCounterHeader{
+		Version: m.next,
+	}
+	migrated := append([]byte{}, data...)
+	copy(migrated, serializeHeader(next))
+	updates, err := m.updates(path, migrated)
+	if err != nil {
+		return nil, refCounterHeader{}, err
+	}
+	updates = append(updates, writeaheadlog.WriteAtUpdate(path, 0, serializeHeader(next)))
+	return updates, next, nil
+}
+
+// callUpgrade migrates a refcounter of an older version to the current version
+// in place. Refcounters that are current already are left untouched. It is not
+// possible to upgrade a refcounter while an update session is open.
+func (rc *refCounter) callUpgrade() error {
+	if rc.staticReadOnly {
+		return ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.isDeleted {
+		return ErrUpdateAfterDelete
+	}
+	if rc.isUpdateInProgress {
+		return ErrUpgradeDuringUpdate
+	}
+	if rc.staticMemory != nil {
+		return nil
+	}
+	for rc.Version != refCounterVersion {
+		data, err := ioutil.ReadFile(rc.filepath)
+		if err != nil {
+			return errors.AddContext(err, "failed to read refcounter file")
+		}
+		updates, h, err := refCounterMigrationUpdates(rc.filepath, data)
+		if err != nil {
+			return errors.AddContext(err, "failed to prepare refcounter migration")
+		}
+		if err = rc.staticWal.CreateAndApplyTransaction(ApplyUpdates, updates...); err != nil {
+			return errors.AddContext(err, fmt.Sprintf("failed to migrate refcounter from version %d to %d", rc.Version, h.Version))
+		}
+		rc.refCounterHeader = h
+	}
+	return nil
+}
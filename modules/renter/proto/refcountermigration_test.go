@@ -0,0 +1,270 @@
+package proto
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/writeaheadlog"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+var (
+	// v1RefCounterLocation is a refcounter of the legacy version 1. It has
+	// v1RefCounterNumSectors sectors, see v1RefCounterCount for their counts.
+	v1RefCounterLocation = filepath.Join("testdata", "v1.refcounter")
+
+	// v1RefCounterNumSectors is the number of sectors of the v1 fixture. They
+	// span more than one checksum page.
+	v1RefCounterNumSectors = uint64(2500)
+)
+
+// v1RefCounterCount returns the count of a sector of the v1 fixture.
+func v1RefCounterCount(secIdx uint64) uint16 {
+	if secIdx == v1RefCounterNumSectors-1 {
+		return math.MaxUint16
+	}
+	return uint16(secIdx * 7 % 5)
+}
+
+// testPrepareV1RefCounter copies the v1 fixture into the test's directory and
+// returns the path of the copy.
+func testPrepareV1RefCounter(t *testing.T) string {
+	data, err := ioutil.ReadFile(v1RefCounterLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := build.TempDir(t.Name())
+	if err := os.MkdirAll(td, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(td, "v1"+refCounterExtension)
+	if err := ioutil.WriteFile(path, data, modules.DefaultFilePerm); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testVerifyV1RefCounter verifies that the refcounter at the given path has the
+// given version and the counts of the v1 fixture.
+func testVerifyV1RefCounter(t *testing.T, path string, version [8]byte) {
+	t.Helper()
+	rc, err := loadRefCounter(path, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc.Version != version {
+		t.Fatalf("Expected version %v, got %v", version, rc.Version)
+	}
+	if rc.numSectors != v1RefCounterNumSectors {
+		t.Fatalf("Expected %v sectors, got %v", v1RefCounterNumSectors, rc.numSectors)
+	}
+	counts, err := rc.callCountRange(0, rc.numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var garbage uint64
+	for i, count := range counts {
+		if expected := v1RefCounterCount(uint64(i)); count != expected {
+			t.Fatalf("Expected count %v for sector %v, got %v", expected, i, count)
+		}
+		if count == 0 {
+			garbage++
+		}
+	}
+	if rc.callGarbageCount() != garbage {
+		t.Fatalf("Expected %v garbage sectors, got %v", garbage, rc.callGarbageCount())
+	}
+}
+
+// TestRefCounterMigrateV1 loads the v1 fixture, migrates it to the current
+// version and verifies that all counts survived the migration.
+func TestRefCounterMigrateV1(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// the fixture loads as a legacy refcounter without checksums
+	path := testPrepareV1RefCounter(t)
+	testVerifyV1RefCounter(t, path, refCounterVersionLegacy)
+	if _, err := os.Stat(checksumFilePath(path)); !os.IsNotExist(err) {
+		t.Fatal("legacy refcounter shouldn't have a checksum file", err)
+	}
+
+	// the contract set migrates it when loading it
+	cs := &ContractSet{staticWal: testWAL}
+	rc, err := cs.loadRefCounter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc.Version != refCounterVersion {
+		t.Fatal("unexpected version after migration", rc.Version)
+	}
+	testVerifyV1RefCounter(t, path, refCounterVersion)
+
+	// the migrated refcounter keeps its checksums up to date
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := rc.callSetCount(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRefCounter(path, testWAL); err != nil {
+		t.Fatal(err)
+	}
+
+	// migrating a current refcounter doesn't touch its files
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpgrade(); err != nil {
+		t.Fatal(err)
+	}
+	data2, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, data2) {
+		t.Fatal("migrating a current refcounter changed its file")
+	}
+
+	// a refcounter without a migration can't be loaded
+	if _, _, err := refCounterMigrationUpdates(path, data); !errors.Contains(err, ErrInvalidVersion) {
+		t.Fatal("Expected ErrInvalidVersion, got:", err)
+	}
+	unknown := serializeHeader(refCounterHeader{Version: [8]byte{3}})
+	copy(data, unknown)
+	if err := ioutil.WriteFile(path, data, modules.DefaultFilePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRefCounter(path, testWAL); !errors.Contains(err, ErrInvalidVersion) {
+		t.Fatal("Expected ErrInvalidVersion, got:", err)
+	}
+}
+
+// TestRefCounterMigrationCrash verifies that a crash between the updates of a
+// migration leaves a refcounter behind that loads correctly, both before and
+// after replaying the migration from the WAL.
+func TestRefCounterMigrationCrash(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// prepare the migration, the header is bumped by the last update
+	path := testPrepareV1RefCounter(t)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates, h, err := refCounterMigrationUpdates(path, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != refCounterVersion {
+		t.Fatal("unexpected version after migration", h.Version)
+	}
+	if len(updates) < 2 {
+		t.Fatal("expected the layout updates to be followed by the header update", len(updates))
+	}
+
+	// apply all updates but the header update and "crash"
+	wal, walPath := newTestWAL()
+	txn, err := wal.NewTransaction(updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-txn.SignalSetupComplete(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyUpdates(updates[:len(updates)-1]...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wal.CloseIncomplete(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the refcounter still loads as a legacy refcounter
+	testVerifyV1RefCounter(t, path, refCounterVersionLegacy)
+
+	// replay the migration from the WAL
+	txns, wal, err := writeaheadlog.New(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 1 || !isRefCounterTxn(txns[0]) {
+		t.Fatal("expected the migration to be recovered from the WAL", len(txns))
+	}
+	if err := ApplyUpdates(txns[0].Updates...); err != nil {
+		t.Fatal(err)
+	}
+	if err := txns[0].SignalUpdatesApplied(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	testVerifyV1RefCounter(t, path, refCounterVersion)
+}
+
+// TestRefCounterMigrationInterrupted verifies that a migration which was
+// interrupted without being committed to the WAL can be repeated.
+func TestRefCounterMigrationInterrupted(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// apply all updates but the header update, without a WAL
+	path := testPrepareV1RefCounter(t)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates, _, err := refCounterMigrationUpdates(path, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyUpdates(updates[:len(updates)-1]...); err != nil {
+		t.Fatal(err)
+	}
+
+	// make the checksum file that was left behind stale, as if the legacy
+	// refcounter was changed after the interrupted migration
+	f, err := os.OpenFile(checksumFilePath(path), os.O_RDWR|os.O_APPEND, modules.DefaultFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(make([]byte, refCounterChecksumSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	flipByte(t, checksumFilePath(path), 0)
+
+	// the migration is repeated from the start
+	rc, err := loadRefCounter(path, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpgrade(); err != nil {
+		t.Fatal(err)
+	}
+	testVerifyV1RefCounter(t, path, refCounterVersion)
+}
@@ -0,0 +1,105 @@
+package proto
+
+import (
+	"os"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// RefCounterReadOnly is a read-only handle of a contract's refcounter. It is
+// meant for status and audit tooling that inspects the refcounter of a live
+// contract. It never writes to the refcounter's files and doesn't take part in
+// its update sessions, so it doesn't contend with the process that owns the
+// refcounter. Since that process can change the file at any time, the number
+// of sectors is re-read from the file before every operation.
+type RefCounterReadOnly struct {
+	staticRC *refCounter
+}
+
+// LoadRefCounterReadOnly opens the refcounter at the given path read-only. The
+// refcounter isn't validated against its checksums, they might lag behind the
+// counts while the owning process applies an update.
+func LoadRefCounterReadOnly(path string) (*RefCounterReadOnly, error) {
+	rc, err := openRefCounter(path, nil)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to open refcounter read-only")
+	}
+	rc.staticReadOnly = true
+	return &RefCounterReadOnly{staticRC: rc}, nil
+}
+
+// Count returns the number of references to the given sector.
+func (rc *RefCounterReadOnly) Count(secIdx uint64) (uint16, error) {
+	if err := rc.staticRC.callRefreshNumSectors(); err != nil {
+		return 0, err
+	}
+	return rc.staticRC.callCount(secIdx)
+}
+
+// ForEach calls fn with the count of every sector of the refcounter in order.
+// Iteration stops at the first error returned by fn. Sectors that are dropped
+// by the owning process during the iteration aren't reported.
+func (rc *RefCounterReadOnly) ForEach(fn func(secIdx uint64, count uint16) error) error {
+	if err := rc.staticRC.callRefreshNumSectors(); err != nil {
+		return err
+	}
+	return rc.staticRC.callForEach(fn)
+}
+
+// GarbageCount returns the number of sectors that aren't referenced anymore.
+// The owning process keeps changing the counts, so they are counted anew on
+// every call.
+func (rc *RefCounterReadOnly) GarbageCount() (uint64, error) {
+	var garbage uint64
+	err := rc.ForEach(func(_ uint64, count uint16) error {
+		if count == 0 {
+			garbage++
+		}
+		return nil
+	})
+	return garbage, err
+}
+
+// NumSectors returns the number of sectors tracked by the refcounter.
+func (rc *RefCounterReadOnly) NumSectors() (uint64, error) {
+	if err := rc.staticRC.callRefreshNumSectors(); err != nil {
+		return 0, err
+	}
+	return rc.staticRC.callNumSectors(), nil
+}
+
+// TotalReferences returns the sum of the counts of all sectors, which is the
+// total number of references to the sectors of the contract. Like
+// GarbageCount, the counts are summed anew on every call.
+func (rc *RefCounterReadOnly) TotalReferences() (uint64, error) {
+	var total uint64
+	err := rc.ForEach(func(_ uint64, count uint16) error {
+		total += uint64(count)
+		return nil
+	})
+	return total, err
+}
+
+// callRefreshNumSectors re-reads the number of sectors of a read-only
+// refcounter from the size of its file. A trailing partial counter that the
+// owning process is still writing is ignored.
+func (rc *refCounter) callRefreshNumSectors() error {
+	if !rc.staticReadOnly {
+		return nil
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	fi, err := os.Stat(rc.filepath)
+	if os.IsNotExist(err) {
+		return ErrRefCounterNotExist
+	}
+	if err != nil {
+		return errors.AddContext(err, "failed to read file stats")
+	}
+	if fi.Size() < refCounterHeaderSize {
+		rc.numSectors = 0
+		return nil
+	}
+	rc.numSectors = uint64((fi.Size() - refCounterHeaderSize) / 2)
+	return nil
+}
@@ -0,0 +1,206 @@
+package proto
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/writeaheadlog"
+)
+
+// TestRefCounterReadOnly verifies that a read-only refcounter reads the counts
+// of a refcounter that is changed by its owner, but never changes it.
+func TestRefCounterReadOnly(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// prepare a refcounter with a garbage sector
+	owner := testPrepareRefCounter(4, t)
+	apply := func(updates ...writeaheadlog.Update) {
+		if err := owner.callCreateAndApplyTransaction(updates...); err != nil {
+			t.Fatal(err)
+		}
+		if err := owner.callUpdateApplied(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := owner.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := owner.callSetCount(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apply(u)
+
+	rc, err := LoadRefCounterReadOnly(owner.filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRefCounterReadOnly(owner.filepath + ".missing"); !errors.Contains(err, ErrRefCounterNotExist) {
+		t.Fatal("Expected ErrRefCounterNotExist, got:", err)
+	}
+	assertCounts := func(expected ...uint16) {
+		t.Helper()
+		numSectors, err := rc.NumSectors()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if numSectors != uint64(len(expected)) {
+			t.Fatalf("Expected %v sectors, got %v", len(expected), numSectors)
+		}
+		var counts []uint16
+		err = rc.ForEach(func(secIdx uint64, count uint16) error {
+			counts = append(counts, count)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var garbage, total uint64
+		for i, count := range expected {
+			if counts[i] != count {
+				t.Fatalf("Expected count %v for sector %v, got %v", count, i, counts[i])
+			}
+			c, err := rc.Count(uint64(i))
+			if err != nil || c != count {
+				t.Fatalf("Expected count %v for sector %v, got %v %v", count, i, c, err)
+			}
+			if count == 0 {
+				garbage++
+			}
+			total += uint64(count)
+		}
+		if g, err := rc.GarbageCount(); err != nil || g != garbage {
+			t.Fatalf("Expected %v garbage sectors, got %v %v", garbage, g, err)
+		}
+		if tr, err := rc.TotalReferences(); err != nil || tr != total {
+			t.Fatalf("Expected %v total references, got %v %v", total, tr, err)
+		}
+	}
+	assertCounts(1, 0, 1, 1)
+
+	// the mutating methods are rejected without touching the file
+	data, err := ioutil.ReadFile(owner.filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rorc := rc.staticRC
+	mutators := map[string]func() error{
+		"AbortUpdate": rorc.callAbortUpdate,
+		"Append": func() error {
+			_, err := rorc.callAppend()
+			return err
+		},
+		"CreateAndApplyTransaction": func() error { return rorc.callCreateAndApplyTransaction(u) },
+		"Decrement": func() error {
+			_, err := rorc.callDecrement(0)
+			return err
+		},
+		"DeleteRefCounter": func() error {
+			_, err := rorc.callDeleteRefCounter()
+			return err
+		},
+		"DropSectors": func() error {
+			_, err := rorc.callDropSectors(1)
+			return err
+		},
+		"Increment": func() error {
+			_, err := rorc.callIncrement(0)
+			return err
+		},
+		"RemoveSectors": func() error {
+			_, _, err := rorc.callRemoveSectors([]uint64{0})
+			return err
+		},
+		"Rename": func() error { return rorc.callRename(owner.filepath + ".renamed") },
+		"Repair": rorc.callRepair,
+		"Reset":  func() error { return rorc.callReset(1) },
+		"SetCount": func() error {
+			_, err := rorc.callSetCount(0, 2)
+			return err
+		},
+		"StartUpdate": rorc.callStartUpdate,
+		"Swap": func() error {
+			_, err := rorc.callSwap(0, 1)
+			return err
+		},
+		"SwapBatch": func() error {
+			_, err := rorc.callSwapBatch([][2]uint64{{0, 1}})
+			return err
+		},
+		"UpdateApplied": rorc.callUpdateApplied,
+		"UpdateCounts": func() error {
+			_, err := rorc.callUpdateCounts(map[uint64]int{0: 1})
+			return err
+		},
+		"Upgrade": rorc.callUpgrade,
+	}
+	for name, fn := range mutators {
+		if err := fn(); !errors.Contains(err, ErrReadOnly) {
+			t.Fatalf("%v: expected ErrReadOnly, got %v", name, err)
+		}
+	}
+	data2, err := ioutil.ReadFile(owner.filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, data2) {
+		t.Fatal("read-only refcounter changed the file")
+	}
+
+	// changes of the owner are picked up
+	if err := owner.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err = owner.callAppend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u2, err := owner.callSetCount(0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apply(u, u2)
+	assertCounts(3, 0, 1, 1, 1)
+	if err := owner.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err = owner.callDropSectors(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apply(u)
+	assertCounts(3, 0)
+
+	// a truncation after the number of sectors was read is tolerated
+	rorc.mu.Lock()
+	rorc.numSectors = 5
+	rorc.mu.Unlock()
+	if _, err := rorc.callCount(3); !errors.Contains(err, ErrInvalidSectorNumber) {
+		t.Fatal("Expected ErrInvalidSectorNumber, got:", err)
+	}
+	var counts []uint16
+	err = rorc.callForEach(func(_ uint64, count uint16) error {
+		counts = append(counts, count)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 2 || counts[0] != 3 || counts[1] != 0 {
+		t.Fatal("unexpected counts after truncation", counts)
+	}
+
+	// a deleted refcounter can't be read anymore
+	if err := os.Remove(owner.filepath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.NumSectors(); !errors.Contains(err, ErrRefCounterNotExist) {
+		t.Fatal("Expected ErrRefCounterNotExist, got:", err)
+	}
+}
@@ -0,0 +1,94 @@
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/writeaheadlog"
+
+	"go.sia.tech/siad/crypto"
+)
+
+// refCounterRebuildData returns the content of a refcounter for a contract with
+// the given sector roots. Every sector has a count of 1, except for sectors
+// whose root is stored at an earlier index of the contract as well. Files
+// reference a sector by its root, so they are served by its first copy and the
+// duplicates are marked as garbage.
+func refCounterRebuildData(roots []crypto.Hash) (data []byte, numGarbage uint64) {
+	data = newRefCounterData(uint64(len(roots)))
+	seen := make(map[crypto.Hash]struct{}, len(roots))
+	for i, root := range roots {
+		if _, exists := seen[root]; exists {
+			binary.LittleEndian.PutUint16(data[offset(uint64(i)):], 0)
+			numGarbage++
+			continue
+		}
+		seen[root] = struct{}{}
+	}
+	return data, numGarbage
+}
+
+// rebuildRefCounterUpdates returns the updates that replace the files of the
+// refcounter at the given path with ones rebuilt from the contract's sector
+// roots. The first update removes whatever is left of the old files, so a
+// single WAL transaction swaps the refcounter atomically.
+func rebuildRefCounterUpdates(path string, data []byte, trackAccess bool) []writeaheadlog.Update {
+	return append([]writeaheadlog.Update{createDeleteUpdate(path)}, createRefCounterDataUpdates(path, data, trackAccess)...)
+}
+
+// rebuildRefCounter replaces the refcounter at the given path, which can be
+// missing or corrupt, with one that is rebuilt from the contract's sector
+// roots and loads it.
+func rebuildRefCounter(path string, roots []crypto.Hash, wal *writeaheadlog.WAL, trackAccess bool) (*refCounter, error) {
+	data, _ := refCounterRebuildData(roots)
+	if err := wal.CreateAndApplyTransaction(ApplyUpdates, rebuildRefCounterUpdates(path, data, trackAccess)...); err != nil {
+		return nil, errors.AddContext(err, "failed to rebuild refcounter")
+	}
+	return loadRefCounter(path, wal)
+}
+
+// callRebuild replaces the files of the refcounter with ones that are rebuilt
+// from the contract's sector roots, see refCounterRebuildData. The pending
+// counts of the refcounter are discarded. It is not possible to rebuild the
+// refcounter while an update session is open.
+func (rc *refCounter) callRebuild(roots []crypto.Hash) error {
+	if rc.staticReadOnly {
+		return ErrReadOnly
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.isDeleted {
+		return ErrUpdateAfterDelete
+	}
+	if rc.isUpdateInProgress {
+		return ErrRebuildDuringUpdate
+	}
+	data, numGarbage := refCounterRebuildData(roots)
+	if rc.staticMemory != nil {
+		rc.staticMemory.data = data
+	} else {
+		updates := rebuildRefCounterUpdates(rc.filepath, data, rc.staticTrackAccess)
+		if err := rc.staticWal.CreateAndApplyTransaction(ApplyUpdates, updates...); err != nil {
+			return errors.AddContext(err, fmt.Sprintf("failed to rebuild refcounter '%v'", rc.filepath))
+		}
+		if rc.staticPreload != nil {
+			if err := rc.preload(); err != nil {
+				return errors.AddContext(err, "failed to preload refcounter")
+			}
+		}
+		// The file was replaced, the mapping needs to cover the new one.
+		if rc.mmap != nil {
+			rc.remap()
+		}
+	}
+	numSec := uint64(len(roots))
+	rc.Version = refCounterVersion
+	rc.numSectors = numSec
+	rc.sessionNumSectors = numSec
+	rc.numGarbage = numGarbage
+	rc.sessionNumGarbage = numGarbage
+	rc.newSectorCounts = make(map[uint64]uint16)
+	rc.newAccessTimes = make(map[uint64]uint32)
+	return nil
+}
@@ -0,0 +1,214 @@
+package proto
+
+import (
+	"encoding/binary"
+	"os"
+	"reflect"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/ratelimit"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// testRefCounterCounts returns all counts of the given refcounter.
+func testRefCounterCounts(t *testing.T, rc *refCounter) []uint16 {
+	t.Helper()
+	counts, err := rc.callCountRange(0, rc.callNumSectors())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return counts
+}
+
+// TestRefCounterRebuild verifies that a refcounter is rebuilt from the sector
+// roots of its contract, with duplicate sectors marked as garbage.
+func TestRefCounterRebuild(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	roots := []crypto.Hash{{1}, {2}, {1}, {3}, {2}, {1}}
+	expected := []uint16{1, 1, 0, 1, 0, 0}
+
+	// the rebuilt data marks the duplicates as garbage
+	data, numGarbage := refCounterRebuildData(roots)
+	if numGarbage != 3 {
+		t.Fatal("unexpected number of garbage sectors", numGarbage)
+	}
+	for i, count := range expected {
+		if c := binary.LittleEndian.Uint16(data[offset(uint64(i)):]); c != count {
+			t.Fatalf("Expected count %v for sector %v, got %v", count, i, c)
+		}
+	}
+
+	// rebuild a refcounter whose counts diverged from the roots
+	rc := testPrepareRefCounter(2, t)
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := rc.callSetCount(0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callRebuild(roots); !errors.Contains(err, ErrRebuildDuringUpdate) {
+		t.Fatal("Expected ErrRebuildDuringUpdate, got:", err)
+	}
+	if err := rc.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callRebuild(roots); err != nil {
+		t.Fatal(err)
+	}
+	if counts := testRefCounterCounts(t, rc); !reflect.DeepEqual(counts, expected) {
+		t.Fatal("unexpected counts", counts)
+	}
+	if rc.callGarbageCount() != 3 {
+		t.Fatal("unexpected number of garbage sectors", rc.callGarbageCount())
+	}
+
+	// the rebuilt refcounter is valid on disk
+	loaded, err := loadRefCounter(rc.filepath, testWAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts := testRefCounterCounts(t, loaded); !reflect.DeepEqual(counts, expected) {
+		t.Fatal("unexpected counts after reload", counts)
+	}
+
+	// a missing refcounter is rebuilt as well
+	if err := rc.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err = rc.callDeleteRefCounter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.callRebuild(roots); !errors.Contains(err, ErrUpdateAfterDelete) {
+		t.Fatal("Expected ErrUpdateAfterDelete, got:", err)
+	}
+	rebuilt, err := rebuildRefCounter(rc.filepath, roots, testWAL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts := testRefCounterCounts(t, rebuilt); !reflect.DeepEqual(counts, expected) {
+		t.Fatal("unexpected counts", counts)
+	}
+
+	// in-memory refcounters are rebuilt in memory
+	mem := newInMemoryRefCounter(1)
+	if err := mem.callRebuild(roots); err != nil {
+		t.Fatal(err)
+	}
+	if counts := testRefCounterCounts(t, mem); !reflect.DeepEqual(counts, expected) {
+		t.Fatal("unexpected counts", counts)
+	}
+}
+
+// TestContractSetRebuildRefCounter verifies that the contract set rebuilds a
+// missing or corrupt refcounter when it loads a contract, and on request.
+func TestContractSetRebuildRefCounter(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	testDir := build.TempDir(t.Name())
+	rl := ratelimit.NewRateLimit(0, 0, 0)
+	cs, err := NewContractSet(testDir, rl, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := contractHeader{Transaction: types.Transaction{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID:             types.FileContractID{1},
+			NewValidProofOutputs: []types.SiacoinOutput{{}, {}},
+			UnlockConditions: types.UnlockConditions{
+				PublicKeys: []types.SiaPublicKey{{}, {}},
+			},
+		}},
+	}}
+	id := header.ID()
+	roots := []crypto.Hash{{1}, {2}, {1}}
+	if _, err := cs.managedInsertContract(header, roots); err != nil {
+		t.Fatal(err)
+	}
+	c := cs.managedMustAcquire(t, id)
+	path := c.staticRC.filepath
+	cs.Return(c)
+
+	// reopen reopens the contract set and verifies that the contract was
+	// loaded with the rebuilt counts and that a warning alert was registered.
+	reopen := func() {
+		t.Helper()
+		cs, err = NewContractSet(testDir, rl, modules.ProdDependencies)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := cs.managedMustAcquire(t, id)
+		defer cs.Return(c)
+		if counts := testRefCounterCounts(t, c.staticRC); !reflect.DeepEqual(counts, []uint16{1, 1, 0}) {
+			t.Fatal("unexpected counts", counts)
+		}
+		crit, _, warn, _ := cs.Alerts()
+		if len(crit) != 0 || len(warn) != 1 || warn[0].ID != modules.AlertIDRenterRefCounterRebuilt(id.String()) {
+			t.Fatal("expected a single rebuild alert", crit, warn)
+		}
+	}
+
+	// a missing refcounter is rebuilt
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	reopen()
+
+	// a corrupt refcounter is rebuilt
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	flipByte(t, path, refCounterHeaderSize)
+	reopen()
+
+	// an intact refcounter is rebuilt on request
+	c = cs.managedMustAcquire(t, id)
+	defer cs.Return(c)
+	if err := c.staticRC.callStartUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := c.staticRC.callSetCount(2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.staticRC.callCreateAndApplyTransaction(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.staticRC.callUpdateApplied(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.RebuildRefCounter(c); err != nil {
+		t.Fatal(err)
+	}
+	if counts := testRefCounterCounts(t, c.staticRC); !reflect.DeepEqual(counts, []uint16{1, 1, 0}) {
+		t.Fatal("unexpected counts", counts)
+	}
+	if _, err := loadRefCounter(path, cs.staticWal); err != nil {
+		t.Fatal("rebuilt refcounter is invalid:", err)
+	}
+}
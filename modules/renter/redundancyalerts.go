@@ -0,0 +1,138 @@
+package renter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+)
+
+// redundancyAlerts tracks the unrecoverable files and the directories with low
+// redundancy that were found while calculating the directories' metadata. The
+// renter registers a single alert for each of the two conditions which names
+// the worst siapath and the number of affected files or directories.
+type redundancyAlerts struct {
+	// unrecoverable maps a directory to the redundancy of each of its
+	// unrecoverable files.
+	unrecoverable map[modules.SiaPath]map[modules.SiaPath]float64
+
+	// lowRedundancy maps a directory to the min redundancy of its files if it
+	// is below AlertDirLowRedundancyThreshold.
+	lowRedundancy map[modules.SiaPath]float64
+
+	mu sync.Mutex
+}
+
+// newRedundancyAlerts returns an empty redundancyAlerts.
+func newRedundancyAlerts() *redundancyAlerts {
+	return &redundancyAlerts{
+		unrecoverable: make(map[modules.SiaPath]map[modules.SiaPath]float64),
+		lowRedundancy: make(map[modules.SiaPath]float64),
+	}
+}
+
+// fileUnrecoverable returns whether a file with the given metadata can't be
+// recovered. That is the case if fewer than MinPieces pieces of one of its
+// chunks are available and there is no local copy of the file to repair from.
+func fileUnrecoverable(md siafile.BubbledMetadata) bool {
+	return !md.OnDisk && md.Redundancy >= 0 && md.Redundancy < 1
+}
+
+// dirLowRedundancy returns whether a directory whose files have the given min
+// redundancy has a low redundancy. Empty directories have a min redundancy of
+// -1.
+func dirLowRedundancy(minRedundancy float64) bool {
+	return minRedundancy >= 0 && minRedundancy < AlertDirLowRedundancyThreshold
+}
+
+// inDirectory returns whether the given directory is the directory at siaPath
+// or one of its subdirectories.
+func inDirectory(dir, siaPath modules.SiaPath) bool {
+	return siaPath.IsRoot() || dir.Equals(siaPath) || strings.HasPrefix(dir.String(), siaPath.String()+"/")
+}
+
+// worstSiaPath returns the siapath with the lowest redundancy. Ties are broken
+// by the siapath to make the result deterministic.
+func worstSiaPath(redundancies map[modules.SiaPath]float64) (worst modules.SiaPath, redundancy float64) {
+	first := true
+	for sp, r := range redundancies {
+		if first || r < redundancy || (r == redundancy && sp.String() < worst.String()) {
+			worst, redundancy = sp, r
+			first = false
+		}
+	}
+	return
+}
+
+// callRemoveDirectory removes the tracked files and directories of the given
+// directory and its subdirectories. It is called when a directory is deleted
+// or renamed. The alerts are updated by the next call to
+// managedUpdateRedundancyAlerts.
+func (ra *redundancyAlerts) callRemoveDirectory(siaPath modules.SiaPath) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	for dir := range ra.unrecoverable {
+		if inDirectory(dir, siaPath) {
+			delete(ra.unrecoverable, dir)
+		}
+	}
+	for dir := range ra.lowRedundancy {
+		if inDirectory(dir, siaPath) {
+			delete(ra.lowRedundancy, dir)
+		}
+	}
+}
+
+// managedUpdateRedundancyAlerts updates the tracked files and the min
+// redundancy of the given directory, replacing what was tracked for it before,
+// and registers or unregisters the renter's redundancy alerts accordingly.
+func (r *Renter) managedUpdateRedundancyAlerts(siaPath modules.SiaPath, files []bubbledSiaFileMetadata, minRedundancy float64) {
+	ra := r.staticRedundancyAlerts
+	ra.mu.Lock()
+	unrecoverable := make(map[modules.SiaPath]float64)
+	for _, file := range files {
+		if fileUnrecoverable(file.bm) {
+			unrecoverable[file.sp] = file.bm.Redundancy
+		}
+	}
+	if len(unrecoverable) > 0 {
+		ra.unrecoverable[siaPath] = unrecoverable
+	} else {
+		delete(ra.unrecoverable, siaPath)
+	}
+	if dirLowRedundancy(minRedundancy) {
+		ra.lowRedundancy[siaPath] = minRedundancy
+	} else {
+		delete(ra.lowRedundancy, siaPath)
+	}
+
+	// Collect the unrecoverable files of all directories.
+	allUnrecoverable := make(map[modules.SiaPath]float64)
+	for _, files := range ra.unrecoverable {
+		for sp, redundancy := range files {
+			allUnrecoverable[sp] = redundancy
+		}
+	}
+	lowRedundancy := make(map[modules.SiaPath]float64, len(ra.lowRedundancy))
+	for dir, redundancy := range ra.lowRedundancy {
+		lowRedundancy[dir] = redundancy
+	}
+	ra.mu.Unlock()
+
+	if len(allUnrecoverable) > 0 {
+		worst, redundancy := worstSiaPath(allUnrecoverable)
+		cause := fmt.Sprintf("%v files are unrecoverable, the worst is '%v' with a redundancy of %.2f", len(allUnrecoverable), worst, redundancy)
+		r.staticAlerter.RegisterAlert(modules.AlertIDRenterUnrecoverableFiles, AlertMSGUnrecoverableFiles, cause, modules.SeverityError)
+	} else {
+		r.staticAlerter.UnregisterAlert(modules.AlertIDRenterUnrecoverableFiles)
+	}
+	if len(lowRedundancy) > 0 {
+		worst, redundancy := worstSiaPath(lowRedundancy)
+		cause := fmt.Sprintf("%v directories have a redundancy below %v, the worst is '%v' with a redundancy of %.2f", len(lowRedundancy), AlertDirLowRedundancyThreshold, worst, redundancy)
+		r.staticAlerter.RegisterAlert(modules.AlertIDRenterLowRedundancyDirs, AlertMSGLowRedundancyDirs, cause, modules.SeverityWarning)
+	} else {
+		r.staticAlerter.UnregisterAlert(modules.AlertIDRenterLowRedundancyDirs)
+	}
+}
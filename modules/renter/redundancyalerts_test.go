@@ -0,0 +1,129 @@
+package renter
+
+import (
+	"strings"
+	"testing"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+	"go.sia.tech/siad/siatest/dependencies"
+)
+
+// TestRedundancyAlerts verifies that the renter registers and unregisters its
+// redundancy alerts as the tracked files and directories change.
+func TestRedundancyAlerts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// alert returns the cause of the alert with the given id if it is
+	// registered with the given severity.
+	alert := func(id modules.AlertID, severity modules.AlertSeverity) (string, bool) {
+		_, errs, warns, _ := r.Alerts()
+		for _, a := range append(errs, warns...) {
+			if a.ID == id && a.Severity == severity {
+				return a.Cause, true
+			}
+		}
+		return "", false
+	}
+	newSiaPath := func(s string) modules.SiaPath {
+		sp, err := modules.NewSiaPath(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sp
+	}
+	file := func(sp string, redundancy float64, onDisk bool) bubbledSiaFileMetadata {
+		return bubbledSiaFileMetadata{
+			sp: newSiaPath(sp),
+			bm: siafile.BubbledMetadata{Redundancy: redundancy, OnDisk: onDisk},
+		}
+	}
+	dirA, dirB := newSiaPath("a"), newSiaPath("a/b")
+
+	// healthy files don't trigger any alert, neither do unrecoverable files
+	// with a local copy
+	r.managedUpdateRedundancyAlerts(dirA, []bubbledSiaFileMetadata{
+		file("a/healthy", 3, false),
+		file("a/local", 0.5, true),
+	}, 3)
+	if _, ok := alert(modules.AlertIDRenterUnrecoverableFiles, modules.SeverityError); ok {
+		t.Fatal("unexpected unrecoverable files alert")
+	}
+	if _, ok := alert(modules.AlertIDRenterLowRedundancyDirs, modules.SeverityWarning); ok {
+		t.Fatal("unexpected low redundancy alert")
+	}
+
+	// unrecoverable files in two directories
+	r.managedUpdateRedundancyAlerts(dirA, []bubbledSiaFileMetadata{
+		file("a/lost", 0.5, false),
+	}, 0.5)
+	r.managedUpdateRedundancyAlerts(dirB, []bubbledSiaFileMetadata{
+		file("a/b/lost", 0.25, false),
+		file("a/b/low", 1.2, false),
+	}, 0.25)
+	cause, ok := alert(modules.AlertIDRenterUnrecoverableFiles, modules.SeverityError)
+	if !ok || !strings.Contains(cause, "2 files") || !strings.Contains(cause, "'a/b/lost'") {
+		t.Fatal("unexpected unrecoverable files alert", cause)
+	}
+	cause, ok = alert(modules.AlertIDRenterLowRedundancyDirs, modules.SeverityWarning)
+	if !ok || !strings.Contains(cause, "2 directories") || !strings.Contains(cause, "'a/b'") {
+		t.Fatal("unexpected low redundancy alert", cause)
+	}
+
+	// the files of the second directory are repaired to a low redundancy, the
+	// alert updates to the remaining unrecoverable file
+	r.managedUpdateRedundancyAlerts(dirB, []bubbledSiaFileMetadata{
+		file("a/b/lost", 1.2, false),
+		file("a/b/low", 1.2, false),
+	}, 1.2)
+	cause, ok = alert(modules.AlertIDRenterUnrecoverableFiles, modules.SeverityError)
+	if !ok || !strings.Contains(cause, "1 files") || !strings.Contains(cause, "'a/lost'") {
+		t.Fatal("unexpected unrecoverable files alert", cause)
+	}
+
+	// deleting the first directory removes it and its subdirectories, once
+	// the directories are updated again the alerts are unregistered
+	r.staticRedundancyAlerts.callRemoveDirectory(dirA)
+	r.managedUpdateRedundancyAlerts(modules.RootSiaPath(), nil, -1)
+	if _, ok := alert(modules.AlertIDRenterUnrecoverableFiles, modules.SeverityError); ok {
+		t.Fatal("unrecoverable files alert should be unregistered")
+	}
+	if _, ok := alert(modules.AlertIDRenterLowRedundancyDirs, modules.SeverityWarning); ok {
+		t.Fatal("low redundancy alert should be unregistered")
+	}
+}
+
+// TestWorstSiaPath is a unit test for worstSiaPath.
+func TestWorstSiaPath(t *testing.T) {
+	t.Parallel()
+
+	a, b, c := modules.RandomSiaPath(), modules.RandomSiaPath(), modules.RandomSiaPath()
+	worst, redundancy := worstSiaPath(map[modules.SiaPath]float64{a: 1, b: 0.5, c: 2})
+	if !worst.Equals(b) || redundancy != 0.5 {
+		t.Fatal("unexpected", worst, redundancy)
+	}
+
+	// ties are broken by the siapath
+	worst, _ = worstSiaPath(map[modules.SiaPath]float64{a: 1, b: 1})
+	expected := a
+	if b.String() < a.String() {
+		expected = b
+	}
+	if !worst.Equals(expected) {
+		t.Fatal("unexpected", worst)
+	}
+}
@@ -318,7 +318,7 @@ func (r *Renter) managedReadRegistry(ctx context.Context, spk types.SiaPublicKey
 		// protection. Should be replaced as part of the gouging overhaul.
 		pt := worker.staticPriceTable().staticPriceTable
 		err := checkProjectDownloadGouging(pt, cache.staticRenterAllowance)
-		if err != nil {
+		if err != nil && !worker.staticGougingExempt(modules.GougingCheckDownload) {
 			r.log.Debugf("price gouging detected in worker %v, err: %v\n", worker.staticHostPubKeyStr, err)
 			continue
 		}
@@ -476,7 +476,7 @@ func (r *Renter) managedUpdateRegistry(ctx context.Context, spk types.SiaPublicK
 			continue
 		}
 		err = checkUploadGouging(cache.staticRenterAllowance, host.HostExternalSettings)
-		if err != nil {
+		if err != nil && !worker.staticGougingExempt(modules.GougingCheckUpload) {
 			r.log.Debugf("price gouging detected in worker %v, err: %v\n", worker.staticHostPubKeyStr, err)
 			continue
 		}
@@ -209,6 +209,10 @@ type Renter struct {
 	// staticBubbleScheduler manages the bubble requests for the renter
 	staticBubbleScheduler *bubbleScheduler
 
+	// staticRedundancyAlerts tracks the unrecoverable files and the
+	// directories with low redundancy for the renter's redundancy alerts.
+	staticRedundancyAlerts *redundancyAlerts
+
 	// cachedUtilities contain contract information used when calculating metadata
 	// information about the filesystem, such as health. This information is used
 	// in various functions such as listing filesystem information and bubble.
@@ -241,6 +245,19 @@ type Renter struct {
 	userDownloadMemoryManager *memoryManager
 	repairMemoryManager       *memoryManager
 
+	// lastGougingSpendingSave is the last time the gouging spending was
+	// saved to disk.
+	lastGougingSpendingSave time.Time
+
+	// staticPCWSGougingCallback is called whenever a chunk worker set rejects
+	// a worker because its host is price gouging. It is optional, by default
+	// the rejections are recorded by the worker pool.
+	staticPCWSGougingCallback pcwsGougingCallback
+
+	// staticPCWSPrefetchCache contains the beginning of the chunks that were
+	// prefetched when their worker set was created.
+	staticPCWSPrefetchCache *pcwsPrefetchCache
+
 	// Utilities.
 	cs                                 modules.ConsensusSet
 	deps                               modules.Dependencies
@@ -604,6 +621,9 @@ func (r *Renter) SetSettings(s modules.RenterSettings) error {
 	if s.MaxDownloadSpeed < 0 || s.MaxUploadSpeed < 0 {
 		return errors.New("bandwidth limits cannot be negative")
 	}
+	if s.PriceAnomalyFactor != 0 && s.PriceAnomalyFactor <= 1 {
+		return errors.New("price anomaly factor must be greater than 1")
+	}
 
 	// Set allowance.
 	err := r.hostContractor.SetAllowance(s.Allowance)
@@ -624,6 +644,8 @@ func (r *Renter) SetSettings(s modules.RenterSettings) error {
 	id := r.mu.Lock()
 	r.persist.MaxDownloadSpeed = s.MaxDownloadSpeed
 	r.persist.MaxUploadSpeed = s.MaxUploadSpeed
+	r.persist.DisablePriceAnomalyDetection = !s.PriceAnomalyDetection
+	r.persist.PriceAnomalyFactor = s.PriceAnomalyFactor
 	err = r.saveSync()
 	r.mu.Unlock(id)
 	if err != nil {
@@ -817,6 +839,7 @@ func (r *Renter) Settings() (modules.RenterSettings, error) {
 		return modules.RenterSettings{}, errors.AddContext(err, "error getting IPViolationsCheck:")
 	}
 	paused, endTime := r.uploadHeap.managedPauseStatus()
+	anomalyDetection, anomalyFactor := r.managedPriceAnomalySettings()
 	return modules.RenterSettings{
 		Allowance:        r.hostContractor.Allowance(),
 		IPViolationCheck: enabled,
@@ -826,6 +849,8 @@ func (r *Renter) Settings() (modules.RenterSettings, error) {
 			Paused:       paused,
 			PauseEndTime: endTime,
 		},
+		PriceAnomalyDetection: anomalyDetection,
+		PriceAnomalyFactor:    anomalyFactor,
 	}, nil
 }
 
@@ -920,13 +945,14 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 		hostContractor: hc,
 		persistDir:     persistDir,
 		rl:             rl,
-		staticAlerter:  modules.NewAlerter("renter"),
 		staticMux:      mux,
 		mu:             siasync.New(modules.SafeMutexDelay, 1),
 		tpool:          tpool,
 	}
 	r.staticBubbleScheduler = newBubbleScheduler(r)
+	r.staticRedundancyAlerts = newRedundancyAlerts()
 	r.staticStreamBufferSet = newStreamBufferSet(&r.tg)
+	r.staticPCWSPrefetchCache = newPCWSPrefetchCache()
 	r.staticUploadChunkDistributionQueue = newUploadChunkDistributionQueue(r)
 	r.staticRRS = newReadRegistryStats(ReadRegistryBackgroundTimeout, readRegistryStatsInterval, readRegistryStatsDecay, readRegistryStatsPercentile)
 	close(r.uploadHeap.pauseChan)
@@ -959,6 +985,24 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 		return nil, err
 	}
 
+	// Load the alerts that were registered before the last shutdown, and save
+	// them again when shutting down.
+	var alertErr error
+	r.staticAlerter, alertErr = modules.NewPersistedAlerter("renter", r.persistDir)
+	if alertErr != nil {
+		r.log.Println("WARN: discarding persisted alerts:", alertErr)
+	}
+	if err := r.tg.AfterStop(r.staticAlerter.Close); err != nil {
+		return nil, err
+	}
+	// The alerts of the contractor and hostdb roll up into the renter's.
+	if err := r.staticAlerter.AddSubAlerter(r.hostContractor); err != nil {
+		return nil, errors.AddContext(err, "unable to add the contractor alerter")
+	}
+	if err := r.staticAlerter.AddSubAlerter(r.hostDB); err != nil {
+		return nil, errors.AddContext(err, "unable to add the hostdb alerter")
+	}
+
 	// Initialize some of the components.
 	err = r.newAccountManager()
 	if err != nil {
@@ -981,6 +1025,7 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 
 	// After persist is initialized, create the worker pool.
 	r.staticWorkerPool = r.newWorkerPool()
+	r.staticPCWSGougingCallback = r.staticWorkerPool.callRecordPCWSGouging
 
 	// Set the worker pool on the contractor.
 	r.hostContractor.UpdateWorkerPool(r.staticWorkerPool)
@@ -559,6 +559,11 @@ func (r *Renter) threadedUpdateRenterHealth() {
 	}
 	defer r.tg.Done()
 
+	// Low redundancy alerts restored from disk are re-registered while the
+	// files are checked. Once every directory was checked since startup, the
+	// remaining restored alerts belong to files that no longer exist.
+	startTime := time.Now()
+
 	// Loop until the renter has shutdown or until the renter's top level files
 	// directory has a LasHealthCheckTime within the healthCheckInterval
 	for {
@@ -583,6 +588,9 @@ func (r *Renter) threadedUpdateRenterHealth() {
 			}
 			continue
 		}
+		if lastHealthCheckTime.After(startTime) {
+			r.staticAlerter.UnregisterRestoredAlerts()
+		}
 
 		// Check if the time since the last check on the least recently checked
 		// folder is inside the health check interval. If so, the whole
@@ -53,6 +53,10 @@ const (
 )
 
 var (
+	// errFundAccountGouging is returned when the cost of funding an ephemeral
+	// account on the host is considered too high.
+	errFundAccountGouging = errors.New("fund account cost is too high - price gouging protection enabled")
+
 	// accountIdleCheckFrequency establishes how frequently the sync function
 	// should check whether the worker is idle. A relatively high frequency is
 	// okay, because this function only runs while the worker is frozen and
@@ -696,6 +700,9 @@ func (w *worker) managedRefillAccount() {
 
 	// check the current price table for gouging errors
 	err = checkFundAccountGouging(w.staticPriceTable().staticPriceTable, w.staticCache().staticRenterAllowance, w.staticBalanceTarget)
+	if err != nil && w.staticGougingExempt(modules.GougingCheckFundAccount) {
+		err = nil
+	}
 	if err != nil {
 		return
 	}
@@ -789,6 +796,7 @@ func (w *worker) managedRefillAccount() {
 		err = errors.AddContext(err, "could not provide payment for the account")
 		return
 	}
+	w.renter.managedTrackGougingSpending(modules.GougingSpending{AccountFees: pt.FundAccountCost})
 
 	// receive FundAccountResponse. The response contains a receipt and a
 	// signature, which is useful for places where accountability is required,
@@ -870,6 +878,7 @@ func (w *worker) staticHostAccountBalance() (_ types.Currency, err error) {
 		}
 		return types.ZeroCurrency, err
 	}
+	w.renter.managedTrackGougingSpending(modules.GougingSpending{AccountFees: pt.AccountBalanceCost})
 
 	// prepare the request.
 	abr := modules.AccountBalanceRequest{Account: w.staticAccount.staticID}
@@ -889,7 +898,8 @@ func (w *worker) staticHostAccountBalance() (_ types.Currency, err error) {
 
 // checkFundAccountGouging verifies the cost of funding an ephemeral account on
 // the host is reasonable, if deemed unreasonable we will block the refill and
-// the worker will eventually be put into cooldown.
+// the account will be put on a maintenance cooldown. The returned error wraps
+// errFundAccountGouging.
 func checkFundAccountGouging(pt modules.RPCPriceTable, allowance modules.Allowance, targetBalance types.Currency) error {
 	// If there is no allowance, price gouging checks have to be disabled,
 	// because there is no baseline for understanding what might count as price
@@ -915,7 +925,7 @@ func checkFundAccountGouging(pt modules.RPCPriceTable, allowance modules.Allowan
 	// above a certain % of the allowance.
 	totalFundAccountCost := pt.FundAccountCost.Mul64(numRefills)
 	if totalFundAccountCost.Cmp(allowance.Funds.MulFloat(fundAccountGougingPercentageThreshold)) > 0 {
-		return fmt.Errorf("fund account cost %v is considered too high, the total cost of refilling the account to spend the total allowance exceeds %v%% of the allowance - price gouging protection enabled", pt.FundAccountCost, fundAccountGougingPercentageThreshold)
+		return errors.AddContext(errFundAccountGouging, fmt.Sprintf("fund account cost %v is considered too high, the total cost of refilling the account to spend the total allowance exceeds %v%% of the allowance", pt.FundAccountCost, fundAccountGougingPercentageThreshold*100))
 	}
 
 	return nil
@@ -129,6 +129,34 @@ func testAccountCheckFundAccountGouging(t *testing.T) {
 	if err == nil || !strings.Contains(err.Error(), "fund account cost") {
 		t.Fatalf("expected fund account cost gouging error, instead error was '%v'", err)
 	}
+
+	// verify a table of fund account costs that straddle the threshold, for
+	// the given parameters the threshold lies between 5mS and 6mS
+	mS := types.SiacoinPrecision.Div64(1e3)
+	tests := []struct {
+		name      string
+		allowance modules.Allowance
+		cost      types.Currency
+		gouging   bool
+	}{
+		{"Free", allowance, types.ZeroCurrency, false},
+		{"Cheap", allowance, mS, false},
+		{"BelowThreshold", allowance, mS.Mul64(5), false},
+		{"AboveThreshold", allowance, mS.Mul64(6), true},
+		{"Expensive", allowance, mS.Mul64(75), true},
+		{"NoAllowance", modules.Allowance{}, mS.Mul64(75), false},
+	}
+	for _, test := range tests {
+		pt := newDefaultPriceTable()
+		pt.FundAccountCost = test.cost
+		err := checkFundAccountGouging(pt, test.allowance, targetBalance)
+		if test.gouging && !errors.Contains(err, errFundAccountGouging) {
+			t.Fatalf("%v: expected errFundAccountGouging, got '%v'", test.name, err)
+		}
+		if !test.gouging && err != nil {
+			t.Fatalf("%v: unexpected error '%v'", test.name, err)
+		}
+	}
 }
 
 // testAccountConstants makes sure that certain relationships between constants
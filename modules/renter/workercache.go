@@ -26,13 +26,21 @@ type (
 	// must be static because this object is saved and loaded using
 	// atomic.Pointer.
 	workerCache struct {
-		staticBlockHeight     types.BlockHeight
-		staticContractID      types.FileContractID
-		staticContractUtility modules.ContractUtility
-		staticHostVersion     string
-		staticRenterAllowance modules.Allowance
-		staticHostMuxAddress  string
-		staticSynced          bool
+		staticBlockHeight      types.BlockHeight
+		staticContractID       types.FileContractID
+		staticContractUtility  modules.ContractUtility
+		staticGougingExemption *modules.GougingExemption
+		staticHostVersion      string
+		staticRenterAllowance  modules.Allowance
+		staticHostMuxAddress   string
+		staticSynced           bool
+
+		// The host's settings and the time of the scan at which they were
+		// recorded, used as a baseline by the price anomaly detection.
+		staticHostSettings          modules.HostExternalSettings
+		staticHostLastScan          time.Time
+		staticPriceAnomalyDetection bool
+		staticPriceAnomalyFactor    float64
 
 		staticLastUpdate time.Time
 	}
@@ -70,15 +78,31 @@ func (w *worker) managedUpdateCache() {
 		return
 	}
 
+	// Find the most recent successful scan of the host.
+	var lastScan time.Time
+	for i := len(host.ScanHistory) - 1; i >= 0; i-- {
+		if host.ScanHistory[i].Success {
+			lastScan = host.ScanHistory[i].Timestamp
+			break
+		}
+	}
+	anomalyDetection, anomalyFactor := w.renter.managedPriceAnomalySettings()
+
 	// Create the cache object.
 	newCache := &workerCache{
-		staticBlockHeight:     w.renter.cs.Height(),
-		staticContractID:      renterContract.ID,
-		staticContractUtility: renterContract.Utility,
-		staticHostMuxAddress:  host.SiaMuxAddress(),
-		staticHostVersion:     host.Version,
-		staticRenterAllowance: w.renter.hostContractor.Allowance(),
-		staticSynced:          w.renter.cs.Synced(),
+		staticBlockHeight:      w.renter.cs.Height(),
+		staticContractID:       renterContract.ID,
+		staticContractUtility:  renterContract.Utility,
+		staticGougingExemption: w.renter.managedGougingExemption(w.staticHostPubKey),
+		staticHostMuxAddress:   host.SiaMuxAddress(),
+		staticHostVersion:      host.Version,
+		staticRenterAllowance:  w.renter.hostContractor.Allowance(),
+		staticSynced:           w.renter.cs.Synced(),
+
+		staticHostSettings:          host.HostExternalSettings,
+		staticHostLastScan:          lastScan,
+		staticPriceAnomalyDetection: anomalyDetection,
+		staticPriceAnomalyFactor:    anomalyFactor,
 
 		staticLastUpdate: time.Now(),
 	}
@@ -123,7 +123,7 @@ func (w *worker) threadedPerformDownloadChunkJob(udc *unfinishedDownloadChunk) {
 	// Before performing the download, check for price gouging.
 	allowance := w.renter.hostContractor.Allowance()
 	err := checkDownloadGouging(allowance, &w.staticPriceTable().staticPriceTable)
-	if err != nil {
+	if err != nil && !w.staticGougingExempt(modules.GougingCheckDownload) {
 		w.renter.log.Debugln("worker downloader is not being used because price gouging was detected:", err)
 		udc.managedUnregisterWorker(w)
 		return
@@ -125,6 +125,9 @@ func (j *jobDownloadSnapshot) callExecute() {
 	allowance := w.staticCache().staticRenterAllowance
 	pt := w.staticPriceTable().staticPriceTable
 	err = checkDownloadSnapshotGouging(allowance, pt)
+	if err != nil && w.staticGougingExempt(modules.GougingCheckSnapshot) {
+		err = nil
+	}
 	if err != nil {
 		err = errors.AddContext(err, "price gouging check failed for download snapshot job")
 		return
@@ -17,6 +17,11 @@ var (
 	// account refill is not being met. The error may or may not be extended to
 	// provide a reason.
 	ErrJobDiscarded = errors.New("job is being discarded")
+
+	// errJobQueueOnCooldown is returned when a job can't be added to a queue
+	// because the queue is on a cooldown. The cooldown is temporary, adding
+	// the job may succeed later.
+	errJobQueueOnCooldown = errors.New("unable to add job to queue, queue is on cooldown")
 )
 
 type (
@@ -64,6 +64,49 @@ func (w *worker) newJobHasSector(ctx context.Context, responseChan chan *jobHasS
 	}
 }
 
+// hostHasRoot checks whether the host with the given public key stores the
+// sector with the given root. It launches a single HasSector job against the
+// host's worker, which makes it a lightweight alternative to a pcws for
+// verifying a specific host, e.g. during repairs.
+func (r *Renter) hostHasRoot(ctx context.Context, hostPubKey types.SiaPublicKey, root crypto.Hash) (bool, error) {
+	w, err := r.staticWorkerPool.callWorker(hostPubKey)
+	if err != nil {
+		return false, errors.AddContext(err, "unable to get worker")
+	}
+
+	// Check for gouging. The lookup of a single root is priced like the
+	// lookup of a chunk with a single piece.
+	cache := w.staticCache()
+	pt := w.staticPriceTable().staticPriceTable
+	numWorkers := r.staticWorkerPool.callNumWorkers()
+	err = checkPCWSGouging(pt, cache.staticRenterAllowance, numWorkers, modules.NewPassthroughErasureCoder())
+	if err != nil && !w.staticGougingExempt(modules.GougingCheckHasSector) {
+		return false, errors.AddContext(err, "price gouging detected")
+	}
+
+	// Launch the job and wait for the response.
+	responseChan := make(chan *jobHasSectorResponse, 1)
+	jhs := w.newJobHasSector(ctx, responseChan, root)
+	if _, err := w.staticJobHasSectorQueue.callAddWithEstimate(jhs); err != nil {
+		return false, errors.AddContext(err, "unable to add has sector job")
+	}
+	var resp *jobHasSectorResponse
+	select {
+	case resp = <-responseChan:
+	case <-ctx.Done():
+		return false, errors.AddContext(ctx.Err(), "has sector job timed out")
+	case <-r.tg.StopChan():
+		return false, errors.New("renter is shutting down")
+	}
+	if resp.staticErr != nil {
+		return false, errors.AddContext(resp.staticErr, "has sector job failed")
+	}
+	if len(resp.staticAvailables) != 1 {
+		return false, errors.New("received invalid number of responses")
+	}
+	return resp.staticAvailables[0], nil
+}
+
 // callDiscard will discard a job, sending the provided error.
 func (j *jobHasSector) callDiscard(err error) {
 	w := j.staticQueue.staticWorker()
@@ -178,6 +221,9 @@ func (jq *jobHasSectorQueue) callAddWithEstimate(j *jobHasSector) (time.Time, er
 	j.externJobStartTime = now
 	j.externEstimatedJobDuration = estimate
 	if !jq.add(j) {
+		if !jq.killed && jq.onCooldown() {
+			return time.Time{}, errJobQueueOnCooldown
+		}
 		return time.Time{}, errors.New("unable to add job to queue")
 	}
 	return now.Add(estimate), nil
@@ -1,7 +1,11 @@
 package renter
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
 
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
@@ -100,3 +104,52 @@ func TestHasSectorJobExpectedBandwidth(t *testing.T) {
 		t.Fatal("unexpected")
 	}
 }
+
+// TestHostHasRoot verifies that hostHasRoot reports whether a host stores a
+// sector.
+func TestHostHasRoot(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create a worker tester
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := wt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+
+	// add a random sector to the host
+	sectorData := fastrand.Bytes(int(modules.SectorSize))
+	sectorRoot := crypto.MerkleRoot(sectorData)
+	err = wt.host.AddSector(sectorRoot, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// the host has the sector but not a random one
+	hasRoot, err := r.hostHasRoot(ctx, wt.staticHostPubKey, sectorRoot)
+	if err != nil || !hasRoot {
+		t.Fatal("host should have the root", hasRoot, err)
+	}
+	hasRoot, err = r.hostHasRoot(ctx, wt.staticHostPubKey, crypto.Hash{1})
+	if err != nil || hasRoot {
+		t.Fatal("host shouldn't have the root", hasRoot, err)
+	}
+
+	// unknown hosts return an error
+	_, err = r.hostHasRoot(ctx, types.SiaPublicKey{}, sectorRoot)
+	if err == nil {
+		t.Fatal("expected error for unknown host")
+	}
+}
@@ -167,6 +167,9 @@ func (j *jobUploadSnapshot) callExecute() {
 	allowance := w.renter.hostContractor.Allowance()
 	hostSettings := sess.HostSettings()
 	err = checkUploadSnapshotGouging(allowance, hostSettings)
+	if err != nil && w.staticGougingExempt(modules.GougingCheckSnapshot) {
+		err = nil
+	}
 	if err != nil {
 		err = errors.AddContext(err, "snapshot upload blocked because potential price gouging was detected")
 		return
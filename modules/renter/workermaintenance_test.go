@@ -159,3 +159,57 @@ func TestWorkerMaintenanceRefillLowContractFunds(t *testing.T) {
 		t.Fatalf("expected balance %v but got %v", accountBalance, expectedBalance)
 	}
 }
+
+// TestWorkerMaintenanceFundAccountGouging verifies that the worker refuses to
+// refill its account when the host's fund account cost is considered price
+// gouging, and that the refusal puts the worker on a maintenance cooldown.
+func TestWorkerMaintenanceFundAccountGouging(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	deps := &dependencies.DependencyDisableWorker{}
+	wt, err := newWorkerTesterCustomDependency(t.Name(), deps, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := wt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	w := wt.worker
+
+	// fetch a pricetable.
+	w.staticUpdatePriceTable()
+
+	// make funding the account unreasonably expensive.
+	wpt := *w.staticPriceTable()
+	wpt.staticPriceTable.FundAccountCost = w.staticCache().staticRenterAllowance.Funds.Div64(50)
+	w.staticSetPriceTable(&wpt)
+
+	// trigger a refill.
+	w.managedRefillAccount()
+
+	// the account should not have been funded.
+	w.staticAccount.mu.Lock()
+	accountBalance := w.staticAccount.balance
+	recentErr := w.staticAccount.recentErr
+	w.staticAccount.mu.Unlock()
+	if !accountBalance.IsZero() {
+		t.Fatal("account should not have been refilled", accountBalance)
+	}
+	if !errors.Contains(recentErr, errFundAccountGouging) {
+		t.Fatal("expected errFundAccountGouging, got", recentErr)
+	}
+
+	// the worker should be on a maintenance cooldown.
+	if !w.managedOnMaintenanceCooldown() {
+		t.Fatal("expected maintenance cooldown")
+	}
+	if !errors.Contains(w.managedMaintenanceRecentError(), errFundAccountGouging) {
+		t.Fatal("expected errFundAccountGouging, got", w.managedMaintenanceRecentError())
+	}
+}
@@ -3,6 +3,7 @@ package renter
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 
@@ -28,6 +29,50 @@ type workerPool struct {
 	workers map[string]*worker // The string is the host's public key.
 	mu      sync.RWMutex
 	renter  *Renter
+
+	// pcwsGouging tracks the hosts that were rejected by a chunk worker set
+	// because of price gouging. It has its own lock since it is updated from
+	// the download path.
+	pcwsGouging   map[string]*pcwsGougingRecord
+	pcwsGougingMu sync.Mutex
+
+	// staticHasSectorLimiter limits the rate at which the chunk worker sets
+	// launch HasSector jobs across all workers.
+	staticHasSectorLimiter *hasSectorRateLimiter
+
+	// staticHasSectorCostHistogram records the costs of the HasSector jobs
+	// that the chunk worker sets compute for the workers.
+	staticHasSectorCostHistogram *hasSectorCostHistogram
+
+	// staticHasSectorCostCache caches the costs of the HasSector jobs that the
+	// chunk worker sets compute for the workers.
+	staticHasSectorCostCache *hasSectorCostCache
+}
+
+// pcwsGougingRecord keeps track of how often a host was rejected by a chunk
+// worker set because of price gouging, and why it was rejected most recently.
+type pcwsGougingRecord struct {
+	count         uint64
+	recentErr     error
+	recentErrTime time.Time
+}
+
+// callRecordPCWSGouging records that the given host was rejected by a chunk
+// worker set for the given reason.
+func (wp *workerPool) callRecordPCWSGouging(hostKey types.SiaPublicKey, reason error) {
+	wp.pcwsGougingMu.Lock()
+	defer wp.pcwsGougingMu.Unlock()
+	if wp.pcwsGouging == nil {
+		wp.pcwsGouging = make(map[string]*pcwsGougingRecord)
+	}
+	record, exists := wp.pcwsGouging[hostKey.String()]
+	if !exists {
+		record = new(pcwsGougingRecord)
+		wp.pcwsGouging[hostKey.String()] = record
+	}
+	record.count++
+	record.recentErr = reason
+	record.recentErrTime = time.Now()
 }
 
 // callStatus returns the status of the workers in the worker pool.
@@ -43,9 +88,26 @@ func (wp *workerPool) callStatus() modules.WorkerPoolStatus {
 	var statuss []modules.WorkerStatus // Plural of status is statuss, deal with it.
 	workers := wp.callWorkers()
 
+	// Grab the gouging rejections of the chunk worker sets.
+	var totalPCWSGougingRejections uint64
+	wp.pcwsGougingMu.Lock()
+	pcwsGouging := make(map[string]pcwsGougingRecord, len(wp.pcwsGouging))
+	for hostKey, record := range wp.pcwsGouging {
+		pcwsGouging[hostKey] = *record
+		totalPCWSGougingRejections += record.count
+	}
+	wp.pcwsGougingMu.Unlock()
+
 	// Loop all workers and collect their status objects.
 	for _, w := range workers {
 		status := w.callStatus()
+		if record, exists := pcwsGouging[w.staticHostPubKeyStr]; exists {
+			status.PCWSGougingRejections = record.count
+			if record.recentErr != nil {
+				status.PCWSGougingRecentErr = record.recentErr.Error()
+			}
+			status.PCWSGougingRecentErrTime = record.recentErrTime
+		}
 		if status.DownloadOnCoolDown {
 			totalDownloadCoolDown++
 		}
@@ -63,6 +125,13 @@ func (wp *workerPool) callStatus() modules.WorkerPoolStatus {
 		TotalMaintenanceCoolDown: totalMaintenanceCoolDown,
 		TotalUploadCoolDown:      totalUploadCoolDown,
 		Workers:                  statuss,
+
+		TotalPCWSGougingRejections: totalPCWSGougingRejections,
+
+		HasSectorRateLimitUtilization: wp.staticHasSectorLimiter.managedUtilization(),
+		TotalHasSectorRateLimitSkips:  wp.staticHasSectorLimiter.managedSkipped(),
+
+		HasSectorCostHistogram: wp.staticHasSectorCostHistogram.managedBuckets(time.Now()),
 	}
 }
 
@@ -185,6 +254,10 @@ func (r *Renter) newWorkerPool() *workerPool {
 	wp := &workerPool{
 		workers: make(map[string]*worker),
 		renter:  r,
+
+		staticHasSectorLimiter:       newHasSectorRateLimiter(hasSectorRateLimit, hasSectorRateLimitBurst, hasSectorRateLimitMaxWait),
+		staticHasSectorCostHistogram: newHasSectorCostHistogram(),
+		staticHasSectorCostCache:     newHasSectorCostCache(),
 	}
 	wp.renter.tg.OnStop(func() error {
 		wp.mu.RLock()
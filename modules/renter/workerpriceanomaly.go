@@ -0,0 +1,179 @@
+package renter
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// workerpriceanomaly.go contains the logic that compares the prices in a
+// host's price table against the settings the hostdb recorded during the most
+// recent scan of that host. The gouging checks only compare prices against the
+// allowance, which means a host could sharply increase its prices in between
+// two scans while staying below the allowance ceiling. The anomaly detection
+// catches such a host, puts the affected job queues on cooldown and asks the
+// hostdb to rescan the host so its scan history reflects the new prices.
+
+var (
+	// errPriceTableAnomaly is returned when a price in the host's price table
+	// increased by more than the allowed factor compared to the host's most
+	// recent scan.
+	errPriceTableAnomaly = errors.New("price table rejected due to price anomaly")
+
+	// priceAnomalyScanWindow is the maximum age of the host's most recent
+	// successful scan for it to be used as a baseline. If the scan is older
+	// than this, the host had every opportunity to change its prices
+	// legitimately and the comparison is skipped.
+	priceAnomalyScanWindow = build.Select(build.Var{
+		Standard: 8 * time.Hour,
+		Testnet:  8 * time.Hour,
+		Dev:      10 * time.Minute,
+		Testing:  time.Minute,
+	}).(time.Duration)
+)
+
+// priceAnomalyDimension describes a single price that is compared between the
+// host's scanned settings and its price table.
+type priceAnomalyDimension struct {
+	staticName string
+
+	staticScanPrice  func(modules.HostExternalSettings) types.Currency
+	staticTablePrice func(modules.RPCPriceTable) types.Currency
+
+	// These flags indicate which job queues are affected by an anomaly in
+	// this dimension.
+	staticAffectsDownloads bool
+	staticAffectsUploads   bool
+}
+
+// priceAnomalyDimensions contains all of the prices that are checked for
+// anomalies. The mapping between the settings and the price table mirrors the
+// way the host constructs its price table.
+var priceAnomalyDimensions = []priceAnomalyDimension{
+	{
+		staticName:             "baserpc",
+		staticScanPrice:        func(hes modules.HostExternalSettings) types.Currency { return hes.BaseRPCPrice },
+		staticTablePrice:       func(pt modules.RPCPriceTable) types.Currency { return pt.InitBaseCost },
+		staticAffectsDownloads: true,
+		staticAffectsUploads:   true,
+	},
+	{
+		staticName:             "downloadbandwidth",
+		staticScanPrice:        func(hes modules.HostExternalSettings) types.Currency { return hes.DownloadBandwidthPrice },
+		staticTablePrice:       func(pt modules.RPCPriceTable) types.Currency { return pt.DownloadBandwidthCost },
+		staticAffectsDownloads: true,
+	},
+	{
+		staticName:             "sectoraccess",
+		staticScanPrice:        func(hes modules.HostExternalSettings) types.Currency { return hes.SectorAccessPrice },
+		staticTablePrice:       func(pt modules.RPCPriceTable) types.Currency { return pt.ReadBaseCost },
+		staticAffectsDownloads: true,
+	},
+	{
+		staticName:           "storage",
+		staticScanPrice:      func(hes modules.HostExternalSettings) types.Currency { return hes.StoragePrice },
+		staticTablePrice:     func(pt modules.RPCPriceTable) types.Currency { return pt.WriteStoreCost },
+		staticAffectsUploads: true,
+	},
+	{
+		staticName:           "uploadbandwidth",
+		staticScanPrice:      func(hes modules.HostExternalSettings) types.Currency { return hes.UploadBandwidthPrice },
+		staticTablePrice:     func(pt modules.RPCPriceTable) types.Currency { return pt.UploadBandwidthCost },
+		staticAffectsUploads: true,
+	},
+}
+
+// managedPriceAnomalySettings returns whether price anomaly detection is
+// enabled and the factor that is used to detect an anomaly.
+func (r *Renter) managedPriceAnomalySettings() (bool, float64) {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	factor := r.persist.PriceAnomalyFactor
+	if factor == 0 {
+		factor = DefaultPriceAnomalyFactor
+	}
+	return !r.persist.DisablePriceAnomalyDetection, factor
+}
+
+// checkPriceTableAnomaly compares the prices in the given price table against
+// the given host settings, which were recorded during a scan at 'scanTime'. It
+// returns the dimension that increased by more than 'factor' together with the
+// dimension's definition, or nil if no anomaly was found.
+func checkPriceTableAnomaly(pt modules.RPCPriceTable, hes modules.HostExternalSettings, scanTime time.Time, factor float64) (*modules.PriceAnomaly, *priceAnomalyDimension) {
+	// Without a recent scan there is no baseline to compare against.
+	if scanTime.IsZero() || time.Since(scanTime) > priceAnomalyScanWindow {
+		return nil, nil
+	}
+	for i := range priceAnomalyDimensions {
+		dim := &priceAnomalyDimensions[i]
+		scanPrice := dim.staticScanPrice(hes)
+		tablePrice := dim.staticTablePrice(pt)
+		// A zero price can't be used as a baseline, any increase would be
+		// infinitely large.
+		if scanPrice.IsZero() {
+			continue
+		}
+		if tablePrice.Cmp(scanPrice.MulFloat(factor)) <= 0 {
+			continue
+		}
+		return &modules.PriceAnomaly{
+			Dimension:  dim.staticName,
+			Factor:     factor,
+			ScanPrice:  scanPrice,
+			ScanTime:   scanTime,
+			TablePrice: tablePrice,
+			Time:       time.Now(),
+		}, dim
+	}
+	return nil, nil
+}
+
+// staticCheckPriceTableAnomaly checks the given price table for a price
+// anomaly. If one is found, the job queues affected by the anomaly are put on
+// cooldown, the hostdb is asked to rescan the host and an error is returned.
+func (w *worker) staticCheckPriceTableAnomaly(pt modules.RPCPriceTable) (*modules.PriceAnomaly, error) {
+	cache := w.staticCache()
+	if !cache.staticPriceAnomalyDetection {
+		return nil, nil
+	}
+	anomaly, dim := checkPriceTableAnomaly(pt, cache.staticHostSettings, cache.staticHostLastScan, cache.staticPriceAnomalyFactor)
+	if anomaly == nil {
+		return nil, nil
+	}
+	err := errors.AddContext(errPriceTableAnomaly, fmt.Sprintf("host %v increased its %v price from %v to %v, which exceeds %vx the price at its last scan", w.staticHostPubKeyStr, anomaly.Dimension, anomaly.ScanPrice.HumanString(), anomaly.TablePrice.HumanString(), anomaly.Factor))
+
+	// Put the affected queues on cooldown.
+	if dim.staticAffectsDownloads {
+		w.staticJobHasSectorQueue.callReportFailure(err)
+		w.staticJobReadQueue.callReportFailure(err)
+		w.staticJobLowPrioReadQueue.callReportFailure(err)
+		w.staticJobReadRegistryQueue.callReportFailure(err)
+		w.staticJobDownloadSnapshotQueue.callReportFailure(err)
+	}
+	if dim.staticAffectsUploads {
+		w.staticJobUpdateRegistryQueue.callReportFailure(err)
+		w.staticJobUploadSnapshotQueue.callReportFailure(err)
+
+		// Chunk uploads don't use a job queue, they use the session settings
+		// and would keep paying the increased prices. Put them on cooldown
+		// the same way a failed upload would.
+		w.mu.Lock()
+		w.uploadRecentFailure = time.Now()
+		w.uploadRecentFailureErr = err
+		w.uploadConsecutiveFailures++
+		w.mu.Unlock()
+		w.managedDropUploadChunks()
+	}
+
+	// Have the hostdb rescan the host, the scan history should reflect the
+	// host's new prices.
+	rescanErr := w.renter.hostDB.ScheduleRescan(w.staticHostPubKey)
+	if rescanErr != nil {
+		w.renter.log.Printf("WARN: unable to schedule rescan for host %v: %v", w.staticHostPubKeyStr, rescanErr)
+	}
+	return anomaly, err
+}
@@ -0,0 +1,261 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/siatest/dependencies"
+	"go.sia.tech/siad/types"
+)
+
+// TestCheckPriceTableAnomaly is a unit test for checkPriceTableAnomaly.
+func TestCheckPriceTableAnomaly(t *testing.T) {
+	t.Parallel()
+
+	hes := modules.DefaultHostExternalSettings()
+	pt := newDefaultPriceTable()
+	pt.InitBaseCost = hes.BaseRPCPrice
+	pt.ReadBaseCost = hes.SectorAccessPrice
+	pt.WriteStoreCost = hes.StoragePrice
+	scanTime := time.Now()
+
+	// verify the happy case
+	anomaly, _ := checkPriceTableAnomaly(pt, hes, scanTime, DefaultPriceAnomalyFactor)
+	if anomaly != nil {
+		t.Fatal("unexpected anomaly", anomaly)
+	}
+
+	// a price right at the factor is still accepted
+	pt.DownloadBandwidthCost = hes.DownloadBandwidthPrice.MulFloat(DefaultPriceAnomalyFactor)
+	anomaly, _ = checkPriceTableAnomaly(pt, hes, scanTime, DefaultPriceAnomalyFactor)
+	if anomaly != nil {
+		t.Fatal("unexpected anomaly", anomaly)
+	}
+
+	// a price above the factor is an anomaly
+	pt.DownloadBandwidthCost = hes.DownloadBandwidthPrice.MulFloat(DefaultPriceAnomalyFactor + 1)
+	anomaly, dim := checkPriceTableAnomaly(pt, hes, scanTime, DefaultPriceAnomalyFactor)
+	if anomaly == nil {
+		t.Fatal("expected anomaly")
+	}
+	if anomaly.Dimension != "downloadbandwidth" || !dim.staticAffectsDownloads || dim.staticAffectsUploads {
+		t.Fatal("unexpected dimension", anomaly.Dimension)
+	}
+	if !anomaly.ScanPrice.Equals(hes.DownloadBandwidthPrice) || !anomaly.TablePrice.Equals(pt.DownloadBandwidthCost) {
+		t.Fatal("unexpected prices", anomaly.ScanPrice, anomaly.TablePrice)
+	}
+
+	// a larger factor accepts the price
+	anomaly, _ = checkPriceTableAnomaly(pt, hes, scanTime, DefaultPriceAnomalyFactor+1)
+	if anomaly != nil {
+		t.Fatal("unexpected anomaly", anomaly)
+	}
+
+	// a stale or missing scan is not used as a baseline
+	anomaly, _ = checkPriceTableAnomaly(pt, hes, time.Now().Add(-2*priceAnomalyScanWindow), DefaultPriceAnomalyFactor)
+	if anomaly != nil {
+		t.Fatal("unexpected anomaly", anomaly)
+	}
+	anomaly, _ = checkPriceTableAnomaly(pt, hes, time.Time{}, DefaultPriceAnomalyFactor)
+	if anomaly != nil {
+		t.Fatal("unexpected anomaly", anomaly)
+	}
+
+	// a zero scan price is not used as a baseline
+	pt = newDefaultPriceTable()
+	hes.StoragePrice = types.ZeroCurrency
+	pt.WriteStoreCost = modules.DefaultStoragePrice
+	pt.InitBaseCost = hes.BaseRPCPrice
+	pt.ReadBaseCost = hes.SectorAccessPrice
+	anomaly, _ = checkPriceTableAnomaly(pt, hes, scanTime, DefaultPriceAnomalyFactor)
+	if anomaly != nil {
+		t.Fatal("unexpected anomaly", anomaly)
+	}
+}
+
+// TestPriceAnomalySettings verifies the price anomaly detection settings are
+// exposed through the renter settings and validated when set.
+func TestPriceAnomalySettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// check the defaults
+	settings, err := r.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !settings.PriceAnomalyDetection || settings.PriceAnomalyFactor != DefaultPriceAnomalyFactor {
+		t.Fatal("unexpected defaults", settings.PriceAnomalyDetection, settings.PriceAnomalyFactor)
+	}
+
+	// a factor that doesn't allow any increase is rejected
+	settings.PriceAnomalyFactor = 1
+	if err := r.SetSettings(settings); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// update the settings
+	settings.PriceAnomalyDetection = false
+	settings.PriceAnomalyFactor = 5
+	if err := r.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	settings, err = r.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.PriceAnomalyDetection || settings.PriceAnomalyFactor != 5 {
+		t.Fatal("settings not updated", settings.PriceAnomalyDetection, settings.PriceAnomalyFactor)
+	}
+}
+
+// TestWorkerPriceAnomaly verifies the worker rejects the price table of a host
+// that sharply raises its prices in between scans.
+func TestWorkerPriceAnomaly(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	deps := &dependencies.DependencyDisableWorker{}
+	wt, err := newWorkerTesterCustomDependency(t.Name(), deps, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := wt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	w := wt.worker
+
+	// populate the cache, the host should have been scanned successfully
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		w.managedUpdateCache()
+		if w.staticCache().staticHostLastScan.IsZero() {
+			return errors.New("host not scanned yet")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := w.staticCache()
+	if !cache.staticPriceAnomalyDetection {
+		t.Fatal("price anomaly detection should be enabled by default")
+	}
+
+	// fetch a price table, the prices match the scan
+	w.staticUpdatePriceTable()
+	if err := w.staticPriceTable().staticRecentErr; err != nil {
+		t.Fatal(err)
+	}
+
+	// have the host raise its download price mid-session
+	is := wt.host.InternalSettings()
+	is.MinDownloadBandwidthPrice = cache.staticHostSettings.DownloadBandwidthPrice.MulFloat(2 * DefaultPriceAnomalyFactor)
+	err = wt.host.SetInternalSettings(is)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// allow the worker to update its price table again
+	wpt := *w.staticPriceTable()
+	wpt.staticUpdateTime = time.Now().Add(-time.Second)
+	w.staticSetPriceTable(&wpt)
+
+	// the new price table should be rejected
+	w.staticUpdatePriceTable()
+	pt := w.staticPriceTable()
+	if !errors.Contains(pt.staticRecentErr, errPriceTableAnomaly) {
+		t.Fatal("expected price anomaly error, got", pt.staticRecentErr)
+	}
+	anomaly := pt.staticRecentPriceAnomaly
+	if anomaly == nil || anomaly.Dimension != "downloadbandwidth" {
+		t.Fatal("unexpected price anomaly", anomaly)
+	}
+	if w.staticPriceTableStatus().RecentPriceAnomaly != anomaly {
+		t.Fatal("price anomaly not reported in the status")
+	}
+
+	// the download queues should be on cooldown, the upload queues and chunk
+	// uploads shouldn't
+	if !w.staticJobReadQueue.callOnCooldown() || !w.staticJobHasSectorQueue.callOnCooldown() {
+		t.Fatal("download queues should be on cooldown")
+	}
+	if w.staticJobUploadSnapshotQueue.callOnCooldown() {
+		t.Fatal("upload queues should not be on cooldown")
+	}
+	w.mu.Lock()
+	uploadCooldown, _ := w.onUploadCooldown()
+	w.mu.Unlock()
+	if uploadCooldown {
+		t.Fatal("chunk uploads should not be on cooldown")
+	}
+
+	// the hostdb should rescan the host and pick up the new price
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		host, _, err := wt.rt.renter.hostDB.Host(w.staticHostPubKey)
+		if err != nil {
+			return err
+		}
+		if !host.DownloadBandwidthPrice.Equals(is.MinDownloadBandwidthPrice) {
+			return errors.New("host not rescanned yet")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// refresh the cache so the new download price is the baseline
+	w.managedUpdateCache()
+	cache = w.staticCache()
+
+	// have the host raise its upload price as well
+	is.MinUploadBandwidthPrice = cache.staticHostSettings.UploadBandwidthPrice.MulFloat(2 * DefaultPriceAnomalyFactor)
+	err = wt.host.SetInternalSettings(is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wpt = *w.staticPriceTable()
+	wpt.staticUpdateTime = time.Now().Add(-time.Second)
+	w.staticSetPriceTable(&wpt)
+
+	// the price table should be rejected and uploads should stop
+	w.staticUpdatePriceTable()
+	pt = w.staticPriceTable()
+	if !errors.Contains(pt.staticRecentErr, errPriceTableAnomaly) {
+		t.Fatal("expected price anomaly error, got", pt.staticRecentErr)
+	}
+	if pt.staticRecentPriceAnomaly == nil || pt.staticRecentPriceAnomaly.Dimension != "uploadbandwidth" {
+		t.Fatal("unexpected price anomaly", pt.staticRecentPriceAnomaly)
+	}
+	if !w.staticJobUploadSnapshotQueue.callOnCooldown() || !w.staticJobUpdateRegistryQueue.callOnCooldown() {
+		t.Fatal("upload queues should be on cooldown")
+	}
+	w.mu.Lock()
+	uploadCooldown, _ = w.onUploadCooldown()
+	recentErr := w.uploadRecentFailureErr
+	w.mu.Unlock()
+	if !uploadCooldown || !errors.Contains(recentErr, errPriceTableAnomaly) {
+		t.Fatal("chunk uploads should be on cooldown", recentErr)
+	}
+}
@@ -97,6 +97,10 @@ type (
 		// staticRecentErrTime specifies the time at which the most recent
 		// occurred
 		staticRecentErrTime time.Time
+
+		// staticRecentPriceAnomaly describes the most recent price anomaly
+		// that caused the host's price table to be rejected.
+		staticRecentPriceAnomaly *modules.PriceAnomaly
 	}
 )
 
@@ -210,6 +214,7 @@ func (w *worker) staticUpdatePriceTable() {
 	}()
 
 	var err error
+	var anomaly *modules.PriceAnomaly
 
 	// If this is the first time we are fetching a price table update from the
 	// host, we use the time it took for a single round trip as an initial
@@ -250,13 +255,18 @@ func (w *worker) staticUpdatePriceTable() {
 
 		// Because of race conditions, can't modify the existing price
 		// table, need to make a new one.
+		recentAnomaly := currentPT.staticRecentPriceAnomaly
+		if anomaly != nil {
+			recentAnomaly = anomaly
+		}
 		pt := &workerPriceTable{
-			staticPriceTable:       currentPT.staticPriceTable,
-			staticExpiryTime:       currentPT.staticExpiryTime,
-			staticLastForcedUpdate: currentPT.staticLastForcedUpdate,
-			staticUpdateTime:       cd,
-			staticRecentErr:        err,
-			staticRecentErrTime:    time.Now(),
+			staticPriceTable:         currentPT.staticPriceTable,
+			staticExpiryTime:         currentPT.staticExpiryTime,
+			staticLastForcedUpdate:   currentPT.staticLastForcedUpdate,
+			staticUpdateTime:         cd,
+			staticRecentErr:          err,
+			staticRecentErrTime:      time.Now(),
+			staticRecentPriceAnomaly: recentAnomaly,
 		}
 		w.staticSetPriceTable(pt)
 
@@ -311,12 +321,23 @@ func (w *worker) staticUpdatePriceTable() {
 
 	// check for gouging before paying
 	err = checkUpdatePriceTableGouging(pt, w.staticCache().staticRenterAllowance)
+	if err != nil && w.staticGougingExempt(modules.GougingCheckPriceTable) {
+		err = nil
+	}
 	if err != nil {
 		err = errors.Compose(err, errors.AddContext(errPriceTableGouging, fmt.Sprintf("host %v", w.staticHostPubKeyStr)))
 		w.renter.log.Println("ERROR: ", err)
 		return
 	}
 
+	// check whether any of the prices increased sharply since the host was
+	// last scanned
+	anomaly, err = w.staticCheckPriceTableAnomaly(pt)
+	if err != nil {
+		w.renter.log.Println("ERROR: ", err)
+		return
+	}
+
 	// Before we pay for the price table we validate the host's block height,
 	// this is necessary because we use the host's block height when making
 	// payments by ephemeral account.
@@ -344,6 +365,7 @@ func (w *worker) staticUpdatePriceTable() {
 		err = errors.AddContext(err, "unable to provide payment")
 		return
 	}
+	w.renter.managedTrackGougingSpending(modules.GougingSpending{BaseRPC: pt.UpdatePriceTableCost})
 
 	// The price table will not become valid until the host has received and
 	// confirmed our payment. The host will signal this by sending an empty
@@ -367,12 +389,13 @@ func (w *worker) staticUpdatePriceTable() {
 	// has not been an error for debugging purposes, if there has been an error
 	// previously the devs like to be able to see what it was.
 	wpt := &workerPriceTable{
-		staticPriceTable:       pt,
-		staticExpiryTime:       expiryTime,
-		staticUpdateTime:       newUpdateTime,
-		staticLastForcedUpdate: currentPT.staticLastForcedUpdate,
-		staticRecentErr:        currentPT.staticRecentErr,
-		staticRecentErrTime:    currentPT.staticRecentErrTime,
+		staticPriceTable:         pt,
+		staticExpiryTime:         expiryTime,
+		staticUpdateTime:         newUpdateTime,
+		staticLastForcedUpdate:   currentPT.staticLastForcedUpdate,
+		staticRecentErr:          currentPT.staticRecentErr,
+		staticRecentErrTime:      currentPT.staticRecentErrTime,
+		staticRecentPriceAnomaly: currentPT.staticRecentPriceAnomaly,
 	}
 	w.staticSetPriceTable(wpt)
 }
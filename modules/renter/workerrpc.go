@@ -58,10 +58,14 @@ func (w *worker) managedExecuteProgram(p modules.Program, data []byte, fcid type
 
 	// track the withdrawal
 	var refund types.Currency
+	pt := w.staticPriceTable().staticPriceTable
 	w.staticAccount.managedTrackWithdrawal(cost)
 	defer func() {
 		withdrawn := cost.Sub(refund)
 		w.staticAccount.managedCommitWithdrawal(category, withdrawn, refund, err == nil)
+		if err == nil {
+			w.renter.managedTrackGougingSpending(programGougingSpending(pt, category, withdrawn, limit.Downloaded(), limit.Uploaded()))
+		}
 	}()
 
 	// create a new stream
@@ -89,7 +93,6 @@ func (w *worker) managedExecuteProgram(p modules.Program, data []byte, fcid type
 	}
 
 	// send price table uid
-	pt := w.staticPriceTable().staticPriceTable
 	err = modules.RPCWrite(buffer, pt.UID)
 	if err != nil {
 		return
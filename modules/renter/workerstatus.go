@@ -39,12 +39,21 @@ func (w *worker) callStatus() modules.WorkerStatus {
 	// Update the worker cache before returning a status.
 	w.staticTryUpdateCache()
 	cache := w.staticCache()
+	gougingExemption := cache.staticGougingExemption
+	var gougingExemptChecks []modules.GougingCheck
+	if gougingExemption != nil {
+		gougingExemptChecks = gougingExemption.Checks
+	}
 	return modules.WorkerStatus{
 		// Contract Information
 		ContractID:      cache.staticContractID,
 		ContractUtility: cache.staticContractUtility,
 		HostPubKey:      w.staticHostPubKey,
 
+		// Gouging exemption information
+		GougingExempt:       gougingExemption != nil,
+		GougingExemptChecks: gougingExemptChecks,
+
 		// Download information
 		DownloadCoolDownError: downloadCoolDownErr,
 		DownloadCoolDownTime:  downloadCoolDownTime,
@@ -106,6 +115,8 @@ func (w *worker) staticPriceTableStatus() modules.WorkerPriceTableStatus {
 
 		RecentErr:     recentErrStr,
 		RecentErrTime: pt.staticRecentErrTime,
+
+		RecentPriceAnomaly: pt.staticRecentPriceAnomaly,
 	}
 }
 
@@ -195,7 +195,7 @@ func (w *worker) managedPerformUploadChunkJob() {
 	allowance := w.renter.hostContractor.Allowance()
 	hostSettings := e.HostSettings()
 	err = checkUploadGouging(allowance, hostSettings)
-	if err != nil && !w.renter.deps.Disrupt("DisableUploadGouging") {
+	if err != nil && !w.renter.deps.Disrupt("DisableUploadGouging") && !w.staticGougingExempt(modules.GougingCheckUpload) {
 		failureErr := errors.AddContext(err, "worker uploader is not being used because price gouging was detected")
 		w.managedUploadFailed(uc, pieceIndex, failureErr)
 		return
@@ -2,6 +2,8 @@ package modules
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"sync"
 
@@ -19,6 +21,17 @@ type (
 		WriteBPS           int64  `json:"writebps"`
 		PacketSize         uint64 `json:"packetsize"`
 
+		// EnableMetrics enables the /daemon/metrics endpoint which exposes
+		// the daemon's alerts and a few other gauges in the Prometheus text
+		// format.
+		EnableMetrics bool `json:"enablemetrics"`
+
+		// AlertWebhookURLs are the URLs alert events are POSTed to. Only events
+		// of alerts with a severity of at least AlertWebhookSeverity are
+		// sent.
+		AlertWebhookURLs     []string      `json:"alertwebhooks,omitempty"`
+		AlertWebhookSeverity AlertSeverity `json:"alertwebhookseverity,omitempty"`
+
 		// path of config on disk.
 		path string
 		mu   sync.Mutex
@@ -59,6 +72,71 @@ func (cfg *SiadConfig) SetRatelimit(readBPS, writeBPS int64) error {
 	return cfg.save()
 }
 
+// MetricsEnabled returns true if the /daemon/metrics endpoint is enabled.
+func (cfg *SiadConfig) MetricsEnabled() bool {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return cfg.EnableMetrics
+}
+
+// SetMetricsEnabled enables or disables the /daemon/metrics endpoint and
+// persists the setting to disk.
+func (cfg *SiadConfig) SetMetricsEnabled(enabled bool) error {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.EnableMetrics = enabled
+	return cfg.save()
+}
+
+// AlertWebhooks returns the URLs alert events are sent to and the minimum
+// severity of the alerts events are sent for.
+func (cfg *SiadConfig) AlertWebhooks() ([]string, AlertSeverity) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	minSeverity := cfg.AlertWebhookSeverity
+	if minSeverity == SeverityUnknown {
+		minSeverity = SeverityInfo
+	}
+	return append([]string{}, cfg.AlertWebhookURLs...), minSeverity
+}
+
+// SetAlertWebhooks sets the URLs alert events are sent to and the minimum
+// severity of the alerts events are sent for and persists them to disk. The
+// URLs need to be absolute http or https URLs.
+func (cfg *SiadConfig) SetAlertWebhooks(urls []string, minSeverity AlertSeverity) error {
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid webhook url: %v", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook url needs to be an absolute http or https url, got %v", RedactURL(rawURL))
+		}
+	}
+	if minSeverity < SeverityInfo || minSeverity > SeverityCritical {
+		return errors.New("invalid webhook severity")
+	}
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.AlertWebhookURLs = append([]string{}, urls...)
+	cfg.AlertWebhookSeverity = minSeverity
+	return cfg.save()
+}
+
+// RedactURL returns the scheme and host of a URL but hides the credentials,
+// path and query which might contain secrets, e.g. a webhook token.
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "<redacted>"
+	}
+	redacted := u.Scheme + "://" + u.Host
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		redacted += "/<redacted>"
+	}
+	return redacted
+}
+
 // save saves the config to disk.
 func (cfg *SiadConfig) save() error {
 	return persist.SaveJSON(configMetadata, cfg, cfg.path)
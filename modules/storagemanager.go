@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"time"
+
 	"go.sia.tech/siad/crypto"
 )
 
@@ -40,6 +42,62 @@ type (
 		// folder. Progress is always reported in bytes.
 		ProgressNumerator   uint64
 		ProgressDenominator uint64
+
+		// ReadOnly indicates that no new sectors are placed in the storage
+		// folder. The sectors it stores can still be read and deleted.
+		ReadOnly bool `json:"readonly"`
+
+		// Quarantined indicates that the storage folder's disk returned too
+		// many errors. No new sectors are placed in a quarantined storage
+		// folder until its disk recovers, but reads are still attempted.
+		Quarantined bool `json:"quarantined"`
+
+		// ResizeProgress is the progress of an ongoing resize of the storage
+		// folder. It is nil if the storage folder isn't being resized.
+		ResizeProgress *StorageFolderResizeProgress `json:"resizeprogress,omitempty"`
+
+		// ScrubbedSectors is the number of sectors that the background scrub
+		// verified against their sector roots, CorruptSectors is the number
+		// of sectors in the storage folder that failed the verification.
+		ScrubbedSectors uint64 `json:"scrubbedsectors"`
+		CorruptSectors  uint64 `json:"corruptsectors"`
+	}
+
+	// StorageFolderResizeProgress describes the progress of an ongoing resize
+	// of a storage folder. Shrinking a storage folder relocates the sectors
+	// that are stored in the truncated space to other storage folders, the
+	// sector counts are zero for a folder that is grown.
+	StorageFolderResizeProgress struct {
+		SectorsMoved uint64 `json:"sectorsmoved"`
+		SectorsTotal uint64 `json:"sectorstotal"`
+
+		// BytesPerSecond is the average speed of the resize since it started
+		// and EstimatedCompletion the time at which the resize is expected to
+		// complete at that speed. EstimatedCompletion is zero as long as no
+		// progress was made.
+		BytesPerSecond      uint64    `json:"bytespersecond"`
+		EstimatedCompletion time.Time `json:"estimatedcompletion"`
+		Started             time.Time `json:"started"`
+	}
+
+	// StorageScrubSettings configures the background scrub that reads every
+	// sector of the storage folders and verifies it against its sector root
+	// to detect bit rot before a download or storage proof fails.
+	StorageScrubSettings struct {
+		// Enabled turns the scrub on. The scrub is opt-in.
+		Enabled bool
+
+		// Interval is the amount of time between the completion of a scrub
+		// of a storage folder and the start of the next one.
+		Interval time.Duration
+
+		// MarkCorrupt makes reads of sectors that the scrub found to be
+		// corrupt fail right away instead of serving the corrupt data.
+		MarkCorrupt bool
+
+		// MaxBytesPerSecond caps the rate at which the scrub reads sectors. A
+		// zero cap means there is no limit.
+		MaxBytesPerSecond uint64
 	}
 
 	// A StorageManager is responsible for managing storage folders and
@@ -105,6 +163,14 @@ type (
 		// of all at once.
 		MarkSectorsForRemoval(sectorRoots []crypto.Hash) error
 
+		// MoveSectors will move sectors from the source storage folder into
+		// the destination storage folder until the source folder is empty,
+		// maxBytes of sectors were moved or the destination folder is full.
+		// A maxBytes of 0 moves all sectors. No sectors are lost if the
+		// manager shuts down during the move, and an interrupted move is
+		// resumed at startup. The number of moved sectors is returned.
+		MoveSectors(source, dest uint16, maxBytes uint64) (uint64, error)
+
 		// RemoveStorageFolder will remove a storage folder from the manager.
 		// All storage on the folder will be moved to other storage folders,
 		// meaning that no data will be lost. If the manager is unable to save
@@ -129,6 +195,22 @@ type (
 		// that data will be lost.
 		ResizeStorageFolder(index uint16, newSize uint64, force bool) error
 
+		// SetStorageFolderReadOnly will mark a storage folder as read-only or
+		// writable again. No new sectors are placed in read-only storage
+		// folders, but the sectors they store can still be read and deleted.
+		// This allows for retiring a failing disk gracefully.
+		SetStorageFolderReadOnly(index uint16, readOnly bool) error
+
+		// SetScrubSettings will update the settings of the background scrub
+		// that verifies the integrity of the stored sectors.
+		SetScrubSettings(settings StorageScrubSettings) error
+
+		// CancelStorageFolderResize will cancel the ongoing resize of a
+		// storage folder. The storage folder keeps its old size and sectors
+		// that were relocated already stay in their new storage folder. The
+		// resize can be issued again later.
+		CancelStorageFolderResize(index uint16) error
+
 		// StorageFolders will return a list of storage folders tracked by the
 		// manager.
 		StorageFolders() []StorageFolderMetadata
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+const (
+	// alertWebhookFailureThreshold is the number of batches in a row that
+	// can't be delivered to a webhook before an alert is registered.
+	alertWebhookFailureThreshold = 3
+
+	// alertWebhookMaxAttempts is the number of times the delivery of a batch
+	// to a webhook is attempted before the batch is dropped.
+	alertWebhookMaxAttempts = 5
+
+	// alertWebhookModule is the module name of the alerts registered by the
+	// alert webhook notifier.
+	alertWebhookModule = "daemon"
+
+	// alertMSGAlertWebhookFailing is the message of the alert that is
+	// registered if alert events can't be delivered to a webhook.
+	alertMSGAlertWebhookFailing = "Alert events can't be delivered to a webhook"
+)
+
+var (
+	// alertWebhookBatchWindow is the amount of time alert events are
+	// collected for before they are sent to the webhooks in a single request.
+	alertWebhookBatchWindow = build.Select(build.Var{
+		Dev:      5 * time.Second,
+		Standard: 10 * time.Second,
+		Testnet:  10 * time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+
+	// alertWebhookRetryBackoff is the amount of time the notifier waits
+	// before retrying a failed delivery for the first time. The wait is
+	// doubled with every further attempt.
+	alertWebhookRetryBackoff = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: 2 * time.Second,
+		Testnet:  2 * time.Second,
+		Testing:  10 * time.Millisecond,
+	}).(time.Duration)
+
+	// alertWebhookTimeout is the amount of time a single delivery attempt may
+	// take.
+	alertWebhookTimeout = build.Select(build.Var{
+		Dev:      10 * time.Second,
+		Standard: 30 * time.Second,
+		Testnet:  30 * time.Second,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+)
+
+type (
+	// alertNotifier subscribes to the alert events of the daemon's modules
+	// and POSTs them to the webhooks configured in the siad config. Events are
+	// batched for alertWebhookBatchWindow before they are sent.
+	alertNotifier struct {
+		// deadLetters is the number of batches that couldn't be delivered to
+		// a webhook and were dropped. failures tracks the batches in a row
+		// that couldn't be delivered for every webhook.
+		deadLetters uint64
+		failures    map[string]int
+
+		staticAlerter    *modules.GenericAlerter
+		staticClient     *http.Client
+		staticConfig     *modules.SiadConfig
+		staticCtx        context.Context
+		staticCancel     context.CancelFunc
+		staticSubscriber modules.AlertSubscriber
+
+		mu sync.Mutex
+		wg sync.WaitGroup
+	}
+
+	// alertWebhookPayload is the body of the requests sent to the webhooks.
+	alertWebhookPayload struct {
+		Events []alertWebhookEvent `json:"events"`
+	}
+
+	// alertWebhookEvent describes the registration or unregistration of an
+	// alert to a webhook.
+	alertWebhookEvent struct {
+		ID              modules.AlertID       `json:"id"`
+		Module          string                `json:"module"`
+		Severity        modules.AlertSeverity `json:"severity"`
+		Cause           string                `json:"cause"`
+		Msg             string                `json:"msg"`
+		Registered      bool                  `json:"registered"`
+		FirstRegistered time.Time             `json:"firstregistered"`
+		LastRegistered  time.Time             `json:"lastregistered"`
+	}
+)
+
+// newAlertNotifier creates an alert notifier which sends the events of the
+// subscriber to the webhooks configured in the config.
+func newAlertNotifier(cfg *modules.SiadConfig, s modules.AlertSubscriber) *alertNotifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &alertNotifier{
+		failures: make(map[string]int),
+
+		staticAlerter:    modules.NewAlerter(alertWebhookModule),
+		staticClient:     &http.Client{Timeout: alertWebhookTimeout},
+		staticConfig:     cfg,
+		staticCtx:        ctx,
+		staticCancel:     cancel,
+		staticSubscriber: s,
+	}
+	events := make(chan modules.AlertEvent, alertEventsBufferSize)
+	s.RegisterSubscriber(events)
+	n.wg.Add(1)
+	go n.threadedBatchEvents(events)
+	return n
+}
+
+// Close stops the notifier and waits for pending deliveries to be aborted.
+func (n *alertNotifier) Close() {
+	n.staticCancel()
+	n.wg.Wait()
+}
+
+// threadedBatchEvents collects the events sent to the channel and delivers
+// them in batches until the notifier is closed.
+func (n *alertNotifier) threadedBatchEvents(events chan modules.AlertEvent) {
+	defer n.wg.Done()
+	defer n.staticSubscriber.UnregisterSubscriber(events)
+
+	var batch []alertWebhookEvent
+	var flush <-chan time.Time
+	for {
+		select {
+		case <-n.staticCtx.Done():
+			return
+		case event := <-events:
+			urls, minSeverity := n.staticConfig.AlertWebhooks()
+			if len(urls) == 0 || event.Alert.Severity < minSeverity {
+				continue
+			}
+			batch = append(batch, alertWebhookEvent{
+				ID:              event.ID,
+				Module:          event.Alert.Module,
+				Severity:        event.Alert.Severity,
+				Cause:           event.Alert.Cause,
+				Msg:             event.Alert.Msg,
+				Registered:      event.Registered,
+				FirstRegistered: event.Alert.FirstRegistered,
+				LastRegistered:  event.Alert.LastRegistered,
+			})
+			if flush == nil {
+				flush = time.After(alertWebhookBatchWindow)
+			}
+		case <-flush:
+			urls, _ := n.staticConfig.AlertWebhooks()
+			for _, u := range urls {
+				n.wg.Add(1)
+				go n.threadedDeliver(u, batch)
+			}
+			batch, flush = nil, nil
+		}
+	}
+}
+
+// threadedDeliver sends the batch of events to the webhook, retrying with an
+// exponential backoff if the delivery fails.
+func (n *alertNotifier) threadedDeliver(webhook string, batch []alertWebhookEvent) {
+	defer n.wg.Done()
+
+	body, err := json.Marshal(alertWebhookPayload{Events: batch})
+	if err != nil {
+		build.Critical("failed to marshal alert webhook payload", err)
+		return
+	}
+	backoff := alertWebhookRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err = n.managedPost(webhook, body)
+		if err == nil {
+			n.managedHandleDelivery(webhook, nil)
+			return
+		}
+		if attempt == alertWebhookMaxAttempts {
+			break
+		}
+		select {
+		case <-n.staticCtx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	if n.staticCtx.Err() != nil {
+		return
+	}
+	n.managedHandleDelivery(webhook, err)
+}
+
+// managedPost POSTs the body to the webhook. The returned error never contains
+// the webhook's URL since it might contain secrets.
+func (n *alertNotifier) managedPost(webhook string, body []byte) error {
+	req, err := http.NewRequest("POST", webhook, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("unable to create request")
+	}
+	req = req.WithContext(n.staticCtx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Sia-Agent")
+	resp, err := n.staticClient.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// managedHandleDelivery updates the failure counters after a delivery to the
+// webhook and registers or unregisters the failing webhook alert.
+func (n *alertNotifier) managedHandleDelivery(webhook string, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err == nil {
+		delete(n.failures, webhook)
+	} else {
+		n.deadLetters++
+		n.failures[webhook]++
+	}
+	if err != nil && n.failures[webhook] >= alertWebhookFailureThreshold {
+		cause := fmt.Sprintf("%v batches in a row couldn't be delivered to %v, %v batches were dropped in total: %v", n.failures[webhook], modules.RedactURL(webhook), n.deadLetters, err)
+		n.staticAlerter.RegisterAlert(modules.AlertIDAlertWebhookFailing, alertMSGAlertWebhookFailing, cause, modules.SeverityError)
+		return
+	}
+	for _, failures := range n.failures {
+		if failures >= alertWebhookFailureThreshold {
+			return
+		}
+	}
+	n.staticAlerter.UnregisterAlert(modules.AlertIDAlertWebhookFailing)
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+)
+
+// TestAlertNotifier verifies that the alert notifier batches alert events,
+// sends them to the configured webhooks, retries failed deliveries and
+// registers an alert if deliveries keep failing.
+func TestAlertNotifier(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dir := build.TempDir("api", t.Name())
+	if err := os.MkdirAll(dir, persist.DefaultDiskPermissionsTest); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := modules.NewConfig(filepath.Join(dir, modules.ConfigName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create a webhook which fails a configurable number of requests
+	var mu sync.Mutex
+	var payloads []alertWebhookPayload
+	var requests, failRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if req.Method != "POST" || req.Header.Get("Content-Type") != "application/json" {
+			t.Error("unexpected request", req.Method, req.Header)
+		}
+		if failRequests > 0 {
+			failRequests--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var payload alertWebhookPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+	webhook := server.URL + "/hooks/secret-token"
+
+	alerter := modules.NewAlerter("test")
+	n := newAlertNotifier(cfg, alerter)
+	defer n.Close()
+
+	// without webhooks nothing is sent
+	alerter.RegisterAlert("info", "msg", "cause", modules.SeverityInfo)
+	time.Sleep(2 * alertWebhookBatchWindow)
+	mu.Lock()
+	if requests != 0 {
+		t.Fatal("no requests should have been sent", requests)
+	}
+	mu.Unlock()
+
+	// events registered within the batch window are sent in a single request,
+	// events below the minimum severity are skipped
+	if err := cfg.SetAlertWebhooks([]string{webhook}, modules.SeverityWarning); err != nil {
+		t.Fatal(err)
+	}
+	alerter.RegisterAlert("info2", "msg", "cause", modules.SeverityInfo)
+	alerter.RegisterAlert("warn", "warnmsg", "warncause", modules.SeverityWarning)
+	alerter.RegisterAlert("crit", "critmsg", "critcause", modules.SeverityCritical)
+	alerter.UnregisterAlert("warn")
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(payloads) != 1 {
+			return errors.New("payload wasn't delivered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	events := payloads[0].Events
+	mu.Unlock()
+	if len(events) != 3 {
+		t.Fatal("unexpected number of events", events)
+	}
+	warn, crit, unregistered := events[0], events[1], events[2]
+	if warn.ID != "warn" || warn.Module != "test" || warn.Severity != modules.SeverityWarning || warn.Cause != "warncause" || warn.Msg != "warnmsg" || !warn.Registered || warn.FirstRegistered.IsZero() || warn.LastRegistered.IsZero() {
+		t.Fatal("unexpected event", warn)
+	}
+	if crit.ID != "crit" || crit.Severity != modules.SeverityCritical || !crit.Registered {
+		t.Fatal("unexpected event", crit)
+	}
+	if unregistered.ID != "warn" || unregistered.Registered {
+		t.Fatal("unexpected event", unregistered)
+	}
+
+	// failed deliveries are retried
+	mu.Lock()
+	failRequests = alertWebhookMaxAttempts - 1
+	requests = 0
+	mu.Unlock()
+	alerter.RegisterAlert("err", "msg", "cause", modules.SeverityError)
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(payloads) != 2 {
+			return errors.New("payload wasn't delivered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if requests != alertWebhookMaxAttempts {
+		t.Fatal("unexpected number of requests", requests)
+	}
+	mu.Unlock()
+
+	// batches which can't be delivered are dropped and an alert is registered
+	// once deliveries keep failing
+	mu.Lock()
+	failRequests = alertWebhookFailureThreshold * alertWebhookMaxAttempts
+	mu.Unlock()
+	for i := 1; i <= alertWebhookFailureThreshold; i++ {
+		alerter.RegisterAlert(modules.AlertID(fmt.Sprint("dropped", i)), "msg", "cause", modules.SeverityError)
+		err = build.Retry(100, 50*time.Millisecond, func() error {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if n.deadLetters != uint64(i) {
+				return fmt.Errorf("expected %v dead letters, got %v", i, n.deadLetters)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var alert modules.Alert
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		_, errAlerts, _, _ := n.staticAlerter.Alerts()
+		if len(errAlerts) != 1 {
+			return errors.New("alert wasn't registered")
+		}
+		alert = errAlerts[0]
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert.Module != alertWebhookModule || strings.Contains(alert.Cause, "secret-token") {
+		t.Fatal("unexpected alert", alert)
+	}
+	if !strings.Contains(alert.Cause, modules.RedactURL(webhook)) {
+		t.Fatal("alert should contain the redacted url", alert.Cause)
+	}
+
+	// a successful delivery unregisters the alert
+	alerter.RegisterAlert("last", "msg", "cause", modules.SeverityError)
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		if _, errAlerts, _, _ := n.staticAlerter.Alerts(); len(errAlerts) != 0 {
+			return errors.New("alert wasn't unregistered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
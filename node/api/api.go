@@ -129,6 +129,15 @@ type (
 		Shutdown          func() error
 		siadConfig        *modules.SiadConfig
 
+		// metricsModules are the modules which registered alerts since the
+		// metrics were first requested.
+		metricsModules map[string]struct{}
+		metricsMu      sync.Mutex
+
+		// alertNotifier sends the alert events of the modules to the
+		// webhooks configured in the siad config.
+		alertNotifier *alertNotifier
+
 		staticStartTime time.Time
 
 		staticDeps modules.Dependencies
@@ -183,6 +192,30 @@ func (api *API) SetModules(acc modules.Accounting, cs modules.ConsensusSet, e mo
 	}
 	api.modulesSet = true
 	api.buildHTTPRoutes()
+	api.startAlertNotifier()
+}
+
+// startAlertNotifier starts sending the alert events of the loaded modules to
+// the webhooks configured in the siad config, replacing a previously started
+// notifier.
+func (api *API) startAlertNotifier() {
+	if api.alertNotifier != nil {
+		api.alertNotifier.Close()
+		api.alertNotifier = nil
+	}
+	if api.siadConfig == nil {
+		return
+	}
+	api.alertNotifier = newAlertNotifier(api.siadConfig, api.alertAggregator())
+}
+
+// Close stops sending alert events to webhooks. It doesn't close the modules
+// of the API.
+func (api *API) Close() error {
+	if api.alertNotifier != nil {
+		api.alertNotifier.Close()
+	}
+	return nil
 }
 
 // StartTime returns the time at which the API started
@@ -224,6 +257,7 @@ func NewCustom(cfg *modules.SiadConfig, requiredUserAgent string, requiredPasswo
 
 	// Register API handlers
 	api.buildHTTPRoutes()
+	api.startAlertNotifier()
 
 	return api
 }
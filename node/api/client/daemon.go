@@ -3,7 +3,9 @@ package client
 import (
 	"net/url"
 	"strconv"
+	"time"
 
+	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/node/api"
 )
 
@@ -24,6 +26,36 @@ func (c *Client) DaemonAlertsGet() (dag api.DaemonAlertsGet, err error) {
 	return
 }
 
+// DaemonAlertsGetBySeverity requests the /daemon/alerts resource, only
+// returning the alerts with a severity of at least 'min'.
+func (c *Client) DaemonAlertsGetBySeverity(min modules.AlertSeverity) (dag api.DaemonAlertsGet, err error) {
+	values := url.Values{}
+	values.Set("severity", min.String())
+	err = c.get("/daemon/alerts?"+values.Encode(), &dag)
+	return
+}
+
+// DaemonAlertsDismissPost uses the /daemon/alerts/dismiss endpoint to dismiss
+// the alert with the given id of the given module.
+func (c *Client) DaemonAlertsDismissPost(module string, id modules.AlertID) (err error) {
+	values := url.Values{}
+	values.Set("module", module)
+	values.Set("id", string(id))
+	err = c.post("/daemon/alerts/dismiss", values.Encode(), nil)
+	return
+}
+
+// DaemonAlertsSnoozePost uses the /daemon/alerts/snooze endpoint to snooze the
+// alert with the given id of the given module for the given duration.
+func (c *Client) DaemonAlertsSnoozePost(module string, id modules.AlertID, d time.Duration) (err error) {
+	values := url.Values{}
+	values.Set("module", module)
+	values.Set("id", string(id))
+	values.Set("duration", strconv.FormatUint(uint64(d/time.Second), 10))
+	err = c.post("/daemon/alerts/snooze", values.Encode(), nil)
+	return
+}
+
 // DaemonVersionGet requests the /daemon/version resource.
 func (c *Client) DaemonVersionGet() (dvg api.DaemonVersionGet, err error) {
 	err = c.get("/daemon/version", &dvg)
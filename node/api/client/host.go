@@ -68,6 +68,17 @@ const (
 	// HostParamCustomRegistryPath is the locataion of the host's registry on
 	// disk.
 	HostParamCustomRegistryPath = HostParam("customregistrypath")
+	// HostParamScrubEnabled indicates if the host verifies its stored sectors
+	// in the background.
+	HostParamScrubEnabled = HostParam("scrubenabled")
+	// HostParamScrubInterval is the time between two scrubs of a storage
+	// folder in seconds.
+	HostParamScrubInterval = HostParam("scrubinterval")
+	// HostParamScrubMarkCorrupt indicates if reads of corrupt sectors fail.
+	HostParamScrubMarkCorrupt = HostParam("scrubmarkcorrupt")
+	// HostParamScrubMaxBytesPerSecond is the maximum rate at which the scrub
+	// reads sectors.
+	HostParamScrubMaxBytesPerSecond = HostParam("scrubmaxbytespersecond")
 )
 
 // HostAnnouncePost uses the /host/announce endpoint to announce the host to
@@ -153,6 +164,38 @@ func (c *Client) HostStorageFoldersResizePost(path string, size uint64) (err err
 	return
 }
 
+// HostStorageFoldersResizeCancelPost uses the
+// /host/storage/folders/resize/cancel api endpoint to cancel the ongoing resize
+// of a storage folder.
+func (c *Client) HostStorageFoldersResizeCancelPost(path string) (err error) {
+	values := url.Values{}
+	values.Set("path", path)
+	err = c.post("/host/storage/folders/resize/cancel", values.Encode(), nil)
+	return
+}
+
+// HostStorageFoldersReadOnlyPost uses the /host/storage/folders/readonly api
+// endpoint to mark a storage folder as read-only or as writable again.
+func (c *Client) HostStorageFoldersReadOnlyPost(path string, readOnly bool) (err error) {
+	values := url.Values{}
+	values.Set("path", path)
+	values.Set("readonly", strconv.FormatBool(readOnly))
+	err = c.post("/host/storage/folders/readonly", values.Encode(), nil)
+	return
+}
+
+// HostStorageFoldersMovePost uses the /host/storage/folders/move api endpoint
+// to move up to maxBytes of sectors from one storage folder into another. A
+// maxBytes of 0 moves all sectors.
+func (c *Client) HostStorageFoldersMovePost(source, destination string, maxBytes uint64) (mp api.StorageFoldersMovePOST, err error) {
+	values := url.Values{}
+	values.Set("source", source)
+	values.Set("destination", destination)
+	values.Set("maxbytes", strconv.FormatUint(maxBytes, 10))
+	err = c.post("/host/storage/folders/move", values.Encode(), &mp)
+	return
+}
+
 // HostStorageGet requests the /host/storage endpoint.
 func (c *Client) HostStorageGet() (sg api.StorageGET, err error) {
 	err = c.get("/host/storage", &sg)
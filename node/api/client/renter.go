@@ -525,6 +525,16 @@ func (c *Client) RenterSetCheckIPViolationPost(enabled bool) (err error) {
 	return
 }
 
+// RenterSetPriceAnomalyDetectionPost uses the /renter endpoint to enable or
+// disable the price anomaly detection and to set the factor it uses.
+func (c *Client) RenterSetPriceAnomalyDetectionPost(enabled bool, factor float64) (err error) {
+	values := url.Values{}
+	values.Set("priceanomalydetection", fmt.Sprint(enabled))
+	values.Set("priceanomalyfactor", fmt.Sprint(factor))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
 // RenterStreamGet uses the /renter/stream endpoint to download data as a
 // stream.
 func (c *Client) RenterStreamGet(siaPath modules.SiaPath, disableLocalFetch, root bool) (resp []byte, err error) {
@@ -733,6 +743,39 @@ func (c *Client) RenterFuseUnmount(mount string) (err error) {
 	return
 }
 
+// RenterGougingExemptionsGet uses the /renter/gougingexemptions endpoint to
+// request the renter's gouging exemptions.
+func (c *Client) RenterGougingExemptionsGet() (rgeg api.RenterGougingExemptionsGET, err error) {
+	err = c.get("/renter/gougingexemptions", &rgeg)
+	return
+}
+
+// RenterGougingExemptionAddPost uses the /renter/gougingexemptions endpoint to
+// exempt a host from the given gouging checks. If no checks are provided, the
+// host is exempt from all checks.
+func (c *Client) RenterGougingExemptionAddPost(hostKey types.SiaPublicKey, checks []modules.GougingCheck) (err error) {
+	checkStrs := make([]string, 0, len(checks))
+	for _, check := range checks {
+		checkStrs = append(checkStrs, string(check))
+	}
+	values := url.Values{}
+	values.Set("action", "add")
+	values.Set("hostkey", hostKey.String())
+	values.Set("checks", strings.Join(checkStrs, ","))
+	err = c.post("/renter/gougingexemptions", values.Encode(), nil)
+	return
+}
+
+// RenterGougingExemptionRemovePost uses the /renter/gougingexemptions endpoint
+// to remove the gouging exemption of a host.
+func (c *Client) RenterGougingExemptionRemovePost(hostKey types.SiaPublicKey) (err error) {
+	values := url.Values{}
+	values.Set("action", "remove")
+	values.Set("hostkey", hostKey.String())
+	err = c.post("/renter/gougingexemptions", values.Encode(), nil)
+	return
+}
+
 // RenterUploadsPausePost uses the /renter/uploads/pause endpoint to pause the
 // renter's uploads and repairs
 func (c *Client) RenterUploadsPausePost(duration time.Duration) (err error) {
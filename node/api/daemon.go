@@ -13,8 +13,12 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/inconshreveable/go-update"
 
 	"github.com/julienschmidt/httprouter"
@@ -61,6 +65,20 @@ dU1u9C6NxDnhx0CDVlcKmRzfmW5RtcgLiYXJoR4AgFbhSH2D+13dwrJrzR85ZeZK
 y6/Gelaei3D0
 =XTvn
 -----END PGP PUBLIC KEY BLOCK-----`
+
+	// alertEventsBufferSize is the number of alert events that are buffered
+	// for a websocket client before further events are dropped.
+	alertEventsBufferSize = 64
+
+	// alertEventsWriteTimeout is the amount of time an alert event may take
+	// to be written to a websocket client.
+	alertEventsWriteTimeout = 10 * time.Second
+)
+
+var (
+	// alertEventsUpgrader upgrades connections to the alert events route to
+	// websocket connections.
+	alertEventsUpgrader = websocket.Upgrader{}
 )
 
 type (
@@ -72,6 +90,31 @@ type (
 		ErrorAlerts    []modules.Alert `json:"erroralerts"`
 		WarningAlerts  []modules.Alert `json:"warningalerts"`
 		InfoAlerts     []modules.Alert `json:"infoalerts"`
+
+		NumCriticalAlerts int `json:"numcriticalalerts"`
+		NumErrorAlerts    int `json:"numerroralerts"`
+		NumWarningAlerts  int `json:"numwarningalerts"`
+		NumInfoAlerts     int `json:"numinfoalerts"`
+
+		// Groups contains the same alerts as Alerts, but alerts that share a
+		// root cause or an identical cause are grouped together.
+		Groups []DaemonAlertGroup `json:"groups"`
+	}
+
+	// DaemonAlertGroup is a group of alerts, possibly from different modules,
+	// that share a root cause or an identical cause.
+	DaemonAlertGroup struct {
+		// Cause is the root cause or the cause shared by the alerts of the
+		// group.
+		Cause string `json:"cause"`
+		// Modules are the modules the alerts of the group originated from.
+		Modules []string `json:"modules"`
+		// Severity is the highest severity and LastRegistered the most recent
+		// registration among the alerts of the group.
+		Severity       modules.AlertSeverity `json:"severity"`
+		LastRegistered time.Time             `json:"lastregistered"`
+
+		Alerts []modules.Alert `json:"alerts"`
 	}
 
 	// DaemonVersionGet contains information about the running daemon's version.
@@ -134,9 +177,12 @@ type (
 
 	// DaemonSettingsGet contains information about global daemon settings.
 	DaemonSettingsGet struct {
-		MaxDownloadSpeed int64         `json:"maxdownloadspeed"`
-		MaxUploadSpeed   int64         `json:"maxuploadspeed"`
-		Modules          configModules `json:"modules"`
+		AlertWebhooks        []string              `json:"alertwebhooks"`
+		AlertWebhookSeverity modules.AlertSeverity `json:"alertwebhookseverity"`
+		MaxDownloadSpeed     int64                 `json:"maxdownloadspeed"`
+		MaxUploadSpeed       int64                 `json:"maxuploadspeed"`
+		MetricsEnabled       bool                  `json:"metricsenabled"`
+		Modules              configModules         `json:"modules"`
 	}
 
 	// DaemonVersion holds the version information for siad
@@ -302,63 +348,256 @@ func updateToRelease(version string) (err error) {
 
 // daemonAlertsHandlerGET handles the API call that returns the alerts of all
 // loaded modules.
-func (api *API) daemonAlertsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
-	// initialize slices to avoid "null" in response.
-	crit := make([]modules.Alert, 0, 6)
-	err := make([]modules.Alert, 0, 6)
-	warn := make([]modules.Alert, 0, 6)
-	info := make([]modules.Alert, 0, 6)
+func (api *API) daemonAlertsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the minimum severity, by default all alerts are returned.
+	minSeverity := modules.AlertSeverity(modules.SeverityInfo)
+	if severityStr := req.FormValue("severity"); severityStr != "" {
+		var err error
+		minSeverity, err = modules.ParseAlertSeverity(severityStr)
+		if err != nil {
+			WriteError(w, Error{Message: "unable to parse severity: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	WriteJSON(w, aggregateAlerts(minSeverity, api.alertAggregator()...))
+}
+
+// daemonAlertsDismissHandlerPOST handles the API call that dismisses an alert.
+func (api *API) daemonAlertsDismissHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	module, id, ok := parseAlertAddress(w, req)
+	if !ok {
+		return
+	}
+	err := api.alertAggregator().DismissModuleAlert(module, id)
+	if err != nil {
+		writeAlertError(w, err, "unable to dismiss alert")
+		return
+	}
+	WriteSuccess(w)
+}
+
+// daemonAlertsSnoozeHandlerPOST handles the API call that snoozes an alert for
+// a number of seconds.
+func (api *API) daemonAlertsSnoozeHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	module, id, ok := parseAlertAddress(w, req)
+	if !ok {
+		return
+	}
+	seconds, err := strconv.ParseUint(req.FormValue("duration"), 10, 64)
+	if err != nil {
+		WriteError(w, Error{Message: "unable to parse duration: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.alertAggregator().SnoozeModuleAlert(module, id, time.Duration(seconds)*time.Second)
+	if err != nil {
+		writeAlertError(w, err, "unable to snooze alert")
+		return
+	}
+	WriteSuccess(w)
+}
+
+// parseAlertAddress parses the module and id of the alert a request refers
+// to. If either is missing, an error is written to the client.
+func parseAlertAddress(w http.ResponseWriter, req *http.Request) (string, modules.AlertID, bool) {
+	module := req.FormValue("module")
+	if module == "" {
+		WriteError(w, Error{Message: "module must be specified"}, http.StatusBadRequest)
+		return "", "", false
+	}
+	id := req.FormValue("id")
+	if id == "" {
+		WriteError(w, Error{Message: "id must be specified"}, http.StatusBadRequest)
+		return "", "", false
+	}
+	return module, modules.AlertID(id), true
+}
+
+// writeAlertError writes an error that occurred while dismissing or snoozing
+// an alert to the client.
+func writeAlertError(w http.ResponseWriter, err error, msg string) {
+	code := http.StatusInternalServerError
+	if errors.Contains(err, modules.ErrAlertNotFound) {
+		code = http.StatusNotFound
+	} else if errors.Contains(err, modules.ErrInvalidSnoozeDuration) {
+		code = http.StatusBadRequest
+	}
+	WriteError(w, Error{Message: fmt.Sprintf("%v: %v", msg, err)}, code)
+}
+
+// daemonAlertsWSHandler upgrades the connection to a websocket and pushes the
+// alert events of all loaded modules to the client until it disconnects.
+func (api *API) daemonAlertsWSHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the minimum severity, by default all events are sent.
+	minSeverity := modules.AlertSeverity(modules.SeverityInfo)
+	if severityStr := req.FormValue("severity"); severityStr != "" {
+		var err error
+		minSeverity, err = modules.ParseAlertSeverity(severityStr)
+		if err != nil {
+			WriteError(w, Error{Message: "unable to parse severity: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	conn, err := alertEventsUpgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// The upgrader replied to the client already.
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	serveAlertEvents(conn, api.alertAggregator(), minSeverity)
+}
+
+// alertAggregator returns an aggregator for the alerters of the loaded
+// modules.
+func (api *API) alertAggregator() modules.AlertAggregator {
+	var alerters modules.AlertAggregator
 	if api.gateway != nil {
-		c, e, w, i := api.gateway.Alerts()
-		crit = append(crit, c...)
-		err = append(err, e...)
-		warn = append(warn, w...)
-		info = append(info, i...)
+		alerters = append(alerters, api.gateway)
 	}
 	if api.cs != nil {
-		c, e, w, i := api.cs.Alerts()
-		crit = append(crit, c...)
-		err = append(err, e...)
-		warn = append(warn, w...)
-		info = append(info, i...)
+		alerters = append(alerters, api.cs)
 	}
 	if api.tpool != nil {
-		c, e, w, i := api.tpool.Alerts()
-		crit = append(crit, c...)
-		err = append(err, e...)
-		warn = append(warn, w...)
-		info = append(info, i...)
+		alerters = append(alerters, api.tpool)
 	}
 	if api.wallet != nil {
-		c, e, w, i := api.wallet.Alerts()
-		crit = append(crit, c...)
-		err = append(err, e...)
-		warn = append(warn, w...)
-		info = append(info, i...)
+		alerters = append(alerters, api.wallet)
 	}
 	if api.renter != nil {
-		c, e, w, i := api.renter.Alerts()
-		crit = append(crit, c...)
-		err = append(err, e...)
-		warn = append(warn, w...)
-		info = append(info, i...)
+		alerters = append(alerters, api.renter)
 	}
 	if api.host != nil {
-		c, e, w, i := api.host.Alerts()
-		crit = append(crit, c...)
-		err = append(err, e...)
-		warn = append(warn, w...)
-		info = append(info, i...)
-	}
-	// Sort alerts by severity. Critical first, then Error and finally Warning.
-	alerts := append(append(crit, append(err, warn...)...), info...)
-	WriteJSON(w, DaemonAlertsGet{
+		alerters = append(alerters, api.host)
+	}
+	if api.alertNotifier != nil {
+		alerters = append(alerters, api.alertNotifier.staticAlerter)
+	}
+	return alerters
+}
+
+// serveAlertEvents writes the alert events of the given subscriber with a
+// severity of at least 'minSeverity' to the websocket connection until the
+// connection is closed.
+func serveAlertEvents(conn *websocket.Conn, s modules.AlertSubscriber, minSeverity modules.AlertSeverity) {
+	events := make(chan modules.AlertEvent, alertEventsBufferSize)
+	s.RegisterSubscriber(events)
+	defer s.UnregisterSubscriber(events)
+
+	// Read from the connection to notice when the client goes away. Clients
+	// aren't expected to send any messages.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event := <-events:
+			if event.Alert.Severity < minSeverity {
+				continue
+			}
+			err := conn.SetWriteDeadline(time.Now().Add(alertEventsWriteTimeout))
+			if err != nil {
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// aggregateAlerts aggregates the alerts of the given alerters with a severity
+// of at least 'minSeverity' into a DaemonAlertsGet response.
+func aggregateAlerts(minSeverity modules.AlertSeverity, alerters ...modules.Alerter) DaemonAlertsGet {
+	c, e, w, i := modules.AlertsBySeverity(minSeverity, alerters...)
+
+	// initialize slices to avoid "null" in response.
+	crit := append(make([]modules.Alert, 0, len(c)), c...)
+	err := append(make([]modules.Alert, 0, len(e)), e...)
+	warn := append(make([]modules.Alert, 0, len(w)), w...)
+	info := append(make([]modules.Alert, 0, len(i)), i...)
+
+	// Sort alerts by severity. Critical first, then Error and Warning, info
+	// alerts are not actionable and come last.
+	alerts := make([]modules.Alert, 0, len(crit)+len(err)+len(warn)+len(info))
+	alerts = append(append(append(append(alerts, crit...), err...), warn...), info...)
+	return DaemonAlertsGet{
+		Groups:         groupAlerts(alerts),
 		Alerts:         alerts,
 		CriticalAlerts: crit,
 		ErrorAlerts:    err,
 		WarningAlerts:  warn,
 		InfoAlerts:     info,
+
+		NumCriticalAlerts: len(crit),
+		NumErrorAlerts:    len(err),
+		NumWarningAlerts:  len(warn),
+		NumInfoAlerts:     len(info),
+	}
+}
+
+// groupAlerts groups the alerts that share a root cause or, if they don't have
+// a root cause, an identical cause. Alerts without a cause aren't grouped. The
+// groups are sorted by their highest severity, then by their most recent
+// registration.
+func groupAlerts(alerts []modules.Alert) []DaemonAlertGroup {
+	groups := make([]DaemonAlertGroup, 0, len(alerts))
+	groupIndices := make(map[string]int)
+	for _, alert := range alerts {
+		cause := alert.RootCause
+		if cause == "" {
+			cause = alert.Cause
+		}
+		i, exists := groupIndices[cause]
+		if !exists || cause == "" {
+			i = len(groups)
+			groups = append(groups, DaemonAlertGroup{Cause: cause})
+			if cause != "" {
+				groupIndices[cause] = i
+			}
+		}
+		group := &groups[i]
+		group.Alerts = append(group.Alerts, alert)
+		if alert.Severity > group.Severity {
+			group.Severity = alert.Severity
+		}
+		if alert.LastRegistered.After(group.LastRegistered) {
+			group.LastRegistered = alert.LastRegistered
+		}
+		if !containsString(group.Modules, alert.Module) {
+			group.Modules = append(group.Modules, alert.Module)
+		}
+	}
+	for _, group := range groups {
+		sort.Strings(group.Modules)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Severity != groups[j].Severity {
+			return groups[i].Severity > groups[j].Severity
+		}
+		return groups[i].LastRegistered.After(groups[j].LastRegistered)
 	})
+	return groups
+}
+
+// containsString returns true if the slice contains the string.
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
 }
 
 // daemonUpdateHandlerGET handles the API call that checks for an update.
@@ -510,10 +749,23 @@ func (api *API) daemonStopHandler(w http.ResponseWriter, _ *http.Request, _ http
 // settings.
 func (api *API) daemonSettingsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	gmds, gmus, _ := modules.GlobalRateLimits.Limits()
+	// The webhook URLs are redacted since they might contain secrets.
+	webhooks := []string{}
+	webhookSeverity := modules.AlertSeverity(modules.SeverityInfo)
+	if api.siadConfig != nil {
+		var urls []string
+		urls, webhookSeverity = api.siadConfig.AlertWebhooks()
+		for _, u := range urls {
+			webhooks = append(webhooks, modules.RedactURL(u))
+		}
+	}
 	WriteJSON(w, DaemonSettingsGet{
-		MaxDownloadSpeed: gmds,
-		MaxUploadSpeed:   gmus,
-		Modules:          api.staticConfigModules,
+		AlertWebhooks:        webhooks,
+		AlertWebhookSeverity: webhookSeverity,
+		MaxDownloadSpeed:     gmds,
+		MaxUploadSpeed:       gmus,
+		MetricsEnabled:       api.siadConfig != nil && api.siadConfig.MetricsEnabled(),
+		Modules:              api.staticConfigModules,
 	})
 }
 
@@ -539,6 +791,46 @@ func (api *API) daemonSettingsHandlerPOST(w http.ResponseWriter, req *http.Reque
 		}
 		maxUploadSpeed = uploadSpeed
 	}
+	// Scan whether the metrics endpoint should be enabled. (optional parameter)
+	if m := req.FormValue("enablemetrics"); m != "" {
+		enableMetrics, err := strconv.ParseBool(m)
+		if err != nil {
+			WriteError(w, Error{"unable to parse enablemetrics: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err := api.siadConfig.SetMetricsEnabled(enableMetrics); err != nil {
+			WriteError(w, Error{"unable to set enablemetrics: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	// Scan the alert webhooks and their minimum severity. (optional
+	// parameters) Passing an empty alertwebhooks parameter removes all
+	// webhooks.
+	_, setWebhooks := req.Form["alertwebhooks"]
+	severityStr := req.FormValue("alertwebhookseverity")
+	if setWebhooks || severityStr != "" {
+		urls, minSeverity := api.siadConfig.AlertWebhooks()
+		if setWebhooks {
+			urls = nil
+			for _, u := range strings.Split(req.FormValue("alertwebhooks"), ",") {
+				if u = strings.TrimSpace(u); u != "" {
+					urls = append(urls, u)
+				}
+			}
+		}
+		if severityStr != "" {
+			var err error
+			minSeverity, err = modules.ParseAlertSeverity(severityStr)
+			if err != nil {
+				WriteError(w, Error{"unable to parse alertwebhookseverity: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+		}
+		if err := api.siadConfig.SetAlertWebhooks(urls, minSeverity); err != nil {
+			WriteError(w, Error{"unable to set alertwebhooks: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
 	// Set the limit.
 	if err := api.siadConfig.SetRatelimit(maxDownloadSpeed, maxUploadSpeed); err != nil {
 		WriteError(w, Error{"unable to set limits: " + err.Error()}, http.StatusBadRequest)
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.sia.tech/siad/modules"
+)
+
+// TestAggregateAlerts verifies the alerts of multiple alerters are aggregated
+// and sorted by severity, with the info alerts last.
+func TestAggregateAlerts(t *testing.T) {
+	alerter1 := modules.NewAlerter("module1")
+	alerter2 := modules.NewAlerter("module2")
+	alerter1.RegisterAlert("info", "msg", "cause", modules.SeverityInfo)
+	alerter1.RegisterAlert("warn", "msg", "cause", modules.SeverityWarning)
+	alerter2.RegisterAlert("info", "msg", "cause", modules.SeverityInfo)
+	alerter2.RegisterAlert("crit", "msg", "cause", modules.SeverityCritical)
+	alerter2.RegisterAlert("err", "msg", "cause", modules.SeverityError)
+
+	dag := aggregateAlerts(modules.SeverityInfo, alerter1, alerter2)
+	if len(dag.Alerts) != 5 {
+		t.Fatal("unexpected number of alerts", len(dag.Alerts))
+	}
+	if dag.NumCriticalAlerts != 1 || dag.NumErrorAlerts != 1 || dag.NumWarningAlerts != 1 || dag.NumInfoAlerts != 2 {
+		t.Fatal("unexpected counts", dag.NumCriticalAlerts, dag.NumErrorAlerts, dag.NumWarningAlerts, dag.NumInfoAlerts)
+	}
+	if len(dag.InfoAlerts) != 2 || len(dag.CriticalAlerts) != 1 {
+		t.Fatal("unexpected alerts", dag.InfoAlerts, dag.CriticalAlerts)
+	}
+
+	// the alerts should be sorted from the highest to the lowest severity
+	for i := 1; i < len(dag.Alerts); i++ {
+		if dag.Alerts[i-1].Severity < dag.Alerts[i].Severity {
+			t.Fatal("alerts are not sorted", dag.Alerts)
+		}
+	}
+	if dag.Alerts[len(dag.Alerts)-1].Severity != modules.SeverityInfo {
+		t.Fatal("info alerts should come last")
+	}
+
+	// filtering by severity excludes the info alerts
+	dag = aggregateAlerts(modules.SeverityWarning, alerter1, alerter2)
+	if len(dag.Alerts) != 3 || dag.NumInfoAlerts != 0 || dag.InfoAlerts == nil {
+		t.Fatal("unexpected alerts", dag.Alerts, dag.NumInfoAlerts)
+	}
+}
+
+// TestAggregateAlertsGroups verifies that alerts of multiple alerters which
+// share a cause or root cause are grouped.
+func TestAggregateAlertsGroups(t *testing.T) {
+	alerter1 := modules.NewAlerter("module1")
+	alerter2 := modules.NewAlerter("module2")
+	alerter3 := modules.NewAlerter("module3")
+	alerter1.RegisterAlert("offline", "msg", "no internet", modules.SeverityWarning)
+	alerter2.RegisterAlert("offline", "msg", "no internet", modules.SeverityError)
+	alerter3.RegisterAlertWithRootCause("peers", "msg", "no peers", "no internet", modules.SeverityWarning)
+	alerter2.RegisterAlert("unknown1", "msg", "", modules.SeverityInfo)
+	alerter3.RegisterAlert("unknown2", "msg", "", modules.SeverityInfo)
+	time.Sleep(time.Millisecond)
+	alerter1.RegisterAlert("funds", "msg", "low funds", modules.SeverityInfo)
+	alerter3.RegisterAlert("funds", "msg", "low funds", modules.SeverityInfo)
+	alerter2.RegisterAlert("disk", "msg", "disk full", modules.SeverityCritical)
+
+	dag := aggregateAlerts(modules.SeverityInfo, alerter1, alerter2, alerter3)
+	if len(dag.Alerts) != 8 {
+		t.Fatal("the flat list should contain all alerts", len(dag.Alerts))
+	}
+	if len(dag.Groups) != 5 {
+		t.Fatal("unexpected number of groups", len(dag.Groups))
+	}
+
+	// the groups should be sorted by severity
+	disk, internet, funds := dag.Groups[0], dag.Groups[1], dag.Groups[2]
+	if disk.Cause != "disk full" || disk.Severity != modules.SeverityCritical || len(disk.Alerts) != 1 {
+		t.Fatal("unexpected group", disk)
+	}
+	if internet.Cause != "no internet" || internet.Severity != modules.SeverityError || len(internet.Alerts) != 3 {
+		t.Fatal("unexpected group", internet)
+	}
+	if strings.Join(internet.Modules, ",") != "module1,module2,module3" {
+		t.Fatal("unexpected modules", internet.Modules)
+	}
+	if funds.Cause != "low funds" || funds.Severity != modules.SeverityInfo || len(funds.Alerts) != 2 {
+		t.Fatal("unexpected group", funds)
+	}
+	if strings.Join(funds.Modules, ",") != "module1,module3" {
+		t.Fatal("unexpected modules", funds.Modules)
+	}
+
+	// alerts without a cause aren't grouped and groups of the same severity
+	// are sorted by their most recent registration
+	for _, group := range dag.Groups[3:] {
+		if group.Cause != "" || len(group.Alerts) != 1 || group.Severity != modules.SeverityInfo {
+			t.Fatal("unexpected group", group)
+		}
+	}
+	for i := 1; i < len(dag.Groups); i++ {
+		prev, cur := dag.Groups[i-1], dag.Groups[i]
+		if prev.Severity == cur.Severity && prev.LastRegistered.Before(cur.LastRegistered) {
+			t.Fatal("groups are not sorted", prev, cur)
+		}
+	}
+
+	// the groups are never null
+	dag = aggregateAlerts(modules.SeverityInfo)
+	if dag.Groups == nil {
+		t.Fatal("groups shouldn't be nil")
+	}
+}
+
+// TestServeAlertEvents verifies that alert events are pushed to websocket
+// clients.
+func TestServeAlertEvents(t *testing.T) {
+	t.Parallel()
+
+	alerter := modules.NewAlerter("module")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := alertEventsUpgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serveAlertEvents(conn, alerter, modules.SeverityWarning)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// register alerts until the subscription is in place, the info alert
+	// should be filtered out
+	var event modules.AlertEvent
+	received := make(chan error)
+	go func() {
+		received <- conn.ReadJSON(&event)
+	}()
+	for done := false; !done; {
+		alerter.RegisterAlert("info", "msg", "cause", modules.SeverityInfo)
+		alerter.RegisterAlert("warn", "msg", "cause", modules.SeverityWarning)
+		select {
+		case err := <-received:
+			if err != nil {
+				t.Fatal(err)
+			}
+			done = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if event.ID != "warn" || !event.Registered || event.Alert.Severity != modules.SeverityWarning {
+		t.Fatal("unexpected event", event)
+	}
+}
@@ -20,6 +20,10 @@ type (
 
 		MaxDownloadSpeed int64 `json:"maxdownloadspeed"`
 		MaxUploadSpeed   int64 `json:"maxuploadspeed"`
+
+		// Alerts are the alerts of the gateway, e.g. about missing outbound
+		// peers or a stalled consensus set, ordered by severity.
+		Alerts []modules.Alert `json:"alerts"`
 	}
 
 	// GatewayBandwidthGET contains the bandwidth usage of the gateway
@@ -86,7 +90,14 @@ func gatewayHandlerGET(gateway modules.Gateway, w http.ResponseWriter, _ *http.R
 	if peers == nil {
 		peers = make([]modules.Peer, 0)
 	}
-	WriteJSON(w, GatewayGET{gateway.Address(), peers, gateway.Online(), mds, mus})
+	online := gateway.Online()
+	crit, err, warn, info := gateway.Alerts()
+	alerts := make([]modules.Alert, 0, len(crit)+len(err)+len(warn)+len(info))
+	alerts = append(alerts, crit...)
+	alerts = append(alerts, err...)
+	alerts = append(alerts, warn...)
+	alerts = append(alerts, info...)
+	WriteJSON(w, GatewayGET{gateway.Address(), peers, online, mds, mus, alerts})
 }
 
 // gatewayHandlerPOST handles the API call changing gateway specific settings.
@@ -73,6 +73,12 @@ type (
 	StorageGET struct {
 		Folders []modules.StorageFolderMetadata `json:"folders"`
 	}
+
+	// StorageFoldersMovePOST contains the information that is returned after
+	// a POST request to /host/storage/folders/move.
+	StorageFoldersMovePOST struct {
+		SectorsMoved uint64 `json:"sectorsmoved"`
+	}
 )
 
 // RegisterRoutesHost is a helper function to register all host routes.
@@ -110,6 +116,15 @@ func RegisterRoutesHost(router *httprouter.Router, h modules.Host, deps modules.
 	router.POST("/host/storage/folders/resize", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		storageFoldersResizeHandler(h, w, req, ps)
 	}, requiredPassword))
+	router.POST("/host/storage/folders/resize/cancel", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		storageFoldersResizeCancelHandler(h, w, req, ps)
+	}, requiredPassword))
+	router.POST("/host/storage/folders/readonly", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		storageFoldersReadOnlyHandler(h, w, req, ps)
+	}, requiredPassword))
+	router.POST("/host/storage/folders/move", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		storageFoldersMoveHandler(h, w, req, ps)
+	}, requiredPassword))
 	router.POST("/host/storage/sectors/delete/:merkleroot", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		storageSectorsDeleteHandler(h, w, req, ps)
 	}, requiredPassword))
@@ -366,6 +381,38 @@ func parseHostSettings(host modules.Host, req *http.Request) (modules.HostIntern
 	if req.FormValue("customregistrypath") != "" {
 		settings.CustomRegistryPath = req.FormValue("customregistrypath")
 	}
+	if req.FormValue("scrubenabled") != "" {
+		var x bool
+		_, err := fmt.Sscan(req.FormValue("scrubenabled"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.ScrubEnabled = x
+	}
+	if req.FormValue("scrubinterval") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("scrubinterval"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.ScrubInterval = time.Duration(x) * time.Second
+	}
+	if req.FormValue("scrubmarkcorrupt") != "" {
+		var x bool
+		_, err := fmt.Sscan(req.FormValue("scrubmarkcorrupt"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.ScrubMarkCorrupt = x
+	}
+	if req.FormValue("scrubmaxbytespersecond") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("scrubmaxbytespersecond"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.ScrubMaxBytesPerSecond = x
+	}
 
 	// Validate the RPC, Sector Access, and Download Prices
 	minBaseRPCPrice := settings.MinBaseRPCPrice
@@ -528,6 +575,99 @@ func storageFoldersResizeHandler(host modules.Host, w http.ResponseWriter, req *
 	WriteSuccess(w)
 }
 
+// storageFoldersResizeCancelHandler cancels the ongoing resize of a storage
+// folder in the storage manager.
+func storageFoldersResizeCancelHandler(host modules.Host, w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	folderPath := req.FormValue("path")
+	if folderPath == "" {
+		WriteError(w, Error{"path parameter is required"}, http.StatusBadRequest)
+		return
+	}
+
+	storageFolders := host.StorageFolders()
+	folderIndex, err := folderIndex(folderPath, storageFolders)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	err = host.CancelStorageFolderResize(uint16(folderIndex))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// storageFoldersReadOnlyHandler marks a storage folder in the storage manager
+// as read-only or as writable again.
+func storageFoldersReadOnlyHandler(host modules.Host, w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	folderPath := req.FormValue("path")
+	if folderPath == "" {
+		WriteError(w, Error{"path parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	readOnly := req.FormValue("readonly") == "true"
+
+	storageFolders := host.StorageFolders()
+	folderIndex, err := folderIndex(folderPath, storageFolders)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	err = host.SetStorageFolderReadOnly(uint16(folderIndex), readOnly)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// storageFoldersMoveHandler moves sectors from one storage folder of the
+// storage manager into another.
+func storageFoldersMoveHandler(host modules.Host, w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sourcePath := req.FormValue("source")
+	if sourcePath == "" {
+		WriteError(w, Error{"source parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	destPath := req.FormValue("destination")
+	if destPath == "" {
+		WriteError(w, Error{"destination parameter is required"}, http.StatusBadRequest)
+		return
+	}
+
+	storageFolders := host.StorageFolders()
+	sourceIndex, err := folderIndex(sourcePath, storageFolders)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	destIndex, err := folderIndex(destPath, storageFolders)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	var maxBytes uint64
+	if mb := req.FormValue("maxbytes"); mb != "" {
+		_, err = fmt.Sscan(mb, &maxBytes)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxbytes: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	moved, err := host.MoveSectors(uint16(sourceIndex), uint16(destIndex), maxBytes)
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("moved %v sectors: %v", moved, err)}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, StorageFoldersMovePOST{
+		SectorsMoved: moved,
+	})
+}
+
 // storageFoldersRemoveHandler removes a storage folder from the storage
 // manager.
 func storageFoldersRemoveHandler(host modules.Host, w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
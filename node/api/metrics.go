@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	"go.sia.tech/siad/modules"
+)
+
+// metricsContentType is the content type of the Prometheus text exposition
+// format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricsSeverities are the severities for which alert counts are exposed.
+var metricsSeverities = []modules.AlertSeverity{
+	modules.SeverityInfo,
+	modules.SeverityWarning,
+	modules.SeverityError,
+	modules.SeverityCritical,
+}
+
+type (
+	// metricsLabel is a label of a metrics sample.
+	metricsLabel struct {
+		name  string
+		value string
+	}
+
+	// metricsSample is a single value of a metric.
+	metricsSample struct {
+		labels []metricsLabel
+		value  float64
+	}
+)
+
+// daemonMetricsHandlerGET handles the API call that returns the daemon's
+// metrics in the Prometheus text exposition format.
+func (api *API) daemonMetricsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	if api.siadConfig == nil || !api.siadConfig.MetricsEnabled() {
+		WriteError(w, Error{"metrics are disabled, enable them using /daemon/settings"}, http.StatusNotFound)
+		return
+	}
+	var buf bytes.Buffer
+	api.writeMetrics(&buf, api.alertAggregator()...)
+	w.Header().Set("Content-Type", metricsContentType)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// writeMetrics writes the daemon's metrics, including the alert counts of the
+// given alerters, to the buffer.
+func (api *API) writeMetrics(buf *bytes.Buffer, alerters ...modules.Alerter) {
+	c, e, w, i := modules.AlertsBySeverity(modules.SeverityInfo, alerters...)
+	var alerts []modules.Alert
+	for _, a := range [][]modules.Alert{c, e, w, i} {
+		alerts = append(alerts, a...)
+	}
+	writeMetric(buf, "siad_alerts", "Number of registered alerts by module and severity.", api.managedAlertCountSamples(alerts))
+
+	if api.cs != nil {
+		writeMetric(buf, "siad_consensus_height", "Height of the consensus set.", []metricsSample{{value: float64(api.cs.Height())}})
+	}
+	if api.gateway != nil {
+		writeMetric(buf, "siad_gateway_peers", "Number of peers of the gateway.", []metricsSample{{value: float64(len(api.gateway.Peers()))}})
+	}
+	if api.renter != nil {
+		writeMetric(buf, "siad_renter_contracts", "Number of contracts of the renter.", []metricsSample{{value: float64(len(api.renter.Contracts()))}})
+	}
+}
+
+// managedAlertCountSamples returns a sample for every severity of every module
+// that ever registered an alert. Modules which no longer have any alerts are
+// reported with a count of zero instead of being dropped.
+func (api *API) managedAlertCountSamples(alerts []modules.Alert) []metricsSample {
+	type key struct {
+		module   string
+		severity modules.AlertSeverity
+	}
+	counts := make(map[key]int)
+
+	api.metricsMu.Lock()
+	if api.metricsModules == nil {
+		api.metricsModules = make(map[string]struct{})
+	}
+	for _, alert := range alerts {
+		api.metricsModules[alert.Module] = struct{}{}
+		counts[key{alert.Module, alert.Severity}]++
+	}
+	mods := make([]string, 0, len(api.metricsModules))
+	for module := range api.metricsModules {
+		mods = append(mods, module)
+	}
+	api.metricsMu.Unlock()
+
+	sort.Strings(mods)
+	samples := make([]metricsSample, 0, len(mods)*len(metricsSeverities))
+	for _, module := range mods {
+		for _, severity := range metricsSeverities {
+			samples = append(samples, metricsSample{
+				labels: []metricsLabel{{"module", module}, {"severity", severity.String()}},
+				value:  float64(counts[key{module, severity}]),
+			})
+		}
+	}
+	return samples
+}
+
+// writeMetric writes a gauge with its samples to the buffer.
+func writeMetric(buf *bytes.Buffer, name, help string, samples []metricsSample) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s gauge\n", name)
+	for _, sample := range samples {
+		buf.WriteString(name)
+		if len(sample.labels) > 0 {
+			labels := make([]string, 0, len(sample.labels))
+			for _, l := range sample.labels {
+				labels = append(labels, fmt.Sprintf("%s=\"%s\"", l.name, escapeMetricsLabelValue(l.value)))
+			}
+			fmt.Fprintf(buf, "{%s}", strings.Join(labels, ","))
+		}
+		fmt.Fprintf(buf, " %v\n", sample.value)
+	}
+}
+
+// escapeMetricsLabelValue escapes backslashes, double quotes and line feeds in
+// a label value as required by the text exposition format.
+func escapeMetricsLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
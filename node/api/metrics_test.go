@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+)
+
+// metricsSampleRegex matches a sample line of the text exposition format.
+var metricsSampleRegex = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{(?:[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\]|\\.)*",?)*\})? (\S+)$`)
+
+// parseMetrics parses metrics in the text exposition format into a map of
+// samples, keyed by the metric name and labels. It returns an error if a line
+// doesn't follow the format or a sample belongs to an undeclared metric.
+func parseMetrics(b []byte) (map[string]float64, error) {
+	samples := make(map[string]float64)
+	declared := make(map[string]bool)
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		if strings.HasPrefix(line, "# TYPE ") {
+			fields := strings.Fields(line)
+			if len(fields) != 4 || fields[3] != "gauge" {
+				return nil, fmt.Errorf("invalid type line %q", line)
+			}
+			declared[fields[2]] = true
+			continue
+		}
+		match := metricsSampleRegex.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("invalid sample line %q", line)
+		}
+		if !declared[match[1]] {
+			return nil, fmt.Errorf("sample of undeclared metric %q", line)
+		}
+		value, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in line %q: %v", line, err)
+		}
+		samples[match[1]+match[2]] = value
+	}
+	return samples, sc.Err()
+}
+
+// TestWriteMetrics verifies that the alert gauges are written in the text
+// exposition format and track the registered alerts.
+func TestWriteMetrics(t *testing.T) {
+	alerter1 := modules.NewAlerter("module1")
+	alerter2 := modules.NewAlerter("module2")
+	alerter3 := modules.NewAlerter("module\"3\"")
+	alerter1.RegisterAlert("warn1", "msg", "cause", modules.SeverityWarning)
+	alerter1.RegisterAlert("warn2", "msg", "cause", modules.SeverityWarning)
+	alerter2.RegisterAlert("crit", "msg", "cause", modules.SeverityCritical)
+	alerter3.RegisterAlert("info", "msg", "cause", modules.SeverityInfo)
+
+	api := &API{}
+	metrics := func() map[string]float64 {
+		var buf bytes.Buffer
+		api.writeMetrics(&buf, alerter1, alerter2, alerter3)
+		samples, err := parseMetrics(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return samples
+	}
+	gauge := func(samples map[string]float64, module string, severity modules.AlertSeverity) float64 {
+		key := fmt.Sprintf(`siad_alerts{module="%s",severity="%s"}`, escapeMetricsLabelValue(module), severity)
+		value, exists := samples[key]
+		if !exists {
+			t.Fatal("missing sample", key)
+		}
+		return value
+	}
+
+	samples := metrics()
+	if len(samples) != 3*len(metricsSeverities) {
+		t.Fatal("unexpected number of samples", len(samples))
+	}
+	if gauge(samples, "module1", modules.SeverityWarning) != 2 || gauge(samples, "module1", modules.SeverityError) != 0 {
+		t.Fatal("unexpected module1 gauges", samples)
+	}
+	if gauge(samples, "module2", modules.SeverityCritical) != 1 {
+		t.Fatal("unexpected module2 gauges", samples)
+	}
+	if gauge(samples, "module\"3\"", modules.SeverityInfo) != 1 {
+		t.Fatal("unexpected module3 gauges", samples)
+	}
+
+	// registering and unregistering alerts should update the gauges, modules
+	// without alerts are still reported
+	alerter1.UnregisterAlert("warn1")
+	alerter2.UnregisterAlert("crit")
+	alerter2.RegisterAlert("err", "msg", "cause", modules.SeverityError)
+	alerter3.UnregisterAlert("info")
+	samples = metrics()
+	if gauge(samples, "module1", modules.SeverityWarning) != 1 {
+		t.Fatal("unexpected module1 gauges", samples)
+	}
+	if gauge(samples, "module2", modules.SeverityCritical) != 0 || gauge(samples, "module2", modules.SeverityError) != 1 {
+		t.Fatal("unexpected module2 gauges", samples)
+	}
+	if gauge(samples, "module\"3\"", modules.SeverityInfo) != 0 {
+		t.Fatal("unexpected module3 gauges", samples)
+	}
+}
+
+// TestDaemonMetricsHandlerGET verifies that the metrics endpoint is only
+// served if enabled in the config.
+func TestDaemonMetricsHandlerGET(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	dir := build.TempDir("api", t.Name())
+	if err := os.MkdirAll(dir, persist.DefaultDiskPermissionsTest); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := modules.NewConfig(filepath.Join(dir, modules.ConfigName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &API{siadConfig: cfg}
+
+	rec := httptest.NewRecorder()
+	api.daemonMetricsHandlerGET(rec, httptest.NewRequest("GET", "/daemon/metrics", nil), nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatal("metrics should be disabled by default", rec.Code)
+	}
+
+	if err := cfg.SetMetricsEnabled(true); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	api.daemonMetricsHandlerGET(rec, httptest.NewRequest("GET", "/daemon/metrics", nil), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatal("unexpected status", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != metricsContentType {
+		t.Fatal("unexpected content type", ct)
+	}
+	if _, err := parseMetrics(rec.Body.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the setting should be persisted
+	cfg, err = modules.NewConfig(filepath.Join(dir, modules.ConfigName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.MetricsEnabled() {
+		t.Fatal("metrics setting wasn't persisted")
+	}
+}
@@ -86,6 +86,7 @@ type (
 	RenterGET struct {
 		Settings         modules.RenterSettings     `json:"settings"`
 		FinancialMetrics modules.ContractorSpending `json:"financialmetrics"`
+		GougingSpending  modules.GougingSpending    `json:"gougingspending"`
 		CurrentPeriod    types.BlockHeight          `json:"currentperiod"`
 		NextPeriod       types.BlockHeight          `json:"nextperiod"`
 
@@ -143,6 +144,13 @@ type (
 		GoodForRenew bool `json:"goodforrenew"`
 		// Signals if a contract has been marked as bad
 		BadContract bool `json:"badcontract"`
+		// Number of sectors tracked by the contract's refcounter.
+		NumSectors uint64 `json:"numsectors"`
+		// Number of sectors that aren't referenced by any file anymore and
+		// can be removed from the contract.
+		GarbageSectors uint64 `json:"garbagesectors"`
+		// Size of the contract's refcounter file in bytes.
+		RefCounterSize uint64 `json:"refcountersize"`
 	}
 
 	// RenterContracts contains the renter's contracts.
@@ -188,6 +196,11 @@ type (
 		MountPoints []modules.MountInfo `json:"mountpoints"`
 	}
 
+	// RenterGougingExemptionsGET lists the renter's gouging exemptions.
+	RenterGougingExemptionsGET struct {
+		Exemptions []modules.GougingExemption `json:"exemptions"`
+	}
+
 	// RenterLoad lists files that were loaded into the renter.
 	RenterLoad struct {
 		FilesAdded []string `json:"filesadded"`
@@ -668,6 +681,11 @@ func (api *API) renterHandlerGET(w http.ResponseWriter, _ *http.Request, _ httpr
 		WriteError(w, Error{"unable to get Period Spending: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
+	gougingSpending, err := api.renter.GougingSpending()
+	if err != nil {
+		WriteError(w, Error{"unable to get gouging spending: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
 	currentPeriod := api.renter.CurrentPeriod()
 	nextPeriod := currentPeriod + settings.Allowance.Period
 	memoryStatus, err := api.renter.MemoryStatus()
@@ -678,6 +696,7 @@ func (api *API) renterHandlerGET(w http.ResponseWriter, _ *http.Request, _ httpr
 	WriteJSON(w, RenterGET{
 		Settings:         settings,
 		FinancialMetrics: spending,
+		GougingSpending:  gougingSpending,
 		CurrentPeriod:    currentPeriod,
 		NextPeriod:       nextPeriod,
 
@@ -959,6 +978,25 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		settings.IPViolationCheck = ipviolationcheck
 	}
 
+	// Scan the priceanomalydetection flag.
+	if pad := req.FormValue("priceanomalydetection"); pad != "" {
+		var priceAnomalyDetection bool
+		if _, err := fmt.Sscan(pad, &priceAnomalyDetection); err != nil {
+			WriteError(w, Error{"unable to parse priceanomalydetection: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.PriceAnomalyDetection = priceAnomalyDetection
+	}
+	// Scan the price anomaly factor.
+	if paf := req.FormValue("priceanomalyfactor"); paf != "" {
+		var priceAnomalyFactor float64
+		if _, err := fmt.Sscan(paf, &priceAnomalyFactor); err != nil {
+			WriteError(w, Error{"unable to parse priceanomalyfactor: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.PriceAnomalyFactor = priceAnomalyFactor
+	}
+
 	// Set the settings in the renter.
 	err = api.renter.SetSettings(settings)
 	if err != nil {
@@ -1136,6 +1174,9 @@ func (api *API) parseRenterContracts(disabled, inactive, expired bool) RenterCon
 			StorageSpendingDeprecated: c.StorageSpending,
 			TotalCost:                 c.TotalCost,
 			UploadSpending:            c.UploadSpending,
+			NumSectors:                c.NumSectors,
+			GarbageSectors:            c.GarbageSectors,
+			RefCounterSize:            c.RefCounterSize,
 		}
 
 		// Determine contract status
@@ -1340,6 +1381,54 @@ func (api *API) renterDownloadByUIDHandlerGET(w http.ResponseWriter, _ *http.Req
 	})
 }
 
+// renterGougingExemptionsHandlerGET handles the API call to
+// /renter/gougingexemptions.
+func (api *API) renterGougingExemptionsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	exemptions, err := api.renter.GougingExemptions()
+	if err != nil {
+		WriteError(w, Error{"unable to get gouging exemptions: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, RenterGougingExemptionsGET{
+		Exemptions: exemptions,
+	})
+}
+
+// renterGougingExemptionsHandlerPOST handles the API call to add or remove a
+// gouging exemption through /renter/gougingexemptions.
+func (api *API) renterGougingExemptionsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the host key.
+	var hostKey types.SiaPublicKey
+	if err := hostKey.LoadString(req.FormValue("hostkey")); err != nil {
+		WriteError(w, Error{"unable to parse hostkey: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	switch action := req.FormValue("action"); action {
+	case "add":
+		// Parse the optional checks.
+		var checks []modules.GougingCheck
+		if checksStr := req.FormValue("checks"); checksStr != "" {
+			for _, check := range strings.Split(checksStr, ",") {
+				checks = append(checks, modules.GougingCheck(check))
+			}
+		}
+		if err := api.renter.AddGougingExemption(hostKey, checks); err != nil {
+			WriteError(w, Error{"unable to add gouging exemption: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	case "remove":
+		if err := api.renter.RemoveGougingExemption(hostKey); err != nil {
+			WriteError(w, Error{"unable to remove gouging exemption: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	default:
+		WriteError(w, Error{fmt.Sprintf("unknown action '%v', must be 'add' or 'remove'", action)}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // renterFuseHandlerGET handles the API call to /renter/fuse.
 func (api *API) renterFuseHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	rfi := RenterFuseInfo{
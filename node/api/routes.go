@@ -35,7 +35,11 @@ func (api *API) buildHTTPRoutes() {
 
 	// Daemon API Calls
 	router.GET("/daemon/alerts", api.daemonAlertsHandlerGET)
+	router.POST("/daemon/alerts/dismiss", RequirePassword(api.daemonAlertsDismissHandlerPOST, requiredPassword))
+	router.POST("/daemon/alerts/snooze", RequirePassword(api.daemonAlertsSnoozeHandlerPOST, requiredPassword))
+	router.GET("/daemon/alerts/ws", api.daemonAlertsWSHandler)
 	router.GET("/daemon/constants", api.daemonConstantsHandler)
+	router.GET("/daemon/metrics", api.daemonMetricsHandlerGET)
 	router.GET("/daemon/settings", api.daemonSettingsHandlerGET)
 	router.POST("/daemon/settings", api.daemonSettingsHandlerPOST)
 	router.GET("/daemon/stack", api.daemonStackHandlerGET)
@@ -99,6 +103,8 @@ func (api *API) buildHTTPRoutes() {
 		router.POST("/renter/recoveryscan", RequirePassword(api.renterRecoveryScanHandlerPOST, requiredPassword))
 		router.GET("/renter/recoveryscan", api.renterRecoveryScanHandlerGET)
 		router.GET("/renter/fuse", api.renterFuseHandlerGET)
+		router.GET("/renter/gougingexemptions", api.renterGougingExemptionsHandlerGET)
+		router.POST("/renter/gougingexemptions", RequirePassword(api.renterGougingExemptionsHandlerPOST, requiredPassword))
 		router.POST("/renter/fuse/mount", RequirePassword(api.renterFuseMountHandlerPOST, requiredPassword))
 		router.POST("/renter/fuse/unmount", RequirePassword(api.renterFuseUnmountHandlerPOST, requiredPassword))
 
@@ -67,6 +67,10 @@ func (srv *Server) Close() error {
 	if !errors.Contains(srv.serveErr, http.ErrServerClosed) {
 		err = errors.Compose(err, srv.serveErr)
 	}
+	// Stop sending alert events.
+	if srv.api != nil {
+		err = errors.Compose(err, srv.api.Close())
+	}
 	// Shutdown modules.
 	if srv.node != nil {
 		err = errors.Compose(err, srv.node.Close())
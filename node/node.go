@@ -342,6 +342,10 @@ func New(params NodeParams, loadStartTime time.Time) (*Node, <-chan error) {
 		errChan <- errors.Extend(err, errors.New("unable to create consensus set"))
 		return nil, errChan
 	}
+	// Let the gateway alert about a consensus set that stops receiving blocks.
+	if gw, ok := g.(*gateway.Gateway); ok && cs != nil {
+		gw.MonitorConsensus(cs)
+	}
 
 	// Explorer.
 	e, err := func() (modules.Explorer, error) {
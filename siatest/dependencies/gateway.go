@@ -15,3 +15,14 @@ type DependencyDisableAutoOnline struct {
 func (d *DependencyDisableAutoOnline) Disrupt(s string) bool {
 	return s == "DisableGatewayAutoOnline"
 }
+
+// DependencyEnableGatewayConnectivityAlerts enables the gateway's alerts about
+// missing outbound peers and a stalled consensus set during testing.
+type DependencyEnableGatewayConnectivityAlerts struct {
+	modules.ProductionDependencies
+}
+
+// Disrupt returns true if the correct string is provided.
+func (d *DependencyEnableGatewayConnectivityAlerts) Disrupt(s string) bool {
+	return s == "EnableGatewayConnectivityAlerts"
+}
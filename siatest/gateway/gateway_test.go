@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -240,6 +241,59 @@ func TestGatewayOfflineAlert(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// The alert should be counted as a warning and should be filtered out when
+	// only asking for errors.
+	dag, err := testNode.DaemonAlertsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dag.NumWarningAlerts != len(dag.WarningAlerts) || dag.NumWarningAlerts == 0 {
+		t.Fatal("unexpected number of warnings", dag.NumWarningAlerts, len(dag.WarningAlerts))
+	}
+	dag, err = testNode.DaemonAlertsGetBySeverity(modules.SeverityError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dag.NumWarningAlerts != 0 || len(dag.WarningAlerts) != 0 || dag.NumInfoAlerts != 0 {
+		t.Fatal("warnings should be filtered", dag.NumWarningAlerts)
+	}
+	for _, alert := range dag.Alerts {
+		if alert.Severity < modules.SeverityError {
+			t.Fatal("alert has wrong severity", alert.Severity)
+		}
+	}
+
+	// Dismiss and snooze the alert, it should still be returned but flagged.
+	err = testNode.DaemonAlertsDismissPost("gateway", modules.AlertIDGatewayOffline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = testNode.DaemonAlertsSnoozePost("gateway", modules.AlertIDGatewayOffline, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag, err = testNode.DaemonAlertsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, alert := range dag.Alerts {
+		if alert.Module == "gateway" && alert.ID == modules.AlertIDGatewayOffline {
+			found = alert.Dismissed && alert.Snoozed()
+		}
+	}
+	if !found {
+		t.Fatal("alert should be dismissed and snoozed", dag.Alerts)
+	}
+	err = testNode.DaemonAlertsDismissPost("gateway", "unknown")
+	if err == nil || !strings.Contains(err.Error(), modules.ErrAlertNotFound.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+	err = testNode.DaemonAlertsSnoozePost("gateway", modules.AlertIDGatewayOffline, 0)
+	if err == nil || !strings.Contains(err.Error(), modules.ErrInvalidSnoozeDuration.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+
 	// Connect nodes.
 	err = build.Retry(100, 100*time.Millisecond, func() error {
 		return testNode.GatewayConnectPost(testNode2.GatewayAddress())
@@ -271,6 +325,89 @@ func TestGatewayOfflineAlert(t *testing.T) {
 	}
 }
 
+// TestGatewayConnectivityAlerts tests that a gateway without outbound peers
+// registers an alert which is returned by /gateway and cleared once the gateway
+// connects to a peer.
+func TestGatewayConnectivityAlerts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testDir := gatewayTestDir(t.Name())
+
+	// Create a gateway with the connectivity alerts enabled.
+	params := node.Gateway(testDir)
+	params.GatewayDeps = &dependencies.DependencyEnableGatewayConnectivityAlerts{}
+	testNode, err := siatest.NewCleanNode(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := testNode.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	testNode2, err := siatest.NewCleanNode(node.Gateway(testDir + "2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := testNode2.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// hasAlert checks whether /gateway returns the no outbound peers alert.
+	hasAlert := func() (bool, error) {
+		gg, err := testNode.GatewayGet()
+		if err != nil {
+			return false, err
+		}
+		for _, alert := range gg.Alerts {
+			if alert.ID == modules.AlertIDGatewayNoOutboundPeers && alert.Severity == modules.SeverityWarning {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// Without outbound peers the alert is registered.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		found, err := hasAlert()
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errors.New("alert not registered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Connect to the second node, the alert should be cleared.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		return testNode.GatewayConnectPost(testNode2.GatewayAddress())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		found, err := hasAlert()
+		if err != nil {
+			return err
+		}
+		if found {
+			return errors.New("alert still registered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestGatewayBandwidth checks that the Gateway's bandwidth is being monitored
 func TestGatewayBandwidth(t *testing.T) {
 	if testing.Short() {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -150,20 +151,45 @@ func testContractorIncompleteMaintenanceAlert(t *testing.T, tg *siatest.TestGrou
 		t.Fatal("Failed to lock wallet", err)
 	}
 	// The renter should have 1 alert once we have mined enough blocks to trigger a
-	// renewal.
-	expectedAlert := modules.Alert{
-		Severity: modules.SeverityWarning,
-		Msg:      contractor.AlertMSGWalletLockedDuringMaintenance,
-		Cause:    modules.ErrLockedWallet.Error(),
-		Module:   "contractor",
-	}
+	// renewal. The cause contains the time at which the wallet was found to be
+	// locked.
+	var cause string
 	err = build.Retry(100, 100*time.Millisecond, func() error {
 		// Mine a block to trigger contract maintenance.
 		if err := tg.Miners()[0].MineBlock(); err != nil {
 			return err
 		}
-		// Make sure the alert is sane.
-		return r.IsAlertRegistered(expectedAlert)
+		dag, err := r.DaemonAlertsGet()
+		if err != nil {
+			return err
+		}
+		for _, alert := range dag.Alerts {
+			if alert.ID != modules.AlertIDWalletLockedDuringMaintenance {
+				continue
+			}
+			if alert.Severity != modules.SeverityWarning || alert.Msg != contractor.AlertMSGWalletLockedDuringMaintenance || alert.Module != "contractor" {
+				return fmt.Errorf("unexpected alert %v", alert)
+			}
+			if !strings.HasPrefix(alert.Cause, modules.ErrLockedWallet.Error()+" since ") {
+				return fmt.Errorf("unexpected cause %v", alert.Cause)
+			}
+			cause = alert.Cause
+			return nil
+		}
+		return errors.New("alert is not registered")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another round of maintenance shouldn't change the cause.
+	if err := tg.Miners()[0].MineBlock(); err != nil {
+		t.Fatal("Failed to mine block", err)
+	}
+	err = r.IsAlertRegistered(modules.Alert{
+		Severity: modules.SeverityWarning,
+		Msg:      contractor.AlertMSGWalletLockedDuringMaintenance,
+		Cause:    cause,
+		Module:   "contractor",
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -172,12 +198,9 @@ func testContractorIncompleteMaintenanceAlert(t *testing.T, tg *siatest.TestGrou
 	if err := r.WalletUnlockPost(wsg.PrimarySeed); err != nil {
 		t.Fatal("Failed to lock wallet", err)
 	}
-	// Mine a block to trigger contract maintenance.
-	if err := tg.Miners()[0].MineBlock(); err != nil {
-		t.Fatal("Failed to mine block", err)
-	}
-	// The renter should have 0 alerts now.
-	err = build.Retry(1000, 100*time.Millisecond, func() error {
+	// The renter should have 0 alerts within seconds without mining another
+	// block.
+	err = build.Retry(50, 100*time.Millisecond, func() error {
 		dag, err = r.DaemonAlertsGet()
 		if err != nil {
 			return err
@@ -187,6 +210,9 @@ func testContractorIncompleteMaintenanceAlert(t *testing.T, tg *siatest.TestGrou
 		}
 		return nil
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 }
 
 // TestRemoveRecoverableContracts makes sure that recoverable contracts which